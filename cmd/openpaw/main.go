@@ -18,7 +18,9 @@ import (
 	"github.com/openpaw/openpaw/internal/agents"
 	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/backup"
+	"github.com/openpaw/openpaw/internal/budget"
 	"github.com/openpaw/openpaw/internal/config"
+	"github.com/openpaw/openpaw/internal/contextindex"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/dreaming"
 	"github.com/openpaw/openpaw/internal/handlers"
@@ -30,14 +32,17 @@ import (
 	"github.com/openpaw/openpaw/internal/memory"
 	"github.com/openpaw/openpaw/internal/models"
 	"github.com/openpaw/openpaw/internal/netutil"
+	"github.com/openpaw/openpaw/internal/notify"
 	"github.com/openpaw/openpaw/internal/platform"
 	"github.com/openpaw/openpaw/internal/providers"
 	"github.com/openpaw/openpaw/internal/scheduler"
 	"github.com/openpaw/openpaw/internal/secrets"
 	"github.com/openpaw/openpaw/internal/server"
+	"github.com/openpaw/openpaw/internal/sharedmemory"
 	"github.com/openpaw/openpaw/internal/terminal"
 	"github.com/openpaw/openpaw/internal/toolmgr"
 	"github.com/openpaw/openpaw/internal/updater"
+	"github.com/openpaw/openpaw/internal/websearch"
 	ws "github.com/openpaw/openpaw/internal/websocket"
 	"github.com/openpaw/openpaw/web"
 )
@@ -67,6 +72,12 @@ func main() {
 		updater.RunUpdateCommand(version, cfg.DataDir)
 	}
 
+	// Handle restore command: rebuild a data dir from the git backup repo
+	if len(os.Args) >= 2 && os.Args[1] == "restore" {
+		backup.RunRestoreCommand(config.Load(), os.Args[2:])
+		os.Exit(0)
+	}
+
 	// Augment PATH so CLI providers (claude, codex, openclaw, …) are
 	// discoverable when launched as a GUI/desktop app, which inherits only a
 	// minimal PATH from launchd/Finder.
@@ -122,6 +133,8 @@ func main() {
 
 	// Initialize services
 	authService := auth.NewService(jwtSecret)
+	authService.SetAPITokenStore(db)
+	authService.SetUserStore(db)
 
 	// Resolve encryption key: env var > database > generate and persist (separate from JWT)
 	encKey := cfg.EncryptionKey
@@ -217,7 +230,8 @@ func main() {
 	apiKey, _ := llm.ResolveAPIKey(envAPIKey, dbAPIKey)
 	llmClient := llm.NewClient(apiKey)
 
-	// Provider router: OpenRouter (default) + subscription CLI providers.
+	// Provider router: OpenRouter (default) + subscription CLI providers +
+	// any self-hosted OpenAI-compatible server configured in Settings.
 	// The MCP registry exposes OpenPaw tools to CLI providers over HTTP.
 	mcpRegistry := mcp.NewRegistry()
 	mcpBaseURL := fmt.Sprintf("http://127.0.0.1:%d/api/v1/mcp/", cfg.Port)
@@ -238,6 +252,13 @@ func main() {
 	providerRouter := llm.NewProviderRouter(llmClient)
 	providerRouter.Register(claudeProvider)
 	providerRouter.Register(codexProvider)
+	providerRouter.Register(llm.NewCompatProvider(handlers.LoadCompatConfig(db, secretsMgr)))
+
+	// WebSearch tool backends (SearXNG / Brave / Google), re-read per search
+	// so a key added in Secrets works without a restart.
+	llm.SetWebSearcher(websearch.NewSearcher(func() []websearch.Backend {
+		return websearch.LoadConfig(db, secretsMgr).Backends()
+	}))
 
 	// Studio's media providers. Keys are read on every call rather than
 	// captured once, so saving one in Settings takes effect without a restart.
@@ -309,6 +330,12 @@ func main() {
 	// credential itself, not just its name.
 	agentMgr.SecretsMgr = secretsMgr
 
+	// Delivers notifications to the configured email, push, chat and webhook
+	// channels. It picks up every new notification on its own; notifyFn kicks
+	// it so these go out straight away.
+	notifier := notify.New(db, secretsMgr)
+	notifier.Start()
+
 	// Wire notification function (creates notification + broadcasts)
 	notifyFn := func(in models.NotificationInput) {
 		n, err := handlers.CreateNotification(db, in)
@@ -317,9 +344,23 @@ func main() {
 			return
 		}
 		broadcastFn("notification_created", n)
+		notifier.Kick()
 	}
 	agentMgr.NotifyFn = notifyFn
 
+	// Spend budgets. Every agent run goes through agentMgr.RunBudgeted, which
+	// checks and records against this.
+	budgetMgr := budget.New(db)
+	budgetMgr.SetNotifyFunc(notifyFn)
+	agentMgr.Budget = budgetMgr
+
+	// External MCP servers. Tool lists are refreshed in the background so a
+	// slow npx download doesn't hold up startup; until then agents get the
+	// lists cached from the last run.
+	mcpClients := mcp.NewClients(db, secretsMgr)
+	agentMgr.MCPClients = mcpClients
+	go mcpClients.RefreshAll(context.Background())
+
 	// Create tool process manager
 	toolMgr := toolmgr.New(db, toolsDir, toolDataDir, broadcastFn, secretsMgr)
 	agentMgr.ToolMgr = toolMgr
@@ -328,11 +369,31 @@ func main() {
 	// Create memory manager
 	memoryMgr := memory.NewManager(cfg.DataDir)
 	agentMgr.MemoryMgr = memoryMgr
+	// Semantic memory search. The backend is re-read on every use, so turning
+	// it on in Settings starts the background backfill without a restart.
+	memoryMgr.SetEmbedder(func() memory.Embedder {
+		return handlers.LoadEmbeddingConfig(db, secretsMgr).Embedder()
+	})
+	memoryMgr.StartEmbeddingIndexer()
+	// Shared memory scopes. Access is looked up on every tool call, so a
+	// grant changed in Settings applies to the agent's next one.
+	sharedMemory := sharedmemory.New(db)
+	memoryMgr.SetScopeResolver(sharedMemory.ScopesFor)
+
+	// Passage index over context documents for search_context. Shares the
+	// memory embedding backend; without one it matches by keyword only.
+	contextIndex := contextindex.New(db, cfg.DataDir)
+	contextIndex.SetEmbedder(func() memory.Embedder {
+		return handlers.LoadEmbeddingConfig(db, secretsMgr).Embedder()
+	})
+	contextIndex.Start()
+	agentMgr.ContextIndex = contextIndex
 
 	// Create dreaming manager — per-reply memory capture plus the scheduled
 	// consolidation pass. Runs on the gateway model, hence agentMgr.
 	dreamingMgr := dreaming.New(db, memoryMgr, agentMgr, broadcastFn)
 	dreamingMgr.SetNotifyFunc(notifyFn)
+	dreamingMgr.SetSharedScopes(sharedMemory)
 	dreamingMgr.LoadConfig()
 
 	// Create terminal manager. New sessions open in the active workspace's files
@@ -422,11 +483,14 @@ func main() {
 		BackupMgr:     backupMgr,
 		MemoryMgr:     memoryMgr,
 		DreamingMgr:   dreamingMgr,
+		SharedMemory:  sharedMemory,
 		TerminalMgr:   terminalMgr,
 		LLMClient:     llmClient,
 		Providers:     providerRouter,
 		MediaRegistry: mediaRegistry,
 		MCPRegistry:   mcpRegistry,
+		MCPClients:    mcpClients,
+		Notifier:      notifier,
 		FrontendFS:    frontendFS,
 		ToolsDir:      toolsDir,
 		DataDir:       cfg.DataDir,
@@ -549,8 +613,15 @@ func main() {
 	// Shut down agents
 	agentMgr.Shutdown()
 
+	// Stop external MCP server processes
+	mcpClients.Close()
+
+	// Stop notification delivery; unsent deliveries resume on the next start
+	notifier.Close()
+
 	// Close memory databases
 	memoryMgr.Close()
+	contextIndex.Close()
 
 	// Shut down terminal sessions
 	terminalMgr.Shutdown()
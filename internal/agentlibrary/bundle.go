@@ -0,0 +1,713 @@
+package agentlibrary
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+// An agent bundle is a zip holding everything that makes an agent what it is
+// on one machine, so it can be set up the same way on another: the agent_roles
+// settings, its identity files, its installed skills, the tools it is granted
+// and, if asked for, its memories.
+//
+//	agent-bundle.json       manifest: settings, tool grants, file hashes
+//	identity/SOUL.md        identity files
+//	skills/<name>/...       the agent's own skill folders
+//	memory/memories.json    active memories (optional)
+//	avatar/<file>           an uploaded avatar
+//
+// USER.md is left out on purpose: it describes the person the agent works
+// with, not the agent, and is the last thing a teammate should inherit.
+const (
+	BundleFormat  = "openpaw-agent-bundle"
+	ManifestFile  = "agent-bundle.json"
+	bundleVersion = 1
+
+	maxBundleFiles    = 500
+	maxBundleFileSize = 10 << 20
+	maxBundleSize     = 100 << 20
+)
+
+// Conflict modes for an import whose slug is already taken.
+const (
+	ConflictRename  = "rename"  // import under the next free slug (default)
+	ConflictReplace = "replace" // overwrite the existing agent
+	ConflictFail    = "fail"    // refuse
+)
+
+// How a bundled tool grant was matched to a local tool.
+const (
+	ToolMatchMapped  = "mapped"
+	ToolMatchLibrary = "library"
+	ToolMatchName    = "name"
+	ToolMatchSkipped = "skipped"
+	ToolMatchMissing = "missing"
+)
+
+var (
+	ErrSlugTaken     = errors.New("an agent with this slug already exists")
+	ErrInvalidBundle = errors.New("invalid agent bundle")
+	ErrInvalidImport = errors.New("invalid import options")
+
+	bundleSlugRegex = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+	bundleIdentityFiles = []string{
+		agents.FileSoul, agents.FileRunbook, agents.FileAgents,
+		agents.FileHeartbeat, agents.FileBoot,
+	}
+)
+
+// BundleAgent is the portable part of an agent_roles row. Ids, ordering,
+// workspace and enabled state belong to the machine, not the agent, and are
+// not carried over.
+type BundleAgent struct {
+	Slug                 string `json:"slug"`
+	Name                 string `json:"name"`
+	Description          string `json:"description"`
+	SystemPrompt         string `json:"system_prompt"`
+	Model                string `json:"model"`
+	Provider             string `json:"provider"`
+	AvatarPath           string `json:"avatar_path"`
+	AvatarDescription    string `json:"avatar_description"`
+	HeartbeatEnabled     bool   `json:"heartbeat_enabled"`
+	HeartbeatIntervalSec int    `json:"heartbeat_interval_sec"`
+	HeartbeatMaxTurns    int    `json:"heartbeat_max_turns"`
+	HeartbeatTimeoutSec  int    `json:"heartbeat_timeout_sec"`
+	LibrarySlug          string `json:"library_slug"`
+	LibraryVersion       string `json:"library_version"`
+	Folder               string `json:"folder"`
+}
+
+// BundleTool is a tool grant. Tool ids are random per machine, so the catalog
+// slug and name travel with it for the importing side to match on.
+type BundleTool struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	LibrarySlug string `json:"library_slug"`
+}
+
+type BundleManifest struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt string            `json:"exported_at"`
+	Agent      BundleAgent       `json:"agent"`
+	Tools      []BundleTool      `json:"tools"`
+	Skills     []string          `json:"skills"`
+	Memories   int               `json:"memories"`
+	Files      map[string]string `json:"files"`
+}
+
+// bundleFile is one file in a bundle. The mode is kept so a skill's
+// scripts/deploy.sh is still executable on the other side.
+type bundleFile struct {
+	data []byte
+	mode fs.FileMode
+}
+
+// ExportBundle packs an agent into a bundle. mem may be nil when memories are
+// not wanted.
+func ExportBundle(db *database.DB, dataDir string, mem *memory.Manager, slug string, withMemory bool) ([]byte, *BundleManifest, error) {
+	m := &BundleManifest{
+		Format:     BundleFormat,
+		Version:    bundleVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Tools:      []BundleTool{},
+		Skills:     []string{},
+		Files:      map[string]string{},
+	}
+	a := &m.Agent
+	err := db.QueryRow(
+		`SELECT slug, name, description, system_prompt, model, provider, avatar_path, avatar_description,
+		        heartbeat_enabled, heartbeat_interval_sec, heartbeat_max_turns, heartbeat_timeout_sec,
+		        library_slug, library_version, folder
+		 FROM agent_roles WHERE slug = ?`, slug,
+	).Scan(&a.Slug, &a.Name, &a.Description, &a.SystemPrompt, &a.Model, &a.Provider, &a.AvatarPath, &a.AvatarDescription,
+		&a.HeartbeatEnabled, &a.HeartbeatIntervalSec, &a.HeartbeatMaxTurns, &a.HeartbeatTimeoutSec,
+		&a.LibrarySlug, &a.LibraryVersion, &a.Folder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := map[string]bundleFile{}
+	agentDir := agents.AgentDir(dataDir, slug)
+	for _, name := range bundleIdentityFiles {
+		data, err := os.ReadFile(filepath.Join(agentDir, name))
+		if err != nil {
+			continue
+		}
+		files["identity/"+name] = bundleFile{data, 0644}
+	}
+
+	skillsDir := filepath.Join(agentDir, "skills")
+	entries, _ := os.ReadDir(skillsDir)
+	for _, e := range entries {
+		if !e.IsDir() || !agents.IsValidSkillName(e.Name()) {
+			continue
+		}
+		root := filepath.Join(skillsDir, e.Name())
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files["skills/"+e.Name()+"/"+filepath.ToSlash(rel)] = bundleFile{data, info.Mode().Perm()}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("read skill %s: %w", e.Name(), err)
+		}
+		m.Skills = append(m.Skills, e.Name())
+	}
+
+	if file := uploadedAvatar(a.AvatarPath); file != "" {
+		if data, err := os.ReadFile(filepath.Join(dataDir, "avatars", file)); err == nil {
+			files["avatar/"+file] = bundleFile{data, 0644}
+		}
+	}
+
+	rows, err := db.Query(
+		`SELECT t.id, t.name, t.library_slug FROM agent_tool_access a
+		 JOIN tools t ON t.id = a.tool_id AND t.deleted_at IS NULL
+		 WHERE a.agent_role_slug = ? ORDER BY t.name`, slug)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read tool grants: %w", err)
+	}
+	for rows.Next() {
+		var t BundleTool
+		if err := rows.Scan(&t.ID, &t.Name, &t.LibrarySlug); err == nil {
+			m.Tools = append(m.Tools, t)
+		}
+	}
+	rows.Close()
+
+	if withMemory && mem != nil {
+		records, err := mem.All(slug)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read memories: %w", err)
+		}
+		if records == nil {
+			records = []memory.Record{}
+		}
+		data, _ := json.MarshalIndent(records, "", "  ")
+		files["memory/memories.json"] = bundleFile{data, 0644}
+		m.Memories = len(records)
+	}
+
+	if len(files) > maxBundleFiles {
+		return nil, nil, fmt.Errorf("too many files (max %d)", maxBundleFiles)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		f := files[name]
+		sum := sha256.Sum256(f.data)
+		m.Files[name] = hex.EncodeToString(sum[:])
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(f.mode)
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return nil, nil, err
+		}
+	}
+	manifest, _ := json.MarshalIndent(m, "", "  ")
+	fw, err := zw.Create(ManifestFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	fw.Write(manifest)
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), m, nil
+}
+
+// uploadedAvatar returns the file name of an avatar the user uploaded, or ""
+// for the built-in ones, which every install already has.
+func uploadedAvatar(avatarPath string) string {
+	const prefix = "/api/v1/uploads/avatars/"
+	if !strings.HasPrefix(avatarPath, prefix) {
+		return ""
+	}
+	return filepath.Base(avatarPath)
+}
+
+// ImportOptions control how a bundle is installed.
+type ImportOptions struct {
+	// Slug overrides the bundle's slug.
+	Slug string
+	// OnConflict is one of the Conflict* modes; empty means ConflictRename.
+	OnConflict string
+	// ToolMap maps a bundled tool id, or its catalog slug, to the local tool
+	// id to grant instead. An empty value drops the grant.
+	ToolMap map[string]string
+	// SkipMemory leaves the bundled memories out.
+	SkipMemory bool
+	// DryRun resolves the slug and tools without writing anything.
+	DryRun bool
+	// ProviderAvailable reports whether a provider can be used here. A bundle
+	// pinned to one that can't falls back to the default provider.
+	ProviderAvailable func(string) bool
+}
+
+// ToolMapping is how one bundled tool grant was resolved.
+type ToolMapping struct {
+	BundleTool
+	LocalID   string `json:"local_id,omitempty"`
+	LocalName string `json:"local_name,omitempty"`
+	Match     string `json:"match"`
+}
+
+type ImportResult struct {
+	Slug     string        `json:"slug"`
+	Name     string        `json:"name"`
+	Renamed  bool          `json:"renamed"`
+	Replaced bool          `json:"replaced"`
+	DryRun   bool          `json:"dry_run"`
+	Tools    []ToolMapping `json:"tools"`
+	Skills   []string      `json:"skills"`
+	Memories int           `json:"memories"`
+	Warnings []string      `json:"warnings"`
+}
+
+// ReadBundle unpacks a bundle and checks every file against the manifest.
+func ReadBundle(data []byte) (*BundleManifest, map[string]bundleFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: not a zip file", ErrInvalidBundle)
+	}
+	if len(zr.File) > maxBundleFiles+1 {
+		return nil, nil, fmt.Errorf("%w: too many files (max %d)", ErrInvalidBundle, maxBundleFiles)
+	}
+
+	files := map[string]bundleFile{}
+	total := 0
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if zf.UncompressedSize64 > maxBundleFileSize {
+			return nil, nil, fmt.Errorf("%w: %s is too large", ErrInvalidBundle, zf.Name)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: open %s", ErrInvalidBundle, zf.Name)
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, maxBundleFileSize+1))
+		rc.Close()
+		if err != nil || len(b) > maxBundleFileSize {
+			return nil, nil, fmt.Errorf("%w: read %s", ErrInvalidBundle, zf.Name)
+		}
+		// Sizes in the zip headers are the sender's claim; count what was read.
+		if total += len(b); total > maxBundleSize {
+			return nil, nil, fmt.Errorf("%w: bundle is too large", ErrInvalidBundle)
+		}
+		// Only the permission bits are honoured, and only the owner's
+		// execute bit among the unusual ones.
+		mode := fs.FileMode(0644)
+		if zf.Mode().Perm()&0100 != 0 {
+			mode = 0755
+		}
+		files[zf.Name] = bundleFile{b, mode}
+	}
+
+	manifest, ok := files[ManifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, ManifestFile)
+	}
+	delete(files, ManifestFile)
+	var m BundleManifest
+	if err := json.Unmarshal(manifest.data, &m); err != nil {
+		return nil, nil, fmt.Errorf("%w: unreadable %s", ErrInvalidBundle, ManifestFile)
+	}
+	if m.Format != BundleFormat {
+		return nil, nil, fmt.Errorf("%w: not an agent bundle", ErrInvalidBundle)
+	}
+	if m.Version > bundleVersion {
+		return nil, nil, fmt.Errorf("%w: bundle version %d is newer than this install supports", ErrInvalidBundle, m.Version)
+	}
+
+	for name, f := range files {
+		if !bundlePathAllowed(name) {
+			return nil, nil, fmt.Errorf("%w: unexpected file %s", ErrInvalidBundle, name)
+		}
+		want, ok := m.Files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: undeclared file %s", ErrInvalidBundle, name)
+		}
+		sum := sha256.Sum256(f.data)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, nil, fmt.Errorf("%w: hash mismatch for %s", ErrInvalidBundle, name)
+		}
+	}
+	for name := range m.Files {
+		if _, ok := files[name]; !ok {
+			return nil, nil, fmt.Errorf("%w: missing declared file %s", ErrInvalidBundle, name)
+		}
+	}
+	return &m, files, nil
+}
+
+// bundlePathAllowed accepts only the paths an export writes, so nothing in an
+// archive can land outside the agent's own directory.
+func bundlePathAllowed(name string) bool {
+	if name == "" || strings.Contains(name, "\\") || path.IsAbs(name) || path.Clean(name) != name {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." || part == "" {
+			return false
+		}
+	}
+	dir, file, _ := strings.Cut(name, "/")
+	switch dir {
+	case "identity":
+		for _, f := range bundleIdentityFiles {
+			if file == f {
+				return true
+			}
+		}
+		return false
+	case "skills":
+		skill, rest, ok := strings.Cut(file, "/")
+		return ok && rest != "" && agents.IsValidSkillName(skill)
+	case "memory":
+		return file == "memories.json"
+	case "avatar":
+		return !strings.Contains(file, "/") && isAvatarFile(file)
+	}
+	return false
+}
+
+func isAvatarFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".webp":
+		return true
+	}
+	return false
+}
+
+// ImportBundle installs a bundle as an agent. With DryRun set it only reports
+// what it would do: the slug the agent would get and how each tool grant
+// resolves, which is what an import dialog shows before committing.
+func ImportBundle(db *database.DB, dataDir string, mem *memory.Manager, data []byte, opts ImportOptions) (*ImportResult, error) {
+	m, files, err := ReadBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	a := m.Agent
+	if strings.TrimSpace(a.Name) == "" {
+		return nil, fmt.Errorf("%w: agent has no name", ErrInvalidBundle)
+	}
+
+	res := &ImportResult{Name: a.Name, DryRun: opts.DryRun, Skills: []string{}, Warnings: []string{}}
+	slug := a.Slug
+	if opts.Slug != "" {
+		slug = opts.Slug
+	}
+	if !bundleSlugRegex.MatchString(slug) || slug == "gateway" {
+		return nil, fmt.Errorf("%w: invalid slug %q", ErrInvalidImport, slug)
+	}
+
+	var existingID string
+	err = db.QueryRow("SELECT id FROM agent_roles WHERE slug = ?", slug).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if existingID != "" {
+		switch opts.OnConflict {
+		case ConflictFail:
+			return nil, ErrSlugTaken
+		case ConflictReplace:
+			res.Replaced = true
+		case "", ConflictRename:
+			slug, err = freeSlug(db, slug)
+			if err != nil {
+				return nil, err
+			}
+			existingID = ""
+			res.Renamed = true
+		default:
+			return nil, fmt.Errorf("%w: unknown conflict mode %q", ErrInvalidImport, opts.OnConflict)
+		}
+	}
+	res.Slug = slug
+
+	res.Tools, err = resolveTools(db, m.Tools, opts.ToolMap)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range res.Tools {
+		if t.Match == ToolMatchMissing {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("no local tool matches %q; its grant was skipped", t.Name))
+		}
+	}
+
+	if a.Provider != "" && opts.ProviderAvailable != nil && !opts.ProviderAvailable(a.Provider) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("provider %q is not set up here; the agent uses the default provider", a.Provider))
+		a.Provider = ""
+	}
+
+	skills := map[string]bool{}
+	var avatarFile string
+	var records []memory.Record
+	for name := range files {
+		switch {
+		case strings.HasPrefix(name, "skills/"):
+			skill, _, _ := strings.Cut(strings.TrimPrefix(name, "skills/"), "/")
+			skills[skill] = true
+		case strings.HasPrefix(name, "avatar/"):
+			avatarFile = strings.TrimPrefix(name, "avatar/")
+		}
+	}
+	for skill := range skills {
+		res.Skills = append(res.Skills, skill)
+	}
+	sort.Strings(res.Skills)
+	if f, ok := files["memory/memories.json"]; ok && !opts.SkipMemory {
+		if err := json.Unmarshal(f.data, &records); err != nil {
+			return nil, fmt.Errorf("%w: unreadable memories.json", ErrInvalidBundle)
+		}
+		if mem == nil && len(records) > 0 {
+			res.Warnings = append(res.Warnings, "memory is unavailable; bundled memories were skipped")
+			records = nil
+		}
+		res.Memories = len(records)
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+
+	if avatarFile != "" {
+		name := uuid.New().String() + strings.ToLower(filepath.Ext(avatarFile))
+		dir := filepath.Join(dataDir, "avatars")
+		if err := os.MkdirAll(dir, 0755); err == nil && os.WriteFile(filepath.Join(dir, name), files["avatar/"+avatarFile].data, 0644) == nil {
+			a.AvatarPath = "/api/v1/uploads/avatars/" + name
+		} else {
+			res.Warnings = append(res.Warnings, "the avatar could not be saved")
+			a.AvatarPath = ""
+		}
+	} else if uploadedAvatar(a.AvatarPath) != "" {
+		// Points at an upload on the exporting machine that didn't come along.
+		a.AvatarPath = ""
+	}
+
+	now := time.Now().UTC()
+	if existingID != "" {
+		_, err = db.Exec(
+			`UPDATE agent_roles SET name = ?, description = ?, system_prompt = ?, model = ?, provider = ?,
+			     avatar_path = ?, avatar_description = ?, heartbeat_enabled = ?, heartbeat_interval_sec = ?,
+			     heartbeat_max_turns = ?, heartbeat_timeout_sec = ?, library_slug = ?, library_version = ?,
+			     folder = ?, identity_initialized = 1, updated_at = ?
+			 WHERE id = ?`,
+			a.Name, a.Description, a.SystemPrompt, a.Model, a.Provider, a.AvatarPath, a.AvatarDescription,
+			a.HeartbeatEnabled, a.HeartbeatIntervalSec, a.HeartbeatMaxTurns, a.HeartbeatTimeoutSec,
+			a.LibrarySlug, a.LibraryVersion, a.Folder, now, existingID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("update agent_roles record: %w", err)
+		}
+	} else {
+		var maxOrder int
+		db.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM agent_roles").Scan(&maxOrder)
+		_, err = db.Exec(
+			`INSERT INTO agent_roles (id, slug, name, description, system_prompt, model, provider, avatar_path, avatar_description,
+			     enabled, sort_order, is_preset, identity_initialized, heartbeat_enabled, heartbeat_interval_sec,
+			     heartbeat_max_turns, heartbeat_timeout_sec, library_slug, library_version, folder, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, 0, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), slug, a.Name, a.Description, a.SystemPrompt, a.Model, a.Provider, a.AvatarPath, a.AvatarDescription,
+			maxOrder+1, a.HeartbeatEnabled, a.HeartbeatIntervalSec, a.HeartbeatMaxTurns, a.HeartbeatTimeoutSec,
+			a.LibrarySlug, a.LibraryVersion, a.Folder, now, now,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("insert agent_roles record: %w", err)
+		}
+	}
+
+	if err := writeBundleFiles(dataDir, slug, a.Name, files, res.Skills); err != nil {
+		if existingID == "" {
+			db.Exec("DELETE FROM agent_roles WHERE slug = ?", slug)
+			os.RemoveAll(agents.AgentDir(dataDir, slug))
+		}
+		return nil, err
+	}
+
+	for _, t := range res.Tools {
+		if t.LocalID == "" {
+			continue
+		}
+		db.Exec(
+			"INSERT OR IGNORE INTO agent_tool_access (id, agent_role_slug, tool_id, granted_at) VALUES (?, ?, ?, ?)",
+			uuid.New().String(), slug, t.LocalID, now,
+		)
+	}
+
+	restored := 0
+	for _, r := range records {
+		if r.ID == "" {
+			r.ID = uuid.New().String()
+		}
+		if err := mem.Restore(slug, r); err != nil {
+			continue
+		}
+		restored++
+	}
+	if restored < len(records) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%d of %d memories could not be restored", len(records)-restored, len(records)))
+	}
+	res.Memories = restored
+
+	logger.Success("Imported agent bundle: %s (%s)", a.Name, slug)
+	return res, nil
+}
+
+// freeSlug finds the first of slug-2, slug-3, ... that no agent uses.
+func freeSlug(db *database.DB, slug string) (string, error) {
+	for i := 2; i < 1000; i++ {
+		candidate := fmt.Sprintf("%s-%d", slug, i)
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM agent_roles WHERE slug = ?)", candidate).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", ErrSlugTaken
+}
+
+// resolveTools matches bundled tool grants to local tools: an explicit entry
+// in toolMap first (by bundled id, then catalog slug), then the same catalog
+// tool installed here, then a tool with the same name.
+func resolveTools(db *database.DB, bundled []BundleTool, toolMap map[string]string) ([]ToolMapping, error) {
+	type localTool struct{ id, name, librarySlug string }
+	rows, err := db.Query("SELECT id, name, library_slug FROM tools WHERE deleted_at IS NULL ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	var local []localTool
+	for rows.Next() {
+		var t localTool
+		if err := rows.Scan(&t.id, &t.name, &t.librarySlug); err == nil {
+			local = append(local, t)
+		}
+	}
+	rows.Close()
+
+	out := []ToolMapping{}
+	for _, bt := range bundled {
+		tm := ToolMapping{BundleTool: bt, Match: ToolMatchMissing}
+		target, mapped := toolMap[bt.ID]
+		if !mapped && bt.LibrarySlug != "" {
+			target, mapped = toolMap[bt.LibrarySlug]
+		}
+		if mapped {
+			tm.Match = ToolMatchSkipped
+			for _, t := range local {
+				if target != "" && t.id == target {
+					tm.LocalID, tm.LocalName, tm.Match = t.id, t.name, ToolMatchMapped
+				}
+			}
+			if target != "" && tm.LocalID == "" {
+				return nil, fmt.Errorf("%w: tool_map names no tool with id %q", ErrInvalidImport, target)
+			}
+			out = append(out, tm)
+			continue
+		}
+		if bt.LibrarySlug != "" {
+			for _, t := range local {
+				if t.librarySlug == bt.LibrarySlug {
+					tm.LocalID, tm.LocalName, tm.Match = t.id, t.name, ToolMatchLibrary
+					break
+				}
+			}
+		}
+		if tm.LocalID == "" {
+			for _, t := range local {
+				if strings.EqualFold(t.name, bt.Name) {
+					tm.LocalID, tm.LocalName, tm.Match = t.id, t.name, ToolMatchName
+					break
+				}
+			}
+		}
+		out = append(out, tm)
+	}
+	return out, nil
+}
+
+// writeBundleFiles puts the identity files and skills in place. Skills in the
+// bundle replace any of the same name; others the agent has are left alone.
+func writeBundleFiles(dataDir, slug, name string, files map[string]bundleFile, skills []string) error {
+	if err := agents.InitAgentDir(dataDir, slug, name, ""); err != nil {
+		return fmt.Errorf("init agent dir: %w", err)
+	}
+	agentDir := agents.AgentDir(dataDir, slug)
+	for _, skill := range skills {
+		if err := os.RemoveAll(filepath.Join(agentDir, "skills", skill)); err != nil {
+			return fmt.Errorf("clear skill %s: %w", skill, err)
+		}
+	}
+	for name, f := range files {
+		var out string
+		switch {
+		case strings.HasPrefix(name, "identity/"):
+			out = filepath.Join(agentDir, strings.TrimPrefix(name, "identity/"))
+		case strings.HasPrefix(name, "skills/"):
+			out = filepath.Join(agentDir, filepath.FromSlash(name))
+		default:
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return fmt.Errorf("create dir for %s: %w", name, err)
+		}
+		if err := os.WriteFile(out, f.data, f.mode); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package agents
+
+import (
+	"context"
+
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/llm"
+)
+
+// RunBudgeted runs one agent loop under the spend budgets: it refuses to start
+// when the run's scope is already at a hard limit, and records what the run
+// cost afterwards — including a failed run's, since the tokens were spent
+// either way.
+//
+// scope names what the caller knows (the agent, usually); whatever it leaves
+// empty comes from the scope already on ctx, so a schedule's ID survives
+// down to the agents it delegates to. The merged scope is put back on ctx for
+// the same reason.
+func (m *Manager) RunBudgeted(ctx context.Context, provider llm.Provider, cfg llm.AgentConfig, userMessage string, scope budget.Scope) (*llm.AgentResult, error) {
+	scope = scope.Merge(budget.ScopeFrom(ctx))
+	if err := m.Budget.Check(scope); err != nil {
+		return nil, err
+	}
+	ctx = budget.WithScope(ctx, scope)
+
+	result, err := provider.RunAgentLoop(ctx, cfg, userMessage)
+	if result != nil {
+		m.Budget.Record(scope, budget.Usage{
+			Provider:     provider.Name(),
+			Model:        cfg.Model,
+			InputTokens:  result.InputTokens,
+			OutputTokens: result.OutputTokens,
+			CostUSD:      result.TotalCostUSD,
+		})
+	}
+	return result, err
+}
+
+// RunOneShotBudgeted is RunBudgeted for a single toolless completion, such as
+// the gateway's routing call on a CLI provider.
+func (m *Manager) RunOneShotBudgeted(ctx context.Context, provider llm.Provider, model, system, prompt string, scope budget.Scope) (string, *llm.UsageInfo, error) {
+	scope = scope.Merge(budget.ScopeFrom(ctx))
+	if err := m.Budget.Check(scope); err != nil {
+		return "", nil, err
+	}
+	ctx = budget.WithScope(ctx, scope)
+
+	text, usage, err := provider.RunOneShot(ctx, model, system, prompt)
+	if usage != nil {
+		m.Budget.Record(scope, budget.Usage{
+			Provider:     provider.Name(),
+			Model:        model,
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			CostUSD:      usage.CostUSD,
+		})
+	}
+	return text, usage, err
+}
+
+// CheckBudget reports whether a run in scope may start, without running it.
+// Used where a refusal has to happen before any side effects — a scheduled
+// prompt writing into its thread, say.
+func (m *Manager) CheckBudget(ctx context.Context, scope budget.Scope) error {
+	return m.Budget.Check(scope.Merge(budget.ScopeFrom(ctx)))
+}
@@ -0,0 +1,64 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/database"
+	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// oneShotProvider answers one-shot calls with a fixed usage and nothing else.
+type oneShotProvider struct{ usage *llm.UsageInfo }
+
+func (p oneShotProvider) Name() string       { return "stub" }
+func (p oneShotProvider) IsConfigured() bool { return true }
+func (p oneShotProvider) RunAgentLoop(context.Context, llm.AgentConfig, string) (*llm.AgentResult, error) {
+	return &llm.AgentResult{}, nil
+}
+func (p oneShotProvider) RunOneShot(context.Context, string, string, string) (string, *llm.UsageInfo, error) {
+	return "{}", p.usage, nil
+}
+func (p oneShotProvider) ResolveModel(name, fallback string) string { return name }
+func (p oneShotProvider) ListModels(context.Context) ([]llm.ModelInfo, error) {
+	return nil, nil
+}
+
+func TestGatewayAndBuildsAreBudgeted(t *testing.T) {
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m := NewManager(db, t.TempDir(), func(string, interface{}) {}, nil)
+	m.Budget = budget.New(db)
+
+	// The routing call is charged to the gateway in the ledger.
+	p := oneShotProvider{usage: &llm.UsageInfo{InputTokens: 10, OutputTokens: 5, CostUSD: 0.02}}
+	if _, _, err := m.RunOneShotBudgeted(context.Background(), p, "haiku", "", "route this", budget.Scope{AgentSlug: "gateway"}); err != nil {
+		t.Fatal(err)
+	}
+	var slug string
+	var cost float64
+	if err := db.QueryRow("SELECT agent_role_slug, cost_usd FROM usage_ledger").Scan(&slug, &cost); err != nil || slug != "gateway" || cost != 0.02 {
+		t.Fatalf("ledger = %q %v (%v), want the gateway charged 0.02", slug, cost, err)
+	}
+
+	// A builder whose budget is spent is refused before it starts.
+	db.Exec("INSERT INTO budgets (id, scope_type, scope_id, period, limit_usd, limit_tokens, soft_percent) VALUES ('b1', ?, 'builder', ?, 0.01, 0, 80)",
+		budget.ScopeAgent, budget.PeriodDaily)
+	m.Budget.Record(budget.Scope{AgentSlug: "builder"}, budget.Usage{CostUSD: 0.05})
+	_, err = m.spawnBuilder(context.Background(), spawnConfig{agentType: "tool_builder"}, &models.WorkOrder{ID: "wo1"}, "", "")
+	var exceeded *budget.ExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("spawn over budget = %v, want a budget refusal", err)
+	}
+	var agents int
+	db.QueryRow("SELECT COUNT(*) FROM agents").Scan(&agents)
+	if agents != 0 {
+		t.Errorf("%d agents started over budget", agents)
+	}
+}
@@ -0,0 +1,77 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/openpaw/openpaw/internal/chatsearch"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/llm"
+)
+
+// BuildChatSearchToolDefs lets agents recall past discussions in their
+// workspace instead of asking the user to find them.
+func BuildChatSearchToolDefs() []llm.ToolDef {
+	return []llm.ToolDef{
+		databaseToolDef("search_chat_history",
+			"Full-text search of this workspace's past chats: message content, thread titles and work orders. Returns snippets with matches in **bold** and the thread_id to cite. Use it when the user refers to an earlier conversation or decision.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{"type": "string", "description": "Words to find; every word must match"},
+					"agent": map[string]interface{}{"type": "string", "description": "Optional agent slug: only that agent's messages, and threads it took part in"},
+					"kinds": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "string", "enum": []string{chatsearch.KindMessage, chatsearch.KindThread, chatsearch.KindWorkOrder}},
+					},
+					"from":   map[string]interface{}{"type": "string", "description": "Earliest date, YYYY-MM-DD"},
+					"to":     map[string]interface{}{"type": "string", "description": "Latest date, YYYY-MM-DD (inclusive)"},
+					"limit":  map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100, "default": 20},
+					"offset": map[string]interface{}{"type": "integer", "minimum": 0},
+				},
+				"required": []string{"query"},
+			}),
+	}
+}
+
+func MakeChatSearchToolHandlers(db *database.DB, workspaceID string) map[string]llm.ToolHandler {
+	return map[string]llm.ToolHandler{
+		"search_chat_history": func(_ context.Context, _ string, input json.RawMessage) llm.ToolResult {
+			var params struct {
+				Query  string   `json:"query"`
+				Agent  string   `json:"agent"`
+				Kinds  []string `json:"kinds"`
+				From   string   `json:"from"`
+				To     string   `json:"to"`
+				Limit  int      `json:"limit"`
+				Offset int      `json:"offset"`
+			}
+			if err := json.Unmarshal(input, &params); err != nil {
+				return databaseToolError(err)
+			}
+			after, before, err := chatsearch.DateRange(params.From, params.To)
+			if err != nil {
+				return databaseToolError(err)
+			}
+			results, err := chatsearch.Search(db, chatsearch.Options{
+				WorkspaceID: workspaceID,
+				Query:       params.Query,
+				Agent:       params.Agent,
+				Kinds:       params.Kinds,
+				After:       after,
+				Before:      before,
+				Limit:       params.Limit,
+				Offset:      params.Offset,
+			})
+			if err != nil {
+				return databaseToolError(err)
+			}
+			return databaseToolJSON(results)
+		},
+	}
+}
+
+func buildChatSearchPromptSection() string {
+	return "## CHAT HISTORY\n" +
+		"`search_chat_history` searches every past conversation in this workspace. When the user mentions something discussed before (\"the schema we picked\", \"what did we decide about...\"), search for it rather than asking them, and cite the thread you found it in.\n"
+}
@@ -0,0 +1,40 @@
+package agents
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSearchChatHistoryToolStaysInWorkspace(t *testing.T) {
+	db, workspaceID := newDatabaseToolsTestDB(t)
+	otherWorkspace := uuid.NewString()
+	_, _ = db.Exec("INSERT INTO workspaces (id, name) VALUES (?, 'Other')", otherWorkspace)
+	_, _ = db.Exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('mine', 'Schema', ?), ('theirs', 'Schema', ?)", workspaceID, otherWorkspace)
+	_, _ = db.Exec(`INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug) VALUES
+		('m1', 'mine', 'assistant', 'We picked the Postgres schema.', 'architect'),
+		('m2', 'theirs', 'assistant', 'Their Postgres schema.', 'architect')`)
+
+	handlers := MakeChatSearchToolHandlers(db, workspaceID)
+	result := callDatabaseTool(t, handlers, "search_chat_history", `{"query":"postgres schema","kinds":["message"]}`)
+	if result.IsError {
+		t.Fatalf("search failed: %s", result.Output)
+	}
+	var found struct {
+		Hits []struct {
+			ID       string `json:"id"`
+			ThreadID string `json:"thread_id"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal([]byte(result.Output), &found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Hits) != 1 || found.Hits[0].ThreadID != "mine" {
+		t.Fatalf("hits = %+v, want only this workspace's message", found.Hits)
+	}
+
+	if bad := callDatabaseTool(t, handlers, "search_chat_history", `{"query":"postgres","from":"last week"}`); !bad.IsError {
+		t.Errorf("a malformed date was accepted: %s", bad.Output)
+	}
+}
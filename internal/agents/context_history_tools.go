@@ -0,0 +1,133 @@
+package agents
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/contexthistory"
+	"github.com/openpaw/openpaw/internal/contextindex"
+	"github.com/openpaw/openpaw/internal/database"
+	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+func buildContextDocumentHistoryDef() llm.ToolDef {
+	return databaseToolDef("context_document_history",
+		"See how a context document changed over time and undo bad edits. Every save by the user or an agent is kept as a revision. action \"list\" shows the revisions (who saved each and when), \"show\" returns one revision's full text, \"diff\" shows a unified diff from the revision before it (or from against_revision_id), and \"restore\" puts the document back the way a revision left it.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":                  map[string]interface{}{"type": "string", "description": "The document's ID from list_context_documents, or its exact name."},
+				"action":              map[string]interface{}{"type": "string", "enum": []string{"list", "show", "diff", "restore"}, "default": "list"},
+				"revision_id":         map[string]interface{}{"type": "string", "description": "The revision to show, diff or restore (from action list)."},
+				"against_revision_id": map[string]interface{}{"type": "string", "description": "Optional earlier revision to diff against."},
+				"limit":               map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 200, "default": 20},
+			},
+			"required": []string{"id"},
+		})
+}
+
+func handleContextDocumentHistory(db *database.DB, index *contextindex.Index, history *contexthistory.Store, workspaceID, agentSlug string, broadcast func(string, interface{})) llm.ToolHandler {
+	return func(ctx context.Context, _ string, input json.RawMessage) llm.ToolResult {
+		var params struct {
+			ID                string `json:"id"`
+			Action            string `json:"action"`
+			RevisionID        string `json:"revision_id"`
+			AgainstRevisionID string `json:"against_revision_id"`
+			Limit             int    `json:"limit"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return databaseToolError(err)
+		}
+		ref := strings.TrimSpace(params.ID)
+		if ref == "" {
+			return llm.ToolResult{Output: "id is required", IsError: true}
+		}
+		var id string
+		err := db.QueryRow(
+			`SELECT id FROM context_files
+			 WHERE is_about_you = 0 AND workspace_id = ? AND (id = ? OR LOWER(name) = LOWER(?))
+			 ORDER BY id = ? DESC LIMIT 1`,
+			workspaceID, ref, ref, ref,
+		).Scan(&id)
+		if err != nil {
+			return llm.ToolResult{Output: fmt.Sprintf("No context document %q. Use list_context_documents to see what exists.", ref), IsError: true}
+		}
+
+		action := params.Action
+		if action == "" {
+			action = "list"
+		}
+		if action != "list" && params.RevisionID == "" {
+			return llm.ToolResult{Output: "revision_id is required for action " + action, IsError: true}
+		}
+		notFound := llm.ToolResult{Output: "That revision doesn't belong to this document. Use action list to see its revisions.", IsError: true}
+
+		switch action {
+		case "list":
+			limit := params.Limit
+			if limit <= 0 {
+				limit = 20
+			}
+			revisions, err := history.List(workspaceID, id, limit, 0)
+			if err != nil {
+				return databaseToolError(err)
+			}
+			return databaseToolJSON(revisions)
+		case "show":
+			revision, err := history.Get(workspaceID, params.RevisionID)
+			if err != nil || revision.FileID != id {
+				return notFound
+			}
+			return databaseToolJSON(revision)
+		case "diff":
+			diff, err := history.Diff(workspaceID, params.RevisionID, params.AgainstRevisionID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && diff.To.FileID != id) {
+				return notFound
+			}
+			if err != nil {
+				return databaseToolError(err)
+			}
+			return databaseToolJSON(diff)
+		case "restore":
+			revision, err := history.Restore(workspaceID, id, params.RevisionID, contextAuthor(ctx, agentSlug))
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound
+			}
+			if err != nil {
+				return databaseToolError(err)
+			}
+			indexContextDocument(index, id)
+			db.LogAudit("system", "context_document_restored", "context", "context_file", id, "agent="+agentSlug+" revision="+params.RevisionID)
+			if broadcast != nil {
+				broadcast("context_updated", map[string]interface{}{"type": "document_updated", "file_id": id})
+			}
+			return databaseToolJSON(revision)
+		}
+		return llm.ToolResult{Output: "action must be list, show, diff or restore", IsError: true}
+	}
+}
+
+// contextAuthor attributes an agent's document save to the agent and the
+// thread it's working in, for the version history.
+func contextAuthor(ctx context.Context, agentSlug string) contexthistory.Author {
+	return contexthistory.Agent(agentSlug, budget.ScopeFrom(ctx).ThreadID)
+}
+
+// recordContextRevision adds an agent's save to the document's history. prev
+// is the document before the save, nil for a new one. A failure is only
+// logged: the save itself has already happened.
+func recordContextRevision(ctx context.Context, history *contexthistory.Store, workspaceID, id, agentSlug string, prev *contexthistory.Snapshot) {
+	next, ok, err := history.Current(workspaceID, id)
+	if err == nil && ok {
+		_, err = history.Record(workspaceID, id, prev, next, contextAuthor(ctx, agentSlug))
+	}
+	if err != nil {
+		logger.Warn("Record revision of context document %s: %v", id, err)
+	}
+}
@@ -38,7 +38,7 @@ func toolFuncs(t *testing.T, db *database.DB, dataDir string) map[string]llmHand
 
 func toolFuncsForWorkspace(t *testing.T, db *database.DB, dataDir, workspaceID string) map[string]llmHandlerMap {
 	t.Helper()
-	raw := MakeContextToolHandlers(db, dataDir, workspaceID, "atlas", nil)
+	raw := MakeContextToolHandlers(db, nil, dataDir, workspaceID, "atlas", nil)
 	out := map[string]llmHandlerMap{}
 	for name, h := range raw {
 		handler := h
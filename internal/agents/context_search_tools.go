@@ -0,0 +1,57 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/openpaw/openpaw/internal/contextindex"
+	"github.com/openpaw/openpaw/internal/llm"
+)
+
+// BuildContextSearchToolDefs lets agents pull the passages that answer a
+// question out of the context library instead of reading documents whole.
+func BuildContextSearchToolDefs() []llm.ToolDef {
+	return []llm.ToolDef{
+		databaseToolDef("search_context",
+			"Search the passages of every context document in this workspace and return the best matches, each with a citation (document name and heading path). Ask in plain words; passages are matched by keyword and, when configured, by meaning.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query":    map[string]interface{}{"type": "string", "description": "The question or topic to find passages about"},
+					"document": map[string]interface{}{"type": "string", "description": "Optional document ID or exact name to search within"},
+					"limit":    map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 20, "default": 5},
+				},
+				"required": []string{"query"},
+			}),
+	}
+}
+
+func MakeContextSearchToolHandlers(index *contextindex.Index, workspaceID string) map[string]llm.ToolHandler {
+	return map[string]llm.ToolHandler{
+		"search_context": func(ctx context.Context, _ string, input json.RawMessage) llm.ToolResult {
+			var params struct {
+				Query    string `json:"query"`
+				Document string `json:"document"`
+				Limit    int    `json:"limit"`
+			}
+			if err := json.Unmarshal(input, &params); err != nil {
+				return databaseToolError(err)
+			}
+			results, err := index.Search(ctx, contextindex.Options{
+				WorkspaceID: workspaceID,
+				Query:       params.Query,
+				Document:    params.Document,
+				Limit:       params.Limit,
+			})
+			if err != nil {
+				return databaseToolError(err)
+			}
+			return databaseToolJSON(results)
+		},
+	}
+}
+
+func buildContextSearchPromptSection() string {
+	return "## CONTEXT SEARCH\n" +
+		"`search_context` finds the passages in this workspace's context documents that answer a question, so you don't have to read long manuals and reports whole. Search before answering anything the documents might cover, and credit each fact you take from a passage with its `citation`, e.g. (Source: Handbook › Leave › Parental leave). Use `read_context_document` when you need the whole document, such as before updating it.\n"
+}
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/contexthistory"
+	"github.com/openpaw/openpaw/internal/contextindex"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/logger"
 )
 
 // BuildContextToolDefs returns tool definitions that let agents create and
@@ -23,19 +26,23 @@ func BuildContextToolDefs() []llm.ToolDef {
 		buildReadContextDocumentDef(),
 		buildUpdateContextDocumentDef(),
 		buildDeleteContextDocumentDef(),
+		buildContextDocumentHistoryDef(),
 	}
 }
 
 // MakeContextToolHandlers returns handler closures for the context-document
 // tools, capturing the data dir (for on-disk storage) and the agentSlug (for
-// audit attribution). The optional broadcast function notifies the frontend.
-func MakeContextToolHandlers(db *database.DB, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) map[string]llm.ToolHandler {
+// audit and revision attribution). The optional broadcast function notifies
+// the frontend, and the optional index is updated when a document is written.
+func MakeContextToolHandlers(db *database.DB, index *contextindex.Index, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) map[string]llm.ToolHandler {
+	history := contexthistory.New(db, dataDir)
 	return map[string]llm.ToolHandler{
-		"create_context_document": handleCreateContextDocument(db, dataDir, workspaceID, agentSlug, broadcast),
-		"list_context_documents":  handleListContextDocuments(db, workspaceID),
-		"read_context_document":   handleReadContextDocument(db, dataDir, workspaceID),
-		"update_context_document": handleUpdateContextDocument(db, dataDir, workspaceID, agentSlug, broadcast),
-		"delete_context_document": handleDeleteContextDocument(db, dataDir, workspaceID, agentSlug, broadcast),
+		"create_context_document":  handleCreateContextDocument(db, index, history, dataDir, workspaceID, agentSlug, broadcast),
+		"list_context_documents":   handleListContextDocuments(db, workspaceID),
+		"read_context_document":    handleReadContextDocument(db, dataDir, workspaceID),
+		"update_context_document":  handleUpdateContextDocument(db, index, history, dataDir, workspaceID, agentSlug, broadcast),
+		"delete_context_document":  handleDeleteContextDocument(db, dataDir, workspaceID, agentSlug, broadcast),
+		"context_document_history": handleContextDocumentHistory(db, index, history, workspaceID, agentSlug, broadcast),
 	}
 }
 
@@ -60,7 +67,7 @@ func buildContextPromptSection(db *database.DB, workspaceID string) string {
 	section := "## CONTEXT DOCUMENTS\n" +
 		"You have full CRUD access to this workspace's reusable Context documents (Markdown): create, list, read, update, and delete. Use a document for prose the user will read or refine—PRDs, specs, research, plans, checklists, big ideas, meeting notes, and curated lists. Use a database instead when the information is a repeated set of structured records that benefits from fields, filtering, sorting, or dashboards.\n\n" +
 		"When durable output would clearly help but the user did not ask to save it, briefly offer to make a Context document or database, whichever fits. Do not interrupt every answer with this offer and do not create one silently. If the user explicitly asks to write, make, save, or keep it, create it directly.\n\n" +
-		"ALWAYS `read_context_document` before `update_context_document`. Updating replaces the entire file, and the user edits these documents by hand between conversations—writing from memory silently destroys whatever they changed. Read it, apply your change to what is actually there, then write the whole document back. Only delete a document when the user explicitly asks; never delete one merely to reorganize."
+		"ALWAYS `read_context_document` before `update_context_document`. Updating replaces the entire file, and the user edits these documents by hand between conversations—writing from memory silently destroys whatever they changed. Read it, apply your change to what is actually there, then write the whole document back. Every save is kept as a revision: if a rewrite lost something, `context_document_history` can diff it against earlier versions and restore one. Only delete a document when the user explicitly asks; never delete one merely to reorganize."
 	if len(names) > 0 {
 		section += "\n\nExisting documents:\n- " + strings.Join(names, "\n- ")
 	}
@@ -190,7 +197,7 @@ func contextDir(dataDir string) string {
 	return filepath.Join(dataDir, "context")
 }
 
-func handleCreateContextDocument(db *database.DB, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) llm.ToolHandler {
+func handleCreateContextDocument(db *database.DB, index *contextindex.Index, history *contexthistory.Store, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
 		var params struct {
 			Name     string `json:"name"`
@@ -238,6 +245,8 @@ func handleCreateContextDocument(db *database.DB, dataDir, workspaceID, agentSlu
 			return llm.ToolResult{Output: "Failed to save document: " + err.Error(), IsError: true}
 		}
 
+		recordContextRevision(ctx, history, workspaceID, id, agentSlug, nil)
+		indexContextDocument(index, id)
 		db.LogAudit("system", "context_document_created", "context", "context_file", id, "agent="+agentSlug+" name="+params.Name)
 		if broadcast != nil {
 			broadcast("context_updated", map[string]interface{}{"type": "document_created", "file_id": id})
@@ -252,6 +261,18 @@ func handleCreateContextDocument(db *database.DB, dataDir, workspaceID, agentSlu
 	}
 }
 
+// indexContextDocument re-chunks a document the agent just wrote, so
+// search_context finds it later in the same conversation. A failure only
+// delays that: search refreshes stale documents before it runs.
+func indexContextDocument(index *contextindex.Index, id string) {
+	if index == nil {
+		return
+	}
+	if err := index.IndexFile(id); err != nil {
+		logger.Warn("Index context document %s: %v", id, err)
+	}
+}
+
 func handleListContextDocuments(db *database.DB, workspaceID string) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
 		rows, err := db.Query(`SELECT id, name, COALESCE(folder_id, ''), size_bytes, updated_at FROM context_files WHERE is_about_you = 0 AND workspace_id = ? ORDER BY updated_at DESC LIMIT 200`, workspaceID)
@@ -309,14 +330,14 @@ func handleReadContextDocument(db *database.DB, dataDir, workspaceID string) llm
 		}
 
 		// Agents are shown document names, not IDs, so accept either.
-		var id, name, filename, mimeType string
+		var id, name, filename, mimeType, textFilename string
 		var updated time.Time
 		err := db.QueryRow(
-			`SELECT id, name, filename, mime_type, updated_at FROM context_files
+			`SELECT id, name, filename, mime_type, text_filename, updated_at FROM context_files
 			 WHERE is_about_you = 0 AND workspace_id = ? AND (id = ? OR LOWER(name) = LOWER(?))
 			 ORDER BY id = ? DESC LIMIT 1`,
 			workspaceID, ref, ref, ref,
-		).Scan(&id, &name, &filename, &mimeType, &updated)
+		).Scan(&id, &name, &filename, &mimeType, &textFilename, &updated)
 		if err != nil {
 			return llm.ToolResult{
 				Output:  fmt.Sprintf("No document %q. Call list_context_documents for the available names and IDs.", ref),
@@ -324,14 +345,15 @@ func handleReadContextDocument(db *database.DB, dataDir, workspaceID string) llm
 			}
 		}
 
-		if !isTextDocument(mimeType) {
+		textPath, ok := contextTextPath(dataDir, filename, textFilename, mimeType)
+		if !ok {
 			return llm.ToolResult{
 				Output:  fmt.Sprintf("%q is a %s file, which cannot be read as text.", name, mimeType),
 				IsError: true,
 			}
 		}
 
-		data, err := os.ReadFile(filepath.Join(contextDir(dataDir), filepath.Base(filename)))
+		data, err := os.ReadFile(textPath)
 		if err != nil {
 			return llm.ToolResult{Output: "Could not read " + name + ": " + err.Error(), IsError: true}
 		}
@@ -354,6 +376,20 @@ func handleReadContextDocument(db *database.DB, dataDir, workspaceID string) llm
 	}
 }
 
+// contextTextPath returns where a document's text lives: the Markdown
+// extracted from an uploaded Word file, spreadsheet, web page or image when
+// there is one, else the file itself if it is text. Mirrors textPath in
+// handlers/context.go.
+func contextTextPath(dataDir, filename, textFilename, mimeType string) (string, bool) {
+	if textFilename != "" {
+		return filepath.Join(contextDir(dataDir), filepath.Base(textFilename)), true
+	}
+	if isTextDocument(mimeType) {
+		return filepath.Join(contextDir(dataDir), filepath.Base(filename)), true
+	}
+	return "", false
+}
+
 // isTextDocument reports whether a context file can be handed to a model as
 // text. Mirrors isTextMime in handlers/context.go.
 func isTextDocument(mime string) bool {
@@ -363,7 +399,7 @@ func isTextDocument(mime string) bool {
 		mime == "application/javascript"
 }
 
-func handleUpdateContextDocument(db *database.DB, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) llm.ToolHandler {
+func handleUpdateContextDocument(db *database.DB, index *contextindex.Index, history *contexthistory.Store, dataDir, workspaceID, agentSlug string, broadcast func(string, interface{})) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
 		var params struct {
 			ID      string  `json:"id"`
@@ -387,14 +423,20 @@ func handleUpdateContextDocument(db *database.DB, dataDir, workspaceID, agentSlu
 			}
 		}
 
-		var filename string
-		if err := db.QueryRow("SELECT filename FROM context_files WHERE id = ? AND workspace_id = ? AND is_about_you = 0", params.ID, workspaceID).Scan(&filename); err != nil {
+		var filename, mimeType, textFilename string
+		if err := db.QueryRow("SELECT filename, mime_type, text_filename FROM context_files WHERE id = ? AND workspace_id = ? AND is_about_you = 0", params.ID, workspaceID).Scan(&filename, &mimeType, &textFilename); err != nil {
 			return llm.ToolResult{Output: "document not found", IsError: true}
 		}
 
+		prev, hasText, _ := history.Current(workspaceID, params.ID)
 		now := time.Now().UTC()
 		if params.Content != nil {
-			diskPath := filepath.Join(contextDir(dataDir), filepath.Base(filename))
+			// A converted upload keeps its original; the edit goes to the
+			// extracted text agents read.
+			diskPath, ok := contextTextPath(dataDir, filename, textFilename, mimeType)
+			if !ok {
+				return llm.ToolResult{Output: "This document is a " + mimeType + " file with no text to edit.", IsError: true}
+			}
 			if err := os.WriteFile(diskPath, []byte(*params.Content), 0644); err != nil {
 				return llm.ToolResult{Output: "Failed to write document: " + err.Error(), IsError: true}
 			}
@@ -408,6 +450,10 @@ func handleUpdateContextDocument(db *database.DB, dataDir, workspaceID, agentSlu
 			}
 		}
 
+		if hasText {
+			recordContextRevision(ctx, history, workspaceID, params.ID, agentSlug, &prev)
+		}
+		indexContextDocument(index, params.ID)
 		db.LogAudit("system", "context_document_updated", "context", "context_file", params.ID, "agent="+agentSlug)
 		if broadcast != nil {
 			broadcast("context_updated", map[string]interface{}{"type": "document_updated", "file_id": params.ID})
@@ -431,17 +477,20 @@ func handleDeleteContextDocument(db *database.DB, dataDir, workspaceID, agentSlu
 			return llm.ToolResult{Output: "id is required", IsError: true}
 		}
 
-		var name, filename string
+		var name, filename, textFilename string
 		if err := db.QueryRow(
-			"SELECT name, filename FROM context_files WHERE id = ? AND workspace_id = ? AND is_about_you = 0",
+			"SELECT name, filename, text_filename FROM context_files WHERE id = ? AND workspace_id = ? AND is_about_you = 0",
 			params.ID, workspaceID,
-		).Scan(&name, &filename); err != nil {
+		).Scan(&name, &filename, &textFilename); err != nil {
 			return llm.ToolResult{Output: "document not found", IsError: true}
 		}
 
 		if err := os.Remove(filepath.Join(contextDir(dataDir), filepath.Base(filename))); err != nil && !os.IsNotExist(err) {
 			return llm.ToolResult{Output: "Failed to remove stored document: " + err.Error(), IsError: true}
 		}
+		if textFilename != "" {
+			os.Remove(filepath.Join(contextDir(dataDir), filepath.Base(textFilename)))
+		}
 		if _, err := db.Exec("DELETE FROM context_files WHERE id = ? AND workspace_id = ?", params.ID, workspaceID); err != nil {
 			return llm.ToolResult{Output: "Failed to delete document record: " + err.Error(), IsError: true}
 		}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/openpaw/openpaw/internal/budget"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/userdb"
@@ -21,14 +22,45 @@ func BuildDatabaseToolDefs() []llm.ToolDef {
 			"List the databases in this workspace with every table name, table ID, column name/type/ID, and row count. Use this before querying or changing stored structured data.",
 			map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}),
 		databaseToolDef("query_database",
-			"Read and search rows in one database table. Returns both a table-shaped columns/rows result and records keyed by column name. Use this to answer questions from saved structured data.",
+			"Read, filter and summarize rows in one database table. Returns both a table-shaped columns/rows result and records keyed by column name. "+
+				"Use filter for typed conditions, sort for ordering, and group_by/aggregates to count, sum or average instead of reading every row. Columns can be named by name or ID.",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"table_id": map[string]interface{}{"type": "string", "description": "Table ID from list_databases"},
 					"search":   map[string]interface{}{"type": "string", "description": "Optional case-insensitive text search across every column"},
-					"limit":    map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 500, "default": 100},
-					"offset":   map[string]interface{}{"type": "integer", "minimum": 0, "default": 0},
+					"filter": map[string]interface{}{
+						"type": "object",
+						"description": "A condition {\"column\":\"Amount\",\"op\":\"gt\",\"value\":100} or a group {\"and\":[...]} / {\"or\":[...]} of filters. " +
+							"Ops: eq, neq, gt, gte, lt, lte, contains, not_contains, starts_with, is_empty, not_empty, in, not_in (value is a list), between (value is [low, high]), " +
+							"within_last, within_next (date columns; value is a number of days). Date values may be YYYY-MM-DD, today, yesterday, -7d or +30d.",
+					},
+					"sort": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"column":    map[string]interface{}{"type": "string"},
+								"direction": map[string]interface{}{"type": "string", "enum": []string{"asc", "desc"}},
+							},
+							"required": []string{"column"},
+						},
+					},
+					"group_by": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Columns to group by; each result row is then one group"},
+					"aggregates": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"op":     map[string]interface{}{"type": "string", "enum": []string{"count", "sum", "avg", "min", "max"}},
+								"column": map[string]interface{}{"type": "string", "description": "Optional for count"},
+								"as":     map[string]interface{}{"type": "string", "description": "Result column name"},
+							},
+							"required": []string{"op"},
+						},
+					},
+					"limit":  map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 500, "default": 100},
+					"offset": map[string]interface{}{"type": "integer", "minimum": 0, "default": 0},
 				},
 				"required": []string{"table_id"},
 			}),
@@ -65,7 +97,8 @@ func BuildDatabaseToolDefs() []llm.ToolDef {
 				"required": []string{"name"},
 			}),
 		databaseToolDef("alter_database",
-			"Change database structure or metadata. Supports update/delete database, create/update/delete table, and add/update/delete column. Use update_table with table_id and name to rename a table.",
+			"Change database structure or metadata. Supports update/delete database, create/update/delete table, and add/update/delete column. Use update_table with table_id and name to rename a table. "+
+				"Besides the plain types, add_column can create link columns (rows of another table in the same database), lookup and rollup columns that read through a link, and formula columns computed from the row. Lookup, rollup and formula values are computed, never written.",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -78,8 +111,13 @@ func BuildDatabaseToolDefs() []llm.ToolDef {
 					"column_id":   map[string]interface{}{"type": "string"},
 					"name":        map[string]interface{}{"type": "string"},
 					"description": map[string]interface{}{"type": "string"},
-					"column_type": map[string]interface{}{"type": "string", "enum": []string{"text", "long_text", "number", "checkbox", "date", "url", "email", "select"}},
-					"options":     map[string]interface{}{"type": "object"},
+					"column_type": map[string]interface{}{"type": "string", "enum": []string{"text", "long_text", "number", "checkbox", "date", "url", "email", "select", "link", "lookup", "rollup", "formula"}},
+					"options": map[string]interface{}{
+						"type": "object",
+						"description": "select: {\"choices\":[...]}. link: {\"table_id\":\"...\"}. lookup: {\"link_column_id\":\"...\",\"target_column_id\":\"...\"}. " +
+							"rollup: the same plus \"function\": count, sum, avg, min, max or concat. formula: {\"expression\":\"{Price} * {Qty}\"} using + - * / & = != < > <= >=, " +
+							"IF, AND, OR, NOT, BLANK, ROUND, ABS, MIN, MAX, SUM, AVG, COUNT, LEN, UPPER, LOWER, TRIM, CONCAT, TODAY, DAYS.",
+					},
 				},
 				"required": []string{"action"},
 			}),
@@ -91,7 +129,7 @@ func BuildDatabaseToolDefs() []llm.ToolDef {
 					"action":   map[string]interface{}{"type": "string", "enum": []string{"create", "update", "delete"}},
 					"table_id": map[string]interface{}{"type": "string"},
 					"row_id":   map[string]interface{}{"type": "string"},
-					"values":   map[string]interface{}{"type": "object", "description": "Column-name to value mapping, e.g. {\"Name\":\"OpenPaw\",\"Status\":\"Active\"}"},
+					"values":   map[string]interface{}{"type": "object", "description": "Column-name to value mapping, e.g. {\"Name\":\"OpenPaw\",\"Status\":\"Active\"}. A link column takes a list of row IDs from its linked table."},
 				},
 				"required": []string{"action"},
 			}),
@@ -153,7 +191,7 @@ func buildDatabasesPromptSection(db *database.DB, workspaceID string) string {
 		}
 		lines = append(lines, fmt.Sprintf("- %s (ID: `%s`) — %s", item.Name, item.ID, strings.Join(tables, ", ")))
 	}
-	return "## DATABASES\nWorkspace databases hold durable structured records. Use them for repeated items with fields, filtering, sorting, or dashboards; use a Context document for long-form prose such as PRDs, specs, plans, and big ideas. Use `list_databases` for schema IDs, `query_database` to read/search, and database mutation tools when asked to save or maintain records. Dashboard builders can connect widgets directly to these tables. If persistence would help but was not requested, briefly offer the appropriate format rather than creating it silently.\n" +
+	return "## DATABASES\nWorkspace databases hold durable structured records. Use them for repeated items with fields, filtering, sorting, or dashboards; use a Context document for long-form prose such as PRDs, specs, plans, and big ideas. Use `list_databases` for schema IDs, `query_database` to read, filter and summarize (group_by and aggregates answer how-many and how-much questions without reading every row), and database mutation tools when asked to save or maintain records. Dashboard builders can connect widgets directly to these tables. If persistence would help but was not requested, briefly offer the appropriate format rather than creating it silently.\n" +
 		strings.Join(lines, "\n") + "\n"
 }
 
@@ -177,9 +215,7 @@ func handleQueryDatabase(store *userdb.Store, workspaceID string) llm.ToolHandle
 	return func(_ context.Context, _ string, input json.RawMessage) llm.ToolResult {
 		var params struct {
 			TableID string `json:"table_id"`
-			Search  string `json:"search"`
-			Limit   int    `json:"limit"`
-			Offset  int    `json:"offset"`
+			userdb.Query
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return databaseToolError(err)
@@ -187,7 +223,7 @@ func handleQueryDatabase(store *userdb.Store, workspaceID string) llm.ToolHandle
 		if params.TableID == "" {
 			return databaseToolError(fmt.Errorf("table_id is required"))
 		}
-		rows, err := store.NamedRows(workspaceID, params.TableID, params.Search, params.Limit, params.Offset)
+		rows, err := store.Query(workspaceID, params.TableID, params.Query)
 		if err != nil {
 			return databaseToolError(err)
 		}
@@ -207,7 +243,8 @@ type databaseTableInput struct {
 }
 
 func handleCreateDatabase(db *database.DB, store *userdb.Store, workspaceID, actor string, changed func(string, string)) llm.ToolHandler {
-	return func(_ context.Context, _ string, input json.RawMessage) llm.ToolResult {
+	return func(ctx context.Context, _ string, input json.RawMessage) llm.ToolResult {
+		store := store.As(databaseActor(ctx, actor))
 		var params struct {
 			Name        string               `json:"name"`
 			Description string               `json:"description"`
@@ -278,7 +315,8 @@ func configureStarterTable(store *userdb.Store, workspaceID string, table userdb
 }
 
 func handleAlterDatabase(db *database.DB, store *userdb.Store, workspaceID, actor string, changed func(string, string)) llm.ToolHandler {
-	return func(_ context.Context, _ string, input json.RawMessage) llm.ToolResult {
+	return func(ctx context.Context, _ string, input json.RawMessage) llm.ToolResult {
+		store := store.As(databaseActor(ctx, actor))
 		var params struct {
 			Action      string                 `json:"action"`
 			DatabaseID  string                 `json:"database_id"`
@@ -374,7 +412,8 @@ func handleAlterDatabase(db *database.DB, store *userdb.Store, workspaceID, acto
 }
 
 func handleDatabaseRows(db *database.DB, store *userdb.Store, workspaceID, actor string, changed func(string, string)) llm.ToolHandler {
-	return func(_ context.Context, _ string, input json.RawMessage) llm.ToolResult {
+	return func(ctx context.Context, _ string, input json.RawMessage) llm.ToolResult {
+		store := store.As(databaseActor(ctx, actor))
 		var params struct {
 			Action  string                 `json:"action"`
 			TableID string                 `json:"table_id"`
@@ -434,6 +473,13 @@ func handleDatabaseRows(db *database.DB, store *userdb.Store, workspaceID, actor
 	}
 }
 
+// databaseActor attributes a tool's database writes to the agent and to the
+// thread or schedule run it's working in, for the row history.
+func databaseActor(ctx context.Context, actor string) userdb.Actor {
+	scope := budget.ScopeFrom(ctx)
+	return userdb.Actor{Actor: actor, ThreadID: scope.ThreadID, ScheduleID: scope.ScheduleID, ExecutionID: scope.ExecutionID}
+}
+
 func databaseToolJSON(value interface{}) llm.ToolResult {
 	raw, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/budget"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
 )
@@ -311,7 +312,23 @@ func (m *Manager) roleChatSubAgent(ctx context.Context, agentSlug, task, threadI
 		}
 	}
 
-	result, err := provider.RunAgentLoop(subCtx, cfg, task)
+	if m.MCPClients != nil {
+		mcpDefs, mcpHandlers := m.MCPClients.ToolsFor(agentSlug, m.threadWorkspaceID(threadID))
+		cfg.ExtraTools = append(cfg.ExtraTools, mcpDefs...)
+		if cfg.ExtraHandlers == nil && len(mcpHandlers) > 0 {
+			cfg.ExtraHandlers = map[string]llm.ToolHandler{}
+		}
+		for name, handler := range mcpHandlers {
+			cfg.ExtraHandlers[name] = handler
+		}
+	}
+
+	result, err := m.RunBudgeted(subCtx, provider, cfg, task, budget.Scope{
+		AgentSlug:   agentSlug,
+		WorkspaceID: m.threadWorkspaceID(threadID),
+		ThreadID:    threadID,
+		Source:      budget.SourceSubagent,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("sub-agent %s failed: %w", agentSlug, err)
 	}
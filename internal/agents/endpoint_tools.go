@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+// Capabilities an admin can expose through the MCP endpoint. Each one maps to
+// a group of the same tools agents use in chat, so an outside client gets
+// exactly the behaviour (and workspace scoping) an agent would.
+const (
+	EndpointDatabases = "databases"
+	EndpointMemory    = "memory"
+	EndpointInbox     = "inbox"
+	EndpointSchedules = "schedules"
+	EndpointServices  = "services"
+)
+
+// EndpointCapabilities lists every capability, in the order the settings UI shows them.
+var EndpointCapabilities = []string{EndpointDatabases, EndpointMemory, EndpointInbox, EndpointSchedules, EndpointServices}
+
+// EndpointTools assembles the tools an MCP client may call in a workspace.
+// canWrite is false for viewers: they get the read half of each capability
+// and nothing that changes data or starts work.
+func (m *Manager) EndpointTools(workspaceID string, caps []string, canWrite bool) ([]llm.ToolDef, map[string]llm.ToolHandler) {
+	enabled := map[string]bool{}
+	for _, c := range caps {
+		enabled[c] = true
+	}
+	var defs []llm.ToolDef
+	handlers := map[string]llm.ToolHandler{}
+	add := func(pool []llm.ToolDef, poolHandlers map[string]llm.ToolHandler, names ...string) {
+		for _, name := range names {
+			for _, d := range pool {
+				if d.Function.Name == name && poolHandlers[name] != nil {
+					defs = append(defs, d)
+					handlers[name] = poolHandlers[name]
+				}
+			}
+		}
+	}
+
+	if enabled[EndpointDatabases] {
+		dbHandlers := MakeDatabaseToolHandlers(m.db, workspaceID, "", m.broadcast)
+		add(BuildDatabaseToolDefs(), dbHandlers, "list_databases", "query_database")
+		if canWrite {
+			add(BuildDatabaseToolDefs(), dbHandlers, "database_rows")
+		}
+	}
+
+	if enabled[EndpointMemory] && m.MemoryMgr != nil {
+		defs = append(defs, searchMemoriesDef())
+		handlers["search_memories"] = m.handleSearchMemories()
+	}
+
+	if enabled[EndpointInbox] {
+		inboxHandlers := MakeInboxToolHandlers(m.db, workspaceID, "", m.broadcast)
+		add(BuildInboxToolDefs(), inboxHandlers, "list_inbox_posts")
+		if canWrite {
+			defs = append(defs, createInboxPostDef())
+			manage := inboxHandlers["manage_inbox_post"]
+			handlers["create_inbox_post"] = func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
+				var fields map[string]interface{}
+				if err := json.Unmarshal(input, &fields); err != nil {
+					return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
+				}
+				fields["action"] = "create"
+				if _, ok := fields["source_type"]; !ok {
+					fields["source_type"] = "mcp"
+				}
+				forced, _ := json.Marshal(fields)
+				return manage(ctx, workDir, forced)
+			}
+		}
+	}
+
+	if enabled[EndpointSchedules] {
+		// Scoped to the client's workspace, unlike an agent's schedule tools:
+		// a client must not see or start another workspace's routines.
+		scheduleHandlers := map[string]llm.ToolHandler{"schedule_list": m.handleScheduleList(workspaceID)}
+		if canWrite {
+			scheduleHandlers["schedule_run_now"] = m.handleScheduleRunNow(workspaceID)
+		}
+		add(BuildScheduleToolDefs(canWrite), scheduleHandlers, "schedule_list", "schedule_run_now")
+	}
+
+	if enabled[EndpointServices] && m.ToolMgr != nil {
+		defs = append(defs, listServicesDef())
+		handlers["list_services"] = func(_ context.Context, _ string, _ json.RawMessage) llm.ToolResult {
+			section := m.buildToolsPromptSection("", workspaceID)
+			if section == "" {
+				return llm.ToolResult{Output: "No services are running in this workspace."}
+			}
+			return llm.ToolResult{Output: section}
+		}
+		if canWrite {
+			defs = append(defs, llm.BuildCallToolDef())
+			handlers["call_tool"] = m.makeCallToolHandler(workspaceID)
+		}
+	}
+
+	return defs, handlers
+}
+
+// searchMemoriesDef is memory_search with an agent argument: an outside client
+// is nobody in particular, so it has to say whose memories it is searching.
+func searchMemoriesDef() llm.ToolDef {
+	var search llm.ToolDef
+	for _, d := range memory.BuildMemoryToolDefs() {
+		if d.Function.Name == "memory_search" {
+			search = d
+		}
+	}
+	var schema map[string]interface{}
+	json.Unmarshal(search.Function.Parameters, &schema)
+	props, _ := schema["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		schema = map[string]interface{}{"type": "object", "properties": props}
+	}
+	props["agent"] = map[string]interface{}{"type": "string", "description": "Slug of the agent whose memories to search"}
+	schema["required"] = []string{"agent", "query"}
+	params, _ := json.Marshal(schema)
+
+	return llm.ToolDef{Type: "function", Function: llm.FunctionDef{
+		Name:        "search_memories",
+		Description: "Search one agent's long-term memory by keyword and, when semantic search is configured, by meaning. Results are ranked by relevance.",
+		Parameters:  params,
+	}}
+}
+
+func (m *Manager) handleSearchMemories() llm.ToolHandler {
+	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
+		var params struct {
+			Agent string `json:"agent"`
+		}
+		json.Unmarshal(input, &params)
+		slug := strings.TrimSpace(params.Agent)
+		if slug == "" {
+			return llm.ToolResult{Output: "agent is required", IsError: true}
+		}
+		// The slug names a file on disk; only ever open one for a real agent.
+		var n int
+		if err := m.db.QueryRow("SELECT COUNT(*) FROM agent_roles WHERE slug = ?", slug).Scan(&n); err != nil || n == 0 {
+			return llm.ToolResult{Output: "No agent with that slug.", IsError: true}
+		}
+		m.MemoryMgr.EnsureMigrated(slug)
+		return m.MemoryMgr.MakeMemoryHandlers(slug)["memory_search"](ctx, workDir, input)
+	}
+}
+
+func createInboxPostDef() llm.ToolDef {
+	return databaseToolDef("create_inbox_post",
+		"Post a report or note to this workspace's Inbox, where the user will see it alongside scheduled reports.",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"title":    map[string]interface{}{"type": "string"},
+				"body":     map[string]interface{}{"type": "string", "description": "Short preview"},
+				"detail":   map[string]interface{}{"type": "string", "description": "Full markdown post/report"},
+				"priority": map[string]interface{}{"type": "string", "enum": []string{"low", "normal", "high"}},
+				"link":     map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"title"},
+		})
+}
+
+func listServicesDef() llm.ToolDef {
+	return databaseToolDef("list_services",
+		"List the custom services available in this workspace, with their IDs and endpoints, for use with call_tool.",
+		map[string]interface{}{"type": "object", "properties": map[string]interface{}{}})
+}
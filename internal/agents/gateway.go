@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/budget"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -107,13 +108,17 @@ func (m *Manager) GatewayAnalyze(ctx context.Context, userMessage, threadID stri
 	var outputText string
 	var usage *llm.UsageInfo
 
+	// Routing is a run like any other: it is refused once the gateway's or the
+	// workspace's budget is spent, and what it costs is charged to them.
+	scope := budget.Scope{AgentSlug: "gateway", WorkspaceID: workspaceID, ThreadID: threadID}
+
 	if provider := m.Provider(); provider.Name() != llm.ProviderOpenRouter {
 		// CLI providers (Claude Code / Codex): a single one-shot call with a
 		// strict JSON instruction. No tools — todo tools are an OpenRouter-loop
 		// nicety the routing decision doesn't depend on.
-		text, u, err := provider.RunOneShot(ctx,
+		text, u, err := m.RunOneShotBudgeted(ctx, provider,
 			provider.ResolveModel(m.GatewayModel, llm.ModelHaiku), "",
-			prompt+"\n\nRespond with ONLY a single JSON object as specified by the instructions above — no prose, no markdown fences.")
+			prompt+"\n\nRespond with ONLY a single JSON object as specified by the instructions above — no prose, no markdown fences.", scope)
 		if err != nil {
 			return nil, nil, fmt.Errorf("gateway agent failed: %w", err)
 		}
@@ -126,7 +131,7 @@ func (m *Manager) GatewayAnalyze(ctx context.Context, userMessage, threadID stri
 		todoTools := BuildTodoToolDefs()
 		todoHandlers := MakeTodoToolHandlers(m.db, "pounce", m.broadcast)
 
-		result, err := m.client.RunAgentLoop(ctx, llm.AgentConfig{
+		result, err := m.RunBudgeted(ctx, m.client, llm.AgentConfig{
 			Model:         llm.ResolveModel(m.GatewayModel, llm.ModelHaiku),
 			System:        "",
 			MaxTurns:      3,
@@ -140,7 +145,7 @@ func (m *Manager) GatewayAnalyze(ctx context.Context, userMessage, threadID stri
 					})
 				}
 			},
-		}, prompt)
+		}, prompt, scope)
 		if err != nil {
 			return nil, nil, fmt.Errorf("gateway agent failed: %w", err)
 		}
@@ -710,9 +715,16 @@ func (m *Manager) RoleChat(ctx context.Context, systemPrompt, model string, hist
 	if cfg.ExtraHandlers == nil {
 		cfg.ExtraHandlers = map[string]llm.ToolHandler{}
 	}
-	for name, handler := range MakeContextToolHandlers(m.db, m.DataDir, wsID, agentRoleSlug, m.broadcast) {
+	for name, handler := range MakeContextToolHandlers(m.db, m.ContextIndex, m.DataDir, wsID, agentRoleSlug, m.broadcast) {
 		cfg.ExtraHandlers[name] = handler
 	}
+	if m.ContextIndex != nil {
+		cfg.System += "\n\n---\n\n" + buildContextSearchPromptSection()
+		cfg.ExtraTools = append(cfg.ExtraTools, BuildContextSearchToolDefs()...)
+		for name, handler := range MakeContextSearchToolHandlers(m.ContextIndex, wsID) {
+			cfg.ExtraHandlers[name] = handler
+		}
+	}
 
 	// Workspace databases provide durable structured data for interactive chats
 	// and unattended schedules alike. Scope by this run's workspace rather than
@@ -732,6 +744,13 @@ func (m *Manager) RoleChat(ctx context.Context, systemPrompt, model string, hist
 		cfg.ExtraHandlers[name] = handler
 	}
 
+	// Past conversations, so agents can recall earlier decisions themselves.
+	cfg.System += "\n\n---\n\n" + buildChatSearchPromptSection()
+	cfg.ExtraTools = append(cfg.ExtraTools, BuildChatSearchToolDefs()...)
+	for name, handler := range MakeChatSearchToolHandlers(m.db, wsID) {
+		cfg.ExtraHandlers[name] = handler
+	}
+
 	// Studio tools: browse the media library and generate into it. Separate
 	// from generate_image below, which is the older single-shot image path —
 	// these add folders, video and audio, and providers beyond OpenRouter.
@@ -746,6 +765,19 @@ func (m *Manager) RoleChat(ctx context.Context, systemPrompt, model string, hist
 		cfg.System += "\n\n---\n\n" + buildStudioPromptSection(m.MediaRegistry)
 	}
 
+	// External MCP servers granted to this agent or workspace. CLI engines get
+	// these through the same bridge as every other extra tool.
+	if m.MCPClients != nil {
+		mcpDefs, mcpHandlers := m.MCPClients.ToolsFor(agentRoleSlug, wsID)
+		cfg.ExtraTools = append(cfg.ExtraTools, mcpDefs...)
+		if cfg.ExtraHandlers == nil {
+			cfg.ExtraHandlers = map[string]llm.ToolHandler{}
+		}
+		for name, handler := range mcpHandlers {
+			cfg.ExtraHandlers[name] = handler
+		}
+	}
+
 	// Inject image generation tool (OpenRouter with model fallback chain)
 	if m.client != nil && m.client.IsConfigured() {
 		cfg.ExtraTools = append(cfg.ExtraTools, BuildGenerateImageDef())
@@ -803,7 +835,13 @@ func (m *Manager) RoleChat(ctx context.Context, systemPrompt, model string, hist
 		cfg.Session = &llm.SessionKey{ThreadID: threadID, AgentSlug: agentRoleSlug}
 	}
 
-	result, err := provider.RunAgentLoop(ctx, cfg, userMessage)
+	// No Source: a scheduled run's scope on ctx says "schedule", and anything
+	// else is recorded as chat.
+	result, err := m.RunBudgeted(ctx, provider, cfg, userMessage, budget.Scope{
+		AgentSlug:   agentRoleSlug,
+		WorkspaceID: wsID,
+		ThreadID:    threadID,
+	})
 	if err != nil {
 		return "", nil, "", "", "", fmt.Errorf("role chat failed: %w", err)
 	}
@@ -898,6 +936,12 @@ func (m *Manager) SendScheduledPrompt(ctx context.Context, agentSlug, prompt, th
 		workspaceID = m.db.ActiveWorkspaceID()
 	}
 
+	// Refuse before touching the thread, so a run over budget leaves no
+	// unanswered prompt behind.
+	if err := m.CheckBudget(ctx, budget.Scope{AgentSlug: agentSlug, WorkspaceID: workspaceID, Source: budget.SourceSchedule}); err != nil {
+		return "", threadID, err
+	}
+
 	// A schedule pinned to a thread keeps writing there — the user picked that
 	// conversation deliberately. An unpinned schedule runs *threadless*: it used
 	// to spawn a new chat on every tick, burying the chat list under runs nobody
@@ -913,7 +957,7 @@ func (m *Manager) SendScheduledPrompt(ctx context.Context, agentSlug, prompt, th
 		m.db.Exec("UPDATE chat_threads SET updated_at = ? WHERE id = ?", now, threadID)
 
 		rows, err := m.db.Query(
-			"SELECT role, content FROM chat_messages WHERE thread_id = ? ORDER BY created_at ASC",
+			"SELECT role, content FROM chat_messages WHERE id IN ("+database.ActiveBranch+") ORDER BY created_at ASC",
 			threadID,
 		)
 		if err == nil {
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/contextindex"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -33,6 +35,12 @@ type ToolManager interface {
 	FetchFile(toolID, path string) ([]byte, string, error) // returns body, contentType, error
 }
 
+// MCPToolSource supplies the tools of external MCP servers granted to an
+// agent or workspace. Satisfied by mcp.Clients.
+type MCPToolSource interface {
+	ToolsFor(agentSlug, workspaceID string) ([]llm.ToolDef, map[string]llm.ToolHandler)
+}
+
 // MemoryManager is the interface for the per-agent memory system (avoids circular imports).
 type MemoryManager interface {
 	SaveNote(slug, note, source string) error
@@ -97,7 +105,15 @@ type Manager struct {
 	// when the task needs it. Nil leaves agents with names only.
 	SecretsMgr SecretDecryptor
 	// MediaRegistry backs the studio_* tools. Nil disables them.
-	MediaRegistry   *media.Registry
+	MediaRegistry *media.Registry
+	// MCPClients adds tools from external MCP servers. Nil offers none.
+	MCPClients MCPToolSource
+	// Budget enforces spend limits and keeps the usage ledger. Nil runs
+	// everything unmetered.
+	Budget *budget.Manager
+	// ContextIndex backs search_context and is kept current as agents write
+	// context documents. Nil leaves agents reading documents whole.
+	ContextIndex    *contextindex.Index
 	manifestCache   sync.Map // map[toolID][]byte
 	streamStates    sync.Map // map[threadID]*StreamState
 	activeSubAgents int32    // atomic counter for concurrent sub-agents
@@ -236,6 +252,12 @@ func CheckProvider(router *llm.ProviderRouter) {
 	switch active.Name() {
 	case llm.ProviderOpenRouter:
 		CheckAPIKey(router.OpenRouter())
+	case llm.ProviderOpenAICompat:
+		if active.IsConfigured() {
+			logger.Success("LLM provider: %s", active.Name())
+		} else {
+			logger.Warn("LLM provider %s selected but no server URL is set — chat agents will not work until one is configured", active.Name())
+		}
 	default:
 		if active.IsConfigured() {
 			logger.Success("LLM provider: %s (subscription CLI)", active.Name())
@@ -147,7 +147,7 @@ func BuildScheduleToolDefs(canModify bool) []llm.ToolDef {
 
 func (m *Manager) MakeScheduleToolHandlers(selfSlug, threadID string, canModify bool) map[string]llm.ToolHandler {
 	handlers := map[string]llm.ToolHandler{
-		"schedule_list": m.handleScheduleList(),
+		"schedule_list": m.handleScheduleList(""),
 	}
 	if !canModify {
 		return handlers
@@ -155,7 +155,7 @@ func (m *Manager) MakeScheduleToolHandlers(selfSlug, threadID string, canModify
 	handlers["schedule_create"] = m.handleScheduleCreate(selfSlug, threadID)
 	handlers["schedule_update"] = m.handleScheduleUpdate()
 	handlers["schedule_delete"] = m.handleScheduleDelete()
-	handlers["schedule_run_now"] = m.handleScheduleRunNow()
+	handlers["schedule_run_now"] = m.handleScheduleRunNow("")
 	return handlers
 }
 
@@ -172,7 +172,10 @@ type scheduleView struct {
 	NextRun     string `json:"next_run,omitempty"`
 }
 
-func (m *Manager) handleScheduleList() llm.ToolHandler {
+// handleScheduleList lists prompt schedules. A non-empty workspaceID limits it
+// to that workspace's schedules and the global ones, for callers that may only
+// see one workspace.
+func (m *Manager) handleScheduleList(workspaceID string) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
 		var params struct {
 			AgentSlug string `json:"agent_slug"`
@@ -183,6 +186,10 @@ func (m *Manager) handleScheduleList() llm.ToolHandler {
 		                 enabled, thread_id, last_run_at, next_run_at
 		          FROM schedules WHERE type = 'prompt'`
 		var args []interface{}
+		if workspaceID != "" {
+			query += " AND (workspace_id = ? OR workspace_id IS NULL OR workspace_id = '')"
+			args = append(args, workspaceID)
+		}
 		if slug := strings.TrimSpace(params.AgentSlug); slug != "" {
 			query += " AND agent_role_slug = ?"
 			args = append(args, slug)
@@ -346,10 +353,10 @@ func (m *Manager) handleScheduleUpdate() llm.ToolHandler {
 			return llm.ToolResult{Output: "schedule_id is required. Use schedule_list to find it.", IsError: true}
 		}
 
-		var sType string
+		var sType, triggerType string
 		if err := m.db.QueryRow(
-			"SELECT type FROM schedules WHERE id = ?", params.ScheduleID,
-		).Scan(&sType); err != nil {
+			"SELECT type, trigger_type FROM schedules WHERE id = ?", params.ScheduleID,
+		).Scan(&sType, &triggerType); err != nil {
 			return llm.ToolResult{Output: "No schedule with that id. Use schedule_list to find it.", IsError: true}
 		}
 		// Tool-action and dashboard schedules are generated machinery whose
@@ -375,6 +382,9 @@ func (m *Manager) handleScheduleUpdate() llm.ToolHandler {
 			sets, args = append(sets, "prompt_content = ?"), append(args, strings.TrimSpace(*params.Prompt))
 		}
 		if params.CronExpr != nil {
+			if triggerType == scheduler.TriggerWebhook {
+				return llm.ToolResult{Output: "That automation runs on an inbound webhook, not a timer, so it has no cron_expr.", IsError: true}
+			}
 			expr, err := scheduler.NormalizeCron(*params.CronExpr)
 			if err != nil {
 				return llm.ToolResult{Output: err.Error(), IsError: true}
@@ -411,7 +421,7 @@ func (m *Manager) handleScheduleUpdate() llm.ToolHandler {
 			return llm.ToolResult{Output: "Saved, but could not re-read the schedule to restart it: " + err.Error(), IsError: true}
 		}
 		m.Scheduler.RemoveSchedule(params.ScheduleID)
-		if enabled {
+		if enabled && triggerType == scheduler.TriggerCron {
 			m.Scheduler.AddSchedule(cfg)
 		}
 
@@ -474,7 +484,9 @@ func (m *Manager) handleScheduleDelete() llm.ToolHandler {
 	}
 }
 
-func (m *Manager) handleScheduleRunNow() llm.ToolHandler {
+// handleScheduleRunNow starts a schedule. A non-empty workspaceID refuses
+// schedules belonging to any other workspace, as though they didn't exist.
+func (m *Manager) handleScheduleRunNow(workspaceID string) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
 		if m.Scheduler == nil {
 			return llm.ToolResult{Output: "The scheduler is not available in this run.", IsError: true}
@@ -489,7 +501,7 @@ func (m *Manager) handleScheduleRunNow() llm.ToolHandler {
 		}
 
 		cfg, _, err := m.scheduleConfig(params.ScheduleID)
-		if err != nil {
+		if err != nil || (workspaceID != "" && cfg.WorkspaceID != "" && cfg.WorkspaceID != workspaceID) {
 			return llm.ToolResult{Output: "No schedule with that id.", IsError: true}
 		}
 		m.Scheduler.RunNow(cfg)
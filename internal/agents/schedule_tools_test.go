@@ -3,9 +3,11 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/scheduler"
 )
 
@@ -302,3 +304,43 @@ func TestUnattendedContextMarker(t *testing.T) {
 		t.Error("WithUnattended did not mark the context")
 	}
 }
+
+// An MCP client is confined to one workspace: it sees that workspace's
+// schedules and the global ones, and can't start anyone else's.
+func TestEndpointSchedulesStayInTheirWorkspace(t *testing.T) {
+	m, db, sched := newScheduleTestManager(t)
+	db.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('ws-a', 'A', 1), ('ws-b', 'B', 2)")
+	for _, s := range []struct{ id, workspace string }{{"s-a", "ws-a"}, {"s-global", ""}} {
+		if _, err := db.Exec(
+			`INSERT INTO schedules (id, name, cron_expr, prompt_content, agent_role_slug, enabled, type, workspace_id)
+			 VALUES (?, ?, '@daily', 'Secret plan for '||?, 'scout', 1, 'prompt', NULLIF(?, ''))`,
+			s.id, s.id, s.id, s.workspace,
+		); err != nil {
+			t.Fatalf("insert schedule: %v", err)
+		}
+	}
+
+	call := func(handlers map[string]llm.ToolHandler, name, scheduleID string) (string, bool) {
+		raw, _ := json.Marshal(map[string]string{"schedule_id": scheduleID})
+		res := handlers[name](context.Background(), "", raw)
+		return res.Output, res.IsError
+	}
+
+	_, handlers := m.EndpointTools("ws-b", []string{EndpointSchedules}, true)
+	if out, _ := call(handlers, "schedule_list", ""); strings.Contains(out, "s-a") || !strings.Contains(out, "s-global") {
+		t.Errorf("workspace B's list = %s, want the global schedule only", out)
+	}
+	if _, isErr := call(handlers, "schedule_run_now", "s-a"); !isErr {
+		t.Error("workspace B started workspace A's schedule")
+	}
+	if _, isErr := call(handlers, "schedule_run_now", "s-global"); isErr {
+		t.Error("workspace B could not start a global schedule")
+	}
+	if len(sched.ran) != 1 || sched.ran[0] != "s-global" {
+		t.Errorf("ran = %v, want the global schedule only", sched.ran)
+	}
+
+	if _, viewer := m.EndpointTools("ws-b", []string{EndpointSchedules}, false); viewer["schedule_run_now"] != nil {
+		t.Error("a viewer was offered schedule_run_now")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/openpaw/openpaw/internal/budget"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/models"
@@ -36,8 +37,15 @@ func (m *Manager) spawnBuilder(ctx context.Context, cfg spawnConfig, workOrder *
 		return nil, fmt.Errorf("max concurrent agents (%d) reached", maxConcurrentAgents)
 	}
 
-	agentID := generateAgentID()
+	// Builds are the longest runs in the app, so a spent budget refuses them
+	// before a work order is marked in progress.
 	workspaceID := m.threadWorkspaceID(threadID)
+	scope := budget.Scope{AgentSlug: "builder", WorkspaceID: workspaceID, ThreadID: threadID}
+	if err := m.CheckBudget(ctx, scope); err != nil {
+		return nil, err
+	}
+
+	agentID := generateAgentID()
 	now := time.Now().UTC()
 	agent := models.Agent{
 		ID:          agentID,
@@ -144,7 +152,7 @@ func (m *Manager) spawnBuilder(ctx context.Context, cfg spawnConfig, workOrder *
 			},
 		}
 
-		result, err := m.Provider().RunAgentLoop(agentCtx, agentCfg, cfg.prompt)
+		result, err := m.RunBudgeted(agentCtx, m.Provider(), agentCfg, cfg.prompt, scope)
 
 		output := outputBuf.String()
 		status := "completed"
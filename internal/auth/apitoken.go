@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix starts every personal access token, so one is recognisable in
+// an Authorization header (and in a leaked config file) without a lookup.
+const APITokenPrefix = "opaw_"
+
+var ErrTokenRevoked = errors.New("token revoked")
+
+// Scopes a personal access token can carry.
+const (
+	ScopeAll            = "all"
+	ScopeRead           = "read"
+	ScopeChat           = "chat"
+	ScopeSchedulesRun   = "schedules:run"
+	ScopeDatabasesWrite = "databases:write"
+	ScopeMCP            = "mcp"
+)
+
+// ScopeInfo describes a scope for the token creation UI.
+type ScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var Scopes = []ScopeInfo{
+	{ScopeRead, "Read anything (GET requests only)"},
+	{ScopeChat, "Send messages and manage chat threads (not tmux sessions or share links)"},
+	{ScopeSchedulesRun, "Trigger schedules with run-now"},
+	{ScopeDatabasesWrite, "Create, update and delete database rows and tables"},
+	{ScopeMCP, "Connect an MCP client (Claude Desktop, Cursor) to the MCP endpoint"},
+	{ScopeAll, "Full access, same as the signed-in user"},
+}
+
+// ValidScope reports whether name is a known scope.
+func ValidScope(name string) bool {
+	for _, s := range Scopes {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken is a stored personal access token, resolved to its owner.
+type APIToken struct {
+	ID        string
+	UserID    string
+	Username  string
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+}
+
+// APITokenStore looks tokens up by hash and records their use.
+type APITokenStore interface {
+	LookupAPIToken(hash string) (*APIToken, error)
+	RecordAPITokenUse(token *APIToken, r *http.Request)
+}
+
+// GenerateAPIToken returns a new random token in plaintext. Only its hash is
+// ever stored.
+func GenerateAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + hex.EncodeToString(b), nil
+}
+
+// HashAPIToken is the lookup key for a token. Tokens are 256 bits of random
+// data, so a fast hash is enough — there is nothing to brute-force.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether a bearer credential is a personal access token
+// rather than a session JWT.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// SetAPITokenStore enables personal access tokens. Until it is called,
+// ValidateAPIToken rejects everything.
+func (s *Service) SetAPITokenStore(store APITokenStore) {
+	s.apiTokens = store
+}
+
+// ValidateAPIToken resolves a plaintext token, rejecting unknown, revoked and
+// expired ones.
+func (s *Service) ValidateAPIToken(token string) (*APIToken, error) {
+	if s.apiTokens == nil || !IsAPIToken(token) {
+		return nil, ErrInvalidToken
+	}
+	t, err := s.apiTokens.LookupAPIToken(HashAPIToken(token))
+	if err != nil || t == nil {
+		return nil, ErrInvalidToken
+	}
+	if t.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// RecordAPITokenUse notes that a token authenticated a request.
+func (s *Service) RecordAPITokenUse(t *APIToken, r *http.Request) {
+	if s.apiTokens != nil {
+		s.apiTokens.RecordAPITokenUse(t, r)
+	}
+}
+
+// ScopesAllow reports whether a token with the given scopes may make a request.
+// path is the full request path (/api/v1/...).
+//
+// Account, token and user management is never reachable with a token,
+// whatever its scopes: a leaked token must not be able to mint more tokens or
+// invitations, change the password or delete accounts.
+func ScopesAllow(scopes []string, method, path string) bool {
+	rel := strings.TrimPrefix(path, "/api/v1")
+	readOnly := method == http.MethodGet || method == http.MethodHead
+
+	if strings.HasPrefix(rel, "/auth/") && !(readOnly && rel == "/auth/me") {
+		return false
+	}
+	if rel == "/users" || strings.HasPrefix(rel, "/users/") {
+		return false
+	}
+
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeAll:
+			return true
+		case ScopeRead:
+			if readOnly {
+				return true
+			}
+		case ScopeChat:
+			if chatScopeAllows(rel) {
+				return true
+			}
+		case ScopeSchedulesRun:
+			if method == http.MethodPost && strings.HasPrefix(rel, "/schedules/") && strings.HasSuffix(rel, "/run-now") {
+				return true
+			}
+		case ScopeDatabasesWrite:
+			if rel == "/databases" || strings.HasPrefix(rel, "/databases/") {
+				return true
+			}
+		case ScopeMCP:
+			// Only the endpoint itself; what a client may do there is decided
+			// by the owner's workspace role and the exposed capabilities.
+			if rel == "/mcp" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chatScopeRoutes are what the chat scope covers: threads, their messages and
+// attachments.
+var chatScopeRoutes = []string{"/chat/active", "/chat/search", "/chat/threads", "/chat/messages", "/chat/attachments", "/chat/pasted-images"}
+
+// chatScopeAllows reports whether a path is conversation rather than the host
+// reached through it. The tmux routes type into shells on the machine and a
+// share link publishes a thread to anyone, so neither comes with chat.
+func chatScopeAllows(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if len(parts) >= 5 && parts[2] == "threads" && (parts[4] == "tmux-watch" || parts[4] == "shares") {
+		return false
+	}
+	for _, route := range chatScopeRoutes {
+		if rel == route || strings.HasPrefix(rel, route+"/") {
+			return true
+		}
+	}
+	return false
+}
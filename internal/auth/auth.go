@@ -23,6 +23,8 @@ type Claims struct {
 type Service struct {
 	jwtSecret []byte
 	tokenTTL  time.Duration
+	apiTokens APITokenStore
+	users     UserStore
 }
 
 func NewService(jwtSecret string) *Service {
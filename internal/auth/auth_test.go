@@ -244,3 +244,52 @@ func TestValidateTokenWrongSecret(t *testing.T) {
 		t.Errorf("error = %v, want %v", err, ErrInvalidToken)
 	}
 }
+
+func TestScopesAllow(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		method string
+		path   string
+		want   bool
+	}{
+		{[]string{ScopeRead}, "GET", "/api/v1/schedules", true},
+		{[]string{ScopeRead}, "POST", "/api/v1/schedules", false},
+		{[]string{ScopeChat}, "POST", "/api/v1/chat/threads/t1/messages", true},
+		{[]string{ScopeChat}, "GET", "/api/v1/tools", false},
+		{[]string{ScopeChat}, "POST", "/api/v1/chat/attachments", true},
+		{[]string{ScopeChat}, "POST", "/api/v1/chat/tmux/send", false},
+		{[]string{ScopeChat}, "DELETE", "/api/v1/chat/tmux", false},
+		{[]string{ScopeChat}, "POST", "/api/v1/chat/threads/t1/tmux-watch", false},
+		{[]string{ScopeChat}, "POST", "/api/v1/chat/threads/t1/shares", false},
+		{[]string{ScopeSchedulesRun}, "POST", "/api/v1/schedules/s1/run-now", true},
+		{[]string{ScopeSchedulesRun}, "DELETE", "/api/v1/schedules/s1", false},
+		{[]string{ScopeDatabasesWrite}, "PUT", "/api/v1/databases/d1/tables/t1/rows/r1", true},
+		{[]string{ScopeMCP}, "POST", "/api/v1/mcp", true},
+		{[]string{ScopeMCP}, "GET", "/api/v1/databases", false},
+		{[]string{ScopeAll}, "DELETE", "/api/v1/tools/x", true},
+		{[]string{ScopeAll}, "GET", "/api/v1/auth/me", true},
+		{[]string{ScopeAll}, "POST", "/api/v1/auth/tokens", false},
+		{[]string{ScopeAll}, "POST", "/api/v1/auth/change-password", false},
+		{[]string{ScopeAll}, "POST", "/api/v1/users/invitations", false},
+		{nil, "GET", "/api/v1/tools", false},
+	}
+	for _, c := range cases {
+		if got := ScopesAllow(c.scopes, c.method, c.path); got != c.want {
+			t.Errorf("ScopesAllow(%v, %s %s) = %v, want %v", c.scopes, c.method, c.path, got, c.want)
+		}
+	}
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	a, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken: %v", err)
+	}
+	b, _ := GenerateAPIToken()
+	if a == b || !IsAPIToken(a) {
+		t.Fatalf("tokens %q / %q", a, b)
+	}
+	if HashAPIToken(a) == a || HashAPIToken(a) != HashAPIToken(a) {
+		t.Error("HashAPIToken must be a stable digest, not the token")
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Account and workspace roles, from most to least privileged.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ValidRole reports whether name is a known role.
+func ValidRole(name string) bool {
+	_, ok := roleRank[name]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants at least min. Unknown and empty
+// roles grant nothing.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[min]
+}
+
+// LowerRole returns the less privileged of two roles.
+func LowerRole(a, b string) string {
+	if roleRank[a] <= roleRank[b] {
+		return a
+	}
+	return b
+}
+
+// GenerateInviteToken returns a new random invitation token in plaintext.
+// Like API tokens, only HashAPIToken of it is stored.
+func GenerateInviteToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UserStore confirms that a session's user still exists, so removing a team
+// member ends their sessions rather than leaving them valid until expiry.
+type UserStore interface {
+	UserExists(id string) bool
+}
+
+// SetUserStore enables the per-request user check. Until it is called, every
+// validly signed session is accepted.
+func (s *Service) SetUserStore(store UserStore) {
+	s.users = store
+}
+
+// UserActive reports whether a session's user may still make requests.
+func (s *Service) UserActive(userID string) bool {
+	return s.users == nil || s.users.UserExists(userID)
+}
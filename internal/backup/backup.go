@@ -125,6 +125,9 @@ func (m *Manager) GetConfig() map[string]interface{} {
 }
 
 func (m *Manager) UpdateConfig(cfg map[string]string) error {
+	if repoURL := cfg["backup_repo_url"]; repoURL != "" && !validRepoURL(repoURL) {
+		return ErrInvalidRepoURL
+	}
 	for key, val := range cfg {
 		dbKey := key
 		dbVal := val
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/openpaw/openpaw/internal/config"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+// RunRestoreCommand handles the `openpaw restore` CLI command. It works on a
+// data directory with no server running, which is the point: a fresh machine
+// can be rebuilt from the backup repo before OpenPaw is ever started on it.
+func RunRestoreCommand(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	repoURL := fs.String("repo", "", "backup repository URL (defaults to the configured one)")
+	token := fs.String("token", os.Getenv("OPENPAW_BACKUP_TOKEN"), "access token for the repository (or OPENPAW_BACKUP_TOKEN)")
+	commit := fs.String("commit", "", "restore this commit instead of the latest snapshot")
+	dryRun := fs.Bool("dry-run", false, "show what would change without writing anything")
+	dataDir := fs.String("data-dir", cfg.DataDir, "data directory to restore into")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: openpaw restore [--repo URL] [--token TOKEN] [--commit SHA] [--dry-run] [--data-dir DIR]")
+		fmt.Fprintln(os.Stderr, "Stop OpenPaw before restoring into its data directory.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	db, err := database.New(*dataDir)
+	if err != nil {
+		logger.Error("Failed to open database: %v", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	encKey, err := restoreEncryptionKey(db, cfg.EncryptionKey)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+
+	mgr := New(db, secrets.NewManager(encKey), *dataDir, nil)
+	mgr.LoadConfig()
+
+	opts := RestoreOptions{RepoURL: *repoURL, Commit: *commit, DryRun: *dryRun}
+	if *token != "" {
+		opts.AuthToken = *token
+		opts.AuthMethod = "token"
+	}
+
+	logger.Info("Restoring from backup repository...")
+	result, err := mgr.Restore(opts)
+	if err != nil {
+		logger.Error("Restore failed: %v", err)
+		os.Exit(1)
+	}
+	if !*dryRun {
+		db.LogAudit("cli", "backup_restored", "backup", "backup", result.Commit, "manifest "+result.ManifestVersion)
+	}
+
+	printRestoreResult(result)
+	if *dryRun {
+		logger.Info("Dry run — nothing was written. Re-run without --dry-run to restore.")
+	} else {
+		logger.Success("Restored snapshot %s into %s.", result.Commit, *dataDir)
+	}
+}
+
+// restoreEncryptionKey resolves the key secrets are encrypted with the same
+// way the server does, generating and persisting one for a brand-new data
+// directory so placeholder secrets stay readable once the server starts.
+func restoreEncryptionKey(db *database.DB, envKey string) (string, error) {
+	if envKey != "" {
+		return envKey, nil
+	}
+	var stored string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'encryption_key'").Scan(&stored); err == nil && stored != "" {
+		return stored, nil
+	}
+	var existingSecrets int
+	db.QueryRow("SELECT COUNT(*) FROM secrets").Scan(&existingSecrets)
+	if existingSecrets > 0 {
+		return "", fmt.Errorf("this data directory has secrets but no stored encryption key — start OpenPaw once before restoring into it")
+	}
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		return "", fmt.Errorf("generate encryption key: %w", err)
+	}
+	if _, err := db.Exec("INSERT INTO settings (id, key, value) VALUES (?, 'encryption_key', ?)", "encryption-key", key); err != nil {
+		return "", fmt.Errorf("persist encryption key: %w", err)
+	}
+	return key, nil
+}
+
+func printRestoreResult(result *RestoreResult) {
+	fmt.Printf("Snapshot %s (manifest %s, taken %s)\n", result.Commit, result.ManifestVersion, result.SnapshotAt.Format("2006-01-02 15:04 UTC"))
+
+	categories := make([]string, 0, len(result.Summary))
+	for c := range result.Summary {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	fmt.Printf("  %-20s %8s %8s %10s\n", "", "create", "update", "unchanged")
+	for _, c := range categories {
+		counts := result.Summary[c]
+		fmt.Printf("  %-20s %8d %8d %10d\n", c, counts.Created, counts.Updated, counts.Unchanged)
+	}
+	for _, w := range result.Warnings {
+		logger.Warn("%s", w)
+	}
+}
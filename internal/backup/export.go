@@ -25,6 +25,11 @@ func nullStr(ns sql.NullString) string {
 	return ""
 }
 
+// ManifestVersion is written into every snapshot's manifest.json. Bump the
+// minor version when a snapshot gains files an older restore would not know
+// about, and the major version when an older restore would misread them.
+const ManifestVersion = "1.3"
+
 type Manifest struct {
 	Version   string    `json:"version"`
 	Timestamp time.Time `json:"timestamp"`
@@ -41,6 +46,7 @@ type Stats struct {
 	Skills          int `json:"skills"`
 	Memories        int `json:"memories"`
 	Users           int `json:"users"`
+	Workspaces      int `json:"workspaces"`
 	AgentToolAccess int `json:"agent_tool_access"`
 	Secrets         int `json:"secrets"`
 	Avatars         int `json:"avatars"`
@@ -82,6 +88,12 @@ func exportData(db *database.DB, dataDir, destDir string) (int, error) {
 		stats.Users = len(n)
 	}
 
+	// Workspaces (everything scoped to one references its row)
+	if n, count, err := exportWorkspaces(db, destDir); err == nil {
+		files = append(files, n...)
+		stats.Workspaces = count
+	}
+
 	// Agent roles
 	if n, err := exportAgentRoles(db, dataDir, destDir); err == nil {
 		files = append(files, n...)
@@ -216,7 +228,7 @@ func exportData(db *database.DB, dataDir, destDir string) (int, error) {
 
 	// Write manifest
 	manifest := Manifest{
-		Version:   "1.2",
+		Version:   ManifestVersion,
 		Timestamp: time.Now().UTC(),
 		Files:     files,
 		Stats:     stats,
@@ -235,6 +247,7 @@ func exportSettings(db *database.DB, destDir string) ([]string, error) {
 	sensitive := map[string]bool{
 		"jwt_secret": true, "encryption_key": true,
 		"openrouter_api_key": true, "backup_auth_token": true,
+		"memory_embedding_api_key": true,
 	}
 
 	rows, err := db.Query("SELECT key, value FROM settings")
@@ -391,22 +404,22 @@ func exportContext(db *database.DB, destDir string) ([]string, error) {
 	}
 
 	// Files (with content from context_store if text-based)
-	fRows, err := db.Query("SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at FROM context_files ORDER BY name")
+	fRows, err := db.Query("SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at, text_filename FROM context_files ORDER BY name")
 	if err == nil {
 		defer fRows.Close()
 		var contextFiles []map[string]interface{}
 		for fRows.Next() {
-			var id, name, filename, mimeType string
+			var id, name, filename, mimeType, textFilename string
 			var folderID *string
 			var sizeBytes, isAboutYou int
 			var workspaceID sql.NullString
 			var createdAt, updatedAt time.Time
-			if fRows.Scan(&id, &folderID, &name, &filename, &mimeType, &sizeBytes, &isAboutYou, &workspaceID, &createdAt, &updatedAt) != nil {
+			if fRows.Scan(&id, &folderID, &name, &filename, &mimeType, &sizeBytes, &isAboutYou, &workspaceID, &createdAt, &updatedAt, &textFilename) != nil {
 				continue
 			}
 			f := map[string]interface{}{
 				"id": id, "folder_id": folderID, "name": name, "filename": filename,
-				"mime_type": mimeType, "size_bytes": sizeBytes,
+				"mime_type": mimeType, "size_bytes": sizeBytes, "text_filename": textFilename,
 				"is_about_you": isAboutYou == 1,
 				"workspace_id": nullStr(workspaceID),
 				"created_at":   createdAt, "updated_at": updatedAt,
@@ -712,6 +725,38 @@ func exportUsers(db *database.DB, destDir string) ([]string, error) {
 	return []string{path}, nil
 }
 
+func exportWorkspaces(db *database.DB, destDir string) ([]string, int, error) {
+	rows, err := db.Query("SELECT id, name, sort_order, is_default, image_url, created_at, updated_at FROM workspaces ORDER BY sort_order")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var workspaces []map[string]interface{}
+	for rows.Next() {
+		var id, name, imageURL string
+		var sortOrder, isDefault int
+		var createdAt, updatedAt time.Time
+		if rows.Scan(&id, &name, &sortOrder, &isDefault, &imageURL, &createdAt, &updatedAt) != nil {
+			continue
+		}
+		workspaces = append(workspaces, map[string]interface{}{
+			"id": id, "name": name, "sort_order": sortOrder, "is_default": isDefault == 1,
+			"image_url": imageURL, "created_at": createdAt, "updated_at": updatedAt,
+		})
+	}
+
+	if len(workspaces) == 0 {
+		return nil, 0, nil
+	}
+
+	path := "workspaces.json"
+	if err := writeJSONFile(filepath.Join(destDir, path), workspaces); err != nil {
+		return nil, 0, err
+	}
+	return []string{path}, len(workspaces), nil
+}
+
 func exportAgentToolAccess(db *database.DB, destDir string) ([]string, error) {
 	rows, err := db.Query("SELECT id, agent_role_slug, tool_id, granted_at FROM agent_tool_access")
 	if err != nil {
@@ -868,7 +913,7 @@ func exportChatHistory(db *database.DB, dataDir, destDir string) ([]string, erro
 
 	// Threads
 	rows, err := db.Query(
-		`SELECT id, title, workspace_id, parent_thread_id, root_message_id, created_at, updated_at
+		`SELECT id, title, workspace_id, parent_thread_id, root_message_id, head_message_id, created_at, updated_at
 		 FROM chat_threads ORDER BY created_at`,
 	)
 	if err != nil {
@@ -879,16 +924,16 @@ func exportChatHistory(db *database.DB, dataDir, destDir string) ([]string, erro
 	var threads []map[string]interface{}
 	var threadIDs []string
 	for rows.Next() {
-		var id, title string
+		var id, title, headMessageID string
 		var workspaceID, parentThreadID, rootMessageID sql.NullString
 		var createdAt, updatedAt time.Time
-		if rows.Scan(&id, &title, &workspaceID, &parentThreadID, &rootMessageID, &createdAt, &updatedAt) != nil {
+		if rows.Scan(&id, &title, &workspaceID, &parentThreadID, &rootMessageID, &headMessageID, &createdAt, &updatedAt) != nil {
 			continue
 		}
 		threads = append(threads, map[string]interface{}{
 			"id": id, "title": title, "workspace_id": nullStr(workspaceID),
 			"parent_thread_id": nullStr(parentThreadID), "root_message_id": nullStr(rootMessageID),
-			"created_at": createdAt, "updated_at": updatedAt,
+			"head_message_id": headMessageID, "created_at": createdAt, "updated_at": updatedAt,
 		})
 		threadIDs = append(threadIDs, id)
 	}
@@ -933,25 +978,26 @@ func exportChatHistory(db *database.DB, dataDir, destDir string) ([]string, erro
 	}
 	for _, tid := range threadIDs {
 		msgRows, err := db.Query(
-			"SELECT id, thread_id, role, content, agent_role_slug, cost_usd, input_tokens, output_tokens, widget_data, image_url, created_at FROM chat_messages WHERE thread_id = ? ORDER BY created_at", tid)
+			"SELECT id, thread_id, COALESCE(parent_id, ''), role, content, agent_role_slug, cost_usd, input_tokens, output_tokens, widget_data, image_url, tool_calls_json, stopped, created_at FROM chat_messages WHERE thread_id = ? ORDER BY created_at", tid)
 		if err != nil {
 			continue
 		}
 		var messages []map[string]interface{}
 		for msgRows.Next() {
-			var id, threadID, role, content, agentSlug string
+			var id, threadID, parentID, role, content, agentSlug string
 			var costUSD float64
-			var inputTokens, outputTokens int
-			var widgetData, imageURL *string
+			var inputTokens, outputTokens, stopped int
+			var widgetData, imageURL, toolCalls *string
 			var createdAt time.Time
-			if msgRows.Scan(&id, &threadID, &role, &content, &agentSlug, &costUSD, &inputTokens, &outputTokens, &widgetData, &imageURL, &createdAt) != nil {
+			if msgRows.Scan(&id, &threadID, &parentID, &role, &content, &agentSlug, &costUSD, &inputTokens, &outputTokens, &widgetData, &imageURL, &toolCalls, &stopped, &createdAt) != nil {
 				continue
 			}
 			m := map[string]interface{}{
-				"id": id, "thread_id": threadID, "role": role, "content": content,
+				"id": id, "thread_id": threadID, "parent_id": parentID, "role": role, "content": content,
 				"agent_role_slug": agentSlug, "cost_usd": costUSD,
 				"input_tokens": inputTokens, "output_tokens": outputTokens,
 				"widget_data": widgetData, "image_url": imageURL,
+				"tool_calls_json": toolCalls, "stopped": stopped == 1,
 				"created_at": createdAt,
 			}
 			messages = append(messages, m)
@@ -1,11 +1,13 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/openpaw/openpaw/internal/logger"
@@ -46,6 +48,9 @@ func TestConnection(repoURL, authToken, authMethod string) error {
 }
 
 func testConnection(repoURL, authToken, authMethod string) error {
+	if !validRepoURL(repoURL) {
+		return ErrInvalidRepoURL
+	}
 	tmpDir, err := os.MkdirTemp("", "openpaw-backup-test-*")
 	if err != nil {
 		return fmt.Errorf("create temp dir: %w", err)
@@ -57,7 +62,7 @@ func testConnection(repoURL, authToken, authMethod string) error {
 		return err
 	}
 
-	cmd := exec.Command("git", "clone", "--depth", "1", cloneURL, tmpDir)
+	cmd := exec.Command("git", "clone", "--depth", "1", "--", cloneURL, tmpDir)
 	cmd.Env = sanitizeEnv()
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -72,12 +77,15 @@ func testConnection(repoURL, authToken, authMethod string) error {
 }
 
 func cloneOrInit(repoURL, authToken, authMethod, workDir string) error {
+	if !validRepoURL(repoURL) {
+		return ErrInvalidRepoURL
+	}
 	cloneURL, err := injectAuth(repoURL, authToken, authMethod)
 	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command("git", "clone", cloneURL, workDir)
+	cmd := exec.Command("git", "clone", "--", cloneURL, workDir)
 	cmd.Env = sanitizeEnv()
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -95,12 +103,96 @@ func cloneOrInit(repoURL, authToken, authMethod, workDir string) error {
 	return nil
 }
 
+// ErrInvalidRepoURL is returned for a backup remote git shouldn't be handed.
+var ErrInvalidRepoURL = errors.New("repo URL must be an https://, ssh:// or file:// URL, or user@host:path")
+
+// scpRepoPattern is git's scp-like ssh form, user@host:path.
+var scpRepoPattern = regexp.MustCompile(`^[0-9A-Za-z._-]+@[0-9A-Za-z][0-9A-Za-z.-]*:`)
+
+// validRepoURL accepts the transports a backup remote may use. Anything else,
+// such as a leading "-" git would read as an option or a transport like ext::
+// that runs a command, is refused before git sees it.
+func validRepoURL(repoURL string) bool {
+	if strings.HasPrefix(repoURL, "-") {
+		return false
+	}
+	if scpRepoPattern.MatchString(repoURL) {
+		return true
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || strings.HasPrefix(u.Host, "-") {
+		return false
+	}
+	return u.Scheme == "https" || u.Scheme == "ssh" || u.Scheme == "file"
+}
+
+// refPattern is what a restore accepts as the snapshot to check out. It starts
+// with a letter or digit so git can never read the ref as an option.
+var refPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._/-]*$`)
+
+func validRef(ref string) bool { return refPattern.MatchString(ref) }
+
+// cloneSnapshot clones the backup repo into workDir for a restore, checking out
+// ref when one is given (a commit SHA or tag) instead of the branch tip. It
+// returns the short SHA of the snapshot that was checked out.
+func cloneSnapshot(repoURL, authToken, authMethod, ref, workDir string) (string, error) {
+	if ref != "" && !validRef(ref) {
+		return "", ErrInvalidRef
+	}
+	if !validRepoURL(repoURL) {
+		return "", ErrInvalidRepoURL
+	}
+	cloneURL, err := injectAuth(repoURL, authToken, authMethod)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "clone", "--", cloneURL, workDir)
+	cmd.Env = sanitizeEnv()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("clone failed: %s", strings.TrimSpace(string(out)))
+	}
+	if strings.Contains(string(out), "empty repository") {
+		return "", fmt.Errorf("backup repository is empty")
+	}
+
+	if ref != "" {
+		// Resolve the ref to a commit first and check out the SHA, so the ref
+		// only ever reaches git as a revision argument.
+		cmd = exec.Command("git", "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+		cmd.Dir = workDir
+		cmd.Env = sanitizeEnv()
+		commitOut, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("commit %s not found in the backup repository", ref)
+		}
+		sha := strings.TrimSpace(string(commitOut))
+
+		cmd = exec.Command("git", "-c", "advice.detachedHead=false", "checkout", "--detach", sha)
+		cmd.Dir = workDir
+		cmd.Env = sanitizeEnv()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("checkout %s failed: %s", ref, strings.TrimSpace(string(out)))
+		}
+	}
+
+	cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = workDir
+	cmd.Env = sanitizeEnv()
+	shaOut, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("get SHA failed: %w", err)
+	}
+	return strings.TrimSpace(string(shaOut)), nil
+}
+
 func initFreshRepo(remoteURL, workDir string) error {
 	os.MkdirAll(workDir, 0755)
 
 	cmds := [][]string{
 		{"git", "init"},
-		{"git", "remote", "add", "origin", remoteURL},
+		{"git", "remote", "add", "origin", "--", remoteURL},
 		{"git", "checkout", "-b", "main"},
 	}
 	for _, args := range cmds {
@@ -0,0 +1,1052 @@
+package backup
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+// ErrBusy is returned when a restore is requested while a backup or another
+// restore is still running against the same data directory.
+var ErrBusy = errors.New("a backup or restore is already running")
+
+// ErrInvalidRef is returned when the commit to restore isn't something git
+// could read as a commit SHA, tag or branch name.
+var ErrInvalidRef = errors.New("commit must be a commit SHA, tag or branch name")
+
+// maxRestoreChanges caps the per-item change list in a RestoreResult. A chat
+// history runs to thousands of messages; the summary counts stay exact.
+const maxRestoreChanges = 1000
+
+// RestoreOptions selects the snapshot to restore. Empty repo fields fall back
+// to the configured backup repository.
+type RestoreOptions struct {
+	RepoURL    string `json:"repo_url"`
+	AuthToken  string `json:"auth_token"`
+	AuthMethod string `json:"auth_method"`
+	Commit     string `json:"commit"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// RestoreCounts tallies what a restore did (or, in a dry run, would do) to one
+// category of data.
+type RestoreCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// RestoreChange is one row or file a restore creates or overwrites.
+type RestoreChange struct {
+	Category string `json:"category"`
+	Key      string `json:"key"`
+	Action   string `json:"action"`
+}
+
+type RestoreResult struct {
+	Commit           string                    `json:"commit"`
+	ManifestVersion  string                    `json:"manifest_version"`
+	SnapshotAt       time.Time                 `json:"snapshot_at"`
+	DryRun           bool                      `json:"dry_run"`
+	Summary          map[string]*RestoreCounts `json:"summary"`
+	Changes          []RestoreChange           `json:"changes"`
+	ChangesTruncated bool                      `json:"changes_truncated"`
+	Warnings         []string                  `json:"warnings"`
+}
+
+// Restore clones a snapshot from the backup repository and loads it into this
+// instance's data directory. With DryRun set nothing is written; the result
+// describes what a real restore would create and overwrite.
+func (m *Manager) Restore(opts RestoreOptions) (*RestoreResult, error) {
+	if opts.Commit != "" && !validRef(opts.Commit) {
+		return nil, ErrInvalidRef
+	}
+	if !m.running.CompareAndSwap(false, true) {
+		return nil, ErrBusy
+	}
+	defer m.running.Store(false)
+
+	m.mu.RLock()
+	cfg := m.config
+	m.mu.RUnlock()
+
+	if opts.RepoURL == "" {
+		opts.RepoURL = cfg.RepoURL
+		if opts.AuthToken == "" {
+			opts.AuthToken = cfg.AuthToken
+		}
+		if opts.AuthMethod == "" {
+			opts.AuthMethod = cfg.AuthMethod
+		}
+	}
+	if opts.RepoURL == "" {
+		return nil, fmt.Errorf("no backup repository configured")
+	}
+
+	workDir, err := os.MkdirTemp("", "openpaw-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// git clone wants to create the directory itself.
+	snapshotDir := filepath.Join(workDir, "snapshot")
+	sha, err := cloneSnapshot(opts.RepoURL, opts.AuthToken, opts.AuthMethod, opts.Commit, snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := RestoreFromDir(m.db, m.secretsMgr, m.dataDir, snapshotDir, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	result.Commit = sha
+
+	if !opts.DryRun && m.broadcast != nil {
+		m.broadcast("backup_restored", map[string]interface{}{"commit": sha})
+	}
+	return result, nil
+}
+
+// RestoreFromDir loads an exported snapshot from srcDir into db and dataDir.
+//
+// Rows are matched on their primary key (slug for agents, key for settings)
+// and inserted or updated in place, never deleted, so restoring into an
+// instance that already has data merges rather than wipes. All database writes
+// happen in one transaction; files and memory databases are only written once
+// it commits. A dry run performs the same writes and rolls them back, so its
+// counts include anything the database itself would reject.
+func RestoreFromDir(db *database.DB, secretsMgr *secrets.Manager, dataDir, srcDir string, dryRun bool) (*RestoreResult, error) {
+	var manifest Manifest
+	found, err := readSnapshotJSON(srcDir, "manifest.json", &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("not an OpenPaw backup: manifest.json is missing")
+	}
+	if err := checkManifestVersion(manifest.Version); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	r := &restorer{
+		tx:         tx,
+		secretsMgr: secretsMgr,
+		srcDir:     srcDir,
+		dataDir:    dataDir,
+		dryRun:     dryRun,
+		columns:    map[string]map[string]columnInfo{},
+		result: &RestoreResult{
+			ManifestVersion: manifest.Version,
+			SnapshotAt:      manifest.Timestamp,
+			DryRun:          dryRun,
+			Summary:         map[string]*RestoreCounts{},
+			Changes:         []RestoreChange{},
+			Warnings:        []string{},
+		},
+	}
+
+	// Parents before children: the foreign keys are enforced inside the
+	// transaction, so a message restored ahead of its thread would fail.
+	steps := []func() error{
+		r.restoreSettings,
+		r.restoreWorkspaces,
+		r.restoreUsers,
+		r.restoreAgentRoles,
+		r.restoreTools,
+		r.restoreAgentToolAccess,
+		r.restoreSecrets,
+		r.restoreContext,
+		r.restoreDashboards,
+		r.restoreDatabases,
+		r.restoreSchedules,
+		r.restoreChat,
+		r.restoreNotifications,
+		r.restoreSystemStats,
+		r.restoreProjects,
+		r.restoreAgentTasks,
+		r.restoreTodoLists,
+		r.restoreMedia,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Files are planned after the rows so a dry run reports them too.
+	r.planFiles()
+	if err := r.planMemories(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return r.result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit restore: %w", err)
+	}
+	if err := r.writeFiles(); err != nil {
+		return r.result, err
+	}
+	if err := r.writeMemories(); err != nil {
+		return r.result, err
+	}
+	return r.result, nil
+}
+
+// checkManifestVersion accepts snapshots from this release line and older. A
+// newer minor version may carry files this build would silently skip, and a
+// newer major version may mean something else entirely, so both are refused.
+func checkManifestVersion(v string) error {
+	major, minor, ok := parseManifestVersion(v)
+	if !ok {
+		return fmt.Errorf("unrecognized backup manifest version %q", v)
+	}
+	curMajor, curMinor, _ := parseManifestVersion(ManifestVersion)
+	if major != curMajor || minor > curMinor {
+		return fmt.Errorf("backup manifest version %s is not supported by this OpenPaw (reads %d.0 to %s) — update OpenPaw before restoring", v, curMajor, ManifestVersion)
+	}
+	return nil
+}
+
+func parseManifestVersion(v string) (int, int, bool) {
+	parts := strings.SplitN(strings.TrimSpace(v), ".", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+type columnInfo struct {
+	nullable bool
+}
+
+type pendingFile struct {
+	src, dst string
+}
+
+type pendingMemory struct {
+	slug    string
+	records []map[string]interface{}
+}
+
+type restorer struct {
+	tx         *sql.Tx
+	secretsMgr *secrets.Manager
+	srcDir     string
+	dataDir    string
+	dryRun     bool
+	result     *RestoreResult
+	columns    map[string]map[string]columnInfo
+	files      []pendingFile
+	memories   []pendingMemory
+}
+
+func (r *restorer) record(category, key, action string) {
+	counts := r.result.Summary[category]
+	if counts == nil {
+		counts = &RestoreCounts{}
+		r.result.Summary[category] = counts
+	}
+	switch action {
+	case "create":
+		counts.Created++
+	case "update":
+		counts.Updated++
+	default:
+		counts.Unchanged++
+		return
+	}
+	if len(r.result.Changes) >= maxRestoreChanges {
+		r.result.ChangesTruncated = true
+		return
+	}
+	r.result.Changes = append(r.result.Changes, RestoreChange{Category: category, Key: key, Action: action})
+}
+
+func (r *restorer) warn(format string, args ...interface{}) {
+	r.result.Warnings = append(r.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+// tableColumns reads a table's live column set, so a snapshot field the
+// current schema no longer has is dropped instead of failing the insert.
+func (r *restorer) tableColumns(table string) (map[string]columnInfo, error) {
+	if cols, ok := r.columns[table]; ok {
+		return cols, nil
+	}
+	rows, err := r.tx.Query(`SELECT name, "notnull" FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	cols := map[string]columnInfo{}
+	for rows.Next() {
+		var name string
+		var notNull int
+		if err := rows.Scan(&name, &notNull); err != nil {
+			return nil, err
+		}
+		cols[name] = columnInfo{nullable: notNull == 0}
+	}
+	r.columns[table] = cols
+	return cols, rows.Err()
+}
+
+// upsert writes one snapshot record into table, matching an existing row on
+// keyCols. The row id is never rewritten on update: other rows may point at it.
+func (r *restorer) upsert(category, table string, keyCols []string, rec map[string]interface{}) error {
+	cols, err := r.tableColumns(table)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	values := map[string]interface{}{}
+	for name, v := range rec {
+		info, ok := cols[name]
+		if !ok {
+			continue
+		}
+		names = append(names, name)
+		values[name] = sqlValue(name, v, info)
+	}
+	sort.Strings(names)
+
+	var where []string
+	var keyArgs []interface{}
+	var keyParts []string
+	for _, k := range keyCols {
+		v, ok := values[k]
+		if !ok || v == nil {
+			return nil // a record without its key cannot be placed
+		}
+		where = append(where, k+" = ?")
+		keyArgs = append(keyArgs, v)
+		keyParts = append(keyParts, fmt.Sprint(v))
+	}
+	key := strings.Join(keyParts, "/")
+
+	existing, err := r.tx.Query(
+		fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(names, ", "), table, strings.Join(where, " AND ")),
+		keyArgs...,
+	)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", table, err)
+	}
+	current := make([]interface{}, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range current {
+		ptrs[i] = &current[i]
+	}
+	found := existing.Next()
+	if found {
+		err = existing.Scan(ptrs...)
+	}
+	existing.Close()
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", table, err)
+	}
+
+	if !found {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+		args := make([]interface{}, len(names))
+		for i, n := range names {
+			args[i] = values[n]
+		}
+		if _, err := r.tx.Exec(
+			fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders),
+			args...,
+		); err != nil {
+			return fmt.Errorf("restore %s %s: %w", table, key, err)
+		}
+		r.record(category, key, "create")
+		return nil
+	}
+
+	var sets []string
+	var args []interface{}
+	changed := false
+	for i, n := range names {
+		if n == "id" || containsString(keyCols, n) {
+			continue
+		}
+		sets = append(sets, n+" = ?")
+		args = append(args, values[n])
+		// Timestamps round-trip through several formats; a row whose only
+		// difference is how its dates are spelled is not a change.
+		if !strings.HasSuffix(n, "_at") && !sameValue(current[i], values[n]) {
+			changed = true
+		}
+	}
+	if !changed {
+		r.record(category, key, "unchanged")
+		return nil
+	}
+	args = append(args, keyArgs...)
+	if _, err := r.tx.Exec(
+		fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), strings.Join(where, " AND ")),
+		args...,
+	); err != nil {
+		return fmt.Errorf("restore %s %s: %w", table, key, err)
+	}
+	r.record(category, key, "update")
+	return nil
+}
+
+func (r *restorer) upsertAll(category, table string, keyCols []string, recs []map[string]interface{}) error {
+	for _, rec := range recs {
+		if err := r.upsert(category, table, keyCols, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreList upserts every record of a top-level JSON array file.
+func (r *restorer) restoreList(path, category, table string, keyCols ...string) error {
+	var recs []map[string]interface{}
+	found, err := readSnapshotJSON(r.srcDir, path, &recs)
+	if err != nil || !found {
+		return err
+	}
+	return r.upsertAll(category, table, keyCols, recs)
+}
+
+// restoreEach upserts one record per JSON file in a snapshot directory.
+func (r *restorer) restoreEach(dir, category, table string, keyCols ...string) error {
+	entries, err := os.ReadDir(filepath.Join(r.srcDir, dir))
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		var rec map[string]interface{}
+		if _, err := readSnapshotJSON(r.srcDir, filepath.Join(dir, e.Name()), &rec); err != nil {
+			return err
+		}
+		if err := r.upsert(category, table, keyCols, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreSettings() error {
+	// Secrets were never exported; the last-run bookkeeping describes the
+	// machine that took the snapshot, not this one.
+	skip := func(key string) bool {
+		return key == "jwt_secret" || key == "encryption_key" || strings.HasPrefix(key, "backup_last_")
+	}
+	for _, name := range []string{"general.json", "design.json", "models.json"} {
+		var values map[string]string
+		found, err := readSnapshotJSON(r.srcDir, filepath.Join("settings", name), &values)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if skip(k) {
+				continue
+			}
+			if err := r.upsert("settings", "settings", []string{"key"}, map[string]interface{}{
+				"id": "restore-" + k, "key": k, "value": values[k],
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreWorkspaces() error {
+	return r.restoreList("workspaces.json", "workspaces", "workspaces", "id")
+}
+
+// restoreUsers only refreshes the profile of accounts that already exist.
+// Password hashes are deliberately left out of snapshots, so an account can't
+// be recreated from one — the person finishes setup and signs in again.
+func (r *restorer) restoreUsers() error {
+	var users []map[string]interface{}
+	found, err := readSnapshotJSON(r.srcDir, "users.json", &users)
+	if err != nil || !found {
+		return err
+	}
+	missing := 0
+	for _, u := range users {
+		username, _ := u["username"].(string)
+		var exists int
+		r.tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&exists)
+		if exists == 0 {
+			missing++
+			continue
+		}
+		if err := r.upsert("users", "users", []string{"username"}, map[string]interface{}{
+			"username": username, "display_name": u["display_name"], "avatar_path": u["avatar_path"],
+		}); err != nil {
+			return err
+		}
+	}
+	if missing > 0 {
+		r.warn("%d user account(s) were not recreated: passwords are not part of a backup. Complete setup to create an admin account.", missing)
+	}
+	return nil
+}
+
+func (r *restorer) restoreAgentRoles() error {
+	return r.restoreEach("agent_roles", "agent_roles", "agent_roles", "slug")
+}
+
+// restoreTools brings back tool rows so grants, dashboards and schedules that
+// name them stay valid. Tool source lives outside the snapshot, so a tool
+// whose directory is missing here is restored disabled rather than left for
+// the startup pass to fail compiling.
+func (r *restorer) restoreTools() error {
+	entries, err := os.ReadDir(filepath.Join(r.srcDir, "tools"))
+	if err != nil {
+		return nil
+	}
+	toolsDir := filepath.Join(r.dataDir, "..", "tools")
+	var missing []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		var rec map[string]interface{}
+		if _, err := readSnapshotJSON(r.srcDir, filepath.Join("tools", e.Name()), &rec); err != nil {
+			return err
+		}
+		id, _ := rec["id"].(string)
+		if id == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(toolsDir, id)); err != nil {
+			rec["enabled"] = false
+			if name, _ := rec["name"].(string); name != "" {
+				missing = append(missing, name)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		if err := r.upsert("tools", "tools", []string{"id"}, rec); err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		r.warn("Tool source is not part of a backup; these tools were restored disabled until they are reinstalled: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (r *restorer) restoreAgentToolAccess() error {
+	return r.restoreList("agent_tool_access.json", "agent_tool_access", "agent_tool_access", "id")
+}
+
+// restoreSecrets recreates secret names as placeholders. Only metadata is
+// backed up, so each one has to be filled in again before a tool can use it.
+func (r *restorer) restoreSecrets() error {
+	var recs []map[string]interface{}
+	found, err := readSnapshotJSON(r.srcDir, "secrets.json", &recs)
+	if err != nil || !found {
+		return err
+	}
+	placeholders := 0
+	for _, rec := range recs {
+		name, _ := rec["name"].(string)
+		if name == "" {
+			continue
+		}
+		var exists int
+		r.tx.QueryRow("SELECT COUNT(*) FROM secrets WHERE name = ?", name).Scan(&exists)
+		if exists == 0 {
+			if r.secretsMgr == nil {
+				continue
+			}
+			encrypted, err := r.secretsMgr.Encrypt("REPLACE_ME")
+			if err != nil {
+				return fmt.Errorf("encrypt placeholder: %w", err)
+			}
+			rec["encrypted_value"] = encrypted
+			placeholders++
+		}
+		if err := r.upsert("secrets", "secrets", []string{"name"}, rec); err != nil {
+			return err
+		}
+	}
+	if placeholders > 0 {
+		r.warn("%d secret(s) were recreated as placeholders — secret values are not part of a backup, so set them again in Secrets.", placeholders)
+	}
+	return nil
+}
+
+func (r *restorer) restoreContext() error {
+	var folders []map[string]interface{}
+	if _, err := readSnapshotJSON(r.srcDir, "context/folders.json", &folders); err != nil {
+		return err
+	}
+	if err := r.upsertAll("context", "context_folders", []string{"id"}, parentsFirst(folders, "parent_id")); err != nil {
+		return err
+	}
+	return r.restoreList("context/files.json", "context", "context_files", "id")
+}
+
+func (r *restorer) restoreDashboards() error {
+	return r.restoreEach("dashboards", "dashboards", "dashboards", "id")
+}
+
+func (r *restorer) restoreDatabases() error {
+	var snapshot struct {
+		Databases []map[string]interface{} `json:"databases"`
+		Tables    []map[string]interface{} `json:"tables"`
+		Columns   []map[string]interface{} `json:"columns"`
+		Rows      []map[string]interface{} `json:"rows"`
+	}
+	found, err := readSnapshotJSON(r.srcDir, "databases.json", &snapshot)
+	if err != nil || !found {
+		return err
+	}
+	for _, row := range snapshot.Rows {
+		row["data"] = row["values"]
+	}
+	for _, step := range []struct {
+		table string
+		recs  []map[string]interface{}
+	}{
+		{"user_databases", snapshot.Databases},
+		{"user_database_tables", snapshot.Tables},
+		{"user_database_columns", snapshot.Columns},
+		{"user_database_rows", snapshot.Rows},
+	} {
+		if err := r.upsertAll("databases", step.table, []string{"id"}, step.recs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreSchedules() error {
+	return r.restoreList("schedules.json", "schedules", "schedules", "id")
+}
+
+func (r *restorer) restoreChat() error {
+	if err := r.restoreList("chat/threads.json", "chat", "chat_threads", "id"); err != nil {
+		return err
+	}
+	if err := r.restoreList("chat/thread_members.json", "chat", "thread_members", "thread_id", "agent_role_slug"); err != nil {
+		return err
+	}
+	entries, _ := os.ReadDir(filepath.Join(r.srcDir, "chat", "messages"))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if err := r.restoreList(filepath.Join("chat", "messages", e.Name()), "chat", "chat_messages", "id"); err != nil {
+			return err
+		}
+	}
+	return r.restoreList("chat/attachments/metadata.json", "chat", "chat_attachments", "id")
+}
+
+// restoreNotifications brings back the Inbox. Restored notifications are
+// marked routed so they aren't delivered to the notification channels again.
+func (r *restorer) restoreNotifications() error {
+	var recs []map[string]interface{}
+	found, err := readSnapshotJSON(r.srcDir, "notifications.json", &recs)
+	if err != nil || !found {
+		return err
+	}
+	for _, rec := range recs {
+		rec["routed"] = true
+	}
+	return r.upsertAll("notifications", "notifications", []string{"id"}, recs)
+}
+
+func (r *restorer) restoreSystemStats() error {
+	var stats map[string]float64
+	found, err := readSnapshotJSON(r.srcDir, "system_stats.json", &stats)
+	if err != nil || !found {
+		return err
+	}
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := r.upsert("system_stats", "system_stats", []string{"key"}, map[string]interface{}{
+			"key": k, "value": stats[k],
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreProjects() error {
+	var projects []map[string]interface{}
+	found, err := readSnapshotJSON(r.srcDir, "projects.json", &projects)
+	if err != nil || !found {
+		return err
+	}
+	for _, p := range projects {
+		if err := r.upsert("projects", "projects", []string{"id"}, p); err != nil {
+			return err
+		}
+		repos, _ := p["repos"].([]interface{})
+		for _, raw := range repos {
+			if repo, ok := raw.(map[string]interface{}); ok {
+				if err := r.upsert("projects", "project_repos", []string{"id"}, repo); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreAgentTasks() error {
+	return r.restoreList("agent_tasks.json", "agent_tasks", "agent_tasks", "id")
+}
+
+func (r *restorer) restoreTodoLists() error {
+	var snapshot struct {
+		Lists []map[string]interface{} `json:"lists"`
+		Items []map[string]interface{} `json:"items"`
+	}
+	found, err := readSnapshotJSON(r.srcDir, "todo_lists.json", &snapshot)
+	if err != nil || !found {
+		return err
+	}
+	if err := r.upsertAll("todo_lists", "todo_lists", []string{"id"}, snapshot.Lists); err != nil {
+		return err
+	}
+	return r.upsertAll("todo_lists", "todo_items", []string{"id"}, snapshot.Items)
+}
+
+func (r *restorer) restoreMedia() error {
+	return r.restoreList("media/metadata.json", "media", "media", "id")
+}
+
+// planFiles maps every file-backed part of the snapshot onto its place in the
+// data directory and records whether each would be created or overwritten.
+func (r *restorer) planFiles() {
+	r.planTree("agent_files", filepath.Join(r.srcDir, "agent_files"), func(rel string) string {
+		slug, name, ok := strings.Cut(rel, string(filepath.Separator))
+		if !ok {
+			return ""
+		}
+		return filepath.Join(agents.AgentDir(r.dataDir, slug), name)
+	})
+	r.planTree("skills", filepath.Join(r.srcDir, "skills"), func(rel string) string {
+		return filepath.Join(r.dataDir, "skills", rel)
+	})
+	r.planTree("agent_skills", filepath.Join(r.srcDir, "agent_skills"), func(rel string) string {
+		slug, rest, ok := strings.Cut(rel, string(filepath.Separator))
+		if !ok {
+			return ""
+		}
+		return filepath.Join(agents.AgentDir(r.dataDir, slug), "skills", rest)
+	})
+	r.planTree("context", filepath.Join(r.srcDir, "context", "blobs"), func(rel string) string {
+		return filepath.Join(r.dataDir, "context", rel)
+	})
+	r.planTree("chat", filepath.Join(r.srcDir, "chat", "attachments", "blobs"), func(rel string) string {
+		return filepath.Join(r.dataDir, "chat-attachments", rel)
+	})
+	r.planTree("avatars", filepath.Join(r.srcDir, "avatars"), func(rel string) string {
+		return filepath.Join(r.dataDir, "avatars", rel)
+	})
+	r.planTree("backgrounds", filepath.Join(r.srcDir, "backgrounds"), func(rel string) string {
+		return filepath.Join(r.dataDir, "backgrounds", rel)
+	})
+	r.planTree("media", filepath.Join(r.srcDir, "media", "files"), func(rel string) string {
+		return filepath.Join(r.dataDir, "..", "media", rel)
+	})
+}
+
+func (r *restorer) planTree(category, root string, target func(rel string) string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		// Only regular files: a symlink in the snapshot would have the restore
+		// copy whatever it points at on this machine into the data dir.
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		dst := target(rel)
+		if dst == "" {
+			return nil
+		}
+		action := "create"
+		if existing, err := os.ReadFile(dst); err == nil {
+			incoming, err := os.ReadFile(path)
+			if err == nil && bytes.Equal(existing, incoming) {
+				r.record(category, filepath.ToSlash(rel), "unchanged")
+				return nil
+			}
+			action = "update"
+		}
+		r.record(category, filepath.ToSlash(rel), action)
+		r.files = append(r.files, pendingFile{src: path, dst: dst})
+		return nil
+	})
+}
+
+func (r *restorer) writeFiles() error {
+	for _, f := range r.files {
+		if err := os.MkdirAll(filepath.Dir(f.dst), 0755); err != nil {
+			return fmt.Errorf("restore %s: %w", f.dst, err)
+		}
+		if err := copyFile(f.src, f.dst); err != nil {
+			return fmt.Errorf("restore %s: %w", f.dst, err)
+		}
+	}
+	return nil
+}
+
+// planMemories reads each agent's exported memories and diffs them against
+// that agent's memory database, opened read-only so a dry run creates nothing.
+func (r *restorer) planMemories() error {
+	entries, err := os.ReadDir(filepath.Join(r.srcDir, "memories"))
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		slug := strings.TrimSuffix(e.Name(), ".json")
+		var recs []map[string]interface{}
+		if _, err := readSnapshotJSON(r.srcDir, filepath.Join("memories", e.Name()), &recs); err != nil {
+			return err
+		}
+
+		existing := map[string]string{}
+		dbPath := filepath.Join(agents.AgentDir(r.dataDir, slug), "memory.db")
+		if slug == "gateway" {
+			dbPath = filepath.Join(r.dataDir, "gateway", "memory.db")
+		}
+		if _, err := os.Stat(dbPath); err == nil {
+			if current, err := readMemoriesFromDB(dbPath); err == nil {
+				for _, m := range current {
+					id, _ := m["id"].(string)
+					existing[id] = memoryFingerprint(m)
+				}
+			}
+		}
+
+		var pending []map[string]interface{}
+		for _, rec := range recs {
+			id, _ := rec["id"].(string)
+			if id == "" {
+				continue
+			}
+			fp, ok := existing[id]
+			switch {
+			case !ok:
+				r.record("memories", slug+"/"+id, "create")
+			case fp != memoryFingerprint(rec):
+				r.record("memories", slug+"/"+id, "update")
+			default:
+				r.record("memories", slug+"/"+id, "unchanged")
+				continue
+			}
+			pending = append(pending, rec)
+		}
+		if len(pending) > 0 {
+			r.memories = append(r.memories, pendingMemory{slug: slug, records: pending})
+		}
+	}
+	return nil
+}
+
+func (r *restorer) writeMemories() error {
+	if len(r.memories) == 0 {
+		return nil
+	}
+	mgr := memory.NewManager(r.dataDir)
+	defer mgr.Close()
+	for _, pm := range r.memories {
+		mdb, err := mgr.GetDB(pm.slug)
+		if err != nil {
+			return fmt.Errorf("open memory db for %s: %w", pm.slug, err)
+		}
+		for _, rec := range pm.records {
+			archived := 0
+			if b, _ := rec["archived"].(bool); b {
+				archived = 1
+			}
+			if _, err := mdb.Exec(
+				`INSERT INTO memories (id, content, summary, category, importance, source, tags, access_count, archived, created_at, updated_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				 ON CONFLICT(id) DO UPDATE SET content = excluded.content, summary = excluded.summary,
+				   category = excluded.category, importance = excluded.importance, source = excluded.source,
+				   tags = excluded.tags, access_count = excluded.access_count, archived = excluded.archived,
+				   updated_at = excluded.updated_at`,
+				rec["id"], rec["content"], rec["summary"], rec["category"], sqlValue("importance", rec["importance"], columnInfo{}),
+				rec["source"], rec["tags"], sqlValue("access_count", rec["access_count"], columnInfo{}), archived,
+				rec["created_at"], rec["updated_at"],
+			); err != nil {
+				return fmt.Errorf("restore memory %s/%v: %w", pm.slug, rec["id"], err)
+			}
+		}
+	}
+	return nil
+}
+
+func memoryFingerprint(m map[string]interface{}) string {
+	return fmt.Sprint(m["content"], "\x00", m["summary"], "\x00", m["category"], "\x00",
+		normalizeValue(sqlValue("importance", m["importance"], columnInfo{})), "\x00", m["tags"], "\x00", m["archived"])
+}
+
+// sqlValue converts a decoded JSON value back into what the column stores:
+// booleans as 0/1, nested JSON as text, and exported timestamps as time.Time
+// so they are written in the driver's format like every other row.
+func sqlValue(column string, v interface{}, info columnInfo) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case bool:
+		if val {
+			return 1
+		}
+		return 0
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+		return val
+	case string:
+		// Nullable workspace scopes were exported as "" for NULL.
+		if val == "" && info.nullable && column == "workspace_id" {
+			return nil
+		}
+		if strings.HasSuffix(column, "_at") {
+			if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+				return t.UTC()
+			}
+		}
+		return val
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	default:
+		return val
+	}
+}
+
+func sameValue(current, incoming interface{}) bool {
+	return normalizeValue(current) == normalizeValue(incoming)
+}
+
+func normalizeValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case float64:
+		if val == math.Trunc(val) {
+			return strconv.FormatInt(int64(val), 10)
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// parentsFirst orders self-referencing records so each parent precedes its
+// children, which the foreign key on the parent column requires.
+func parentsFirst(recs []map[string]interface{}, parentKey string) []map[string]interface{} {
+	placed := map[string]bool{}
+	var ordered []map[string]interface{}
+	remaining := recs
+	for len(remaining) > 0 {
+		var next []map[string]interface{}
+		for _, rec := range remaining {
+			parent, _ := rec[parentKey].(string)
+			if parent == "" || placed[parent] {
+				id, _ := rec["id"].(string)
+				placed[id] = true
+				ordered = append(ordered, rec)
+			} else {
+				next = append(next, rec)
+			}
+		}
+		if len(next) == len(remaining) {
+			// Orphans whose parent isn't in the snapshot: let the foreign key
+			// decide rather than looping forever.
+			ordered = append(ordered, next...)
+			break
+		}
+		remaining = next
+	}
+	return ordered
+}
+
+func readSnapshotJSON(srcDir, rel string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(srcDir, rel))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("decode %s: %w", rel, err)
+	}
+	return true, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
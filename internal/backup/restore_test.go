@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/secrets"
+	"github.com/openpaw/openpaw/internal/userdb"
+)
+
+func TestRestoreRoundTrip(t *testing.T) {
+	srcDataDir := t.TempDir()
+	src, err := database.New(srcDataDir)
+	if err != nil {
+		t.Fatalf("open source database: %v", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('ws-lab', 'Lab', 1)"); err != nil {
+		t.Fatalf("insert workspace: %v", err)
+	}
+	if _, err := src.Exec("INSERT INTO agent_roles (id, slug, name, system_prompt) VALUES ('role-1', 'scout', 'Scout', 'Find things.')"); err != nil {
+		t.Fatalf("insert agent role: %v", err)
+	}
+	if _, err := src.Exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('thread-1', 'Plans', 'ws-lab')"); err != nil {
+		t.Fatalf("insert thread: %v", err)
+	}
+	if _, err := src.Exec("INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug) VALUES ('msg-1', 'thread-1', 'assistant', 'Done.', 'scout')"); err != nil {
+		t.Fatalf("insert message: %v", err)
+	}
+	if _, err := src.Exec("INSERT INTO secrets (id, name, encrypted_value) VALUES ('sec-1', 'GITHUB_TOKEN', 'x')"); err != nil {
+		t.Fatalf("insert secret: %v", err)
+	}
+	store := userdb.NewStore(src)
+	created, err := store.CreateDatabase("ws-lab", "Leads", "")
+	if err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	if _, err := store.CreateRow("ws-lab", created.Tables[0].ID, map[string]interface{}{
+		created.Tables[0].Columns[0].ID: "Acme",
+	}); err != nil {
+		t.Fatalf("create row: %v", err)
+	}
+	mem := memory.NewManager(srcDataDir)
+	if _, err := mem.Add("scout", memory.Record{Content: "Staging moved to staging2.example.com"}); err != nil {
+		t.Fatalf("add memory: %v", err)
+	}
+	mem.Close()
+	os.MkdirAll(filepath.Join(srcDataDir, "avatars"), 0755)
+	os.WriteFile(filepath.Join(srcDataDir, "avatars", "scout.png"), []byte("png"), 0644)
+
+	snapshot := t.TempDir()
+	if _, err := exportData(src, srcDataDir, snapshot); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dstDataDir := t.TempDir()
+	dst, err := database.New(dstDataDir)
+	if err != nil {
+		t.Fatalf("open destination database: %v", err)
+	}
+	defer dst.Close()
+	key, _ := secrets.GenerateKey()
+	secretsMgr := secrets.NewManager(key)
+
+	preview, err := RestoreFromDir(dst, secretsMgr, dstDataDir, snapshot, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if preview.Summary["databases"] == nil || preview.Summary["databases"].Created == 0 {
+		t.Fatalf("dry run summary = %#v, want created database rows", preview.Summary)
+	}
+	if preview.Summary["memories"] == nil || preview.Summary["memories"].Created != 1 {
+		t.Fatalf("dry run memories = %#v, want one created", preview.Summary["memories"])
+	}
+	var count int
+	dst.QueryRow("SELECT COUNT(*) FROM user_database_rows").Scan(&count)
+	if count != 0 {
+		t.Fatalf("dry run wrote %d rows", count)
+	}
+	if _, err := os.Stat(filepath.Join(dstDataDir, "avatars", "scout.png")); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote avatar file (err=%v)", err)
+	}
+
+	result, err := RestoreFromDir(dst, secretsMgr, dstDataDir, snapshot, false)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	var workspaceID, content string
+	if err := dst.QueryRow("SELECT workspace_id FROM chat_threads WHERE id = 'thread-1'").Scan(&workspaceID); err != nil || workspaceID != "ws-lab" {
+		t.Fatalf("thread workspace = %q (err=%v)", workspaceID, err)
+	}
+	if err := dst.QueryRow("SELECT content FROM chat_messages WHERE id = 'msg-1'").Scan(&content); err != nil || content != "Done." {
+		t.Fatalf("message content = %q (err=%v)", content, err)
+	}
+	named, err := userdb.NewStore(dst).NamedRows("ws-lab", created.Tables[0].ID, "", 10, 0)
+	if err != nil || len(named.Records) != 1 || named.Records[0]["Name"] != "Acme" {
+		t.Fatalf("restored rows = %#v (err=%v)", named.Records, err)
+	}
+	var encrypted string
+	dst.QueryRow("SELECT encrypted_value FROM secrets WHERE name = 'GITHUB_TOKEN'").Scan(&encrypted)
+	if plain, _ := secretsMgr.Decrypt(encrypted); plain != "REPLACE_ME" {
+		t.Fatalf("secret placeholder = %q", plain)
+	}
+	if len(result.Warnings) == 0 || !strings.Contains(strings.Join(result.Warnings, "\n"), "placeholder") {
+		t.Fatalf("warnings = %v, want a placeholder warning", result.Warnings)
+	}
+	if data, err := os.ReadFile(filepath.Join(dstDataDir, "avatars", "scout.png")); err != nil || string(data) != "png" {
+		t.Fatalf("avatar = %q (err=%v)", data, err)
+	}
+	restoredMem := memory.NewManager(dstDataDir)
+	defer restoredMem.Close()
+	recent, err := restoredMem.Recent("scout", 10)
+	if err != nil || len(recent) != 1 || !strings.Contains(recent[0].Content, "staging2") {
+		t.Fatalf("restored memories = %#v (err=%v)", recent, err)
+	}
+
+	again, err := RestoreFromDir(dst, secretsMgr, dstDataDir, snapshot, true)
+	if err != nil {
+		t.Fatalf("second dry run: %v", err)
+	}
+	for category, counts := range again.Summary {
+		if counts.Created != 0 || counts.Updated != 0 {
+			t.Errorf("second dry run %s = %+v, want everything unchanged", category, *counts)
+		}
+	}
+}
+
+func TestRestoreRejectsNewerManifest(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := database.New(dataDir)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	snapshot := t.TempDir()
+	writeJSONFile(filepath.Join(snapshot, "manifest.json"), Manifest{Version: "2.0"})
+	if _, err := RestoreFromDir(db, nil, dataDir, snapshot, true); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("err = %v, want unsupported manifest", err)
+	}
+
+	writeJSONFile(filepath.Join(snapshot, "manifest.json"), Manifest{Version: "1.0"})
+	if _, err := RestoreFromDir(db, nil, dataDir, snapshot, true); err != nil {
+		t.Fatalf("older manifest rejected: %v", err)
+	}
+}
+
+// A snapshot is whatever is in the backup repo. A symlink in it must not
+// have the restore copy a file from this machine into the data dir.
+func TestRestoreSkipsSymlinks(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := database.New(dataDir)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	secret := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(secret, []byte("private key"), 0600)
+	snapshot := t.TempDir()
+	writeJSONFile(filepath.Join(snapshot, "manifest.json"), Manifest{Version: "1.0"})
+	os.MkdirAll(filepath.Join(snapshot, "avatars"), 0755)
+	os.WriteFile(filepath.Join(snapshot, "avatars", "scout.png"), []byte("png"), 0644)
+	if err := os.Symlink(secret, filepath.Join(snapshot, "avatars", "stolen.png")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if _, err := RestoreFromDir(db, nil, dataDir, snapshot, false); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dataDir, "avatars", "scout.png")); err != nil || string(data) != "png" {
+		t.Fatalf("avatar = %q (err=%v)", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dataDir, "avatars", "stolen.png")); !os.IsNotExist(err) {
+		t.Errorf("symlinked file was restored (err=%v)", err)
+	}
+}
+
+func TestCloneSnapshotResolvesRefs(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(repo, "manifest.json"), []byte(`{"version":"1.0"}`), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	git("tag", "v1")
+	first := git("rev-parse", "--short", "HEAD")
+	os.WriteFile(filepath.Join(repo, "manifest.json"), []byte(`{"version":"1.1"}`), 0644)
+	git("commit", "-q", "-am", "second")
+
+	sha, err := cloneSnapshot("file://"+repo, "", "", "v1", filepath.Join(t.TempDir(), "snapshot"))
+	if err != nil || sha != first {
+		t.Fatalf("clone v1 = %q, %v; want %s", sha, err, first)
+	}
+	if _, err := cloneSnapshot("file://"+repo, "", "", "no-such-tag", filepath.Join(t.TempDir(), "snapshot")); err == nil {
+		t.Error("an unknown ref was checked out")
+	}
+	// So are a bare path and anything git would read as an option or run.
+	for _, repoURL := range []string{repo, "--upload-pack=touch /tmp/x", "ext::sh -c touch% /tmp/x", "ssh://-oProxyCommand=x/repo"} {
+		if _, err := cloneSnapshot(repoURL, "", "", "", filepath.Join(t.TempDir(), "snapshot")); !errors.Is(err, ErrInvalidRepoURL) {
+			t.Errorf("repo %q: err = %v, want ErrInvalidRepoURL", repoURL, err)
+		}
+	}
+	for _, repoURL := range []string{"https://github.com/me/backup.git", "ssh://git@github.com/me/backup.git", "git@github.com:me/backup.git"} {
+		if !validRepoURL(repoURL) {
+			t.Errorf("repo %q refused", repoURL)
+		}
+	}
+	// An option-shaped ref is refused before git sees it.
+	for _, ref := range []string{"--orphan=x", "-b", "v1 --force"} {
+		if _, err := cloneSnapshot("file://"+repo, "", "", ref, filepath.Join(t.TempDir(), "snapshot")); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("ref %q: err = %v, want ErrInvalidRef", ref, err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package budget records what every agent run costs and enforces spend limits
+// per agent, workspace and schedule. Runs are checked before they start and
+// recorded after they finish; see agents.Manager.RunBudgeted for the one place
+// both happen.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// What a budget can cap.
+const (
+	ScopeAgent     = "agent"
+	ScopeWorkspace = "workspace"
+	ScopeSchedule  = "schedule"
+)
+
+// Budget periods. Both are calendar periods in UTC, so a daily budget resets at
+// midnight UTC rather than 24 hours after the first run.
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// Where a run came from, recorded in the ledger.
+const (
+	SourceChat      = "chat"
+	SourceSchedule  = "schedule"
+	SourceHeartbeat = "heartbeat"
+	SourceSubagent  = "subagent"
+)
+
+// Scope identifies who a run is charged to. Any field may be empty; a run is
+// checked against every budget whose scope it matches.
+type Scope struct {
+	AgentSlug   string
+	WorkspaceID string
+	ScheduleID  string
+	ThreadID    string
+	Source      string
+	// ExecutionID is the schedule_executions row of a scheduled run. The
+	// ledger doesn't need it, but whatever the run changes can say which run
+	// it was.
+	ExecutionID string
+}
+
+type scopeKey struct{}
+
+// WithScope attaches a run's scope to its context, so the code that finally
+// calls the provider — several layers down — can charge the right budgets.
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFrom returns the scope attached to ctx, if any.
+func ScopeFrom(ctx context.Context) Scope {
+	s, _ := ctx.Value(scopeKey{}).(Scope)
+	return s
+}
+
+// Merge fills s's empty fields from other, so an outer caller's scope (the
+// schedule) survives an inner one adding the agent and workspace.
+func (s Scope) Merge(other Scope) Scope {
+	if s.AgentSlug == "" {
+		s.AgentSlug = other.AgentSlug
+	}
+	if s.WorkspaceID == "" {
+		s.WorkspaceID = other.WorkspaceID
+	}
+	if s.ScheduleID == "" {
+		s.ScheduleID = other.ScheduleID
+	}
+	if s.ThreadID == "" {
+		s.ThreadID = other.ThreadID
+	}
+	if s.Source == "" {
+		s.Source = other.Source
+	}
+	if s.ExecutionID == "" {
+		s.ExecutionID = other.ExecutionID
+	}
+	return s
+}
+
+// Usage is what one finished run cost.
+type Usage struct {
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	CostUSD      float64
+}
+
+// ExceededError refuses a run because a hard limit has been reached.
+type ExceededError struct {
+	Budget      models.Budget
+	SpentUSD    float64
+	SpentTokens int64
+}
+
+func (e *ExceededError) Error() string {
+	b := e.Budget
+	what := fmt.Sprintf("$%.2f of $%.2f", e.SpentUSD, b.LimitUSD)
+	if b.LimitUSD <= 0 || (b.LimitTokens > 0 && e.SpentTokens >= b.LimitTokens) {
+		what = fmt.Sprintf("%d of %d tokens", e.SpentTokens, b.LimitTokens)
+	}
+	return fmt.Sprintf("budget exceeded: %s %s %q has used %s this %s period",
+		b.Period, b.ScopeType, b.ScopeID, what, b.Period)
+}
+
+// IsExceeded reports whether err is, or wraps, a budget refusal.
+func IsExceeded(err error) bool {
+	var e *ExceededError
+	return errors.As(err, &e)
+}
+
+// NotifyFunc files a notification in the Inbox.
+type NotifyFunc func(models.NotificationInput)
+
+type Manager struct {
+	db       *database.DB
+	notifyFn NotifyFunc
+	now      func() time.Time
+}
+
+func New(db *database.DB) *Manager {
+	return &Manager{db: db, now: time.Now}
+}
+
+func (m *Manager) SetNotifyFunc(fn NotifyFunc) {
+	m.notifyFn = fn
+}
+
+// PeriodStart returns the start of the period containing t, in UTC.
+func PeriodStart(period string, t time.Time) time.Time {
+	t = t.UTC()
+	if period == PeriodMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+const budgetColumns = "id, scope_type, scope_id, period, limit_usd, limit_tokens, soft_percent, enabled, created_at, updated_at"
+
+func scanBudget(row interface{ Scan(...interface{}) error }) (models.Budget, error) {
+	var b models.Budget
+	err := row.Scan(&b.ID, &b.ScopeType, &b.ScopeID, &b.Period, &b.LimitUSD, &b.LimitTokens,
+		&b.SoftPercent, &b.Enabled, &b.CreatedAt, &b.UpdatedAt)
+	return b, err
+}
+
+// matching returns the enabled budgets a scope is charged against.
+func (m *Manager) matching(s Scope) []models.Budget {
+	var out []models.Budget
+	for _, pair := range [][2]string{
+		{ScopeAgent, s.AgentSlug},
+		{ScopeWorkspace, s.WorkspaceID},
+		{ScopeSchedule, s.ScheduleID},
+	} {
+		if pair[1] == "" {
+			continue
+		}
+		rows, err := m.db.Query(
+			"SELECT "+budgetColumns+" FROM budgets WHERE scope_type = ? AND scope_id = ? AND enabled = 1", pair[0], pair[1],
+		)
+		if err != nil {
+			logger.Error("Failed to load budgets for %s %s: %v", pair[0], pair[1], err)
+			continue
+		}
+		for rows.Next() {
+			if b, err := scanBudget(rows); err == nil {
+				out = append(out, b)
+			}
+		}
+		rows.Close()
+	}
+	return out
+}
+
+// scopeColumn is the ledger column a budget's scope type filters on.
+func scopeColumn(scopeType string) string {
+	switch scopeType {
+	case ScopeWorkspace:
+		return "workspace_id"
+	case ScopeSchedule:
+		return "schedule_id"
+	}
+	return "agent_role_slug"
+}
+
+// Spent fills in a budget's spend for its current period.
+func (m *Manager) Spent(b *models.Budget) {
+	b.PeriodStart = PeriodStart(b.Period, m.now())
+	m.db.QueryRow(
+		"SELECT COALESCE(SUM(cost_usd), 0), COALESCE(SUM(input_tokens + output_tokens), 0) FROM usage_ledger WHERE "+
+			scopeColumn(b.ScopeType)+" = ? AND created_at >= ?",
+		b.ScopeID, b.PeriodStart,
+	).Scan(&b.SpentUSD, &b.SpentTokens)
+}
+
+// overLimit reports whether spend has reached pct percent of either limit.
+func overLimit(b models.Budget, pct int) bool {
+	if b.LimitUSD > 0 && b.SpentUSD >= b.LimitUSD*float64(pct)/100 {
+		return true
+	}
+	return b.LimitTokens > 0 && float64(b.SpentTokens) >= float64(b.LimitTokens)*float64(pct)/100
+}
+
+// Check refuses a run when any budget its scope matches is at its hard limit.
+// A nil Manager allows everything.
+func (m *Manager) Check(s Scope) error {
+	if m == nil {
+		return nil
+	}
+	for _, b := range m.matching(s) {
+		m.Spent(&b)
+		if overLimit(b, 100) {
+			return &ExceededError{Budget: b, SpentUSD: b.SpentUSD, SpentTokens: b.SpentTokens}
+		}
+	}
+	return nil
+}
+
+// Record adds a finished run to the ledger and sends any warning it triggers:
+// once when spend first reaches the soft threshold in a period, and once when
+// it reaches the hard limit (from which point Check refuses new runs).
+func (m *Manager) Record(s Scope, u Usage) {
+	if m == nil {
+		return
+	}
+	if s.Source == "" {
+		s.Source = SourceChat
+	}
+	if _, err := m.db.Exec(
+		`INSERT INTO usage_ledger (id, source, agent_role_slug, workspace_id, schedule_id, thread_id, provider, model,
+		                           input_tokens, output_tokens, cost_usd, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), s.Source, s.AgentSlug, s.WorkspaceID, s.ScheduleID, s.ThreadID, u.Provider, u.Model,
+		u.InputTokens, u.OutputTokens, u.CostUSD, m.now().UTC(),
+	); err != nil {
+		logger.Error("Failed to record usage for %s: %v", s.AgentSlug, err)
+		return
+	}
+
+	for _, b := range m.matching(s) {
+		m.Spent(&b)
+		switch {
+		case overLimit(b, 100):
+			m.alert(b, "hard", s.WorkspaceID)
+		case b.SoftPercent > 0 && b.SoftPercent < 100 && overLimit(b, b.SoftPercent):
+			m.alert(b, "soft", s.WorkspaceID)
+		}
+	}
+}
+
+// alert files a budget warning, at most once per budget, period and level.
+func (m *Manager) alert(b models.Budget, level, workspaceID string) {
+	res, err := m.db.Exec(
+		"INSERT OR IGNORE INTO budget_alerts (budget_id, period_start, level, created_at) VALUES (?, ?, ?, ?)",
+		b.ID, b.PeriodStart.Format("2006-01-02"), level, m.now().UTC(),
+	)
+	if err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 || m.notifyFn == nil {
+		return
+	}
+
+	spend := fmt.Sprintf("$%.2f", b.SpentUSD)
+	if b.LimitUSD > 0 {
+		spend += fmt.Sprintf(" of $%.2f", b.LimitUSD)
+	}
+	spend += fmt.Sprintf(", %d", b.SpentTokens)
+	if b.LimitTokens > 0 {
+		spend += fmt.Sprintf(" of %d", b.LimitTokens)
+	}
+	spend += " tokens"
+
+	in := models.NotificationInput{
+		WorkspaceID: workspaceID,
+		SourceType:  "budget",
+		SourceID:    b.ID,
+		Link:        "/settings/budgets",
+	}
+	if b.ScopeType == ScopeAgent {
+		in.SourceAgentSlug = b.ScopeID
+	}
+	subject := fmt.Sprintf("%s budget for %s %s", b.Period, b.ScopeType, b.ScopeID)
+	if level == "hard" {
+		in.Title = "Budget reached — " + subject
+		in.Body = "New runs are refused until the period resets. Spent " + spend + "."
+		in.Priority = "high"
+	} else {
+		in.Title = fmt.Sprintf("Budget at %d%% — %s", b.SoftPercent, subject)
+		in.Body = "Spent " + spend + " so far this period."
+		in.Priority = "normal"
+	}
+	in.Detail = in.Body
+	m.notifyFn(in)
+}
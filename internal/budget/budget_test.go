@@ -0,0 +1,100 @@
+package budget
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+func newTestManager(t *testing.T) (*Manager, *[]models.NotificationInput) {
+	t.Helper()
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var sent []models.NotificationInput
+	m := New(db)
+	m.SetNotifyFunc(func(in models.NotificationInput) { sent = append(sent, in) })
+	return m, &sent
+}
+
+func addBudget(t *testing.T, m *Manager, id, scopeType, scopeID, period string, usd float64, tokens int64) {
+	t.Helper()
+	if _, err := m.db.Exec(
+		"INSERT INTO budgets (id, scope_type, scope_id, period, limit_usd, limit_tokens, soft_percent) VALUES (?, ?, ?, ?, ?, ?, 80)",
+		id, scopeType, scopeID, period, usd, tokens,
+	); err != nil {
+		t.Fatalf("insert budget: %v", err)
+	}
+}
+
+func TestBudgetSoftThenHardLimit(t *testing.T) {
+	m, sent := newTestManager(t)
+	addBudget(t, m, "b1", ScopeSchedule, "nightly", PeriodDaily, 1.00, 0)
+	run := Scope{AgentSlug: "scout", ScheduleID: "nightly", Source: SourceSchedule}
+
+	m.Record(run, Usage{Provider: "openrouter", Model: "m", CostUSD: 0.50})
+	if err := m.Check(run); err != nil || len(*sent) != 0 {
+		t.Fatalf("at 50%%: Check = %v, %d alerts; want allowed, none", err, len(*sent))
+	}
+
+	// The soft warning goes out once, not after every run past it.
+	m.Record(run, Usage{CostUSD: 0.35})
+	m.Record(run, Usage{CostUSD: 0.01})
+	if len(*sent) != 1 || (*sent)[0].Priority != "normal" {
+		t.Fatalf("past 80%%: alerts = %+v, want one soft warning", *sent)
+	}
+
+	m.Record(run, Usage{CostUSD: 0.20})
+	err := m.Check(run)
+	if !IsExceeded(fmt.Errorf("scheduled prompt failed: %w", err)) {
+		t.Fatalf("over the limit: Check = %v, want a budget refusal", err)
+	}
+	if len(*sent) != 2 || (*sent)[1].Priority != "high" {
+		t.Errorf("at the limit: alerts = %+v, want a hard warning after the soft one", *sent)
+	}
+
+	// Other schedules, even for the same agent, are unaffected.
+	if err := m.Check(Scope{AgentSlug: "scout", ScheduleID: "weekly"}); err != nil {
+		t.Errorf("unrelated schedule refused: %v", err)
+	}
+}
+
+func TestBudgetTokenLimitAndPeriods(t *testing.T) {
+	m, _ := newTestManager(t)
+	addBudget(t, m, "daily", ScopeAgent, "scout", PeriodDaily, 0, 1000)
+	addBudget(t, m, "monthly", ScopeWorkspace, "ws1", PeriodMonthly, 5, 0)
+
+	day := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return day }
+	m.Record(Scope{AgentSlug: "scout", WorkspaceID: "ws1"}, Usage{InputTokens: 900, OutputTokens: 200, CostUSD: 3})
+	if err := m.Check(Scope{AgentSlug: "scout"}); !IsExceeded(err) {
+		t.Fatalf("1100 of 1000 tokens: Check = %v, want refused", err)
+	}
+
+	// Midnight UTC resets the daily budget but not the monthly one.
+	day = day.Add(2 * time.Hour)
+	if err := m.Check(Scope{AgentSlug: "scout"}); err != nil {
+		t.Errorf("next day: Check = %v, want allowed", err)
+	}
+	m.Record(Scope{AgentSlug: "other", WorkspaceID: "ws1"}, Usage{CostUSD: 2})
+	if err := m.Check(Scope{AgentSlug: "scout", WorkspaceID: "ws1"}); !IsExceeded(err) {
+		t.Errorf("workspace at $5 of $5 this month: Check = %v, want refused", err)
+	}
+
+	rep, err := m.Report(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Total.Runs != 2 || rep.Total.CostUSD != 5 || len(rep.ByAgent) != 2 || len(rep.ByDay) != 2 {
+		t.Errorf("report = %+v, want 2 runs, $5, two agents over two days", rep)
+	}
+	if rep.ByDay[0].Key != "2026-03-10" {
+		t.Errorf("first day = %q, want 2026-03-10", rep.ByDay[0].Key)
+	}
+}
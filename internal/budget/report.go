@@ -0,0 +1,111 @@
+package budget
+
+import (
+	"time"
+
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// ReportRow is spend summed over one group of ledger rows.
+type ReportRow struct {
+	Key          string  `json:"key"`
+	Runs         int     `json:"runs"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// Report is spend over a time range, totalled and broken down four ways.
+type Report struct {
+	From       time.Time   `json:"from"`
+	To         time.Time   `json:"to"`
+	Total      ReportRow   `json:"total"`
+	ByAgent    []ReportRow `json:"by_agent"`
+	ByModel    []ReportRow `json:"by_model"`
+	ByProvider []ReportRow `json:"by_provider"`
+	BySource   []ReportRow `json:"by_source"`
+	ByDay      []ReportRow `json:"by_day"`
+}
+
+// Report aggregates the ledger between from (inclusive) and to (exclusive).
+func (m *Manager) Report(from, to time.Time) (*Report, error) {
+	from, to = from.UTC(), to.UTC()
+	rep := &Report{From: from, To: to}
+	if err := m.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		 FROM usage_ledger WHERE created_at >= ? AND created_at < ?`, from, to,
+	).Scan(&rep.Total.Runs, &rep.Total.InputTokens, &rep.Total.OutputTokens, &rep.Total.CostUSD); err != nil {
+		return nil, err
+	}
+	rep.Total.Key = "total"
+
+	for _, g := range []struct {
+		expr, order string
+		into        *[]ReportRow
+	}{
+		{"agent_role_slug", "cost DESC", &rep.ByAgent},
+		{"model", "cost DESC", &rep.ByModel},
+		{"provider", "cost DESC", &rep.ByProvider},
+		{"source", "cost DESC", &rep.BySource},
+		// Timestamps are stored in UTC, so the date prefix is the UTC day.
+		{"substr(created_at, 1, 10)", "k ASC", &rep.ByDay},
+	} {
+		rows, err := m.db.Query(
+			`SELECT `+g.expr+` AS k, COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+			        COALESCE(SUM(cost_usd), 0) AS cost
+			 FROM usage_ledger WHERE created_at >= ? AND created_at < ?
+			 GROUP BY k ORDER BY `+g.order, from, to,
+		)
+		if err != nil {
+			return nil, err
+		}
+		*g.into = []ReportRow{}
+		for rows.Next() {
+			var r ReportRow
+			if err := rows.Scan(&r.Key, &r.Runs, &r.InputTokens, &r.OutputTokens, &r.CostUSD); err == nil {
+				*g.into = append(*g.into, r)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rep, nil
+}
+
+// List returns every budget with its spend in the current period.
+func (m *Manager) List() ([]models.Budget, error) {
+	rows, err := m.db.Query("SELECT " + budgetColumns + " FROM budgets ORDER BY scope_type, scope_id, period")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []models.Budget{}
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			continue
+		}
+		budgets = append(budgets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range budgets {
+		m.Spent(&budgets[i])
+	}
+	return budgets, nil
+}
+
+// Get returns one budget with its current spend.
+func (m *Manager) Get(id string) (*models.Budget, error) {
+	b, err := scanBudget(m.db.QueryRow("SELECT "+budgetColumns+" FROM budgets WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	m.Spent(&b)
+	return &b, nil
+}
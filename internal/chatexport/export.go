@@ -0,0 +1,403 @@
+// Package chatexport takes a chat thread out of OpenPaw: as a JSON document
+// another instance can import, and as Markdown or a self-contained HTML page
+// for reading. It also keeps the read-only share links that serve that page to
+// someone without an account.
+//
+// The JSON document is the whole thread — every branch, the focused message
+// threads opened from its messages, tool calls, widgets, attachment bytes and
+// what each reply cost. Markdown and HTML show the conversation as it reads:
+// the active branch only.
+package chatexport
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+const (
+	Format        = "openpaw-chat-thread"
+	formatVersion = 1
+)
+
+// ErrInvalid is returned when importing a document that isn't a usable thread
+// export.
+var ErrInvalid = errors.New("invalid chat export")
+
+// Document is the JSON export of one thread.
+type Document struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Thread     Thread    `json:"thread"`
+}
+
+// Thread is a conversation with every branch. HeadMessageID marks the branch
+// being shown; RootMessageID is set on a focused message thread, naming the
+// message of the parent thread it was opened from.
+type Thread struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	RootMessageID  string    `json:"root_message_id,omitempty"`
+	HeadMessageID  string    `json:"head_message_id"`
+	TotalCostUSD   float64   `json:"total_cost_usd"`
+	InputTokens    int       `json:"input_tokens"`
+	OutputTokens   int       `json:"output_tokens"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Messages       []Message `json:"messages"`
+	MessageThreads []Thread  `json:"message_threads,omitempty"`
+}
+
+// Message is one chat message. Widgets and ToolCalls are the JSON arrays the
+// chat stores, kept as they are.
+type Message struct {
+	ID            string          `json:"id"`
+	ParentID      string          `json:"parent_id"`
+	Role          string          `json:"role"`
+	Content       string          `json:"content"`
+	AgentRoleSlug string          `json:"agent_role_slug,omitempty"`
+	AgentName     string          `json:"agent_name,omitempty"`
+	CostUSD       float64         `json:"cost_usd"`
+	InputTokens   int             `json:"input_tokens"`
+	OutputTokens  int             `json:"output_tokens"`
+	Widgets       json.RawMessage `json:"widgets,omitempty"`
+	ToolCalls     json.RawMessage `json:"tool_calls,omitempty"`
+	ImageURL      string          `json:"image_url,omitempty"`
+	Stopped       bool            `json:"stopped,omitempty"`
+	Attachments   []Attachment    `json:"attachments,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Attachment is a file uploaded with a message. Data is its content, base64
+// in the JSON; it is empty when the file was missing from disk.
+type Attachment struct {
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int64  `json:"size_bytes"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+type Store struct {
+	db             *database.DB
+	attachmentsDir string
+}
+
+// New returns a store reading and writing chat attachments in attachmentsDir.
+func New(db *database.DB, attachmentsDir string) *Store {
+	return &Store{db: db, attachmentsDir: attachmentsDir}
+}
+
+// Export loads a thread with its message threads, or sql.ErrNoRows.
+func (s *Store) Export(threadID string) (*Document, error) {
+	t, err := s.loadThread(threadID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(
+		"SELECT id FROM chat_threads WHERE parent_thread_id = ? ORDER BY created_at ASC", threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var childIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			childIDs = append(childIDs, id)
+		}
+	}
+	rows.Close()
+	for _, id := range childIDs {
+		child, err := s.loadThread(id)
+		if err != nil {
+			return nil, err
+		}
+		t.MessageThreads = append(t.MessageThreads, *child)
+	}
+
+	return &Document{Format: Format, Version: formatVersion, ExportedAt: time.Now().UTC(), Thread: *t}, nil
+}
+
+func (s *Store) loadThread(threadID string) (*Thread, error) {
+	var t Thread
+	if err := s.db.QueryRow(
+		"SELECT id, title, root_message_id, head_message_id, created_at, updated_at FROM chat_threads WHERE id = ?",
+		threadID,
+	).Scan(&t.ID, &t.Title, &t.RootMessageID, &t.HeadMessageID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT m.id, COALESCE(m.parent_id, ''), m.role, m.content, m.agent_role_slug, COALESCE(ar.name, ''),
+		        m.cost_usd, m.input_tokens, m.output_tokens, COALESCE(m.widget_data, ''),
+		        COALESCE(m.tool_calls_json, ''), COALESCE(m.image_url, ''), m.stopped, m.created_at
+		 FROM chat_messages m
+		 LEFT JOIN agent_roles ar ON ar.slug = m.agent_role_slug AND m.agent_role_slug != ''
+		 WHERE m.thread_id = ?
+		 ORDER BY m.created_at ASC, m.rowid ASC`,
+		threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	t.Messages = []Message{}
+	for rows.Next() {
+		var m Message
+		var widgets, toolCalls string
+		if err := rows.Scan(
+			&m.ID, &m.ParentID, &m.Role, &m.Content, &m.AgentRoleSlug, &m.AgentName,
+			&m.CostUSD, &m.InputTokens, &m.OutputTokens, &widgets,
+			&toolCalls, &m.ImageURL, &m.Stopped, &m.CreatedAt,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.Widgets = rawJSON(widgets)
+		m.ToolCalls = rawJSON(toolCalls)
+		index[m.ID] = len(t.Messages)
+		t.Messages = append(t.Messages, m)
+		t.TotalCostUSD += m.CostUSD
+		t.InputTokens += m.InputTokens
+		t.OutputTokens += m.OutputTokens
+	}
+	rows.Close()
+
+	attRows, err := s.db.Query(
+		`SELECT a.message_id, a.filename, a.original_name, a.mime_type, a.size_bytes
+		 FROM chat_attachments a JOIN chat_messages m ON m.id = a.message_id
+		 WHERE m.thread_id = ? ORDER BY a.created_at ASC`,
+		threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer attRows.Close()
+	for attRows.Next() {
+		var messageID, filename string
+		var a Attachment
+		if err := attRows.Scan(&messageID, &filename, &a.Name, &a.MimeType, &a.SizeBytes); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(s.attachmentsDir, filepath.Base(filename)))
+		if err != nil {
+			logger.Warn("Chat export: attachment %s of message %s is missing: %v", a.Name, messageID, err)
+		}
+		a.Data = data
+		if i, ok := index[messageID]; ok {
+			t.Messages[i].Attachments = append(t.Messages[i].Attachments, a)
+		}
+	}
+	return &t, attRows.Err()
+}
+
+// rawJSON keeps a stored JSON column as-is, dropping it if it is empty or
+// not JSON at all.
+func rawJSON(s string) json.RawMessage {
+	if s == "" || !json.Valid([]byte(s)) {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// Branch returns the messages on the thread's active branch, in the order the
+// chat shows them.
+func (t *Thread) Branch() []Message {
+	byID := make(map[string]Message, len(t.Messages))
+	for _, m := range t.Messages {
+		byID[m.ID] = m
+	}
+	onBranch := map[string]bool{}
+	for id := t.HeadMessageID; id != "" && !onBranch[id]; {
+		m, ok := byID[id]
+		if !ok {
+			break
+		}
+		onBranch[id] = true
+		id = m.ParentID
+	}
+	var out []Message
+	for _, m := range t.Messages {
+		if onBranch[m.ID] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Import recreates an exported thread in a workspace under new IDs and
+// returns the new thread's ID. What the replies cost is not carried over:
+// that spend belongs to the instance that paid it, and counting it again here
+// would inflate this one's usage figures.
+func (s *Store) Import(doc *Document, workspaceID string) (string, error) {
+	if doc.Format != Format {
+		return "", fmt.Errorf("%w: not an OpenPaw chat thread export", ErrInvalid)
+	}
+	if doc.Version < 1 || doc.Version > formatVersion {
+		return "", fmt.Errorf("%w: unsupported version %d", ErrInvalid, doc.Version)
+	}
+	if err := validateThread(&doc.Thread); err != nil {
+		return "", err
+	}
+	for i := range doc.Thread.MessageThreads {
+		if err := validateThread(&doc.Thread.MessageThreads[i]); err != nil {
+			return "", err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var written []string
+	ids := map[string]string{}
+	threadID, err := s.importThread(tx, &doc.Thread, workspaceID, "", "", ids, &written)
+	if err == nil {
+		for i := range doc.Thread.MessageThreads {
+			child := &doc.Thread.MessageThreads[i]
+			root, ok := ids[child.RootMessageID]
+			if !ok {
+				continue // opened from a message the export doesn't have
+			}
+			if _, err = s.importThread(tx, child, workspaceID, threadID, root, ids, &written); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return "", err
+	}
+	return threadID, nil
+}
+
+func validateThread(t *Thread) error {
+	if strings.TrimSpace(t.Title) == "" {
+		t.Title = "Imported chat"
+	}
+	seen := map[string]bool{}
+	for _, m := range t.Messages {
+		if m.ID == "" || seen[m.ID] {
+			return fmt.Errorf("%w: message IDs must be present and unique", ErrInvalid)
+		}
+		seen[m.ID] = true
+		if m.Role != "user" && m.Role != "assistant" && m.Role != "system" {
+			return fmt.Errorf("%w: message %s has unknown role %q", ErrInvalid, m.ID, m.Role)
+		}
+	}
+	for _, m := range t.Messages {
+		if m.ParentID != "" && !seen[m.ParentID] {
+			return fmt.Errorf("%w: message %s follows %s, which is not in the export", ErrInvalid, m.ID, m.ParentID)
+		}
+	}
+	if t.HeadMessageID != "" && !seen[t.HeadMessageID] {
+		return fmt.Errorf("%w: head message %s is not in the export", ErrInvalid, t.HeadMessageID)
+	}
+	return nil
+}
+
+func (s *Store) importThread(tx *sql.Tx, t *Thread, workspaceID, parentThreadID, rootMessageID string, ids map[string]string, written *[]string) (string, error) {
+	msgs := append([]Message(nil), t.Messages...)
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	for _, m := range msgs {
+		ids[m.ID] = uuid.New().String()
+	}
+	head := t.HeadMessageID
+	if head == "" && len(msgs) > 0 {
+		head = msgs[len(msgs)-1].ID
+	}
+
+	threadID := uuid.New().String()
+	now := time.Now().UTC()
+	createdAt := t.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO chat_threads (id, title, workspace_id, parent_thread_id, root_message_id, head_message_id, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		threadID, t.Title, workspaceID, parentThreadID, rootMessageID, ids[head], createdAt, now,
+	); err != nil {
+		return "", err
+	}
+
+	for _, m := range msgs {
+		var widgets, toolCalls, imageURL *string
+		if w := rawJSON(string(m.Widgets)); w != nil {
+			v := string(w)
+			widgets = &v
+		}
+		if tc := rawJSON(string(m.ToolCalls)); tc != nil {
+			v := string(tc)
+			toolCalls = &v
+		}
+		if m.ImageURL != "" {
+			imageURL = &m.ImageURL
+		}
+		createdAt := m.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		// The parent is always named, so the append trigger leaves the head
+		// set above alone.
+		if _, err := tx.Exec(
+			`INSERT INTO chat_messages (id, thread_id, parent_id, role, content, agent_role_slug, widget_data, tool_calls_json, image_url, stopped, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ids[m.ID], threadID, ids[m.ParentID], m.Role, m.Content, m.AgentRoleSlug, widgets, toolCalls, imageURL, m.Stopped, createdAt,
+		); err != nil {
+			return "", err
+		}
+		for _, a := range m.Attachments {
+			if err := s.importAttachment(tx, ids[m.ID], a, written); err != nil {
+				return "", err
+			}
+		}
+	}
+	return threadID, nil
+}
+
+func (s *Store) importAttachment(tx *sql.Tx, messageID string, a Attachment, written *[]string) error {
+	if len(a.Data) == 0 {
+		return nil // nothing to restore; the export noted the file was missing
+	}
+	if err := os.MkdirAll(s.attachmentsDir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Base(a.Name)
+	if name == "." || name == string(filepath.Separator) {
+		name = "attachment"
+	}
+	filename := uuid.New().String() + filepath.Ext(name)
+	path := filepath.Join(s.attachmentsDir, filename)
+	if err := os.WriteFile(path, a.Data, 0644); err != nil {
+		return err
+	}
+	*written = append(*written, path)
+	mimeType := a.MimeType
+	if !validMimeType(mimeType) {
+		mimeType = "application/octet-stream"
+	}
+	_, err := tx.Exec(
+		"INSERT INTO chat_attachments (id, message_id, filename, original_name, mime_type, size_bytes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		uuid.New().String(), messageID, filename, name, mimeType, len(a.Data), time.Now().UTC(),
+	)
+	return err
+}
@@ -0,0 +1,199 @@
+package chatexport
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+const testWorkspace = "00000000-0000-0000-0000-000000000001"
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := database.New(dir)
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db, filepath.Join(dir, "chat-attachments"))
+}
+
+func mustExec(t *testing.T, s *Store, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := s.db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// seedThread builds a thread whose second user message was edited, leaving
+// two branches, with a reply that used a tool, showed a widget and cost
+// money, an attachment, and a message thread opened from the first reply.
+func seedThread(t *testing.T, s *Store) string {
+	t.Helper()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+
+	mustExec(t, s, "INSERT INTO chat_threads (id, title, workspace_id, created_at, updated_at) VALUES ('t1', 'Quarterly <numbers>', ?, ?, ?)", testWorkspace, at(0), at(0))
+	// Appending without a parent lets the trigger chain the messages.
+	mustExec(t, s, "INSERT INTO chat_messages (id, thread_id, role, content, created_at) VALUES ('u1', 't1', 'user', 'How did Q1 go?', ?)", at(1))
+	mustExec(t, s, `INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, cost_usd, input_tokens, output_tokens, widget_data, tool_calls_json, created_at)
+		VALUES ('a1', 't1', 'assistant', 'Revenue was up. <script>alert(1)</script>', 'analyst', 0.25, 1000, 200, ?, ?, ?)`,
+		`[{"type":"chart","title":"Revenue","data":{"q1":120}}]`,
+		`[{"tool_name":"sales_report","endpoint":"/report","status":"success"}]`, at(2))
+	mustExec(t, s, "INSERT INTO chat_messages (id, thread_id, role, content, created_at) VALUES ('u2', 't1', 'user', 'And Q2?', ?)", at(3))
+	mustExec(t, s, "INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, cost_usd, created_at) VALUES ('a2', 't1', 'assistant', 'Flat.', 'analyst', 0.1, ?)", at(4))
+	// The edit: a sibling of u2 the thread now shows.
+	mustExec(t, s, "INSERT INTO chat_messages (id, thread_id, parent_id, role, content, created_at) VALUES ('u2b', 't1', 'a1', 'user', 'And Q3?', ?)", at(5))
+	mustExec(t, s, "UPDATE chat_threads SET head_message_id = 'u2b' WHERE id = 't1'")
+
+	if err := os.MkdirAll(s.attachmentsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.attachmentsDir, "f1.png"), []byte("png-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, s, "INSERT INTO chat_attachments (id, message_id, filename, original_name, mime_type, size_bytes, created_at) VALUES ('at1', 'u1', 'f1.png', 'chart.png', 'image/png', 9, ?)", at(1))
+
+	mustExec(t, s, "INSERT INTO chat_threads (id, title, workspace_id, parent_thread_id, root_message_id, created_at, updated_at) VALUES ('t2', 'Thread', ?, 't1', 'a1', ?, ?)", testWorkspace, at(6), at(6))
+	mustExec(t, s, "INSERT INTO chat_messages (id, thread_id, role, content, created_at) VALUES ('r1', 't2', 'user', 'Which region?', ?)", at(7))
+	return "t1"
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	doc, err := s.Export(seedThread(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Thread.TotalCostUSD != 0.35 || doc.Thread.InputTokens != 1000 {
+		t.Errorf("totals = %v / %d", doc.Thread.TotalCostUSD, doc.Thread.InputTokens)
+	}
+	if len(doc.Thread.Messages) != 5 || len(doc.Thread.MessageThreads) != 1 {
+		t.Fatalf("exported %d messages, %d message threads", len(doc.Thread.Messages), len(doc.Thread.MessageThreads))
+	}
+	if a := doc.Thread.Messages[0].Attachments; len(a) != 1 || string(a[0].Data) != "png-bytes" {
+		t.Fatalf("attachment = %+v", a)
+	}
+
+	// Through JSON, as a file moved between instances would go.
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newTestStore(t)
+	var in Document
+	if err := json.Unmarshal(raw, &in); err != nil {
+		t.Fatal(err)
+	}
+	newID, err := other.Import(&in, testWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := other.Export(newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var branch []string
+	for _, m := range back.Thread.Branch() {
+		branch = append(branch, m.Content)
+	}
+	if got := strings.Join(branch, "|"); got != "How did Q1 go?|Revenue was up. <script>alert(1)</script>|And Q3?" {
+		t.Errorf("imported branch = %s", got)
+	}
+	if len(back.Thread.Messages) != 5 {
+		t.Errorf("imported %d messages, want both branches", len(back.Thread.Messages))
+	}
+	if back.Thread.TotalCostUSD != 0 {
+		t.Errorf("imported cost = %v, want none carried over", back.Thread.TotalCostUSD)
+	}
+	reply := back.Thread.Branch()[1]
+	if !strings.Contains(string(reply.ToolCalls), "sales_report") || !strings.Contains(string(reply.Widgets), "Revenue") {
+		t.Errorf("tool calls / widgets lost: %s %s", reply.ToolCalls, reply.Widgets)
+	}
+	if a := back.Thread.Messages[0].Attachments; len(a) != 1 || a[0].Name != "chart.png" || string(a[0].Data) != "png-bytes" {
+		t.Errorf("imported attachment = %+v", a)
+	}
+	if mt := back.Thread.MessageThreads; len(mt) != 1 || mt[0].RootMessageID != reply.ID || mt[0].Messages[0].Content != "Which region?" {
+		t.Errorf("message thread = %+v", mt)
+	}
+
+	in.Format = "something-else"
+	if _, err := other.Import(&in, testWorkspace); !errors.Is(err, ErrInvalid) {
+		t.Errorf("foreign document: %v", err)
+	}
+}
+
+func TestRenderShowsActiveBranch(t *testing.T) {
+	s := newTestStore(t)
+	doc, err := s.Export(seedThread(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := Markdown(doc, RenderOptions{Costs: true})
+	for _, want := range []string{"# Quarterly <numbers>", "**analyst** ·", "version 2 of 2", "`sales_report` /report (success)", "Attachment: chart.png", "> Which region?", "$0.2500"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "And Q2?") {
+		t.Error("markdown shows the abandoned branch")
+	}
+
+	page, err := HTML(doc, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page, "<script>alert(1)</script>") || !strings.Contains(page, "&lt;script&gt;") {
+		t.Error("message content is not escaped")
+	}
+	if !strings.Contains(page, `src="data:image/png;base64,`) {
+		t.Error("image attachment is not inlined")
+	}
+	if strings.Contains(page, "$0.2500") {
+		t.Error("costs shown without being asked for")
+	}
+}
+
+func TestShareLifecycle(t *testing.T) {
+	s := newTestStore(t)
+	threadID := seedThread(t, s)
+
+	token, share, err := s.CreateShare(threadID, "u", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ResolveShare(token); err != nil || got != threadID {
+		t.Fatalf("resolve = %q, %v", got, err)
+	}
+	if shares, _ := s.ListShares(threadID); len(shares) != 1 || shares[0].ViewCount != 1 {
+		t.Errorf("shares = %+v", shares)
+	}
+	if _, err := s.ResolveShare("not-a-token"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown token: %v", err)
+	}
+
+	mustExec(t, s, "UPDATE chat_thread_shares SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), share.ID)
+	if _, err := s.ResolveShare(token); !errors.Is(err, ErrShareExpired) {
+		t.Errorf("expired token: %v", err)
+	}
+
+	if err := s.RevokeShare(threadID, share.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeShare(threadID, share.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoking twice: %v", err)
+	}
+	if _, err := s.ResolveShare(token); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked token: %v", err)
+	}
+}
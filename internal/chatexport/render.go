@@ -0,0 +1,335 @@
+package chatexport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+
+	llm "github.com/openpaw/openpaw/internal/llm"
+)
+
+// RenderOptions controls what the readable formats show.
+type RenderOptions struct {
+	// Costs adds what each reply cost and the thread's total. Share links leave
+	// them out: a teammate looking at a result has no use for the bill.
+	Costs bool
+}
+
+var mimeTypeRegex = regexp.MustCompile(`^[a-z]+/[a-z0-9.+-]+$`)
+
+func validMimeType(s string) bool { return mimeTypeRegex.MatchString(s) }
+
+// entry is a message as the readable formats show it.
+type entry struct {
+	Message
+	Label     string
+	Version   int
+	Versions  int
+	Tools     []llm.ToolCallRecord
+	Widgets   []widget
+	Files     []file
+	Replies   []entry
+	Costs     bool
+	ReplyWord string
+}
+
+type widget struct {
+	Title string
+	Type  string
+	Data  string
+}
+
+type file struct {
+	Name    string
+	Size    string
+	Image   bool
+	DataURI template.URL
+}
+
+// entries lays out a thread's active branch, attaching each message's focused
+// thread when there is one.
+func entries(t *Thread, children []Thread, opts RenderOptions) []entry {
+	versions := map[string][]string{}
+	for _, m := range t.Messages {
+		versions[m.ParentID] = append(versions[m.ParentID], m.ID)
+	}
+	replies := map[string]*Thread{}
+	for i := range children {
+		replies[children[i].RootMessageID] = &children[i]
+	}
+
+	var out []entry
+	for _, m := range t.Branch() {
+		e := entry{Message: m, Label: label(m), Costs: opts.Costs}
+		if siblings := versions[m.ParentID]; len(siblings) > 1 {
+			e.Versions = len(siblings)
+			for i, id := range siblings {
+				if id == m.ID {
+					e.Version = i + 1
+				}
+			}
+		}
+		if len(m.ToolCalls) > 0 {
+			json.Unmarshal(m.ToolCalls, &e.Tools)
+		}
+		if len(m.Widgets) > 0 {
+			var payloads []llm.WidgetPayload
+			json.Unmarshal(m.Widgets, &payloads)
+			for _, p := range payloads {
+				var data bytes.Buffer
+				if json.Indent(&data, p.Data, "", "  ") != nil {
+					data.Reset()
+					data.Write(p.Data)
+				}
+				e.Widgets = append(e.Widgets, widget{Title: p.Title, Type: p.Type, Data: data.String()})
+			}
+		}
+		for _, a := range m.Attachments {
+			f := file{Name: a.Name, Size: humanSize(a.SizeBytes)}
+			if len(a.Data) > 0 {
+				mimeType := a.MimeType
+				if !validMimeType(mimeType) {
+					mimeType = "application/octet-stream"
+				}
+				f.Image = strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml"
+				// Built here from a checked MIME type and base64, so it is safe
+				// to hand the template as a URL.
+				f.DataURI = template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(a.Data))
+			}
+			e.Files = append(e.Files, f)
+		}
+		if child, ok := replies[m.ID]; ok {
+			e.Replies = entries(child, nil, opts)
+			e.ReplyWord = "replies"
+			if len(e.Replies) == 1 {
+				e.ReplyWord = "reply"
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func label(m Message) string {
+	switch m.Role {
+	case "user":
+		return "User"
+	case "system":
+		return "Summary of earlier messages"
+	}
+	if m.AgentName != "" {
+		return m.AgentName
+	}
+	if m.AgentRoleSlug != "" {
+		return m.AgentRoleSlug
+	}
+	return "Assistant"
+}
+
+func humanSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func formatCost(usd float64) string {
+	return fmt.Sprintf("$%.4f", usd)
+}
+
+// summaryLine describes the thread under its title.
+func summaryLine(doc *Document, branch []entry, opts RenderOptions) string {
+	parts := []string{"Exported from OpenPaw on " + formatTime(doc.ExportedAt), fmt.Sprintf("%d messages", len(branch))}
+	if opts.Costs {
+		t := doc.Thread
+		parts = append(parts, formatCost(t.TotalCostUSD), fmt.Sprintf("%d input / %d output tokens", t.InputTokens, t.OutputTokens))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Markdown renders the active branch as a Markdown document.
+func Markdown(doc *Document, opts RenderOptions) string {
+	branch := entries(&doc.Thread, doc.Thread.MessageThreads, opts)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n_%s_\n", doc.Thread.Title, summaryLine(doc, branch, opts))
+	for _, e := range branch {
+		b.WriteString("\n---\n\n")
+		writeMarkdownEntry(&b, e)
+		if len(e.Replies) > 0 {
+			var thread strings.Builder
+			fmt.Fprintf(&thread, "**Thread: %d %s**\n", len(e.Replies), e.ReplyWord)
+			for _, r := range e.Replies {
+				thread.WriteString("\n")
+				writeMarkdownEntry(&thread, r)
+			}
+			b.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimRight(thread.String(), "\n"), "\n") {
+				if line == "" {
+					b.WriteString(">\n")
+				} else {
+					b.WriteString("> " + line + "\n")
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+func writeMarkdownEntry(b *strings.Builder, e entry) {
+	fmt.Fprintf(b, "**%s** · %s", e.Label, formatTime(e.CreatedAt))
+	if e.Versions > 1 {
+		fmt.Fprintf(b, " · version %d of %d", e.Version, e.Versions)
+	}
+	if e.Stopped {
+		b.WriteString(" · stopped")
+	}
+	fmt.Fprintf(b, "\n\n%s\n", strings.TrimSpace(e.Content))
+	if e.ImageURL != "" {
+		fmt.Fprintf(b, "\n![image](%s)\n", e.ImageURL)
+	}
+	if len(e.Files) > 0 {
+		b.WriteString("\n")
+		for _, f := range e.Files {
+			fmt.Fprintf(b, "- Attachment: %s (%s)\n", f.Name, f.Size)
+		}
+	}
+	if len(e.Tools) > 0 {
+		b.WriteString("\nTools used:\n\n")
+		for _, tc := range e.Tools {
+			fmt.Fprintf(b, "- `%s`", tc.ToolName)
+			if detail := toolDetail(tc); detail != "" {
+				fmt.Fprintf(b, " %s", detail)
+			}
+			fmt.Fprintf(b, " (%s)\n", tc.Status)
+		}
+	}
+	for _, w := range e.Widgets {
+		fmt.Fprintf(b, "\nWidget: %s (%s)\n\n```json\n%s\n```\n", widgetTitle(w), w.Type, w.Data)
+	}
+	if e.Costs && (e.CostUSD > 0 || e.InputTokens > 0 || e.OutputTokens > 0) {
+		fmt.Fprintf(b, "\n_Cost: %s · %d input / %d output tokens_\n", formatCost(e.CostUSD), e.InputTokens, e.OutputTokens)
+	}
+}
+
+func toolDetail(tc llm.ToolCallRecord) string {
+	if tc.Endpoint != "" {
+		return tc.Endpoint
+	}
+	return tc.Detail
+}
+
+func widgetTitle(w widget) string {
+	if w.Title != "" {
+		return w.Title
+	}
+	return "untitled"
+}
+
+// HTML renders the active branch as a single page with its styles and
+// attachments inlined, so it can be saved, mailed or served as it is.
+func HTML(doc *Document, opts RenderOptions) (string, error) {
+	branch := entries(&doc.Thread, doc.Thread.MessageThreads, opts)
+	var b bytes.Buffer
+	err := pageTemplate.Execute(&b, map[string]interface{}{
+		"Title":   doc.Thread.Title,
+		"Summary": summaryLine(doc, branch, opts),
+		"Entries": branch,
+	})
+	return b.String(), err
+}
+
+// contentHTML escapes message text, keeping fenced code blocks apart from
+// prose. Everything else stays as written.
+func contentHTML(s string) template.HTML {
+	var b strings.Builder
+	inCode := false
+	var chunk []string
+	flush := func() {
+		text := strings.Trim(strings.Join(chunk, "\n"), "\n")
+		chunk = nil
+		if text == "" {
+			return
+		}
+		if inCode {
+			b.WriteString("<pre><code>" + template.HTMLEscapeString(text) + "</code></pre>")
+		} else {
+			b.WriteString(`<div class="text">` + template.HTMLEscapeString(text) + "</div>")
+		}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			inCode = !inCode
+			continue
+		}
+		chunk = append(chunk, line)
+	}
+	flush()
+	return template.HTML(b.String())
+}
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"content": contentHTML,
+	"time":    formatTime,
+	"cost":    formatCost,
+	"detail":  toolDetail,
+	"title":   widgetTitle,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font: 15px/1.55 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; color: #1f2328; background: #f6f8fa; margin: 0; }
+main { max-width: 820px; margin: 0 auto; padding: 32px 20px 64px; }
+h1 { font-size: 24px; margin: 0 0 4px; }
+.summary { color: #656d76; font-size: 13px; margin-bottom: 24px; }
+.message { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 14px 16px; margin: 12px 0; }
+.message.user { background: #eef4ff; }
+.message.system { background: #fff8e6; }
+.meta { font-size: 12px; color: #656d76; margin-bottom: 8px; }
+.meta strong { color: #1f2328; font-size: 13px; }
+.text { white-space: pre-wrap; word-wrap: break-word; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 6px; padding: 10px; overflow-x: auto; font-size: 13px; }
+img { max-width: 100%; border-radius: 6px; margin-top: 8px; }
+ul.tools, ul.files { font-size: 13px; padding-left: 18px; margin: 8px 0 0; }
+.status-error { color: #cf222e; }
+details { margin-top: 8px; font-size: 13px; }
+details.replies { border-left: 3px solid #d0d7de; padding-left: 12px; }
+.cost { font-size: 12px; color: #656d76; margin-top: 8px; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<div class="summary">{{.Summary}}</div>
+{{template "entries" .Entries}}
+</main>
+</body>
+</html>
+{{define "entries"}}{{range .}}
+<div class="message {{.Role}}">
+<div class="meta"><strong>{{.Label}}</strong> · {{time .CreatedAt}}{{if gt .Versions 1}} · version {{.Version}} of {{.Versions}}{{end}}{{if .Stopped}} · stopped{{end}}</div>
+{{content .Content}}
+{{if .ImageURL}}<img src="{{.ImageURL}}" alt="">{{end}}
+{{range .Files}}{{if .Image}}<img src="{{.DataURI}}" alt="{{.Name}}">{{end}}{{end}}
+{{if .Files}}<ul class="files">{{range .Files}}<li>{{if .DataURI}}<a download="{{.Name}}" href="{{.DataURI}}">{{.Name}}</a>{{else}}{{.Name}}{{end}} ({{.Size}})</li>{{end}}</ul>{{end}}
+{{if .Tools}}<ul class="tools">{{range .Tools}}<li><code>{{.ToolName}}</code> {{detail .}} <span class="status-{{.Status}}">({{.Status}})</span></li>{{end}}</ul>{{end}}
+{{range .Widgets}}<details><summary>Widget: {{title .}} ({{.Type}})</summary><pre><code>{{.Data}}</code></pre></details>{{end}}
+{{if and .Costs (or (gt .CostUSD 0.0) (gt .InputTokens 0) (gt .OutputTokens 0))}}<div class="cost">Cost: {{cost .CostUSD}} · {{.InputTokens}} input / {{.OutputTokens}} output tokens</div>{{end}}
+{{if .Replies}}<details class="replies"><summary>Thread: {{len .Replies}} {{.ReplyWord}}</summary>{{template "entries" .Replies}}</details>{{end}}
+</div>{{end}}{{end}}`))
@@ -0,0 +1,111 @@
+package chatexport
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/auth"
+)
+
+// ErrShareExpired is returned when resolving a share link past its expiry.
+var ErrShareExpired = errors.New("share link has expired")
+
+// Share is a read-only link to a thread. The token itself is never stored, so
+// it is only known when the share is created.
+type Share struct {
+	ID           string     `json:"id"`
+	ThreadID     string     `json:"thread_id"`
+	CreatedBy    string     `json:"created_by"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	ViewCount    int        `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreateShare makes a link to a thread that works for ttl and returns its
+// token.
+func (s *Store) CreateShare(threadID, userID string, ttl time.Duration) (string, *Share, error) {
+	token, err := auth.GenerateInviteToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now().UTC()
+	share := &Share{
+		ID:        uuid.New().String(),
+		ThreadID:  threadID,
+		CreatedBy: userID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if _, err := s.db.Exec(
+		"INSERT INTO chat_thread_shares (id, thread_id, token_hash, created_by, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		share.ID, threadID, auth.HashAPIToken(token), userID, share.ExpiresAt, now,
+	); err != nil {
+		return "", nil, err
+	}
+	return token, share, nil
+}
+
+// ListShares returns a thread's share links, newest first, expired ones
+// included so their owner can see they lapsed.
+func (s *Store) ListShares(threadID string) ([]Share, error) {
+	rows, err := s.db.Query(
+		`SELECT id, thread_id, created_by, expires_at, view_count, last_viewed_at, created_at
+		 FROM chat_thread_shares WHERE thread_id = ? ORDER BY created_at DESC`,
+		threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := []Share{}
+	for rows.Next() {
+		var sh Share
+		var lastViewed sql.NullTime
+		if err := rows.Scan(&sh.ID, &sh.ThreadID, &sh.CreatedBy, &sh.ExpiresAt, &sh.ViewCount, &lastViewed, &sh.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastViewed.Valid {
+			sh.LastViewedAt = &lastViewed.Time
+		}
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+// RevokeShare deletes a thread's share link, or returns sql.ErrNoRows.
+func (s *Store) RevokeShare(threadID, shareID string) error {
+	res, err := s.db.Exec("DELETE FROM chat_thread_shares WHERE id = ? AND thread_id = ?", shareID, threadID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResolveShare returns the thread a share token opens and counts the view. It
+// returns sql.ErrNoRows for an unknown or revoked token and ErrShareExpired
+// for one past its expiry.
+func (s *Store) ResolveShare(token string) (string, error) {
+	var id, threadID string
+	var expiresAt time.Time
+	if err := s.db.QueryRow(
+		"SELECT id, thread_id, expires_at FROM chat_thread_shares WHERE token_hash = ?",
+		auth.HashAPIToken(strings.TrimSpace(token)),
+	).Scan(&id, &threadID, &expiresAt); err != nil {
+		return "", err
+	}
+	if time.Now().After(expiresAt) {
+		return "", ErrShareExpired
+	}
+	s.db.Exec(
+		"UPDATE chat_thread_shares SET view_count = view_count + 1, last_viewed_at = ? WHERE id = ?",
+		time.Now().UTC(), id,
+	)
+	return threadID, nil
+}
@@ -0,0 +1,351 @@
+// Package chatsearch finds past conversations: message content, thread titles
+// and work-order text, scoped to one workspace. It uses the FTS5 indexes set
+// up by the database package and falls back to substring matching on builds
+// without FTS5.
+package chatsearch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+// What a hit matched.
+const (
+	KindMessage   = "message"
+	KindThread    = "thread"
+	KindWorkOrder = "work_order"
+)
+
+// Snippets mark matched words in bold, which reads the same to an agent as it
+// renders in the chat UI.
+const (
+	markOpen     = "**"
+	markClose    = "**"
+	snippetWords = 24
+	snippetChars = 240
+	maxResults   = 100
+)
+
+// Options narrows a search. Agent limits message hits to that agent's
+// replies, and thread and work-order hits to threads it took part in.
+type Options struct {
+	WorkspaceID string
+	Query       string
+	Agent       string
+	Kinds       []string
+	After       time.Time
+	Before      time.Time
+	Limit       int
+	Offset      int
+}
+
+// Hit is one match, with enough to open the thread at the right place.
+type Hit struct {
+	Kind        string    `json:"kind"`
+	ID          string    `json:"id"`
+	ThreadID    string    `json:"thread_id"`
+	ThreadTitle string    `json:"thread_title"`
+	Role        string    `json:"role,omitempty"`
+	AgentSlug   string    `json:"agent_role_slug,omitempty"`
+	Snippet     string    `json:"snippet"`
+	CreatedAt   time.Time `json:"created_at"`
+	rank        float64
+}
+
+type Results struct {
+	Query  string `json:"query"`
+	Hits   []Hit  `json:"hits"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	// FullText is false when the build lacks FTS5 and matching is by
+	// substring, without stemming or relevance ranking.
+	FullText bool `json:"full_text"`
+}
+
+// ErrEmptyQuery is returned for a query with no searchable words.
+var ErrEmptyQuery = errors.New("search query has no words to match")
+
+// Search runs a query. Hits from all three sources are merged by relevance
+// (or, without FTS5, newest first) and paged together.
+func Search(db *database.DB, opts Options) (Results, error) {
+	terms := queryTerms(opts.Query)
+	if len(terms) == 0 {
+		return Results{}, ErrEmptyQuery
+	}
+	if opts.Limit <= 0 || opts.Limit > maxResults {
+		opts.Limit = 20
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	results := Results{Query: opts.Query, Hits: []Hit{}, Limit: opts.Limit, Offset: opts.Offset, FullText: db.HasFTS()}
+
+	// Each source returns enough for the requested page on its own; the merge
+	// below cuts the page out of the combined list.
+	window := opts.Offset + opts.Limit
+	var hits []Hit
+	for _, kind := range []string{KindMessage, KindThread, KindWorkOrder} {
+		if !wantKind(opts.Kinds, kind) {
+			continue
+		}
+		found, err := searchSource(db, kind, terms, opts, window)
+		if err != nil {
+			return results, err
+		}
+		hits = append(hits, found...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank < hits[j].rank
+		}
+		return hits[i].CreatedAt.After(hits[j].CreatedAt)
+	})
+	if opts.Offset < len(hits) {
+		hits = hits[opts.Offset:]
+		if len(hits) > opts.Limit {
+			hits = hits[:opts.Limit]
+		}
+		results.Hits = hits
+	}
+	return results, nil
+}
+
+func wantKind(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// source describes how to search one kind of record. text is the SQL for the
+// searchable text, used by the substring fallback and its snippets.
+type source struct {
+	fts, from, id, thread, text, created, role, agent string
+}
+
+// joinFrom joins a source's tables onto its FTS index.
+func (src source) joinFrom() string {
+	table, rest, _ := strings.Cut(src.from, " JOIN ")
+	return fmt.Sprintf("%s f JOIN %s ON s.rowid = f.rowid JOIN %s", src.fts, table, rest)
+}
+
+var sources = map[string]source{
+	KindMessage: {
+		fts:     "chat_messages_fts",
+		from:    "chat_messages s JOIN chat_threads t ON t.id = s.thread_id",
+		id:      "s.id",
+		thread:  "s.thread_id",
+		text:    "s.content",
+		created: "s.created_at",
+		role:    "s.role",
+		agent:   "s.agent_role_slug",
+	},
+	KindThread: {
+		fts:     "chat_threads_fts",
+		from:    "chat_threads s JOIN chat_threads t ON t.id = s.id",
+		id:      "s.id",
+		thread:  "s.id",
+		text:    "s.title",
+		created: "s.created_at",
+		role:    "''",
+		agent:   "''",
+	},
+	KindWorkOrder: {
+		fts:     "work_orders_fts",
+		from:    "work_orders s JOIN chat_threads t ON t.id = s.thread_id",
+		id:      "s.id",
+		thread:  "s.thread_id",
+		text:    "s.title || ' — ' || s.description || CASE WHEN s.result != '' THEN ' — ' || s.result ELSE '' END",
+		created: "s.created_at",
+		role:    "''",
+		agent:   "''",
+	},
+}
+
+func searchSource(db *database.DB, kind string, terms []string, opts Options, limit int) ([]Hit, error) {
+	src := sources[kind]
+	where := []string{"t.workspace_id = ?"}
+	args := []interface{}{opts.WorkspaceID}
+	if opts.Agent != "" {
+		if kind == KindMessage {
+			where = append(where, "s.agent_role_slug = ?")
+		} else {
+			where = append(where, "EXISTS (SELECT 1 FROM chat_messages am WHERE am.thread_id = t.id AND am.agent_role_slug = ?)")
+		}
+		args = append(args, opts.Agent)
+	}
+	if !opts.After.IsZero() {
+		where = append(where, src.created+" >= ?")
+		args = append(args, opts.After.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !opts.Before.IsZero() {
+		where = append(where, src.created+" < ?")
+		args = append(args, opts.Before.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	var query string
+	fullText := db.HasFTS()
+	if fullText {
+		// snippet() picks the best-matching column; work orders have three.
+		query = fmt.Sprintf(`
+			SELECT %s, %s, t.title, %s, %s, s.created_at,
+			       snippet(%s, -1, '%s', '%s', '…', %d), bm25(%s)
+			  FROM %s
+			 WHERE %s MATCH ? AND %s
+			 ORDER BY bm25(%s) LIMIT ?`,
+			src.id, src.thread, src.role, src.agent,
+			src.fts, markOpen, markClose, snippetWords, src.fts,
+			src.joinFrom(),
+			src.fts, strings.Join(where, " AND "),
+			src.fts)
+		args = append([]interface{}{ftsQuery(terms)}, args...)
+	} else {
+		for _, term := range terms {
+			where = append(where, "("+src.text+") LIKE ? ESCAPE '\\'")
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		query = fmt.Sprintf(`
+			SELECT %s, %s, t.title, %s, %s, s.created_at, %s, 0
+			  FROM %s
+			 WHERE %s
+			 ORDER BY s.created_at DESC LIMIT ?`,
+			src.id, src.thread, src.role, src.agent, src.text,
+			src.from, strings.Join(where, " AND "))
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search %s: %w", kind, err)
+	}
+	defer rows.Close()
+	var hits []Hit
+	for rows.Next() {
+		h := Hit{Kind: kind}
+		if err := rows.Scan(&h.ID, &h.ThreadID, &h.ThreadTitle, &h.Role, &h.AgentSlug, &h.CreatedAt, &h.Snippet, &h.rank); err != nil {
+			return nil, fmt.Errorf("search %s: %w", kind, err)
+		}
+		if !fullText {
+			h.Snippet = highlight(h.Snippet, terms)
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// queryTerms splits free text into lower-case words. Punctuation never
+// reaches FTS5, so a query can't be a syntax error.
+func queryTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ftsQuery matches every word, the last one as a prefix so a half-typed word
+// still finds something.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
+
+// highlight builds a snippet for the substring fallback: a window of text
+// around the first match, with every match marked.
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lower-casing changed the length (rare scripts); skip marking rather
+		// than mark the wrong characters.
+		lower = runes
+	}
+	first := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, []rune(term), 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	start, end := 0, len(runes)
+	if first > snippetChars/3 {
+		start = first - snippetChars/3
+	}
+	if end-start > snippetChars {
+		end = start + snippetChars
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			tr := []rune(term)
+			if i+len(tr) <= end && indexRunes(lower[i:i+len(tr)], tr, 0) == 0 && len(tr) > matched {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(markOpen + string(runes[i:i+matched]) + markClose)
+			i += matched
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func indexRunes(s, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// DateRange turns inclusive YYYY-MM-DD bounds (either may be empty) into the
+// half-open range Options takes.
+func DateRange(from, to string) (after, before time.Time, err error) {
+	if from != "" {
+		if after, err = time.Parse("2006-01-02", from); err != nil {
+			return after, before, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+	}
+	if to != "" {
+		if before, err = time.Parse("2006-01-02", to); err != nil {
+			return after, before, fmt.Errorf("to must be a YYYY-MM-DD date")
+		}
+		before = before.AddDate(0, 0, 1)
+	}
+	return after, before, nil
+}
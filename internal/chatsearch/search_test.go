@@ -0,0 +1,105 @@
+package chatsearch
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+func seed(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	old := time.Now().UTC().AddDate(0, -2, 0)
+	exec("INSERT INTO chat_threads (id, title, workspace_id, created_at) VALUES ('t1', 'Database design', 'ws1', ?)", old)
+	exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('t2', 'Weekend plans', 'ws1')")
+	exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('t3', 'Postgres elsewhere', 'ws2')")
+	exec("INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, created_at) VALUES ('m1', 't1', 'user', 'Should we use Postgres or SQLite?', '', ?)", old)
+	exec("INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, created_at) VALUES ('m2', 't1', 'assistant', 'We picked the Postgres schema with one table per tenant.', 'architect', ?)", old)
+	exec("INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug) VALUES ('m3', 't2', 'assistant', 'Hiking on Saturday, then a Postgres meetup.', 'planner')")
+	exec("INSERT INTO chat_messages (id, thread_id, role, content) VALUES ('m4', 't3', 'user', 'Postgres schema for another workspace')")
+	exec("INSERT INTO work_orders (id, title, description, thread_id, created_at) VALUES ('w1', 'Migrate schema', 'Write the Postgres migration scripts', 't1', ?)", old)
+	// Edits are reindexed.
+	exec("UPDATE chat_messages SET content = 'Hiking on Saturday, then a Rust meetup.' WHERE id = 'm3'")
+	return db
+}
+
+func ids(results Results) string {
+	var out []string
+	for _, h := range results.Hits {
+		out = append(out, h.ID)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestSearchScopesAndFilters(t *testing.T) {
+	db := seed(t)
+
+	all, err := Search(db, Options{WorkspaceID: "ws1", Query: "postgres"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(all); len(all.Hits) != 3 || strings.Contains(got, "m4") || strings.Contains(got, "m3") {
+		t.Fatalf("hits = %s, want m1, m2 and w1 only", got)
+	}
+	for _, h := range all.Hits {
+		if !strings.Contains(h.Snippet, "**") || h.ThreadTitle != "Database design" {
+			t.Errorf("hit %s: snippet %q, thread %q", h.ID, h.Snippet, h.ThreadTitle)
+		}
+	}
+
+	cases := []struct {
+		opts Options
+		want string
+	}{
+		{Options{Query: "postgres schema", Kinds: []string{KindMessage}}, "m2"},
+		{Options{Query: "postgres", Agent: "architect", Kinds: []string{KindMessage}}, "m2"},
+		{Options{Query: "design"}, "t1"},
+		{Options{Query: "migration", Kinds: []string{KindWorkOrder}}, "w1"},
+		{Options{Query: "rust"}, "m3"},
+		{Options{Query: "postgres", After: time.Now().AddDate(0, 0, -7)}, ""},
+		{Options{Query: "hiking", Before: time.Now().AddDate(0, 0, -7)}, ""},
+	}
+	for _, c := range cases {
+		c.opts.WorkspaceID = "ws1"
+		results, err := Search(db, c.opts)
+		if err != nil {
+			t.Errorf("%+v: %v", c.opts, err)
+			continue
+		}
+		if got := ids(results); got != c.want {
+			t.Errorf("%+v = %q, want %q", c.opts, got, c.want)
+		}
+	}
+
+	if _, err := Search(db, Options{WorkspaceID: "ws1", Query: `"*" -- ()`}); err != ErrEmptyQuery {
+		t.Errorf("punctuation-only query: %v", err)
+	}
+	page, _ := Search(db, Options{WorkspaceID: "ws1", Query: "postgres", Limit: 2, Offset: 2})
+	if len(page.Hits) != 1 {
+		t.Errorf("second page = %s", ids(page))
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := highlight("We picked the Postgres schema.", []string{"postgres", "schema"})
+	if got != "We picked the **Postgres** **schema**." {
+		t.Errorf("highlight = %q", got)
+	}
+	long := strings.Repeat("filler ", 100) + "needle " + strings.Repeat("filler ", 100)
+	if got := highlight(long, []string{"needle"}); !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "**needle**") {
+		t.Errorf("long highlight = %q", got)
+	}
+}
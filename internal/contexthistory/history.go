@@ -0,0 +1,324 @@
+// Package contexthistory keeps the version history of context library
+// documents. Every save through the editor or the agent tools appends the
+// document's full title and text, who saved it and from which chat thread, so
+// a bad rewrite can be compared against earlier versions and rolled back.
+package contexthistory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/textdiff"
+)
+
+// Revision kinds. A baseline is the text a document had before its first
+// recorded save; it has no known author.
+const (
+	KindBaseline = "baseline"
+	KindCreated  = "created"
+	KindUpdated  = "updated"
+	KindRestored = "restored"
+)
+
+const (
+	maxRevisionsPerPage = 200
+	diffContextLines    = 3
+)
+
+// ErrNotText is returned when restoring into a document that has no text,
+// such as an image nothing could be extracted from.
+var ErrNotText = errors.New("document has no text to restore into")
+
+// Author is who saved a revision: a user ID or "agent:<slug>", and for agents
+// the chat thread they were working in.
+type Author struct {
+	Actor    string `json:"actor"`
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// Agent attributes a save to an agent working in a thread.
+func Agent(slug, threadID string) Author {
+	return Author{Actor: "agent:" + slug, ThreadID: threadID}
+}
+
+// Revision is one saved version of a document. Lists leave Content out; Get
+// fills it in.
+type Revision struct {
+	ID        string `json:"id"`
+	FileID    string `json:"file_id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	SizeBytes int    `json:"size_bytes"`
+	Author
+	RestoredFrom string    `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Content      string    `json:"content,omitempty"`
+}
+
+// Snapshot is a document's title and text at one moment.
+type Snapshot struct {
+	Name    string
+	Content string
+}
+
+// Diff compares two revisions of a document. From is nil when To is the
+// document's first revision, which then diffs against an empty text.
+type Diff struct {
+	From    *Revision `json:"from,omitempty"`
+	To      Revision  `json:"to"`
+	Added   int       `json:"added"`
+	Removed int       `json:"removed"`
+	Unified string    `json:"unified"`
+}
+
+type Store struct {
+	db  *database.DB
+	dir string
+}
+
+func New(db *database.DB, dataDir string) *Store {
+	return &Store{db: db, dir: filepath.Join(dataDir, "context")}
+}
+
+// Current reads a document's title and text as they are now. ok is false for
+// a file with no readable text.
+func (s *Store) Current(workspaceID, fileID string) (snap Snapshot, ok bool, err error) {
+	var filename, textFilename, mimeType string
+	err = s.db.QueryRow(
+		`SELECT name, filename, text_filename, mime_type FROM context_files WHERE id = ? AND workspace_id = ?`,
+		fileID, workspaceID,
+	).Scan(&snap.Name, &filename, &textFilename, &mimeType)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	path, ok := s.textPath(filename, textFilename, mimeType)
+	if !ok {
+		return snap, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return snap, false, err
+	}
+	snap.Content = string(data)
+	return snap, true, nil
+}
+
+// Record appends next as a document's newest revision. prev is what the
+// document held before the save, nil for a new document; when the document
+// has no history yet, prev is kept first as its baseline. Nothing is recorded
+// when next matches the newest revision, so a save that changed nothing
+// returns nil.
+func (s *Store) Record(workspaceID, fileID string, prev *Snapshot, next Snapshot, by Author) (*Revision, error) {
+	latest, err := s.latest(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Name == next.Name && latest.Content == next.Content {
+		return nil, nil
+	}
+	kind := KindUpdated
+	switch {
+	case prev == nil:
+		kind = KindCreated
+	case latest == nil:
+		if *prev == next {
+			return nil, nil
+		}
+		if _, err := s.insert(workspaceID, fileID, KindBaseline, *prev, Author{}, ""); err != nil {
+			return nil, err
+		}
+	}
+	return s.insert(workspaceID, fileID, kind, next, by, "")
+}
+
+func (s *Store) insert(workspaceID, fileID, kind string, snap Snapshot, by Author, restoredFrom string) (*Revision, error) {
+	rev := &Revision{
+		ID:           uuid.New().String(),
+		FileID:       fileID,
+		Kind:         kind,
+		Name:         snap.Name,
+		SizeBytes:    len(snap.Content),
+		Author:       by,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    time.Now().UTC(),
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO context_file_revisions
+		    (id, file_id, workspace_id, kind, name, content, actor, thread_id, restored_from, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.ID, fileID, workspaceID, kind, snap.Name, snap.Content, by.Actor, by.ThreadID, restoredFrom, rev.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("record revision: %w", err)
+	}
+	return rev, nil
+}
+
+const revisionColumns = `id, file_id, kind, name, content, actor, thread_id, restored_from, created_at`
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (Revision, error) {
+	var v Revision
+	err := row.Scan(&v.ID, &v.FileID, &v.Kind, &v.Name, &v.Content, &v.Actor, &v.ThreadID, &v.RestoredFrom, &v.CreatedAt)
+	v.SizeBytes = len(v.Content)
+	return v, err
+}
+
+func (s *Store) latest(fileID string) (*Revision, error) {
+	v, err := scanRevision(s.db.QueryRow(
+		`SELECT `+revisionColumns+` FROM context_file_revisions WHERE file_id = ? ORDER BY rowid DESC LIMIT 1`, fileID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List returns a document's revisions, newest first, without their text.
+func (s *Store) List(workspaceID, fileID string, limit, offset int) ([]Revision, error) {
+	if limit <= 0 || limit > maxRevisionsPerPage {
+		limit = maxRevisionsPerPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	rows, err := s.db.Query(`
+		SELECT `+revisionColumns+` FROM context_file_revisions
+		 WHERE workspace_id = ? AND file_id = ?
+		 ORDER BY rowid DESC
+		 LIMIT ? OFFSET ?`, workspaceID, fileID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []Revision{}
+	for rows.Next() {
+		v, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		v.Content = ""
+		revisions = append(revisions, v)
+	}
+	return revisions, rows.Err()
+}
+
+// Get returns one revision with its text.
+func (s *Store) Get(workspaceID, id string) (Revision, error) {
+	return scanRevision(s.db.QueryRow(
+		`SELECT `+revisionColumns+` FROM context_file_revisions WHERE workspace_id = ? AND id = ?`, workspaceID, id))
+}
+
+// Diff compares revision toID with revision fromID of the same document, or
+// with fromID empty, with the revision before it.
+func (s *Store) Diff(workspaceID, toID, fromID string) (Diff, error) {
+	to, err := s.Get(workspaceID, toID)
+	if err != nil {
+		return Diff{}, err
+	}
+	var from Revision
+	hasFrom := true
+	if fromID != "" {
+		from, err = s.Get(workspaceID, fromID)
+		if err == nil && from.FileID != to.FileID {
+			return Diff{}, errors.New("invalid revision: both revisions must belong to the same document")
+		}
+	} else {
+		from, err = scanRevision(s.db.QueryRow(`
+			SELECT `+revisionColumns+` FROM context_file_revisions
+			 WHERE file_id = ? AND rowid < (SELECT rowid FROM context_file_revisions WHERE id = ?)
+			 ORDER BY rowid DESC LIMIT 1`, to.FileID, to.ID))
+		if errors.Is(err, sql.ErrNoRows) {
+			from, hasFrom, err = Revision{}, false, nil
+		}
+	}
+	if err != nil {
+		return Diff{}, err
+	}
+
+	diff := Diff{To: to}
+	fromName := "/dev/null"
+	if hasFrom {
+		diff.From = &from
+		fromName = revisionLabel(from)
+	}
+	edits := textdiff.Lines(from.Content, to.Content)
+	diff.Added, diff.Removed = textdiff.Count(edits)
+	diff.Unified = textdiff.Unified(edits, fromName, revisionLabel(to), diffContextLines)
+	if diff.From != nil && from.Name != to.Name {
+		diff.Unified = fmt.Sprintf("renamed %q to %q\n", from.Name, to.Name) + diff.Unified
+	}
+	return diff, nil
+}
+
+func revisionLabel(v Revision) string {
+	return fmt.Sprintf("%s\t%s", v.Name, v.CreatedAt.Format(time.RFC3339))
+}
+
+// Restore writes a revision's title and text back to its document and
+// records that as a new revision. The caller re-indexes the document.
+func (s *Store) Restore(workspaceID, fileID, revisionID string, by Author) (*Revision, error) {
+	rev, err := s.Get(workspaceID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.FileID != fileID {
+		return nil, sql.ErrNoRows
+	}
+	var filename, textFilename, mimeType string
+	err = s.db.QueryRow(
+		`SELECT filename, text_filename, mime_type FROM context_files WHERE id = ? AND workspace_id = ?`,
+		fileID, workspaceID,
+	).Scan(&filename, &textFilename, &mimeType)
+	if err != nil {
+		return nil, err
+	}
+	path, ok := s.textPath(filename, textFilename, mimeType)
+	if !ok {
+		return nil, ErrNotText
+	}
+	if err := os.WriteFile(path, []byte(rev.Content), 0644); err != nil {
+		return nil, fmt.Errorf("write document: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if textFilename != "" {
+		// The upload itself is unchanged; only its extracted text was restored.
+		_, err = s.db.Exec(`UPDATE context_files SET name = ?, updated_at = ? WHERE id = ? AND workspace_id = ?`,
+			rev.Name, now, fileID, workspaceID)
+	} else {
+		_, err = s.db.Exec(`UPDATE context_files SET name = ?, size_bytes = ?, updated_at = ? WHERE id = ? AND workspace_id = ?`,
+			rev.Name, len(rev.Content), now, fileID, workspaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.insert(workspaceID, fileID, KindRestored, Snapshot{Name: rev.Name, Content: rev.Content}, by, rev.ID)
+}
+
+// textPath returns where a document's text lives. Mirrors textPath in
+// handlers/context.go.
+func (s *Store) textPath(filename, textFilename, mimeType string) (string, bool) {
+	if textFilename != "" {
+		return filepath.Join(s.dir, filepath.Base(textFilename)), true
+	}
+	if isText(mimeType) {
+		return filepath.Join(s.dir, filepath.Base(filename)), true
+	}
+	return "", false
+}
+
+// isText mirrors isTextMime in handlers/context.go.
+func isText(mime string) bool {
+	return strings.HasPrefix(mime, "text/") ||
+		mime == "application/json" ||
+		mime == "application/xml" ||
+		mime == "application/javascript"
+}
@@ -0,0 +1,83 @@
+package contexthistory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+const ws = "00000000-0000-0000-0000-000000000001"
+
+func TestRecordDiffAndRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := database.New(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dir := filepath.Join(dataDir, "context")
+	os.MkdirAll(dir, 0755)
+	path := filepath.Join(dir, "plan.md")
+	original := "# Launch plan\n\nShip on Friday.\nTell support.\n"
+	os.WriteFile(path, []byte(original), 0644)
+	now := time.Now().UTC()
+	if _, err := db.Exec(
+		`INSERT INTO context_files (id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at)
+		 VALUES ('f1', 'Launch plan', 'plan.md', 'text/markdown', 0, 0, ?, ?, ?)`, ws, now, now); err != nil {
+		t.Fatal(err)
+	}
+	s := New(db, dataDir)
+
+	// The first edit of a document with no history keeps what it had as the baseline.
+	prev, ok, err := s.Current(ws, "f1")
+	if err != nil || !ok || prev.Content != original {
+		t.Fatalf("current = %+v %v %v", prev, ok, err)
+	}
+	rewrite := "# Launch plan\n\nShip on Monday.\n"
+	os.WriteFile(path, []byte(rewrite), 0644)
+	edit, err := s.Record(ws, "f1", &prev, Snapshot{Name: "Launch plan", Content: rewrite}, Agent("writer", "thread-1"))
+	if err != nil || edit == nil {
+		t.Fatalf("record: %v %v", edit, err)
+	}
+	if again, _ := s.Record(ws, "f1", &prev, Snapshot{Name: "Launch plan", Content: rewrite}, Agent("writer", "thread-1")); again != nil {
+		t.Errorf("unchanged save recorded %+v", again)
+	}
+
+	revisions, err := s.List(ws, "f1", 0, 0)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("list = %+v %v", revisions, err)
+	}
+	if revisions[0].Kind != KindUpdated || revisions[0].Actor != "agent:writer" || revisions[0].ThreadID != "thread-1" || revisions[1].Kind != KindBaseline {
+		t.Errorf("revisions = %+v", revisions)
+	}
+
+	diff, err := s.Diff(ws, edit.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.From == nil || diff.Added != 1 || diff.Removed != 2 ||
+		!strings.Contains(diff.Unified, "-Ship on Friday.\n-Tell support.\n+Ship on Monday.\n") {
+		t.Errorf("diff = %+v\n%s", diff, diff.Unified)
+	}
+	if first, err := s.Diff(ws, revisions[1].ID, ""); err != nil || first.From != nil || first.Added != 4 {
+		t.Errorf("baseline diff = %+v %v", first, err)
+	}
+
+	restored, err := s.Restore(ws, "f1", revisions[1].ID, Author{Actor: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Errorf("restored text = %q", data)
+	}
+	if restored.Kind != KindRestored || restored.RestoredFrom != revisions[1].ID {
+		t.Errorf("restore revision = %+v", restored)
+	}
+	if _, err := s.Restore(ws, "other", revisions[1].ID, Author{}); err == nil {
+		t.Error("restored a revision into another document")
+	}
+}
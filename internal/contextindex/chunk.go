@@ -0,0 +1,123 @@
+package contextindex
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// targetChunkChars is the passage size paragraphs are packed up to. About
+	// two hundred words: enough to carry a fact with its surrounding sentence,
+	// small enough that five passages cost less than one long document.
+	targetChunkChars = 1200
+	// maxChunkChars is where a single paragraph with no blank lines (a PDF
+	// page, a long list) is cut regardless.
+	maxChunkChars = 2000
+	// headingSeparator joins a heading path, e.g. "Leave › Parental leave".
+	headingSeparator = " › "
+)
+
+var headingLine = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// chunk is one passage of a document before it is stored.
+type chunk struct {
+	heading string
+	content string
+}
+
+// chunkText splits a document into passages. Markdown headings start a new
+// passage and are carried along as the passage's heading path, so a passage
+// deep in a manual still says which chapter and section it came from.
+// Headings inside fenced code blocks are left alone.
+func chunkText(text string) []chunk {
+	var (
+		chunks  []chunk
+		path    []string
+		levels  []int
+		section []string
+		fenced  bool
+	)
+	flush := func() {
+		heading := strings.Join(path, headingSeparator)
+		for _, content := range packParagraphs(strings.Join(section, "\n")) {
+			chunks = append(chunks, chunk{heading: heading, content: content})
+		}
+		section = section[:0]
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil && !fenced {
+			flush()
+			level := len(m[1])
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				path = path[:len(path)-1]
+			}
+			levels = append(levels, level)
+			path = append(path, m[2])
+			continue
+		}
+		section = append(section, line)
+	}
+	flush()
+	return chunks
+}
+
+// packParagraphs groups a section's paragraphs into passages of about
+// targetChunkChars, never splitting a paragraph that fits.
+func packParagraphs(text string) []string {
+	var out []string
+	var current strings.Builder
+	emit := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			out = append(out, s)
+		}
+		current.Reset()
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		for _, piece := range splitLong(para) {
+			if current.Len() > 0 && current.Len()+len(piece)+2 > targetChunkChars {
+				emit()
+			}
+			if current.Len() > 0 {
+				current.WriteString("\n\n")
+			}
+			current.WriteString(piece)
+		}
+	}
+	emit()
+	return out
+}
+
+// splitLong cuts a paragraph longer than maxChunkChars at sentence ends, or
+// failing that at whitespace, into pieces of about targetChunkChars.
+func splitLong(para string) []string {
+	var pieces []string
+	for len(para) > maxChunkChars {
+		cut := strings.LastIndex(para[:targetChunkChars], ". ")
+		if cut < targetChunkChars/2 {
+			cut = strings.LastIndexAny(para[:targetChunkChars], " \n\t")
+		}
+		if cut <= 0 {
+			cut = targetChunkChars
+			for cut < len(para) && !isRuneStart(para[cut]) {
+				cut++
+			}
+		} else {
+			cut++
+		}
+		pieces = append(pieces, strings.TrimSpace(para[:cut]))
+		para = strings.TrimSpace(para[cut:])
+	}
+	return append(pieces, para)
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
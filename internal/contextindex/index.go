@@ -0,0 +1,326 @@
+// Package contextindex splits context documents into passages and searches
+// them, so an agent can pull the few paragraphs that answer a question out of
+// a library of long manuals instead of reading whole files. Passages are
+// matched by keyword (FTS5, or LIKE on builds without it) and, when an
+// embedding backend is configured, by meaning; every hit carries the document
+// name and heading path it came from.
+package contextindex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+const (
+	// embedBatch is how many passages go to the backend per request.
+	embedBatch = 32
+	// indexInterval is how often the indexer sweeps even when nothing woke
+	// it, catching documents changed by paths that don't call IndexFile
+	// (restores, imports, direct edits on disk followed by a touch).
+	indexInterval = 10 * time.Minute
+	// maxIndexBytes bounds how much of one document is chunked.
+	maxIndexBytes = 8 << 20
+)
+
+// Index keeps the passage index for every workspace's context documents.
+type Index struct {
+	db       *database.DB
+	dir      string
+	embedder func() memory.Embedder
+	kick     chan struct{}
+	stop     chan struct{}
+	// mu serialises chunking, so an upload and a search refreshing the same
+	// file don't both rewrite its passages.
+	mu sync.Mutex
+}
+
+// New returns an index over the context files stored under dataDir.
+func New(db *database.DB, dataDir string) *Index {
+	return &Index{
+		db:   db,
+		dir:  filepath.Join(dataDir, "context"),
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+}
+
+// SetEmbedder installs the embedding backend resolver. A resolver returning
+// nil turns semantic matching off; keyword search is unaffected either way.
+func (x *Index) SetEmbedder(fn func() memory.Embedder) {
+	x.embedder = fn
+}
+
+func (x *Index) currentEmbedder() memory.Embedder {
+	if x.embedder == nil {
+		return nil
+	}
+	return x.embedder()
+}
+
+func (x *Index) kickIndexer() {
+	select {
+	case x.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the background indexer until Close: it re-chunks documents whose
+// index is stale and embeds passages that have no vector for the configured
+// model.
+func (x *Index) Start() {
+	go func() {
+		timer := time.NewTimer(30 * time.Second)
+		defer timer.Stop()
+		for {
+			select {
+			case <-x.stop:
+				return
+			case <-timer.C:
+			case <-x.kick:
+			}
+			x.sweep()
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(indexInterval)
+		}
+	}()
+}
+
+// Close stops the background indexer.
+func (x *Index) Close() {
+	close(x.stop)
+}
+
+func (x *Index) sweep() {
+	if _, err := x.Refresh(""); err != nil {
+		logger.Warn("Context index refresh: %v", err)
+	}
+	e := x.currentEmbedder()
+	if e == nil {
+		return
+	}
+	for {
+		select {
+		case <-x.stop:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		n, err := x.IndexEmbeddings(ctx, e)
+		cancel()
+		if err != nil {
+			logger.Warn("Context passage embedding: %v", err)
+			return
+		}
+		if n < embedBatch {
+			break
+		}
+	}
+	// Vectors for passages that no longer exist in any document.
+	x.db.Exec(`DELETE FROM context_chunk_embeddings WHERE content_hash NOT IN (SELECT content_hash FROM context_chunks)`)
+}
+
+// IndexFile re-chunks one document now. Upload and edit paths call it so a
+// document is searchable as soon as it is saved; its passages are embedded in
+// the background shortly after.
+func (x *Index) IndexFile(fileID string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var f staleFile
+	err := x.db.QueryRow(
+		`SELECT id, workspace_id, filename, text_filename, mime_type, is_about_you, updated_at FROM context_files WHERE id = ?`, fileID,
+	).Scan(&f.id, &f.workspaceID, &f.filename, &f.textFilename, &f.mimeType, &f.aboutYou, &f.updatedAt)
+	if err != nil {
+		return fmt.Errorf("context file %s: %w", fileID, err)
+	}
+	if err := x.indexFile(f); err != nil {
+		return err
+	}
+	x.kickIndexer()
+	return nil
+}
+
+// Refresh re-chunks every document in a workspace (all workspaces when
+// workspaceID is empty) whose index is older than its last edit, and returns
+// how many it re-chunked. Search calls it first, so results never lag an edit
+// made by a path that didn't call IndexFile.
+func (x *Index) Refresh(workspaceID string) (int, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	query := `SELECT id, workspace_id, filename, text_filename, mime_type, is_about_you, updated_at, indexed_at FROM context_files`
+	var args []interface{}
+	if workspaceID != "" {
+		query += ` WHERE workspace_id = ?`
+		args = append(args, workspaceID)
+	}
+	rows, err := x.db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var stale []staleFile
+	for rows.Next() {
+		var f staleFile
+		var indexed *time.Time
+		if rows.Scan(&f.id, &f.workspaceID, &f.filename, &f.textFilename, &f.mimeType, &f.aboutYou, &f.updatedAt, &indexed) != nil {
+			continue
+		}
+		if indexed == nil || !indexed.Equal(f.updatedAt) {
+			stale = append(stale, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range stale {
+		if err := x.indexFile(f); err != nil {
+			logger.Warn("Context index for %s: %v", f.id, err)
+		}
+	}
+	if len(stale) > 0 {
+		x.kickIndexer()
+	}
+	return len(stale), nil
+}
+
+type staleFile struct {
+	id, workspaceID, filename, textFilename, mimeType string
+	aboutYou                                          bool
+	updatedAt                                         time.Time
+}
+
+// indexFile replaces a document's passages. The About You file is left out:
+// it is already in every agent's prompt. Converted uploads are chunked from
+// their extracted Markdown; other binary files get no passages.
+func (x *Index) indexFile(f staleFile) error {
+	var chunks []chunk
+	source := f.filename
+	if f.textFilename != "" {
+		source = f.textFilename
+	}
+	if !f.aboutYou && (f.textFilename != "" || isText(f.mimeType)) {
+		data, err := readPrefix(filepath.Join(x.dir, filepath.Base(source)), maxIndexBytes)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		chunks = chunkText(string(data))
+	}
+
+	tx, err := x.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM context_chunks WHERE file_id = ?`, f.id); err != nil {
+		return err
+	}
+	for i, c := range chunks {
+		if _, err := tx.Exec(
+			`INSERT INTO context_chunks (id, file_id, workspace_id, ordinal, heading, content, content_hash) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), f.id, f.workspaceID, i, c.heading, c.content, contentHash(embeddingText(c.heading, c.content)),
+		); err != nil {
+			return err
+		}
+	}
+	// Stamped with the updated_at that was read, not now: an edit landing
+	// while this ran leaves the file stale for the next refresh.
+	if _, err := tx.Exec(`UPDATE context_files SET indexed_at = ? WHERE id = ?`, f.updatedAt, f.id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IndexEmbeddings embeds up to one batch of passages that have no vector for
+// the backend's model and returns how many it embedded. Identical passages
+// (the same section in two documents) share a vector.
+func (x *Index) IndexEmbeddings(ctx context.Context, e memory.Embedder) (int, error) {
+	rows, err := x.db.Query(
+		`SELECT c.content_hash, MIN(c.heading), MIN(c.content)
+		   FROM context_chunks c
+		   LEFT JOIN context_chunk_embeddings e ON e.content_hash = c.content_hash AND e.model = ?
+		  WHERE e.content_hash IS NULL
+		  GROUP BY c.content_hash LIMIT ?`,
+		e.Model(), embedBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var hashes, texts []string
+	for rows.Next() {
+		var hash, heading, content string
+		if rows.Scan(&hash, &heading, &content) != nil {
+			continue
+		}
+		hashes = append(hashes, hash)
+		texts = append(texts, embeddingText(heading, content))
+	}
+	rows.Close()
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	vectors, err := e.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != len(texts) {
+		return 0, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	for i, hash := range hashes {
+		if _, err := x.db.Exec(
+			`INSERT OR REPLACE INTO context_chunk_embeddings (content_hash, model, dims, vector) VALUES (?, ?, ?, ?)`,
+			hash, e.Model(), len(vectors[i]), memory.EncodeVector(vectors[i]),
+		); err != nil {
+			return i, err
+		}
+	}
+	return len(hashes), nil
+}
+
+// embeddingText is what gets embedded for a passage. The heading goes in
+// because a passage often only makes sense under it ("Eligibility" means
+// nothing without "Parental leave").
+func embeddingText(heading, content string) string {
+	if heading == "" {
+		return content
+	}
+	return heading + "\n\n" + content
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:16])
+}
+
+// isText reports whether a context file can be chunked as text. Mirrors
+// isTextMime in handlers/context.go.
+func isText(mime string) bool {
+	return strings.HasPrefix(mime, "text/") ||
+		mime == "application/json" ||
+		mime == "application/xml" ||
+		mime == "application/javascript"
+}
+
+func readPrefix(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
@@ -0,0 +1,190 @@
+package contextindex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+const handbook = `# Employee Handbook
+
+Welcome to the company.
+
+## Leave
+
+### Parental leave
+
+Employees get sixteen weeks of paid parental leave after six months of service.
+
+### Sick leave
+
+Ten days of sick leave per year, no doctor's note needed for the first three.
+
+## Equipment
+
+` + "```" + `
+# not a heading
+` + "```" + `
+
+Laptops are refreshed every three years.
+`
+
+func TestChunkTextCarriesHeadingPath(t *testing.T) {
+	chunks := chunkText(handbook)
+	want := []string{
+		"Employee Handbook",
+		"Employee Handbook › Leave › Parental leave",
+		"Employee Handbook › Leave › Sick leave",
+		"Employee Handbook › Equipment",
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+	for i, c := range chunks {
+		if c.heading != want[i] {
+			t.Errorf("chunk %d heading = %q, want %q", i, c.heading, want[i])
+		}
+	}
+	if !strings.Contains(chunks[3].content, "# not a heading") {
+		t.Errorf("fenced heading was treated as a heading: %+v", chunks[3])
+	}
+
+	long := strings.Repeat("A sentence about the warranty terms. ", 200)
+	pieces := chunkText(long)
+	if len(pieces) < 4 {
+		t.Fatalf("long paragraph split into %d pieces", len(pieces))
+	}
+	for _, p := range pieces {
+		if len(p.content) > maxChunkChars {
+			t.Errorf("piece of %d chars", len(p.content))
+		}
+	}
+}
+
+// wordEmbedder puts text on two axes — time off and hardware — so a query
+// with no word in common with a passage can still match it by meaning.
+type wordEmbedder struct{}
+
+func (wordEmbedder) Model() string { return "words-v1" }
+
+func (wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		v := []float32{0.01, 0.01}
+		for _, w := range []string{"leave", "vacation", "baby", "holiday"} {
+			if strings.Contains(text, w) {
+				v[0]++
+			}
+		}
+		for _, w := range []string{"laptop", "computer", "equipment"} {
+			if strings.Contains(text, w) {
+				v[1]++
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestIndexAndSearch(t *testing.T) {
+	dataDir := t.TempDir()
+	db, err := database.New(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	dir := filepath.Join(dataDir, "context")
+	os.MkdirAll(dir, 0755)
+
+	addFile := func(id, workspaceID, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, id+".md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now().UTC()
+		if _, err := db.Exec(
+			`INSERT INTO context_files (id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at)
+			 VALUES (?, ?, ?, 'text/markdown', ?, 0, ?, ?, ?)`,
+			id, name, id+".md", len(content), workspaceID, now, now,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addFile("f1", "ws1", "Handbook", handbook)
+	addFile("f2", "ws1", "Release notes", "Version 2 adds parental controls.")
+	addFile("f3", "ws2", "Other handbook", "Parental leave is twelve weeks here.")
+
+	index := New(db, dataDir)
+	if err := index.IndexFile("f1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// f2 was never indexed explicitly; Search refreshes it.
+	results, err := index.Search(context.Background(), Options{WorkspaceID: "ws1", Query: "How much parental leave do we get?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Passages) == 0 {
+		t.Fatal("no passages")
+	}
+	top := results.Passages[0]
+	if top.Citation != "Handbook › Employee Handbook › Leave › Parental leave" || !strings.Contains(top.Content, "sixteen weeks") {
+		t.Errorf("top passage = %+v", top)
+	}
+	for _, p := range results.Passages {
+		if p.FileID == "f3" {
+			t.Error("passage from another workspace")
+		}
+	}
+
+	scoped, _ := index.Search(context.Background(), Options{WorkspaceID: "ws1", Query: "parental", Document: "release notes"})
+	if len(scoped.Passages) != 1 || scoped.Passages[0].FileID != "f2" {
+		t.Errorf("document-scoped search = %+v", scoped.Passages)
+	}
+
+	// An edit is picked up because updated_at moved past indexed_at.
+	os.WriteFile(filepath.Join(dir, "f2.md"), []byte("Version 3 drops the legacy importer."), 0644)
+	db.Exec("UPDATE context_files SET updated_at = ? WHERE id = 'f2'", time.Now().UTC().Add(time.Second))
+	edited, _ := index.Search(context.Background(), Options{WorkspaceID: "ws1", Query: "importer"})
+	if len(edited.Passages) != 1 || edited.Passages[0].FileID != "f2" {
+		t.Errorf("after edit = %+v", edited.Passages)
+	}
+
+	// Semantic matching finds "vacation" under Leave with no shared keyword.
+	index.SetEmbedder(func() memory.Embedder { return wordEmbedder{} })
+	for {
+		n, err := index.IndexEmbeddings(context.Background(), wordEmbedder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < embedBatch {
+			break
+		}
+	}
+	semantic, err := index.Search(context.Background(), Options{WorkspaceID: "ws1", Query: "vacation days", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !semantic.Semantic || len(semantic.Passages) == 0 || !strings.Contains(semantic.Passages[0].Heading, "leave") {
+		t.Errorf("semantic search = %+v", semantic)
+	}
+
+	if _, err := index.Search(context.Background(), Options{WorkspaceID: "ws1", Query: "?!"}); err != ErrEmptyQuery {
+		t.Errorf("empty query: %v", err)
+	}
+
+	// Deleting a file drops its passages.
+	db.Exec("DELETE FROM context_files WHERE id = 'f1'")
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM context_chunks WHERE file_id = 'f1'").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d passages left after delete", n)
+	}
+}
@@ -0,0 +1,302 @@
+package contextindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+const (
+	// minSimilarity is the cosine floor for a semantic hit, as in memory
+	// search: below it every passage looks a little like every question.
+	minSimilarity = 0.3
+	// rrfK is the Reciprocal Rank Fusion constant.
+	rrfK = 60
+	// queryEmbedTimeout bounds the query embedding. A slow backend degrades
+	// search to keyword-only rather than stalling the agent.
+	queryEmbedTimeout = 10 * time.Second
+	defaultLimit      = 5
+	maxLimit          = 20
+)
+
+// stopWords are dropped from keyword queries. Agents search with whole
+// questions, and matching any word means "the" would otherwise match every
+// passage in the library.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "does": true, "for": true,
+	"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "our": true, "the": true, "this": true,
+	"to": true, "we": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "with": true, "you": true,
+}
+
+// ErrEmptyQuery is returned for a query with no searchable words.
+var ErrEmptyQuery = errors.New("search query has no words to match")
+
+// Options narrows a search. Document limits it to one file, by ID or exact
+// name.
+type Options struct {
+	WorkspaceID string
+	Query       string
+	Document    string
+	Limit       int
+}
+
+// Passage is one matching section of a document. Citation is how an answer
+// should credit it: the document name, then the heading path when there is
+// one. Match says which half of the hybrid search found it: "keyword",
+// "semantic" or "both".
+type Passage struct {
+	FileID     string  `json:"file_id"`
+	Document   string  `json:"document"`
+	Heading    string  `json:"heading,omitempty"`
+	Citation   string  `json:"citation"`
+	Ordinal    int     `json:"passage"`
+	Content    string  `json:"content"`
+	Match      string  `json:"match"`
+	Similarity float64 `json:"similarity,omitempty"`
+
+	id    string
+	score float64
+}
+
+type Results struct {
+	Query    string    `json:"query"`
+	Passages []Passage `json:"passages"`
+	// FullText is false when the build lacks FTS5 and keywords are matched
+	// by substring, without stemming.
+	FullText bool `json:"full_text"`
+	// Semantic is true when an embedding backend is configured and passages
+	// were also matched by meaning.
+	Semantic bool `json:"semantic"`
+}
+
+// Search returns the passages that best answer a query. Keyword and semantic
+// rankings are merged with Reciprocal Rank Fusion, the same way memory search
+// merges them.
+func (x *Index) Search(ctx context.Context, opts Options) (Results, error) {
+	terms := queryTerms(opts.Query)
+	if len(terms) == 0 {
+		return Results{}, ErrEmptyQuery
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
+	}
+	if _, err := x.Refresh(opts.WorkspaceID); err != nil {
+		return Results{}, err
+	}
+
+	results := Results{Query: opts.Query, Passages: []Passage{}, FullText: x.db.HasFTS()}
+	depth := opts.Limit * 3
+	keyword, kwErr := x.keywordSearch(terms, opts, depth)
+
+	var semantic []Passage
+	if e := x.currentEmbedder(); e != nil {
+		var err error
+		semantic, err = x.semanticSearch(ctx, e, opts, depth)
+		if err != nil {
+			logger.Warn("Semantic context search fell back to keywords: %v", err)
+		} else {
+			results.Semantic = true
+		}
+	}
+	if kwErr != nil && len(semantic) == 0 {
+		return results, kwErr
+	}
+
+	merged := map[string]*Passage{}
+	var order []*Passage
+	for i := range keyword {
+		p := keyword[i]
+		p.Match = "keyword"
+		p.score = 1.0 / float64(rrfK+i+1)
+		merged[p.id] = &p
+		order = append(order, &p)
+	}
+	for i, s := range semantic {
+		if p, ok := merged[s.id]; ok {
+			p.Match = "both"
+			p.Similarity = s.Similarity
+			p.score += 1.0 / float64(rrfK+i+1)
+			continue
+		}
+		p := s
+		p.Match = "semantic"
+		p.score = 1.0 / float64(rrfK+i+1)
+		merged[p.id] = &p
+		order = append(order, &p)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].score > order[j].score })
+
+	for _, p := range order {
+		if len(results.Passages) == opts.Limit {
+			break
+		}
+		p.Citation = citation(p.Document, p.Heading)
+		results.Passages = append(results.Passages, *p)
+	}
+	return results, nil
+}
+
+func citation(document, heading string) string {
+	if heading == "" {
+		return document
+	}
+	return document + headingSeparator + heading
+}
+
+const passageColumns = `c.id, c.file_id, f.name, c.heading, c.ordinal, c.content`
+
+// scopeSQL restricts a chunks table aliased c (joined to its file as f) to the
+// workspace and, optionally, one document.
+func scopeSQL(opts Options) (string, []interface{}) {
+	where := " AND c.workspace_id = ?"
+	args := []interface{}{opts.WorkspaceID}
+	if doc := strings.TrimSpace(opts.Document); doc != "" {
+		where += " AND (f.id = ? OR LOWER(f.name) = LOWER(?))"
+		args = append(args, doc, doc)
+	}
+	return where, args
+}
+
+// keywordSearch matches passages containing any of the terms, ranked by BM25
+// with heading matches weighted double. Without FTS5 it ranks by how many of
+// the terms a passage contains.
+func (x *Index) keywordSearch(terms []string, opts Options, limit int) ([]Passage, error) {
+	scope, scopeArgs := scopeSQL(opts)
+	var query string
+	var args []interface{}
+	if x.db.HasFTS() {
+		quoted := make([]string, len(terms))
+		for i, t := range terms {
+			quoted[i] = `"` + t + `"`
+		}
+		query = `SELECT ` + passageColumns + `
+		           FROM context_chunks_fts JOIN context_chunks c ON c.rowid = context_chunks_fts.rowid
+		           JOIN context_files f ON f.id = c.file_id
+		          WHERE context_chunks_fts MATCH ?` + scope + `
+		          ORDER BY bm25(context_chunks_fts, 2.0, 1.0) LIMIT ?`
+		args = append([]interface{}{strings.Join(quoted, " OR ")}, scopeArgs...)
+	} else {
+		var score, match []string
+		for range terms {
+			cond := "(c.heading || ' ' || c.content) LIKE ? ESCAPE '\\'"
+			score = append(score, "CASE WHEN "+cond+" THEN 1 ELSE 0 END")
+			match = append(match, cond)
+		}
+		query = `SELECT ` + passageColumns + `
+		           FROM context_chunks c JOIN context_files f ON f.id = c.file_id
+		          WHERE (` + strings.Join(match, " OR ") + `)` + scope + `
+		          ORDER BY (` + strings.Join(score, " + ") + `) DESC, f.updated_at DESC, c.ordinal LIMIT ?`
+		// Bound in statement order: the match terms, the scope, then the
+		// same terms again for the score.
+		for _, t := range terms {
+			args = append(args, "%"+escapeLike(t)+"%")
+		}
+		args = append(args, scopeArgs...)
+		for _, t := range terms {
+			args = append(args, "%"+escapeLike(t)+"%")
+		}
+	}
+	args = append(args, limit)
+
+	rows, err := x.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search context: %w", err)
+	}
+	defer rows.Close()
+	var out []Passage
+	for rows.Next() {
+		var p Passage
+		if err := rows.Scan(&p.id, &p.FileID, &p.Document, &p.Heading, &p.Ordinal, &p.Content); err != nil {
+			return nil, fmt.Errorf("search context: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// semanticSearch compares the query's embedding with every passage vector
+// from the same model in the workspace. A linear scan, like memory search:
+// even a large library is tens of thousands of passages.
+func (x *Index) semanticSearch(ctx context.Context, e memory.Embedder, opts Options, limit int) ([]Passage, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryEmbedTimeout)
+	defer cancel()
+	vecs, err := e.Embed(ctx, []string{opts.Query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) == 0 || len(vecs[0]) == 0 {
+		return nil, errors.New("embedder returned no vector for the query")
+	}
+	q := vecs[0]
+
+	scope, scopeArgs := scopeSQL(opts)
+	rows, err := x.db.Query(
+		`SELECT `+passageColumns+`, v.vector
+		   FROM context_chunks c JOIN context_files f ON f.id = c.file_id
+		   JOIN context_chunk_embeddings v ON v.content_hash = c.content_hash AND v.model = ?
+		  WHERE 1 = 1`+scope,
+		append([]interface{}{e.Model()}, scopeArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Passage
+	for rows.Next() {
+		var p Passage
+		var blob []byte
+		if rows.Scan(&p.id, &p.FileID, &p.Document, &p.Heading, &p.Ordinal, &p.Content, &blob) != nil {
+			continue
+		}
+		p.Similarity = memory.Cosine(q, memory.DecodeVector(blob))
+		if p.Similarity >= minSimilarity {
+			out = append(out, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Similarity > out[j].Similarity })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// queryTerms splits a query into lower-case words, dropping stop words unless
+// that would leave nothing. Punctuation never reaches FTS5, so a query can't
+// be a syntax error.
+func queryTerms(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var terms []string
+	seen := map[string]bool{}
+	for _, w := range words {
+		if !stopWords[w] && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	if len(terms) == 0 {
+		return words
+	}
+	return terms
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
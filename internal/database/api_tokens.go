@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/auth"
+)
+
+// LookupAPIToken resolves a personal access token by its hash. Revoked and
+// expired tokens are returned as-is; auth.Service decides what to do with them.
+func (db *DB) LookupAPIToken(hash string) (*auth.APIToken, error) {
+	var t auth.APIToken
+	var scopesJSON string
+	var expiresAt, revokedAt sql.NullTime
+	err := db.QueryRow(
+		`SELECT t.id, t.user_id, u.username, t.name, t.scopes, t.expires_at, t.revoked_at
+		 FROM api_tokens t JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = ?`, hash,
+	).Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &scopesJSON, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(scopesJSON), &t.Scopes)
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+// RecordAPITokenUse stamps the token's last use and writes an audit entry for
+// the request, so every scripted call can be traced back to its token.
+func (db *DB) RecordAPITokenUse(t *auth.APIToken, r *http.Request) {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	db.Exec("UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?", time.Now().UTC(), ip, t.ID)
+	db.LogAudit(t.UserID, "api_token_used", "auth", "api_token", t.ID, strings.TrimSpace(t.Name+": "+r.Method+" "+r.URL.Path))
+}
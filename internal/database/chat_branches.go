@@ -0,0 +1,29 @@
+package database
+
+// ActiveBranch selects the IDs of the messages on a thread's active branch:
+// the path from the thread's head message back to its first message. Edits
+// and regenerations fork a thread rather than rewrite it (see migration 087),
+// so everything that treats a thread as one conversation — the history sent to
+// agents, compaction, transcripts — reads this path instead of every message.
+//
+// Use it as `id IN (` + ActiveBranch + `)` with the thread ID as its argument.
+const ActiveBranch = `WITH RECURSIVE branch(id, parent_id) AS (
+    SELECT m.id, m.parent_id FROM chat_messages m
+    JOIN chat_threads t ON t.head_message_id = m.id
+    WHERE t.id = ?
+    UNION
+    SELECT m.id, m.parent_id FROM chat_messages m
+    JOIN branch b ON m.id = b.parent_id
+) SELECT id FROM branch`
+
+// SetThreadHead makes a message the head of its thread's active branch.
+// Switching branches changes the conversation a CLI provider's stored session
+// remembers, so those sessions are dropped and the next turn replays the new
+// branch's history instead.
+func (db *DB) SetThreadHead(threadID, messageID string) error {
+	if _, err := db.Exec("UPDATE chat_threads SET head_message_id = ? WHERE id = ?", messageID, threadID); err != nil {
+		return err
+	}
+	db.DeleteThreadProviderSessions(threadID)
+	return nil
+}
@@ -18,6 +18,8 @@ var migrationsFS embed.FS
 type DB struct {
 	*sql.DB
 	OnAudit func(action, category string)
+
+	fts bool
 }
 
 func New(dataDir string) (*DB, error) {
@@ -44,6 +46,9 @@ func New(dataDir string) (*DB, error) {
 	if err := db.runMigrations(); err != nil {
 		return nil, fmt.Errorf("run migrations: %w", err)
 	}
+	if err := db.setupSearchIndexes(); err != nil {
+		return nil, err
+	}
 
 	logger.Success("Database initialized at %s", dbPath)
 	return db, nil
@@ -0,0 +1,26 @@
+-- Personal access tokens for scripting the REST API.
+--
+-- The UI signs in with a JWT cookie plus a CSRF header, which suits a browser
+-- and nothing else: curl, CI jobs and home-automation scripts had to log in
+-- with a password and juggle both. A token is sent as `Authorization: Bearer`
+-- instead, is limited to the scopes it was created with, and can be revoked on
+-- its own without touching the user's password.
+--
+-- Only a SHA-256 of the token is stored; the plaintext is shown once at
+-- creation. token_prefix keeps enough of it to recognise in a list.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    token_prefix TEXT NOT NULL,
+    scopes       TEXT NOT NULL DEFAULT '[]',
+    expires_at   DATETIME,
+    last_used_at DATETIME,
+    last_used_ip TEXT NOT NULL DEFAULT '',
+    revoked_at   DATETIME,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
@@ -0,0 +1,45 @@
+-- Multiple users, roles and per-workspace membership.
+--
+-- Setup creates one user and every route assumed that user owned everything.
+-- A shared box needs more than one account, so users now carry a global role:
+--
+--   admin  — everything, including users, invitations and revealing secrets
+--   editor — create and change things in the workspaces they belong to
+--   viewer — read-only in the workspaces they belong to
+--
+-- Existing users were the sole owner of their install, so they become admins.
+--
+-- Admins see every workspace. Everyone else needs a workspace_members row, and
+-- their effective role there is the lower of their global role and the
+-- membership role — an editor can be made a viewer in one workspace, but a
+-- viewer can't be promoted past their account's role by a membership.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'admin';
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id TEXT NOT NULL,
+    user_id      TEXT NOT NULL,
+    role         TEXT NOT NULL DEFAULT 'viewer',
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user ON workspace_members(user_id);
+
+-- Invitations are single-use links. As with API tokens only a SHA-256 of the
+-- token is stored; the plaintext link is shown once to the admin who made it.
+-- workspace_ids is a JSON array of workspaces the new account joins, each with
+-- the invitation's role.
+CREATE TABLE IF NOT EXISTS user_invitations (
+    id               TEXT PRIMARY KEY,
+    token_hash       TEXT NOT NULL UNIQUE,
+    role             TEXT NOT NULL DEFAULT 'viewer',
+    workspace_ids    TEXT NOT NULL DEFAULT '[]',
+    note             TEXT NOT NULL DEFAULT '',
+    invited_by       TEXT NOT NULL DEFAULT '',
+    expires_at       DATETIME NOT NULL,
+    accepted_at      DATETIME,
+    accepted_user_id TEXT NOT NULL DEFAULT '',
+    created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
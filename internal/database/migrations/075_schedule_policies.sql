@@ -0,0 +1,28 @@
+-- Retry, timeout and overlap policy for scheduled prompts.
+--
+-- A scheduled run used to be a single attempt with the global agent timeout:
+-- one 503 from the provider at 9am and the morning report simply failed. And a
+-- run longer than the cron interval was joined by a second, concurrent run of
+-- the same prompt on the next fire.
+--
+-- retry_max_attempts counts every attempt, so the default of 1 keeps the old
+-- behaviour. The wait before attempt n+1 is
+-- retry_backoff_seconds * retry_backoff_multiplier^(n-1). retry_on is a JSON
+-- array of error classes (timeout, rate_limit, server, network, any); an empty
+-- array means every class except "any".
+--
+-- timeout_seconds = 0 uses the global agent timeout.
+--
+-- concurrency_policy decides what a fire does while the previous run is still
+-- going: skip it (recorded as a 'skipped' execution), queue one run to start
+-- when the current one ends, or allow both to run side by side. Skip is the
+-- default because overlapping runs were never intended.
+ALTER TABLE schedules ADD COLUMN retry_max_attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE schedules ADD COLUMN retry_backoff_seconds INTEGER NOT NULL DEFAULT 30;
+ALTER TABLE schedules ADD COLUMN retry_backoff_multiplier REAL NOT NULL DEFAULT 2;
+ALTER TABLE schedules ADD COLUMN retry_on TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE schedules ADD COLUMN timeout_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE schedules ADD COLUMN concurrency_policy TEXT NOT NULL DEFAULT 'skip';
+
+-- Each attempt is its own execution row; attempt numbers them within a run.
+ALTER TABLE schedule_executions ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,20 @@
+-- Event-triggered automations: a schedule can fire on an inbound webhook
+-- instead of a cron expression.
+--
+-- trigger_type is 'cron' (the only kind there was) or 'webhook'. A webhook
+-- automation has no cron entry; it runs when something POSTs to
+-- /api/v1/hooks/{webhook_token}. The token only routes the request — every
+-- delivery must also carry an HMAC-SHA256 signature made with webhook_secret,
+-- which is stored encrypted with the secrets key because verifying a signature
+-- needs the plaintext (a hash would not do). prompt_content is the prompt
+-- template; {{payload.some.field}} placeholders are filled from the JSON body.
+ALTER TABLE schedules ADD COLUMN trigger_type TEXT NOT NULL DEFAULT 'cron';
+ALTER TABLE schedules ADD COLUMN webhook_token TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN webhook_secret TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_schedules_webhook_token
+    ON schedules(webhook_token) WHERE webhook_token != '';
+
+-- What started each run, so one history covers both kinds: 'cron', 'manual'
+-- (Run now) or 'webhook'.
+ALTER TABLE schedule_executions ADD COLUMN triggered_by TEXT NOT NULL DEFAULT 'cron';
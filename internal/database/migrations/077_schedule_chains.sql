@@ -0,0 +1,75 @@
+-- Schedule chains: follow-up runs that start when a schedule finishes.
+--
+-- A morning research → summarize → post flow used to be three cron jobs spaced
+-- ten minutes apart, each guessing the previous one was done and none able to
+-- see its output. A chain hangs a DAG of steps off one root schedule (cron or
+-- webhook). When the root run ends, each top-level step checks run_on against
+-- the outcome ('success', 'failure' or 'always') and its condition against the
+-- output, then runs its agent with a prompt built from the upstream output.
+-- Steps under it see that step's outcome in turn. Every step has exactly one
+-- upstream (parent_step_id, '' = the root schedule), so the graph can fan out
+-- but never loop.
+CREATE TABLE IF NOT EXISTS schedule_chains (
+    id               TEXT PRIMARY KEY,
+    name             TEXT NOT NULL,
+    description      TEXT NOT NULL DEFAULT '',
+    root_schedule_id TEXT NOT NULL,
+    enabled          INTEGER NOT NULL DEFAULT 1,
+    created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (root_schedule_id) REFERENCES schedules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_chains_root ON schedule_chains(root_schedule_id);
+
+-- condition_type is '' (always continue), 'contains', 'not_contains' or
+-- 'regex', tested against the upstream output. prompt_template may use
+-- {{output}}, {{error}} and {{status}}; without {{output}} the upstream output
+-- is appended as context. notify files the step's result to the Inbox.
+CREATE TABLE IF NOT EXISTS schedule_chain_steps (
+    id              TEXT PRIMARY KEY,
+    chain_id        TEXT NOT NULL,
+    parent_step_id  TEXT NOT NULL DEFAULT '',
+    name            TEXT NOT NULL DEFAULT '',
+    run_on          TEXT NOT NULL DEFAULT 'success',
+    condition_type  TEXT NOT NULL DEFAULT '',
+    condition_value TEXT NOT NULL DEFAULT '',
+    agent_role_slug TEXT NOT NULL,
+    prompt_template TEXT NOT NULL DEFAULT '',
+    notify          INTEGER NOT NULL DEFAULT 0,
+    sort_order      INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (chain_id) REFERENCES schedule_chains(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_chain_steps_chain ON schedule_chain_steps(chain_id);
+
+-- One row per time the chain ran, pointing at the root schedule's execution,
+-- plus a row per step so the history shows the whole flow as a unit. Steps
+-- that didn't run (wrong outcome, condition not met, upstream skipped) are
+-- recorded as 'skipped' with the reason in error.
+CREATE TABLE IF NOT EXISTS schedule_chain_runs (
+    id                TEXT PRIMARY KEY,
+    chain_id          TEXT NOT NULL,
+    root_execution_id TEXT NOT NULL DEFAULT '',
+    status            TEXT NOT NULL DEFAULT 'running',
+    started_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at       DATETIME,
+    FOREIGN KEY (chain_id) REFERENCES schedule_chains(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_chain_runs_chain ON schedule_chain_runs(chain_id, started_at);
+
+CREATE TABLE IF NOT EXISTS schedule_chain_run_steps (
+    id          TEXT PRIMARY KEY,
+    run_id      TEXT NOT NULL,
+    step_id     TEXT NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'running',
+    output      TEXT NOT NULL DEFAULT '',
+    error       TEXT NOT NULL DEFAULT '',
+    thread_id   TEXT NOT NULL DEFAULT '',
+    started_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY (run_id) REFERENCES schedule_chain_runs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_chain_run_steps_run ON schedule_chain_run_steps(run_id);
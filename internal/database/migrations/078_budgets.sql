@@ -0,0 +1,55 @@
+-- Token and cost budgets.
+--
+-- Spend used to be recorded only where a reply landed in a chat thread, so a
+-- threadless scheduled run or a heartbeat cost money nobody could see, let
+-- alone cap. usage_ledger gets one row per finished agent run from every path
+-- (chat, schedule, heartbeat, sub-agent), tagged with who ran it, where, and
+-- on which provider and model. Budgets and the spend report read from it.
+CREATE TABLE IF NOT EXISTS usage_ledger (
+    id              TEXT PRIMARY KEY,
+    source          TEXT NOT NULL DEFAULT 'chat',
+    agent_role_slug TEXT NOT NULL DEFAULT '',
+    workspace_id    TEXT NOT NULL DEFAULT '',
+    schedule_id     TEXT NOT NULL DEFAULT '',
+    thread_id       TEXT NOT NULL DEFAULT '',
+    provider        TEXT NOT NULL DEFAULT '',
+    model           TEXT NOT NULL DEFAULT '',
+    input_tokens    INTEGER NOT NULL DEFAULT 0,
+    output_tokens   INTEGER NOT NULL DEFAULT 0,
+    cost_usd        REAL NOT NULL DEFAULT 0,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_created ON usage_ledger(created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_agent ON usage_ledger(agent_role_slug, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_workspace ON usage_ledger(workspace_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_schedule ON usage_ledger(schedule_id, created_at);
+
+-- A budget caps one agent, workspace or schedule over a calendar day or month
+-- (UTC). Either limit may be 0 for "no limit". Reaching soft_percent of a limit
+-- files a warning in the Inbox; reaching the limit itself refuses new runs in
+-- that scope until the period rolls over.
+CREATE TABLE IF NOT EXISTS budgets (
+    id           TEXT PRIMARY KEY,
+    scope_type   TEXT NOT NULL,
+    scope_id     TEXT NOT NULL,
+    period       TEXT NOT NULL DEFAULT 'daily',
+    limit_usd    REAL NOT NULL DEFAULT 0,
+    limit_tokens INTEGER NOT NULL DEFAULT 0,
+    soft_percent INTEGER NOT NULL DEFAULT 80,
+    enabled      INTEGER NOT NULL DEFAULT 1,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (scope_type, scope_id, period)
+);
+
+-- One row per budget, period and level already announced, so each warning is
+-- sent once per period rather than after every run past the threshold.
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id    TEXT NOT NULL,
+    period_start TEXT NOT NULL,
+    level        TEXT NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (budget_id, period_start, level),
+    FOREIGN KEY (budget_id) REFERENCES budgets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,43 @@
+-- External MCP servers whose tools agents can use.
+--
+-- The /mcp/{token} endpoint only ever served OpenPaw's own tools outward, to
+-- the CLI engines. These are the other direction: third-party servers OpenPaw
+-- connects to as a client. transport is 'stdio' (command + args, spawned as a
+-- child process) or 'http' (a streamable-HTTP url). env and headers are JSON
+-- objects mapping a variable or header name to the name of a secret, so no
+-- credential is stored here in the clear.
+--
+-- tools_json caches the last tools/list, so a chat turn can offer a server's
+-- tools without first spawning it; the process starts on the first call.
+CREATE TABLE IF NOT EXISTS mcp_servers (
+    id                TEXT PRIMARY KEY,
+    name              TEXT NOT NULL UNIQUE,
+    description       TEXT NOT NULL DEFAULT '',
+    transport         TEXT NOT NULL DEFAULT 'stdio',
+    command           TEXT NOT NULL DEFAULT '',
+    args              TEXT NOT NULL DEFAULT '[]',
+    url               TEXT NOT NULL DEFAULT '',
+    env               TEXT NOT NULL DEFAULT '{}',
+    headers           TEXT NOT NULL DEFAULT '{}',
+    enabled           INTEGER NOT NULL DEFAULT 1,
+    tools_json        TEXT NOT NULL DEFAULT '[]',
+    last_error        TEXT NOT NULL DEFAULT '',
+    last_connected_at DATETIME,
+    created_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Who may use a server's tools: an agent (scope_id is its slug) or every
+-- agent running in a workspace (scope_id is the workspace ID). A server with
+-- no grants is connected but offered to nobody.
+CREATE TABLE IF NOT EXISTS mcp_server_grants (
+    id         TEXT PRIMARY KEY,
+    server_id  TEXT NOT NULL,
+    scope_type TEXT NOT NULL,
+    scope_id   TEXT NOT NULL,
+    granted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (server_id, scope_type, scope_id),
+    FOREIGN KEY (server_id) REFERENCES mcp_servers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mcp_server_grants_scope ON mcp_server_grants(scope_type, scope_id);
@@ -0,0 +1,28 @@
+-- Row and schema history for workspace databases.
+--
+-- UpdateRow and DeleteRow used to overwrite or remove rows with no trace, so a
+-- scheduled agent that bulk-edited a table wrongly left nothing to inspect or
+-- roll back. Every row write and column change now appends one revision with
+-- the state before and after it (JSON, 'null' where the row or column didn't
+-- exist) and who made it: a user, or an agent in a thread or schedule run.
+-- Revisions are never updated; a restore appends its own. row_id is '' for
+-- schema changes, column_id is '' for row changes.
+CREATE TABLE IF NOT EXISTS user_database_revisions (
+    id           TEXT PRIMARY KEY,
+    table_id     TEXT NOT NULL,
+    row_id       TEXT NOT NULL DEFAULT '',
+    column_id    TEXT NOT NULL DEFAULT '',
+    kind         TEXT NOT NULL,
+    before_data  TEXT NOT NULL DEFAULT 'null',
+    after_data   TEXT NOT NULL DEFAULT 'null',
+    actor        TEXT NOT NULL DEFAULT '',
+    thread_id    TEXT NOT NULL DEFAULT '',
+    schedule_id  TEXT NOT NULL DEFAULT '',
+    execution_id TEXT NOT NULL DEFAULT '',
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (table_id) REFERENCES user_database_tables(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_database_revisions_table ON user_database_revisions(table_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_database_revisions_row ON user_database_revisions(row_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_database_revisions_execution ON user_database_revisions(execution_id);
@@ -0,0 +1,37 @@
+-- Passage index for context documents.
+--
+-- Agents could only read a context document whole, so a library of long
+-- manuals either overflowed the context window or went unread. Each text
+-- document is now split into passages under its Markdown headings; the
+-- passages are searched by keyword (context_chunks_fts, set up at runtime —
+-- see database/search_index.go) and, when an embedding backend is configured,
+-- by meaning.
+--
+-- indexed_at records the updated_at of the version that was chunked, so a file
+-- whose updated_at has moved on is re-chunked.
+CREATE TABLE IF NOT EXISTS context_chunks (
+    id           TEXT PRIMARY KEY,
+    file_id      TEXT NOT NULL,
+    workspace_id TEXT NOT NULL,
+    ordinal      INTEGER NOT NULL,
+    heading      TEXT NOT NULL DEFAULT '',
+    content      TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    FOREIGN KEY (file_id) REFERENCES context_files(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_context_chunks_file ON context_chunks(file_id, ordinal);
+CREATE INDEX IF NOT EXISTS idx_context_chunks_workspace ON context_chunks(workspace_id);
+
+-- Vectors are keyed by passage text rather than chunk ID, so editing one
+-- section of a long document re-embeds only that section.
+CREATE TABLE IF NOT EXISTS context_chunk_embeddings (
+    content_hash TEXT NOT NULL,
+    model        TEXT NOT NULL,
+    dims         INTEGER NOT NULL,
+    vector       BLOB NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (content_hash, model)
+);
+
+ALTER TABLE context_files ADD COLUMN indexed_at DATETIME;
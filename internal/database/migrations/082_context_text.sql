@@ -0,0 +1,9 @@
+-- Extracted text for context files that aren't plain text.
+--
+-- Uploads used to be stored raw, apart from PDFs, which were replaced by their
+-- pdftotext output when the tool happened to be installed. Word, OpenDocument,
+-- HTML, EPUB and spreadsheet files, and images, are now converted to Markdown
+-- on upload and the result kept beside the original as text_filename, which
+-- agents, search and the MCP endpoint read in its place. '' means the file is
+-- read as it is (or can't be read as text at all).
+ALTER TABLE context_files ADD COLUMN text_filename TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,24 @@
+-- Version history for context documents.
+--
+-- update_context_document and the editor replace a document's whole text, so
+-- a bad rewrite used to leave nothing to go back to. Every save now appends a
+-- revision holding the full text and title as saved, who saved it (a user ID
+-- or "agent:<slug>") and the chat thread an agent was working in. A
+-- document's first edit after this migration also stores the text it had
+-- before as a 'baseline' revision. Revisions are never updated; restoring
+-- one appends a 'restored' revision pointing back at it.
+CREATE TABLE IF NOT EXISTS context_file_revisions (
+    id            TEXT PRIMARY KEY,
+    file_id       TEXT NOT NULL,
+    workspace_id  TEXT NOT NULL DEFAULT '',
+    kind          TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    content       TEXT NOT NULL,
+    actor         TEXT NOT NULL DEFAULT '',
+    thread_id     TEXT NOT NULL DEFAULT '',
+    restored_from TEXT NOT NULL DEFAULT '',
+    created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_id) REFERENCES context_files(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_context_file_revisions_file ON context_file_revisions(file_id, created_at);
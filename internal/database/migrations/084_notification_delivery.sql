@@ -0,0 +1,74 @@
+-- Delivering notifications beyond the browser.
+--
+-- Notifications were only stored and broadcast over the WebSocket, so a
+-- scheduled report or heartbeat alert reached nobody without an open tab.
+-- Channels are the places a notification can also be sent: SMTP email, ntfy
+-- or Gotify push, a Telegram bot, a Slack or Discord incoming webhook, or a
+-- signed generic webhook. config holds a channel's plain settings; secrets
+-- maps a credential setting (password, bot_token, webhook_url...) to the name
+-- of the secret holding it, so no credential is stored here in the clear.
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    config     TEXT NOT NULL DEFAULT '{}',
+    secrets    TEXT NOT NULL DEFAULT '{}',
+    enabled    INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Rules decide which notifications go to which channel. Every condition left
+-- empty matches anything: min_priority ('low', 'normal' or 'high'),
+-- source_types (a JSON array such as ["schedule","heartbeat"]), workspace_id
+-- and agent_slug. During quiet hours (HH:MM to HH:MM in timezone) matching
+-- deliveries are held until the window ends, except high-priority ones when
+-- quiet_allows_high is set.
+CREATE TABLE IF NOT EXISTS notification_rules (
+    id                TEXT PRIMARY KEY,
+    channel_id        TEXT NOT NULL,
+    name              TEXT NOT NULL DEFAULT '',
+    min_priority      TEXT NOT NULL DEFAULT '',
+    source_types      TEXT NOT NULL DEFAULT '[]',
+    workspace_id      TEXT NOT NULL DEFAULT '',
+    agent_slug        TEXT NOT NULL DEFAULT '',
+    quiet_start       TEXT NOT NULL DEFAULT '',
+    quiet_end         TEXT NOT NULL DEFAULT '',
+    timezone          TEXT NOT NULL DEFAULT 'UTC',
+    quiet_allows_high INTEGER NOT NULL DEFAULT 1,
+    enabled           INTEGER NOT NULL DEFAULT 1,
+    created_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_rules_channel ON notification_rules(channel_id);
+
+-- One row per notification sent (or being sent) to a channel: the channel's
+-- delivery log. status is 'pending' until it is 'sent', or 'failed' once the
+-- retries run out or the channel rejects it outright; next_attempt_at is when
+-- a pending delivery is tried next.
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id              TEXT PRIMARY KEY,
+    notification_id TEXT NOT NULL,
+    channel_id      TEXT NOT NULL,
+    rule_id         TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at         DATETIME,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_channel ON notification_deliveries(channel_id, created_at);
+
+-- Notifications are routed to channels once, by the dispatcher, whichever
+-- code path inserted them. Everything already in the Inbox counts as routed,
+-- so turning this on doesn't send out the backlog.
+ALTER TABLE notifications ADD COLUMN routed INTEGER NOT NULL DEFAULT 0;
+UPDATE notifications SET routed = 1;
+CREATE INDEX IF NOT EXISTS idx_notifications_unrouted ON notifications(routed) WHERE routed = 0;
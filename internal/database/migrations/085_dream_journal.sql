@@ -0,0 +1,37 @@
+-- A journal of what each dream did to an agent's memory.
+--
+-- dream_runs only kept counts, so a consolidation that rewrote or dropped the
+-- wrong memory left no trace of what the memory used to say, and nothing could
+-- put it back. Every add, update and forget is now recorded with the memory as
+-- it was before and after, which is what the run's diff, the review queue and
+-- the revert are built on.
+--
+-- status is 'applied' for a change the dream made, 'proposed' for one waiting
+-- in the review queue (propose-only mode), 'rejected' once a reviewer turned it
+-- down, and 'reverted' once a revert undid it. before_json and after_json hold
+-- the memory record; before is empty for an add and after for a forget.
+CREATE TABLE IF NOT EXISTS dream_ops (
+    id          TEXT PRIMARY KEY,
+    run_id      TEXT NOT NULL,
+    agent_slug  TEXT NOT NULL,
+    seq         INTEGER NOT NULL DEFAULT 0,
+    op          TEXT NOT NULL,
+    memory_id   TEXT NOT NULL DEFAULT '',
+    before_json TEXT NOT NULL DEFAULT '',
+    after_json  TEXT NOT NULL DEFAULT '',
+    reason      TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'applied',
+    decided_by  TEXT NOT NULL DEFAULT '',
+    decided_at  TIMESTAMP,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (run_id) REFERENCES dream_runs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_dream_ops_run ON dream_ops(run_id, seq);
+CREATE INDEX IF NOT EXISTS idx_dream_ops_status ON dream_ops(status, created_at);
+
+-- mode is 'apply' for a run that changed memory itself and 'propose' for one
+-- that only queued its changes for review. reverted_at is set once a revert
+-- has put the run's changes back.
+ALTER TABLE dream_runs ADD COLUMN mode TEXT NOT NULL DEFAULT 'apply';
+ALTER TABLE dream_runs ADD COLUMN reverted_at TIMESTAMP;
@@ -0,0 +1,34 @@
+-- Shared memory spaces across agents.
+--
+-- Every agent's memory was its own database, so a fact one agent learned
+-- ("staging moved to staging2") had to be relearned by each of the others.
+-- A shared memory scope is a memory database several agents read and write
+-- through the same memory tools; its memories live on disk under
+-- data/shared/<id>/memory.db, and this table only holds who may reach it.
+--
+-- workspace_id NULL makes the scope global. default_access ('none', 'read' or
+-- 'write') is what agents get without a row of their own: every agent for a
+-- global scope, but for a workspace scope only the agents pinned to that
+-- workspace (agent_roles.workspace_id) — an agent that is not must be granted
+-- access explicitly.
+CREATE TABLE IF NOT EXISTS shared_memory_scopes (
+    id             TEXT PRIMARY KEY,
+    name           TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    description    TEXT NOT NULL DEFAULT '',
+    workspace_id   TEXT REFERENCES workspaces(id) ON DELETE CASCADE,
+    default_access TEXT NOT NULL DEFAULT 'write',
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Per-agent access that overrides the scope's default, including 'none' to
+-- keep one agent out of a scope everyone else shares.
+CREATE TABLE IF NOT EXISTS shared_memory_access (
+    scope_id   TEXT NOT NULL REFERENCES shared_memory_scopes(id) ON DELETE CASCADE,
+    agent_slug TEXT NOT NULL,
+    access     TEXT NOT NULL,
+    PRIMARY KEY (scope_id, agent_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shared_memory_access_agent ON shared_memory_access(agent_slug);
+
@@ -0,0 +1,40 @@
+-- Conversation branching. Editing a user message or regenerating a reply forks
+-- the thread at that point instead of rewriting it, so a thread's messages
+-- form a tree: each message points at the one it follows, and the thread
+-- remembers which leaf it is showing. The active branch is the path from that
+-- head back to the first message.
+--
+-- parent_id is '' for a first message. It is left nullable so the trigger
+-- below can tell "append to the conversation" (every existing insert, which
+-- doesn't name a parent) from a deliberate fork.
+ALTER TABLE chat_messages ADD COLUMN parent_id TEXT;
+ALTER TABLE chat_threads ADD COLUMN head_message_id TEXT NOT NULL DEFAULT '';
+
+-- Existing threads are linear: each message follows the one before it.
+UPDATE chat_messages SET parent_id = COALESCE((
+    SELECT p.id FROM chat_messages p
+    WHERE p.thread_id = chat_messages.thread_id
+      AND (p.created_at < chat_messages.created_at
+           OR (p.created_at = chat_messages.created_at AND p.rowid < chat_messages.rowid))
+    ORDER BY p.created_at DESC, p.rowid DESC
+    LIMIT 1
+), '');
+
+UPDATE chat_threads SET head_message_id = COALESCE((
+    SELECT m.id FROM chat_messages m
+    WHERE m.thread_id = chat_threads.id
+    ORDER BY m.created_at DESC, m.rowid DESC
+    LIMIT 1
+), '');
+
+CREATE INDEX IF NOT EXISTS idx_chat_messages_parent ON chat_messages(parent_id);
+
+-- A message saved without a parent continues the active branch and becomes
+-- its new head. Forks name their parent and move the head themselves.
+CREATE TRIGGER IF NOT EXISTS chat_messages_branch_ai AFTER INSERT ON chat_messages
+WHEN NEW.parent_id IS NULL BEGIN
+    UPDATE chat_messages
+       SET parent_id = COALESCE((SELECT head_message_id FROM chat_threads WHERE id = NEW.thread_id), '')
+     WHERE rowid = NEW.rowid;
+    UPDATE chat_threads SET head_message_id = NEW.id WHERE id = NEW.thread_id;
+END;
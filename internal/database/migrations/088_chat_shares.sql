@@ -0,0 +1,17 @@
+-- Read-only share links for chat threads. Whoever holds the link can read the
+-- thread's active branch without an account until it expires or is revoked.
+-- As with invitations only a SHA-256 of the token is stored; the link itself
+-- is shown once, to the user who made it.
+CREATE TABLE IF NOT EXISTS chat_thread_shares (
+    id             TEXT PRIMARY KEY,
+    thread_id      TEXT NOT NULL,
+    token_hash     TEXT NOT NULL UNIQUE,
+    created_by     TEXT NOT NULL DEFAULT '',
+    expires_at     DATETIME NOT NULL,
+    view_count     INTEGER NOT NULL DEFAULT 0,
+    last_viewed_at DATETIME,
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_thread_shares_thread ON chat_thread_shares(thread_id);
@@ -0,0 +1,8 @@
+-- Each user's active workspace.
+--
+-- The active workspace was one instance-wide setting, so one member switching
+-- workspace moved every other user's requests with them. Requests now act in
+-- the workspace named by their X-Workspace-ID header, else the one their user
+-- last switched to. The settings key stays as the instance's default, which
+-- background work (dreaming, agent tools, terminals) still uses.
+ALTER TABLE users ADD COLUMN active_workspace_id TEXT NOT NULL DEFAULT '';
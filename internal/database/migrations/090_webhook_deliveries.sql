@@ -0,0 +1,16 @@
+-- Webhook deliveries already run, so a captured request can't be sent again.
+--
+-- A valid signature only proves the sender once held the secret; anyone who
+-- sees a delivery in transit or in a log could otherwise replay it. Each
+-- accepted delivery records a replay key: the X-OpenPaw-Delivery id, which the
+-- signature covers, or for senders that sign only the body (GitHub and its
+-- kind) the signature itself. Keys are kept for a while and then pruned.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    schedule_id TEXT NOT NULL,
+    replay_key  TEXT NOT NULL,
+    received_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (schedule_id, replay_key),
+    FOREIGN KEY (schedule_id) REFERENCES schedules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_received ON webhook_deliveries(received_at);
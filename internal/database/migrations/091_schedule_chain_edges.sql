@@ -0,0 +1,22 @@
+-- Chain steps can wait on more than one upstream step.
+--
+-- parent_step_id gave every step exactly one upstream, so a chain could fan
+-- out but never join: "summarize once both the research and the metrics steps
+-- are done" had no way to be written down. Each row here says step_id runs
+-- after depends_on_step_id; a step with no rows hangs off the root schedule.
+-- A step runs once every step it depends on has finished with an outcome its
+-- run_on accepts. parent_step_id is no longer read; existing parents are
+-- carried over as edges.
+CREATE TABLE IF NOT EXISTS schedule_chain_edges (
+    step_id            TEXT NOT NULL,
+    depends_on_step_id TEXT NOT NULL,
+    PRIMARY KEY (step_id, depends_on_step_id),
+    FOREIGN KEY (step_id) REFERENCES schedule_chain_steps(id) ON DELETE CASCADE,
+    FOREIGN KEY (depends_on_step_id) REFERENCES schedule_chain_steps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_chain_edges_depends_on ON schedule_chain_edges(depends_on_step_id);
+
+INSERT OR IGNORE INTO schedule_chain_edges (step_id, depends_on_step_id)
+SELECT s.id, s.parent_step_id FROM schedule_chain_steps s
+WHERE s.parent_step_id != '' AND EXISTS (SELECT 1 FROM schedule_chain_steps p WHERE p.id = s.parent_step_id);
@@ -0,0 +1,58 @@
+package database
+
+import "github.com/openpaw/openpaw/internal/auth"
+
+// UserExists reports whether a user account exists.
+func (db *DB) UserExists(userID string) bool {
+	var one int
+	return db.QueryRow("SELECT 1 FROM users WHERE id = ?", userID).Scan(&one) == nil
+}
+
+// UserRole returns a user's account-wide role, or "" when the user doesn't
+// exist.
+func (db *DB) UserRole(userID string) string {
+	var role string
+	if err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role); err != nil {
+		return ""
+	}
+	return role
+}
+
+// WorkspaceRole returns the role a user has inside a workspace, or "" when
+// they have no access to it. Admins have admin everywhere; everyone else
+// needs a membership and gets the lower of their account and membership roles.
+func (db *DB) WorkspaceRole(userID, workspaceID string) string {
+	role := db.UserRole(userID)
+	if role == "" || role == auth.RoleAdmin {
+		return role
+	}
+	var member string
+	if err := db.QueryRow(
+		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, userID,
+	).Scan(&member); err != nil {
+		return ""
+	}
+	return auth.LowerRole(role, member)
+}
+
+// CountAdmins returns how many admin accounts exist, so the last one can't be
+// demoted or removed.
+func (db *DB) CountAdmins() int {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", auth.RoleAdmin).Scan(&n)
+	return n
+}
+
+// UserWorkspaceID returns the workspace a user last switched to. It falls
+// back to the instance's active workspace when they never have, or when that
+// workspace has since been deleted or they've lost access to it.
+func (db *DB) UserWorkspaceID(userID string) string {
+	var id string
+	db.QueryRow(
+		"SELECT u.active_workspace_id FROM users u JOIN workspaces w ON w.id = u.active_workspace_id WHERE u.id = ?", userID,
+	).Scan(&id)
+	if id == "" || db.WorkspaceRole(userID, id) == "" {
+		return db.ActiveWorkspaceID()
+	}
+	return id
+}
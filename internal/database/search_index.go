@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+// Full-text indexes: chat message content, thread titles, work-order text and
+// context-document passages. They are external-content FTS5 tables kept in
+// step by triggers, so they cost no extra copy of the text.
+//
+// FTS5 needs the fts5 build tag on go-sqlite3, which release builds set but a
+// plain `go build` doesn't, so the indexes live here rather than in a
+// migration. Without FTS5 the triggers are dropped — a trigger naming a
+// missing module would fail every insert — and search falls back to LIKE.
+// When FTS5 comes back the triggers are recreated and the indexes rebuilt,
+// since they missed everything written in between.
+type ftsIndex struct {
+	table   string
+	columns []string
+}
+
+func (i ftsIndex) name() string { return i.table + "_fts" }
+
+var ftsIndexes = []ftsIndex{
+	{"chat_messages", []string{"content"}},
+	{"chat_threads", []string{"title"}},
+	{"work_orders", []string{"title", "description", "result"}},
+	{"context_chunks", []string{"heading", "content"}},
+}
+
+func (i ftsIndex) tableSQL() string {
+	return fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(
+    %s,
+    content=%s, content_rowid=rowid,
+    tokenize='porter unicode61'
+);`, i.name(), strings.Join(i.columns, ", "), i.table)
+}
+
+func (i ftsIndex) triggerSQL() string {
+	cols := strings.Join(i.columns, ", ")
+	newVals := "new." + strings.Join(i.columns, ", new.")
+	oldVals := "old." + strings.Join(i.columns, ", old.")
+	insert := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", i.name(), cols, newVals)
+	remove := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", i.name(), i.name(), cols, oldVals)
+	return fmt.Sprintf(`
+CREATE TRIGGER IF NOT EXISTS %[1]s_ai AFTER INSERT ON %[2]s BEGIN
+    %[3]s
+END;
+CREATE TRIGGER IF NOT EXISTS %[1]s_ad AFTER DELETE ON %[2]s BEGIN
+    %[4]s
+END;
+CREATE TRIGGER IF NOT EXISTS %[1]s_au AFTER UPDATE OF %[5]s ON %[2]s BEGIN
+    %[4]s
+    %[3]s
+END;
+`, i.name(), i.table, insert, remove, cols)
+}
+
+func (i ftsIndex) triggers() []string {
+	return []string{i.name() + "_ai", i.name() + "_ad", i.name() + "_au"}
+}
+
+// HasFTS reports whether the full-text indexes are live.
+func (db *DB) HasFTS() bool { return db.fts }
+
+func (db *DB) setupSearchIndexes() error {
+	var enabled int
+	_ = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if enabled != 1 {
+		for _, index := range ftsIndexes {
+			for _, name := range index.triggers() {
+				if _, err := db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+					return fmt.Errorf("drop search trigger: %w", err)
+				}
+			}
+		}
+		logger.Warn("SQLite was built without FTS5; search falls back to substring matching")
+		return nil
+	}
+
+	for _, index := range ftsIndexes {
+		var existing int
+		_ = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", index.name()+"_ai").Scan(&existing)
+		if _, err := db.Exec(index.tableSQL()); err != nil {
+			return fmt.Errorf("create %s: %w", index.name(), err)
+		}
+		if _, err := db.Exec(index.triggerSQL()); err != nil {
+			return fmt.Errorf("create %s triggers: %w", index.name(), err)
+		}
+		if existing == 0 {
+			if _, err := db.Exec(fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", index.name(), index.name())); err != nil {
+				return fmt.Errorf("build %s: %w", index.name(), err)
+			}
+		}
+	}
+	db.fts = true
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package docconvert turns documents people drop into the context library —
+// Word and OpenDocument files, web pages, e-books, spreadsheets, PDFs and
+// images — into Markdown an agent can read and search. Everything except PDF
+// text extraction and the OCR fallback is pure Go, so conversion works on a
+// bare install.
+package docconvert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupported is returned for a format with no converter, or one whose
+// converter needs a tool or service that isn't available.
+var ErrUnsupported = errors.New("no converter for this file type")
+
+// maxEntryBytes caps how much of any one archive member is decompressed, so a
+// zip bomb disguised as a .docx can't exhaust memory.
+const maxEntryBytes = 64 << 20
+
+// Describer turns an image, given as a data: URI, into text. The server backs
+// it with the vision model.
+type Describer func(ctx context.Context, imageDataURI string) (string, error)
+
+// Options carries the services some converters need.
+type Options struct {
+	// Describe handles images. Nil falls back to tesseract OCR when it is
+	// installed.
+	Describe Describer
+}
+
+type converter func(ctx context.Context, name string, data []byte, opts Options) (string, error)
+
+var converters = map[string]converter{
+	".docx":  bytesOnly(convertDOCX),
+	".odt":   bytesOnly(convertODT),
+	".html":  bytesOnly(htmlText),
+	".htm":   bytesOnly(htmlText),
+	".xhtml": bytesOnly(htmlText),
+	".epub":  bytesOnly(convertEPUB),
+	".xlsx":  bytesOnly(convertXLSX),
+	".csv":   bytesOnly(func(data []byte) (string, error) { return convertCSV(data, ',') }),
+	".tsv":   bytesOnly(func(data []byte) (string, error) { return convertCSV(data, '\t') }),
+	".pdf":   convertPDF,
+	".png":   convertImage,
+	".jpg":   convertImage,
+	".jpeg":  convertImage,
+	".gif":   convertImage,
+	".webp":  convertImage,
+}
+
+// bytesOnly adapts a converter that needs nothing but the file's bytes.
+func bytesOnly(fn func(data []byte) (string, error)) converter {
+	return func(_ context.Context, _ string, data []byte, _ Options) (string, error) {
+		return fn(data)
+	}
+}
+
+func htmlText(data []byte) (string, error) { return convertHTML(data), nil }
+
+// Supported reports whether Convert has a converter for a file name.
+func Supported(name string) bool {
+	_, ok := converters[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// Convert extracts a file's content as Markdown. The format is chosen by the
+// file name's extension. An empty result with a nil error means the file had
+// no text worth keeping.
+func Convert(ctx context.Context, name string, data []byte, opts Options) (string, error) {
+	conv, ok := converters[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	text, err := conv(ctx, name, data, opts)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(collapseBlankLines(text)) + "\n", nil
+}
+
+// openZip opens an in-memory archive (DOCX, ODT, EPUB and XLSX are all zips).
+func openZip(data []byte) (*zip.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid document archive: %w", err)
+	}
+	return zr, nil
+}
+
+// readZipEntry returns one member of an archive, or nil if it isn't there.
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxEntryBytes))
+	}
+	return nil, nil
+}
+
+// collapseBlankLines squeezes runs of blank lines to one.
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
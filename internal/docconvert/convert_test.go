@@ -0,0 +1,146 @@
+package docconvert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func convert(t *testing.T, name string, data []byte, opts Options) string {
+	t.Helper()
+	out, err := Convert(context.Background(), name, data, opts)
+	if err != nil {
+		t.Fatalf("convert %s: %v", name, err)
+	}
+	return out
+}
+
+func wantAll(t *testing.T, label, got string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("%s: missing %q in:\n%s", label, w, got)
+		}
+	}
+}
+
+func TestConvertDOCX(t *testing.T) {
+	const w = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	doc := `<w:document ` + w + `><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Refund policy</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Refunds within </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>30 days</w:t></w:r><w:r><w:t>.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Keep the receipt</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Plan</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Days</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>Pro</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>60</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`
+	got := convert(t, "policy.docx", zipOf(t, map[string]string{"word/document.xml": doc}), Options{})
+	wantAll(t, "docx", got, "# Refund policy", "Refunds within **30 days**.", "- Keep the receipt", "| Plan | Days |", "| Pro | 60 |")
+}
+
+func TestConvertODT(t *testing.T) {
+	content := `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"><office:body><office:text>
+<text:h text:outline-level="2">Setup</text:h>
+<text:p>Install<text:s text:c="2"/>first.</text:p>
+<text:list><text:list-item><text:p>Step one</text:p></text:list-item></text:list>
+<table:table><table:table-row><table:table-cell><text:p>A</text:p></table:table-cell><table:table-cell><text:p>B</text:p></table:table-cell></table:table-row></table:table>
+</office:text></office:body></office:document-content>`
+	got := convert(t, "setup.odt", zipOf(t, map[string]string{"content.xml": content}), Options{})
+	wantAll(t, "odt", got, "## Setup", "Install  first.", "- Step one", "| A | B |")
+}
+
+func TestConvertHTML(t *testing.T) {
+	page := `<!DOCTYPE html><html><head><title>x</title><style>p{}</style></head><body>
+<nav><a href="/">Home</a></nav>
+<h1>Release notes</h1>
+<p>Version <b>2.0</b> adds <a href="https://example.com/sync">sync</a> &amp; search.<br>
+<script>if (a < b) { alert(1) }</script>
+<ul><li>Faster startup<li>Dark mode</ul>
+<table><tr><th>OS</th><th>Status</th></tr><tr><td>Linux</td><td>Supported</td></tr></table>
+<pre>go build ./...</pre>
+</body></html>`
+	got := convert(t, "notes.html", []byte(page), Options{})
+	wantAll(t, "html", got, "# Release notes", "Version **2.0** adds [sync](https://example.com/sync) & search.", "- Faster startup", "- Dark mode", "| OS | Status |", "```\ngo build ./...\n```")
+	if strings.Contains(got, "Home") || strings.Contains(got, "alert") {
+		t.Errorf("navigation or script leaked into:\n%s", got)
+	}
+}
+
+func TestConvertEPUB(t *testing.T) {
+	book := zipOf(t, map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/book.opf"/></rootfiles></container>`,
+		"OEBPS/book.opf": `<package xmlns:dc="http://purl.org/dc/elements/1.1/"><metadata><dc:title>Field Guide</dc:title></metadata>
+<manifest><item id="c2" href="ch%202.xhtml" media-type="application/xhtml+xml"/><item id="c1" href="ch1.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`,
+		"OEBPS/ch1.xhtml":  `<html><body><h2>Birds</h2><p>Robins sing at dawn.</p></body></html>`,
+		"OEBPS/ch 2.xhtml": `<html><body><h2>Trees</h2><p>Oaks live long.</p></body></html>`,
+	})
+	got := convert(t, "guide.epub", book, Options{})
+	wantAll(t, "epub", got, "# Field Guide", "## Birds", "Robins sing at dawn.", "## Trees")
+	if strings.Index(got, "Birds") > strings.Index(got, "Trees") {
+		t.Errorf("chapters out of spine order:\n%s", got)
+	}
+}
+
+func TestConvertSpreadsheets(t *testing.T) {
+	book := zipOf(t, map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Budget" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>Item</t></si><si><t>Cost</t></si><si><r><t>Lap</t></r><r><t>top</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2" t="inlineStr"><is><t>note | here</t></is></c></row>
+<row r="3"><c r="A3" t="b"><v>1</v></c><c r="B3"><v>1200</v></c><c r="D3" s="1"/></row>
+</sheetData></worksheet>`,
+	})
+	got := convert(t, "budget.xlsx", book, Options{})
+	wantAll(t, "xlsx", got, "## Budget", "| Item | Cost |  |", "| Laptop |  | note \\| here |", "| TRUE | 1200 |  |")
+
+	csv := convert(t, "people.csv", []byte("\xef\xbb\xbfname,role\n\"Lee, A.\",admin\n"), Options{})
+	wantAll(t, "csv", csv, "| name | role |", "| Lee, A. | admin |")
+}
+
+func TestConvertImage(t *testing.T) {
+	var gotURI string
+	describe := func(ctx context.Context, uri string) (string, error) {
+		gotURI = uri
+		return "A bar chart of revenue by quarter.", nil
+	}
+	png := []byte("\x89PNG\r\n\x1a\n rest of image")
+	got := convert(t, "chart.png", png, Options{Describe: describe})
+	if !strings.HasPrefix(gotURI, "data:image/png;base64,") || !strings.Contains(got, "bar chart") {
+		t.Errorf("uri %q, text %q", gotURI, got)
+	}
+
+	// Without tesseract to fall back on, the vision error is what comes back.
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		failing := func(ctx context.Context, uri string) (string, error) { return "", errors.New("no vision model") }
+		if _, err := Convert(context.Background(), "chart.png", png, Options{Describe: failing}); err == nil || !strings.Contains(err.Error(), "no vision model") {
+			t.Errorf("failed description: %v", err)
+		}
+	}
+
+	if _, err := Convert(context.Background(), "archive.tar", nil, Options{}); err != ErrUnsupported {
+		t.Errorf("unknown extension: %v", err)
+	}
+}
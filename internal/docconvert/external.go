@@ -0,0 +1,86 @@
+package docconvert
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DescribePrompt asks a vision model for text worth indexing: the words in
+// the image first, since screenshots and scans are mostly text, then what a
+// reader couldn't get from the words alone.
+const DescribePrompt = "This image was added to a document library so it can be searched and quoted. " +
+	"First transcribe all visible text verbatim, keeping its reading order. " +
+	"Then describe what the image shows: for a chart, its axes, series and notable values; " +
+	"for a screenshot, the application and what is on screen; for a diagram, its parts and how they connect; " +
+	"for a photo, the subject. Reply in Markdown, with no preamble."
+
+// externalTimeout bounds one call to pdftotext, tesseract or the vision
+// model, so one stuck conversion can't hold an upload forever.
+const externalTimeout = 90 * time.Second
+
+// convertImage describes an image with the vision model, or OCRs it with
+// tesseract when no model is available or the call fails.
+func convertImage(ctx context.Context, name string, data []byte, opts Options) (string, error) {
+	var visionErr error
+	if opts.Describe != nil {
+		ctx, cancel := context.WithTimeout(ctx, externalTimeout)
+		defer cancel()
+		uri := "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+		text, err := opts.Describe(ctx, uri)
+		if err == nil && strings.TrimSpace(text) != "" {
+			return text, nil
+		}
+		visionErr = err
+	}
+	text, err := runTool(ctx, "tesseract", data, filepath.Ext(name), func(in string) []string {
+		return []string{in, "stdout"}
+	})
+	if err == ErrUnsupported && visionErr != nil {
+		return "", fmt.Errorf("describe image: %w", visionErr)
+	}
+	return text, err
+}
+
+// convertPDF extracts a PDF's text layer with pdftotext, keeping the page
+// layout so columns and tables stay readable. Scanned PDFs have no text
+// layer and come back empty.
+func convertPDF(ctx context.Context, _ string, data []byte, _ Options) (string, error) {
+	return runTool(ctx, "pdftotext", data, ".pdf", func(in string) []string {
+		return []string{"-layout", in, "-"}
+	})
+}
+
+// runTool writes data to a temporary file, runs tool with the arguments built
+// for that file and returns its standard output. ErrUnsupported means the
+// tool isn't installed.
+func runTool(ctx context.Context, tool string, data []byte, ext string, args func(in string) []string) (string, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	tmp, err := os.CreateTemp("", "openpaw-convert-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	tmp.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, externalTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args(tmp.Name())...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", tool, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,308 @@
+package docconvert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Script and style bodies are cut before parsing: their contents aren't
+	// markup, and a stray "<" in them would derail the tokenizer.
+	scriptBlock  = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	styleBlock   = regexp.MustCompile(`(?is)<style\b.*?</style\s*>`)
+	anyTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	headingLevel = map[string]int{"h1": 1, "h2": 2, "h3": 3, "h4": 4, "h5": 5, "h6": 6}
+)
+
+// skippedElements hold page furniture rather than content.
+var skippedElements = map[string]bool{
+	"head": true, "nav": true, "noscript": true, "svg": true, "template": true,
+	"iframe": true, "form": true, "button": true, "select": true,
+}
+
+// blockElements end the running paragraph.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "aside": true, "blockquote": true,
+	"figure": true, "figcaption": true, "dl": true, "dt": true, "dd": true,
+	"address": true, "hr": true,
+}
+
+// convertHTML turns a web page or XHTML chapter into Markdown: headings,
+// paragraphs, lists, links, bold and italic, preformatted blocks and tables.
+// HTML in the wild is rarely well-formed, so the standard library's XML
+// tokenizer runs in its forgiving mode; if it still gives up, the rest of the
+// page is kept as plain text.
+func convertHTML(data []byte) string {
+	src := scriptBlock.ReplaceAll(data, nil)
+	src = styleBlock.ReplaceAll(src, nil)
+
+	var (
+		md         markdown
+		inline     strings.Builder
+		heading    int
+		listDepth  int
+		inItem     bool
+		pre        int
+		skip       int
+		tableDepth int
+		rows       [][]string
+		cell       strings.Builder
+		inCell     bool
+		links      []linkStart
+	)
+	flush := func() {
+		text := inline.String()
+		inline.Reset()
+		switch {
+		case heading > 0:
+			md.heading(heading, text)
+		case inItem:
+			md.item(listDepth-1, text)
+		default:
+			md.paragraph(oneLine(text))
+		}
+	}
+	out := func() *strings.Builder {
+		if inCell {
+			return &cell
+		}
+		return &inline
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Keep what was converted and salvage the rest as text.
+			rest := src[min(int(d.InputOffset()), len(src)):]
+			inline.WriteString(" " + string(anyTag.ReplaceAll(rest, []byte(" "))))
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if skip > 0 || skippedElements[name] {
+				skip++
+				continue
+			}
+			switch {
+			case headingLevel[name] > 0:
+				flush()
+				heading = headingLevel[name]
+			case blockElements[name]:
+				if !inCell {
+					flush()
+				}
+			case name == "ul" || name == "ol":
+				flush()
+				listDepth++
+			case name == "li":
+				flush()
+				inItem = listDepth > 0
+			case name == "br":
+				out().WriteString("\n")
+			case name == "pre":
+				flush()
+				pre++
+			case name == "code" && pre == 0:
+				out().WriteString("`")
+			case name == "strong" || name == "b":
+				out().WriteString("**")
+			case name == "em" || name == "i":
+				out().WriteString("_")
+			case name == "a":
+				links = append(links, linkStart{href: attr(t, "href"), at: out().Len(), cell: inCell})
+			case name == "img":
+				if alt := strings.TrimSpace(attr(t, "alt")); alt != "" {
+					out().WriteString("[image: " + alt + "]")
+				}
+			case name == "table":
+				if tableDepth++; tableDepth == 1 {
+					flush()
+					rows = nil
+				}
+			case name == "tr":
+				if tableDepth == 1 {
+					rows = append(rows, nil)
+				}
+			case name == "td" || name == "th":
+				if tableDepth == 1 {
+					cell.Reset()
+					inCell = true
+				}
+			}
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if pre > 0 {
+				inline.Write(t)
+			} else {
+				out().WriteString(collapseSpace(string(t)))
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch {
+			case headingLevel[name] > 0:
+				flush()
+				heading = 0
+			case blockElements[name]:
+				if !inCell {
+					flush()
+				}
+			case name == "ul" || name == "ol":
+				flush()
+				if listDepth > 0 {
+					listDepth--
+				}
+				inItem = listDepth > 0
+			case name == "li":
+				flush()
+			case name == "pre":
+				if pre > 0 {
+					pre--
+				}
+				md.code(inline.String())
+				inline.Reset()
+			case name == "code" && pre == 0:
+				out().WriteString("`")
+			case name == "strong" || name == "b":
+				out().WriteString("**")
+			case name == "em" || name == "i":
+				out().WriteString("_")
+			case name == "a" && len(links) > 0:
+				link := links[len(links)-1]
+				links = links[:len(links)-1]
+				if b := out(); link.cell == inCell && link.at <= b.Len() {
+					wrapLink(b, link)
+				}
+			case (name == "td" || name == "th") && tableDepth == 1 && inCell:
+				inCell = false
+				if len(rows) > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], cell.String())
+				}
+			case name == "table" && tableDepth > 0:
+				if tableDepth--; tableDepth == 0 {
+					md.table(rows)
+				}
+			}
+		}
+	}
+	flush()
+	return md.String()
+}
+
+type linkStart struct {
+	href string
+	at   int
+	cell bool
+}
+
+// wrapLink turns the text written since a link opened into [text](href).
+// In-page anchors and script links are left as plain text.
+func wrapLink(b *strings.Builder, link linkStart) {
+	href := strings.TrimSpace(link.href)
+	s := b.String()
+	text := strings.TrimSpace(s[link.at:])
+	if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	b.Reset()
+	b.WriteString(s[:link.at] + " [" + text + "](" + href + ") ")
+}
+
+func collapseSpace(s string) string {
+	if strings.TrimSpace(s) == "" {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(strings.Fields(s), " ")
+	if first := s[0]; first == ' ' || first == '\n' || first == '\t' || first == '\r' {
+		out = " " + out
+	}
+	if last := s[len(s)-1]; last == ' ' || last == '\n' || last == '\t' || last == '\r' {
+		out += " "
+	}
+	return out
+}
+
+// convertEPUB reads the book's reading order from its package document and
+// converts each chapter in turn, under the book's title.
+func convertEPUB(data []byte) (string, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	container, _ := readZipEntry(zr, "META-INF/container.xml")
+	var opfPath string
+	eachElement(container, "rootfile", func(el xml.StartElement) {
+		if opfPath == "" {
+			opfPath = attr(el, "full-path")
+		}
+	})
+	opf, _ := readZipEntry(zr, opfPath)
+	if opf == nil {
+		return "", fmt.Errorf("not an EPUB: no package document")
+	}
+
+	var pkg struct {
+		Title    []string `xml:"metadata>title"`
+		Manifest []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return "", fmt.Errorf("read EPUB package: %w", err)
+	}
+	hrefs := map[string]string{}
+	for _, item := range pkg.Manifest {
+		if strings.Contains(item.MediaType, "html") {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	var b strings.Builder
+	if len(pkg.Title) > 0 && strings.TrimSpace(pkg.Title[0]) != "" {
+		b.WriteString("# " + oneLine(pkg.Title[0]) + "\n\n")
+	}
+	base := path.Dir(opfPath)
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapter, _ := readZipEntry(zr, path.Join(base, href))
+		if chapter == nil {
+			continue
+		}
+		b.WriteString(convertHTML(chapter) + "\n")
+	}
+	return b.String(), nil
+}
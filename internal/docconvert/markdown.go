@@ -0,0 +1,106 @@
+package docconvert
+
+import "strings"
+
+// markdown accumulates converted blocks. Converters feed it headings,
+// paragraphs, list items and tables as they walk a document.
+type markdown struct {
+	b      strings.Builder
+	inList bool
+}
+
+// block ends a list in progress, so the block that follows isn't read as a
+// continuation of its last item.
+func (m *markdown) block() {
+	if m.inList {
+		m.b.WriteString("\n")
+		m.inList = false
+	}
+}
+
+func (m *markdown) heading(level int, text string) {
+	text = oneLine(text)
+	if text == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	m.block()
+	m.b.WriteString(strings.Repeat("#", level) + " " + text + "\n\n")
+}
+
+func (m *markdown) paragraph(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		m.block()
+		m.b.WriteString(text + "\n\n")
+	}
+}
+
+// item writes a list item. Consecutive items form one list, so no blank line
+// follows until the next block.
+func (m *markdown) item(depth int, text string) {
+	if text = oneLine(text); text == "" {
+		return
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	m.b.WriteString(strings.Repeat("  ", depth) + "- " + text + "\n")
+	m.inList = true
+}
+
+func (m *markdown) code(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	m.block()
+	m.b.WriteString("\n```\n" + strings.Trim(text, "\n") + "\n```\n\n")
+}
+
+// table writes rows as a Markdown table, the first row as its header. Rows
+// are padded to the widest one.
+func (m *markdown) table(rows [][]string) {
+	m.block()
+	m.b.WriteString(markdownTable(rows))
+}
+
+func (m *markdown) String() string { return m.b.String() }
+
+func markdownTable(rows [][]string) string {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	if width == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n")
+	for i, row := range rows {
+		b.WriteString("|")
+		for c := 0; c < width; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = strings.ReplaceAll(oneLine(row[c]), "|", `\|`)
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// oneLine collapses all whitespace, newlines included, to single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,266 @@
+package docconvert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// convertDOCX reads word/document.xml: paragraphs, headings (from the
+// Heading n and Title styles), list items, bold runs and tables.
+func convertDOCX(data []byte) (string, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	doc, err := readZipEntry(zr, "word/document.xml")
+	if err != nil || doc == nil {
+		return "", fmt.Errorf("not a Word document: no word/document.xml")
+	}
+
+	var (
+		md         markdown
+		para, run  strings.Builder
+		style      string
+		listLevel  = -1
+		bold       bool
+		inText     bool
+		tableDepth int
+		rows       [][]string
+		cell       []string
+	)
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read word/document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style, listLevel = "", -1
+			case "pStyle":
+				style = attr(t, "val")
+			case "numPr":
+				listLevel = 0
+			case "ilvl":
+				listLevel, _ = strconv.Atoi(attr(t, "val"))
+			case "r":
+				run.Reset()
+				bold = false
+			case "b":
+				bold = attr(t, "val") != "0" && attr(t, "val") != "false"
+			case "t":
+				inText = true
+			case "tab":
+				run.WriteString("\t")
+			case "br", "cr":
+				run.WriteString("\n")
+			case "tbl":
+				if tableDepth++; tableDepth == 1 {
+					rows = nil
+				}
+			case "tr":
+				if tableDepth == 1 {
+					rows = append(rows, nil)
+				}
+			case "tc":
+				if tableDepth == 1 {
+					cell = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				run.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "r":
+				para.WriteString(strong(run.String(), bold && wordHeadingLevel(style) == 0))
+			case "p":
+				text := strings.ReplaceAll(para.String(), "****", "")
+				switch {
+				case tableDepth > 0:
+					cell = append(cell, text)
+				case wordHeadingLevel(style) > 0:
+					md.heading(wordHeadingLevel(style), text)
+				case listLevel >= 0:
+					md.item(listLevel, text)
+				default:
+					md.paragraph(text)
+				}
+			case "tc":
+				if tableDepth == 1 && len(rows) > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], strings.Join(cell, " "))
+				}
+			case "tbl":
+				if tableDepth--; tableDepth == 0 {
+					md.table(rows)
+				}
+			}
+		}
+	}
+	return md.String(), nil
+}
+
+// wordHeadingLevel maps a paragraph style ID to a heading level, or 0.
+func wordHeadingLevel(style string) int {
+	s := strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	switch {
+	case s == "title":
+		return 1
+	case s == "subtitle":
+		return 2
+	case strings.HasPrefix(s, "heading"):
+		if n, err := strconv.Atoi(s[len("heading"):]); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// convertODT reads content.xml of an OpenDocument text file: headings
+// (text:h with their outline level), paragraphs, nested lists and tables.
+func convertODT(data []byte) (string, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	content, err := readZipEntry(zr, "content.xml")
+	if err != nil || content == nil {
+		return "", fmt.Errorf("not an OpenDocument file: no content.xml")
+	}
+
+	var (
+		md         markdown
+		para       strings.Builder
+		inPara     int
+		level      int
+		listDepth  int
+		skip       int
+		tableDepth int
+		rows       [][]string
+		cell       []string
+	)
+	d := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read content.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "annotation", "tracked-changes":
+				skip = 1
+			case "h", "p":
+				if inPara == 0 {
+					para.Reset()
+					level = 0
+					if t.Name.Local == "h" {
+						level, _ = strconv.Atoi(attr(t, "outline-level"))
+						if level == 0 {
+							level = 1
+						}
+					}
+				}
+				inPara++
+			case "list":
+				listDepth++
+			case "s":
+				n, _ := strconv.Atoi(attr(t, "c"))
+				para.WriteString(strings.Repeat(" ", max(n, 1)))
+			case "tab":
+				para.WriteString("\t")
+			case "line-break":
+				para.WriteString("\n")
+			case "table":
+				if tableDepth++; tableDepth == 1 {
+					rows = nil
+				}
+			case "table-row":
+				if tableDepth == 1 {
+					rows = append(rows, nil)
+				}
+			case "table-cell":
+				if tableDepth == 1 {
+					cell = nil
+				}
+			}
+		case xml.CharData:
+			if skip == 0 && inPara > 0 {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "h", "p":
+				if inPara--; inPara > 0 {
+					continue
+				}
+				text := para.String()
+				switch {
+				case tableDepth > 0:
+					cell = append(cell, text)
+				case level > 0:
+					md.heading(level, text)
+				case listDepth > 0:
+					md.item(listDepth-1, text)
+				default:
+					md.paragraph(text)
+				}
+			case "list":
+				listDepth--
+			case "table-cell":
+				if tableDepth == 1 && len(rows) > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], strings.Join(cell, " "))
+				}
+			case "table":
+				if tableDepth--; tableDepth == 0 {
+					md.table(rows)
+				}
+			}
+		}
+	}
+	return md.String(), nil
+}
+
+// strong wraps text in bold markers, keeping surrounding spaces outside them.
+func strong(text string, bold bool) string {
+	trimmed := strings.TrimSpace(text)
+	if !bold || trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + "**" + trimmed + "**" + text[start+len(trimmed):]
+}
+
+// attr returns an attribute's value by local name, ignoring its namespace.
+func attr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
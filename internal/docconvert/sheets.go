@@ -0,0 +1,237 @@
+package docconvert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSheetRows and maxSheetCols bound one sheet's table. Past that the
+	// table stops being readable anyway; the note says how much was left out.
+	maxSheetRows = 1000
+	maxSheetCols = 50
+)
+
+// convertXLSX writes each worksheet as a section with a Markdown table.
+// Cells are shown as stored: shared and inline strings resolve to their text,
+// numbers (including dates, which Excel stores as serial numbers) as written.
+func convertXLSX(data []byte) (string, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return "", err
+	}
+	workbook, err := readZipEntry(zr, "xl/workbook.xml")
+	if err != nil || workbook == nil {
+		return "", fmt.Errorf("not an Excel workbook: no xl/workbook.xml")
+	}
+	rels, _ := readZipEntry(zr, "xl/_rels/workbook.xml.rels")
+	targets := map[string]string{}
+	eachElement(rels, "Relationship", func(el xml.StartElement) {
+		target := attr(el, "Target")
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[attr(el, "Id")] = target
+	})
+	table, _ := readZipEntry(zr, "xl/sharedStrings.xml")
+	shared := sharedStrings(table)
+
+	var md markdown
+	eachElement(workbook, "sheet", func(el xml.StartElement) {
+		name := attr(el, "name")
+		sheet, _ := readZipEntry(zr, targets[attr(el, "id")])
+		if sheet == nil {
+			return
+		}
+		rows, more := sheetRows(sheet, shared)
+		if len(rows) == 0 {
+			return
+		}
+		md.heading(2, name)
+		md.table(rows)
+		if more > 0 {
+			md.paragraph(fmt.Sprintf("_(%d more rows not shown)_", more))
+		}
+	})
+	return md.String(), nil
+}
+
+// sharedStrings reads the workbook's string table. Phonetic guides (rPh) are
+// left out.
+func sharedStrings(data []byte) []string {
+	var out []string
+	var cur strings.Builder
+	inText, skip := false, 0
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return out
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "rPh":
+				skip++
+			case "t":
+				inText = skip == 0
+			}
+		case xml.CharData:
+			if inText {
+				cur.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				out = append(out, cur.String())
+			case "rPh":
+				skip--
+			case "t":
+				inText = false
+			}
+		}
+	}
+}
+
+// sheetRows reads a worksheet into a grid, dropping empty rows and trailing
+// empty columns, and returns how many rows were cut by maxSheetRows.
+func sheetRows(sheet []byte, shared []string) ([][]string, int) {
+	var (
+		rows     [][]string
+		row      []string
+		col      int
+		cellType string
+		value    strings.Builder
+		inValue  bool
+		more     int
+	)
+	d := xml.NewDecoder(bytes.NewReader(sheet))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row, col = nil, 0
+			case "c":
+				if ref := attr(t, "r"); ref != "" {
+					col = columnIndex(ref)
+				}
+				cellType = attr(t, "t")
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text := value.String()
+				switch cellType {
+				case "s":
+					if i, err := strconv.Atoi(text); err == nil && i >= 0 && i < len(shared) {
+						text = shared[i]
+					}
+				case "b":
+					text = map[string]string{"1": "TRUE", "0": "FALSE"}[text]
+				}
+				if col < maxSheetCols {
+					for len(row) <= col {
+						row = append(row, "")
+					}
+					row[col] = text
+				}
+				col++
+			case "row":
+				for len(row) > 0 && strings.TrimSpace(row[len(row)-1]) == "" {
+					row = row[:len(row)-1]
+				}
+				if len(row) == 0 {
+					continue
+				}
+				if len(rows) >= maxSheetRows {
+					more++
+					continue
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, more
+}
+
+// columnIndex turns a cell reference such as "AB12" into a zero-based column.
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
+
+// convertCSV renders delimited text as one Markdown table.
+func convertCSV(data []byte, comma rune) (string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var rows [][]string
+	more := 0
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read CSV: %w", err)
+		}
+		if len(rows) >= maxSheetRows {
+			more++
+			continue
+		}
+		if len(rec) > maxSheetCols {
+			rec = rec[:maxSheetCols]
+		}
+		rows = append(rows, rec)
+	}
+	out := markdownTable(rows)
+	if more > 0 {
+		out += fmt.Sprintf("_(%d more rows not shown)_\n", more)
+	}
+	return out, nil
+}
+
+// eachElement calls fn for every element with the given local name.
+func eachElement(data []byte, local string, fn func(xml.StartElement)) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return
+		}
+		if el, ok := tok.(xml.StartElement); ok && el.Name.Local == local {
+			fn(el)
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/memory"
 	"github.com/openpaw/openpaw/internal/models"
@@ -56,7 +58,10 @@ type RunStats struct {
 	MemoriesAdded   int    `json:"memories_added"`
 	MemoriesUpdated int    `json:"memories_updated"`
 	MemoriesPruned  int    `json:"memories_pruned"`
-	Summary         string `json:"summary"`
+	// Proposed counts changes queued for review instead of made, in
+	// propose-only mode.
+	Proposed int    `json:"proposed"`
+	Summary  string `json:"summary"`
 }
 
 // dreamAll runs a dream for every eligible agent, one after another.
@@ -96,6 +101,7 @@ func (m *Manager) dreamAll() {
 		}
 		m.dreamAgent(ctx, slug)
 	}
+	m.promoteShared(ctx)
 	logger.Success("Dreaming finished")
 }
 
@@ -134,12 +140,16 @@ func (m *Manager) dreamAgent(ctx context.Context, slug string) {
 
 	runID := uuid.New().String()
 	startedAt := time.Now().UTC()
+	mode := modeApply
+	if cfg.ProposeOnly {
+		mode = modePropose
+	}
 	m.db.Exec(
-		"INSERT INTO dream_runs (id, agent_slug, status, started_at) VALUES (?, ?, 'running', ?)",
-		runID, slug, startedAt,
+		"INSERT INTO dream_runs (id, agent_slug, status, mode, started_at) VALUES (?, ?, 'running', ?, ?)",
+		runID, slug, mode, startedAt,
 	)
 
-	stats, err := m.runDream(ctx, slug, cfg)
+	stats, changes, err := m.runDream(ctx, runID, slug, cfg)
 	finishedAt := time.Now().UTC()
 
 	status, errStr := "success", ""
@@ -162,17 +172,20 @@ func (m *Manager) dreamAgent(ctx context.Context, slug string) {
 		})
 	}
 
-	m.fileReport(notify, slug, runID, status, errStr, stats)
+	m.fileReport(notify, slug, runID, status, errStr, stats, changes)
 }
 
-func (m *Manager) runDream(ctx context.Context, slug string, cfg Config) (RunStats, error) {
+// runDream reads the agent's new chats and consolidates its memory. The
+// changes are made and journalled under runID, or in propose-only mode only
+// journalled, for review.
+func (m *Manager) runDream(ctx context.Context, runID, slug string, cfg Config) (RunStats, []change, error) {
 	stats := RunStats{AgentSlug: slug}
 
 	m.mem.EnsureMigrated(slug)
 
 	threads, err := m.unscannedThreads(slug, cfg.MaxThreads)
 	if err != nil {
-		return stats, fmt.Errorf("find unscanned chats: %w", err)
+		return stats, nil, fmt.Errorf("find unscanned chats: %w", err)
 	}
 
 	var facts []memory.Record
@@ -196,22 +209,39 @@ func (m *Manager) runDream(ctx context.Context, slug string, cfg Config) (RunSta
 
 	existing, err := m.mem.Recent(slug, cfg.ReviewLimit)
 	if err != nil {
-		return stats, fmt.Errorf("read existing memories: %w", err)
+		return stats, nil, fmt.Errorf("read existing memories: %w", err)
 	}
 
 	// Nothing new and nothing stored is not a failure — it is an agent that
 	// hasn't been used since the last dream.
 	if len(facts) == 0 && len(existing) == 0 {
 		stats.Summary = "Nothing new to review."
-		return stats, nil
+		return stats, nil, nil
 	}
 
 	ops, err := m.consolidate(ctx, slug, facts, existing)
 	if err != nil {
-		return stats, err
+		return stats, nil, err
+	}
+
+	// In propose-only mode nothing is written until someone approves it in
+	// the review queue. The chats still count as read: the facts in them are
+	// in the proposals, and re-reading them nightly until the queue is
+	// cleared would only propose the same changes again.
+	if cfg.ProposeOnly {
+		changes := m.planOps(slug, ops, existing)
+		m.journal(runID, slug, opProposed, changes)
+		stats.Proposed = len(changes)
+		stats.Summary = strings.TrimSpace(ops.Summary)
+		if stats.Summary == "" {
+			stats.Summary = fmt.Sprintf("Reviewed %d chat(s) and %d memory(s).", stats.ThreadsScanned, len(existing))
+		}
+		return stats, changes, nil
 	}
 
-	added, updated, pruned := m.applyOps(slug, ops, existing)
+	changes := m.applyOps(slug, ops, existing)
+	m.journal(runID, slug, opApplied, changes)
+	added, updated, pruned := counts(changes)
 	stats.MemoriesAdded, stats.MemoriesUpdated, stats.MemoriesPruned = added, updated, pruned
 	stats.Summary = strings.TrimSpace(ops.Summary)
 	if stats.Summary == "" {
@@ -228,7 +258,7 @@ func (m *Manager) runDream(ctx context.Context, slug string, cfg Config) (RunSta
 		})
 	}
 
-	return stats, nil
+	return stats, changes, nil
 }
 
 // threadRef is a conversation eligible for scanning.
@@ -342,7 +372,7 @@ func (m *Manager) scanThread(ctx context.Context, slug string, t threadRef) ([]m
 func (m *Manager) transcript(threadID string) (string, error) {
 	rows, err := m.db.Query(
 		`SELECT role, COALESCE(agent_role_slug, ''), content
-		 FROM chat_messages WHERE thread_id = ? ORDER BY created_at ASC`,
+		 FROM chat_messages WHERE id IN (`+database.ActiveBranch+`) ORDER BY created_at ASC`,
 		threadID,
 	)
 	if err != nil {
@@ -421,8 +451,8 @@ func (m *Manager) consolidate(ctx context.Context, slug string, facts, existing
 	if len(facts) == 0 {
 		prompt.WriteString("_(none — no new conversations since the last review)_\n")
 	}
-	for _, f := range facts {
-		fmt.Fprintf(&prompt, "- [%s|importance %d] %s\n", f.Category, f.Importance, singleLine(f.Content))
+	for i, f := range facts {
+		fmt.Fprintf(&prompt, "- fact %d [%s|importance %d] %s\n", i+1, f.Category, f.Importance, singleLine(f.Content))
 	}
 
 	prompt.WriteString("\n## EXISTING MEMORIES\n\n")
@@ -432,6 +462,7 @@ func (m *Manager) consolidate(ctx context.Context, slug string, facts, existing
 	for _, e := range existing {
 		fmt.Fprintf(&prompt, "- id=%s [%s|importance %d] %s\n", e.ID, e.Category, e.Importance, singleLine(e.Content))
 	}
+	writeMergeCandidates(&prompt, m.mergeCandidates(ctx, slug, facts, existing))
 	prompt.WriteString("\nConsolidate. Use only ids listed above.")
 
 	callCtx, cancel := context.WithTimeout(ctx, consolidateTimeout)
@@ -447,19 +478,51 @@ func (m *Manager) consolidate(ctx context.Context, slug string, facts, existing
 	return ops, nil
 }
 
-// applyOps writes the review's verdict to the memory database, enforcing the
-// limits the prompt only asks for.
+// mergeCandidates asks memory for pairs that look alike by embedding. Empty
+// when semantic search isn't configured.
+func (m *Manager) mergeCandidates(ctx context.Context, slug string, facts, existing []memory.Record) []memory.MergeCandidate {
+	texts := make([]string, len(facts))
+	for i, f := range facts {
+		texts[i] = f.Content
+	}
+	ids := make([]string, len(existing))
+	for i, e := range existing {
+		ids[i] = e.ID
+	}
+	return m.mem.MergeCandidates(ctx, slug, texts, ids)
+}
+
+// writeMergeCandidates lists likely duplicates for the review. They are hints,
+// not instructions: similar wording can still carry different facts, so the
+// model judges each pair itself.
+func writeMergeCandidates(prompt *strings.Builder, pairs []memory.MergeCandidate) {
+	if len(pairs) == 0 {
+		return
+	}
+	prompt.WriteString("\n## LIKELY DUPLICATES (by meaning; check before merging)\n\n")
+	for _, p := range pairs {
+		if p.Fact >= 0 {
+			fmt.Fprintf(prompt, "- fact %d ≈ id=%s (similarity %.2f)\n", p.Fact+1, p.B, p.Similarity)
+		} else {
+			fmt.Fprintf(prompt, "- id=%s ≈ id=%s (similarity %.2f)\n", p.A, p.B, p.Similarity)
+		}
+	}
+}
+
+// planOps turns the review's verdict into the changes it asks for, enforcing
+// the limits the prompt only asks for.
 //
 // Every id is checked against the set that was actually under review. A model
 // inventing an id is harmless on its own — the delete would match no row — but
 // an id it half-remembered from a different agent would not be, and this is the
 // one code path in the app that deletes memories without a person watching.
-func (m *Manager) applyOps(slug string, ops consolidateOps, existing []memory.Record) (added, updated, pruned int) {
+func (m *Manager) planOps(slug string, ops consolidateOps, existing []memory.Record) []change {
 	known := make(map[string]memory.Record, len(existing))
 	for _, e := range existing {
 		known[e.ID] = e
 	}
 
+	var changes []change
 	for _, rec := range ops.Add {
 		if strings.TrimSpace(rec.Content) == "" {
 			continue
@@ -469,27 +532,25 @@ func (m *Manager) applyOps(slug string, ops consolidateOps, existing []memory.Re
 		}
 		rec.ID = ""
 		rec.Source = "dream"
-		if _, err := m.mem.Add(slug, rec); err != nil {
-			logger.Warn("Dreaming could not add a memory for %s: %v", slug, err)
-			continue
-		}
-		added++
+		changes = append(changes, change{Op: opAdd, After: &rec})
 	}
 
 	for _, rec := range ops.Update {
-		if _, ok := known[rec.ID]; !ok {
+		prev, ok := known[rec.ID]
+		if !ok {
 			logger.Warn("Dreaming tried to update unknown memory %q for %s — skipped", rec.ID, slug)
 			continue
 		}
-		if err := m.mem.Update(slug, rec); err != nil {
-			logger.Warn("Dreaming could not update memory %s for %s: %v", rec.ID, slug, err)
+		after := overlay(prev, rec)
+		if sameMemory(prev, after) {
 			continue
 		}
-		updated++
+		changes = append(changes, change{Op: opUpdate, MemoryID: rec.ID, Before: &prev, After: &after})
 	}
 
+	forgets := 0
 	for _, f := range ops.Forget {
-		if pruned >= maxForgets {
+		if forgets >= maxForgets {
 			logger.Warn("Dreaming stopped at %d deletions for %s — the rest were left in place", maxForgets, slug)
 			break
 		}
@@ -502,14 +563,26 @@ func (m *Manager) applyOps(slug string, ops consolidateOps, existing []memory.Re
 			logger.Warn("Dreaming kept memory %s for %s: importance %d is protected from deletion", f.ID, slug, prev.Importance)
 			continue
 		}
-		if err := m.mem.Forget(slug, f.ID); err != nil {
-			logger.Warn("Dreaming could not forget memory %s for %s: %v", f.ID, slug, err)
+		changes = append(changes, change{Op: opForget, MemoryID: f.ID, Before: &prev, Reason: f.Reason})
+		forgets++
+	}
+	return changes
+}
+
+// applyOps writes the review's verdict to the memory database and returns the
+// changes actually made, for the run's journal.
+func (m *Manager) applyOps(slug string, ops consolidateOps, existing []memory.Record) []change {
+	var applied []change
+	for _, c := range m.planOps(slug, ops, existing) {
+		if err := m.applyChange(slug, &c); errors.Is(err, errDuplicate) {
+			continue
+		} else if err != nil {
+			logger.Warn("Dreaming could not %s memory %s for %s: %v", c.Op, c.MemoryID, slug, err)
 			continue
 		}
-		pruned++
+		applied = append(applied, c)
 	}
-
-	return added, updated, pruned
+	return applied
 }
 
 // fileReport puts the run's outcome in the Inbox.
@@ -518,11 +591,11 @@ func (m *Manager) applyOps(slug string, ops consolidateOps, existing []memory.Re
 // memories look identical from the outside, and both happened overnight. Only
 // runs that actually changed something (or failed) are reported — a nightly
 // "reviewed nothing" notification is how a user learns to ignore the Inbox.
-func (m *Manager) fileReport(notify NotifyFunc, slug, runID, status, errStr string, stats RunStats) {
+func (m *Manager) fileReport(notify NotifyFunc, slug, runID, status, errStr string, stats RunStats, changes []change) {
 	if notify == nil {
 		return
 	}
-	changed := stats.MemoriesAdded + stats.MemoriesUpdated + stats.MemoriesPruned
+	changed := stats.MemoriesAdded + stats.MemoriesUpdated + stats.MemoriesPruned + stats.Proposed
 	if status == "success" && changed == 0 {
 		return
 	}
@@ -541,7 +614,18 @@ func (m *Manager) fileReport(notify NotifyFunc, slug, runID, status, errStr stri
 		SourceID:        runID,
 	}
 
-	if status == "success" {
+	switch {
+	case status == "success" && stats.Proposed > 0:
+		added, updated, pruned := counts(changes)
+		in.Title = who + ": memory changes to review"
+		in.Body = fmt.Sprintf("%d to add · %d to update · %d to forget", added, updated, pruned)
+		in.Priority = "normal"
+		in.Detail = fmt.Sprintf(
+			"**%s reviewed its memory and proposes these changes.** Nothing has been changed yet: "+
+				"approve or reject them in the dreaming review queue.\n\n%s\n\n%s",
+			who, stats.Summary, describeChanges(changes),
+		)
+	case status == "success":
 		in.Title = who + ": dreamed"
 		in.Body = fmt.Sprintf("%d added · %d updated · %d forgotten", stats.MemoriesAdded, stats.MemoriesUpdated, stats.MemoriesPruned)
 		in.Priority = "low"
@@ -552,7 +636,10 @@ func (m *Manager) fileReport(notify NotifyFunc, slug, runID, status, errStr stri
 			who, stats.Summary, stats.ThreadsScanned, stats.FactsFound,
 			stats.MemoriesAdded, stats.MemoriesUpdated, stats.MemoriesPruned,
 		)
-	} else {
+		if len(changes) > 0 {
+			in.Detail += "\n**What changed**\n\n" + describeChanges(changes)
+		}
+	default:
 		in.Title = "Failed — " + who + ": dreaming"
 		in.Body = truncate(errStr, 160)
 		in.Priority = "high"
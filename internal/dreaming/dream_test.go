@@ -155,7 +155,7 @@ func TestApplyOpsGuardsDeletions(t *testing.T) {
 		}{ID: "not-a-real-id", Reason: "hallucinated"},
 	)
 
-	_, _, pruned := m.applyOps("scout", ops, existing)
+	_, _, pruned := counts(m.applyOps("scout", ops, existing))
 	if pruned != 1 {
 		t.Fatalf("expected exactly 1 deletion, got %d", pruned)
 	}
@@ -187,7 +187,7 @@ func TestApplyOpsRejectsUnknownUpdateIDs(t *testing.T) {
 		},
 	}
 
-	_, updated, _ := m.applyOps("scout", ops, existing)
+	_, updated, _ := counts(m.applyOps("scout", ops, existing))
 	if updated != 1 {
 		t.Fatalf("expected 1 update, got %d", updated)
 	}
@@ -215,7 +215,7 @@ func TestApplyOpsSkipsDuplicateAdds(t *testing.T) {
 		},
 	}
 
-	added, _, _ := m.applyOps("scout", ops, existing)
+	added, _, _ := counts(m.applyOps("scout", ops, existing))
 	if added != 1 {
 		t.Fatalf("expected 1 add (the duplicate should be skipped), got %d", added)
 	}
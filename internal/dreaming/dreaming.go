@@ -75,6 +75,12 @@ type Config struct {
 	ReviewLimit int `json:"review_limit"`
 	// ReflexEnabled gates the after-every-reply capture.
 	ReflexEnabled bool `json:"reflex_enabled"`
+	// ProposeOnly keeps the dream from changing memory itself: its adds,
+	// updates and deletions wait in the review queue until approved.
+	ProposeOnly bool `json:"propose_only"`
+	// PromoteShared lets the dream copy facts several agents each hold into
+	// the shared memory scopes they can all write to.
+	PromoteShared bool `json:"promote_shared"`
 }
 
 // DefaultConfig is dreaming on nightly, reflex off.
@@ -91,7 +97,9 @@ type Config struct {
 // regardless of how much was said that day, which the reflex's cannot be.
 //
 // That does mean deletions happen unattended on a schedule out of the box, so
-// the deletion guards in applyOps are load-bearing rather than belt-and-braces.
+// the deletion guards in planOps are load-bearing rather than belt-and-braces.
+// Every change is journalled, so a bad run can be reverted, and ProposeOnly
+// puts a person in front of them instead.
 func DefaultConfig() Config {
 	return Config{
 		Enabled:       true,
@@ -124,6 +132,7 @@ type Manager struct {
 	thinker   Thinker
 	broadcast BroadcastFunc
 	notify    NotifyFunc
+	shared    SharedScopes
 
 	mu      sync.RWMutex
 	cfg     Config
@@ -135,6 +144,10 @@ type Manager struct {
 	// a large history, and two passes consolidating the same memory database
 	// concurrently would each act on a view the other is invalidating.
 	dreaming atomic.Bool
+
+	// reviewMu serialises approvals and reverts, so two clicks on the same
+	// change can't both apply it.
+	reviewMu sync.Mutex
 }
 
 func New(db *database.DB, mem *memory.Manager, thinker Thinker, broadcast BroadcastFunc) *Manager {
@@ -153,6 +166,13 @@ func (m *Manager) SetNotifyFunc(fn NotifyFunc) {
 	m.notify = fn
 }
 
+// SetSharedScopes gives the dream the shared memory scopes to promote into.
+func (m *Manager) SetSharedScopes(s SharedScopes) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shared = s
+}
+
 // LoadConfig reads dreaming settings from the database.
 func (m *Manager) LoadConfig() {
 	m.mu.Lock()
@@ -163,7 +183,8 @@ func (m *Manager) LoadConfig() {
 	rows, err := m.db.Query(
 		`SELECT key, value FROM settings WHERE key IN
 		 ('dreaming_enabled', 'dreaming_cron', 'dreaming_max_threads',
-		  'dreaming_review_limit', 'dreaming_reflex_enabled')`,
+		  'dreaming_review_limit', 'dreaming_reflex_enabled', 'dreaming_propose_only',
+		  'dreaming_promote_shared')`,
 	)
 	if err == nil {
 		defer rows.Close()
@@ -177,6 +198,10 @@ func (m *Manager) LoadConfig() {
 				cfg.Enabled = val == "true" || val == "1"
 			case "dreaming_reflex_enabled":
 				cfg.ReflexEnabled = val == "true" || val == "1"
+			case "dreaming_propose_only":
+				cfg.ProposeOnly = val == "true" || val == "1"
+			case "dreaming_promote_shared":
+				cfg.PromoteShared = val == "true" || val == "1"
 			case "dreaming_cron":
 				if _, err := cronParser.Parse(val); err == nil {
 					cfg.CronExpr = val
@@ -210,6 +235,8 @@ func (m *Manager) GetConfig() map[string]string {
 		"dreaming_max_threads":    fmt.Sprintf("%d", cfg.MaxThreads),
 		"dreaming_review_limit":   fmt.Sprintf("%d", cfg.ReviewLimit),
 		"dreaming_reflex_enabled": boolStr(cfg.ReflexEnabled),
+		"dreaming_propose_only":   boolStr(cfg.ProposeOnly),
+		"dreaming_promote_shared": boolStr(cfg.PromoteShared),
 		"dreaming_next_run":       m.nextRunISO(),
 		"dreaming_running":        boolStr(m.dreaming.Load()),
 	}
@@ -229,7 +256,8 @@ func (m *Manager) UpdateConfig(in map[string]string) error {
 	for key, val := range in {
 		switch key {
 		case "dreaming_enabled", "dreaming_cron", "dreaming_max_threads",
-			"dreaming_review_limit", "dreaming_reflex_enabled":
+			"dreaming_review_limit", "dreaming_reflex_enabled", "dreaming_propose_only",
+			"dreaming_promote_shared":
 		default:
 			continue // ignore anything not ours
 		}
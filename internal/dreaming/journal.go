@@ -0,0 +1,560 @@
+package dreaming
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/models"
+	"github.com/openpaw/openpaw/internal/textdiff"
+)
+
+// Journal op kinds and statuses, as stored in dream_ops.
+const (
+	opAdd    = "add"
+	opUpdate = "update"
+	opForget = "forget"
+
+	opApplied  = "applied"
+	opProposed = "proposed"
+	opRejected = "rejected"
+	opReverted = "reverted"
+)
+
+// Run modes, as stored in dream_runs.mode.
+const (
+	modeApply   = "apply"
+	modePropose = "propose"
+)
+
+// ErrDecided is returned when a change in the review queue has already been
+// approved or rejected.
+var ErrDecided = errors.New("this change has already been decided")
+
+// errDuplicate skips an add whose fact memory already holds.
+var errDuplicate = errors.New("an equivalent memory already exists")
+
+// change is one edit to an agent's memory: what the memory was before and
+// what it is (or would be) after. Before is nil for an add, After for a
+// forget.
+type change struct {
+	Op       string
+	MemoryID string
+	Before   *memory.Record
+	After    *memory.Record
+	Reason   string
+}
+
+// counts tallies changes the way dream_runs reports them.
+func counts(changes []change) (added, updated, pruned int) {
+	for _, c := range changes {
+		switch c.Op {
+		case opAdd:
+			added++
+		case opUpdate:
+			updated++
+		case opForget:
+			pruned++
+		}
+	}
+	return added, updated, pruned
+}
+
+// sameMemory reports whether two records say the same thing, ignoring
+// bookkeeping like timestamps and source.
+func sameMemory(a, b memory.Record) bool {
+	return a.Content == b.Content && a.Summary == b.Summary && a.Category == b.Category &&
+		a.Importance == b.Importance && a.Tags == b.Tags
+}
+
+// overlay is what memory.Update leaves behind: the non-empty fields of the
+// update over the record as it was.
+func overlay(before, update memory.Record) memory.Record {
+	after := before
+	if c := strings.TrimSpace(update.Content); c != "" {
+		after.Content = c
+	}
+	if s := strings.TrimSpace(update.Summary); s != "" {
+		after.Summary = s
+	}
+	if c := strings.TrimSpace(update.Category); c != "" {
+		after.Category = c
+	}
+	if t := strings.TrimSpace(update.Tags); t != "" {
+		after.Tags = t
+	}
+	if update.Importance > 0 {
+		after.Importance = min(update.Importance, 10)
+	}
+	return after
+}
+
+// applyChange makes one change to the memory database, filling in the new
+// memory's id and what it looks like afterwards.
+func (m *Manager) applyChange(slug string, c *change) error {
+	switch c.Op {
+	case opAdd:
+		if m.mem.HasSimilar(slug, c.After.Content, c.After.Summary) {
+			return errDuplicate
+		}
+		rec := *c.After
+		rec.ID = ""
+		rec.Source = "dream"
+		id, err := m.mem.Add(slug, rec)
+		if err != nil {
+			return err
+		}
+		c.MemoryID = id
+	case opUpdate:
+		if err := m.mem.Update(slug, *c.After); err != nil {
+			return err
+		}
+	case opForget:
+		return m.mem.Forget(slug, c.MemoryID)
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+	if after, err := m.mem.Get(slug, c.MemoryID); err == nil {
+		c.After = &after
+	}
+	return nil
+}
+
+// journal records a run's changes in dream_ops. A failure is only logged: the
+// changes themselves have already been made (or proposed) by then.
+func (m *Manager) journal(runID, slug, status string, changes []change) {
+	now := time.Now().UTC()
+	for i, c := range changes {
+		if _, err := m.db.Exec(
+			`INSERT INTO dream_ops (id, run_id, agent_slug, seq, op, memory_id, before_json, after_json, reason, status, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), runID, slug, i, c.Op, c.MemoryID,
+			recordJSON(c.Before), recordJSON(c.After), c.Reason, status, now,
+		); err != nil {
+			logger.Warn("Dreaming could not journal a %s of memory %s for %s: %v", c.Op, c.MemoryID, slug, err)
+		}
+	}
+}
+
+func recordJSON(r *memory.Record) string {
+	if r == nil {
+		return ""
+	}
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+func parseRecord(s string) *memory.Record {
+	if s == "" {
+		return nil
+	}
+	var r memory.Record
+	if json.Unmarshal([]byte(s), &r) != nil {
+		return nil
+	}
+	return &r
+}
+
+// storedOp is a dream_ops row.
+type storedOp struct {
+	ID, RunID, Slug string
+	change
+	Status    string
+	DecidedBy string
+	DecidedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+const opColumns = `id, run_id, agent_slug, op, memory_id, before_json, after_json, reason, status,
+	decided_by, decided_at, created_at`
+
+func scanOp(row interface{ Scan(...interface{}) error }) (*storedOp, error) {
+	var o storedOp
+	var before, after string
+	if err := row.Scan(&o.ID, &o.RunID, &o.Slug, &o.Op, &o.MemoryID, &before, &after, &o.Reason, &o.Status,
+		&o.DecidedBy, &o.DecidedAt, &o.CreatedAt); err != nil {
+		return nil, err
+	}
+	o.Before, o.After = parseRecord(before), parseRecord(after)
+	return &o, nil
+}
+
+func (m *Manager) queryOps(where string, args ...interface{}) ([]*storedOp, error) {
+	rows, err := m.db.Query("SELECT "+opColumns+" FROM dream_ops WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ops []*storedOp
+	for rows.Next() {
+		o, err := scanOp(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, o)
+	}
+	return ops, rows.Err()
+}
+
+// model renders a journal entry for the API, with a diff of the memory's
+// content from before to after.
+func (o *storedOp) model() models.DreamOp {
+	out := models.DreamOp{
+		ID:            o.ID,
+		RunID:         o.RunID,
+		AgentRoleSlug: o.Slug,
+		Op:            o.Op,
+		MemoryID:      o.MemoryID,
+		Before:        dreamMemory(o.Before),
+		After:         dreamMemory(o.After),
+		Reason:        o.Reason,
+		Status:        o.Status,
+		DecidedBy:     o.DecidedBy,
+		CreatedAt:     o.CreatedAt,
+	}
+	if o.DecidedAt.Valid {
+		t := o.DecidedAt.Time
+		out.DecidedAt = &t
+	}
+	var before, after string
+	if o.Before != nil {
+		before = o.Before.Content + "\n"
+	}
+	if o.After != nil {
+		after = o.After.Content + "\n"
+	}
+	out.Diff = textdiff.Unified(textdiff.Lines(before, after), "before", "after", 3)
+	return out
+}
+
+func dreamMemory(r *memory.Record) *models.DreamMemory {
+	if r == nil {
+		return nil
+	}
+	return &models.DreamMemory{
+		Content:    r.Content,
+		Summary:    r.Summary,
+		Category:   r.Category,
+		Importance: r.Importance,
+		Tags:       r.Tags,
+	}
+}
+
+// Journal returns the recorded changes of the given runs, in the order each
+// run made them, keyed by run id.
+func (m *Manager) Journal(runIDs ...string) (map[string][]models.DreamOp, error) {
+	out := map[string][]models.DreamOp{}
+	if len(runIDs) == 0 {
+		return out, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(runIDs)), ",")
+	args := make([]interface{}, len(runIDs))
+	for i, id := range runIDs {
+		args[i] = id
+	}
+	ops, err := m.queryOps("run_id IN ("+placeholders+") ORDER BY run_id, seq", args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range ops {
+		out[o.RunID] = append(out[o.RunID], o.model())
+	}
+	return out, nil
+}
+
+// ReviewQueue returns the changes waiting for approval, oldest first,
+// optionally for one agent.
+func (m *Manager) ReviewQueue(agentSlug string) ([]models.DreamOp, error) {
+	where, args := "status = ?", []interface{}{opProposed}
+	if agentSlug != "" {
+		where += " AND agent_slug = ?"
+		args = append(args, agentSlug)
+	}
+	ops, err := m.queryOps(where+" ORDER BY created_at, seq", args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.DreamOp, 0, len(ops))
+	for _, o := range ops {
+		out = append(out, o.model())
+	}
+	return out, nil
+}
+
+// ApproveOp makes a proposed change. The memory an update or forget targets
+// must still exist; its state now, rather than when the change was proposed,
+// is what the journal keeps as "before", so a later revert restores it.
+func (m *Manager) ApproveOp(id, userID string) (*models.DreamOp, error) {
+	m.reviewMu.Lock()
+	defer m.reviewMu.Unlock()
+	o, err := m.pendingOp(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.approve(o, userID); err != nil {
+		return nil, err
+	}
+	m.broadcastMemoryChange(o.Slug, "dream_review", []change{o.change})
+	out := o.model()
+	return &out, nil
+}
+
+// RejectOp drops a proposed change without making it.
+func (m *Manager) RejectOp(id, userID string) (*models.DreamOp, error) {
+	m.reviewMu.Lock()
+	defer m.reviewMu.Unlock()
+	o, err := m.pendingOp(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.decide(o, opRejected, userID); err != nil {
+		return nil, err
+	}
+	out := o.model()
+	return &out, nil
+}
+
+// ReviewResult is the outcome of deciding a whole run's queue at once.
+type ReviewResult struct {
+	Approved int      `json:"approved"`
+	Rejected int      `json:"rejected"`
+	Failed   []string `json:"failed"`
+}
+
+// DecideRun approves or rejects every change of a run still in the queue. A
+// change that can no longer be made (its memory is gone) is reported and
+// left in the queue.
+func (m *Manager) DecideRun(runID string, approve bool, userID string) (ReviewResult, error) {
+	m.reviewMu.Lock()
+	defer m.reviewMu.Unlock()
+	res := ReviewResult{Failed: []string{}}
+	ops, err := m.queryOps("run_id = ? AND status = ? ORDER BY seq", runID, opProposed)
+	if err != nil {
+		return res, err
+	}
+	var slug string
+	var made []change
+	for _, o := range ops {
+		slug = o.Slug
+		if !approve {
+			if err := m.decide(o, opRejected, userID); err != nil {
+				return res, err
+			}
+			res.Rejected++
+			continue
+		}
+		if err := m.approve(o, userID); err != nil {
+			res.Failed = append(res.Failed, fmt.Sprintf("%s %s: %v", o.Op, o.ID, err))
+			continue
+		}
+		res.Approved++
+		made = append(made, o.change)
+	}
+	m.broadcastMemoryChange(slug, "dream_review", made)
+	return res, nil
+}
+
+func (m *Manager) pendingOp(id string) (*storedOp, error) {
+	o, err := scanOp(m.db.QueryRow("SELECT "+opColumns+" FROM dream_ops WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != opProposed {
+		return nil, ErrDecided
+	}
+	return o, nil
+}
+
+// approve makes a proposed change and records it as applied.
+func (m *Manager) approve(o *storedOp, userID string) error {
+	if o.Op != opAdd {
+		current, err := m.mem.Get(o.Slug, o.MemoryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("the memory no longer exists")
+		} else if err != nil {
+			return err
+		}
+		o.Before = &current
+	}
+	if err := m.applyChange(o.Slug, &o.change); err != nil {
+		return err
+	}
+	if o.Op == opForget {
+		o.After = nil
+	}
+	if _, err := m.db.Exec(`UPDATE dream_ops SET memory_id = ?, before_json = ?, after_json = ? WHERE id = ?`,
+		o.MemoryID, recordJSON(o.Before), recordJSON(o.After), o.ID); err != nil {
+		return err
+	}
+	column := map[string]string{opAdd: "memories_added", opUpdate: "memories_updated", opForget: "memories_pruned"}[o.Op]
+	m.db.Exec("UPDATE dream_runs SET "+column+" = "+column+" + 1 WHERE id = ?", o.RunID)
+	return m.decide(o, opApplied, userID)
+}
+
+func (m *Manager) decide(o *storedOp, status, userID string) error {
+	now := time.Now().UTC()
+	if _, err := m.db.Exec(`UPDATE dream_ops SET status = ?, decided_by = ?, decided_at = ? WHERE id = ?`,
+		status, userID, now, o.ID); err != nil {
+		return err
+	}
+	o.Status, o.DecidedBy = status, userID
+	o.DecidedAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
+
+// RevertResult is the outcome of putting a run back.
+type RevertResult struct {
+	Reverted int `json:"reverted"`
+	// Rejected counts changes still in the queue, which a revert discards.
+	Rejected int `json:"rejected"`
+	// Conflicts are changes left in place because the memory has been edited
+	// since; reverting them would throw that edit away.
+	Conflicts []string `json:"conflicts"`
+}
+
+// RevertRun undoes everything a run changed, newest change first: memories it
+// added are deleted, updated ones get their old content back, and forgotten
+// ones are recreated under their old ids. Changes still waiting for review
+// are rejected.
+func (m *Manager) RevertRun(runID, userID string) (RevertResult, error) {
+	m.reviewMu.Lock()
+	defer m.reviewMu.Unlock()
+	res := RevertResult{Conflicts: []string{}}
+	var exists string
+	if err := m.db.QueryRow("SELECT id FROM dream_runs WHERE id = ?", runID).Scan(&exists); err != nil {
+		return res, err
+	}
+	ops, err := m.queryOps("run_id = ? AND status IN (?, ?) ORDER BY seq DESC", runID, opApplied, opProposed)
+	if err != nil {
+		return res, err
+	}
+	var slug string
+	var undone []change
+	for _, o := range ops {
+		slug = o.Slug
+		if o.Status == opProposed {
+			if err := m.decide(o, opRejected, userID); err != nil {
+				return res, err
+			}
+			res.Rejected++
+			continue
+		}
+		if err := m.undo(o); err != nil {
+			res.Conflicts = append(res.Conflicts, fmt.Sprintf("%s of memory %s: %v", o.Op, o.MemoryID, err))
+			continue
+		}
+		if err := m.decide(o, opReverted, userID); err != nil {
+			return res, err
+		}
+		res.Reverted++
+		undone = append(undone, o.change)
+	}
+	if len(res.Conflicts) == 0 {
+		m.db.Exec("UPDATE dream_runs SET reverted_at = ? WHERE id = ?", time.Now().UTC(), runID)
+	}
+	m.broadcastMemoryChange(slug, "dream_revert", undone)
+	return res, nil
+}
+
+// undo reverses one applied change, refusing when the memory no longer looks
+// the way the change left it.
+func (m *Manager) undo(o *storedOp) error {
+	current, err := m.mem.Get(o.Slug, o.MemoryID)
+	missing := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !missing {
+		return err
+	}
+	switch o.Op {
+	case opAdd:
+		if missing {
+			return nil // already gone
+		}
+		if o.After != nil && !sameMemory(current, *o.After) {
+			return errors.New("edited since the dream added it")
+		}
+		return m.mem.Forget(o.Slug, o.MemoryID)
+	case opUpdate:
+		if missing {
+			return errors.New("deleted since the dream updated it")
+		}
+		if o.After != nil && !sameMemory(current, *o.After) {
+			return errors.New("edited since the dream updated it")
+		}
+		if o.Before == nil {
+			return errors.New("no record of the memory before the update")
+		}
+		return m.mem.Restore(o.Slug, *o.Before)
+	case opForget:
+		if !missing {
+			return nil // already back
+		}
+		if o.Before == nil {
+			return errors.New("no record of the forgotten memory")
+		}
+		return m.mem.Restore(o.Slug, *o.Before)
+	}
+	return fmt.Errorf("unknown op %q", o.Op)
+}
+
+// broadcastMemoryChange tells the UI an agent's memories changed outside a
+// dream run.
+func (m *Manager) broadcastMemoryChange(slug, source string, changes []change) {
+	if m.broadcast == nil || len(changes) == 0 {
+		return
+	}
+	added, updated, pruned := counts(changes)
+	m.broadcast("memories_updated", map[string]interface{}{
+		"agent_slug": slug,
+		"added":      added,
+		"updated":    updated,
+		"pruned":     pruned,
+		"source":     source,
+	})
+}
+
+// maxReportedChanges caps the changes listed in an Inbox report; the run's
+// history has the rest.
+const maxReportedChanges = 30
+
+// describeChanges renders a run's changes for the Inbox report, so it says
+// exactly what the agent now believes differently.
+func describeChanges(changes []change) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, c := range changes {
+		if i == maxReportedChanges {
+			fmt.Fprintf(&b, "- …and %d more (see the dream's history)\n", len(changes)-i)
+			break
+		}
+		switch c.Op {
+		case opAdd:
+			fmt.Fprintf(&b, "- **Added:** %s\n", singleLine(c.After.Content))
+		case opUpdate:
+			before := ""
+			if c.Before != nil {
+				before = singleLine(c.Before.Content)
+			}
+			after := singleLine(c.After.Content)
+			if before == after {
+				fmt.Fprintf(&b, "- **Updated:** %s _(category, importance or tags)_\n", after)
+			} else {
+				fmt.Fprintf(&b, "- **Updated:** ~~%s~~ → %s\n", before, after)
+			}
+		case opForget:
+			line := "- **Forgot:** ~~" + singleLine(c.Before.Content) + "~~"
+			if c.Reason != "" {
+				line += " — " + singleLine(c.Reason)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
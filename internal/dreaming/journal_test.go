@@ -0,0 +1,153 @@
+package dreaming
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+func seedRun(t *testing.T, db *database.DB, id, mode string) {
+	t.Helper()
+	if _, err := db.Exec(
+		"INSERT INTO dream_runs (id, agent_slug, status, mode, started_at) VALUES (?, 'scout', 'success', ?, ?)",
+		id, mode, time.Now().UTC(),
+	); err != nil {
+		t.Fatalf("insert run: %v", err)
+	}
+}
+
+func forget(id, reason string) struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+} {
+	return struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}{ID: id, Reason: reason}
+}
+
+// A bad night must be undoable: the journal has to hold enough to put every
+// add, rewrite and deletion back, and the revert has to leave alone what the
+// user changed since.
+func TestRevertRunPutsMemoryBack(t *testing.T) {
+	m, db := newTestManager(t)
+	seedRun(t, db, "run-1", modeApply)
+
+	keep, _ := m.mem.Add("scout", memory.Record{Content: "Deploys happen on Tuesdays", Importance: 5})
+	drop, _ := m.mem.Add("scout", memory.Record{Content: "The office wifi is Paw-Guest", Importance: 3, Tags: "office"})
+	existing, _ := m.mem.Recent("scout", 100)
+
+	ops := consolidateOps{
+		Add:    []memory.Record{{Content: "Release notes go in CHANGELOG.md"}},
+		Update: []memory.Record{{ID: keep, Content: "Deploys happen on Thursdays"}},
+	}
+	ops.Forget = append(ops.Forget, forget(drop, "moved offices"))
+	changes := m.applyOps("scout", ops, existing)
+	m.journal("run-1", "scout", opApplied, changes)
+
+	journal, err := m.Journal("run-1")
+	if err != nil || len(journal["run-1"]) != 3 {
+		t.Fatalf("journal = %+v %v", journal, err)
+	}
+	var update string
+	for _, op := range journal["run-1"] {
+		if op.Op == opUpdate {
+			update = op.Diff
+		}
+	}
+	if !strings.Contains(update, "-Deploys happen on Tuesdays\n+Deploys happen on Thursdays\n") {
+		t.Errorf("update diff = %q", update)
+	}
+
+	res, err := m.RevertRun("run-1", "user-1")
+	if err != nil || res.Reverted != 3 || len(res.Conflicts) != 0 {
+		t.Fatalf("revert = %+v %v", res, err)
+	}
+	after, _ := m.mem.Recent("scout", 100)
+	contents := map[string]string{}
+	for _, r := range after {
+		contents[r.ID] = r.Content
+	}
+	if len(after) != 2 || contents[keep] != "Deploys happen on Tuesdays" || contents[drop] != "The office wifi is Paw-Guest" {
+		t.Fatalf("after revert: %+v", after)
+	}
+	if restored, _ := m.mem.Get("scout", drop); restored.Tags != "office" || restored.Importance != 3 {
+		t.Errorf("forgotten memory came back as %+v", restored)
+	}
+}
+
+func TestRevertRunSkipsMemoriesEditedSince(t *testing.T) {
+	m, db := newTestManager(t)
+	seedRun(t, db, "run-1", modeApply)
+
+	id, _ := m.mem.Add("scout", memory.Record{Content: "Standup is at 9", Importance: 5})
+	existing, _ := m.mem.Recent("scout", 100)
+	changes := m.applyOps("scout", consolidateOps{Update: []memory.Record{{ID: id, Content: "Standup is at 10"}}}, existing)
+	m.journal("run-1", "scout", opApplied, changes)
+
+	// The user corrected it by hand after the dream.
+	m.mem.Update("scout", memory.Record{ID: id, Content: "Standup is at 9:30"})
+
+	res, err := m.RevertRun("run-1", "user-1")
+	if err != nil || res.Reverted != 0 || len(res.Conflicts) != 1 {
+		t.Fatalf("revert = %+v %v", res, err)
+	}
+	if got, _ := m.mem.Get("scout", id); got.Content != "Standup is at 9:30" {
+		t.Errorf("revert overwrote a later edit: %q", got.Content)
+	}
+}
+
+// In propose-only mode nothing touches memory until a person approves it.
+func TestProposedChangesWaitForReview(t *testing.T) {
+	m, db := newTestManager(t)
+	seedRun(t, db, "run-1", modePropose)
+
+	old, _ := m.mem.Add("scout", memory.Record{Content: "Uses Postgres 14", Importance: 4})
+	existing, _ := m.mem.Recent("scout", 100)
+	ops := consolidateOps{Add: []memory.Record{{Content: "Prefers dark mode"}}}
+	ops.Forget = append(ops.Forget, forget(old, "upgraded"))
+	m.journal("run-1", "scout", opProposed, m.planOps("scout", ops, existing))
+
+	if left, _ := m.mem.Recent("scout", 100); len(left) != 1 || left[0].ID != old {
+		t.Fatalf("proposals changed memory: %+v", left)
+	}
+	queue, err := m.ReviewQueue("scout")
+	if err != nil || len(queue) != 2 {
+		t.Fatalf("queue = %+v %v", queue, err)
+	}
+
+	var addID, forgetID string
+	for _, op := range queue {
+		if op.Op == opAdd {
+			addID = op.ID
+		} else {
+			forgetID = op.ID
+		}
+	}
+	approved, err := m.ApproveOp(addID, "user-1")
+	if err != nil || approved.Status != opApplied || approved.MemoryID == "" {
+		t.Fatalf("approve = %+v %v", approved, err)
+	}
+	if _, err := m.ApproveOp(addID, "user-1"); err != ErrDecided {
+		t.Errorf("approving twice: %v", err)
+	}
+	if _, err := m.RejectOp(forgetID, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	left, _ := m.mem.Recent("scout", 100)
+	if len(left) != 2 {
+		t.Fatalf("after review: %+v", left)
+	}
+	var added int
+	db.QueryRow("SELECT memories_added FROM dream_runs WHERE id = 'run-1'").Scan(&added)
+	if added != 1 {
+		t.Errorf("memories_added = %d", added)
+	}
+	if queue, _ := m.ReviewQueue(""); len(queue) != 0 {
+		t.Errorf("queue not empty: %+v", queue)
+	}
+}
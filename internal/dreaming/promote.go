@@ -0,0 +1,144 @@
+package dreaming
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// SharedScopes is what promotion needs to know about shared memory: every
+// scope, and which agents may write to each.
+type SharedScopes interface {
+	All() ([]memory.Scope, error)
+	Writers(scopeID string) ([]string, error)
+}
+
+// promoteShared copies facts that several agents have each learned on their
+// own into the shared scopes those agents write to, so the next agent to need
+// one finds it there instead of learning it a third time.
+//
+// Only agents that can write to a scope count towards its facts: a fact the
+// scope's writers hold is one they could have shared themselves. Each scope
+// that gains something gets its own run, journalled like an agent's, so the
+// review queue and revert work on promotions unchanged.
+func (m *Manager) promoteShared(ctx context.Context) {
+	m.mu.RLock()
+	cfg := m.cfg
+	notify := m.notify
+	shared := m.shared
+	m.mu.RUnlock()
+	if !cfg.PromoteShared || shared == nil {
+		return
+	}
+
+	scopes, err := shared.All()
+	if err != nil {
+		logger.Error("Dreaming could not list shared memory scopes: %v", err)
+		return
+	}
+	for _, sc := range scopes {
+		if ctx.Err() != nil {
+			logger.Warn("Dreaming ran out of time before promoting into %s", sc.Name)
+			return
+		}
+		writers, err := shared.Writers(sc.ID)
+		if err != nil {
+			logger.Warn("Dreaming could not list writers of shared memory %s: %v", sc.Name, err)
+			continue
+		}
+		if len(writers) < 2 {
+			continue
+		}
+		m.promoteInto(ctx, sc, writers, cfg, notify)
+	}
+}
+
+// promoteInto runs one scope's promotion. Nothing is recorded for a scope
+// that gains nothing.
+func (m *Manager) promoteInto(ctx context.Context, sc memory.Scope, writers []string, cfg Config, notify NotifyFunc) {
+	key := memory.SharedKey(sc.ID)
+	var planned []change
+	for _, f := range m.mem.CommonFacts(ctx, writers, cfg.ReviewLimit, 2) {
+		if m.mem.HasSimilar(key, f.Record.Content, f.Record.Summary) {
+			continue
+		}
+		rec := f.Record
+		rec.ID = ""
+		planned = append(planned, change{Op: opAdd, After: &rec, Reason: "Held by " + strings.Join(f.Agents, ", ")})
+	}
+	if len(planned) == 0 {
+		return
+	}
+
+	runID := uuid.New().String()
+	mode := modeApply
+	if cfg.ProposeOnly {
+		mode = modePropose
+	}
+	m.db.Exec(
+		"INSERT INTO dream_runs (id, agent_slug, status, mode, started_at) VALUES (?, ?, 'running', ?, ?)",
+		runID, key, mode, time.Now().UTC(),
+	)
+
+	stats := RunStats{AgentSlug: key, FactsFound: len(planned)}
+	changes := planned
+	if cfg.ProposeOnly {
+		m.journal(runID, key, opProposed, changes)
+		stats.Proposed = len(changes)
+	} else {
+		changes = nil
+		for _, c := range planned {
+			if err := m.applyChange(key, &c); err != nil {
+				logger.Warn("Dreaming could not promote a memory into %s: %v", sc.Name, err)
+				continue
+			}
+			changes = append(changes, c)
+		}
+		m.journal(runID, key, opApplied, changes)
+		stats.MemoriesAdded = len(changes)
+		m.broadcastMemoryChange(key, "dream", changes)
+	}
+	stats.Summary = fmt.Sprintf("Found %d fact(s) that %d agents each hold on their own.", len(planned), len(writers))
+
+	m.db.Exec(
+		`UPDATE dream_runs SET status = 'success', facts_found = ?, memories_added = ?, summary = ?, finished_at = ?
+		 WHERE id = ?`,
+		stats.FactsFound, stats.MemoriesAdded, stats.Summary, time.Now().UTC(), runID,
+	)
+	if m.broadcast != nil {
+		m.broadcast("dream_run_finished", map[string]interface{}{
+			"id": runID, "status": "success", "stats": stats,
+		})
+	}
+
+	if notify == nil || len(changes) == 0 {
+		return
+	}
+	in := models.NotificationInput{
+		WorkspaceID: m.db.ActiveWorkspaceID(),
+		SourceType:  "dream",
+		SourceID:    runID,
+		Priority:    "low",
+	}
+	if cfg.ProposeOnly {
+		in.Title = sc.Name + ": shared memory to review"
+		in.Body = fmt.Sprintf("%d fact(s) to share", len(changes))
+		in.Priority = "normal"
+		in.Detail = fmt.Sprintf(
+			"**Several agents learned the same things separately.** Approve them in the dreaming review queue "+
+				"to add them to the %s shared memory.\n\n%s", sc.Name, describeChanges(changes))
+	} else {
+		in.Title = sc.Name + ": shared memory updated"
+		in.Body = fmt.Sprintf("%d fact(s) shared", len(changes))
+		in.Detail = fmt.Sprintf(
+			"**Several agents learned the same things separately**, so they were added to the %s shared memory.\n\n%s",
+			sc.Name, describeChanges(changes))
+	}
+	notify(in)
+}
@@ -0,0 +1,62 @@
+package dreaming
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+type fakeScopes struct {
+	scopes  []memory.Scope
+	writers map[string][]string
+}
+
+func (f fakeScopes) All() ([]memory.Scope, error)        { return f.scopes, nil }
+func (f fakeScopes) Writers(id string) ([]string, error) { return f.writers[id], nil }
+
+// A fact two agents learned separately belongs in the memory they share, once,
+// and the promotion is journalled so it can be reverted like any dream.
+func TestPromoteSharedCopiesCommonFactsOnce(t *testing.T) {
+	m, db := newTestManager(t)
+	m.cfg.PromoteShared = true
+	m.SetSharedScopes(fakeScopes{
+		scopes:  []memory.Scope{{ID: "team", Name: "Team"}, {ID: "solo", Name: "Solo"}},
+		writers: map[string][]string{"team": {"scout", "builder"}, "solo": {"scout"}},
+	})
+	m.mem.Add("scout", memory.Record{Content: "Staging lives at staging2.example.com", Importance: 6})
+	m.mem.Add("builder", memory.Record{Content: "staging lives at staging2.example.com", Importance: 6})
+	m.mem.Add("builder", memory.Record{Content: "Builds use Bazel", Importance: 6})
+
+	m.promoteShared(context.Background())
+	team := memory.SharedKey("team")
+	got, _ := m.mem.Recent(team, 10)
+	if len(got) != 1 || got[0].Source != "dream" {
+		t.Fatalf("team memory = %+v", got)
+	}
+	if solo, _ := m.mem.Recent(memory.SharedKey("solo"), 10); len(solo) != 0 {
+		t.Errorf("a one-writer scope was promoted into: %+v", solo)
+	}
+
+	var runID string
+	var added int
+	db.QueryRow("SELECT id, memories_added FROM dream_runs WHERE agent_slug = ?", team).Scan(&runID, &added)
+	if added != 1 {
+		t.Fatalf("run %q added %d", runID, added)
+	}
+
+	// A second night finds the fact already shared.
+	m.promoteShared(context.Background())
+	var runs int
+	db.QueryRow("SELECT COUNT(*) FROM dream_runs").Scan(&runs)
+	if runs != 1 {
+		t.Errorf("%d runs after a night with nothing new", runs)
+	}
+
+	if res, err := m.RevertRun(runID, "user-1"); err != nil || res.Reverted != 1 {
+		t.Fatalf("revert = %+v %v", res, err)
+	}
+	if left, _ := m.mem.Recent(team, 10); len(left) != 0 {
+		t.Errorf("revert left %+v", left)
+	}
+}
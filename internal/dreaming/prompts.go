@@ -97,7 +97,9 @@ merged into what is already known, duplicates collapse, and what no longer holds
 is let go.
 
 You are given NEW FACTS harvested from conversations the agent has not reviewed
-before, and EXISTING MEMORIES already in its database (each with an id).
+before, and EXISTING MEMORIES already in its database (each with an id). A
+LIKELY DUPLICATES section, when present, lists pairs that read alike by meaning;
+treat them as merge candidates to check, not as verdicts.
 
 Decide, for the whole set:
 
@@ -1,24 +1,33 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/openpaw/openpaw/internal/agentlibrary"
+	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
 )
 
 type AgentLibraryHandler struct {
-	db      *database.DB
-	dataDir string
+	db        *database.DB
+	dataDir   string
+	memoryMgr *memory.Manager
+	agentMgr  *agents.Manager
 }
 
-func NewAgentLibraryHandler(db *database.DB, dataDir string) *AgentLibraryHandler {
-	return &AgentLibraryHandler{db: db, dataDir: dataDir}
+func NewAgentLibraryHandler(db *database.DB, dataDir string, memoryMgr *memory.Manager, agentMgr *agents.Manager) *AgentLibraryHandler {
+	return &AgentLibraryHandler{db: db, dataDir: dataDir, memoryMgr: memoryMgr, agentMgr: agentMgr}
 }
 
 func (h *AgentLibraryHandler) ListCatalog(w http.ResponseWriter, r *http.Request) {
@@ -114,3 +123,99 @@ func (h *AgentLibraryHandler) InstallCatalogAgent(w http.ResponseWriter, r *http
 
 	writeJSON(w, http.StatusCreated, a)
 }
+
+// ExportAgent downloads an agent as a bundle (see agentlibrary.ExportBundle).
+// ?memory=true includes its memories.
+func (h *AgentLibraryHandler) ExportAgent(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	slug := chi.URLParam(r, "slug")
+	withMemory := r.URL.Query().Get("memory") == "true" || r.URL.Query().Get("memory") == "1"
+
+	data, manifest, err := agentlibrary.ExportBundle(h.db, h.dataDir, h.memoryMgr, slug, withMemory)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "agent role not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("export failed: %v", err))
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	detail := fmt.Sprintf("%d skills, %d tools", len(manifest.Skills), len(manifest.Tools))
+	if withMemory {
+		detail += fmt.Sprintf(", %d memories", manifest.Memories)
+	}
+	h.db.LogAudit(userID, "agent_exported", "agent", "agent_role", slug, detail)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.agent.zip"`, slug))
+	w.Write(data)
+}
+
+// ImportAgent installs an agent bundle uploaded as the multipart field "file".
+// Optional fields: slug (install under another slug), on_conflict (rename,
+// replace or fail), tool_map (JSON object of bundled tool id or catalog slug
+// to local tool id, "" to drop the grant), skip_memory, and dry_run, which
+// returns the resolved slug and tool matches without installing anything.
+func (h *AgentLibraryHandler) ImportAgent(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 100<<20)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "missing or invalid file upload")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read upload")
+		return
+	}
+
+	opts := agentlibrary.ImportOptions{
+		Slug:       strings.TrimSpace(r.FormValue("slug")),
+		OnConflict: r.FormValue("on_conflict"),
+		SkipMemory: r.FormValue("skip_memory") == "true",
+		DryRun:     r.FormValue("dry_run") == "true",
+		ProviderAvailable: func(name string) bool {
+			if h.agentMgr == nil || h.agentMgr.Providers == nil {
+				return false
+			}
+			p := h.agentMgr.Providers.Get(name)
+			return p != nil && p.IsConfigured()
+		},
+	}
+	if raw := r.FormValue("tool_map"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.ToolMap); err != nil {
+			writeError(w, http.StatusBadRequest, "tool_map must be a JSON object")
+			return
+		}
+	}
+
+	res, err := agentlibrary.ImportBundle(h.db, h.dataDir, h.memoryMgr, data, opts)
+	switch {
+	case errors.Is(err, agentlibrary.ErrSlugTaken):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, agentlibrary.ErrInvalidBundle), errors.Is(err, agentlibrary.ErrInvalidImport):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("import failed: %v", err))
+		return
+	}
+	if res.DryRun {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "agent_imported", "agent", "agent_role", res.Slug, res.Name)
+
+	writeJSON(w, http.StatusCreated, res)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -116,6 +117,9 @@ func (h *AgentRolesHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name              string  `json:"name"`
 		Slug              string  `json:"slug"`
@@ -208,6 +212,9 @@ func (h *AgentRolesHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	var existing models.AgentRole
@@ -318,6 +325,9 @@ func (h *AgentRolesHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	var exists bool
@@ -332,6 +342,7 @@ func (h *AgentRolesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Remove tool access grants
 	h.db.Exec("DELETE FROM agent_tool_access WHERE agent_role_slug = ?", slug)
+	h.db.Exec("DELETE FROM mcp_server_grants WHERE scope_type = 'agent' AND scope_id = ?", slug)
 
 	_, err = h.db.Exec("DELETE FROM agent_roles WHERE slug = ?", slug)
 	if err != nil {
@@ -349,6 +360,9 @@ func (h *AgentRolesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	var currentEnabled bool
@@ -370,6 +384,9 @@ func (h *AgentRolesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	r.ParseMultipartForm(5 << 20) // 5MB max
 
 	file, header, err := r.FormFile("avatar")
@@ -478,6 +495,9 @@ func (h *AgentRolesHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) UpdateFile(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 	filename := chi.URLParam(r, "*")
 	if filename == "" {
@@ -502,6 +522,9 @@ func (h *AgentRolesHandler) UpdateFile(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) InitFiles(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	var name, systemPrompt string
@@ -560,6 +583,9 @@ func (h *AgentRolesHandler) GetGatewayFile(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *AgentRolesHandler) UpdateGatewayFile(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	filename := chi.URLParam(r, "*")
 	if filename == "" {
 		writeError(w, http.StatusBadRequest, "filename is required")
@@ -595,6 +621,9 @@ func (h *AgentRolesHandler) GetGatewayMemory(w http.ResponseWriter, r *http.Requ
 const describeAvatarPrompt = "Describe this character's visual appearance in 2-3 sentences for use as a reference in AI image generation. Focus on species/form, colors, clothing, distinctive features, and art style. Be specific and concise."
 
 func (h *AgentRolesHandler) DescribeAvatar(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		AvatarPath string `json:"avatar_path"`
 	}
@@ -771,6 +800,9 @@ func (h *AgentRolesHandler) ListTools(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AgentRolesHandler) GrantToolAccess(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 	toolID := chi.URLParam(r, "toolId")
 
@@ -790,6 +822,9 @@ func (h *AgentRolesHandler) GrantToolAccess(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *AgentRolesHandler) RevokeToolAccess(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 	toolID := chi.URLParam(r, "toolId")
 
@@ -811,6 +846,9 @@ func (h *AgentRolesHandler) RevokeToolAccess(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *AgentRolesHandler) UpdateToolOwner(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	toolID := chi.URLParam(r, "id")
 
 	var req struct {
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+// APITokensHandler manages the signed-in user's personal access tokens.
+type APITokensHandler struct {
+	db *database.DB
+}
+
+func NewAPITokensHandler(db *database.DB) *APITokensHandler {
+	return &APITokensHandler{db: db}
+}
+
+type apiTokenEntry struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Scopes lists the scopes a token can be given.
+func (h *APITokensHandler) Scopes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, auth.Scopes)
+}
+
+func (h *APITokensHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	rows, err := h.db.Query(
+		`SELECT id, name, token_prefix, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at
+		 FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list tokens")
+		return
+	}
+	defer rows.Close()
+
+	list := []apiTokenEntry{}
+	for rows.Next() {
+		var t apiTokenEntry
+		var scopesJSON string
+		var expiresAt, lastUsedAt, revokedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.TokenPrefix, &scopesJSON, &expiresAt, &lastUsedAt, &t.LastUsedIP, &revokedAt, &t.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to scan token")
+			return
+		}
+		json.Unmarshal([]byte(scopesJSON), &t.Scopes)
+		t.ExpiresAt = nullTimePtr(expiresAt)
+		t.LastUsedAt = nullTimePtr(lastUsedAt)
+		t.RevokedAt = nullTimePtr(revokedAt)
+		list = append(list, t)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Create issues a new token. The plaintext is in this response and nowhere
+// else — only its hash is stored.
+func (h *APITokensHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+	for _, s := range req.Scopes {
+		if !auth.ValidScope(s) {
+			writeError(w, http.StatusBadRequest, "unknown scope: "+s)
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > 3650 {
+		writeError(w, http.StatusBadRequest, "expires_in_days must be between 0 (never) and 3650")
+		return
+	}
+
+	token, err := auth.GenerateAPIToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	now := time.Now().UTC()
+	entry := apiTokenEntry{
+		ID:          generateID(),
+		Name:        req.Name,
+		TokenPrefix: token[:len(auth.APITokenPrefix)+8],
+		Scopes:      req.Scopes,
+		CreatedAt:   now,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := now.AddDate(0, 0, req.ExpiresInDays)
+		entry.ExpiresAt = &expires
+	}
+	scopesJSON, _ := json.Marshal(req.Scopes)
+
+	userID := middleware.GetUserID(r.Context())
+	_, err = h.db.Exec(
+		`INSERT INTO api_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, userID, entry.Name, auth.HashAPIToken(token), entry.TokenPrefix, string(scopesJSON), entry.ExpiresAt, now,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+
+	h.db.LogAudit(userID, "api_token_created", "auth", "api_token", entry.ID, entry.Name+" ["+strings.Join(req.Scopes, ",")+"]")
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":     token,
+		"api_token": entry,
+	})
+}
+
+// Revoke disables a token immediately. The row is kept so its history stays
+// readable in the list and the audit log.
+func (h *APITokensHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := middleware.GetUserID(r.Context())
+
+	res, err := h.db.Exec(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id, userID,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "token not found")
+		return
+	}
+
+	h.db.LogAudit(userID, "api_token_revoked", "auth", "api_token", id, "")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
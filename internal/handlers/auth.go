@@ -69,9 +69,9 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	var user models.User
 	err := h.db.QueryRow(
-		"SELECT id, username, password_hash, display_name, avatar_path, created_at, updated_at FROM users WHERE username = ?",
+		"SELECT id, username, password_hash, display_name, avatar_path, role, created_at, updated_at FROM users WHERE username = ?",
 		req.Username,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.AvatarPath, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.AvatarPath, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		writeError(w, http.StatusUnauthorized, "invalid credentials")
 		return
@@ -209,7 +209,12 @@ func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"message": "profile updated"})
 }
 
+// DeleteAccount wipes the whole install, not just the caller's account, so
+// only an admin may do it.
 func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	userID := middleware.GetUserID(r.Context())
 
 	// Delete all data including user account. Order: standalone/child tables
@@ -263,9 +268,9 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
 	var user models.User
 	err := h.db.QueryRow(
-		"SELECT id, username, display_name, avatar_path, created_at, updated_at FROM users WHERE id = ?",
+		"SELECT id, username, display_name, avatar_path, role, created_at, updated_at FROM users WHERE id = ?",
 		userID,
-	).Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarPath, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarPath, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "user not found")
 		return
@@ -416,8 +421,8 @@ func (h *SetupHandler) Init(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 
 	_, err = h.db.Exec(
-		"INSERT INTO users (id, username, password_hash, display_name, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
-		id, req.Username, hash, displayName, now, now,
+		"INSERT INTO users (id, username, password_hash, display_name, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, req.Username, hash, displayName, auth.RoleAdmin, now, now,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create admin user")
@@ -482,6 +487,7 @@ func (h *SetupHandler) Init(w http.ResponseWriter, r *http.Request) {
 			Username:    req.Username,
 			DisplayName: displayName,
 			AvatarPath:  "",
+			Role:        auth.RoleAdmin,
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		},
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/backup"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/middleware"
 )
@@ -14,6 +17,7 @@ type BackupManager interface {
 	UpdateConfig(cfg map[string]string) error
 	RunNow()
 	IsRunning() bool
+	Restore(opts backup.RestoreOptions) (*backup.RestoreResult, error)
 }
 
 type BackupHandler struct {
@@ -26,11 +30,17 @@ func NewBackupHandler(db *database.DB, mgr BackupManager) *BackupHandler {
 }
 
 func (h *BackupHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	cfg := h.mgr.GetConfig()
 	writeJSON(w, http.StatusOK, cfg)
 }
 
 func (h *BackupHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req map[string]string
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -38,6 +48,10 @@ func (h *BackupHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.mgr.UpdateConfig(req); err != nil {
+		if errors.Is(err, backup.ErrInvalidRepoURL) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "failed to update backup config: "+err.Error())
 		return
 	}
@@ -49,6 +63,9 @@ func (h *BackupHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BackupHandler) RunNow(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	if h.mgr.IsRunning() {
 		writeError(w, http.StatusConflict, "backup already running")
 		return
@@ -62,7 +79,44 @@ func (h *BackupHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "triggered"})
 }
 
+// Restore loads a snapshot from the backup repository into this instance.
+// With dry_run set it only reports what would be created and overwritten.
+func (h *BackupHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req backup.RestoreOptions
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := h.mgr.Restore(req)
+	if errors.Is(err, backup.ErrBusy) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, backup.ErrInvalidRef) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "restore failed: "+err.Error())
+		return
+	}
+
+	if !req.DryRun {
+		userID := middleware.GetUserID(r.Context())
+		h.db.LogAudit(userID, "backup_restored", "backup", "backup", result.Commit, "manifest "+result.ManifestVersion)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (h *BackupHandler) TestConnection(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		RepoURL    string `json:"repo_url"`
 		AuthToken  string `json:"auth_token"`
@@ -94,6 +148,9 @@ func (h *BackupHandler) TestConnection(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BackupHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	limit := 20
 	offset := 0
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -151,5 +208,8 @@ func (h *BackupHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BackupHandler) DetectGit(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	writeJSON(w, http.StatusOK, detectGitMethodFromHandler())
 }
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+// maxReportDays bounds the spend report's range. The ledger gets a row per
+// run, so an unbounded range is a full table scan per breakdown.
+const maxReportDays = 366
+
+type BudgetsHandler struct {
+	db  *database.DB
+	mgr *budget.Manager
+}
+
+func NewBudgetsHandler(db *database.DB, mgr *budget.Manager) *BudgetsHandler {
+	return &BudgetsHandler{db: db, mgr: mgr}
+}
+
+type budgetRequest struct {
+	ScopeType   string  `json:"scope_type"`
+	ScopeID     string  `json:"scope_id"`
+	Period      string  `json:"period"`
+	LimitUSD    float64 `json:"limit_usd"`
+	LimitTokens int64   `json:"limit_tokens"`
+	SoftPercent *int    `json:"soft_percent"`
+	Enabled     *bool   `json:"enabled"`
+}
+
+func (req *budgetRequest) validate() error {
+	switch req.ScopeType {
+	case budget.ScopeAgent, budget.ScopeWorkspace, budget.ScopeSchedule:
+	default:
+		return fmt.Errorf("scope_type must be agent, workspace or schedule")
+	}
+	if req.ScopeID == "" {
+		return fmt.Errorf("scope_id is required")
+	}
+	if req.Period == "" {
+		req.Period = budget.PeriodDaily
+	}
+	if req.Period != budget.PeriodDaily && req.Period != budget.PeriodMonthly {
+		return fmt.Errorf("period must be daily or monthly")
+	}
+	if req.LimitUSD < 0 || req.LimitTokens < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if req.LimitUSD == 0 && req.LimitTokens == 0 {
+		return fmt.Errorf("set limit_usd, limit_tokens or both")
+	}
+	if req.SoftPercent != nil && (*req.SoftPercent < 0 || *req.SoftPercent > 100) {
+		return fmt.Errorf("soft_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// scopeExists checks the budget names something real, so a typo doesn't
+// leave a budget that silently caps nothing.
+func (h *BudgetsHandler) scopeExists(scopeType, scopeID string) bool {
+	query := "SELECT 1 FROM agent_roles WHERE slug = ?"
+	switch scopeType {
+	case budget.ScopeWorkspace:
+		query = "SELECT 1 FROM workspaces WHERE id = ?"
+	case budget.ScopeSchedule:
+		query = "SELECT 1 FROM schedules WHERE id = ?"
+	}
+	var one int
+	return h.db.QueryRow(query, scopeID).Scan(&one) == nil
+}
+
+// List returns every budget with its spend so far this period.
+func (h *BudgetsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	budgets, err := h.mgr.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list budgets")
+		return
+	}
+	writeJSON(w, http.StatusOK, budgets)
+}
+
+func (h *BudgetsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req budgetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.scopeExists(req.ScopeType, req.ScopeID) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s %q not found", req.ScopeType, req.ScopeID))
+		return
+	}
+	var exists string
+	if h.db.QueryRow("SELECT id FROM budgets WHERE scope_type = ? AND scope_id = ? AND period = ?",
+		req.ScopeType, req.ScopeID, req.Period).Scan(&exists) == nil {
+		writeError(w, http.StatusConflict, "a "+req.Period+" budget for this "+req.ScopeType+" already exists")
+		return
+	}
+
+	softPercent, enabled := 80, true
+	if req.SoftPercent != nil {
+		softPercent = *req.SoftPercent
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	id := generateID()
+	now := time.Now().UTC()
+	if _, err := h.db.Exec(
+		`INSERT INTO budgets (id, scope_type, scope_id, period, limit_usd, limit_tokens, soft_percent, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, req.ScopeType, req.ScopeID, req.Period, req.LimitUSD, req.LimitTokens, softPercent, enabled, now, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create budget")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "budget_created", "budget", "budget", id,
+		fmt.Sprintf("%s %s %s usd=%.2f tokens=%d", req.Period, req.ScopeType, req.ScopeID, req.LimitUSD, req.LimitTokens))
+
+	b, _ := h.mgr.Get(id)
+	writeJSON(w, http.StatusCreated, b)
+}
+
+// Update changes a budget's limits, threshold or enabled flag. Its scope and
+// period are fixed; delete and recreate to move it.
+func (h *BudgetsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	existing, err := h.mgr.Get(id)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "budget not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load budget")
+		return
+	}
+
+	var req budgetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.ScopeType, req.ScopeID, req.Period = existing.ScopeType, existing.ScopeID, existing.Period
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	softPercent, enabled := existing.SoftPercent, existing.Enabled
+	if req.SoftPercent != nil {
+		softPercent = *req.SoftPercent
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	if _, err := h.db.Exec(
+		"UPDATE budgets SET limit_usd = ?, limit_tokens = ?, soft_percent = ?, enabled = ?, updated_at = ? WHERE id = ?",
+		req.LimitUSD, req.LimitTokens, softPercent, enabled, time.Now().UTC(), id,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update budget")
+		return
+	}
+	// A raised limit should be able to warn again this period.
+	h.db.Exec("DELETE FROM budget_alerts WHERE budget_id = ?", id)
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "budget_updated", "budget", "budget", id,
+		fmt.Sprintf("usd=%.2f tokens=%d enabled=%t", req.LimitUSD, req.LimitTokens, enabled))
+
+	b, _ := h.mgr.Get(id)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func (h *BudgetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	res, err := h.db.Exec("DELETE FROM budgets WHERE id = ?", id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete budget")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "budget not found")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "budget_deleted", "budget", "budget", id, "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// Report aggregates spend by agent, model, provider, source and day. from and
+// to are YYYY-MM-DD dates in UTC, both inclusive; the default is the last 30
+// days.
+func (h *BudgetsHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	today := budget.PeriodStart(budget.PeriodDaily, time.Now())
+	from, to := today.AddDate(0, 0, -29), today
+	for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(param); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, param+" must be a YYYY-MM-DD date")
+				return
+			}
+			*dst = t
+		}
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if to.Sub(from) > maxReportDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("the report covers at most %d days", maxReportDays))
+		return
+	}
+
+	rep, err := h.mgr.Report(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build spend report")
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
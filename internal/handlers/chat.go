@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -89,6 +90,10 @@ func parseAggregatedTimestamp(value string) (time.Time, error) {
 }
 
 func (h *ChatHandler) ListThreads(w http.ResponseWriter, r *http.Request) {
+	workspaceID := requestWorkspaceID(r, h.db)
+	if !requireWorkspaceRole(w, r, h.db, workspaceID, auth.RoleViewer) {
+		return
+	}
 	limit := 100
 	offset := 0
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -103,7 +108,7 @@ func (h *ChatHandler) ListThreads(w http.ResponseWriter, r *http.Request) {
 	}
 	// ?pinned=1 / ?pinned=0 backs the All Chats / Pinned tabs; omitted returns both.
 	pinnedFilter := ""
-	args := []interface{}{activeWorkspaceID(h.db)}
+	args := []interface{}{workspaceID}
 	switch r.URL.Query().Get("pinned") {
 	case "1", "true":
 		pinnedFilter = " AND t.pinned = 1"
@@ -193,6 +198,10 @@ func (h *ChatHandler) ActiveThreadIds(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) CreateThread(w http.ResponseWriter, r *http.Request) {
+	workspaceID := requestWorkspaceID(r, h.db)
+	if !requireWorkspaceRole(w, r, h.db, workspaceID, auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Title string `json:"title"`
 	}
@@ -209,7 +218,7 @@ func (h *ChatHandler) CreateThread(w http.ResponseWriter, r *http.Request) {
 
 	_, err := h.db.Exec(
 		"INSERT INTO chat_threads (id, title, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-		id, req.Title, activeWorkspaceID(h.db), now, now,
+		id, req.Title, workspaceID, now, now,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create thread")
@@ -295,7 +304,10 @@ func archiveThreadCosts(db *database.DB, threadID string) {
 }
 
 func (h *ChatHandler) DeleteThread(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 
 	// Focused child threads are hidden from the main chat list but still own
 	// messages, provider sessions, members, and spend. Delete them alongside
@@ -353,7 +365,10 @@ func (h *ChatHandler) DeleteThread(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) UpdateThread(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 
 	var req struct {
 		Title string `json:"title"`
@@ -386,7 +401,10 @@ func (h *ChatHandler) UpdateThread(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) ThreadStatus(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
 
 	// Check for active work orders on this thread
 	var woID, woStatus, woTitle, woType string
@@ -498,21 +516,45 @@ func (h *ChatHandler) ActiveThreads(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, out)
 }
 
-func (h *ChatHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+// requireThread resolves the thread in the URL and checks the caller's role
+// in the workspace the thread belongs to, whichever workspace the request is
+// otherwise acting in. On failure it writes a 404 or 403.
+func (h *ChatHandler) requireThread(w http.ResponseWriter, r *http.Request, min string) (string, bool) {
 	threadID := chi.URLParam(r, "id")
+	return threadID, h.requireThreadRole(w, r, threadID, min)
+}
 
-	var exists string
-	err := h.db.QueryRow(
-		"SELECT id FROM chat_threads WHERE id = ? AND workspace_id = ?",
-		threadID, activeWorkspaceID(h.db),
-	).Scan(&exists)
-	if err != nil {
+// requireThreadRole checks the caller's role in a thread's workspace.
+func (h *ChatHandler) requireThreadRole(w http.ResponseWriter, r *http.Request, threadID, min string) bool {
+	var workspaceID string
+	if err := h.db.QueryRow(
+		"SELECT COALESCE(workspace_id, '') FROM chat_threads WHERE id = ?", threadID,
+	).Scan(&workspaceID); err != nil {
 		writeError(w, http.StatusNotFound, "thread not found")
+		return false
+	}
+	return requireWorkspaceRole(w, r, h.db, workspaceID, min)
+}
+
+func (h *ChatHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
 		return
 	}
 
+	messages, err := h.branchMessages(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// branchMessages loads the messages on a thread's active branch, each with its
+// reactions, focused-thread summary and any alternatives to switch to.
+func (h *ChatHandler) branchMessages(threadID string) ([]models.ChatMessage, error) {
 	rows, err := h.db.Query(
-		`SELECT m.id, m.thread_id, m.role, m.content, m.agent_role_slug,
+		`SELECT m.id, m.thread_id, COALESCE(m.parent_id, ''), m.role, m.content, m.agent_role_slug,
 		        m.cost_usd, m.input_tokens, m.output_tokens, m.widget_data,
 		        m.image_url, m.tool_calls_json, m.stopped, m.created_at,
 		        COALESCE((
@@ -526,13 +568,12 @@ func (h *ChatHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		            WHERE child.root_message_id = m.id
 		        ), 0)
 		 FROM chat_messages m
-		 WHERE m.thread_id = ?
+		 WHERE m.id IN (`+database.ActiveBranch+`)
 		 ORDER BY m.created_at ASC`,
 		threadID,
 	)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list messages")
-		return
+		return nil, fmt.Errorf("failed to list messages")
 	}
 	defer rows.Close()
 
@@ -542,13 +583,12 @@ func (h *ChatHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		var m models.ChatMessage
 		var tcJSON *string
 		if err := rows.Scan(
-			&m.ID, &m.ThreadID, &m.Role, &m.Content, &m.AgentRoleSlug,
+			&m.ID, &m.ThreadID, &m.ParentID, &m.Role, &m.Content, &m.AgentRoleSlug,
 			&m.CostUSD, &m.InputTokens, &m.OutputTokens, &m.WidgetData,
 			&m.ImageURL, &tcJSON, &m.Stopped, &m.CreatedAt,
 			&m.ChildThreadID, &m.ThreadReplyCount,
 		); err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to scan message")
-			return
+			return nil, fmt.Errorf("failed to scan message")
 		}
 		if tcJSON != nil && *tcJSON != "" {
 			m.ToolCalls = json.RawMessage(*tcJSON)
@@ -587,19 +627,13 @@ func (h *ChatHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	writeJSON(w, http.StatusOK, messages)
+	h.attachSiblings(threadID, messages)
+	return messages, nil
 }
 
 func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
-
-	var exists string
-	err := h.db.QueryRow(
-		"SELECT id FROM chat_threads WHERE id = ? AND workspace_id = ?",
-		threadID, activeWorkspaceID(h.db),
-	).Scan(&exists)
-	if err != nil {
-		writeError(w, http.StatusNotFound, "thread not found")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
 		return
 	}
 
@@ -630,7 +664,7 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	userMsgID := generateID()
 	now := time.Now().UTC()
 
-	_, err = h.db.Exec(
+	_, err := h.db.Exec(
 		"INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, created_at) VALUES (?, ?, ?, ?, ?, ?)",
 		userMsgID, threadID, req.Role, req.Content, req.AgentRoleSlug, now,
 	)
@@ -693,11 +727,8 @@ Do not editorialise and do not add a preamble — start directly with the summar
 // PinThread archives a thread: it becomes read-only and gains a long-form
 // summary so it stays useful as a reference.
 func (h *ChatHandler) PinThread(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
-
-	var exists string
-	if err := h.db.QueryRow("SELECT id FROM chat_threads WHERE id = ?", threadID).Scan(&exists); err != nil {
-		writeError(w, http.StatusNotFound, "thread not found")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
 		return
 	}
 	if h.agentManager == nil {
@@ -758,7 +789,10 @@ func (h *ChatHandler) PinThread(w http.ResponseWriter, r *http.Request) {
 // GetPin returns a thread's pin state and summary, so the chat view can render
 // the archive banner and summary card without loading the whole thread list.
 func (h *ChatHandler) GetPin(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
 
 	var pinned bool
 	var summary string
@@ -781,7 +815,10 @@ func (h *ChatHandler) GetPin(w http.ResponseWriter, r *http.Request) {
 // UnpinThread returns a thread to normal, editable use. The summary is kept so
 // re-pinning doesn't have to pay for it again.
 func (h *ChatHandler) UnpinThread(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 	if _, err := h.db.Exec("UPDATE chat_threads SET pinned = 0, pinned_at = NULL WHERE id = ?", threadID); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to unpin thread")
 		return
@@ -794,7 +831,7 @@ func (h *ChatHandler) UnpinThread(w http.ResponseWriter, r *http.Request) {
 // threadTranscript renders a thread as "[role]: content" lines.
 func (h *ChatHandler) threadTranscript(threadID string) (string, error) {
 	rows, err := h.db.Query(
-		"SELECT role, content FROM chat_messages WHERE thread_id = ? ORDER BY created_at ASC",
+		"SELECT role, content FROM chat_messages WHERE id IN ("+database.ActiveBranch+") ORDER BY created_at ASC",
 		threadID,
 	)
 	if err != nil {
@@ -875,11 +912,8 @@ func (h *ChatHandler) attachedToolsSection(toolIDs []string, threadID string) st
 }
 
 func (h *ChatHandler) ThreadStats(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
-
-	var exists string
-	if err := h.db.QueryRow("SELECT id FROM chat_threads WHERE id = ?", threadID).Scan(&exists); err != nil {
-		writeError(w, http.StatusNotFound, "thread not found")
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
 		return
 	}
 
@@ -920,11 +954,8 @@ func (h *ChatHandler) ThreadStats(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) CompactThread(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
-
-	var exists string
-	if err := h.db.QueryRow("SELECT id FROM chat_threads WHERE id = ?", threadID).Scan(&exists); err != nil {
-		writeError(w, http.StatusNotFound, "thread not found")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
 		return
 	}
 
@@ -982,16 +1013,21 @@ func (h *ChatHandler) compactThreadInternal(ctx context.Context, threadID string
 // those messages with a single summary, leaving the most recent
 // compactRetainMessages intact. The summary is inserted at the timestamp of the
 // oldest summarized message so it sorts ahead of the retained tail.
+//
+// Only the active branch is compacted, and never past the first message
+// another branch forks from: the summary takes that message's place for every
+// branch below it, so it must not describe messages those branches never had.
 func (h *ChatHandler) compactThreadWith(ctx context.Context, threadID string, summarize summarizeFunc) error {
 	type msgRow struct {
 		ID        string
+		ParentID  string
 		Role      string
 		Content   string
 		CreatedAt time.Time
 	}
 
 	rows, err := h.db.Query(
-		"SELECT id, role, content, created_at FROM chat_messages WHERE thread_id = ? ORDER BY created_at ASC, id ASC",
+		"SELECT id, COALESCE(parent_id, ''), role, content, created_at FROM chat_messages WHERE id IN ("+database.ActiveBranch+") ORDER BY created_at ASC, id ASC",
 		threadID,
 	)
 	if err != nil {
@@ -1000,7 +1036,7 @@ func (h *ChatHandler) compactThreadWith(ctx context.Context, threadID string, su
 	var msgs []msgRow
 	for rows.Next() {
 		var m msgRow
-		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.ParentID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
 			rows.Close()
 			return fmt.Errorf("failed to scan message: %w", err)
 		}
@@ -1024,6 +1060,14 @@ func (h *ChatHandler) compactThreadWith(ctx context.Context, threadID string, su
 	}
 
 	older := msgs[:len(msgs)-compactRetainMessages]
+	for i, m := range older {
+		var children int
+		h.db.QueryRow("SELECT COUNT(*) FROM chat_messages WHERE parent_id = ?", m.ID).Scan(&children)
+		if children > 1 {
+			older = older[:i+1]
+			break
+		}
+	}
 	var transcript strings.Builder
 	for _, m := range older {
 		transcript.WriteString(fmt.Sprintf("[%s]: %s\n\n", m.Role, m.Content))
@@ -1065,17 +1109,26 @@ func (h *ChatHandler) compactThreadWith(ctx context.Context, threadID string, su
 	for i, id := range olderIDs {
 		delArgs[i] = id
 	}
-	if _, err := tx.Exec("DELETE FROM chat_messages WHERE id IN ("+placeholders+")", delArgs...); err != nil {
-		return fmt.Errorf("failed to delete messages: %w", err)
-	}
-
+	// The summary takes the summarized messages' place in the tree: it follows
+	// what they followed, and whatever followed them now follows it.
 	now := time.Now().UTC()
+	summaryID := generateID()
 	if _, err := tx.Exec(
-		"INSERT INTO chat_messages (id, thread_id, role, content, agent_role_slug, cost_usd, input_tokens, output_tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		generateID(), threadID, "system", summary, "", costUSD, inTok, outTok, older[0].CreatedAt,
+		"INSERT INTO chat_messages (id, thread_id, parent_id, role, content, agent_role_slug, cost_usd, input_tokens, output_tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		summaryID, threadID, older[0].ParentID, "system", summary, "", costUSD, inTok, outTok, older[0].CreatedAt,
 	); err != nil {
 		return fmt.Errorf("failed to insert summary: %w", err)
 	}
+	if _, err := tx.Exec(
+		"UPDATE chat_messages SET parent_id = ? WHERE parent_id IN ("+placeholders+") AND id NOT IN ("+placeholders+")",
+		append(append([]interface{}{summaryID}, delArgs...), delArgs...)...,
+	); err != nil {
+		return fmt.Errorf("failed to relink messages: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM chat_messages WHERE id IN ("+placeholders+")", delArgs...); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
 
 	// The watermark stops the retained tail's pre-compaction input_tokens from
 	// counting against the context window and re-triggering compaction forever.
@@ -1135,7 +1188,8 @@ func (h *ChatHandler) getEffectiveContextLimit(threadID string) int {
 			for rows.Next() {
 				var m string
 				if rows.Scan(&m) == nil && m != "" && h.agentManager != nil {
-					if w := llm.ContextWindowForModel(h.agentManager.Provider().ResolveModel(m, llm.ModelSonnet)); w > best {
+					provider := h.agentManager.Provider()
+					if w := llm.ContextWindowFor(provider.Name(), provider.ResolveModel(m, llm.ModelSonnet)); w > best {
 						best = w
 					}
 				}
@@ -1143,7 +1197,8 @@ func (h *ChatHandler) getEffectiveContextLimit(threadID string) int {
 		}
 	}
 	if best == 0 && h.agentManager != nil {
-		best = llm.ContextWindowForModel(h.agentManager.Provider().ResolveModel(h.agentManager.BuilderModel, llm.ModelSonnet))
+		provider := h.agentManager.Provider()
+		best = llm.ContextWindowFor(provider.Name(), provider.ResolveModel(h.agentManager.BuilderModel, llm.ModelSonnet))
 	}
 	if best == 0 {
 		best = llm.ContextWindowForModel(llm.ModelSonnet)
@@ -1172,7 +1227,8 @@ func (h *ChatHandler) getEffectiveContextLimit(threadID string) int {
 //
 // Messages created at or before the last compaction are excluded: their counts
 // describe a history that no longer exists, and counting them would keep the
-// thread pinned above the threshold forever.
+// thread pinned above the threshold forever. Likewise only the active branch
+// counts; the others are never sent.
 func (h *ChatHandler) threadContextUsed(threadID string) int {
 	var compactedAt sql.NullTime
 	h.db.QueryRow("SELECT compacted_at FROM chat_threads WHERE id = ?", threadID).Scan(&compactedAt)
@@ -1187,7 +1243,7 @@ func (h *ChatHandler) threadContextUsed(threadID string) int {
 	// Size of the conversation that will actually be sent.
 	var liveChars int
 	h.db.QueryRow(
-		"SELECT COALESCE(SUM(LENGTH(content)), 0) FROM chat_messages WHERE thread_id = ?"+liveClause,
+		"SELECT COALESCE(SUM(LENGTH(content)), 0) FROM chat_messages WHERE id IN ("+database.ActiveBranch+")"+liveClause,
 		liveArgs...,
 	).Scan(&liveChars)
 	estimate := liveChars/charsPerTokenEstimate + systemPromptTokenAllowance
@@ -1197,7 +1253,7 @@ func (h *ChatHandler) threadContextUsed(threadID string) int {
 	var peakAt sql.NullString
 	err := h.db.QueryRow(
 		`SELECT COALESCE(MAX(input_tokens), 0), COALESCE(MAX(created_at), '') FROM chat_messages
-		 WHERE thread_id = ? AND role = 'assistant' AND input_tokens > 0`+liveClause,
+		 WHERE id IN (`+database.ActiveBranch+`) AND role = 'assistant' AND input_tokens > 0`+liveClause,
 		args...,
 	).Scan(&reported, &peakAt)
 	if err != nil || reported == 0 {
@@ -1218,7 +1274,7 @@ func (h *ChatHandler) threadContextUsed(threadID string) int {
 		var pendingChars int
 		h.db.QueryRow(
 			`SELECT COALESCE(SUM(LENGTH(content)), 0) FROM chat_messages
-			 WHERE thread_id = ? AND created_at > ?`, threadID, peakAt.String,
+			 WHERE id IN (`+database.ActiveBranch+`) AND created_at > ?`, threadID, peakAt.String,
 		).Scan(&pendingChars)
 		reported += pendingChars / charsPerTokenEstimate
 	}
@@ -1258,7 +1314,7 @@ func (h *ChatHandler) shouldAutoCompact(threadID string) bool {
 	// Below the retain floor there is nothing compaction could remove, so
 	// triggering would loop on a thread it cannot shrink.
 	var msgCount int
-	if err := h.db.QueryRow("SELECT COUNT(*) FROM chat_messages WHERE thread_id = ?", threadID).Scan(&msgCount); err != nil || msgCount <= compactRetainMessages {
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM chat_messages WHERE id IN ("+database.ActiveBranch+")", threadID).Scan(&msgCount); err != nil || msgCount <= compactRetainMessages {
 		return false
 	}
 	return compactionNeeded(h.threadContextUsed(threadID), h.getEffectiveContextLimit(threadID), h.agentManager.AutoCompactThreshold)
@@ -1342,6 +1398,9 @@ func (h *ChatHandler) ToggleReaction(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "message not found")
 		return
 	}
+	if !h.requireThreadRole(w, r, threadID, auth.RoleEditor) {
+		return
+	}
 
 	// Toggle: check if reaction exists
 	var existingID string
@@ -1409,7 +1468,10 @@ func (h *ChatHandler) isConfirmationEnabled() bool {
 }
 
 func (h *ChatHandler) StopThread(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 	userID := middleware.GetUserID(r.Context())
 	stopped := false
 
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// Conversation branching. Editing a user message or regenerating a reply does
+// not rewrite the thread: it forks it at that message, the thread switches to
+// the new branch, and the old one stays one click away. Migration 087 holds the
+// tree; database.ActiveBranch is how everything else reads it.
+
+type branchPoint struct {
+	ID            string
+	ParentID      string
+	Role          string
+	Content       string
+	AgentRoleSlug string
+}
+
+// threadMessage loads one message of a thread, on any branch.
+func (h *ChatHandler) threadMessage(threadID, messageID string) (*branchPoint, error) {
+	var m branchPoint
+	err := h.db.QueryRow(
+		"SELECT id, COALESCE(parent_id, ''), role, content, agent_role_slug FROM chat_messages WHERE id = ? AND thread_id = ?",
+		messageID, threadID,
+	).Scan(&m.ID, &m.ParentID, &m.Role, &m.Content, &m.AgentRoleSlug)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// attachSiblings fills in the alternatives at each point of a branch.
+func (h *ChatHandler) attachSiblings(threadID string, messages []models.ChatMessage) {
+	if len(messages) == 0 {
+		return
+	}
+	rows, err := h.db.Query(
+		"SELECT id, COALESCE(parent_id, '') FROM chat_messages WHERE thread_id = ? ORDER BY created_at ASC, rowid ASC",
+		threadID,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	children := map[string][]string{}
+	for rows.Next() {
+		var id, parentID string
+		if rows.Scan(&id, &parentID) == nil {
+			children[parentID] = append(children[parentID], id)
+		}
+	}
+	for i := range messages {
+		if siblings := children[messages[i].ParentID]; len(siblings) > 1 {
+			messages[i].Siblings = siblings
+		}
+	}
+}
+
+// forkableThread resolves the thread in the URL, refusing threads that can't
+// change branch right now. A reply still being written would land on whichever
+// branch is current when it finishes, so the thread must be idle.
+func (h *ChatHandler) forkableThread(w http.ResponseWriter, r *http.Request) (string, bool) {
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return "", false
+	}
+	if h.threadIsPinned(threadID) {
+		writeError(w, http.StatusConflict, "this chat is pinned and read-only — unpin it to continue the conversation")
+		return "", false
+	}
+	busy := false
+	if _, processing := h.threadCancels.Load(threadID); processing {
+		busy = true
+	} else if h.agentManager != nil {
+		if ss := h.agentManager.GetStreamState(threadID); ss != nil && ss.Active {
+			busy = true
+		}
+	}
+	if busy {
+		writeError(w, http.StatusConflict, "an agent is still replying in this chat — wait for it or stop it first")
+		return "", false
+	}
+	return threadID, true
+}
+
+func (h *ChatHandler) broadcastBranchChanged(threadID, headID string) {
+	if h.agentManager != nil {
+		h.agentManager.Broadcast("thread_branch_changed", map[string]interface{}{
+			"thread_id":       threadID,
+			"head_message_id": headID,
+		})
+	}
+}
+
+// EditMessage forks the thread at a user message: the edited text is saved as
+// a new sibling of the original, the thread switches to it, and the agent
+// answers it as though it had been sent that way.
+func (h *ChatHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.forkableThread(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+	orig, err := h.threadMessage(threadID, chi.URLParam(r, "messageId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	if orig.Role != "user" {
+		writeError(w, http.StatusBadRequest, "only user messages can be edited — regenerate a reply instead")
+		return
+	}
+
+	msgID := generateID()
+	now := time.Now().UTC()
+	if _, err := h.db.Exec(
+		"INSERT INTO chat_messages (id, thread_id, parent_id, role, content, agent_role_slug, created_at) VALUES (?, ?, ?, 'user', ?, ?, ?)",
+		msgID, threadID, orig.ParentID, req.Content, orig.AgentRoleSlug, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save the edited message")
+		return
+	}
+	if err := h.db.SetThreadHead(threadID, msgID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to switch to the edited message")
+		return
+	}
+	h.db.Exec("UPDATE chat_threads SET updated_at = ? WHERE id = ?", now, threadID)
+	h.broadcastBranchChanged(threadID, msgID)
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "chat_message_edited", "chat", "chat_thread", threadID, req.Content)
+
+	if h.agentManager != nil {
+		go h.handleAgentRouting(threadID, req.Content, userID, orig.AgentRoleSlug, false)
+	}
+
+	msg := []models.ChatMessage{{
+		ID:            msgID,
+		ThreadID:      threadID,
+		ParentID:      orig.ParentID,
+		Role:          "user",
+		Content:       req.Content,
+		AgentRoleSlug: orig.AgentRoleSlug,
+		CreatedAt:     now,
+	}}
+	h.attachSiblings(threadID, msg)
+	writeJSON(w, http.StatusCreated, msg[0])
+}
+
+// RegenerateMessage forks the thread at an agent's reply: the thread steps back
+// to the user message it answered and the agent answers again. The old reply
+// stays on its own branch.
+func (h *ChatHandler) RegenerateMessage(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.forkableThread(w, r)
+	if !ok {
+		return
+	}
+	if h.agentManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "no AI provider configured")
+		return
+	}
+	reply, err := h.threadMessage(threadID, chi.URLParam(r, "messageId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	if reply.Role != "assistant" {
+		writeError(w, http.StatusBadRequest, "only agent replies can be regenerated — edit your message instead")
+		return
+	}
+	prompt, err := h.threadMessage(threadID, reply.ParentID)
+	if err != nil || prompt.Role != "user" {
+		writeError(w, http.StatusBadRequest, "this reply doesn't answer a user message, so there is nothing to regenerate it from")
+		return
+	}
+
+	if err := h.db.SetThreadHead(threadID, prompt.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to step back to the message")
+		return
+	}
+	h.broadcastBranchChanged(threadID, prompt.ID)
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "chat_message_regenerated", "chat", "chat_thread", threadID, reply.ID)
+
+	// Ask the agent that answered, rather than letting the gateway pick anew:
+	// "try again" means the same agent, differently.
+	agentSlug := prompt.AgentRoleSlug
+	if agentSlug == "" && reply.AgentRoleSlug != gatewayRoleSlug {
+		agentSlug = reply.AgentRoleSlug
+	}
+	go h.handleAgentRouting(threadID, prompt.Content, userID, agentSlug, false)
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "regenerating", "head_message_id": prompt.ID})
+}
+
+// SwitchBranch shows another branch. The head moves to the newest message at
+// or below the one given, so picking an alternative edit lands on the latest
+// reply in that branch.
+func (h *ChatHandler) SwitchBranch(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.forkableThread(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, err := h.threadMessage(threadID, req.MessageID); err != nil {
+		writeError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	var headID, current string
+	if err := h.db.QueryRow(
+		`WITH RECURSIVE below(id) AS (
+		     SELECT ?
+		     UNION
+		     SELECT m.id FROM chat_messages m JOIN below b ON m.parent_id = b.id
+		 )
+		 SELECT m.id FROM chat_messages m JOIN below b ON b.id = m.id
+		 ORDER BY m.created_at DESC, m.rowid DESC LIMIT 1`,
+		req.MessageID,
+	).Scan(&headID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to find the branch")
+		return
+	}
+	h.db.QueryRow("SELECT head_message_id FROM chat_threads WHERE id = ?", threadID).Scan(&current)
+	if headID != current {
+		if err := h.db.SetThreadHead(threadID, headID); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to switch branch")
+			return
+		}
+		h.broadcastBranchChanged(threadID, headID)
+		userID := middleware.GetUserID(r.Context())
+		h.db.LogAudit(userID, "chat_branch_switched", "chat", "chat_thread", threadID, headID)
+	}
+
+	messages, err := h.branchMessages(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+func branchTestRouter(h *ChatHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(asUser(testAdminID))
+	r.Get("/chat/threads/{id}/messages", h.GetMessages)
+	r.Post("/chat/threads/{id}/messages", h.SendMessage)
+	r.Post("/chat/threads/{id}/messages/{messageId}/edit", h.EditMessage)
+	r.Post("/chat/threads/{id}/messages/{messageId}/regenerate", h.RegenerateMessage)
+	r.Post("/chat/threads/{id}/branch", h.SwitchBranch)
+	return r
+}
+
+func branchRequest(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func branchContents(t *testing.T, router http.Handler, threadID string) ([]models.ChatMessage, string) {
+	t.Helper()
+	rec := branchRequest(t, router, http.MethodGet, "/chat/threads/"+threadID+"/messages", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get messages: %d %s", rec.Code, rec.Body.String())
+	}
+	var msgs []models.ChatMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &msgs); err != nil {
+		t.Fatal(err)
+	}
+	var parts []string
+	for _, m := range msgs {
+		parts = append(parts, m.Content)
+	}
+	return msgs, strings.Join(parts, ",")
+}
+
+func TestEditForksThreadAndKeepsOriginalBranch(t *testing.T) {
+	h := newTestHandler(t)
+	router := branchTestRouter(h)
+	threadID := createTestThread(t, h)
+	addMessage(t, h, threadID, "user", "u1", 0, 1)
+	addMessage(t, h, threadID, "assistant", "a1", 0, 2)
+	u2 := addMessage(t, h, threadID, "user", "u2", 0, 3)
+	addMessage(t, h, threadID, "assistant", "a2", 0, 4)
+	h.db.PutProviderSession(threadID, "scout", "claude-cli", "session-1")
+
+	rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/messages/"+u2+"/edit", `{"content":"u2 edited"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("edit: %d %s", rec.Code, rec.Body.String())
+	}
+	var edited models.ChatMessage
+	json.Unmarshal(rec.Body.Bytes(), &edited)
+
+	msgs, got := branchContents(t, router, threadID)
+	if got != "u1,a1,u2 edited" {
+		t.Fatalf("branch after edit = %s", got)
+	}
+	if s := msgs[2].Siblings; len(s) != 2 || s[0] != u2 || s[1] != edited.ID {
+		t.Errorf("siblings = %v", s)
+	}
+	var history []string
+	for _, m := range h.fetchThreadHistory(threadID) {
+		history = append(history, m.Content)
+	}
+	if strings.Join(history, ",") != "u1,a1,u2 edited" {
+		t.Errorf("history = %v", history)
+	}
+	if s := h.db.GetProviderSession(threadID, "scout", "claude-cli"); s != "" {
+		t.Errorf("provider session survived the edit: %q", s)
+	}
+
+	// The next message continues the edited branch.
+	if rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/messages", `{"content":"u3"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("send: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, got := branchContents(t, router, threadID); got != "u1,a1,u2 edited,u3" {
+		t.Fatalf("branch after send = %s", got)
+	}
+
+	// Switching back lands on the original branch's last reply.
+	rec = branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/branch", `{"message_id":"`+u2+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("switch: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, got := branchContents(t, router, threadID); got != "u1,a1,u2,a2" {
+		t.Fatalf("branch after switch = %s", got)
+	}
+}
+
+func TestBranchChangesRefusedWhenNotAllowed(t *testing.T) {
+	h := newTestHandler(t)
+	router := branchTestRouter(h)
+	threadID := createTestThread(t, h)
+	u1 := addMessage(t, h, threadID, "user", "u1", 0, 1)
+	a1 := addMessage(t, h, threadID, "assistant", "a1", 0, 2)
+
+	if rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/messages/"+a1+"/edit", `{"content":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("editing a reply = %d", rec.Code)
+	}
+	if rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/messages/nope/edit", `{"content":"x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("editing a missing message = %d", rec.Code)
+	}
+
+	h.threadCancels.Store(threadID, context.CancelFunc(func() {}))
+	if rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/messages/"+u1+"/edit", `{"content":"x"}`); rec.Code != http.StatusConflict {
+		t.Errorf("editing mid-reply = %d", rec.Code)
+	}
+	h.threadCancels.Delete(threadID)
+
+	h.db.Exec("UPDATE chat_threads SET pinned = 1 WHERE id = ?", threadID)
+	if rec := branchRequest(t, router, http.MethodPost, "/chat/threads/"+threadID+"/branch", `{"message_id":"`+u1+`"}`); rec.Code != http.StatusConflict {
+		t.Errorf("switching a pinned thread = %d", rec.Code)
+	}
+}
+
+// Compaction stops at the first fork, and the branch hanging from it follows
+// the summary, so neither branch ends up with context it never had.
+func TestCompactionStopsAtFork(t *testing.T) {
+	h := newTestHandler(t)
+	threadID := createTestThread(t, h)
+	var ids []string
+	for i := 0; i < 10; i++ {
+		ids = append(ids, addMessage(t, h, threadID, "user", fmt.Sprintf("m%d", i), 0, i))
+	}
+	fork := generateID()
+	if _, err := h.db.Exec(
+		"INSERT INTO chat_messages (id, thread_id, parent_id, role, content, created_at) VALUES (?, ?, ?, 'user', 'm2 edited', ?)",
+		fork, threadID, ids[1], "2026-01-01 00:01:00",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	if err := h.compactThreadWith(context.Background(), threadID, stubSummarizer("summary", &seen)); err != nil {
+		t.Fatal(err)
+	}
+	if seen != "[user]: m0\n\n[user]: m1\n\n" {
+		t.Errorf("summarized %q", seen)
+	}
+
+	var history []string
+	for _, m := range h.fetchThreadHistory(threadID, 20) {
+		history = append(history, m.Content)
+	}
+	if got := strings.Join(history, ","); got != "summary,m2,m3,m4,m5,m6,m7,m8,m9" {
+		t.Errorf("active branch = %s", got)
+	}
+	var forkParent, summaryParent string
+	h.db.QueryRow("SELECT parent_id FROM chat_messages WHERE id = ?", fork).Scan(&forkParent)
+	h.db.QueryRow("SELECT id FROM chat_messages WHERE thread_id = ? AND role = 'system'", threadID).Scan(&summaryParent)
+	if forkParent != summaryParent {
+		t.Errorf("fork hangs from %q, want the summary %q", forkParent, summaryParent)
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/chatexport"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+const (
+	maxChatImportBytes   = 100 << 20 // attachments travel inline, base64
+	defaultShareDays     = 7
+	maxShareDays         = 90
+	shareContentSecurity = "default-src 'none'; img-src data: https: 'self'; style-src 'unsafe-inline'"
+)
+
+func (h *ChatHandler) chatExports() *chatexport.Store {
+	return chatexport.New(h.db, filepath.Join(h.dataDir, "chat-attachments"))
+}
+
+// writeThreadExport writes a thread in the requested format: json (the
+// default, re-importable), markdown or html. A download sets the
+// attachment disposition; a share view is shown in the browser.
+func writeThreadExport(w http.ResponseWriter, doc *chatexport.Document, format string, opts chatexport.RenderOptions, download bool) {
+	var body []byte
+	var contentType, ext string
+	switch strings.ToLower(format) {
+	case "", "json":
+		b, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to encode the export")
+			return
+		}
+		body, contentType, ext = b, "application/json", ".json"
+	case "markdown", "md":
+		body, contentType, ext = []byte(chatexport.Markdown(doc, opts)), "text/markdown; charset=utf-8", ".md"
+	case "html":
+		page, err := chatexport.HTML(doc, opts)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to render the export")
+			return
+		}
+		body, contentType, ext = []byte(page), "text/html; charset=utf-8", ".html"
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json, markdown or html")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	if download {
+		filename := strings.NewReplacer("/", "-", "\\", "-", "\r", "", "\n", "").Replace(doc.Thread.Title) + ext
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// ExportThread downloads a thread as ?format=json, markdown or html.
+func (h *ChatHandler) ExportThread(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+	doc, err := h.chatExports().Export(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export thread")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "chat_thread_exported", "chat", "chat_thread", threadID, format)
+	writeThreadExport(w, doc, format, chatexport.RenderOptions{Costs: true}, true)
+}
+
+// ImportThread recreates a thread from a JSON export, here or from another
+// instance, in the active workspace.
+func (h *ChatHandler) ImportThread(w http.ResponseWriter, r *http.Request) {
+	workspaceID := requestWorkspaceID(r, h.db)
+	if !requireWorkspaceRole(w, r, h.db, workspaceID, auth.RoleEditor) {
+		return
+	}
+	var doc chatexport.Document
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChatImportBytes)).Decode(&doc); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid export file")
+		return
+	}
+	threadID, err := h.chatExports().Import(&doc, workspaceID)
+	if errors.Is(err, chatexport.ErrInvalid) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to import thread")
+		return
+	}
+
+	rows, err := h.db.Query(
+		"SELECT DISTINCT agent_role_slug FROM chat_messages WHERE thread_id = ? AND agent_role_slug != ''", threadID,
+	)
+	if err == nil {
+		var slugs []string
+		for rows.Next() {
+			var slug string
+			if rows.Scan(&slug) == nil {
+				slugs = append(slugs, slug)
+			}
+		}
+		rows.Close()
+		for _, slug := range slugs {
+			h.addThreadMember(threadID, slug)
+		}
+	}
+
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "chat_thread_imported", "chat", "chat_thread", threadID, doc.Thread.Title)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": threadID, "title": doc.Thread.Title})
+}
+
+// ListShares returns the thread's share links, expired ones included.
+func (h *ChatHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+	shares, err := h.chatExports().ListShares(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list share links")
+		return
+	}
+	writeJSON(w, http.StatusOK, shares)
+}
+
+// CreateShare makes a read-only link to the thread. The token is in the
+// response once and can't be recovered afterwards.
+func (h *ChatHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
+	var req struct {
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultShareDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxShareDays {
+		writeError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 90")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	token, share, err := h.chatExports().CreateShare(threadID, userID, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create share link")
+		return
+	}
+	h.db.LogAudit(userID, "chat_thread_shared", "chat", "chat_thread", threadID, share.ExpiresAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"share": share,
+		"token": token,
+		"path":  "/api/v1/share/" + token,
+	})
+}
+
+// RevokeShare stops a share link working.
+func (h *ChatHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
+	shareID := chi.URLParam(r, "shareId")
+	if err := h.chatExports().RevokeShare(threadID, shareID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "share link not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to revoke share link")
+		}
+		return
+	}
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "chat_share_revoked", "chat", "chat_thread", threadID, shareID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ViewShare serves a shared thread to whoever holds the link, as an HTML page
+// by default or ?format=markdown / json. Public: the token is the credential.
+// What replies cost is left out, and the page can't load anything from
+// elsewhere or be indexed.
+func (h *ChatHandler) ViewShare(w http.ResponseWriter, r *http.Request) {
+	store := h.chatExports()
+	threadID, err := store.ResolveShare(chi.URLParam(r, "token"))
+	if errors.Is(err, chatexport.ErrShareExpired) {
+		writeError(w, http.StatusGone, "share link has expired")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, "share link not found")
+		return
+	}
+	doc, err := store.Export(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load shared thread")
+		return
+	}
+	// The share shows the conversation, not what it cost to have.
+	zeroCosts(&doc.Thread)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	w.Header().Set("Content-Security-Policy", shareContentSecurity)
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	writeThreadExport(w, doc, format, chatexport.RenderOptions{}, false)
+}
+
+func zeroCosts(t *chatexport.Thread) {
+	t.TotalCostUSD, t.InputTokens, t.OutputTokens = 0, 0, 0
+	for i := range t.Messages {
+		m := &t.Messages[i]
+		m.CostUSD, m.InputTokens, m.OutputTokens = 0, 0, 0
+	}
+	for i := range t.MessageThreads {
+		zeroCosts(&t.MessageThreads[i])
+	}
+}
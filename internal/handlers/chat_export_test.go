@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+)
+
+func TestViewShareServesLockedDownPageWithoutCosts(t *testing.T) {
+	h := newTestHandler(t)
+	h.dataDir = t.TempDir()
+	threadID := createTestThread(t, h)
+	addMessage(t, h, threadID, "user", "what changed?", 0, 1)
+	reply := addMessage(t, h, threadID, "assistant", "the <b>pricing</b> page", 0, 2)
+	h.db.Exec("UPDATE chat_messages SET cost_usd = 1.5 WHERE id = ?", reply)
+
+	token, share, err := h.chatExports().CreateShare(threadID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := chi.NewRouter()
+	router.Get("/share/{token}", h.ViewShare)
+	view := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := view("/share/" + token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("view: %d %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "the &lt;b&gt;pricing&lt;/b&gt; page") {
+		t.Errorf("page does not show the escaped reply:\n%s", body)
+	}
+	if strings.Contains(body, "$1.5") {
+		t.Error("share page shows costs")
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Errorf("CSP = %q", csp)
+	}
+	if rec.Header().Get("Content-Disposition") != "" {
+		t.Error("share page is served as a download")
+	}
+
+	if rec := view("/share/" + token + "?format=json"); strings.Contains(rec.Body.String(), `"cost_usd": 1.5`) {
+		t.Error("shared JSON carries costs")
+	}
+	if rec := view("/share/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token = %d", rec.Code)
+	}
+	h.db.Exec("UPDATE chat_thread_shares SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), share.ID)
+	if rec := view("/share/" + token); rec.Code != http.StatusGone {
+		t.Errorf("expired token = %d", rec.Code)
+	}
+}
+
+// Exports and share links are authorized in the thread's own workspace, not
+// whichever one the caller last switched to.
+func TestExportChecksTheThreadsWorkspace(t *testing.T) {
+	h := newTestHandler(t)
+	h.dataDir = t.TempDir()
+	insertTestUser(t, h.db, "user-editor", auth.RoleEditor)
+	h.db.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('ws-b', 'B', 2), ('ws-c', 'C', 3)")
+	h.db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-editor', 'editor'), ('ws-b', 'user-editor', 'viewer')", DefaultWorkspaceID)
+	h.db.Exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('tb', 'B chat', 'ws-b'), ('tc', 'C chat', 'ws-c')")
+
+	router := chi.NewRouter()
+	router.Use(asUser("user-editor"))
+	router.Get("/chat/threads/{id}/export", h.ExportThread)
+	router.Post("/chat/threads/{id}/shares", h.CreateShare)
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/chat/threads/tb/export", http.StatusOK},
+		{http.MethodPost, "/chat/threads/tb/shares", http.StatusForbidden},
+		{http.MethodGet, "/chat/threads/tc/export", http.StatusForbidden},
+		{http.MethodPost, "/chat/threads/tc/shares", http.StatusForbidden},
+		{http.MethodGet, "/chat/threads/nope/export", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s = %d, want %d", tc.method, tc.path, rec.Code, tc.want)
+		}
+	}
+}
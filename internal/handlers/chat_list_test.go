@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/models"
 )
 
@@ -16,6 +17,7 @@ import (
 // otherwise the frontend interprets the failed request as an empty workspace.
 func TestListThreadsAfterDreamScan(t *testing.T) {
 	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
 	h := &ChatHandler{db: db}
 
 	updatedAt := time.Date(2026, 7, 29, 5, 40, 54, 0, time.UTC)
@@ -39,7 +41,7 @@ func TestListThreadsAfterDreamScan(t *testing.T) {
 	for _, path := range []string{"/chat/threads", "/chat/threads?pinned=1"} {
 		t.Run(path, func(t *testing.T) {
 			rec := httptest.NewRecorder()
-			h.ListThreads(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			h.ListThreads(rec, withUser(httptest.NewRequest(http.MethodGet, path, nil), testAdminID))
 
 			if rec.Code != http.StatusOK {
 				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
@@ -7,21 +7,22 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
 )
 
-// messageThreadAnchor validates that a message belongs to a top-level chat in
-// the active workspace. Focused threads cannot be nested; their context stays
-// understandable and mirrors Slack's one-thread-per-message behavior.
+// messageThreadAnchor validates that a message belongs to a top-level chat.
+// Focused threads cannot be nested; their context stays understandable and
+// mirrors Slack's one-thread-per-message behavior.
 func (h *ChatHandler) messageThreadAnchor(messageID string) (parentThreadID, content string, err error) {
 	var parentID, parentParentID, body string
 	err = h.db.QueryRow(
 		`SELECT parent.id, COALESCE(parent.parent_thread_id, ''), message.content
 		 FROM chat_messages message
 		 JOIN chat_threads parent ON parent.id = message.thread_id
-		 WHERE message.id = ? AND parent.workspace_id = ?`,
-		messageID, activeWorkspaceID(h.db),
+		 WHERE message.id = ?`,
+		messageID,
 	).Scan(&parentID, &parentParentID, &body)
 	if err != nil {
 		return "", "", err
@@ -131,7 +132,8 @@ func (h *ChatHandler) notifyMessageThreadUpdated(threadID string) {
 // started. Merely opening the panel does not create an empty database row.
 func (h *ChatHandler) GetMessageThread(w http.ResponseWriter, r *http.Request) {
 	messageID := chi.URLParam(r, "messageId")
-	if _, _, err := h.messageThreadAnchor(messageID); err != nil {
+	parentThreadID, _, err := h.messageThreadAnchor(messageID)
+	if err != nil {
 		if err == errNestedMessageThread {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
@@ -139,6 +141,9 @@ func (h *ChatHandler) GetMessageThread(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "message not found")
 		return
 	}
+	if !h.requireThreadRole(w, r, parentThreadID, auth.RoleViewer) {
+		return
+	}
 
 	thread, replyCount, err := h.findMessageThread(messageID)
 	if err == sql.ErrNoRows {
@@ -172,9 +177,12 @@ func (h *ChatHandler) CreateMessageThread(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusNotFound, "message not found")
 		return
 	}
+	if !h.requireThreadRole(w, r, parentThreadID, auth.RoleEditor) {
+		return
+	}
 
 	thread, created, err := h.ensureMessageThread(
-		parentThreadID, messageID, content, activeWorkspaceID(h.db),
+		parentThreadID, messageID, content, h.chatThreadWorkspaceID(parentThreadID),
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create message thread")
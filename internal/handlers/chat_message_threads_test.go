@@ -8,12 +8,14 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/models"
 )
 
 func messageThreadTestRouter(h *ChatHandler) http.Handler {
 	r := chi.NewRouter()
+	r.Use(asUser(testAdminID))
 	r.Get("/chat/threads", h.ListThreads)
 	r.Get("/chat/threads/{id}/messages", h.GetMessages)
 	r.Get("/chat/messages/{messageId}/thread", h.GetMessageThread)
@@ -86,10 +88,21 @@ func TestMessageThreadIsWorkspaceScopedAndCannotNest(t *testing.T) {
 	_, otherMessageID := seedMessageThreadAnchor(t, h, "other-workspace")
 	router := messageThreadTestRouter(h)
 
+	// An editor of the Default workspace only can't open a thread on a
+	// message in another one.
+	insertTestUser(t, h.db, "user-editor", auth.RoleEditor)
+	if _, err := h.db.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-editor', 'editor')", database.DefaultWorkspaceID,
+	); err != nil {
+		t.Fatal(err)
+	}
+	editorRouter := chi.NewRouter()
+	editorRouter.Use(asUser("user-editor"))
+	editorRouter.Post("/chat/messages/{messageId}/thread", h.CreateMessageThread)
 	rec := httptest.NewRecorder()
-	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chat/messages/"+otherMessageID+"/thread", strings.NewReader(`{}`)))
-	if rec.Code != http.StatusNotFound {
-		t.Fatalf("cross-workspace anchor status = %d, want 404; body %s", rec.Code, rec.Body.String())
+	editorRouter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chat/messages/"+otherMessageID+"/thread", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cross-workspace anchor status = %d, want 403; body %s", rec.Code, rec.Body.String())
 	}
 
 	_, messageID := seedMessageThreadAnchor(t, h, database.DefaultWorkspaceID)
@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/models"
@@ -1122,10 +1123,12 @@ func (h *ChatHandler) fetchThreadHistory(threadID string, limits ...int) []agent
 	if len(limits) > 0 && limits[0] > 0 {
 		limit = limits[0]
 	}
+	// Only the active branch is the conversation; messages on other branches
+	// are alternatives the user stepped away from.
 	rows, err := h.db.Query(
 		fmt.Sprintf(
-			"SELECT id, role, content, agent_role_slug FROM (SELECT id, role, content, agent_role_slug, created_at FROM chat_messages WHERE thread_id = ? ORDER BY created_at DESC LIMIT %d) sub ORDER BY created_at ASC",
-			limit,
+			"SELECT id, role, content, agent_role_slug FROM (SELECT id, role, content, agent_role_slug, created_at FROM chat_messages WHERE id IN (%s) ORDER BY created_at DESC LIMIT %d) sub ORDER BY created_at ASC",
+			database.ActiveBranch, limit,
 		),
 		threadID,
 	)
@@ -1155,7 +1158,7 @@ func (h *ChatHandler) fetchThreadHistory(threadID string, limits ...int) []agent
 		var id, content string
 		if err := h.db.QueryRow(
 			`SELECT id, content FROM chat_messages
-			 WHERE thread_id = ? AND role = 'system' AND content != ''
+			 WHERE id IN (`+database.ActiveBranch+`) AND role = 'system' AND content != ''
 			 ORDER BY created_at DESC LIMIT 1`, threadID,
 		).Scan(&id, &content); err == nil {
 			msgs = append([]agents.ThreadMessage{{ID: id, Role: "system", Content: content}}, msgs...)
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/chatsearch"
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+// SearchHistory searches message content, thread titles and work orders in the
+// active workspace. ?q is required; ?agent, ?kind (comma-separated: message,
+// thread, work_order), ?from and ?to (inclusive YYYY-MM-DD), ?limit and
+// ?offset narrow it. Snippets mark matches with **bold**.
+func (h *ChatHandler) SearchHistory(w http.ResponseWriter, r *http.Request) {
+	workspaceID := requestWorkspaceID(r, h.db)
+	if !requireWorkspaceRole(w, r, h.db, workspaceID, auth.RoleViewer) {
+		return
+	}
+	query := r.URL.Query()
+	after, before, err := chatsearch.DateRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts := chatsearch.Options{
+		WorkspaceID: workspaceID,
+		Query:       query.Get("q"),
+		Agent:       strings.TrimSpace(query.Get("agent")),
+		After:       after,
+		Before:      before,
+	}
+	if kinds := query.Get("kind"); kinds != "" {
+		opts.Kinds = strings.Split(kinds, ",")
+	}
+	opts.Limit, _ = strconv.Atoi(query.Get("limit"))
+	opts.Offset, _ = strconv.Atoi(query.Get("offset"))
+
+	results, err := chatsearch.Search(h.db, opts)
+	if errors.Is(err, chatsearch.ErrEmptyQuery) {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if err != nil {
+		logger.Error("Chat search failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
@@ -211,7 +212,10 @@ func (h *ChatHandler) generateThreadTitle(threadID, content string) {
 }
 
 func (h *ChatHandler) ListThreadMembers(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
 
 	rows, err := h.db.Query(
 		`SELECT tm.agent_role_slug, ar.name, ar.description, ar.avatar_path, tm.joined_at
@@ -241,7 +245,10 @@ func (h *ChatHandler) ListThreadMembers(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *ChatHandler) RemoveThreadMember(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	result, err := h.db.Exec(
@@ -6,8 +6,8 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
 )
@@ -95,7 +95,10 @@ func (h *ChatHandler) saveConfirmationMessage(threadID, userID string, resp *age
 }
 
 func (h *ChatHandler) ConfirmWork(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 	userID := middleware.GetUserID(r.Context())
 
 	var req struct {
@@ -198,7 +201,10 @@ func (h *ChatHandler) ConfirmWork(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ChatHandler) RejectWork(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 
 	var req struct {
 		WorkOrderID string `json:"work_order_id"`
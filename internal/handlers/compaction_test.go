@@ -7,13 +7,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 )
 
 // newTestHandler builds a ChatHandler backed by a real migrated SQLite database
 // in a temp dir. agentManager is left nil — the compaction path under test takes
-// its summarizer by injection, so no provider is needed.
+// its summarizer by injection, so no provider is needed. Its requests act as
+// the admin the database is seeded with (see asUser).
 func newTestHandler(t *testing.T) *ChatHandler {
 	t.Helper()
 	db, err := database.New(t.TempDir())
@@ -21,6 +23,7 @@ func newTestHandler(t *testing.T) *ChatHandler {
 		t.Fatalf("open test db: %v", err)
 	}
 	t.Cleanup(func() { db.Close() })
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
 	return &ChatHandler{db: db}
 }
 
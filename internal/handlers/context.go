@@ -1,20 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/contexthistory"
+	"github.com/openpaw/openpaw/internal/contextindex"
 	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/docconvert"
+	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
 )
@@ -24,16 +32,100 @@ const maxUploadSize = 10 << 20 // 10MB
 type ContextHandler struct {
 	db      *database.DB
 	dataDir string
+	history *contexthistory.Store
+	// Index re-chunks documents as they are uploaded and edited, so agents'
+	// search_context sees them straight away. Nil skips that; search still
+	// catches up on its own.
+	Index *contextindex.Index
+	// Describe turns uploaded images into text with the vision model. Nil
+	// leaves images to OCR, when tesseract is installed.
+	Describe docconvert.Describer
 }
 
 func NewContextHandler(db *database.DB, dataDir string) *ContextHandler {
-	return &ContextHandler{db: db, dataDir: dataDir}
+	return &ContextHandler{db: db, dataDir: dataDir, history: contexthistory.New(db, dataDir)}
 }
 
 func (h *ContextHandler) contextDir() string {
 	return filepath.Join(h.dataDir, "context")
 }
 
+// VisionDescriber backs image conversion with the vision models, checking on
+// each upload whether OpenRouter is configured yet.
+func VisionDescriber(client *llm.Client) docconvert.Describer {
+	return func(ctx context.Context, imageDataURI string) (string, error) {
+		if client == nil || !client.IsConfigured() {
+			return "", fmt.Errorf("no vision model configured")
+		}
+		return client.DescribeImage(ctx, "", imageDataURI, docconvert.DescribePrompt)
+	}
+}
+
+// textPath returns where a file's readable text lives: the extracted
+// Markdown when there is one, else the file itself if it is text.
+func (h *ContextHandler) textPath(filename, textFilename, mimeType string) (string, bool) {
+	if textFilename != "" {
+		return filepath.Join(h.contextDir(), filepath.Base(textFilename)), true
+	}
+	if isTextMime(mimeType) {
+		return filepath.Join(h.contextDir(), filepath.Base(filename)), true
+	}
+	return "", false
+}
+
+// extractText converts an upload to Markdown and stores it beside the
+// original as <disk name>.md, returning that name, or "" when the format
+// isn't converted or yields no text. A failed conversion only costs the
+// extracted text; the upload itself is kept.
+func (h *ContextHandler) extractText(r *http.Request, diskPath, originalName string) string {
+	if !docconvert.Supported(originalName) {
+		return ""
+	}
+	data, err := os.ReadFile(diskPath)
+	if err != nil {
+		return ""
+	}
+	text, err := docconvert.Convert(r.Context(), originalName, data, docconvert.Options{Describe: h.Describe})
+	if err != nil {
+		if err != docconvert.ErrUnsupported {
+			logger.Warn("Convert context upload %s: %v", originalName, err)
+		}
+		return ""
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	textPath := diskPath + ".md"
+	if err := os.WriteFile(textPath, []byte(text), 0644); err != nil {
+		logger.Warn("Store text of %s: %v", originalName, err)
+		return ""
+	}
+	return filepath.Base(textPath)
+}
+
+// recordRevision adds a save to the document's version history. prev is the
+// document as it was before the save, nil for a new one. A failure is logged:
+// the save itself has already happened.
+func (h *ContextHandler) recordRevision(r *http.Request, fileID string, prev *contexthistory.Snapshot) {
+	wsID := requestWorkspaceID(r, h.db)
+	next, ok, err := h.history.Current(wsID, fileID)
+	if err == nil && ok {
+		_, err = h.history.Record(wsID, fileID, prev, next, contexthistory.Author{Actor: middleware.GetUserID(r.Context())})
+	}
+	if err != nil {
+		logger.Warn("Record revision of context file %s: %v", fileID, err)
+	}
+}
+
+func (h *ContextHandler) reindex(fileID string) {
+	if h.Index == nil {
+		return
+	}
+	if err := h.Index.IndexFile(fileID); err != nil {
+		logger.Warn("Index context file %s: %v", fileID, err)
+	}
+}
+
 func (h *ContextHandler) attachmentsDir() string {
 	return filepath.Join(h.dataDir, "chat-attachments")
 }
@@ -47,7 +139,7 @@ type TreeNode struct {
 }
 
 func (h *ContextHandler) GetTree(w http.ResponseWriter, r *http.Request) {
-	wsID := activeWorkspaceID(h.db)
+	wsID := requestWorkspaceID(r, h.db)
 	folders := []models.ContextFolder{}
 	rows, err := h.db.Query("SELECT id, parent_id, name, sort_order, created_at, updated_at FROM context_folders WHERE workspace_id = ? ORDER BY sort_order, name", wsID)
 	if err != nil {
@@ -131,6 +223,9 @@ func folderFiles(files []models.ContextFile, folderID string) []models.ContextFi
 // --- Folders ---
 
 func (h *ContextHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name     string  `json:"name"`
 		ParentID *string `json:"parent_id,omitempty"`
@@ -152,7 +247,7 @@ func (h *ContextHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 
 	_, err := h.db.Exec(
 		"INSERT INTO context_folders (id, parent_id, name, sort_order, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		id, req.ParentID, req.Name, maxSort+1, activeWorkspaceID(h.db), now, now,
+		id, req.ParentID, req.Name, maxSort+1, requestWorkspaceID(r, h.db), now, now,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create folder")
@@ -173,6 +268,9 @@ func (h *ContextHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContextHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	var req struct {
 		Name     *string `json:"name,omitempty"`
@@ -199,6 +297,9 @@ func (h *ContextHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContextHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	// Get all files in this folder (and subfolders) to delete from disk
@@ -236,15 +337,18 @@ func (h *ContextHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContextHandler) deleteFilesInFolder(folderID string) {
-	rows, err := h.db.Query("SELECT filename FROM context_files WHERE folder_id = ?", folderID)
+	rows, err := h.db.Query("SELECT filename, text_filename FROM context_files WHERE folder_id = ?", folderID)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var filename string
-		rows.Scan(&filename)
+		var filename, textFilename string
+		rows.Scan(&filename, &textFilename)
 		os.Remove(filepath.Join(h.contextDir(), filepath.Base(filename)))
+		if textFilename != "" {
+			os.Remove(filepath.Join(h.contextDir(), filepath.Base(textFilename)))
+		}
 	}
 	h.db.Exec("DELETE FROM context_files WHERE folder_id = ?", folderID)
 }
@@ -252,7 +356,7 @@ func (h *ContextHandler) deleteFilesInFolder(folderID string) {
 // --- Files ---
 
 func (h *ContextHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
-	rows, err := h.db.Query("SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, created_at, updated_at FROM context_files WHERE workspace_id = ? ORDER BY name", activeWorkspaceID(h.db))
+	rows, err := h.db.Query("SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, created_at, updated_at, text_filename FROM context_files WHERE workspace_id = ? ORDER BY name", requestWorkspaceID(r, h.db))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list files")
 		return
@@ -262,7 +366,7 @@ func (h *ContextHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	files := []models.ContextFile{}
 	for rows.Next() {
 		var f models.ContextFile
-		if err := rows.Scan(&f.ID, &f.FolderID, &f.Name, &f.Filename, &f.MimeType, &f.SizeBytes, &f.IsAboutYou, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.FolderID, &f.Name, &f.Filename, &f.MimeType, &f.SizeBytes, &f.IsAboutYou, &f.CreatedAt, &f.UpdatedAt, &f.TextFilename); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to scan file")
 			return
 		}
@@ -275,11 +379,11 @@ func (h *ContextHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var f models.ContextFile
-	wsID := activeWorkspaceID(h.db)
+	wsID := requestWorkspaceID(r, h.db)
 	err := h.db.QueryRow(
-		"SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, created_at, updated_at FROM context_files WHERE id = ? AND workspace_id = ?",
+		"SELECT id, folder_id, name, filename, mime_type, size_bytes, is_about_you, created_at, updated_at, text_filename FROM context_files WHERE id = ? AND workspace_id = ?",
 		id, wsID,
-	).Scan(&f.ID, &f.FolderID, &f.Name, &f.Filename, &f.MimeType, &f.SizeBytes, &f.IsAboutYou, &f.CreatedAt, &f.UpdatedAt)
+	).Scan(&f.ID, &f.FolderID, &f.Name, &f.Filename, &f.MimeType, &f.SizeBytes, &f.IsAboutYou, &f.CreatedAt, &f.UpdatedAt, &f.TextFilename)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "file not found")
 		return
@@ -289,9 +393,8 @@ func (h *ContextHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 		"file": f,
 	}
 
-	// For text files, include content
-	if isTextMime(f.MimeType) {
-		diskPath := filepath.Join(h.contextDir(), filepath.Base(f.Filename))
+	// For text files, and files with extracted text, include content
+	if diskPath, ok := h.textPath(f.Filename, f.TextFilename, f.MimeType); ok {
 		data, err := os.ReadFile(diskPath)
 		if err == nil {
 			result["content"] = string(data)
@@ -305,7 +408,7 @@ func (h *ContextHandler) ServeRaw(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var filename, mimeType string
-	err := h.db.QueryRow("SELECT filename, mime_type FROM context_files WHERE id = ? AND workspace_id = ?", id, activeWorkspaceID(h.db)).Scan(&filename, &mimeType)
+	err := h.db.QueryRow("SELECT filename, mime_type FROM context_files WHERE id = ? AND workspace_id = ?", id, requestWorkspaceID(r, h.db)).Scan(&filename, &mimeType)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "file not found")
 		return
@@ -317,6 +420,9 @@ func (h *ContextHandler) ServeRaw(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContextHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
 		writeError(w, http.StatusBadRequest, "file too large (max 10MB)")
 		return
@@ -366,70 +472,49 @@ func (h *ContextHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		displayName = header.Filename
 	}
 
-	// PDF conversion
-	if mimeType == "application/pdf" {
-		converted, convName, convErr := h.convertPDF(diskPath, displayName)
-		if convErr == nil {
-			// Replace the file with the converted text
-			os.Remove(diskPath)
-			diskFilename = convName
-			diskPath = filepath.Join(h.contextDir(), diskFilename)
-			mimeType = "text/markdown"
-			ext = ".md"
-			displayName = displayName + " (converted)"
-			info, _ := os.Stat(diskPath)
-			if info != nil {
-				written = info.Size()
-			}
-			_ = converted
-		}
-	}
+	// Documents, spreadsheets, web pages and images get their text
+	// extracted; the original is kept for download.
+	textFilename := h.extractText(r, diskPath, header.Filename)
 
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
 	_, err = h.db.Exec(
-		"INSERT INTO context_files (id, folder_id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?)",
-		id, folderPtr, displayName, diskFilename, mimeType, written, activeWorkspaceID(h.db), now, now,
+		"INSERT INTO context_files (id, folder_id, name, filename, mime_type, size_bytes, is_about_you, workspace_id, created_at, updated_at, text_filename) VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?)",
+		id, folderPtr, displayName, diskFilename, mimeType, written, requestWorkspaceID(r, h.db), now, now, textFilename,
 	)
 	if err != nil {
 		os.Remove(diskPath)
+		if textFilename != "" {
+			os.Remove(filepath.Join(h.contextDir(), textFilename))
+		}
 		writeError(w, http.StatusInternalServerError, "failed to save file record")
 		return
 	}
 
+	h.recordRevision(r, id, nil)
+	h.reindex(id)
+
 	userID := middleware.GetUserID(r.Context())
 	h.db.LogAudit(userID, "context_file_uploaded", "context", "context_file", id, displayName)
 
 	writeJSON(w, http.StatusCreated, models.ContextFile{
-		ID:        id,
-		FolderID:  folderPtr,
-		Name:      displayName,
-		Filename:  diskFilename,
-		MimeType:  mimeType,
-		SizeBytes: written,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:           id,
+		FolderID:     folderPtr,
+		Name:         displayName,
+		Filename:     diskFilename,
+		MimeType:     mimeType,
+		SizeBytes:    written,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		TextFilename: textFilename,
 	})
 }
 
-func (h *ContextHandler) convertPDF(pdfPath, baseName string) (string, string, error) {
-	if _, err := exec.LookPath("pdftotext"); err != nil {
-		return "", "", fmt.Errorf("pdftotext not available")
-	}
-
-	mdFilename := uuid.New().String() + ".md"
-	txtPath := filepath.Join(h.contextDir(), mdFilename)
-
-	cmd := exec.Command("pdftotext", "-layout", pdfPath, txtPath)
-	if err := cmd.Run(); err != nil {
-		return "", "", fmt.Errorf("pdftotext failed: %w", err)
-	}
-
-	return txtPath, mdFilename, nil
-}
-
 func (h *ContextHandler) UpdateFile(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	var req struct {
@@ -441,41 +526,54 @@ func (h *ContextHandler) UpdateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var filename, mimeType string
-	wsID := activeWorkspaceID(h.db)
-	err := h.db.QueryRow("SELECT filename, mime_type FROM context_files WHERE id = ? AND workspace_id = ?", id, wsID).Scan(&filename, &mimeType)
+	var filename, mimeType, textFilename string
+	wsID := requestWorkspaceID(r, h.db)
+	err := h.db.QueryRow("SELECT filename, mime_type, text_filename FROM context_files WHERE id = ? AND workspace_id = ?", id, wsID).Scan(&filename, &mimeType, &textFilename)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "file not found")
 		return
 	}
 
+	prev, hasText, _ := h.history.Current(wsID, id)
 	now := time.Now().UTC()
 
 	if req.Name != nil {
 		h.db.Exec("UPDATE context_files SET name = ?, updated_at = ? WHERE id = ? AND workspace_id = ?", *req.Name, now, id, wsID)
 	}
 
-	if req.Content != nil && isTextMime(mimeType) {
-		diskPath := filepath.Join(h.contextDir(), filepath.Base(filename))
+	// Editing a converted file edits its extracted text; the original stays
+	// as uploaded.
+	diskPath, isText := h.textPath(filename, textFilename, mimeType)
+	if req.Content != nil && isText {
 		if err := os.WriteFile(diskPath, []byte(*req.Content), 0644); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to update file content")
 			return
 		}
-		info, _ := os.Stat(diskPath)
-		if info != nil {
+		if textFilename != "" {
+			h.db.Exec("UPDATE context_files SET updated_at = ? WHERE id = ? AND workspace_id = ?", now, id, wsID)
+		} else if info, _ := os.Stat(diskPath); info != nil {
 			h.db.Exec("UPDATE context_files SET size_bytes = ?, updated_at = ? WHERE id = ? AND workspace_id = ?", info.Size(), now, id, wsID)
 		}
 	}
+	if req.Name != nil || req.Content != nil {
+		if hasText {
+			h.recordRevision(r, id, &prev)
+		}
+		h.reindex(id)
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
 func (h *ContextHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
-	var filename string
-	wsID := activeWorkspaceID(h.db)
-	err := h.db.QueryRow("SELECT filename FROM context_files WHERE id = ? AND workspace_id = ?", id, wsID).Scan(&filename)
+	var filename, textFilename string
+	wsID := requestWorkspaceID(r, h.db)
+	err := h.db.QueryRow("SELECT filename, text_filename FROM context_files WHERE id = ? AND workspace_id = ?", id, wsID).Scan(&filename, &textFilename)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "file not found")
 		return
@@ -483,6 +581,9 @@ func (h *ContextHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 	// Delete from disk
 	os.Remove(filepath.Join(h.contextDir(), filepath.Base(filename)))
+	if textFilename != "" {
+		os.Remove(filepath.Join(h.contextDir(), filepath.Base(textFilename)))
+	}
 
 	h.db.Exec("DELETE FROM context_files WHERE id = ? AND workspace_id = ?", id, wsID)
 
@@ -492,7 +593,74 @@ func (h *ContextHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ListRevisions lists a document's saved versions, newest first.
+func (h *ContextHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	revisions, err := h.history.List(requestWorkspaceID(r, h.db), chi.URLParam(r, "id"), limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list revisions")
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// GetRevision returns one saved version with its text.
+func (h *ContextHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	revision, err := h.history.Get(requestWorkspaceID(r, h.db), chi.URLParam(r, "revisionId"))
+	if err != nil || revision.FileID != chi.URLParam(r, "id") {
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, revision)
+}
+
+// RevisionDiff shows a unified diff from the version before a revision to
+// that revision, or with ?against=<revision id> from that earlier version.
+func (h *ContextHandler) RevisionDiff(w http.ResponseWriter, r *http.Request) {
+	diff, err := h.history.Diff(requestWorkspaceID(r, h.db), chi.URLParam(r, "revisionId"), r.URL.Query().Get("against"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows), err == nil && diff.To.FileID != chi.URLParam(r, "id"):
+		writeError(w, http.StatusNotFound, "revision not found")
+	case err != nil && strings.Contains(err.Error(), "invalid revision"):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to diff revisions")
+	default:
+		writeJSON(w, http.StatusOK, diff)
+	}
+}
+
+// RestoreRevision puts a document back the way one of its revisions left
+// it. The restore is itself recorded, so it can be undone the same way.
+func (h *ContextHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	revisionID := chi.URLParam(r, "revisionId")
+	userID := middleware.GetUserID(r.Context())
+	revision, err := h.history.Restore(requestWorkspaceID(r, h.db), id, revisionID, contexthistory.Author{Actor: userID})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "revision not found")
+		return
+	case errors.Is(err, contexthistory.ErrNotText):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to restore revision")
+		return
+	}
+	h.reindex(id)
+	h.db.LogAudit(userID, "context_file_restored", "context", "context_file", id, revisionID)
+	writeJSON(w, http.StatusOK, revision)
+}
+
 func (h *ContextHandler) MoveFile(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	var req struct {
@@ -504,7 +672,7 @@ func (h *ContextHandler) MoveFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	now := time.Now().UTC()
-	wsID := activeWorkspaceID(h.db)
+	wsID := requestWorkspaceID(r, h.db)
 	if req.FolderID != nil {
 		var exists int
 		if err := h.db.QueryRow("SELECT COUNT(*) FROM context_folders WHERE id = ? AND workspace_id = ?", *req.FolderID, wsID).Scan(&exists); err != nil || exists == 0 {
@@ -538,6 +706,9 @@ func (h *ContextHandler) GetAboutYou(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ContextHandler) UpdateAboutYou(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Content string `json:"content"`
 	}
@@ -708,6 +879,14 @@ func detectMimeType(filename, contentType string) string {
 		return "image/svg+xml"
 	case ".pdf":
 		return "application/pdf"
+	case ".docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ".xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ".odt":
+		return "application/vnd.oasis.opendocument.text"
+	case ".epub":
+		return "application/epub+zip"
 	}
 	if contentType != "" && contentType != "application/octet-stream" {
 		return contentType
@@ -82,7 +82,7 @@ const dashboardCols = "id, name, description, layout, widgets, dashboard_type, o
 func (h *DashboardsHandler) List(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(
 		"SELECT "+dashboardCols+" FROM dashboards WHERE workspace_id = ? ORDER BY created_at DESC",
-		activeWorkspaceID(h.db),
+		requestWorkspaceID(r, h.db),
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list dashboards")
@@ -130,7 +130,7 @@ func (h *DashboardsHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	_, err := h.db.Exec(
 		"INSERT INTO dashboards (id, name, description, layout, widgets, owner_agent_slug, bg_image, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		id, req.Name, req.Description, req.Layout, req.Widgets, "", req.BgImage, activeWorkspaceID(h.db), now, now,
+		id, req.Name, req.Description, req.Layout, req.Widgets, "", req.BgImage, requestWorkspaceID(r, h.db), now, now,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create dashboard")
@@ -155,7 +155,7 @@ func (h *DashboardsHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *DashboardsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	d, err := h.scanDashboard(h.db.QueryRow(
-		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, activeWorkspaceID(h.db),
+		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, requestWorkspaceID(r, h.db),
 	))
 	if err != nil {
 		writeError(w, http.StatusNotFound, "dashboard not found")
@@ -168,7 +168,7 @@ func (h *DashboardsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var exists string
-	workspaceID := activeWorkspaceID(h.db)
+	workspaceID := requestWorkspaceID(r, h.db)
 	err := h.db.QueryRow("SELECT id FROM dashboards WHERE id = ? AND workspace_id = ?", id, workspaceID).Scan(&exists)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "dashboard not found")
@@ -232,7 +232,7 @@ func (h *DashboardsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Check dashboard type before deletion for cleanup
 	var dashType string
-	workspaceID := activeWorkspaceID(h.db)
+	workspaceID := requestWorkspaceID(r, h.db)
 	h.db.QueryRow("SELECT dashboard_type FROM dashboards WHERE id = ? AND workspace_id = ?", id, workspaceID).Scan(&dashType)
 
 	result, err := h.db.Exec("DELETE FROM dashboards WHERE id = ? AND workspace_id = ?", id, workspaceID)
@@ -389,7 +389,7 @@ func (h *DashboardsHandler) RefreshData(w http.ResponseWriter, r *http.Request)
 	id := chi.URLParam(r, "id")
 
 	d, err := h.scanDashboard(h.db.QueryRow(
-		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, activeWorkspaceID(h.db),
+		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, requestWorkspaceID(r, h.db),
 	))
 	if err != nil {
 		writeError(w, http.StatusNotFound, "dashboard not found")
@@ -417,7 +417,7 @@ func (h *DashboardsHandler) RefreshData(w http.ResponseWriter, r *http.Request)
 
 	results := make(map[string]interface{})
 	databaseStore := userdb.NewStore(h.db)
-	workspaceID := activeWorkspaceID(h.db)
+	workspaceID := requestWorkspaceID(r, h.db)
 	for _, widget := range widgets {
 		if widget.DataSource == nil {
 			continue
@@ -473,7 +473,7 @@ func (h *DashboardsHandler) GetWidgetData(w http.ResponseWriter, r *http.Request
 	var exists string
 	if err := h.db.QueryRow(
 		"SELECT id FROM dashboards WHERE id = ? AND workspace_id = ?",
-		dashboardID, activeWorkspaceID(h.db),
+		dashboardID, requestWorkspaceID(r, h.db),
 	).Scan(&exists); err != nil {
 		writeError(w, http.StatusNotFound, "dashboard not found")
 		return
@@ -539,7 +539,7 @@ func (h *DashboardsHandler) CollectData(w http.ResponseWriter, r *http.Request)
 	id := chi.URLParam(r, "id")
 
 	d, err := h.scanDashboard(h.db.QueryRow(
-		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, activeWorkspaceID(h.db),
+		"SELECT "+dashboardCols+" FROM dashboards WHERE id = ? AND workspace_id = ?", id, requestWorkspaceID(r, h.db),
 	))
 	if err != nil {
 		writeError(w, http.StatusNotFound, "dashboard not found")
@@ -3,14 +3,17 @@ package handlers
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/userdb"
@@ -37,6 +40,12 @@ func (h *DatabasesHandler) changed(databaseID, tableID string) {
 	}
 }
 
+// storeAs returns the store attributing its changes to the request's user,
+// for the row history.
+func (h *DatabasesHandler) storeAs(r *http.Request) *userdb.Store {
+	return h.store.As(userdb.Actor{Actor: middleware.GetUserID(r.Context())})
+}
+
 func writeDatabaseError(w http.ResponseWriter, err error) {
 	if errors.Is(err, sql.ErrNoRows) {
 		writeError(w, http.StatusNotFound, "database item not found")
@@ -52,15 +61,23 @@ func writeDatabaseError(w http.ResponseWriter, err error) {
 		strings.Contains(message, "CSV cannot"),
 		strings.Contains(message, "unsupported column"),
 		strings.Contains(message, "unknown column"),
-		strings.Contains(message, "invalid sort"):
+		strings.Contains(message, "invalid sort"),
+		strings.Contains(message, "invalid query"),
+		strings.Contains(message, "invalid column options"),
+		strings.Contains(message, "invalid value"),
+		strings.Contains(message, "is computed"),
+		strings.Contains(message, "invalid revision"):
 		writeError(w, http.StatusBadRequest, message)
 	default:
 		writeError(w, http.StatusInternalServerError, "database operation failed")
 	}
 }
 
-func (h *DatabasesHandler) List(w http.ResponseWriter, _ *http.Request) {
-	items, err := h.store.ListDatabases(activeWorkspaceID(h.db))
+func (h *DatabasesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	items, err := h.store.ListDatabases(requestWorkspaceID(r, h.db))
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -69,6 +86,9 @@ func (h *DatabasesHandler) List(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (h *DatabasesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
@@ -77,7 +97,7 @@ func (h *DatabasesHandler) Create(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	item, err := h.store.CreateDatabase(activeWorkspaceID(h.db), req.Name, req.Description)
+	item, err := h.store.CreateDatabase(requestWorkspaceID(r, h.db), req.Name, req.Description)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -88,7 +108,10 @@ func (h *DatabasesHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) Get(w http.ResponseWriter, r *http.Request) {
-	item, err := h.store.GetDatabase(activeWorkspaceID(h.db), chi.URLParam(r, "id"))
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	item, err := h.store.GetDatabase(requestWorkspaceID(r, h.db), chi.URLParam(r, "id"))
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -97,6 +120,9 @@ func (h *DatabasesHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name        *string `json:"name"`
 		Description *string `json:"description"`
@@ -106,7 +132,7 @@ func (h *DatabasesHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id := chi.URLParam(r, "id")
-	item, err := h.store.UpdateDatabase(activeWorkspaceID(h.db), id, req.Name, req.Description)
+	item, err := h.store.UpdateDatabase(requestWorkspaceID(r, h.db), id, req.Name, req.Description)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -117,8 +143,11 @@ func (h *DatabasesHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
-	if err := h.store.DeleteDatabase(activeWorkspaceID(h.db), id); err != nil {
+	if err := h.store.DeleteDatabase(requestWorkspaceID(r, h.db), id); err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
@@ -128,6 +157,9 @@ func (h *DatabasesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	r.Body = http.MaxBytesReader(w, r.Body, maxDatabaseCSVUpload+(1<<20))
 	if err := r.ParseMultipartForm(maxDatabaseCSVUpload); err != nil {
 		writeError(w, http.StatusBadRequest, "CSV file is required and must be 25 MB or smaller")
@@ -148,7 +180,7 @@ func (h *DatabasesHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.store.ImportCSV(activeWorkspaceID(h.db), header.Filename, file)
+	result, err := h.storeAs(r).ImportCSV(requestWorkspaceID(r, h.db), header.Filename, file)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -170,7 +202,10 @@ func (h *DatabasesHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) ExportTableCSV(w http.ResponseWriter, r *http.Request) {
-	workspaceID := activeWorkspaceID(h.db)
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	workspaceID := requestWorkspaceID(r, h.db)
 	tableID := chi.URLParam(r, "tableId")
 	table, err := h.store.GetTable(workspaceID, tableID)
 	if err != nil {
@@ -197,6 +232,9 @@ func (h *DatabasesHandler) ExportTableCSV(w http.ResponseWriter, r *http.Request
 }
 
 func (h *DatabasesHandler) CreateTable(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name string `json:"name"`
 	}
@@ -205,7 +243,7 @@ func (h *DatabasesHandler) CreateTable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	databaseID := chi.URLParam(r, "id")
-	table, err := h.store.CreateTable(activeWorkspaceID(h.db), databaseID, req.Name)
+	table, err := h.store.CreateTable(requestWorkspaceID(r, h.db), databaseID, req.Name)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -216,6 +254,9 @@ func (h *DatabasesHandler) CreateTable(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) UpdateTable(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name *string `json:"name"`
 	}
@@ -223,7 +264,7 @@ func (h *DatabasesHandler) UpdateTable(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	table, err := h.store.UpdateTable(activeWorkspaceID(h.db), chi.URLParam(r, "tableId"), req.Name)
+	table, err := h.storeAs(r).UpdateTable(requestWorkspaceID(r, h.db), chi.URLParam(r, "tableId"), req.Name)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -234,13 +275,16 @@ func (h *DatabasesHandler) UpdateTable(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) DeleteTable(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	tableID := chi.URLParam(r, "tableId")
-	table, err := h.store.GetTable(activeWorkspaceID(h.db), tableID)
+	table, err := h.store.GetTable(requestWorkspaceID(r, h.db), tableID)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
-	if err := h.store.DeleteTable(activeWorkspaceID(h.db), tableID); err != nil {
+	if err := h.store.DeleteTable(requestWorkspaceID(r, h.db), tableID); err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
@@ -250,6 +294,9 @@ func (h *DatabasesHandler) DeleteTable(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) CreateColumn(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name    string                 `json:"name"`
 		Type    string                 `json:"type"`
@@ -263,18 +310,21 @@ func (h *DatabasesHandler) CreateColumn(w http.ResponseWriter, r *http.Request)
 		req.Type = "text"
 	}
 	tableID := chi.URLParam(r, "tableId")
-	column, err := h.store.CreateColumn(activeWorkspaceID(h.db), tableID, req.Name, req.Type, req.Options)
+	column, err := h.storeAs(r).CreateColumn(requestWorkspaceID(r, h.db), tableID, req.Name, req.Type, req.Options)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
-	table, _ := h.store.GetTable(activeWorkspaceID(h.db), tableID)
+	table, _ := h.store.GetTable(requestWorkspaceID(r, h.db), tableID)
 	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_column_created", "database", "database_column", column.ID, column.Name)
 	h.changed(table.DatabaseID, tableID)
 	writeJSON(w, http.StatusCreated, column)
 }
 
 func (h *DatabasesHandler) UpdateColumn(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name    *string                `json:"name"`
 		Type    *string                `json:"type"`
@@ -284,20 +334,23 @@ func (h *DatabasesHandler) UpdateColumn(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	column, err := h.store.UpdateColumn(activeWorkspaceID(h.db), chi.URLParam(r, "columnId"), req.Name, req.Type, req.Options)
+	column, err := h.storeAs(r).UpdateColumn(requestWorkspaceID(r, h.db), chi.URLParam(r, "columnId"), req.Name, req.Type, req.Options)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
-	table, _ := h.store.GetTable(activeWorkspaceID(h.db), column.TableID)
+	table, _ := h.store.GetTable(requestWorkspaceID(r, h.db), column.TableID)
 	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_column_updated", "database", "database_column", column.ID, column.Name)
 	h.changed(table.DatabaseID, table.ID)
 	writeJSON(w, http.StatusOK, column)
 }
 
 func (h *DatabasesHandler) DeleteColumn(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	columnID := chi.URLParam(r, "columnId")
-	if err := h.store.DeleteColumn(activeWorkspaceID(h.db), columnID); err != nil {
+	if err := h.storeAs(r).DeleteColumn(requestWorkspaceID(r, h.db), columnID); err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
@@ -307,10 +360,13 @@ func (h *DatabasesHandler) DeleteColumn(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *DatabasesHandler) ListRows(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 	page, err := h.store.ListRowsSorted(
-		activeWorkspaceID(h.db),
+		requestWorkspaceID(r, h.db),
 		chi.URLParam(r, "tableId"),
 		r.URL.Query().Get("search"),
 		limit,
@@ -325,16 +381,38 @@ func (h *DatabasesHandler) ListRows(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, page)
 }
 
+// QueryRows reads a table projected onto column names. Besides search, limit
+// and offset it takes a structured userdb.Query — filters, multi-column sort,
+// group_by and aggregates — as a POST body or, so read-only tokens can use it,
+// as JSON in the query parameter of a GET.
 func (h *DatabasesHandler) QueryRows(w http.ResponseWriter, r *http.Request) {
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	result, err := h.store.NamedRows(
-		activeWorkspaceID(h.db),
-		chi.URLParam(r, "tableId"),
-		r.URL.Query().Get("search"),
-		limit,
-		offset,
-	)
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	var q userdb.Query
+	if r.Method == http.MethodPost {
+		if err := decodeJSON(r, &q); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	} else {
+		if raw := r.URL.Query().Get("query"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &q); err != nil {
+				writeError(w, http.StatusBadRequest, "query must be a JSON object")
+				return
+			}
+		}
+		if search := r.URL.Query().Get("search"); search != "" {
+			q.Search = search
+		}
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+			q.Limit = limit
+		}
+		if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil {
+			q.Offset = offset
+		}
+	}
+	result, err := h.store.Query(requestWorkspaceID(r, h.db), chi.URLParam(r, "tableId"), q)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
@@ -343,6 +421,9 @@ func (h *DatabasesHandler) QueryRows(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DatabasesHandler) CreateRow(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Values map[string]interface{} `json:"values"`
 	}
@@ -351,18 +432,21 @@ func (h *DatabasesHandler) CreateRow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	tableID := chi.URLParam(r, "tableId")
-	row, err := h.store.CreateRow(activeWorkspaceID(h.db), tableID, req.Values)
+	row, err := h.storeAs(r).CreateRow(requestWorkspaceID(r, h.db), tableID, req.Values)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
-	table, _ := h.store.GetTable(activeWorkspaceID(h.db), tableID)
+	table, _ := h.store.GetTable(requestWorkspaceID(r, h.db), tableID)
 	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_row_created", "database", "database_row", row.ID, table.Name)
 	h.changed(table.DatabaseID, tableID)
 	writeJSON(w, http.StatusCreated, row)
 }
 
 func (h *DatabasesHandler) UpdateRow(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Values map[string]interface{} `json:"values"`
 	}
@@ -370,20 +454,23 @@ func (h *DatabasesHandler) UpdateRow(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	row, err := h.store.UpdateRow(activeWorkspaceID(h.db), chi.URLParam(r, "rowId"), req.Values)
+	row, err := h.storeAs(r).UpdateRow(requestWorkspaceID(r, h.db), chi.URLParam(r, "rowId"), req.Values)
 	if err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
-	table, _ := h.store.GetTable(activeWorkspaceID(h.db), row.TableID)
+	table, _ := h.store.GetTable(requestWorkspaceID(r, h.db), row.TableID)
 	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_row_updated", "database", "database_row", row.ID, table.Name)
 	h.changed(table.DatabaseID, row.TableID)
 	writeJSON(w, http.StatusOK, row)
 }
 
 func (h *DatabasesHandler) DeleteRow(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
 	rowID := chi.URLParam(r, "rowId")
-	if err := h.store.DeleteRow(activeWorkspaceID(h.db), rowID); err != nil {
+	if err := h.storeAs(r).DeleteRow(requestWorkspaceID(r, h.db), rowID); err != nil {
 		writeDatabaseError(w, err)
 		return
 	}
@@ -391,3 +478,105 @@ func (h *DatabasesHandler) DeleteRow(w http.ResponseWriter, r *http.Request) {
 	h.changed("", "")
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RowHistory lists a row's revisions, newest first — including a deleted
+// row's, so it can be found and restored.
+func (h *DatabasesHandler) RowHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	revisions, err := h.store.RowHistory(requestWorkspaceID(r, h.db), chi.URLParam(r, "rowId"), limit, offset)
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// TableHistory lists a table's row and schema revisions, newest first.
+func (h *DatabasesHandler) TableHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	revisions, err := h.store.TableHistory(requestWorkspaceID(r, h.db), chi.URLParam(r, "tableId"), limit, offset)
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// RevisionDiff shows what a revision changed, or with ?against=<revision id>
+// what changed between that earlier revision and this one.
+func (h *DatabasesHandler) RevisionDiff(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleViewer) {
+		return
+	}
+	diff, err := h.store.DiffRevisions(requestWorkspaceID(r, h.db), chi.URLParam(r, "revisionId"), r.URL.Query().Get("against"))
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// RestoreRow puts a row back the way one of its revisions left it.
+func (h *DatabasesHandler) RestoreRow(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
+	var req struct {
+		RevisionID string `json:"revision_id"`
+	}
+	if decodeJSON(r, &req) != nil || req.RevisionID == "" {
+		writeError(w, http.StatusBadRequest, "revision_id is required")
+		return
+	}
+	workspaceID := requestWorkspaceID(r, h.db)
+	revision, err := h.store.GetRevision(workspaceID, req.RevisionID)
+	if err == nil && revision.RowID != chi.URLParam(r, "rowId") {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	row, err := h.storeAs(r).RestoreRow(workspaceID, req.RevisionID)
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	table, _ := h.store.GetTable(workspaceID, revision.TableID)
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_row_restored", "database", "database_row", revision.RowID, req.RevisionID)
+	h.changed(table.DatabaseID, revision.TableID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"row": row, "deleted": row == nil})
+}
+
+// RestoreTable rolls a table's rows back to a point in time.
+func (h *DatabasesHandler) RestoreTable(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, requestWorkspaceID(r, h.db), auth.RoleEditor) {
+		return
+	}
+	var req struct {
+		At time.Time `json:"at"`
+	}
+	if decodeJSON(r, &req) != nil || req.At.IsZero() {
+		writeError(w, http.StatusBadRequest, "at is required (RFC 3339 timestamp)")
+		return
+	}
+	workspaceID := requestWorkspaceID(r, h.db)
+	tableID := chi.URLParam(r, "tableId")
+	result, err := h.storeAs(r).RestoreTable(workspaceID, tableID, req.At.UTC())
+	if err != nil {
+		writeDatabaseError(w, err)
+		return
+	}
+	table, _ := h.store.GetTable(workspaceID, tableID)
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "database_table_restored", "database", "database_table", tableID, req.At.UTC().Format(time.RFC3339))
+	h.changed(table.DatabaseID, tableID)
+	writeJSON(w, http.StatusOK, result)
+}
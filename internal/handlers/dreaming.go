@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/dreaming"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
 )
@@ -17,6 +21,12 @@ type DreamingManager interface {
 	UpdateConfig(cfg map[string]string) error
 	RunNow()
 	IsDreaming() bool
+	Journal(runIDs ...string) (map[string][]models.DreamOp, error)
+	ReviewQueue(agentSlug string) ([]models.DreamOp, error)
+	ApproveOp(id, userID string) (*models.DreamOp, error)
+	RejectOp(id, userID string) (*models.DreamOp, error)
+	DecideRun(runID string, approve bool, userID string) (dreaming.ReviewResult, error)
+	RevertRun(runID, userID string) (dreaming.RevertResult, error)
 }
 
 type DreamingHandler struct {
@@ -47,7 +57,7 @@ func (h *DreamingHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DreamingHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.available(w) {
+	if !h.available(w) || !requireRole(w, r, h.db, auth.RoleEditor) {
 		return
 	}
 	var req map[string]string
@@ -70,7 +80,7 @@ func (h *DreamingHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DreamingHandler) RunNow(w http.ResponseWriter, r *http.Request) {
-	if !h.available(w) {
+	if !h.available(w) || !requireRole(w, r, h.db, auth.RoleEditor) {
 		return
 	}
 	if h.mgr.IsDreaming() {
@@ -86,7 +96,8 @@ func (h *DreamingHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
 }
 
-// ListRuns returns the dream history, newest first.
+// ListRuns returns the dream history, newest first, each run with the changes
+// it made or proposes and a diff of every one.
 func (h *DreamingHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 	limit := 20
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -105,7 +116,9 @@ func (h *DreamingHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(
 		`SELECT r.id, r.agent_slug, COALESCE(a.name, r.agent_slug), r.status,
 		        r.threads_scanned, r.facts_found, r.memories_added, r.memories_updated,
-		        r.memories_pruned, r.summary, r.error, r.started_at, r.finished_at
+		        r.memories_pruned, r.summary, r.error, r.mode,
+		        (SELECT COUNT(*) FROM dream_ops o WHERE o.run_id = r.id AND o.status = 'proposed'),
+		        r.started_at, r.finished_at, r.reverted_at
 		 FROM dream_runs r
 		 LEFT JOIN agent_roles a ON a.slug = r.agent_slug
 		 WHERE `+where+`
@@ -121,10 +134,11 @@ func (h *DreamingHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 	runs := []models.DreamRun{}
 	for rows.Next() {
 		var run models.DreamRun
-		var finishedAt sql.NullTime
+		var finishedAt, revertedAt sql.NullTime
 		if err := rows.Scan(&run.ID, &run.AgentRoleSlug, &run.AgentName, &run.Status,
 			&run.ThreadsScanned, &run.FactsFound, &run.MemoriesAdded, &run.MemoriesUpdated,
-			&run.MemoriesPruned, &run.Summary, &run.Error, &run.StartedAt, &finishedAt); err != nil {
+			&run.MemoriesPruned, &run.Summary, &run.Error, &run.Mode, &run.Pending,
+			&run.StartedAt, &finishedAt, &revertedAt); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to scan dream run")
 			return
 		}
@@ -132,8 +146,134 @@ func (h *DreamingHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
 			t := finishedAt.Time
 			run.FinishedAt = &t
 		}
+		if revertedAt.Valid {
+			t := revertedAt.Time
+			run.RevertedAt = &t
+		}
+		run.Ops = []models.DreamOp{}
 		runs = append(runs, run)
 	}
+	rows.Close()
+
+	if h.mgr != nil && len(runs) > 0 {
+		ids := make([]string, len(runs))
+		for i, run := range runs {
+			ids[i] = run.ID
+		}
+		journal, err := h.mgr.Journal(ids...)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load dream changes")
+			return
+		}
+		for i := range runs {
+			if ops := journal[runs[i].ID]; ops != nil {
+				runs[i].Ops = ops
+			}
+		}
+	}
 
 	writeJSON(w, http.StatusOK, runs)
 }
+
+// ReviewQueue lists the changes propose-only dreams are waiting on, oldest
+// first. ?agent= narrows it to one agent.
+func (h *DreamingHandler) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	ops, err := h.mgr.ReviewQueue(r.URL.Query().Get("agent"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load the review queue")
+		return
+	}
+	writeJSON(w, http.StatusOK, ops)
+}
+
+func (h *DreamingHandler) ApproveOp(w http.ResponseWriter, r *http.Request) {
+	h.decideOp(w, r, true)
+}
+
+func (h *DreamingHandler) RejectOp(w http.ResponseWriter, r *http.Request) {
+	h.decideOp(w, r, false)
+}
+
+func (h *DreamingHandler) decideOp(w http.ResponseWriter, r *http.Request, approve bool) {
+	if !h.available(w) || !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	userID := middleware.GetUserID(r.Context())
+	decide, action := h.mgr.RejectOp, "dream_change_rejected"
+	if approve {
+		decide, action = h.mgr.ApproveOp, "dream_change_approved"
+	}
+	op, err := decide(id, userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "change not found")
+		return
+	case errors.Is(err, dreaming.ErrDecided):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		// The memory an update or forget targets has gone since it was proposed.
+		writeError(w, http.StatusConflict, "could not apply this change: "+err.Error())
+		return
+	}
+
+	h.db.LogAudit(userID, action, "dreaming", "dream_op", id, op.AgentRoleSlug+" "+op.Op)
+
+	writeJSON(w, http.StatusOK, op)
+}
+
+func (h *DreamingHandler) ApproveRun(w http.ResponseWriter, r *http.Request) {
+	h.decideRun(w, r, true)
+}
+
+func (h *DreamingHandler) RejectRun(w http.ResponseWriter, r *http.Request) {
+	h.decideRun(w, r, false)
+}
+
+// decideRun approves or rejects everything a run still has in the queue.
+func (h *DreamingHandler) decideRun(w http.ResponseWriter, r *http.Request, approve bool) {
+	if !h.available(w) || !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	userID := middleware.GetUserID(r.Context())
+	res, err := h.mgr.DecideRun(id, approve, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to review the dream's changes")
+		return
+	}
+
+	action := "dream_run_rejected"
+	if approve {
+		action = "dream_run_approved"
+	}
+	h.db.LogAudit(userID, action, "dreaming", "dream_run", id, "")
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// RevertRun puts back everything a run changed. Memories edited since the
+// run are left alone and reported as conflicts.
+func (h *DreamingHandler) RevertRun(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) || !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	userID := middleware.GetUserID(r.Context())
+	res, err := h.mgr.RevertRun(id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "dream run not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revert the dream")
+		return
+	}
+
+	h.db.LogAudit(userID, "dream_run_reverted", "dreaming", "dream_run", id, "")
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -33,6 +33,13 @@ func TestDreamingListRuns(t *testing.T) {
 		t.Fatalf("insert dream run: %v", err)
 	}
 
+	if _, err := db.Exec(
+		`INSERT INTO dream_ops (id, run_id, agent_slug, op, after_json, status)
+		 VALUES ('o1', 'r1', 'scout', 'add', '{"content":"Prefers dark mode"}', 'proposed')`,
+	); err != nil {
+		t.Fatalf("insert dream op: %v", err)
+	}
+
 	// A run for an agent that has since been deleted must still list — the whole
 	// point of the history is that it outlives what it describes.
 	if _, err := db.Exec(
@@ -79,6 +86,9 @@ func TestDreamingListRuns(t *testing.T) {
 	if got.FinishedAt == nil {
 		t.Error("finished_at was dropped")
 	}
+	if got.Mode != "apply" || got.Pending != 1 {
+		t.Errorf("mode = %q, pending = %d; want apply, 1", got.Mode, got.Pending)
+	}
 
 	// Falls back to the slug when the agent row is gone.
 	if runs[1].AgentName != "ghost" {
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/mcp"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+// mcpEndpointCapsKey holds the JSON list of capabilities the MCP endpoint
+// exposes. Unset means all of them.
+const mcpEndpointCapsKey = "mcp_endpoint_capabilities"
+
+// contextResourcePrefix is the URI scheme for context documents served as MCP
+// resources.
+const contextResourcePrefix = "openpaw://context/"
+
+// MCPEndpointHandler serves OpenPaw itself as an MCP server at /api/v1/mcp.
+// A client authenticates with a personal access token and acts as the token's
+// owner: it sees one workspace (?workspace=<id>, or the active one), needs at
+// least viewer access there, and only gets write tools as an editor.
+type MCPEndpointHandler struct {
+	db       *database.DB
+	agentMgr *agents.Manager
+	dataDir  string
+	serve    http.HandlerFunc
+}
+
+func NewMCPEndpointHandler(db *database.DB, agentMgr *agents.Manager, dataDir string) *MCPEndpointHandler {
+	h := &MCPEndpointHandler{db: db, agentMgr: agentMgr, dataDir: dataDir}
+	h.serve = mcp.Endpoint(h)
+	return h
+}
+
+type mcpCallerKey struct{}
+
+// mcpCaller is the resolved workspace and access level of one request.
+type mcpCaller struct {
+	workspaceID string
+	canWrite    bool
+}
+
+func callerFrom(r *http.Request) mcpCaller {
+	c, _ := r.Context().Value(mcpCallerKey{}).(mcpCaller)
+	return c
+}
+
+// Serve handles every MCP request after resolving who is calling and where.
+func (h *MCPEndpointHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	wsID := strings.TrimSpace(r.URL.Query().Get("workspace"))
+	if wsID == "" {
+		wsID = requestWorkspaceID(r, h.db)
+	}
+	if !requireWorkspaceRole(w, r, h.db, wsID, auth.RoleViewer) {
+		return
+	}
+	role := h.db.WorkspaceRole(middleware.GetUserID(r.Context()), wsID)
+	caller := mcpCaller{workspaceID: wsID, canWrite: auth.RoleAtLeast(role, auth.RoleEditor)}
+	h.serve(w, r.WithContext(context.WithValue(r.Context(), mcpCallerKey{}, caller)))
+}
+
+// capabilities returns the capabilities an admin has left switched on.
+func (h *MCPEndpointHandler) capabilities() []string {
+	var raw string
+	h.db.QueryRow("SELECT value FROM settings WHERE key = ?", mcpEndpointCapsKey).Scan(&raw)
+	var caps []string
+	if raw == "" || json.Unmarshal([]byte(raw), &caps) != nil {
+		return agents.EndpointCapabilities
+	}
+	return caps
+}
+
+func (h *MCPEndpointHandler) Tools(r *http.Request) ([]llm.ToolDef, map[string]llm.ToolHandler) {
+	c := callerFrom(r)
+	return h.agentMgr.EndpointTools(c.workspaceID, h.capabilities(), c.canWrite)
+}
+
+// Resources lists the workspace's context documents.
+func (h *MCPEndpointHandler) Resources(r *http.Request) ([]mcp.Resource, error) {
+	rows, err := h.db.Query(
+		"SELECT id, name, mime_type FROM context_files WHERE workspace_id = ? ORDER BY name",
+		callerFrom(r).workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []mcp.Resource{}
+	for rows.Next() {
+		var id, name, mime string
+		if err := rows.Scan(&id, &name, &mime); err != nil {
+			continue
+		}
+		resources = append(resources, mcp.Resource{URI: contextResourcePrefix + id, Name: name, MimeType: mime})
+	}
+	return resources, nil
+}
+
+func (h *MCPEndpointHandler) ReadResource(r *http.Request, uri string) (*mcp.ResourceContents, error) {
+	id := strings.TrimPrefix(uri, contextResourcePrefix)
+	if id == uri || id == "" {
+		return nil, mcp.ErrNotFound
+	}
+	var filename, mime, textFilename string
+	err := h.db.QueryRow(
+		"SELECT filename, mime_type, text_filename FROM context_files WHERE id = ? AND workspace_id = ?",
+		id, callerFrom(r).workspaceID,
+	).Scan(&filename, &mime, &textFilename)
+	if err != nil {
+		return nil, mcp.ErrNotFound
+	}
+	if textFilename != "" {
+		// Serve the Markdown extracted from a converted upload.
+		filename, mime = textFilename, "text/markdown"
+	} else if !isTextMime(mime) {
+		return nil, fmt.Errorf("%s is a binary file and can't be read as text", uri)
+	}
+	data, err := os.ReadFile(filepath.Join(h.dataDir, "context", filepath.Base(filename)))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", uri, err)
+	}
+	return &mcp.ResourceContents{URI: uri, MimeType: mime, Text: string(data)}, nil
+}
+
+// Prompts lists one prompt per enabled agent, so a client can borrow an
+// agent's persona for a task.
+func (h *MCPEndpointHandler) Prompts(r *http.Request) ([]mcp.Prompt, error) {
+	rows, err := h.db.Query("SELECT slug, name, description FROM agent_roles WHERE enabled = 1 ORDER BY sort_order, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prompts := []mcp.Prompt{}
+	for rows.Next() {
+		var slug, name, description string
+		if err := rows.Scan(&slug, &name, &description); err != nil {
+			continue
+		}
+		if description == "" {
+			description = "Work as the " + name + " agent"
+		}
+		prompts = append(prompts, mcp.Prompt{
+			Name:        slug,
+			Description: description,
+			Arguments:   []mcp.PromptArgument{{Name: "task", Description: "What the agent should do", Required: true}},
+		})
+	}
+	return prompts, nil
+}
+
+func (h *MCPEndpointHandler) GetPrompt(r *http.Request, name string, args map[string]string) (*mcp.PromptResult, error) {
+	var agentName, systemPrompt string
+	var identityInitialized bool
+	err := h.db.QueryRow(
+		"SELECT name, system_prompt, identity_initialized FROM agent_roles WHERE slug = ? AND enabled = 1", name,
+	).Scan(&agentName, &systemPrompt, &identityInitialized)
+	if err != nil {
+		return nil, mcp.ErrNotFound
+	}
+	if identityInitialized {
+		if assembled, err := agents.AssembleSystemPrompt(h.dataDir, name); err == nil {
+			systemPrompt = assembled
+		}
+	}
+
+	text := systemPrompt
+	if task := strings.TrimSpace(args["task"]); task != "" {
+		text += "\n\n---\n\n## TASK\n\n" + task
+	}
+	return &mcp.PromptResult{
+		Description: agentName,
+		Messages:    []mcp.PromptMessage{mcp.NewPromptMessage("user", text)},
+	}, nil
+}
+
+// GetSettings returns which capabilities the endpoint exposes.
+func (h *MCPEndpointHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"capabilities": h.capabilities(),
+		"available":    agents.EndpointCapabilities,
+		"path":         "/api/v1/mcp",
+	})
+}
+
+// UpdateSettings changes which capabilities the endpoint exposes.
+func (h *MCPEndpointHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	caps := []string{}
+	for _, c := range req.Capabilities {
+		known := false
+		for _, a := range agents.EndpointCapabilities {
+			known = known || a == c
+		}
+		if !known {
+			writeError(w, http.StatusBadRequest, "unknown capability: "+c)
+			return
+		}
+		caps = append(caps, c)
+	}
+
+	data, _ := json.Marshal(caps)
+	if _, err := h.db.Exec(
+		"INSERT INTO settings (id, key, value) VALUES (?, ?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+		generateID(), mcpEndpointCapsKey, string(data),
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save settings")
+		return
+	}
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "mcp_endpoint_updated", "settings", "settings", mcpEndpointCapsKey, string(data))
+
+	h.GetSettings(w, r)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/mcp"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+// refreshTimeout bounds a synchronous connection test from the API.
+const refreshTimeout = 90 * time.Second
+
+type MCPServersHandler struct {
+	db      *database.DB
+	clients *mcp.Clients
+}
+
+func NewMCPServersHandler(db *database.DB, clients *mcp.Clients) *MCPServersHandler {
+	return &MCPServersHandler{db: db, clients: clients}
+}
+
+type mcpServerRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Transport   string            `json:"transport"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	URL         string            `json:"url"`
+	Env         map[string]string `json:"env"`
+	Headers     map[string]string `json:"headers"`
+	Enabled     *bool             `json:"enabled"`
+}
+
+func (req *mcpServerRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if !mcp.ValidServerName(req.Name) {
+		return fmt.Errorf("name must be 1-24 lowercase letters, digits, - or _ (it prefixes the tool names)")
+	}
+	if req.Transport == "" {
+		req.Transport = mcp.TransportStdio
+	}
+	switch req.Transport {
+	case mcp.TransportStdio:
+		if strings.TrimSpace(req.Command) == "" {
+			return fmt.Errorf("a stdio server needs a command")
+		}
+		req.URL = ""
+	case mcp.TransportHTTP:
+		if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+			return fmt.Errorf("an http server needs an http:// or https:// url")
+		}
+		req.Command, req.Args = "", nil
+	default:
+		return fmt.Errorf("transport must be stdio or http")
+	}
+	for what, m := range map[string]map[string]string{"env": req.Env, "headers": req.Headers} {
+		for key, secret := range m {
+			if strings.TrimSpace(key) == "" || strings.TrimSpace(secret) == "" {
+				return fmt.Errorf("%s entries map a name to a secret name; both are required", what)
+			}
+		}
+	}
+	if req.Args == nil {
+		req.Args = []string{}
+	}
+	if req.Env == nil {
+		req.Env = map[string]string{}
+	}
+	if req.Headers == nil {
+		req.Headers = map[string]string{}
+	}
+	return nil
+}
+
+// refreshInBackground connects to a new or edited server so its tools are
+// ready without making the request wait on a package download.
+func (h *MCPServersHandler) refreshInBackground(id, name string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		if _, err := h.clients.Refresh(ctx, id); err != nil {
+			logger.Warn("MCP server %s: %v", name, err)
+		}
+	}()
+}
+
+func (h *MCPServersHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	servers, err := h.clients.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list MCP servers")
+		return
+	}
+	writeJSON(w, http.StatusOK, servers)
+}
+
+func (h *MCPServersHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req mcpServerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var exists string
+	if h.db.QueryRow("SELECT id FROM mcp_servers WHERE name = ?", req.Name).Scan(&exists) == nil {
+		writeError(w, http.StatusConflict, "an MCP server with this name already exists")
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	args, _ := json.Marshal(req.Args)
+	env, _ := json.Marshal(req.Env)
+	headers, _ := json.Marshal(req.Headers)
+	id := generateID()
+	now := time.Now().UTC()
+	if _, err := h.db.Exec(
+		`INSERT INTO mcp_servers (id, name, description, transport, command, args, url, env, headers, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, req.Name, req.Description, req.Transport, req.Command, string(args), req.URL, string(env), string(headers),
+		enabled, now, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create MCP server")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "mcp_server_created", "mcp", "mcp_server", id, req.Name)
+
+	if enabled {
+		h.refreshInBackground(id, req.Name)
+	}
+	s, _ := h.clients.Get(id)
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (h *MCPServersHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	existing, err := h.clients.Get(id)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load MCP server")
+		return
+	}
+
+	var req mcpServerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var other string
+	if h.db.QueryRow("SELECT id FROM mcp_servers WHERE name = ? AND id != ?", req.Name, id).Scan(&other) == nil {
+		writeError(w, http.StatusConflict, "an MCP server with this name already exists")
+		return
+	}
+	enabled := existing.Enabled
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	args, _ := json.Marshal(req.Args)
+	env, _ := json.Marshal(req.Env)
+	headers, _ := json.Marshal(req.Headers)
+	if _, err := h.db.Exec(
+		`UPDATE mcp_servers SET name = ?, description = ?, transport = ?, command = ?, args = ?, url = ?, env = ?,
+		        headers = ?, enabled = ?, updated_at = ? WHERE id = ?`,
+		req.Name, req.Description, req.Transport, req.Command, string(args), req.URL, string(env), string(headers),
+		enabled, time.Now().UTC(), id,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update MCP server")
+		return
+	}
+	// Drop the old connection; it was opened with the old settings.
+	h.clients.Disconnect(id)
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "mcp_server_updated", "mcp", "mcp_server", id, req.Name)
+
+	if enabled {
+		h.refreshInBackground(id, req.Name)
+	}
+	s, _ := h.clients.Get(id)
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *MCPServersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var name string
+	if err := h.db.QueryRow("SELECT name FROM mcp_servers WHERE id = ?", id).Scan(&name); err != nil {
+		writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	}
+	h.clients.Disconnect(id)
+	if _, err := h.db.Exec("DELETE FROM mcp_servers WHERE id = ?", id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete MCP server")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "mcp_server_deleted", "mcp", "mcp_server", id, name)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// Refresh reconnects to a server and re-reads its tools, reporting the
+// failure if there is one — the "Test connection" button.
+func (h *MCPServersHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := h.clients.Get(id); err != nil {
+		writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), refreshTimeout)
+	defer cancel()
+	if _, err := h.clients.Refresh(ctx, id); err != nil {
+		writeError(w, http.StatusBadGateway, "could not connect: "+err.Error())
+		return
+	}
+	s, _ := h.clients.Get(id)
+	writeJSON(w, http.StatusOK, s)
+}
+
+type mcpGrants struct {
+	Agents     []string `json:"agents"`
+	Workspaces []string `json:"workspaces"`
+}
+
+func (h *MCPServersHandler) loadGrants(id string) mcpGrants {
+	g := mcpGrants{Agents: []string{}, Workspaces: []string{}}
+	rows, err := h.db.Query("SELECT scope_type, scope_id FROM mcp_server_grants WHERE server_id = ? ORDER BY scope_id", id)
+	if err != nil {
+		return g
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scopeType, scopeID string
+		if rows.Scan(&scopeType, &scopeID) != nil {
+			continue
+		}
+		if scopeType == mcp.GrantWorkspace {
+			g.Workspaces = append(g.Workspaces, scopeID)
+		} else {
+			g.Agents = append(g.Agents, scopeID)
+		}
+	}
+	return g
+}
+
+// GetGrants lists the agents and workspaces that may use a server's tools.
+func (h *MCPServersHandler) GetGrants(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := h.clients.Get(id); err != nil {
+		writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.loadGrants(id))
+}
+
+// SetGrants replaces a server's grants with the given agents and workspaces.
+func (h *MCPServersHandler) SetGrants(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	s, err := h.clients.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "MCP server not found")
+		return
+	}
+	var req mcpGrants
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	for _, slug := range req.Agents {
+		var one int
+		if h.db.QueryRow("SELECT 1 FROM agent_roles WHERE slug = ?", slug).Scan(&one) != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("agent %q not found", slug))
+			return
+		}
+	}
+	for _, wsID := range req.Workspaces {
+		var one int
+		if h.db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", wsID).Scan(&one) != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("workspace %q not found", wsID))
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update grants")
+		return
+	}
+	defer tx.Rollback()
+	tx.Exec("DELETE FROM mcp_server_grants WHERE server_id = ?", id)
+	now := time.Now().UTC()
+	for scopeType, ids := range map[string][]string{mcp.GrantAgent: req.Agents, mcp.GrantWorkspace: req.Workspaces} {
+		for _, scopeID := range ids {
+			if _, err := tx.Exec(
+				"INSERT OR IGNORE INTO mcp_server_grants (id, server_id, scope_type, scope_id, granted_at) VALUES (?, ?, ?, ?, ?)",
+				generateID(), id, scopeType, scopeID, now,
+			); err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to update grants")
+				return
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update grants")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "mcp_server_grants_updated", "mcp", "mcp_server", id,
+		fmt.Sprintf("%s agents=%d workspaces=%d", s.Name, len(req.Agents), len(req.Workspaces)))
+
+	writeJSON(w, http.StatusOK, h.loadGrants(id))
+}
@@ -88,6 +88,10 @@ func (h *MemoryHandler) statsForSlug(w http.ResponseWriter, r *http.Request, slu
 	var total, archived int
 	db.QueryRow("SELECT COUNT(*) FROM memories WHERE archived = 0").Scan(&total)
 	db.QueryRow("SELECT COUNT(*) FROM memories WHERE archived = 1").Scan(&archived)
+	// How far the semantic-search backfill has got; counts every model's
+	// vectors, which is close enough for a progress figure.
+	var embedded int
+	db.QueryRow("SELECT COUNT(*) FROM memory_embeddings").Scan(&embedded)
 
 	catRows, err := db.Query("SELECT category, COUNT(*) FROM memories WHERE archived = 0 GROUP BY category ORDER BY COUNT(*) DESC")
 	categories := map[string]int{}
@@ -104,6 +108,7 @@ func (h *MemoryHandler) statsForSlug(w http.ResponseWriter, r *http.Request, slu
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"total_active":   total,
 		"total_archived": archived,
+		"total_embedded": embedded,
 		"categories":     categories,
 	})
 }
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+// Settings keys for semantic memory search. The OpenRouter backend reuses the
+// OpenRouter API key; the key here is only for a self-hosted server that wants
+// one, and is stored encrypted like the other provider keys.
+const (
+	embeddingBackendKey = "memory_embedding_backend"
+	embeddingBaseURLKey = "memory_embedding_base_url"
+	embeddingModelKey   = "memory_embedding_model"
+	embeddingAPIKeyKey  = "memory_embedding_api_key"
+)
+
+// LoadEmbeddingConfig reads the memory embedding backend's saved
+// configuration.
+func LoadEmbeddingConfig(db *database.DB, secretsMgr *secrets.Manager) memory.EmbeddingConfig {
+	var cfg memory.EmbeddingConfig
+	db.QueryRow("SELECT value FROM settings WHERE key = ?", embeddingBackendKey).Scan(&cfg.Backend)
+	db.QueryRow("SELECT value FROM settings WHERE key = ?", embeddingBaseURLKey).Scan(&cfg.BaseURL)
+	db.QueryRow("SELECT value FROM settings WHERE key = ?", embeddingModelKey).Scan(&cfg.Model)
+
+	keySetting := embeddingAPIKeyKey
+	if cfg.Backend == memory.EmbeddingBackendOpenRouter {
+		if cfg.APIKey = os.Getenv("OPENROUTER_API_KEY"); cfg.APIKey != "" {
+			return cfg
+		}
+		keySetting = "openrouter_api_key"
+	}
+	var encrypted string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = ?", keySetting).Scan(&encrypted); err == nil && encrypted != "" && secretsMgr != nil {
+		if key, err := secretsMgr.Decrypt(encrypted); err == nil {
+			cfg.APIKey = key
+		} else {
+			logger.Warn("Failed to decrypt %s: %v", keySetting, err)
+		}
+	}
+	return cfg
+}
+
+// embeddingStatus is the embedding configuration as shown in Settings. The key
+// itself is never returned, only whether one is set.
+func (h *SettingsHandler) embeddingStatus() map[string]interface{} {
+	cfg := LoadEmbeddingConfig(h.db, h.secretsMgr)
+	var storedKey string
+	h.db.QueryRow("SELECT value FROM settings WHERE key = ?", embeddingAPIKeyKey).Scan(&storedKey)
+	return map[string]interface{}{
+		"backend":     cfg.Backend,
+		"base_url":    cfg.BaseURL,
+		"model":       cfg.Model,
+		"has_api_key": storedKey != "",
+		"active":      cfg.Embedder() != nil,
+	}
+}
+
+// GetMemoryEmbeddings returns the semantic memory search configuration.
+func (h *SettingsHandler) GetMemoryEmbeddings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.embeddingStatus())
+}
+
+// UpdateMemoryEmbeddings saves the embedding backend. Omitted fields are left
+// unchanged; an empty api_key clears the stored key. Existing memories are
+// embedded in the background once a backend is active.
+func (h *SettingsHandler) UpdateMemoryEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req struct {
+		Backend *string `json:"backend"`
+		BaseURL *string `json:"base_url"`
+		Model   *string `json:"model"`
+		APIKey  *string `json:"api_key"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Backend != nil {
+		switch *req.Backend {
+		case memory.EmbeddingBackendNone, memory.EmbeddingBackendOpenRouter, memory.EmbeddingBackendCompat:
+		default:
+			writeError(w, http.StatusBadRequest, "backend must be openrouter, openai_compatible or empty")
+			return
+		}
+		h.upsertSetting(embeddingBackendKey, *req.Backend)
+	}
+	if req.BaseURL != nil {
+		baseURL := strings.TrimRight(strings.TrimSpace(*req.BaseURL), "/")
+		if baseURL != "" && !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+			writeError(w, http.StatusBadRequest, "base_url must be an http:// or https:// URL")
+			return
+		}
+		h.upsertSetting(embeddingBaseURLKey, baseURL)
+	}
+	if req.Model != nil {
+		h.upsertSetting(embeddingModelKey, strings.TrimSpace(*req.Model))
+	}
+	if req.APIKey != nil {
+		key := strings.TrimSpace(*req.APIKey)
+		if key == "" {
+			h.db.Exec("DELETE FROM settings WHERE key = ?", embeddingAPIKeyKey)
+		} else {
+			encrypted, err := h.secretsMgr.Encrypt(key)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to encrypt API key")
+				return
+			}
+			h.upsertSetting(embeddingAPIKeyKey, encrypted)
+		}
+	}
+
+	status := h.embeddingStatus()
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "memory_embeddings_updated", "settings", "settings", embeddingBackendKey, status["backend"].(string))
+
+	writeJSON(w, http.StatusOK, status)
+}
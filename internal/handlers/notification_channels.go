@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/notify"
+)
+
+// channelTestTimeout bounds the "Send test" button.
+const channelTestTimeout = 45 * time.Second
+
+// NotificationChannelsHandler manages where notifications are delivered
+// besides the Inbox: channels, the rules routing to them, and their delivery
+// logs.
+type NotificationChannelsHandler struct {
+	db       *database.DB
+	notifier *notify.Dispatcher
+}
+
+func NewNotificationChannelsHandler(db *database.DB, notifier *notify.Dispatcher) *NotificationChannelsHandler {
+	return &NotificationChannelsHandler{db: db, notifier: notifier}
+}
+
+type notificationChannelRequest struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Config  map[string]string `json:"config"`
+	Secrets map[string]string `json:"secrets"`
+	Enabled *bool             `json:"enabled"`
+}
+
+func (h *NotificationChannelsHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	channels, err := h.notifier.ListChannels()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notification channels")
+		return
+	}
+	writeJSON(w, http.StatusOK, channels)
+}
+
+func (h *NotificationChannelsHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req notificationChannelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	c := &notify.Channel{Name: req.Name, Type: req.Type, Config: req.Config, Secrets: req.Secrets, Enabled: true}
+	if req.Enabled != nil {
+		c.Enabled = *req.Enabled
+	}
+	if err := c.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.notifier.CreateChannel(c); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create notification channel")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_channel_created", "notifications", "notification_channel", c.ID, c.Type+" "+c.Name)
+
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *NotificationChannelsHandler) UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	c, err := h.notifier.GetChannel(chi.URLParam(r, "id"))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "notification channel not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load notification channel")
+		return
+	}
+	var req notificationChannelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	c.Name, c.Type, c.Config, c.Secrets = req.Name, req.Type, req.Config, req.Secrets
+	if req.Enabled != nil {
+		c.Enabled = *req.Enabled
+	}
+	if err := c.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.notifier.UpdateChannel(c); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update notification channel")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_channel_updated", "notifications", "notification_channel", c.ID, c.Type+" "+c.Name)
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *NotificationChannelsHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	c, err := h.notifier.GetChannel(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "notification channel not found")
+		return
+	}
+	if err := h.notifier.DeleteChannel(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete notification channel")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_channel_deleted", "notifications", "notification_channel", id, c.Type+" "+c.Name)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// TestChannel sends a sample notification through a channel and reports what
+// the service answered — the "Send test" button.
+func (h *NotificationChannelsHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := h.notifier.GetChannel(id); err != nil {
+		writeError(w, http.StatusNotFound, "notification channel not found")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), channelTestTimeout)
+	defer cancel()
+	if err := h.notifier.Test(ctx, id); err != nil {
+		writeError(w, http.StatusBadGateway, "test failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
+
+// Deliveries is a channel's delivery log, newest first. ?status= narrows it
+// to pending, sent or failed.
+func (h *NotificationChannelsHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := h.notifier.GetChannel(id); err != nil {
+		writeError(w, http.StatusNotFound, "notification channel not found")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	deliveries, err := h.notifier.Deliveries(id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// RetryDelivery queues a pending or failed delivery for another attempt now.
+func (h *NotificationChannelsHandler) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	delivery, err := h.notifier.Retry(chi.URLParam(r, "id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "delivery not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+type notificationRuleRequest struct {
+	ChannelID       string   `json:"channel_id"`
+	Name            string   `json:"name"`
+	MinPriority     string   `json:"min_priority"`
+	SourceTypes     []string `json:"source_types"`
+	WorkspaceID     string   `json:"workspace_id"`
+	AgentSlug       string   `json:"agent_slug"`
+	QuietStart      string   `json:"quiet_start"`
+	QuietEnd        string   `json:"quiet_end"`
+	Timezone        string   `json:"timezone"`
+	QuietAllowsHigh *bool    `json:"quiet_allows_high"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+func (req *notificationRuleRequest) apply(rule *notify.Rule) {
+	rule.Name = req.Name
+	rule.MinPriority = req.MinPriority
+	rule.SourceTypes = req.SourceTypes
+	rule.WorkspaceID = req.WorkspaceID
+	rule.AgentSlug = req.AgentSlug
+	rule.QuietStart = req.QuietStart
+	rule.QuietEnd = req.QuietEnd
+	rule.Timezone = req.Timezone
+	if req.QuietAllowsHigh != nil {
+		rule.QuietAllowsHigh = *req.QuietAllowsHigh
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+// ListRules returns every routing rule, or one channel's with ?channel_id=.
+func (h *NotificationChannelsHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	rules, err := h.notifier.ListRules(r.URL.Query().Get("channel_id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notification rules")
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (h *NotificationChannelsHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req notificationRuleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, err := h.notifier.GetChannel(req.ChannelID); err != nil {
+		writeError(w, http.StatusBadRequest, "channel_id must name an existing channel")
+		return
+	}
+	rule := &notify.Rule{ChannelID: req.ChannelID, QuietAllowsHigh: true, Enabled: true}
+	req.apply(rule)
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.notifier.CreateRule(rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create notification rule")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_rule_created", "notifications", "notification_rule", rule.ID, "channel="+rule.ChannelID)
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (h *NotificationChannelsHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	rule, err := h.notifier.GetRule(chi.URLParam(r, "id"))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "notification rule not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load notification rule")
+		return
+	}
+	var req notificationRuleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.apply(rule)
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.notifier.UpdateRule(rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update notification rule")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_rule_updated", "notifications", "notification_rule", rule.ID, "channel="+rule.ChannelID)
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (h *NotificationChannelsHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := h.notifier.DeleteRule(id); err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "notification rule not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete notification rule")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "notification_rule_deleted", "notifications", "notification_rule", id, "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
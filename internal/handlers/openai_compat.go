@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+// Settings keys for the OpenAI-compatible provider. The API key is stored
+// encrypted like the OpenRouter key; the model overrides are a JSON array of
+// llm.ModelOverride.
+const (
+	compatBaseURLKey      = "openai_compat_base_url"
+	compatAPIKeyKey       = "openai_compat_api_key"
+	compatDefaultModelKey = "openai_compat_default_model"
+	compatModelsKey       = "openai_compat_models"
+)
+
+// LoadCompatConfig reads the OpenAI-compatible provider's saved configuration.
+func LoadCompatConfig(db *database.DB, secretsMgr *secrets.Manager) llm.CompatConfig {
+	var cfg llm.CompatConfig
+	db.QueryRow("SELECT value FROM settings WHERE key = ?", compatBaseURLKey).Scan(&cfg.BaseURL)
+	db.QueryRow("SELECT value FROM settings WHERE key = ?", compatDefaultModelKey).Scan(&cfg.DefaultModel)
+
+	var encrypted string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = ?", compatAPIKeyKey).Scan(&encrypted); err == nil && encrypted != "" && secretsMgr != nil {
+		if key, err := secretsMgr.Decrypt(encrypted); err == nil {
+			cfg.APIKey = key
+		} else {
+			logger.Warn("Failed to decrypt OpenAI-compatible API key: %v", err)
+		}
+	}
+
+	var modelsJSON string
+	if err := db.QueryRow("SELECT value FROM settings WHERE key = ?", compatModelsKey).Scan(&modelsJSON); err == nil && modelsJSON != "" {
+		if err := json.Unmarshal([]byte(modelsJSON), &cfg.Models); err != nil {
+			logger.Warn("Ignoring malformed %s setting: %v", compatModelsKey, err)
+		}
+	}
+	return cfg
+}
+
+// compatProvider returns the registered OpenAI-compatible provider, if any.
+func (h *SettingsHandler) compatProvider() *llm.CompatProvider {
+	if h.providers == nil {
+		return nil
+	}
+	p, _ := h.providers.Get(llm.ProviderOpenAICompat).(*llm.CompatProvider)
+	return p
+}
+
+// GetOpenAICompat returns the OpenAI-compatible provider's configuration.
+func (h *SettingsHandler) GetOpenAICompat(w http.ResponseWriter, r *http.Request) {
+	p := h.compatProvider()
+	if p == nil {
+		writeError(w, http.StatusNotFound, "OpenAI-compatible provider not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, p.StatusInfo())
+}
+
+// UpdateOpenAICompat saves the server URL, key, default model and per-model
+// overrides, and applies them immediately. Omitted fields are left unchanged;
+// an empty api_key clears the stored key.
+func (h *SettingsHandler) UpdateOpenAICompat(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	p := h.compatProvider()
+	if p == nil {
+		writeError(w, http.StatusNotFound, "OpenAI-compatible provider not available")
+		return
+	}
+
+	var req struct {
+		BaseURL      *string              `json:"base_url"`
+		APIKey       *string              `json:"api_key"`
+		DefaultModel *string              `json:"default_model"`
+		Models       *[]llm.ModelOverride `json:"models"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	cfg := p.Config()
+	if req.BaseURL != nil {
+		baseURL := llm.NormalizeCompatBaseURL(*req.BaseURL)
+		if baseURL != "" && !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+			writeError(w, http.StatusBadRequest, "base_url must be an http:// or https:// URL")
+			return
+		}
+		cfg.BaseURL = baseURL
+		h.upsertSetting(compatBaseURLKey, baseURL)
+	}
+	if req.APIKey != nil {
+		key := strings.TrimSpace(*req.APIKey)
+		if key == "" {
+			h.db.Exec("DELETE FROM settings WHERE key = ?", compatAPIKeyKey)
+		} else {
+			encrypted, err := h.secretsMgr.Encrypt(key)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to encrypt API key")
+				return
+			}
+			h.upsertSetting(compatAPIKeyKey, encrypted)
+		}
+		cfg.APIKey = key
+	}
+	if req.DefaultModel != nil {
+		cfg.DefaultModel = strings.TrimSpace(*req.DefaultModel)
+		h.upsertSetting(compatDefaultModelKey, cfg.DefaultModel)
+	}
+	if req.Models != nil {
+		var models []llm.ModelOverride
+		for _, m := range *req.Models {
+			m.ID = strings.TrimSpace(m.ID)
+			if m.ID == "" {
+				continue
+			}
+			if m.ContextLength < 0 || m.MaxTokens < 0 || m.InputPerMillion < 0 || m.OutputPerMillion < 0 {
+				writeError(w, http.StatusBadRequest, "model overrides cannot be negative: "+m.ID)
+				return
+			}
+			models = append(models, m)
+		}
+		data, _ := json.Marshal(models)
+		h.upsertSetting(compatModelsKey, string(data))
+		cfg.Models = models
+	}
+
+	p.Configure(cfg)
+
+	// Re-resolve the live models if this provider is serving chat, so a new
+	// default model takes effect without switching away and back.
+	if h.activeProviderName() == llm.ProviderOpenAICompat {
+		ApplyProviderModels(h.db, h.providers, llm.ProviderOpenAICompat, func(gateway, builder string) {
+			h.agentMgr.GatewayModel = gateway
+			h.agentMgr.BuilderModel = builder
+		})
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "openai_compat_updated", "settings", "settings", compatBaseURLKey, cfg.BaseURL)
+
+	writeJSON(w, http.StatusOK, p.StatusInfo())
+}
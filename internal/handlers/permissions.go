@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+// requireRole checks the caller's account role. On failure it writes a 403
+// and returns false, so handlers can `if !requireRole(...) { return }`.
+func requireRole(w http.ResponseWriter, r *http.Request, db *database.DB, min string) bool {
+	if auth.RoleAtLeast(db.UserRole(middleware.GetUserID(r.Context())), min) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, min+" role required")
+	return false
+}
+
+// requireWorkspaceRole checks the caller's role inside a workspace. An empty
+// workspaceID is an unscoped resource (a global tool or schedule) and falls
+// back to the account role.
+func requireWorkspaceRole(w http.ResponseWriter, r *http.Request, db *database.DB, workspaceID, min string) bool {
+	if workspaceID == "" {
+		return requireRole(w, r, db, min)
+	}
+	if auth.RoleAtLeast(db.WorkspaceRole(middleware.GetUserID(r.Context()), workspaceID), min) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, min+" access to this workspace required")
+	return false
+}
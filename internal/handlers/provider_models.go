@@ -67,6 +67,10 @@ func defaultModelsFor(provider string) (gateway, builder string) {
 		return "sonnet", "sonnet"
 	case "codex":
 		return "gpt-5.5", "gpt-5.5"
+	case llm.ProviderOpenAICompat:
+		// Model names are whatever the server serves; blank resolves to the
+		// provider's configured default.
+		return "", ""
 	default:
 		return llm.ModelSonnet, llm.ModelSonnet
 	}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/models"
+	"github.com/openpaw/openpaw/internal/scheduler"
+)
+
+// chainStepRequest is one step in a create or update body. Key names the
+// step so others can wait on it (depends_on) before it has an ID; sending an
+// existing step's ID as the key keeps that ID, and with it the step's history.
+type chainStepRequest struct {
+	Key            string   `json:"key"`
+	DependsOn      []string `json:"depends_on"`
+	Name           string   `json:"name"`
+	RunOn          string   `json:"run_on"`
+	ConditionType  string   `json:"condition_type"`
+	ConditionValue string   `json:"condition_value"`
+	AgentRoleSlug  string   `json:"agent_role_slug"`
+	PromptTemplate string   `json:"prompt_template"`
+	Notify         bool     `json:"notify"`
+}
+
+// authorizeChain checks the caller's role against the chain's root schedule,
+// whose workspace the whole chain runs in.
+func (h *SchedulesHandler) authorizeChain(w http.ResponseWriter, r *http.Request, id, minRole string) bool {
+	var rootID string
+	if err := h.db.QueryRow("SELECT root_schedule_id FROM schedule_chains WHERE id = ?", id).Scan(&rootID); err != nil {
+		writeError(w, http.StatusNotFound, "chain not found")
+		return false
+	}
+	return h.authorizeSchedule(w, r, rootID, minRole)
+}
+
+// buildChainSteps validates a step list and resolves keys and dependencies
+// into stored steps. Every step must be reachable from the root through steps
+// that are themselves reachable, which rules out cycles and dangling edges.
+func (h *SchedulesHandler) buildChainSteps(chainID string, reqs []chainStepRequest) ([]models.ScheduleChainStep, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("a chain needs at least one step")
+	}
+	if len(reqs) > scheduler.MaxChainSteps {
+		return nil, fmt.Errorf("a chain can have at most %d steps", scheduler.MaxChainSteps)
+	}
+
+	existing := map[string]bool{}
+	if chainID != "" {
+		rows, err := h.db.Query("SELECT id FROM schedule_chain_steps WHERE chain_id = ?", chainID)
+		if err == nil {
+			for rows.Next() {
+				var id string
+				if rows.Scan(&id) == nil {
+					existing[id] = true
+				}
+			}
+			rows.Close()
+		}
+	}
+
+	ids := map[string]string{} // key -> step ID
+	for i, req := range reqs {
+		key := req.Key
+		if key == "" {
+			key = fmt.Sprint(i)
+		}
+		if _, dup := ids[key]; dup {
+			return nil, fmt.Errorf("duplicate step key %q", key)
+		}
+		reqs[i].Key = key
+		if existing[key] {
+			ids[key] = key
+		} else {
+			ids[key] = generateID()
+		}
+	}
+
+	steps := make([]models.ScheduleChainStep, len(reqs))
+	for i, req := range reqs {
+		dependsOn := []string{}
+		seen := map[string]bool{}
+		for _, dep := range req.DependsOn {
+			depID, ok := ids[dep]
+			if !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", req.Key, dep)
+			}
+			if !seen[depID] {
+				seen[depID] = true
+				dependsOn = append(dependsOn, depID)
+			}
+		}
+		if req.RunOn == "" {
+			req.RunOn = scheduler.RunOnSuccess
+		}
+		step := models.ScheduleChainStep{
+			ID:             ids[req.Key],
+			DependsOn:      dependsOn,
+			Name:           req.Name,
+			RunOn:          req.RunOn,
+			ConditionType:  req.ConditionType,
+			ConditionValue: req.ConditionValue,
+			AgentRoleSlug:  req.AgentRoleSlug,
+			PromptTemplate: req.PromptTemplate,
+			Notify:         req.Notify,
+			SortOrder:      i,
+		}
+		if err := scheduler.ValidateChainStep(step); err != nil {
+			return nil, fmt.Errorf("step %q: %w", req.Key, err)
+		}
+		var slug string
+		if err := h.db.QueryRow("SELECT slug FROM agent_roles WHERE slug = ? AND enabled = 1", step.AgentRoleSlug).Scan(&slug); err != nil {
+			return nil, fmt.Errorf("step %q: agent role %q not found or disabled", req.Key, step.AgentRoleSlug)
+		}
+		steps[i] = step
+	}
+
+	// Walk down from the root, taking a step once everything it depends on is
+	// reached; anything left over waits on a cycle.
+	reached := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, st := range steps {
+			if !reached[st.ID] && allReached(reached, st.DependsOn) {
+				reached[st.ID] = true
+				changed = true
+			}
+		}
+	}
+	for _, st := range steps {
+		if !reached[st.ID] {
+			return nil, fmt.Errorf("steps form a cycle")
+		}
+	}
+	return steps, nil
+}
+
+func allReached(reached map[string]bool, ids []string) bool {
+	for _, id := range ids {
+		if !reached[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func saveChainSteps(tx *sql.Tx, chainID string, steps []models.ScheduleChainStep) error {
+	if _, err := tx.Exec("DELETE FROM schedule_chain_steps WHERE chain_id = ?", chainID); err != nil {
+		return err
+	}
+	for _, st := range steps {
+		if _, err := tx.Exec(
+			`INSERT INTO schedule_chain_steps (id, chain_id, name, run_on, condition_type, condition_value,
+			                                   agent_role_slug, prompt_template, notify, sort_order)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			st.ID, chainID, st.Name, st.RunOn, st.ConditionType, st.ConditionValue,
+			st.AgentRoleSlug, st.PromptTemplate, st.Notify, st.SortOrder,
+		); err != nil {
+			return err
+		}
+	}
+	// Edges go in once every step exists, since a step may depend on one
+	// listed after it.
+	for _, st := range steps {
+		for _, dep := range st.DependsOn {
+			if _, err := tx.Exec(
+				"INSERT INTO schedule_chain_edges (step_id, depends_on_step_id) VALUES (?, ?)", st.ID, dep,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *SchedulesHandler) loadChain(id string) (models.ScheduleChain, error) {
+	var c models.ScheduleChain
+	err := h.db.QueryRow(
+		"SELECT id, name, description, root_schedule_id, enabled, created_at, updated_at FROM schedule_chains WHERE id = ?", id,
+	).Scan(&c.ID, &c.Name, &c.Description, &c.RootScheduleID, &c.Enabled, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+	c.Steps, err = scheduler.LoadChainSteps(h.db, id)
+	return c, err
+}
+
+// ListChains returns the chains whose root schedules the caller can see.
+func (h *SchedulesHandler) ListChains(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	userID := middleware.GetUserID(r.Context())
+	rows, err := h.db.Query(
+		`SELECT c.id FROM schedule_chains c JOIN schedules s ON s.id = c.root_schedule_id
+		 WHERE ? OR s.workspace_id IS NULL OR s.workspace_id = ''
+		    OR s.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		 ORDER BY c.created_at DESC`,
+		h.db.UserRole(userID) == auth.RoleAdmin, userID,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list chains")
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	chains := []models.ScheduleChain{}
+	for _, id := range ids {
+		c, err := h.loadChain(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load chain")
+			return
+		}
+		chains = append(chains, c)
+	}
+	writeJSON(w, http.StatusOK, chains)
+}
+
+func (h *SchedulesHandler) CreateChain(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name           string             `json:"name"`
+		Description    string             `json:"description"`
+		RootScheduleID string             `json:"root_schedule_id"`
+		Steps          []chainStepRequest `json:"steps"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" || req.RootScheduleID == "" {
+		writeError(w, http.StatusBadRequest, "name and root_schedule_id are required")
+		return
+	}
+	if !h.authorizeSchedule(w, r, req.RootScheduleID, auth.RoleEditor) {
+		return
+	}
+	steps, err := h.buildChainSteps("", req.Steps)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := generateID()
+	now := time.Now().UTC()
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create chain")
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`INSERT INTO schedule_chains (id, name, description, root_schedule_id, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, 1, ?, ?)`,
+		id, req.Name, req.Description, req.RootScheduleID, now, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create chain")
+		return
+	}
+	if err := saveChainSteps(tx, id, steps); err != nil || tx.Commit() != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create chain")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "schedule_chain_created", "schedule", "schedule_chain", id, req.Name)
+
+	c, _ := h.loadChain(id)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// UpdateChain edits a chain. A steps list replaces the whole graph; steps sent
+// with their existing ID as key keep their run history.
+func (h *SchedulesHandler) UpdateChain(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.authorizeChain(w, r, id, auth.RoleEditor) {
+		return
+	}
+	var req struct {
+		Name        *string            `json:"name"`
+		Description *string            `json:"description"`
+		Enabled     *bool              `json:"enabled"`
+		Steps       []chainStepRequest `json:"steps"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var steps []models.ScheduleChainStep
+	if req.Steps != nil {
+		var err error
+		if steps, err = h.buildChainSteps(id, req.Steps); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update chain")
+		return
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	if req.Name != nil {
+		tx.Exec("UPDATE schedule_chains SET name = ? WHERE id = ?", *req.Name, id)
+	}
+	if req.Description != nil {
+		tx.Exec("UPDATE schedule_chains SET description = ? WHERE id = ?", *req.Description, id)
+	}
+	if req.Enabled != nil {
+		tx.Exec("UPDATE schedule_chains SET enabled = ? WHERE id = ?", *req.Enabled, id)
+	}
+	tx.Exec("UPDATE schedule_chains SET updated_at = ? WHERE id = ?", now, id)
+	if steps != nil {
+		if err := saveChainSteps(tx, id, steps); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update chain")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update chain")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "schedule_chain_updated", "schedule", "schedule_chain", id, "")
+
+	c, _ := h.loadChain(id)
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *SchedulesHandler) DeleteChain(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.authorizeChain(w, r, id, auth.RoleEditor) {
+		return
+	}
+	if _, err := h.db.Exec("DELETE FROM schedule_chains WHERE id = ?", id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete chain")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "schedule_chain_deleted", "schedule", "schedule_chain", id, "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "chain deleted"})
+}
+
+// ChainRuns returns a chain's recent runs with every step's outcome, so a
+// multi-step flow reads as one unit.
+func (h *SchedulesHandler) ChainRuns(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.authorizeChain(w, r, id, auth.RoleViewer) {
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT id, chain_id, root_execution_id, status, started_at, finished_at
+		 FROM schedule_chain_runs WHERE chain_id = ? ORDER BY started_at DESC LIMIT 50`, id,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list chain runs")
+		return
+	}
+	runs := []models.ScheduleChainRun{}
+	for rows.Next() {
+		var run models.ScheduleChainRun
+		if err := rows.Scan(&run.ID, &run.ChainID, &run.RootExecutionID, &run.Status, &run.StartedAt, &run.FinishedAt); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "failed to scan chain run")
+			return
+		}
+		runs = append(runs, run)
+	}
+	rows.Close()
+
+	for i := range runs {
+		runs[i].Steps = []models.ScheduleChainRunStep{}
+		stepRows, err := h.db.Query(
+			`SELECT id, step_id, status, output, error, thread_id, started_at, finished_at
+			 FROM schedule_chain_run_steps WHERE run_id = ? ORDER BY started_at, rowid`, runs[i].ID,
+		)
+		if err != nil {
+			continue
+		}
+		for stepRows.Next() {
+			var st models.ScheduleChainRunStep
+			if stepRows.Scan(&st.ID, &st.StepID, &st.Status, &st.Output, &st.Error, &st.ThreadID, &st.StartedAt, &st.FinishedAt) == nil {
+				runs[i].Steps = append(runs[i].Steps, st)
+			}
+		}
+		stepRows.Close()
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
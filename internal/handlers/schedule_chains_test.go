@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/scheduler"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+func TestCreateChainValidatesGraph(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	db.Exec("INSERT INTO agent_roles (id, slug, name, description, system_prompt, enabled) VALUES ('a1', 'scout', 'Scout', '', '', 1)")
+	db.Exec(`INSERT INTO schedules (id, name, cron_expr, tool_id, enabled, type, agent_role_slug, prompt_content)
+	         VALUES ('s1', 'Research', '0 0 8 * * *', '', 1, 'prompt', 'scout', 'go')`)
+
+	h := NewSchedulesHandler(db, scheduler.New(db), secrets.NewManager("test-key"))
+	router := chi.NewRouter()
+	router.Post("/schedule-chains", h.CreateChain)
+	router.Get("/schedule-chains/{id}/runs", h.ChainRuns)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodPost, "/schedule-chains", strings.NewReader(body)), testAdminID))
+		return rec
+	}
+
+	bad := map[string]string{
+		"cycle":               `{"name":"x","root_schedule_id":"s1","steps":[{"key":"a","depends_on":["b"],"agent_role_slug":"scout"},{"key":"b","depends_on":["a"],"agent_role_slug":"scout"}]}`,
+		"cycle behind a join": `{"name":"x","root_schedule_id":"s1","steps":[{"key":"a","agent_role_slug":"scout"},{"key":"b","depends_on":["a","c"],"agent_role_slug":"scout"},{"key":"c","depends_on":["b"],"agent_role_slug":"scout"}]}`,
+		"self dependency":     `{"name":"x","root_schedule_id":"s1","steps":[{"key":"a","depends_on":["a"],"agent_role_slug":"scout"}]}`,
+		"unknown dependency":  `{"name":"x","root_schedule_id":"s1","steps":[{"key":"a","depends_on":["nope"],"agent_role_slug":"scout"}]}`,
+		"unknown agent":       `{"name":"x","root_schedule_id":"s1","steps":[{"agent_role_slug":"ghost"}]}`,
+		"bad regex":           `{"name":"x","root_schedule_id":"s1","steps":[{"agent_role_slug":"scout","condition_type":"regex","condition_value":"("}]}`,
+		"no steps":            `{"name":"x","root_schedule_id":"s1","steps":[]}`,
+	}
+	for name, body := range bad {
+		if rec := create(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: create = %d, want 400", name, rec.Code)
+		}
+	}
+
+	rec := create(`{"name":"Morning","root_schedule_id":"s1","steps":[
+		{"key":"summarize","agent_role_slug":"scout","prompt_template":"Summarize {{output}}"},
+		{"key":"metrics","agent_role_slug":"scout"},
+		{"key":"post","depends_on":["summarize","metrics"],"agent_role_slug":"scout","condition_type":"contains","condition_value":"new"}
+	]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", rec.Code, rec.Body.String())
+	}
+	var chain struct {
+		ID    string `json:"id"`
+		Steps []struct {
+			ID        string   `json:"id"`
+			DependsOn []string `json:"depends_on"`
+			RunOn     string   `json:"run_on"`
+		} `json:"steps"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &chain)
+	if len(chain.Steps) != 3 || len(chain.Steps[0].DependsOn) != 0 || chain.Steps[0].RunOn != scheduler.RunOnSuccess ||
+		strings.Join(chain.Steps[2].DependsOn, ",") != chain.Steps[0].ID+","+chain.Steps[1].ID {
+		t.Errorf("stored steps = %+v", chain.Steps)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodGet, "/schedule-chains/"+chain.ID+"/runs", nil), testAdminID))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("runs = %d %s, want 200 []", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/scheduler"
+)
+
+// maxWebhookBody caps an inbound delivery. GitHub's largest push events stay
+// well under this; anything bigger isn't a payload a prompt can use.
+const maxWebhookBody = 1 << 20
+
+func webhookPath(token string) string {
+	return "/api/v1/hooks/" + token
+}
+
+// newWebhookSecret creates a URL token and signing secret, returning the
+// secret both in the clear (shown to the user once) and encrypted for storage.
+func (h *SchedulesHandler) newWebhookSecret() (token, secret, encrypted string, err error) {
+	token, secret, err = scheduler.GenerateWebhookToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	encrypted, err = h.secrets.Encrypt(secret)
+	if err != nil {
+		return "", "", "", err
+	}
+	return token, secret, encrypted, nil
+}
+
+// RotateWebhookSecret replaces a webhook automation's signing secret. The URL
+// stays the same, so the sender only needs the new secret.
+func (h *SchedulesHandler) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleEditor) {
+		return
+	}
+
+	var triggerType, token string
+	if err := h.db.QueryRow("SELECT trigger_type, webhook_token FROM schedules WHERE id = ?", id).Scan(&triggerType, &token); err != nil {
+		writeError(w, http.StatusNotFound, "schedule not found")
+		return
+	}
+	if triggerType != scheduler.TriggerWebhook {
+		writeError(w, http.StatusBadRequest, "schedule is not a webhook automation")
+		return
+	}
+
+	_, secret, encrypted, err := h.newWebhookSecret()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create webhook secret")
+		return
+	}
+	if _, err := h.db.Exec("UPDATE schedules SET webhook_secret = ?, updated_at = ? WHERE id = ?", encrypted, time.Now().UTC(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to rotate webhook secret")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "schedule_webhook_rotated", "schedule", "schedule", id, "")
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"webhook_url":    webhookPath(token),
+		"webhook_secret": secret,
+	})
+}
+
+// ReceiveWebhook is the public endpoint a sender POSTs to. The URL token finds
+// the automation, the HMAC signature proves the sender holds its secret, and
+// the payload fills the prompt template before the run is handed to the
+// scheduler — the same path, history and Inbox report as a cron fire.
+//
+// It answers 202 as soon as the run is queued: senders like GitHub time out
+// after ten seconds, far shorter than an agent run.
+func (h *SchedulesHandler) ReceiveWebhook(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if len(body) > maxWebhookBody {
+		writeError(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+
+	var (
+		id, encrypted, prompt, slug, threadID, provider string
+		workspaceID                                     sql.NullString
+		enabled                                         bool
+	)
+	err = h.db.QueryRow(
+		`SELECT id, webhook_secret, enabled, prompt_content, agent_role_slug, thread_id, workspace_id, provider
+		 FROM schedules WHERE webhook_token = ? AND trigger_type = ?`, token, scheduler.TriggerWebhook,
+	).Scan(&id, &encrypted, &enabled, &prompt, &slug, &threadID, &workspaceID, &provider)
+	if token == "" || err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	now := time.Now().UTC()
+	secret, err := h.secrets.Decrypt(encrypted)
+	var replayKey string
+	if err == nil {
+		replayKey = scheduler.VerifySignature(secret, r.Header, body, now)
+	}
+	if replayKey == "" {
+		logger.Warn("Webhook for schedule %s rejected: bad signature", id)
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	if !enabled {
+		writeError(w, http.StatusConflict, "automation is paused")
+		return
+	}
+	fresh, err := scheduler.ClaimDelivery(h.db, id, replayKey, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record delivery")
+		return
+	}
+	if !fresh {
+		// A sender retrying a delivery that did arrive gets its 2xx; a
+		// replay gets nothing run.
+		logger.Warn("Webhook for schedule %s ignored: delivery already received", id)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"message":     "delivery already received",
+			"schedule_id": id,
+		})
+		return
+	}
+
+	h.scheduler.RunNow(scheduler.ScheduleConfig{
+		ID:            id,
+		AgentRoleSlug: slug,
+		PromptContent: scheduler.RenderPrompt(prompt, body, r.Header),
+		ThreadID:      threadID,
+		WorkspaceID:   workspaceID.String,
+		Provider:      provider,
+		Trigger:       scheduler.TriggerWebhook,
+	})
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":     "automation triggered",
+		"schedule_id": id,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/scheduler"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+func TestWebhookAutomationRunsOnSignedDelivery(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	db.Exec("INSERT INTO agent_roles (id, slug, name, description, system_prompt, enabled) VALUES ('a1', 'scout', 'Scout', '', '', 1)")
+
+	h := NewSchedulesHandler(db, scheduler.New(db), secrets.NewManager("test-key"))
+	router := chi.NewRouter()
+	router.Post("/schedules", h.Create)
+	router.Post("/hooks/{token}", h.ReceiveWebhook)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodPost, "/schedules", strings.NewReader(
+		`{"name":"PR review","trigger_type":"webhook","agent_role_slug":"scout","prompt_content":"Review {{payload.pull_request.title}}"}`,
+	)), testAdminID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		ID            string `json:"id"`
+		WebhookURL    string `json:"webhook_url"`
+		WebhookSecret string `json:"webhook_secret"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.WebhookSecret == "" || !strings.HasPrefix(created.WebhookURL, "/api/v1/hooks/") {
+		t.Fatalf("create returned url %q secret %q", created.WebhookURL, created.WebhookSecret)
+	}
+	hookPath := strings.TrimPrefix(created.WebhookURL, "/api/v1")
+
+	body := `{"pull_request":{"title":"Add retries"}}`
+	deliver := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, hookPath, strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := deliver(strings.Repeat("0", 64)); got != http.StatusUnauthorized {
+		t.Errorf("badly signed delivery = %d, want 401", got)
+	}
+
+	mac := hmac.New(sha256.New, []byte(created.WebhookSecret))
+	mac.Write([]byte(body))
+	if got := deliver(hex.EncodeToString(mac.Sum(nil))); got != http.StatusAccepted {
+		t.Fatalf("signed delivery = %d, want 202", got)
+	}
+
+	// The run is recorded with the schedule's other executions.
+	deadline := time.Now().Add(2 * time.Second)
+	var status, triggeredBy string
+	for time.Now().Before(deadline) {
+		db.QueryRow("SELECT status, triggered_by FROM schedule_executions WHERE schedule_id = ?", created.ID).Scan(&status, &triggeredBy)
+		if status == "success" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status != "success" || triggeredBy != scheduler.TriggerWebhook {
+		t.Errorf("execution = %q triggered by %q, want success by webhook", status, triggeredBy)
+	}
+
+	// Sending the same delivery again runs nothing.
+	if got := deliver(hex.EncodeToString(mac.Sum(nil))); got != http.StatusOK {
+		t.Errorf("replayed delivery = %d, want 200", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	var runs int
+	db.QueryRow("SELECT COUNT(*) FROM schedule_executions WHERE schedule_id = ?", created.ID).Scan(&runs)
+	if runs != 1 {
+		t.Errorf("replay ran the automation again: %d executions", runs)
+	}
+}
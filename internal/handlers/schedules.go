@@ -2,20 +2,25 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
 	"github.com/openpaw/openpaw/internal/scheduler"
+	"github.com/openpaw/openpaw/internal/secrets"
 )
 
 type SchedulesHandler struct {
 	db        *database.DB
 	scheduler *scheduler.Scheduler
+	// secrets encrypts webhook signing secrets at rest.
+	secrets *secrets.Manager
 }
 
 func scheduleToConfig(id string, s models.Schedule) scheduler.ScheduleConfig {
@@ -34,16 +39,114 @@ func scheduleToConfig(id string, s models.Schedule) scheduler.ScheduleConfig {
 	}
 }
 
-func NewSchedulesHandler(db *database.DB, sched *scheduler.Scheduler) *SchedulesHandler {
-	return &SchedulesHandler{db: db, scheduler: sched}
+// schedulePolicyRequest is the optional retry/timeout/overlap part of a
+// create or update body. Omitted fields keep their current (or default) value.
+type schedulePolicyRequest struct {
+	RetryMaxAttempts       *int      `json:"retry_max_attempts"`
+	RetryBackoffSeconds    *int      `json:"retry_backoff_seconds"`
+	RetryBackoffMultiplier *float64  `json:"retry_backoff_multiplier"`
+	RetryOn                *[]string `json:"retry_on"`
+	TimeoutSeconds         *int      `json:"timeout_seconds"`
+	ConcurrencyPolicy      *string   `json:"concurrency_policy"`
 }
 
+func (p schedulePolicyRequest) set() bool {
+	return p.RetryMaxAttempts != nil || p.RetryBackoffSeconds != nil || p.RetryBackoffMultiplier != nil ||
+		p.RetryOn != nil || p.TimeoutSeconds != nil || p.ConcurrencyPolicy != nil
+}
+
+func (p schedulePolicyRequest) apply(policy scheduler.Policy) scheduler.Policy {
+	if p.RetryMaxAttempts != nil {
+		policy.MaxAttempts = *p.RetryMaxAttempts
+	}
+	if p.RetryBackoffSeconds != nil {
+		policy.BackoffSeconds = *p.RetryBackoffSeconds
+	}
+	if p.RetryBackoffMultiplier != nil {
+		policy.BackoffMultiplier = *p.RetryBackoffMultiplier
+	}
+	if p.RetryOn != nil {
+		policy.RetryOn = *p.RetryOn
+	}
+	if p.TimeoutSeconds != nil {
+		policy.TimeoutSeconds = *p.TimeoutSeconds
+	}
+	if p.ConcurrencyPolicy != nil {
+		policy.Concurrency = *p.ConcurrencyPolicy
+	}
+	if policy.RetryOn == nil {
+		policy.RetryOn = []string{}
+	}
+	return policy
+}
+
+const schedulePolicyColumns = "retry_max_attempts, retry_backoff_seconds, retry_backoff_multiplier, retry_on, timeout_seconds, concurrency_policy"
+
+const scheduleColumns = `id, name, description, cron_expr, tool_id, action, payload, enabled,
+	type, agent_role_slug, prompt_content, thread_id, dashboard_id, widget_id,
+	workspace_id, provider, last_run_at, next_run_at, created_at, updated_at,
+	trigger_type, webhook_token, ` + schedulePolicyColumns
+
+func scanSchedule(sc rowScanner, s *models.Schedule) error {
+	var workspaceID sql.NullString
+	var webhookToken, retryOn string
+	if err := sc.Scan(&s.ID, &s.Name, &s.Description, &s.CronExpr, &s.ToolID, &s.Action, &s.Payload, &s.Enabled,
+		&s.Type, &s.AgentRoleSlug, &s.PromptContent, &s.ThreadID, &s.DashboardID, &s.WidgetID,
+		&workspaceID, &s.Provider, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt,
+		&s.TriggerType, &webhookToken,
+		&s.RetryMaxAttempts, &s.RetryBackoffSeconds, &s.RetryBackoffMultiplier, &retryOn, &s.TimeoutSeconds, &s.ConcurrencyPolicy); err != nil {
+		return err
+	}
+	if workspaceID.Valid && workspaceID.String != "" {
+		ws := workspaceID.String
+		s.WorkspaceID = &ws
+	}
+	if webhookToken != "" {
+		s.WebhookURL = webhookPath(webhookToken)
+	}
+	json.Unmarshal([]byte(retryOn), &s.RetryOn)
+	return nil
+}
+
+func setSchedulePolicy(s *models.Schedule, p scheduler.Policy) {
+	s.RetryMaxAttempts = p.MaxAttempts
+	s.RetryBackoffSeconds = p.BackoffSeconds
+	s.RetryBackoffMultiplier = p.BackoffMultiplier
+	s.RetryOn = p.RetryOn
+	s.TimeoutSeconds = p.TimeoutSeconds
+	s.ConcurrencyPolicy = p.Concurrency
+}
+
+func NewSchedulesHandler(db *database.DB, sched *scheduler.Scheduler, secretsMgr *secrets.Manager) *SchedulesHandler {
+	return &SchedulesHandler{db: db, scheduler: sched, secrets: secretsMgr}
+}
+
+// authorizeSchedule checks the caller's role in the workspace a schedule
+// targets, or their account role for a global schedule. Unknown schedules get
+// a 404.
+func (h *SchedulesHandler) authorizeSchedule(w http.ResponseWriter, r *http.Request, id, minRole string) bool {
+	var workspaceID sql.NullString
+	if err := h.db.QueryRow("SELECT workspace_id FROM schedules WHERE id = ?", id).Scan(&workspaceID); err != nil {
+		writeError(w, http.StatusNotFound, "schedule not found")
+		return false
+	}
+	return requireWorkspaceRole(w, r, h.db, workspaceID.String, minRole)
+}
+
+// List returns global schedules plus those targeting workspaces the caller
+// can see.
 func (h *SchedulesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	userID := middleware.GetUserID(r.Context())
 	rows, err := h.db.Query(
-		`SELECT id, name, description, cron_expr, tool_id, action, payload, enabled,
-		        type, agent_role_slug, prompt_content, thread_id, dashboard_id, widget_id,
-		        workspace_id, provider, last_run_at, next_run_at, created_at, updated_at
-		 FROM schedules ORDER BY created_at DESC`,
+		`SELECT `+scheduleColumns+`
+		 FROM schedules
+		 WHERE ? OR workspace_id IS NULL OR workspace_id = ''
+		    OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		 ORDER BY created_at DESC`,
+		h.db.UserRole(userID) == auth.RoleAdmin, userID,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list schedules")
@@ -54,17 +157,10 @@ func (h *SchedulesHandler) List(w http.ResponseWriter, r *http.Request) {
 	schedules := []models.Schedule{}
 	for rows.Next() {
 		var s models.Schedule
-		var workspaceID sql.NullString
-		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.CronExpr, &s.ToolID, &s.Action, &s.Payload, &s.Enabled,
-			&s.Type, &s.AgentRoleSlug, &s.PromptContent, &s.ThreadID, &s.DashboardID, &s.WidgetID,
-			&workspaceID, &s.Provider, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := scanSchedule(rows, &s); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to scan schedule")
 			return
 		}
-		if workspaceID.Valid && workspaceID.String != "" {
-			ws := workspaceID.String
-			s.WorkspaceID = &ws
-		}
 		schedules = append(schedules, s)
 	}
 	writeJSON(w, http.StatusOK, schedules)
@@ -80,19 +176,44 @@ func (h *SchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
 		ThreadID      string `json:"thread_id"`
 		WorkspaceID   string `json:"workspace_id"`
 		Provider      string `json:"provider"`
+		// TriggerType is "cron" (default) or "webhook". A webhook automation
+		// has no cron_expr; its prompt_content is a template over the payload.
+		TriggerType string `json:"trigger_type"`
+		schedulePolicyRequest
 	}
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	if req.Name == "" || req.CronExpr == "" {
-		writeError(w, http.StatusBadRequest, "name and cron_expr are required")
+	switch req.TriggerType {
+	case "", scheduler.TriggerCron:
+		req.TriggerType = scheduler.TriggerCron
+		if req.Name == "" || req.CronExpr == "" {
+			writeError(w, http.StatusBadRequest, "name and cron_expr are required")
+			return
+		}
+	case scheduler.TriggerWebhook:
+		if req.Name == "" {
+			writeError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		req.CronExpr = ""
+	default:
+		writeError(w, http.StatusBadRequest, "trigger_type must be cron or webhook")
 		return
 	}
 	if req.AgentRoleSlug == "" || req.PromptContent == "" {
 		writeError(w, http.StatusBadRequest, "agent_role_slug and prompt_content are required")
 		return
 	}
+	if !requireWorkspaceRole(w, r, h.db, req.WorkspaceID, auth.RoleEditor) {
+		return
+	}
+	policy := req.apply(scheduler.DefaultPolicy())
+	if err := policy.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	var agentExists string
 	if err := h.db.QueryRow("SELECT slug FROM agent_roles WHERE slug = ? AND enabled = 1", req.AgentRoleSlug).Scan(&agentExists); err != nil {
@@ -118,32 +239,47 @@ func (h *SchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
 		workspacePtr = &req.WorkspaceID
 	}
 
+	var webhookToken, webhookSecret, encryptedSecret string
+	if req.TriggerType == scheduler.TriggerWebhook {
+		var err error
+		if webhookToken, webhookSecret, encryptedSecret, err = h.newWebhookSecret(); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to create webhook secret")
+			return
+		}
+	}
+
+	retryOn, _ := json.Marshal(policy.RetryOn)
 	_, err := h.db.Exec(
 		`INSERT INTO schedules (id, name, description, cron_expr, tool_id, action, payload, enabled,
-		                        type, agent_role_slug, prompt_content, thread_id, workspace_id, provider, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, '', '', '{}', ?, 'prompt', ?, ?, ?, ?, ?, ?, ?)`,
+		                        type, agent_role_slug, prompt_content, thread_id, workspace_id, provider, created_at, updated_at,
+		                        trigger_type, webhook_token, webhook_secret, `+schedulePolicyColumns+`)
+		 VALUES (?, ?, ?, ?, '', '', '{}', ?, 'prompt', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, req.Name, req.Description, req.CronExpr, true,
 		req.AgentRoleSlug, req.PromptContent, req.ThreadID, workspacePtr, req.Provider, now, now,
+		req.TriggerType, webhookToken, encryptedSecret,
+		policy.MaxAttempts, policy.BackoffSeconds, policy.BackoffMultiplier, string(retryOn), policy.TimeoutSeconds, policy.Concurrency,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create schedule")
 		return
 	}
 
-	h.scheduler.AddSchedule(scheduler.ScheduleConfig{
-		ID:            id,
-		CronExpr:      req.CronExpr,
-		AgentRoleSlug: req.AgentRoleSlug,
-		PromptContent: req.PromptContent,
-		ThreadID:      req.ThreadID,
-		WorkspaceID:   req.WorkspaceID,
-		Provider:      req.Provider,
-	})
+	if req.TriggerType == scheduler.TriggerCron {
+		h.scheduler.AddSchedule(scheduler.ScheduleConfig{
+			ID:            id,
+			CronExpr:      req.CronExpr,
+			AgentRoleSlug: req.AgentRoleSlug,
+			PromptContent: req.PromptContent,
+			ThreadID:      req.ThreadID,
+			WorkspaceID:   req.WorkspaceID,
+			Provider:      req.Provider,
+		})
+	}
 
 	userID := middleware.GetUserID(r.Context())
 	h.db.LogAudit(userID, "schedule_created", "schedule", "schedule", id, req.Name)
 
-	writeJSON(w, http.StatusCreated, models.Schedule{
+	created := models.Schedule{
 		ID:            id,
 		Name:          req.Name,
 		Description:   req.Description,
@@ -157,14 +293,25 @@ func (h *SchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Enabled:       true,
 		CreatedAt:     now,
 		UpdatedAt:     now,
-	})
+		TriggerType:   req.TriggerType,
+		// The secret is shown once, here; after that only rotation reveals one.
+		WebhookSecret: webhookSecret,
+	}
+	if webhookToken != "" {
+		created.WebhookURL = webhookPath(webhookToken)
+	}
+	setSchedulePolicy(&created, policy)
+	writeJSON(w, http.StatusCreated, created)
 }
 
 func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleEditor) {
+		return
+	}
 
-	var exists string
-	err := h.db.QueryRow("SELECT id FROM schedules WHERE id = ?", id).Scan(&exists)
+	var triggerType string
+	err := h.db.QueryRow("SELECT trigger_type FROM schedules WHERE id = ?", id).Scan(&triggerType)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "schedule not found")
 		return
@@ -179,11 +326,16 @@ func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 		ThreadID      *string `json:"thread_id"`
 		WorkspaceID   *string `json:"workspace_id"`
 		Provider      *string `json:"provider"`
+		schedulePolicyRequest
 	}
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	// Retargeting a schedule needs editor rights on the destination as well.
+	if req.WorkspaceID != nil && !requireWorkspaceRole(w, r, h.db, *req.WorkspaceID, auth.RoleEditor) {
+		return
+	}
 
 	now := time.Now().UTC()
 	setClauses := []string{"updated_at = ?"}
@@ -198,6 +350,10 @@ func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 		args = append(args, *req.Description)
 	}
 	if req.CronExpr != nil {
+		if triggerType == scheduler.TriggerWebhook {
+			writeError(w, http.StatusBadRequest, "webhook automations have no cron_expr")
+			return
+		}
 		setClauses = append(setClauses, "cron_expr = ?")
 		args = append(args, *req.CronExpr)
 	}
@@ -217,6 +373,21 @@ func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 		setClauses = append(setClauses, "provider = ?")
 		args = append(args, *req.Provider)
 	}
+	// The policy is read by the scheduler at fire time, so saving it is all an
+	// edit needs — no cron reload.
+	if req.schedulePolicyRequest.set() {
+		policy := req.apply(scheduler.LoadPolicy(h.db, id))
+		if err := policy.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		retryOn, _ := json.Marshal(policy.RetryOn)
+		setClauses = append(setClauses,
+			"retry_max_attempts = ?", "retry_backoff_seconds = ?", "retry_backoff_multiplier = ?",
+			"retry_on = ?", "timeout_seconds = ?", "concurrency_policy = ?")
+		args = append(args, policy.MaxAttempts, policy.BackoffSeconds, policy.BackoffMultiplier,
+			string(retryOn), policy.TimeoutSeconds, policy.Concurrency)
+	}
 	if req.WorkspaceID != nil {
 		// Empty string clears the target (nullable = global).
 		if *req.WorkspaceID == "" {
@@ -240,8 +411,9 @@ func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// holds the agent, prompt and target thread — so editing the prompt saved to
 	// the database and then kept running the old prompt until the next restart,
 	// which looks exactly like the edit not saving at all.
-	if req.CronExpr != nil || req.WorkspaceID != nil || req.AgentRoleSlug != nil ||
-		req.PromptContent != nil || req.ThreadID != nil || req.Provider != nil {
+	// Webhook automations have no cron entry; the receiver reads the row fresh.
+	if triggerType == scheduler.TriggerCron && (req.CronExpr != nil || req.WorkspaceID != nil || req.AgentRoleSlug != nil ||
+		req.PromptContent != nil || req.ThreadID != nil || req.Provider != nil) {
 		var s models.Schedule
 		var workspaceID sql.NullString
 		h.db.QueryRow(
@@ -261,25 +433,16 @@ func (h *SchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	h.db.LogAudit(userID, "schedule_updated", "schedule", "schedule", id, "")
 
 	var s models.Schedule
-	var workspaceID sql.NullString
-	h.db.QueryRow(
-		`SELECT id, name, description, cron_expr, tool_id, action, payload, enabled,
-		        type, agent_role_slug, prompt_content, thread_id, dashboard_id, widget_id,
-		        workspace_id, provider, last_run_at, next_run_at, created_at, updated_at
-		 FROM schedules WHERE id = ?`, id,
-	).Scan(&s.ID, &s.Name, &s.Description, &s.CronExpr, &s.ToolID, &s.Action, &s.Payload, &s.Enabled,
-		&s.Type, &s.AgentRoleSlug, &s.PromptContent, &s.ThreadID, &s.DashboardID, &s.WidgetID,
-		&workspaceID, &s.Provider, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt)
-	if workspaceID.Valid && workspaceID.String != "" {
-		ws := workspaceID.String
-		s.WorkspaceID = &ws
-	}
+	scanSchedule(h.db.QueryRow("SELECT "+scheduleColumns+" FROM schedules WHERE id = ?", id), &s)
 
 	writeJSON(w, http.StatusOK, s)
 }
 
 func (h *SchedulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleEditor) {
+		return
+	}
 	result, err := h.db.Exec("DELETE FROM schedules WHERE id = ?", id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to delete schedule")
@@ -301,13 +464,16 @@ func (h *SchedulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 func (h *SchedulesHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleEditor) {
+		return
+	}
 
 	var s models.Schedule
 	var workspaceID sql.NullString
 	err := h.db.QueryRow(
-		`SELECT id, agent_role_slug, prompt_content, thread_id, workspace_id
+		`SELECT id, agent_role_slug, prompt_content, thread_id, workspace_id, provider, trigger_type
 		 FROM schedules WHERE id = ?`, id,
-	).Scan(&s.ID, &s.AgentRoleSlug, &s.PromptContent, &s.ThreadID, &workspaceID)
+	).Scan(&s.ID, &s.AgentRoleSlug, &s.PromptContent, &s.ThreadID, &workspaceID, &s.Provider, &s.TriggerType)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "schedule not found")
 		return
@@ -316,6 +482,11 @@ func (h *SchedulesHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 		ws := workspaceID.String
 		s.WorkspaceID = &ws
 	}
+	// A webhook template run by hand has no delivery to read from, so its
+	// placeholders render empty.
+	if s.TriggerType == scheduler.TriggerWebhook {
+		s.PromptContent = scheduler.RenderPrompt(s.PromptContent, []byte("{}"), http.Header{})
+	}
 
 	h.scheduler.RunNow(scheduleToConfig(id, s))
 
@@ -330,9 +501,13 @@ func (h *SchedulesHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 
 func (h *SchedulesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleEditor) {
+		return
+	}
 
 	var enabled bool
-	err := h.db.QueryRow("SELECT enabled FROM schedules WHERE id = ?", id).Scan(&enabled)
+	var triggerType string
+	err := h.db.QueryRow("SELECT enabled, trigger_type FROM schedules WHERE id = ?", id).Scan(&enabled, &triggerType)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "schedule not found")
 		return
@@ -345,7 +520,11 @@ func (h *SchedulesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if newEnabled {
+	switch {
+	case triggerType != scheduler.TriggerCron:
+		// Only cron schedules have a live entry; a paused webhook automation
+		// is refused by the receiver instead.
+	case newEnabled:
 		var s models.Schedule
 		var workspaceID sql.NullString
 		h.db.QueryRow(
@@ -356,7 +535,7 @@ func (h *SchedulesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 			s.WorkspaceID = &ws
 		}
 		h.scheduler.AddSchedule(scheduleToConfig(id, s))
-	} else {
+	default:
 		h.scheduler.RemoveSchedule(id)
 	}
 
@@ -375,10 +554,13 @@ func (h *SchedulesHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 
 func (h *SchedulesHandler) Executions(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeSchedule(w, r, id, auth.RoleViewer) {
+		return
+	}
 
 	rows, err := h.db.Query(
-		`SELECT id, schedule_id, status, output, error, started_at, finished_at
-		 FROM schedule_executions WHERE schedule_id = ? ORDER BY started_at DESC LIMIT 50`, id,
+		`SELECT id, schedule_id, status, output, error, attempt, triggered_by, started_at, finished_at
+		 FROM schedule_executions WHERE schedule_id = ? ORDER BY started_at DESC, attempt DESC LIMIT 50`, id,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list executions")
@@ -389,7 +571,7 @@ func (h *SchedulesHandler) Executions(w http.ResponseWriter, r *http.Request) {
 	executions := []models.ScheduleExecution{}
 	for rows.Next() {
 		var e models.ScheduleExecution
-		if err := rows.Scan(&e.ID, &e.ScheduleID, &e.Status, &e.Output, &e.Error, &e.StartedAt, &e.FinishedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.ScheduleID, &e.Status, &e.Output, &e.Error, &e.Attempt, &e.TriggeredBy, &e.StartedAt, &e.FinishedAt); err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to scan execution")
 			return
 		}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
@@ -37,6 +38,9 @@ func (h *SecretsHandler) restartToolsUsing(secretName string) {
 }
 
 func (h *SecretsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
 	rows, err := h.db.Query(
 		`SELECT s.id, s.name, s.description, s.tool_id, COALESCE(t.name, ''), s.created_at, s.updated_at
 		 FROM secrets s
@@ -72,6 +76,9 @@ func (h *SecretsHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Name        string `json:"name"`
 		Value       string `json:"value"`
@@ -122,6 +129,9 @@ func (h *SecretsHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	result, err := h.db.Exec("DELETE FROM secrets WHERE id = ?", id)
 	if err != nil {
@@ -141,6 +151,9 @@ func (h *SecretsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	var secretName string
@@ -184,6 +197,9 @@ func (h *SecretsHandler) Rotate(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) CheckNames(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
 	var req struct {
 		Names []string `json:"names"`
 	}
@@ -220,6 +236,9 @@ func (h *SecretsHandler) CheckNames(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) EnsurePlaceholders(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	var req struct {
 		Secrets []struct {
 			Name        string `json:"name"`
@@ -269,8 +288,12 @@ func (h *SecretsHandler) EnsurePlaceholders(w http.ResponseWriter, r *http.Reque
 // Reveal returns a secret's decrypted value so the UI can copy it to the
 // clipboard. Kept as its own endpoint rather than a field on List so a value
 // only ever leaves the server when someone explicitly asks for that one secret,
-// and so the request can be audit-logged.
+// and so the request can be audit-logged. Only admins may reveal; editors can
+// still set and rotate values they never get to read back.
 func (h *SecretsHandler) Reveal(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	var name, encrypted string
@@ -294,6 +317,9 @@ func (h *SecretsHandler) Reveal(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecretsHandler) Test(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 
 	var encrypted string
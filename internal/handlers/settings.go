@@ -14,6 +14,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -51,7 +52,7 @@ func (h *SettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		// Don't expose encrypted API keys in general settings
-		if key == "openrouter_api_key" {
+		if key == "openrouter_api_key" || key == compatAPIKeyKey || key == embeddingAPIKeyKey {
 			continue
 		}
 		settings[key] = value
@@ -60,6 +61,9 @@ func (h *SettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req map[string]string
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -68,7 +72,7 @@ func (h *SettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 	for key, value := range req {
 		// Don't allow setting API keys through general settings
-		if key == "openrouter_api_key" {
+		if key == "openrouter_api_key" || key == compatAPIKeyKey || key == embeddingAPIKeyKey {
 			continue
 		}
 		h.upsertSetting(key, value)
@@ -114,6 +118,9 @@ func (h *SettingsHandler) GetDesign(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SettingsHandler) UpdateDesign(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var raw json.RawMessage
 	if err := decodeJSON(r, &raw); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -160,6 +167,9 @@ func (h *SettingsHandler) GetModels(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SettingsHandler) UpdateModels(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		GatewayModel         string `json:"gateway_model"`
 		BuilderModel         string `json:"builder_model"`
@@ -293,6 +303,9 @@ func (h *SettingsHandler) GetMediaKeys(w http.ResponseWriter, r *http.Request) {
 // "check this token" endpoint, and burning a paid prediction to test a key
 // would be a surprising charge.
 func (h *SettingsHandler) UpdateMediaKey(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	provider := chi.URLParam(r, "provider")
 	keys, ok := mediaProviderKeys[provider]
 	if !ok {
@@ -330,6 +343,9 @@ func (h *SettingsHandler) UpdateMediaKey(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *SettingsHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		APIKey string `json:"api_key"`
 	}
@@ -446,6 +462,9 @@ func (h *SettingsHandler) GetLLMProvider(w http.ResponseWriter, r *http.Request)
 // UpdateLLMProvider switches the active LLM provider. OpenRouter is always a
 // valid target; CLI providers must be installed (and logged in) first.
 func (h *SettingsHandler) UpdateLLMProvider(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Provider string `json:"provider"`
 	}
@@ -463,6 +482,10 @@ func (h *SettingsHandler) UpdateLLMProvider(w http.ResponseWriter, r *http.Reque
 		writeError(w, http.StatusBadRequest, "unknown provider: "+req.Provider)
 		return
 	}
+	if req.Provider == llm.ProviderOpenAICompat && !p.IsConfigured() {
+		writeError(w, http.StatusBadRequest, "set the OpenAI-compatible server URL first")
+		return
+	}
 	if req.Provider != llm.ProviderOpenRouter && !p.IsConfigured() {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s CLI is not available — install it and log in first", req.Provider))
 		return
@@ -500,6 +523,9 @@ func (h *SettingsHandler) GetOpenClaw(w http.ResponseWriter, r *http.Request) {
 
 // SyncOpenClaw imports/updates OpenClaw agents as remote chat agents.
 func (h *SettingsHandler) SyncOpenClaw(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
 	defer cancel()
 
@@ -517,6 +543,9 @@ func (h *SettingsHandler) SyncOpenClaw(w http.ResponseWriter, r *http.Request) {
 
 // RemoveOpenClaw deletes all imported OpenClaw agents.
 func (h *SettingsHandler) RemoveOpenClaw(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	removed, err := h.agentMgr.RemoveOpenClawAgents()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to remove OpenClaw agents: "+err.Error())
@@ -579,6 +608,9 @@ func (h *SettingsHandler) ServeOpenClawFile(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *SettingsHandler) UploadBackground(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	r.ParseMultipartForm(5 << 20)
 
 	file, header, err := r.FormFile("background")
@@ -634,6 +666,9 @@ func (h *SettingsHandler) UploadBackground(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *SettingsHandler) DeleteBackground(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	bgDir := filepath.Join(h.dataDir, "backgrounds")
 	entries, err := os.ReadDir(bgDir)
 	if err == nil {
@@ -673,6 +708,9 @@ func (h *SettingsHandler) GetGeneral(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SettingsHandler) UpdateGeneral(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		BindAddress string `json:"bind_address"`
 		Port        int    `json:"port"`
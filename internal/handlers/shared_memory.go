@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/memory"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/sharedmemory"
+)
+
+// SharedMemoryHandler manages the shared memory scopes agents have in common:
+// the scopes, who may read or write each, and the memories in them.
+type SharedMemoryHandler struct {
+	db       *database.DB
+	store    *sharedmemory.Store
+	memories *MemoryHandler
+}
+
+func NewSharedMemoryHandler(db *database.DB, store *sharedmemory.Store, memoryMgr *memory.Manager) *SharedMemoryHandler {
+	return &SharedMemoryHandler{db: db, store: store, memories: NewMemoryHandler(memoryMgr)}
+}
+
+// scope loads the scope named in the URL, writing the error response when it
+// can't.
+func (h *SharedMemoryHandler) scope(w http.ResponseWriter, r *http.Request) (*sharedmemory.Scope, bool) {
+	sc, err := h.store.Get(chi.URLParam(r, "id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "shared memory scope not found")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load shared memory scope")
+		return nil, false
+	}
+	return sc, true
+}
+
+func (h *SharedMemoryHandler) writeStoreError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, sharedmemory.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "shared memory scope not found")
+	default:
+		writeError(w, http.StatusInternalServerError, "failed to "+action+" shared memory scope")
+	}
+}
+
+func (h *SharedMemoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	scopes, err := h.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list shared memory scopes")
+		return
+	}
+	writeJSON(w, http.StatusOK, scopes)
+}
+
+func (h *SharedMemoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	if sc, ok := h.scope(w, r); ok {
+		writeJSON(w, http.StatusOK, sc)
+	}
+}
+
+func (h *SharedMemoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req sharedmemory.Input
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	sc, err := h.store.Create(req)
+	if err != nil {
+		h.writeStoreError(w, err, "create")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "shared_memory_created", "memory", "shared_memory_scope", sc.ID, sc.Name)
+
+	writeJSON(w, http.StatusCreated, sc)
+}
+
+func (h *SharedMemoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req sharedmemory.Input
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	sc, err := h.store.Update(chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeStoreError(w, err, "update")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "shared_memory_updated", "memory", "shared_memory_scope", sc.ID, sc.Name)
+
+	writeJSON(w, http.StatusOK, sc)
+}
+
+// Delete removes a scope and every memory in it.
+func (h *SharedMemoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	sc, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(sc.ID); err != nil {
+		h.writeStoreError(w, err, "delete")
+		return
+	}
+	if err := h.memories.memoryMgr.DropShared(sc.ID); err != nil {
+		logger.Warn("Could not remove the memory of shared scope %s: %v", sc.Name, err)
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "shared_memory_deleted", "memory", "shared_memory_scope", sc.ID, sc.Name)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// SetAccess replaces the per-agent grants that override a scope's default
+// access.
+func (h *SharedMemoryHandler) SetAccess(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req struct {
+		Access []sharedmemory.Grant `json:"access"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	sc, err := h.store.SetAccess(chi.URLParam(r, "id"), req.Access)
+	if err != nil {
+		h.writeStoreError(w, err, "update access to")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "shared_memory_access_updated", "memory", "shared_memory_scope", sc.ID, sc.Name)
+
+	writeJSON(w, http.StatusOK, sc)
+}
+
+func (h *SharedMemoryHandler) ListMemories(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	if sc, ok := h.scope(w, r); ok {
+		h.memories.listMemoriesForSlug(w, r, memory.SharedKey(sc.ID))
+	}
+}
+
+func (h *SharedMemoryHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleViewer) {
+		return
+	}
+	if sc, ok := h.scope(w, r); ok {
+		h.memories.statsForSlug(w, r, memory.SharedKey(sc.ID))
+	}
+}
+
+func (h *SharedMemoryHandler) DeleteMemory(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
+	sc, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	key, memoryID := memory.SharedKey(sc.ID), chi.URLParam(r, "memoryId")
+	if _, err := h.memories.memoryMgr.Get(key, memoryID); errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Memory not found")
+		return
+	}
+	if err := h.memories.memoryMgr.Forget(key, memoryID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Delete failed: "+err.Error())
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	h.db.LogAudit(userID, "shared_memory_forgotten", "memory", "shared_memory_scope", sc.ID, memoryID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": true})
+}
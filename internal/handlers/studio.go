@@ -157,7 +157,7 @@ func (h *StudioHandler) Generate(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := contextWithTimeout(r, 15*time.Minute)
 	defer cancel()
 
-	workspaceID := activeWorkspaceID(h.db)
+	workspaceID := requestWorkspaceID(r, h.db)
 
 	items := []*media.Record{}
 	errs := []string{}
@@ -221,7 +221,7 @@ func (h *StudioHandler) Generate(w http.ResponseWriter, r *http.Request) {
 // --- Folders ---
 
 func (h *StudioHandler) ListFolders(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]interface{}{"folders": h.folders()})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"folders": h.folders(requestWorkspaceID(r, h.db))})
 }
 
 type folderRow struct {
@@ -231,8 +231,7 @@ type folderRow struct {
 	CreatedAt string `json:"created_at"`
 }
 
-func (h *StudioHandler) folders() []folderRow {
-	workspaceID := activeWorkspaceID(h.db)
+func (h *StudioHandler) folders(workspaceID string) []folderRow {
 	rows, err := h.db.Query(
 		`SELECT f.id, f.name, f.created_at, (SELECT COUNT(*) FROM media m WHERE m.folder_id = f.id)
 		 FROM media_folders f WHERE f.workspace_id = ? ORDER BY f.name ASC`,
@@ -274,7 +273,7 @@ func (h *StudioHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 	id := uuid.New().String()
 	if _, err := h.db.Exec(
 		"INSERT INTO media_folders (id, workspace_id, name, created_at) VALUES (?, ?, ?, ?)",
-		id, activeWorkspaceID(h.db), name, time.Now().UTC(),
+		id, requestWorkspaceID(r, h.db), name, time.Now().UTC(),
 	); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create folder")
 		return
@@ -408,7 +407,7 @@ func (h *StudioHandler) ListMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	where := []string{"(workspace_id = ? OR workspace_id = '')"}
-	args := []interface{}{activeWorkspaceID(h.db)}
+	args := []interface{}{requestWorkspaceID(r, h.db)}
 
 	// "unfiled" is a real filter, distinct from "no folder filter at all".
 	if folder := q.Get("folder_id"); folder != "" {
@@ -480,7 +479,7 @@ func (h *StudioHandler) ListPresets(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(
 		`SELECT id, name, provider, media_type, model, prompt, count, size, folder_id, params, created_at, updated_at
 		 FROM studio_presets WHERE workspace_id = ? ORDER BY updated_at DESC LIMIT 200`,
-		activeWorkspaceID(h.db),
+		requestWorkspaceID(r, h.db),
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list presets")
@@ -566,7 +565,7 @@ func (h *StudioHandler) SavePreset(w http.ResponseWriter, r *http.Request) {
 		`INSERT INTO studio_presets (id, workspace_id, name, provider, media_type, model, prompt,
 		                             count, size, folder_id, params, created_at, updated_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		id, activeWorkspaceID(h.db), name, req.Provider, req.MediaType, req.Model, req.Prompt,
+		id, requestWorkspaceID(r, h.db), name, req.Provider, req.MediaType, req.Model, req.Prompt,
 		req.Count, req.Size, req.FolderID, params, now, now,
 	); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to save preset")
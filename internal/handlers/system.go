@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -105,6 +106,9 @@ func (h *SystemHandler) Info(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SystemHandler) DeleteData(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	userID := middleware.GetUserID(r.Context())
 
 	// Archive live counters before deletion
@@ -77,6 +77,9 @@ func toWorkbenchResponse(wb *terminal.Workbench) workbenchResponse {
 
 // ListSessions returns active terminal sessions, optionally filtered by workbench_id.
 func (h *TerminalHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	workbenchID := r.URL.Query().Get("workbench_id")
 	sessions := h.terminalMgr.ListSessions(workbenchID)
 
@@ -92,6 +95,9 @@ func (h *TerminalHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 // workspace each belongs to, so the UI can offer a jump-back list that works
 // from any screen and any workspace.
 func (h *TerminalHandler) ActiveSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	type activeSession struct {
 		SessionID   string `json:"session_id"`
 		Title       string `json:"title"`
@@ -128,6 +134,9 @@ func (h *TerminalHandler) ActiveSessions(w http.ResponseWriter, r *http.Request)
 
 // CreateSession creates a new terminal/PTY session.
 func (h *TerminalHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Title          string `json:"title"`
 		Cols           *int   `json:"cols"`
@@ -166,6 +175,9 @@ func (h *TerminalHandler) CreateSession(w http.ResponseWriter, r *http.Request)
 
 // GetSession returns a single terminal session by ID.
 func (h *TerminalHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	session := h.terminalMgr.GetSession(id)
 	if session == nil {
@@ -178,6 +190,9 @@ func (h *TerminalHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 
 // UpdateSession updates the title and color of a terminal session.
 func (h *TerminalHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	session := h.terminalMgr.GetSession(id)
 	if session == nil {
@@ -205,6 +220,9 @@ func (h *TerminalHandler) UpdateSession(w http.ResponseWriter, r *http.Request)
 
 // DeleteSession destroys a terminal session and kills its PTY process.
 func (h *TerminalHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if err := h.terminalMgr.DestroySession(id); err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
@@ -216,6 +234,9 @@ func (h *TerminalHandler) DeleteSession(w http.ResponseWriter, r *http.Request)
 
 // ListWorkbenches returns all workbenches.
 func (h *TerminalHandler) ListWorkbenches(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	workbenches, err := h.terminalMgr.ListWorkbenches()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -230,6 +251,9 @@ func (h *TerminalHandler) ListWorkbenches(w http.ResponseWriter, r *http.Request
 
 // CreateWorkbench creates a new workbench.
 func (h *TerminalHandler) CreateWorkbench(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Name string `json:"name"`
 	}
@@ -250,6 +274,9 @@ func (h *TerminalHandler) CreateWorkbench(w http.ResponseWriter, r *http.Request
 
 // UpdateWorkbench updates the name and color of a workbench.
 func (h *TerminalHandler) UpdateWorkbench(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	var req struct {
 		Name  string `json:"name"`
@@ -272,6 +299,9 @@ func (h *TerminalHandler) UpdateWorkbench(w http.ResponseWriter, r *http.Request
 
 // ReorderWorkbenches updates the sort_order of workbenches.
 func (h *TerminalHandler) ReorderWorkbenches(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		IDs []string `json:"ids"`
 	}
@@ -292,6 +322,9 @@ func (h *TerminalHandler) ReorderWorkbenches(w http.ResponseWriter, r *http.Requ
 
 // DeleteWorkbench destroys all sessions in the workbench and removes it.
 func (h *TerminalHandler) DeleteWorkbench(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if err := h.terminalMgr.DeleteWorkbench(id); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -328,10 +361,17 @@ func (h *TerminalHandler) HandleWS(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if _, err := h.auth.ValidateToken(tokenStr); err != nil {
+	claims, err := h.auth.ValidateToken(tokenStr)
+	if err != nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	// A terminal is a shell on the host, so it is admin-only like the REST
+	// routes that create one.
+	if !auth.RoleAtLeast(h.db.UserRole(claims.UserID), auth.RoleAdmin) {
+		http.Error(w, "admin role required", http.StatusForbidden)
+		return
+	}
 
 	// Look up the session
 	session := h.terminalMgr.GetSession(sessionID)
@@ -467,6 +507,9 @@ func (h *TerminalHandler) HandleWS(w http.ResponseWriter, r *http.Request) {
 // UploadFile accepts a file upload from terminal drag-and-drop or paste,
 // saves it to the terminal-uploads directory, and returns the absolute path.
 func (h *TerminalHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	r.Body = http.MaxBytesReader(w, r.Body, 50<<20) // 50MB limit
 
 	if err := r.ParseMultipartForm(50 << 20); err != nil {
@@ -523,6 +566,9 @@ func (h *TerminalHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 // that supplies only a basename is not enough authority to search unrelated
 // app data.
 func (h *TerminalHandler) ResolvePath(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Name  string `json:"name"`
 		IsDir bool   `json:"is_dir"`
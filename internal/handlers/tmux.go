@@ -9,7 +9,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
@@ -35,6 +35,9 @@ func watchKey(threadID, session string) string { return threadID + "\x00" + sess
 
 // ListTmuxSessions returns every running tmux session with its parsed state.
 func (h *ChatHandler) ListTmuxSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	sessions, err := tmux.List(r.Context())
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list tmux sessions")
@@ -70,6 +73,9 @@ func (h *ChatHandler) ListTmuxSessions(w http.ResponseWriter, r *http.Request) {
 // "finished" on its next tick, which reads as a build completing rather than a
 // session the user closed.
 func (h *ChatHandler) KillTmuxSession(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	session := r.URL.Query().Get("session")
 	if session == "" {
 		writeError(w, http.StatusBadRequest, "session is required")
@@ -109,6 +115,9 @@ func (h *ChatHandler) stopWatchesForSession(session string) int {
 // tmux_send tool uses, so a user watching a session stop on a prompt can answer
 // it from the card in chat instead of finding a terminal to attach from.
 func (h *ChatHandler) SendTmuxInput(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Session string `json:"session"`
 		Text    string `json:"text"`
@@ -137,6 +146,9 @@ func (h *ChatHandler) SendTmuxInput(w http.ResponseWriter, r *http.Request) {
 
 // GetTmuxLogs returns a session's scrollback.
 func (h *ChatHandler) GetTmuxLogs(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	session := r.URL.Query().Get("session")
 	if session == "" {
 		writeError(w, http.StatusBadRequest, "session is required")
@@ -160,7 +172,10 @@ func (h *ChatHandler) GetTmuxLogs(w http.ResponseWriter, r *http.Request) {
 // runs server-side and stops on its own when the session exits, so nothing is
 // left running after the thing it was watching is gone.
 func (h *ChatHandler) StartTmuxWatch(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 
 	var req struct {
 		Session   string `json:"session"`
@@ -263,7 +278,10 @@ func (h *ChatHandler) StopWatch(threadID, session string) int {
 
 // StopTmuxWatch cancels a running watch.
 func (h *ChatHandler) StopTmuxWatch(w http.ResponseWriter, r *http.Request) {
-	threadID := chi.URLParam(r, "id")
+	threadID, ok := h.requireThread(w, r, auth.RoleEditor)
+	if !ok {
+		return
+	}
 	session := r.URL.Query().Get("session")
 
 	writeJSON(w, http.StatusOK, map[string]int{"stopped": h.StopWatch(threadID, session)})
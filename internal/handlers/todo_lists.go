@@ -31,7 +31,7 @@ func (h *TodoListsHandler) ListLists(w http.ResponseWriter, r *http.Request) {
 			(SELECT COUNT(*) FROM todo_items WHERE list_id = tl.id) as total_items,
 			(SELECT COUNT(*) FROM todo_items WHERE list_id = tl.id AND completed = 1) as completed_items
 		FROM todo_lists tl WHERE tl.workspace_id = ? ORDER BY tl.sort_order ASC, tl.created_at ASC`,
-		activeWorkspaceID(h.db))
+		requestWorkspaceID(r, h.db))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list todo lists")
 		return
@@ -71,7 +71,7 @@ func (h *TodoListsHandler) Summary(w http.ResponseWriter, r *http.Request) {
 			(SELECT COUNT(*) FROM todo_items WHERE list_id = tl.id) as total_items,
 			(SELECT COUNT(*) FROM todo_items WHERE list_id = tl.id AND completed = 1) as completed_items
 		FROM todo_lists tl WHERE tl.workspace_id = ? ORDER BY tl.sort_order ASC, tl.created_at ASC`,
-		activeWorkspaceID(h.db))
+		requestWorkspaceID(r, h.db))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to get todo list summary")
 		return
@@ -121,7 +121,7 @@ func (h *TodoListsHandler) CreateList(w http.ResponseWriter, r *http.Request) {
 
 	_, err := h.db.Exec(
 		"INSERT INTO todo_lists (id, name, description, color, sort_order, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		id, req.Name, req.Description, req.Color, maxOrder+1, activeWorkspaceID(h.db), now, now,
+		id, req.Name, req.Description, req.Color, maxOrder+1, requestWorkspaceID(r, h.db), now, now,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create todo list")
@@ -16,6 +16,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/middleware"
 	"github.com/openpaw/openpaw/internal/models"
@@ -97,6 +98,9 @@ func (h *ToolLibraryHandler) GetCatalogTool(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *ToolLibraryHandler) InstallCatalogTool(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	slug := chi.URLParam(r, "slug")
 
 	if installed, _ := toollibrary.IsInstalled(h.db, slug); installed {
@@ -243,6 +247,9 @@ func (h *ToolLibraryHandler) ExportTool(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *ToolLibraryHandler) ImportTool(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	r.Body = http.MaxBytesReader(w, r.Body, 50<<20) // 50MB max
 
 	file, _, err := r.FormFile("file")
@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
@@ -32,7 +33,22 @@ func NewToolsHandler(db *database.DB, agentManager *agents.Manager, toolMgr *too
 	return &ToolsHandler{db: db, agentManager: agentManager, toolMgr: toolMgr, toolsDir: toolsDir}
 }
 
+// authorizeTool checks the caller's role in the workspace a tool is scoped to,
+// or their account role for a tool shared by all workspaces. Unknown tools get
+// a 404.
+func (h *ToolsHandler) authorizeTool(w http.ResponseWriter, r *http.Request, id, minRole string) bool {
+	var workspaceID sql.NullString
+	if err := h.db.QueryRow("SELECT workspace_id FROM tools WHERE id = ? AND deleted_at IS NULL", id).Scan(&workspaceID); err != nil {
+		writeError(w, http.StatusNotFound, "service not found")
+		return false
+	}
+	return requireWorkspaceRole(w, r, h.db, workspaceID.String, minRole)
+}
+
 func (h *ToolsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireWorkspaceRole(w, r, h.db, h.db.ActiveWorkspaceID(), auth.RoleViewer) {
+		return
+	}
 	rows, err := h.db.Query(
 		"SELECT id, name, description, type, config, enabled, status, port, pid, capabilities, owner_agent_slug, library_slug, library_version, source_hash, binary_hash, folder, workspace_id, created_at, updated_at FROM tools WHERE deleted_at IS NULL AND (workspace_id IS NULL OR workspace_id = ?) ORDER BY created_at DESC",
 		h.db.ActiveWorkspaceID(),
@@ -87,6 +103,13 @@ func (h *ToolsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if req.WorkspaceID != nil && *req.WorkspaceID == "" {
 		req.WorkspaceID = nil
 	}
+	targetWorkspace := ""
+	if req.WorkspaceID != nil {
+		targetWorkspace = *req.WorkspaceID
+	}
+	if !requireWorkspaceRole(w, r, h.db, targetWorkspace, auth.RoleEditor) {
+		return
+	}
 
 	id := generateID()
 	now := time.Now().UTC()
@@ -152,6 +175,9 @@ func (h *ToolsHandler) spawnToolBuilder(toolID, name, description, config, userI
 
 func (h *ToolsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleViewer) {
+		return
+	}
 	var t models.Tool
 	var workspaceID sql.NullString
 	err := h.db.QueryRow(
@@ -184,6 +210,9 @@ func (h *ToolsHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 
 	var existing models.Tool
 	err := h.db.QueryRow(
@@ -206,6 +235,10 @@ func (h *ToolsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	// Moving a tool needs editor rights on the destination as well.
+	if req.WorkspaceID != nil && !requireWorkspaceRole(w, r, h.db, *req.WorkspaceID, auth.RoleEditor) {
+		return
+	}
 
 	now := time.Now().UTC()
 	if req.Name != nil {
@@ -250,6 +283,9 @@ func (h *ToolsHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	now := time.Now().UTC()
 	result, err := h.db.Exec("UPDATE tools SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL", now, now, id)
 	if err != nil {
@@ -270,6 +306,9 @@ func (h *ToolsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Call(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 
 	var t models.Tool
 	err := h.db.QueryRow(
@@ -354,6 +393,9 @@ func (h *ToolsHandler) Call(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Enable(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	now := time.Now().UTC()
 	result, err := h.db.Exec("UPDATE tools SET enabled = 1, updated_at = ? WHERE id = ? AND deleted_at IS NULL", now, id)
 	if err != nil {
@@ -372,6 +414,9 @@ func (h *ToolsHandler) Enable(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Disable(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	now := time.Now().UTC()
 	result, err := h.db.Exec("UPDATE tools SET enabled = 0, updated_at = ? WHERE id = ? AND deleted_at IS NULL", now, id)
 	if err != nil {
@@ -390,6 +435,9 @@ func (h *ToolsHandler) Disable(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Compile(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -413,6 +461,9 @@ func (h *ToolsHandler) Compile(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Start(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -436,6 +487,9 @@ func (h *ToolsHandler) Start(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -453,6 +507,9 @@ func (h *ToolsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Restart(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleEditor) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -476,6 +533,9 @@ func (h *ToolsHandler) Restart(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Status(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleViewer) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -485,6 +545,9 @@ func (h *ToolsHandler) Status(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) Proxy(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleViewer) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -511,6 +574,9 @@ func (h *ToolsHandler) Proxy(w http.ResponseWriter, r *http.Request) {
 
 func (h *ToolsHandler) WidgetJS(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !h.authorizeTool(w, r, id, auth.RoleViewer) {
+		return
+	}
 	if h.toolMgr == nil {
 		writeError(w, http.StatusServiceUnavailable, "service manager not available")
 		return
@@ -0,0 +1,556 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// UsersHandler manages accounts, invitations and workspace membership. Every
+// route except the two invitation-accepting ones is admin-only.
+type UsersHandler struct {
+	db   *database.DB
+	auth *auth.Service
+}
+
+func NewUsersHandler(db *database.DB, authService *auth.Service) *UsersHandler {
+	return &UsersHandler{db: db, auth: authService}
+}
+
+type workspaceMembership struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceName string `json:"workspace_name"`
+	Role          string `json:"role"`
+}
+
+type userEntry struct {
+	models.User
+	Workspaces []workspaceMembership `json:"workspaces"`
+}
+
+type invitationEntry struct {
+	ID           string     `json:"id"`
+	Role         string     `json:"role"`
+	WorkspaceIDs []string   `json:"workspace_ids"`
+	Note         string     `json:"note"`
+	InvitedBy    string     `json:"invited_by"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	AcceptedBy   string     `json:"accepted_user_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+const defaultInvitationDays = 7
+
+func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	rows, err := h.db.Query(
+		"SELECT id, username, display_name, avatar_path, role, created_at, updated_at FROM users ORDER BY created_at ASC",
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	users := []userEntry{}
+	index := map[string]int{}
+	for rows.Next() {
+		var u userEntry
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarPath, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "failed to scan user")
+			return
+		}
+		u.Workspaces = []workspaceMembership{}
+		index[u.ID] = len(users)
+		users = append(users, u)
+	}
+	rows.Close()
+
+	members, err := h.db.Query(
+		`SELECT m.user_id, m.workspace_id, w.name, m.role
+		 FROM workspace_members m JOIN workspaces w ON w.id = m.workspace_id
+		 ORDER BY w.sort_order ASC, w.created_at ASC`,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list memberships")
+		return
+	}
+	defer members.Close()
+	for members.Next() {
+		var userID string
+		var m workspaceMembership
+		if err := members.Scan(&userID, &m.WorkspaceID, &m.WorkspaceName, &m.Role); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to scan membership")
+			return
+		}
+		if i, ok := index[userID]; ok {
+			users[i].Workspaces = append(users[i].Workspaces, m)
+		}
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// UpdateRole changes a user's account role. The last admin can't be demoted,
+// so the install always has someone who can manage it.
+func (h *UsersHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "role must be admin, editor or viewer")
+		return
+	}
+
+	current := h.db.UserRole(id)
+	if current == "" {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if current == auth.RoleAdmin && req.Role != auth.RoleAdmin && h.db.CountAdmins() <= 1 {
+		writeError(w, http.StatusConflict, "cannot demote the last admin")
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", req.Role, time.Now().UTC(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update role")
+		return
+	}
+
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "user_role_changed", "auth", "user", id, current+" -> "+req.Role)
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "role": req.Role})
+}
+
+// Delete removes another user's account. Their memberships and API tokens go
+// with it; what they created stays.
+func (h *UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	userID := middleware.GetUserID(r.Context())
+	if id == userID {
+		writeError(w, http.StatusBadRequest, "you cannot remove your own account")
+		return
+	}
+
+	var username, role string
+	if err := h.db.QueryRow("SELECT username, role FROM users WHERE id = ?", id).Scan(&username, &role); err != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if role == auth.RoleAdmin && h.db.CountAdmins() <= 1 {
+		writeError(w, http.StatusConflict, "cannot remove the last admin")
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove user")
+		return
+	}
+
+	h.db.LogAudit(userID, "user_removed", "auth", "user", id, username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UsersHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	rows, err := h.db.Query(
+		`SELECT id, role, workspace_ids, note, invited_by, expires_at, accepted_at, accepted_user_id, created_at
+		 FROM user_invitations ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list invitations")
+		return
+	}
+	defer rows.Close()
+
+	list := []invitationEntry{}
+	for rows.Next() {
+		var inv invitationEntry
+		var workspaceIDs string
+		var acceptedAt sql.NullTime
+		if err := rows.Scan(&inv.ID, &inv.Role, &workspaceIDs, &inv.Note, &inv.InvitedBy, &inv.ExpiresAt, &acceptedAt, &inv.AcceptedBy, &inv.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to scan invitation")
+			return
+		}
+		json.Unmarshal([]byte(workspaceIDs), &inv.WorkspaceIDs)
+		inv.AcceptedAt = nullTimePtr(acceptedAt)
+		list = append(list, inv)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// CreateInvitation issues a single-use invitation. The token is in this
+// response and nowhere else; the admin passes the link on themselves.
+func (h *UsersHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	var req struct {
+		Role          string   `json:"role"`
+		WorkspaceIDs  []string `json:"workspace_ids"`
+		Note          string   `json:"note"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleViewer
+	}
+	if !auth.ValidRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "role must be admin, editor or viewer")
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultInvitationDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > 90 {
+		writeError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 90")
+		return
+	}
+	// Non-admins only see workspaces they belong to, so an invitation with no
+	// workspaces joins the Default one rather than leaving the account empty.
+	if len(req.WorkspaceIDs) == 0 && req.Role != auth.RoleAdmin {
+		req.WorkspaceIDs = []string{DefaultWorkspaceID}
+	}
+	for _, wsID := range req.WorkspaceIDs {
+		var exists int
+		if err := h.db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", wsID).Scan(&exists); err != nil {
+			writeError(w, http.StatusBadRequest, "workspace not found: "+wsID)
+			return
+		}
+	}
+	if req.WorkspaceIDs == nil {
+		req.WorkspaceIDs = []string{}
+	}
+
+	token, err := auth.GenerateInviteToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate invitation")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	now := time.Now().UTC()
+	inv := invitationEntry{
+		ID:           generateID(),
+		Role:         req.Role,
+		WorkspaceIDs: req.WorkspaceIDs,
+		Note:         strings.TrimSpace(req.Note),
+		InvitedBy:    userID,
+		ExpiresAt:    now.AddDate(0, 0, req.ExpiresInDays),
+		CreatedAt:    now,
+	}
+	workspaceIDs, _ := json.Marshal(inv.WorkspaceIDs)
+	if _, err := h.db.Exec(
+		`INSERT INTO user_invitations (id, token_hash, role, workspace_ids, note, invited_by, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.ID, auth.HashAPIToken(token), inv.Role, string(workspaceIDs), inv.Note, userID, inv.ExpiresAt, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create invitation")
+		return
+	}
+
+	h.db.LogAudit(userID, "user_invited", "auth", "invitation", inv.ID, inv.Role+" "+inv.Note)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"invitation": inv,
+	})
+}
+
+func (h *UsersHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	res, err := h.db.Exec("DELETE FROM user_invitations WHERE id = ? AND accepted_at IS NULL", id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke invitation")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "invitation not found")
+		return
+	}
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "invitation_revoked", "auth", "invitation", id, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pendingInvitation resolves a plaintext invitation token, writing an error
+// and returning false if it is unknown, used or expired.
+func (h *UsersHandler) pendingInvitation(w http.ResponseWriter, token string) (invitationEntry, bool) {
+	var inv invitationEntry
+	var workspaceIDs string
+	var acceptedAt sql.NullTime
+	err := h.db.QueryRow(
+		`SELECT id, role, workspace_ids, note, expires_at, accepted_at FROM user_invitations WHERE token_hash = ?`,
+		auth.HashAPIToken(strings.TrimSpace(token)),
+	).Scan(&inv.ID, &inv.Role, &workspaceIDs, &inv.Note, &inv.ExpiresAt, &acceptedAt)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invitation not found")
+		return inv, false
+	}
+	if acceptedAt.Valid {
+		writeError(w, http.StatusGone, "invitation has already been used")
+		return inv, false
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		writeError(w, http.StatusGone, "invitation has expired")
+		return inv, false
+	}
+	json.Unmarshal([]byte(workspaceIDs), &inv.WorkspaceIDs)
+	return inv, true
+}
+
+// GetInvitation lets the accept page show what an invitation grants before
+// the invitee picks a username. Public.
+func (h *UsersHandler) GetInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, ok := h.pendingInvitation(w, chi.URLParam(r, "token"))
+	if !ok {
+		return
+	}
+	names := []string{}
+	for _, wsID := range inv.WorkspaceIDs {
+		var name string
+		if h.db.QueryRow("SELECT name FROM workspaces WHERE id = ?", wsID).Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"role":       inv.Role,
+		"workspaces": names,
+		"note":       inv.Note,
+		"expires_at": inv.ExpiresAt,
+	})
+}
+
+// AcceptInvitation creates the invitee's account, adds their memberships and
+// signs them in. Public.
+func (h *UsersHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	inv, ok := h.pendingInvitation(w, req.Token)
+	if !ok {
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "username and password required")
+		return
+	}
+	if msg := validatePassword(req.Password); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	var existingID string
+	if err := h.db.QueryRow("SELECT id FROM users WHERE username = ?", req.Username).Scan(&existingID); err == nil {
+		writeError(w, http.StatusConflict, "username already taken")
+		return
+	}
+
+	hash, err := h.auth.HashPassword(req.Password)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to hash password")
+		return
+	}
+
+	now := time.Now().UTC()
+	user := models.User{
+		ID:          generateID(),
+		Username:    req.Username,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Role:        inv.Role,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create account")
+		return
+	}
+	defer tx.Rollback()
+
+	// Claim the invitation first so two concurrent accepts can't both succeed.
+	res, err := tx.Exec(
+		"UPDATE user_invitations SET accepted_at = ?, accepted_user_id = ? WHERE id = ? AND accepted_at IS NULL",
+		now, user.ID, inv.ID,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create account")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusGone, "invitation has already been used")
+		return
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO users (id, username, password_hash, display_name, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, hash, user.DisplayName, user.Role, now, now,
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create account")
+		return
+	}
+	for _, wsID := range inv.WorkspaceIDs {
+		// A workspace deleted since the invitation was made is skipped.
+		tx.Exec(
+			"INSERT OR IGNORE INTO workspace_members (workspace_id, user_id, role, created_at) SELECT id, ?, ?, ? FROM workspaces WHERE id = ?",
+			user.ID, inv.Role, now, wsID,
+		)
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create account")
+		return
+	}
+
+	token, err := h.auth.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	secure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+	http.SetCookie(w, &http.Cookie{
+		Name:     "openpaw_token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   secure,
+		MaxAge:   86400,
+	})
+
+	h.db.LogAudit(user.ID, "invitation_accepted", "auth", "user", user.ID, user.Username+" joined as "+user.Role)
+
+	middleware.SetCSRFCookie(w, r)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"user": user})
+}
+
+func (h *UsersHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	rows, err := h.db.Query(
+		`SELECT u.id, u.username, u.display_name, u.role, m.role
+		 FROM workspace_members m JOIN users u ON u.id = m.user_id
+		 WHERE m.workspace_id = ? ORDER BY u.username ASC`,
+		chi.URLParam(r, "id"),
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list members")
+		return
+	}
+	defer rows.Close()
+
+	type memberEntry struct {
+		UserID      string `json:"user_id"`
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+		AccountRole string `json:"account_role"`
+		Role        string `json:"role"`
+	}
+	list := []memberEntry{}
+	for rows.Next() {
+		var m memberEntry
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.AccountRole, &m.Role); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to scan member")
+			return
+		}
+		list = append(list, m)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// SetMember adds a user to a workspace or changes their role there.
+func (h *UsersHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	workspaceID := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userId")
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "role must be admin, editor or viewer")
+		return
+	}
+	var exists int
+	if err := h.db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", workspaceID).Scan(&exists); err != nil {
+		writeError(w, http.StatusNotFound, "workspace not found")
+		return
+	}
+	if !h.db.UserExists(memberID) {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	if _, err := h.db.Exec(
+		`INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(workspace_id, user_id) DO UPDATE SET role = excluded.role`,
+		workspaceID, memberID, req.Role, time.Now().UTC(),
+	); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save membership")
+		return
+	}
+
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "workspace_member_set", "workspace", "workspace", workspaceID, memberID+" as "+req.Role)
+	writeJSON(w, http.StatusOK, map[string]string{"workspace_id": workspaceID, "user_id": memberID, "role": req.Role})
+}
+
+func (h *UsersHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
+	workspaceID := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userId")
+	res, err := h.db.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, memberID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove member")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "membership not found")
+		return
+	}
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "workspace_member_removed", "workspace", "workspace", workspaceID, memberID)
+	w.WriteHeader(http.StatusNoContent)
+}
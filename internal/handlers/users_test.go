@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openpaw/openpaw/internal/auth"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/middleware"
+)
+
+const testAdminID = "user-admin"
+
+func insertTestUser(t *testing.T, db *database.DB, id, role string) {
+	t.Helper()
+	if _, err := db.Exec(
+		"INSERT INTO users (id, username, password_hash, display_name, role) VALUES (?, ?, 'x', ?, ?)",
+		id, id, id, role,
+	); err != nil {
+		t.Fatalf("insert user %s: %v", id, err)
+	}
+}
+
+func withUser(r *http.Request, userID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID))
+}
+
+// asUser is router middleware standing in for Auth in handler tests.
+func asUser(userID string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, withUser(r, userID))
+		})
+	}
+}
+
+func TestWorkspaceRolesGateDatabases(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	insertTestUser(t, db, "user-editor", auth.RoleEditor)
+	insertTestUser(t, db, "user-capped", auth.RoleEditor)
+	insertTestUser(t, db, "user-outsider", auth.RoleEditor)
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-editor', 'editor')", DefaultWorkspaceID)
+	// An editor account with a viewer membership is a viewer in that workspace.
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-capped', 'viewer')", DefaultWorkspaceID)
+
+	h := NewDatabasesHandler(db, nil)
+	router := chi.NewRouter()
+	router.Get("/databases", h.List)
+	router.Post("/databases", h.Create)
+
+	call := func(method, userID string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/databases", strings.NewReader(`{"name":"DB `+userID+`"}`))
+		router.ServeHTTP(rec, withUser(req, userID))
+		return rec.Code
+	}
+
+	cases := []struct {
+		user         string
+		list, create int
+	}{
+		{testAdminID, http.StatusOK, http.StatusCreated},
+		{"user-editor", http.StatusOK, http.StatusCreated},
+		{"user-capped", http.StatusOK, http.StatusForbidden},
+		{"user-outsider", http.StatusForbidden, http.StatusForbidden},
+		{"user-missing", http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if got := call(http.MethodGet, c.user); got != c.list {
+			t.Errorf("%s list = %d, want %d", c.user, got, c.list)
+		}
+		if got := call(http.MethodPost, c.user); got != c.create {
+			t.Errorf("%s create = %d, want %d", c.user, got, c.create)
+		}
+	}
+}
+
+func TestWorkspaceListOnlyShowsMemberships(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	insertTestUser(t, db, "user-viewer", auth.RoleViewer)
+	db.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('ws-private', 'Private', 1)")
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-viewer', 'viewer')", DefaultWorkspaceID)
+
+	h := NewWorkspacesHandler(db, t.TempDir(), nil)
+	count := func(userID string) int {
+		rec := httptest.NewRecorder()
+		h.List(rec, withUser(httptest.NewRequest(http.MethodGet, "/workspaces", nil), userID))
+		var list []map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &list)
+		return len(list)
+	}
+	if got := count(testAdminID); got != 2 {
+		t.Errorf("admin sees %d workspaces, want 2", got)
+	}
+	if got := count("user-viewer"); got != 1 {
+		t.Errorf("viewer sees %d workspaces, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Create(rec, withUser(httptest.NewRequest(http.MethodPost, "/workspaces", strings.NewReader(`{"name":"X"}`)), "user-viewer"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("viewer create workspace = %d, want 403", rec.Code)
+	}
+}
+
+func TestSecretRevealIsAdminOnly(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	insertTestUser(t, db, "user-editor", auth.RoleEditor)
+
+	h := NewSecretsHandler(db, nil, nil)
+	router := chi.NewRouter()
+	router.Post("/secrets/{id}/reveal", h.Reveal)
+
+	reveal := func(userID string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodPost, "/secrets/missing/reveal", nil), userID))
+		return rec.Code
+	}
+	if got := reveal("user-editor"); got != http.StatusForbidden {
+		t.Errorf("editor reveal = %d, want 403", got)
+	}
+	// Admins get past the role check to the lookup.
+	if got := reveal(testAdminID); got != http.StatusNotFound {
+		t.Errorf("admin reveal = %d, want 404", got)
+	}
+}
+
+// Routes that run code or rewrite the instance are admin-only; context
+// documents follow the workspace role like databases do.
+func TestInstanceRoutesRefuseNonAdmins(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, "user-editor", auth.RoleEditor)
+	insertTestUser(t, db, "user-viewer", auth.RoleViewer)
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-editor', 'editor')", DefaultWorkspaceID)
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-viewer', 'viewer')", DefaultWorkspaceID)
+
+	terminal := &TerminalHandler{db: db}
+	tools := &ToolLibraryHandler{db: db}
+	backups := &BackupHandler{db: db}
+	settings := &SettingsHandler{db: db}
+	contextDocs := &ContextHandler{db: db}
+	adminOnly := map[string]http.HandlerFunc{
+		"create terminal session": terminal.CreateSession,
+		"import tool":             tools.ImportTool,
+		"install catalog tool":    tools.InstallCatalogTool,
+		"restore backup":          backups.Restore,
+		"update backup config":    backups.UpdateConfig,
+		"update settings":         settings.Update,
+		"update embeddings":       settings.UpdateMemoryEmbeddings,
+	}
+	for name, handler := range adminOnly {
+		rec := httptest.NewRecorder()
+		handler(rec, withUser(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")), "user-editor"))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("editor %s = %d, want 403", name, rec.Code)
+		}
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"update context file":      contextDocs.UpdateFile,
+		"delete context file":      contextDocs.DeleteFile,
+		"restore context revision": contextDocs.RestoreRevision,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, withUser(httptest.NewRequest(http.MethodPut, "/", strings.NewReader("{}")), "user-viewer"))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("viewer %s = %d, want 403", name, rec.Code)
+		}
+	}
+	// An editor gets past the role check to the lookup.
+	router := chi.NewRouter()
+	router.Delete("/context/files/{id}", contextDocs.DeleteFile)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodDelete, "/context/files/missing", nil), "user-editor"))
+	if rec.Code == http.StatusForbidden {
+		t.Error("editor was refused a context delete")
+	}
+}
+
+func TestViewersCantRunAgents(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, "user-viewer", auth.RoleViewer)
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-viewer', 'viewer')", DefaultWorkspaceID)
+	db.Exec("INSERT INTO chat_threads (id, title, workspace_id) VALUES ('t1', 'Chat', ?)", DefaultWorkspaceID)
+
+	chat := &ChatHandler{db: db}
+	roles := &AgentRolesHandler{db: db}
+	router := chi.NewRouter()
+	router.Use(asUser("user-viewer"))
+	router.Get("/chat/threads/{id}/messages", chat.GetMessages)
+	router.Post("/chat/threads/{id}/messages", chat.SendMessage)
+	router.Post("/chat/threads/{id}/compact", chat.CompactThread)
+	router.Post("/chat/threads", chat.CreateThread)
+	router.Post("/agent-roles", roles.Create)
+	router.Put("/agent-roles/{slug}", roles.Update)
+	router.Delete("/agent-roles/{slug}", roles.Delete)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/chat/threads/t1/messages"},
+		{http.MethodPost, "/chat/threads/t1/compact"},
+		{http.MethodPost, "/chat/threads"},
+		{http.MethodPost, "/agent-roles"},
+		{http.MethodPut, "/agent-roles/scout"},
+		{http.MethodDelete, "/agent-roles/scout"},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"content":"hi","name":"Scout"}`)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("viewer %s %s = %d, want 403", route.method, route.path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chat/threads/t1/messages", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("viewer reading the chat = %d, want 200", rec.Code)
+	}
+}
+
+// Switching workspace moves only the caller, and a request can name its
+// workspace outright as long as the caller can see it.
+func TestWorkspaceIsResolvedPerRequest(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	insertTestUser(t, db, "user-editor", auth.RoleEditor)
+	db.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('ws-b', 'B', 2), ('ws-c', 'C', 3)")
+	db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, 'user-editor', 'editor'), ('ws-b', 'user-editor', 'editor')", DefaultWorkspaceID)
+
+	workspaces := NewWorkspacesHandler(db, t.TempDir(), nil)
+	active := func(userID, header string) (int, string) {
+		router := chi.NewRouter()
+		router.Use(asUser(userID), WorkspaceScope(db))
+		router.Get("/workspaces/active", workspaces.GetActive)
+		req := httptest.NewRequest(http.MethodGet, "/workspaces/active", nil)
+		if header != "" {
+			req.Header.Set(workspaceHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var ws struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &ws)
+		return rec.Code, ws.ID
+	}
+
+	rec := httptest.NewRecorder()
+	workspaces.SetActive(rec, withUser(httptest.NewRequest(http.MethodPut, "/workspaces/active",
+		strings.NewReader(`{"workspace_id":"ws-b"}`)), "user-editor"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("switch workspace = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, id := active("user-editor", ""); id != "ws-b" {
+		t.Errorf("editor's workspace = %q, want ws-b", id)
+	}
+	if _, id := active(testAdminID, ""); id != DefaultWorkspaceID {
+		t.Errorf("admin's workspace = %q, moved by the editor's switch", id)
+	}
+
+	if _, id := active("user-editor", DefaultWorkspaceID); id != DefaultWorkspaceID {
+		t.Errorf("header workspace = %q, want Default", id)
+	}
+	if code, _ := active("user-editor", "ws-c"); code != http.StatusForbidden {
+		t.Errorf("header naming a workspace the editor isn't in = %d, want 403", code)
+	}
+	if code, _ := active("user-editor", "ws-missing"); code != http.StatusNotFound {
+		t.Errorf("header naming no workspace = %d, want 404", code)
+	}
+}
+
+func TestInvitationLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, auth.RoleAdmin)
+	h := NewUsersHandler(db, auth.NewService("test-secret"))
+
+	rec := httptest.NewRecorder()
+	h.CreateInvitation(rec, withUser(httptest.NewRequest(http.MethodPost, "/users/invitations",
+		strings.NewReader(`{"role":"editor","note":"for Sam"}`)), testAdminID))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create invitation = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	accept := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := `{"token":"` + created.Token + `","username":"sam","password":"Passw0rdX"}`
+		h.AcceptInvitation(rec, httptest.NewRequest(http.MethodPost, "/invitations/accept", strings.NewReader(body)))
+		return rec
+	}
+	if rec := accept(); rec.Code != http.StatusCreated {
+		t.Fatalf("accept = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := accept(); rec.Code != http.StatusGone {
+		t.Fatalf("second accept = %d, want 410", rec.Code)
+	}
+
+	var samID string
+	db.QueryRow("SELECT id FROM users WHERE username = 'sam'").Scan(&samID)
+	if got := db.WorkspaceRole(samID, DefaultWorkspaceID); got != auth.RoleEditor {
+		t.Errorf("sam's default workspace role = %q, want editor", got)
+	}
+
+	// The only admin can't be demoted.
+	router := chi.NewRouter()
+	router.Put("/users/{id}/role", h.UpdateRole)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodPut, "/users/"+testAdminID+"/role",
+		strings.NewReader(`{"role":"viewer"}`)), testAdminID))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("demote last admin = %d, want 409", rec.Code)
+	}
+}
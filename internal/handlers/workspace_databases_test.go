@@ -12,6 +12,7 @@ import (
 
 func TestDeleteWorkspacePreservesDatabasesWithConflictingNames(t *testing.T) {
 	db := newTestDB(t)
+	insertTestUser(t, db, testAdminID, "admin")
 	if _, err := db.Exec("INSERT INTO workspaces (id, name, sort_order) VALUES ('old-workspace', 'Old', 1)"); err != nil {
 		t.Fatalf("create workspace: %v", err)
 	}
@@ -28,7 +29,7 @@ func TestDeleteWorkspacePreservesDatabasesWithConflictingNames(t *testing.T) {
 	router := chi.NewRouter()
 	router.Delete("/workspaces/{id}", h.Delete)
 	rec := httptest.NewRecorder()
-	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/workspaces/old-workspace", nil))
+	router.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodDelete, "/workspaces/old-workspace", nil), testAdminID))
 	if rec.Code != http.StatusNoContent {
 		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
 	}
@@ -20,6 +20,7 @@ func searchFilesReq(t *testing.T, h *WorkspacesHandler, query string) *httptest.
 	r.Get("/workspaces/{id}/search", h.SearchFiles)
 	req := httptest.NewRequest(http.MethodGet,
 		"/workspaces/"+DefaultWorkspaceID+"/search?q="+url.QueryEscape(query), nil)
+	req = withUser(req, testAdminID)
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, req)
 	return rec
@@ -161,6 +162,7 @@ func newTestWorkspacesHandler(t *testing.T) (*WorkspacesHandler, string) {
 		t.Fatalf("open test db: %v", err)
 	}
 	t.Cleanup(func() { db.Close() })
+	insertTestUser(t, db, testAdminID, "admin")
 
 	h := &WorkspacesHandler{db: db, dataDir: dataDir}
 	filesDir := h.filesDir(DefaultWorkspaceID)
@@ -176,6 +178,7 @@ func readFileReq(t *testing.T, h *WorkspacesHandler, path string) *httptest.Resp
 	r.Get("/workspaces/{id}/file", h.ReadFile)
 	req := httptest.NewRequest(http.MethodGet,
 		"/workspaces/"+DefaultWorkspaceID+"/file?dir=&path="+path, nil)
+	req = withUser(req, testAdminID)
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, req)
 	return rec
@@ -187,6 +190,7 @@ func writeFileReq(t *testing.T, h *WorkspacesHandler, body string) *httptest.Res
 	r.Put("/workspaces/{id}/file", h.WriteFile)
 	req := httptest.NewRequest(http.MethodPut,
 		"/workspaces/"+DefaultWorkspaceID+"/file", strings.NewReader(body))
+	req = withUser(req, testAdminID)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, req)
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/auth"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/middleware"
@@ -29,10 +31,44 @@ import (
 // can never be deleted.
 const DefaultWorkspaceID = database.DefaultWorkspaceID
 
-// activeWorkspaceID returns the currently active workspace id from settings,
-// falling back to the Default workspace when unset.
-func activeWorkspaceID(db *database.DB) string {
-	return db.ActiveWorkspaceID()
+// workspaceHeader names the workspace a request acts in. The UI sends it on
+// every call, so two people (or two tabs) can work in different workspaces at
+// once.
+const workspaceHeader = "X-Workspace-ID"
+
+type workspaceContextKey struct{}
+
+// WorkspaceScope checks the X-Workspace-ID header before a request reaches
+// its handler: the workspace must exist and the caller must be able to see
+// it. It runs after Auth.
+func WorkspaceScope(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := strings.TrimSpace(r.Header.Get(workspaceHeader))
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var exists int
+			if err := db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", id).Scan(&exists); err != nil {
+				writeError(w, http.StatusNotFound, "workspace not found")
+				return
+			}
+			if !requireWorkspaceRole(w, r, db, id, auth.RoleViewer) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), workspaceContextKey{}, id)))
+		})
+	}
+}
+
+// requestWorkspaceID returns the workspace a request acts in: the one its
+// X-Workspace-ID header named, else the one its user last switched to.
+func requestWorkspaceID(r *http.Request, db *database.DB) string {
+	if id, ok := r.Context().Value(workspaceContextKey{}).(string); ok {
+		return id
+	}
+	return db.UserWorkspaceID(middleware.GetUserID(r.Context()))
 }
 
 type WorkspacesHandler struct {
@@ -64,9 +100,16 @@ func EnsureDefaultWorkspaceDir(dataDir string) {
 	}
 }
 
+// List returns the workspaces the caller can see: all of them for admins,
+// otherwise only those they are a member of.
 func (h *WorkspacesHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	isAdmin := h.db.UserRole(userID) == auth.RoleAdmin
 	rows, err := h.db.Query(
-		"SELECT id, name, image_url, sort_order, is_default, created_at, updated_at FROM workspaces ORDER BY sort_order ASC, created_at ASC",
+		`SELECT id, name, image_url, sort_order, is_default, created_at, updated_at FROM workspaces
+		 WHERE ? OR id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		 ORDER BY sort_order ASC, created_at ASC`,
+		isAdmin, userID,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list workspaces")
@@ -87,6 +130,9 @@ func (h *WorkspacesHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *WorkspacesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 	var req struct {
 		Name string `json:"name"`
 	}
@@ -134,6 +180,9 @@ func (h *WorkspacesHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 func (h *WorkspacesHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !requireWorkspaceRole(w, r, h.db, id, auth.RoleEditor) {
+		return
+	}
 
 	var req struct {
 		Name      *string `json:"name,omitempty"`
@@ -177,6 +226,9 @@ func (h *WorkspacesHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 func (h *WorkspacesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !requireRole(w, r, h.db, auth.RoleAdmin) {
+		return
+	}
 
 	if id == DefaultWorkspaceID {
 		writeError(w, http.StatusBadRequest, "the default workspace cannot be deleted")
@@ -258,8 +310,9 @@ func (h *WorkspacesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If this was the active workspace, fall back to Default.
-	if activeWorkspaceID(h.db) == id {
+	// If this was the instance's active workspace, fall back to Default. Users
+	// who had switched to it fall back on their own.
+	if h.db.ActiveWorkspaceID() == id {
 		h.setActiveWorkspace(DefaultWorkspaceID)
 	}
 
@@ -277,6 +330,9 @@ func (h *WorkspacesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // UploadImage accepts a multipart image and stores it alongside avatars,
 // returning a served URL the caller can persist as a workspace's image_url.
 func (h *WorkspacesHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, h.db, auth.RoleEditor) {
+		return
+	}
 	r.ParseMultipartForm(5 << 20) // 5MB max
 
 	file, header, err := r.FormFile("image")
@@ -338,6 +394,9 @@ func (h *WorkspacesHandler) GenerateImage(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusBadRequest, "invalid workspace id")
 		return
 	}
+	if !requireWorkspaceRole(w, r, h.db, id, auth.RoleEditor) {
+		return
+	}
 	var exists int
 	if err := h.db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", id).Scan(&exists); err != nil {
 		writeError(w, http.StatusNotFound, "workspace not found")
@@ -406,8 +465,9 @@ func (h *WorkspacesHandler) GenerateImage(w http.ResponseWriter, r *http.Request
 	writeJSON(w, http.StatusOK, map[string]string{"image_url": imageURL})
 }
 
+// GetActive returns the workspace the request acts in.
 func (h *WorkspacesHandler) GetActive(w http.ResponseWriter, r *http.Request) {
-	id := activeWorkspaceID(h.db)
+	id := requestWorkspaceID(r, h.db)
 
 	var ws models.Workspace
 	err := h.db.QueryRow(
@@ -439,6 +499,9 @@ func (h *WorkspacesHandler) SetActive(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "workspace_id is required")
 		return
 	}
+	if !requireWorkspaceRole(w, r, h.db, req.WorkspaceID, auth.RoleViewer) {
+		return
+	}
 
 	var exists int
 	if err := h.db.QueryRow("SELECT 1 FROM workspaces WHERE id = ?", req.WorkspaceID).Scan(&exists); err != nil {
@@ -446,12 +509,21 @@ func (h *WorkspacesHandler) SetActive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.setActiveWorkspace(req.WorkspaceID)
-
+	// Switching moves the caller's own requests. An admin also moves the
+	// instance's default, which background work and users who never switched
+	// act in.
 	userID := middleware.GetUserID(r.Context())
+	if _, err := h.db.Exec("UPDATE users SET active_workspace_id = ? WHERE id = ?", req.WorkspaceID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to switch workspace")
+		return
+	}
+	if auth.RoleAtLeast(h.db.UserRole(userID), auth.RoleAdmin) {
+		h.setActiveWorkspace(req.WorkspaceID)
+	}
+
 	h.db.LogAudit(userID, "workspace_activated", "workspace", "workspace", req.WorkspaceID, "")
 
-	h.GetActive(w, r)
+	h.GetActive(w, r.WithContext(context.WithValue(r.Context(), workspaceContextKey{}, req.WorkspaceID)))
 }
 
 // setActiveWorkspace upserts the active workspace pointer in settings.
@@ -489,6 +561,9 @@ func (h *WorkspacesHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "workspace not found")
 		return
 	}
+	if !requireWorkspaceRole(w, r, h.db, id, auth.RoleViewer) {
+		return
+	}
 
 	root := h.filesDir(id)
 	if err := os.MkdirAll(root, 0755); err != nil {
@@ -522,6 +597,9 @@ func (h *WorkspacesHandler) RevealFiles(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusNotFound, "workspace not found")
 		return
 	}
+	if !requireWorkspaceRole(w, r, h.db, id, auth.RoleEditor) {
+		return
+	}
 
 	root := h.filesDir(id)
 	if err := os.MkdirAll(root, 0755); err != nil {
@@ -589,7 +667,7 @@ func safeJoin(base, rel string) (string, bool) {
 // relative path. This backs lazy expansion in the Directory tab.
 func (h *WorkspacesHandler) Browse(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleViewer) {
 		return
 	}
 
@@ -703,7 +781,7 @@ func fuzzyFileScore(name, path, query string) (int, bool) {
 // entries, which would make opening the command palette feel like a disk scan.
 func (h *WorkspacesHandler) SearchFiles(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleViewer) {
 		return
 	}
 
@@ -883,7 +961,7 @@ const maxEditableFileSize = 2 << 20 // 2 MiB
 // through a textarea, and written back over the original.
 func (h *WorkspacesHandler) ReadFile(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleViewer) {
 		return
 	}
 
@@ -933,7 +1011,7 @@ func (h *WorkspacesHandler) ReadFile(w http.ResponseWriter, r *http.Request) {
 // or was deleted, and silently recreating it would resurrect deleted content.
 func (h *WorkspacesHandler) WriteFile(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleEditor) {
 		return
 	}
 
@@ -980,7 +1058,7 @@ func (h *WorkspacesHandler) WriteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.db.LogAudit("user", "workspace_file_edited", "user", "file", req.Path,
+	h.db.LogAudit(middleware.GetUserID(r.Context()), "workspace_file_edited", "user", "file", req.Path,
 		fmt.Sprintf("edited %s (%s)", filepath.Base(target), formatBytes(updated.Size())))
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
@@ -1000,9 +1078,10 @@ type workspaceDirectory struct {
 	Files   []fileNode `json:"files"`
 }
 
-// validWorkspace validates id is a uuid and that the workspace exists,
-// writing an error response and returning false if not.
-func (h *WorkspacesHandler) validWorkspace(w http.ResponseWriter, id string) bool {
+// validWorkspace validates id is a uuid, that the workspace exists and that
+// the caller has at least minRole in it, writing an error response and
+// returning false if not.
+func (h *WorkspacesHandler) validWorkspace(w http.ResponseWriter, r *http.Request, id, minRole string) bool {
 	if _, err := uuid.Parse(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid workspace id")
 		return false
@@ -1012,7 +1091,7 @@ func (h *WorkspacesHandler) validWorkspace(w http.ResponseWriter, id string) boo
 		writeError(w, http.StatusNotFound, "workspace not found")
 		return false
 	}
-	return true
+	return requireWorkspaceRole(w, r, h.db, id, minRole)
 }
 
 // AddDirectory attaches an external, on-disk directory to a workspace so it
@@ -1021,7 +1100,7 @@ func (h *WorkspacesHandler) validWorkspace(w http.ResponseWriter, id string) boo
 // ignored (the existing row is returned).
 func (h *WorkspacesHandler) AddDirectory(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleAdmin) {
 		return
 	}
 
@@ -1095,7 +1174,7 @@ func (h *WorkspacesHandler) AddDirectory(w http.ResponseWriter, r *http.Request)
 // missing with an empty tree.
 func (h *WorkspacesHandler) ListDirectories(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleViewer) {
 		return
 	}
 
@@ -1134,7 +1213,7 @@ func (h *WorkspacesHandler) ListDirectories(w http.ResponseWriter, r *http.Reque
 // removes the attachment row — the real directory on disk is never touched.
 func (h *WorkspacesHandler) RemoveDirectory(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if !h.validWorkspace(w, id) {
+	if !h.validWorkspace(w, r, id, auth.RoleAdmin) {
 		return
 	}
 	dirID := chi.URLParam(r, "dirId")
@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/openpaw/openpaw/internal/agents"
+	"github.com/openpaw/openpaw/internal/budget"
 	"github.com/openpaw/openpaw/internal/database"
 	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
@@ -916,13 +917,17 @@ Now read your heartbeat instructions below and take action.`, time.Now().Format(
 	}
 
 	provider := m.agentMgr.ProviderFor(a.provider)
-	result, err := provider.RunAgentLoop(ctx, llm.AgentConfig{
+	result, err := m.agentMgr.RunBudgeted(ctx, provider, llm.AgentConfig{
 		Model:         provider.ResolveModel(model, llm.ModelSonnet),
 		System:        systemPrompt,
 		MaxTurns:      a.turns(),
 		ExtraTools:    extraTools,
 		ExtraHandlers: extraHandlers,
-	}, string(heartbeatContent))
+	}, string(heartbeatContent), budget.Scope{
+		AgentSlug:   slug,
+		WorkspaceID: m.targetWorkspaceID(),
+		Source:      budget.SourceHeartbeat,
+	})
 
 	status := "completed"
 	errMsg := ""
@@ -65,7 +65,7 @@ func (c *Client) RunAgentLoop(ctx context.Context, cfg AgentConfig, userMessage
 
 	maxTokens := cfg.MaxTokens
 	if maxTokens <= 0 {
-		maxTokens = MaxTokensForModel(cfg.Model)
+		maxTokens = c.maxTokensForModel(cfg.Model)
 	}
 	maxTurns := cfg.MaxTurns
 	if maxTurns <= 0 {
@@ -140,7 +140,7 @@ func (c *Client) RunAgentLoop(ctx context.Context, cfg AgentConfig, userMessage
 				Text:         textBuf.String(),
 				InputTokens:  totalInput,
 				OutputTokens: totalOutput,
-				TotalCostUSD: CalculateCost(cfg.Model, totalInput, totalOutput),
+				TotalCostUSD: c.calculateCost(cfg.Model, totalInput, totalOutput),
 				NumTurns:     numTurns,
 				StopReason:   "cancelled",
 			}, ctx.Err()
@@ -286,7 +286,7 @@ func (c *Client) RunAgentLoop(ctx context.Context, cfg AgentConfig, userMessage
 		stopReason = "max_turns"
 	}
 
-	cost := CalculateCost(cfg.Model, totalInput, totalOutput)
+	cost := c.calculateCost(cfg.Model, totalInput, totalOutput)
 
 	emit(StreamEvent{
 		Type:         EventResult,
@@ -315,7 +315,7 @@ func (c *Client) RunOneShot(ctx context.Context, model string, system, prompt st
 		return "", nil, fmt.Errorf("API client not configured")
 	}
 
-	maxTokens := MaxTokensForModel(model)
+	maxTokens := c.maxTokensForModel(model)
 
 	var messages []ChatMessage
 	if system != "" {
@@ -340,7 +340,7 @@ func (c *Client) RunOneShot(ctx context.Context, model string, system, prompt st
 	usage := &UsageInfo{
 		InputTokens:  result.Usage.PromptTokens,
 		OutputTokens: result.Usage.CompletionTokens,
-		CostUSD:      CalculateCost(model, result.Usage.PromptTokens, result.Usage.CompletionTokens),
+		CostUSD:      c.calculateCost(model, result.Usage.PromptTokens, result.Usage.CompletionTokens),
 	}
 
 	return text, usage, nil
@@ -23,12 +23,16 @@ func (e *APIError) Error() string {
 }
 
 type Client struct {
-	httpClient           *http.Client // streaming — no timeout (SSE needs unlimited time)
-	nonStreamingClient   *http.Client // non-streaming — 30s timeout
-	baseURL              string
-	apiKey               string
-	hasKey               bool
-	mu                   sync.RWMutex
+	httpClient         *http.Client // streaming — no timeout (SSE needs unlimited time)
+	nonStreamingClient *http.Client // non-streaming — 30s timeout
+	baseURL            string
+	apiKey             string
+	hasKey             bool
+	// compat marks a client pointed at a generic OpenAI-compatible server
+	// instead of OpenRouter: the key is optional, the base URL can change at
+	// runtime, and OpenRouter's attribution headers are not sent.
+	compat bool
+	mu     sync.RWMutex
 }
 
 func NewClient(apiKey string) *Client {
@@ -63,9 +67,18 @@ func (c *Client) UpdateAPIKey(apiKey string) {
 func (c *Client) IsConfigured() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	if c.compat {
+		return c.baseURL != ""
+	}
 	return c.hasKey
 }
 
+func (c *Client) getBaseURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.baseURL
+}
+
 func (c *Client) getAPIKey() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -132,34 +145,49 @@ type FunctionCall struct {
 
 // ChatCompletionRequest is the request body for the chat completions endpoint.
 type ChatCompletionRequest struct {
-	Model    string        `json:"model"`
-	Messages []ChatMessage `json:"messages"`
-	Tools    []ToolDef     `json:"tools,omitempty"`
-	Stream   bool          `json:"stream"`
-	MaxTokens int64        `json:"max_tokens,omitempty"`
+	Model     string        `json:"model"`
+	Messages  []ChatMessage `json:"messages"`
+	Tools     []ToolDef     `json:"tools,omitempty"`
+	Stream    bool          `json:"stream"`
+	MaxTokens int64         `json:"max_tokens,omitempty"`
+	// StreamOptions asks OpenAI-style servers for a final usage chunk, which
+	// they omit from streams by default. OpenRouter always sends it.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // prepareRequest builds an authenticated POST request to the chat completions endpoint.
 func (c *Client) prepareRequest(ctx context.Context, reqBody ChatCompletionRequest) (*http.Request, error) {
-	key := c.getAPIKey()
-	if key == "" {
-		return nil, fmt.Errorf("API client not configured")
-	}
-
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	return c.newChatRequest(ctx, body)
+}
+
+// newChatRequest builds an authenticated POST to the chat completions endpoint
+// from an already-marshaled body.
+func (c *Client) newChatRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	if !c.IsConfigured() {
+		return nil, fmt.Errorf("API client not configured")
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.getBaseURL()+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+key)
-	req.Header.Set("HTTP-Referer", "https://openpaw.dev")
-	req.Header.Set("X-Title", "OpenPaw")
+	if key := c.getAPIKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	if !c.compat {
+		req.Header.Set("HTTP-Referer", "https://openpaw.dev")
+		req.Header.Set("X-Title", "OpenPaw")
+	}
 
 	return req, nil
 }
@@ -167,6 +195,9 @@ func (c *Client) prepareRequest(ctx context.Context, reqBody ChatCompletionReque
 // doStreamRequest sends a streaming POST to the chat completions endpoint.
 func (c *Client) doStreamRequest(ctx context.Context, reqBody ChatCompletionRequest) (*http.Response, error) {
 	reqBody.Stream = true
+	if c.compat {
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
 	req, err := c.prepareRequest(ctx, reqBody)
 	if err != nil {
 		return nil, err
@@ -216,21 +247,11 @@ func (c *Client) doRequest(ctx context.Context, reqBody ChatCompletionRequest) (
 // doRawRequest sends a non-streaming POST with pre-marshaled JSON body to chat completions.
 // Used for multimodal requests where the content field is an array rather than a string.
 func (c *Client) doRawRequest(ctx context.Context, body []byte) (*ChatCompletionResponse, error) {
-	key := c.getAPIKey()
-	if key == "" {
-		return nil, fmt.Errorf("API client not configured")
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	req, err := c.newChatRequest(ctx, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+key)
-	req.Header.Set("HTTP-Referer", "https://openpaw.dev")
-	req.Header.Set("X-Title", "OpenPaw")
-
 	resp, err := c.nonStreamingClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("API request failed: %w", err)
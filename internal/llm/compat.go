@@ -0,0 +1,253 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CompatConfig is the saved configuration for the OpenAI-compatible provider.
+type CompatConfig struct {
+	// BaseURL is the server's API root, e.g. http://localhost:11434/v1 for
+	// Ollama or http://localhost:1234/v1 for LM Studio.
+	BaseURL string `json:"base_url"`
+	// APIKey is optional — most local servers accept any request.
+	APIKey       string          `json:"-"`
+	DefaultModel string          `json:"default_model"`
+	Models       []ModelOverride `json:"models"`
+}
+
+// CompatProvider runs agents against any server that speaks the OpenAI
+// /v1/chat/completions protocol (Ollama, LM Studio, vLLM, llama.cpp). It reuses
+// the OpenRouter client's tool-calling loop and SSE parsing, so the two only
+// differ in where requests go and how models are named and priced.
+type CompatProvider struct {
+	client *Client
+
+	mu       sync.RWMutex
+	cfg      CompatConfig
+	lastSeen []string // model IDs from the most recent /models call
+}
+
+func NewCompatProvider(cfg CompatConfig) *CompatProvider {
+	p := &CompatProvider{
+		client: &Client{
+			httpClient: &http.Client{},
+			// Local models can take minutes to answer a one-shot prompt on
+			// modest hardware, so the OpenRouter 30s ceiling is far too short.
+			nonStreamingClient: &http.Client{Timeout: 5 * time.Minute},
+			compat:             true,
+		},
+	}
+	p.Configure(cfg)
+	return p
+}
+
+// Configure applies a new configuration. Safe to call while runs are in
+// flight; they finish against the previous server.
+func (p *CompatProvider) Configure(cfg CompatConfig) {
+	cfg.BaseURL = NormalizeCompatBaseURL(cfg.BaseURL)
+	cfg.DefaultModel = strings.TrimSpace(cfg.DefaultModel)
+
+	p.mu.Lock()
+	p.cfg = cfg
+	p.lastSeen = nil
+	p.mu.Unlock()
+
+	p.client.mu.Lock()
+	p.client.baseURL = cfg.BaseURL
+	p.client.apiKey = cfg.APIKey
+	p.client.hasKey = cfg.APIKey != ""
+	p.client.mu.Unlock()
+
+	SetModelOverrides(ProviderOpenAICompat, cfg.Models)
+}
+
+// Config returns the current configuration.
+func (p *CompatProvider) Config() CompatConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg
+}
+
+// NormalizeCompatBaseURL trims a pasted server URL down to its API root. A bare
+// host gets /v1, the path every supported server uses; a full endpoint URL has
+// /chat/completions stripped so it isn't appended twice.
+func NormalizeCompatBaseURL(raw string) string {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	if raw == "" {
+		return ""
+	}
+	raw = strings.TrimSuffix(raw, "/chat/completions")
+	if u, err := url.Parse(raw); err == nil && (u.Path == "" || u.Path == "/") {
+		return raw + "/v1"
+	}
+	return raw
+}
+
+func (p *CompatProvider) Name() string { return ProviderOpenAICompat }
+
+func (p *CompatProvider) IsConfigured() bool { return p.client.IsConfigured() }
+
+func (p *CompatProvider) RunAgentLoop(ctx context.Context, cfg AgentConfig, userMessage string) (*AgentResult, error) {
+	cfg.Model = p.ResolveModel(cfg.Model, "")
+	return p.client.RunAgentLoop(ctx, cfg, userMessage)
+}
+
+func (p *CompatProvider) RunOneShot(ctx context.Context, model, system, prompt string) (string, *UsageInfo, error) {
+	return p.client.RunOneShot(ctx, p.ResolveModel(model, ""), system, prompt)
+}
+
+// ResolveModel passes server-native model IDs through untouched. OpenPaw's own
+// defaults (tier names and the OpenRouter Claude routes) mean nothing to a
+// local server, so they become the configured default model instead.
+func (p *CompatProvider) ResolveModel(name, fallback string) string {
+	name = strings.TrimSpace(name)
+	if name != "" && name != "auto" && !isOpenPawDefaultModel(name) {
+		return name
+	}
+	return p.defaultModel()
+}
+
+func isOpenPawDefaultModel(name string) bool {
+	for short, id := range legacyModels {
+		if name == short || name == id {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultModel is the configured default, else the first model with an
+// override, else the first model the server listed.
+func (p *CompatProvider) defaultModel() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.cfg.DefaultModel != "" {
+		return p.cfg.DefaultModel
+	}
+	if len(p.cfg.Models) > 0 {
+		return p.cfg.Models[0].ID
+	}
+	if len(p.lastSeen) > 0 {
+		return p.lastSeen[0]
+	}
+	return ""
+}
+
+// compatModel is an entry from an OpenAI-style /models response. Servers that
+// know their context size report it under different names.
+type compatModel struct {
+	ID            string `json:"id"`
+	ContextLength int    `json:"context_length"`
+	MaxModelLen   int    `json:"max_model_len"` // vLLM
+}
+
+// ListModels asks the server for its models and merges in the overrides from
+// settings, which also covers servers that don't list everything they serve.
+func (p *CompatProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	baseURL := p.client.getBaseURL()
+	if baseURL == "" {
+		return nil, fmt.Errorf("OpenAI-compatible server URL not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if key := p.client.getAPIKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	listClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := listClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result struct {
+		Data []compatModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	cfg := p.Config()
+	overrides := make(map[string]ModelOverride, len(cfg.Models))
+	for _, o := range cfg.Models {
+		overrides[o.ID] = o
+	}
+
+	var models []ModelInfo
+	seen := make(map[string]bool)
+	var ids []string
+	for _, m := range result.Data {
+		if m.ID == "" || seen[m.ID] {
+			continue
+		}
+		seen[m.ID] = true
+		ids = append(ids, m.ID)
+		info := ModelInfo{ID: m.ID, Name: m.ID, ContextLength: m.ContextLength}
+		if info.ContextLength == 0 {
+			info.ContextLength = m.MaxModelLen
+		}
+		if o, ok := overrides[m.ID]; ok {
+			applyOverride(&info, o)
+		}
+		models = append(models, info)
+	}
+	for _, o := range cfg.Models {
+		if seen[o.ID] {
+			continue
+		}
+		info := ModelInfo{ID: o.ID, Name: o.ID}
+		applyOverride(&info, o)
+		models = append(models, info)
+	}
+	sort.SliceStable(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+
+	p.mu.Lock()
+	p.lastSeen = ids
+	p.mu.Unlock()
+
+	return models, nil
+}
+
+func applyOverride(info *ModelInfo, o ModelOverride) {
+	if o.ContextLength > 0 {
+		info.ContextLength = o.ContextLength
+	}
+	if o.MaxTokens > 0 {
+		info.TopProvider.MaxCompletionTokens = int(o.MaxTokens)
+	}
+	// ModelPricing follows OpenRouter's convention of USD per token.
+	info.Pricing.Prompt = strconv.FormatFloat(o.InputPerMillion/1_000_000, 'f', -1, 64)
+	info.Pricing.Completion = strconv.FormatFloat(o.OutputPerMillion/1_000_000, 'f', -1, 64)
+}
+
+// StatusInfo reports the provider's configuration for the Settings page,
+// never the API key itself.
+func (p *CompatProvider) StatusInfo() map[string]interface{} {
+	cfg := p.Config()
+	return map[string]interface{}{
+		"available":     p.IsConfigured(),
+		"base_url":      cfg.BaseURL,
+		"api_key_set":   cfg.APIKey != "",
+		"default_model": cfg.DefaultModel,
+		"models":        cfg.Models,
+	}
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeCompatBaseURL(t *testing.T) {
+	cases := map[string]string{
+		"":                                    "",
+		"http://localhost:11434":              "http://localhost:11434/v1",
+		"http://localhost:11434/":             "http://localhost:11434/v1",
+		"http://localhost:1234/v1/":           "http://localhost:1234/v1",
+		"http://gpu:8000/v1/chat/completions": "http://gpu:8000/v1",
+		"https://llm.internal/api/v1":         "https://llm.internal/api/v1",
+	}
+	for in, want := range cases {
+		if got := NormalizeCompatBaseURL(in); got != want {
+			t.Errorf("NormalizeCompatBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCompatProviderResolveModel(t *testing.T) {
+	p := NewCompatProvider(CompatConfig{BaseURL: "http://localhost:11434", DefaultModel: "llama3.1:8b"})
+	defer SetModelOverrides(ProviderOpenAICompat, nil)
+
+	cases := []struct{ name, want string }{
+		{"qwen2.5-coder:32b", "qwen2.5-coder:32b"},
+		{"", "llama3.1:8b"},
+		{"sonnet", "llama3.1:8b"},
+		{ModelSonnet, "llama3.1:8b"},
+		{"auto", "llama3.1:8b"},
+	}
+	for _, c := range cases {
+		if got := p.ResolveModel(c.name, ModelSonnet); got != c.want {
+			t.Errorf("ResolveModel(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCompatProviderAgentLoop(t *testing.T) {
+	var gotAuth, gotModel string
+	var gotUsageOpt bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3.1:8b","object":"model"},{"id":"mistral-7b","max_model_len":32768}]}`)
+		case "/v1/chat/completions":
+			gotAuth = r.Header.Get("Authorization")
+			var req ChatCompletionRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			gotModel = req.Model
+			gotUsageOpt = req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" there\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":1000000,\"completion_tokens\":10}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewCompatProvider(CompatConfig{
+		BaseURL: srv.URL,
+		Models:  []ModelOverride{{ID: "llama3.1:8b", ContextLength: 131072, InputPerMillion: 0.5}},
+	})
+	defer SetModelOverrides(ProviderOpenAICompat, nil)
+
+	models, err := p.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "llama3.1:8b" || models[0].ContextLength != 131072 || models[1].ContextLength != 32768 {
+		t.Fatalf("models = %+v", models)
+	}
+	if got := ContextWindowFor(ProviderOpenAICompat, "llama3.1:8b"); got != 131072 {
+		t.Errorf("ContextWindowFor override = %d, want 131072", got)
+	}
+	// The local server's settings stay with it: OpenRouter sizes and bills
+	// the same ID from its own catalog.
+	if got := ContextWindowFor(ProviderOpenRouter, "llama3.1:8b"); got == 131072 {
+		t.Error("OpenRouter context window picked up the local override")
+	}
+	if CalculateCost("llama3.1:8b", 1_000_000, 0) == 0.5 {
+		t.Error("OpenRouter pricing picked up the local override")
+	}
+
+	res, err := p.RunAgentLoop(context.Background(), AgentConfig{Model: "sonnet"}, "hi")
+	if err != nil {
+		t.Fatalf("RunAgentLoop: %v", err)
+	}
+	if res.Text != "Hello there" {
+		t.Errorf("text = %q", res.Text)
+	}
+	if gotModel != "llama3.1:8b" {
+		t.Errorf("model sent = %q, want the default model", gotModel)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization = %q, want none without a key", gotAuth)
+	}
+	if !gotUsageOpt {
+		t.Error("stream_options.include_usage not requested")
+	}
+	if res.InputTokens != 1000000 || res.TotalCostUSD != 0.5 {
+		t.Errorf("usage = %d tokens, $%v; want 1000000 tokens, $0.5", res.InputTokens, res.TotalCostUSD)
+	}
+
+	p.Configure(CompatConfig{BaseURL: srv.URL, APIKey: "sk-local"})
+	if _, err := p.RunAgentLoop(context.Background(), AgentConfig{Model: "mistral-7b"}, "hi"); err != nil {
+		t.Fatalf("RunAgentLoop with key: %v", err)
+	}
+	if gotAuth != "Bearer sk-local" {
+		t.Errorf("Authorization = %q, want bearer key", gotAuth)
+	}
+}
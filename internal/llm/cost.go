@@ -33,3 +33,32 @@ func CalculateCost(model string, inputTokens, outputTokens int64) float64 {
 	return (float64(inputTokens)/1_000_000)*p.inputPerMillion +
 		(float64(outputTokens)/1_000_000)*p.outputPerMillion
 }
+
+func overrideCost(o ModelOverride, inputTokens, outputTokens int64) float64 {
+	return (float64(inputTokens)/1_000_000)*o.InputPerMillion +
+		(float64(outputTokens)/1_000_000)*o.OutputPerMillion
+}
+
+// calculateCost prices a run for this client. Models on an OpenAI-compatible
+// server are usually self-hosted, so without an override they cost nothing
+// rather than falling back to OpenRouter's Sonnet pricing.
+func (c *Client) calculateCost(model string, inputTokens, outputTokens int64) float64 {
+	if !c.compat {
+		return CalculateCost(model, inputTokens, outputTokens)
+	}
+	if o, ok := modelOverride(ProviderOpenAICompat, model); ok {
+		return overrideCost(o, inputTokens, outputTokens)
+	}
+	return 0
+}
+
+// maxTokensForModel is MaxTokensForModel with the OpenAI-compatible
+// provider's overrides applied to its own runs.
+func (c *Client) maxTokensForModel(model string) int64 {
+	if c.compat {
+		if o, ok := modelOverride(ProviderOpenAICompat, model); ok && o.MaxTokens > 0 {
+			return o.MaxTokens
+		}
+	}
+	return MaxTokensForModel(model)
+}
@@ -68,6 +68,56 @@ var staticContextWindows = map[string]int{
 	"gpt-5.5":      1_050_000,
 }
 
+// ModelOverride pins metadata for a model OpenRouter's catalog can't describe —
+// typically one served by a local OpenAI-compatible server. Zero fields mean
+// "not set"; a model with an override but no prices is treated as free.
+type ModelOverride struct {
+	ID               string  `json:"id"`
+	ContextLength    int     `json:"context_length,omitempty"`
+	MaxTokens        int64   `json:"max_tokens,omitempty"`
+	InputPerMillion  float64 `json:"input_per_million,omitempty"`
+	OutputPerMillion float64 `json:"output_per_million,omitempty"`
+}
+
+// modelOverrides holds each provider's overrides by model ID. They are kept
+// per provider so a local server's "free" pricing or context length for a
+// model ID never leaks into how OpenRouter runs are sized or billed.
+var modelOverrides = struct {
+	sync.RWMutex
+	byProvider map[string]map[string]ModelOverride
+}{byProvider: make(map[string]map[string]ModelOverride)}
+
+// SetModelOverrides replaces a provider's per-model overrides, consulted by
+// ContextWindowFor and by that provider's own token limits and pricing.
+func SetModelOverrides(provider string, overrides []ModelOverride) {
+	byID := make(map[string]ModelOverride, len(overrides))
+	for _, o := range overrides {
+		if id := strings.TrimSpace(o.ID); id != "" {
+			o.ID = id
+			byID[id] = o
+		}
+	}
+	modelOverrides.Lock()
+	modelOverrides.byProvider[provider] = byID
+	modelOverrides.Unlock()
+}
+
+func modelOverride(provider, model string) (ModelOverride, bool) {
+	modelOverrides.RLock()
+	defer modelOverrides.RUnlock()
+	o, ok := modelOverrides.byProvider[provider][model]
+	return o, ok
+}
+
+// ContextWindowFor is ContextWindowForModel for a model run by the named
+// provider, honoring that provider's overrides.
+func ContextWindowFor(provider, model string) int {
+	if o, ok := modelOverride(provider, model); ok && o.ContextLength > 0 {
+		return o.ContextLength
+	}
+	return ContextWindowForModel(model)
+}
+
 // dashVersionRe matches a trailing dash-separated version ("-4-5") so it can
 // be normalized to OpenRouter's dotted form ("-4.5") for cache lookups.
 var dashVersionRe = regexp.MustCompile(`-(\d+)-(\d+)$`)
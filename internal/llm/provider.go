@@ -11,11 +11,15 @@ const (
 	ProviderOpenRouter = "openrouter"
 	ProviderClaudeCode = "claude-code"
 	ProviderCodex      = "codex"
+	// ProviderOpenAICompat is any self-hosted server speaking the OpenAI chat
+	// completions protocol (Ollama, LM Studio, vLLM, llama.cpp).
+	ProviderOpenAICompat = "openai-compatible"
 )
 
 // Provider abstracts an LLM backend. OpenRouter (the *Client) is the default;
 // CLI-based providers (Claude Code, Codex) run inference through local
-// subscription-authenticated binaries.
+// subscription-authenticated binaries; the OpenAI-compatible provider talks to
+// a self-hosted chat completions server.
 type Provider interface {
 	Name() string
 	IsConfigured() bool
@@ -3,11 +3,15 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/openpaw/openpaw/internal/websearch"
 )
 
 func handleWebFetch(ctx context.Context, _ string, input json.RawMessage) ToolResult {
@@ -68,12 +72,54 @@ func stripHTMLTags(s string) string {
 	return b.String()
 }
 
-func handleWebSearch(_ context.Context, _ string, input json.RawMessage) ToolResult {
+// webSearcher backs the WebSearch tool. Nil until SetWebSearcher is called at
+// startup, in which case the tool reports that search is unavailable.
+var (
+	webSearcherMu sync.RWMutex
+	webSearcher   *websearch.Searcher
+)
+
+// SetWebSearcher installs the search backends used by the WebSearch tool.
+func SetWebSearcher(s *websearch.Searcher) {
+	webSearcherMu.Lock()
+	webSearcher = s
+	webSearcherMu.Unlock()
+}
+
+func handleWebSearch(ctx context.Context, _ string, input json.RawMessage) ToolResult {
 	var params struct {
 		Query string `json:"query"`
+		Limit int    `json:"limit"`
 	}
 	if err := json.Unmarshal(input, &params); err != nil {
 		return ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
 	}
-	return ToolResult{Output: "Web search is not available in this environment. Try using WebFetch with a specific URL instead."}
+	if strings.TrimSpace(params.Query) == "" {
+		return ToolResult{Output: "query is required", IsError: true}
+	}
+
+	webSearcherMu.RLock()
+	searcher := webSearcher
+	webSearcherMu.RUnlock()
+	if searcher == nil {
+		return ToolResult{Output: "Web search is not available in this environment. Try using WebFetch with a specific URL instead."}
+	}
+
+	results, err := searcher.Search(ctx, params.Query, params.Limit)
+	if errors.Is(err, websearch.ErrNotConfigured) {
+		return ToolResult{Output: err.Error() + ". Try using WebFetch with a specific URL instead."}
+	}
+	if err != nil {
+		return ToolResult{Output: fmt.Sprintf("Search error: %v", err), IsError: true}
+	}
+	if results == nil {
+		results = []websearch.Result{}
+	}
+
+	out, _ := json.Marshal(map[string]interface{}{
+		"query":   params.Query,
+		"count":   len(results),
+		"results": results,
+	})
+	return ToolResult{Output: string(out)}
 }
@@ -178,11 +178,12 @@ var toolSchemas = map[string]toolSchema{
 		},
 	},
 	"WebSearch": {
-		Description: "Search the web for information.",
+		Description: "Search the web. Returns ranked results as title, url and snippet — use WebFetch to read a result in full.",
 		InputSchema: map[string]interface{}{
 			"type": "object",
 			"properties": map[string]interface{}{
 				"query": map[string]interface{}{"type": "string", "description": "Search query"},
+				"limit": map[string]interface{}{"type": "integer", "description": "Maximum results (default 8, max 20)"},
 			},
 			"required": []string{"query"},
 		},
@@ -1,12 +1,14 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	llm "github.com/openpaw/openpaw/internal/llm"
 	"github.com/openpaw/openpaw/internal/logger"
 )
 
@@ -108,17 +110,9 @@ func (r *Registry) handlePost(w http.ResponseWriter, req *http.Request, session
 	case "ping":
 		resp.Result = map[string]interface{}{}
 	case "tools/list":
-		tools := make([]mcpTool, 0, len(session.Tools))
-		for _, t := range session.Tools {
-			tools = append(tools, mcpTool{
-				Name:        t.Function.Name,
-				Description: t.Function.Description,
-				InputSchema: normalizeSchema(t.Function.Parameters),
-			})
-		}
-		resp.Result = map[string]interface{}{"tools": tools}
+		resp.Result = map[string]interface{}{"tools": publishTools(session.Tools)}
 	case "tools/call":
-		resp.Result, resp.Error = r.callTool(req, session, rpc.Params)
+		resp.Result, resp.Error = callTool(req.Context(), session.Handlers, session.WorkDir, session.AgentSlug, rpc.Params, session.setImageURL)
 	default:
 		resp.Error = &rpcError{Code: -32601, Message: fmt.Sprintf("method %q not supported", rpc.Method)}
 	}
@@ -169,7 +163,22 @@ func normalizeSchema(raw json.RawMessage) json.RawMessage {
 	return out
 }
 
-func (r *Registry) callTool(req *http.Request, session *Session, params json.RawMessage) (interface{}, *rpcError) {
+// publishTools converts tool definitions to tools/list entries.
+func publishTools(defs []llm.ToolDef) []mcpTool {
+	tools := make([]mcpTool, 0, len(defs))
+	for _, t := range defs {
+		tools = append(tools, mcpTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: normalizeSchema(t.Function.Parameters),
+		})
+	}
+	return tools
+}
+
+// callTool runs a tools/call against a handler set. who names the caller in
+// the log; onImage, if set, receives any image URL the tool produced.
+func callTool(ctx context.Context, handlers map[string]llm.ToolHandler, workDir, who string, params json.RawMessage, onImage func(string)) (interface{}, *rpcError) {
 	var call struct {
 		Name      string          `json:"name"`
 		Arguments json.RawMessage `json:"arguments"`
@@ -178,7 +187,7 @@ func (r *Registry) callTool(req *http.Request, session *Session, params json.Raw
 		return nil, &rpcError{Code: -32602, Message: "invalid params"}
 	}
 
-	handler, ok := session.Handlers[call.Name]
+	handler, ok := handlers[call.Name]
 	if !ok {
 		return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("unknown tool %q", call.Name)}
 	}
@@ -188,11 +197,11 @@ func (r *Registry) callTool(req *http.Request, session *Session, params json.Raw
 		args = json.RawMessage(`{}`)
 	}
 
-	logger.Info("MCP tool call: %s (agent=%s)", call.Name, session.AgentSlug)
-	result := handler(req.Context(), session.WorkDir, args)
+	logger.Info("MCP tool call: %s (agent=%s)", call.Name, who)
+	result := handler(ctx, workDir, args)
 
-	if result.ImageURL != "" {
-		session.setImageURL(result.ImageURL)
+	if result.ImageURL != "" && onImage != nil {
+		onImage(result.ImageURL)
 	}
 
 	return map[string]interface{}{
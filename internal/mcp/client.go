@@ -0,0 +1,532 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+// Transports an external server can be reached over.
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// Tool is one tool advertised by an external server's tools/list.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// CallResult is a tools/call result flattened to text, which is all the agent
+// loop can pass back to a model.
+type CallResult struct {
+	Text    string
+	IsError bool
+}
+
+// ErrClosed is returned for calls on a connection whose server has gone away.
+// The caller should drop the client and connect again.
+var ErrClosed = errors.New("MCP server connection closed")
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// clientResponse is a JSON-RPC message as a client receives it: a response to
+// one of its requests, or a request or notification from the server.
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// transport sends one JSON-RPC request and waits for its response. notify
+// sends a notification, which has no response.
+type transport interface {
+	call(ctx context.Context, id int64, method string, params interface{}) (json.RawMessage, error)
+	notify(ctx context.Context, method string, params interface{}) error
+	close() error
+}
+
+// Client is a live connection to one external MCP server.
+type Client struct {
+	t      transport
+	nextID int64
+	Server string // server name as the remote reported it
+}
+
+// Connect opens a transport and runs the initialize handshake.
+func Connect(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	var t transport
+	var err error
+	switch cfg.Transport {
+	case TransportHTTP:
+		t = newHTTPTransport(cfg.URL, cfg.Headers)
+	case TransportStdio, "":
+		t, err = startStdio(cfg.Command, cfg.Args, cfg.Env)
+	default:
+		return nil, fmt.Errorf("unknown MCP transport %q", cfg.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{t: t}
+	result, err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "openpaw", "version": "1.0"},
+	})
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	var init struct {
+		ServerInfo struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	json.Unmarshal(result, &init)
+	c.Server = init.ServerInfo.Name
+
+	if err := t.notify(ctx, "notifications/initialized", nil); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialized notification: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	return c.t.call(ctx, atomic.AddInt64(&c.nextID, 1), method, params)
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < 50; page++ {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		raw, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		tools = append(tools, res.Tools...)
+		if res.NextCursor == "" {
+			break
+		}
+		cursor = res.NextCursor
+	}
+	return tools, nil
+}
+
+// CallTool runs one tool. A tool that fails reports it in the result
+// (IsError); the error return is for the connection or protocol failing.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (CallResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	raw, err := c.call(ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args})
+	if err != nil {
+		return CallResult{}, err
+	}
+	var res struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource *struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return CallResult{}, fmt.Errorf("tools/call: %w", err)
+	}
+
+	var parts []string
+	for _, c := range res.Content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, c.Resource.Text)
+		case c.Resource != nil:
+			parts = append(parts, "[resource: "+c.Resource.URI+"]")
+		default:
+			// Images and audio can't be passed through as tool output text.
+			parts = append(parts, fmt.Sprintf("[%s content (%s) omitted]", c.Type, c.MimeType))
+		}
+	}
+	if len(parts) == 0 && len(res.StructuredContent) > 0 {
+		parts = append(parts, string(res.StructuredContent))
+	}
+	return CallResult{Text: strings.Join(parts, "\n"), IsError: res.IsError}, nil
+}
+
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+// --- stdio ---
+
+// stdioTransport speaks newline-delimited JSON-RPC over a child process's
+// stdin and stdout.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan clientResponse
+	done    chan struct{}
+}
+
+func startStdio(command string, args []string, env map[string]string) (*stdioTransport, error) {
+	if command == "" {
+		return nil, fmt.Errorf("stdio MCP server has no command")
+	}
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tailBuffer{max: 4096},
+		pending: map[string]chan clientResponse{},
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", command, err)
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer close(t.done)
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg clientResponse
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// Servers that log to stdout break the protocol; skip the noise.
+			continue
+		}
+		if msg.Method != "" {
+			t.answerServerRequest(msg)
+			continue
+		}
+		t.mu.Lock()
+		ch := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+	t.cmd.Wait()
+}
+
+// answerServerRequest turns down requests from the server (sampling, roots):
+// OpenPaw only consumes tools. Notifications need no answer.
+func (t *stdioTransport) answerServerRequest(msg clientResponse) {
+	if len(msg.ID) == 0 || string(msg.ID) == "null" {
+		return
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: -32601, Message: "not supported by this client"}}
+	t.write(resp)
+}
+
+func (t *stdioTransport) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, id int64, method string, params interface{}) (json.RawMessage, error) {
+	key := fmt.Sprint(id)
+	ch := make(chan clientResponse, 1)
+	t.mu.Lock()
+	t.pending[key] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+	}()
+
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	if err := t.write(req); err != nil {
+		return nil, t.closedErr()
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-t.done:
+		return nil, t.closedErr()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// closedErr reports the process exiting, with whatever it last wrote to
+// stderr — usually the only clue to a missing dependency or a bad key.
+func (t *stdioTransport) closedErr() error {
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		return fmt.Errorf("%w: %s", ErrClosed, tail)
+	}
+	return ErrClosed
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params interface{}) error {
+	req := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	return t.write(req)
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		if t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// --- streamable HTTP ---
+
+// httpTransport POSTs each message to the server's endpoint. The reply is
+// either plain JSON or an SSE stream carrying the response among any
+// notifications.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newHTTPTransport(url string, headers map[string]string) *httpTransport {
+	return &httpTransport{url: url, headers: headers, client: &http.Client{}}
+}
+
+func (t *httpTransport) post(ctx context.Context, msg map[string]interface{}) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && msg["method"] != "initialize" {
+		// The server dropped our session; the caller has to start over.
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: session expired", ErrClosed)
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		resp.Body.Close()
+		return nil, fmt.Errorf("MCP server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) call(ctx context.Context, id int64, method string, params interface{}) (json.RawMessage, error) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	want := fmt.Sprint(id)
+	var reply *clientResponse
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		reply, err = readSSEResponse(resp.Body, want)
+	} else {
+		var r clientResponse
+		err = json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&r)
+		reply = &r
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s response: %w", method, err)
+	}
+	if reply.Error != nil {
+		return nil, reply.Error
+	}
+	return reply.Result, nil
+}
+
+// readSSEResponse reads events until the response with the wanted ID.
+func readSSEResponse(body io.Reader, wantID string) (*clientResponse, error) {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64*1024), 32<<20)
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		var msg clientResponse
+		if json.Unmarshal([]byte(data.String()), &msg) == nil && msg.Method == "" && string(msg.ID) == wantID {
+			return &msg, nil
+		}
+		data.Reset()
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("stream ended without a response")
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if resp, err := t.client.Do(req.WithContext(ctx)); err == nil {
+		resp.Body.Close()
+	} else {
+		logger.Warn("MCP: closing session at %s: %v", t.url, err)
+	}
+	return nil
+}
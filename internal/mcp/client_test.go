@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The bridge is itself an MCP server, so the HTTP client is tested against it.
+func TestClientOverHTTP(t *testing.T) {
+	srv, _, session := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := Connect(ctx, ServerConfig{Transport: TransportHTTP, URL: srv.URL + "/mcp/" + session.Token})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close()
+	if c.Server != "openpaw" {
+		t.Errorf("server name = %q, want openpaw", c.Server)
+	}
+
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "save_note" {
+		t.Fatalf("ListTools = %+v, %v; want save_note", tools, err)
+	}
+	res, err := c.CallTool(ctx, "save_note", json.RawMessage(`{"note":"hi"}`))
+	if err != nil || res.Text != "saved: hi" || res.IsError {
+		t.Fatalf("CallTool = %+v, %v; want saved: hi", res, err)
+	}
+}
+
+// TestStdioHelperServer is not a test: it is the stdio MCP server that
+// TestClientOverStdio runs, by re-executing the test binary.
+func TestStdioHelperServer(t *testing.T) {
+	if os.Getenv("OPENPAW_MCP_HELPER") != "1" {
+		return
+	}
+	// Servers that log to stdout are common; the client must skip the noise.
+	fmt.Println("starting helper server")
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var req rpcRequest
+		if json.Unmarshal(sc.Bytes(), &req) != nil || len(req.ID) == 0 {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		switch req.Method {
+		case "initialize":
+			resp.Result = map[string]interface{}{"serverInfo": map[string]string{"name": "helper"}}
+		case "tools/list":
+			resp.Result = map[string]interface{}{"tools": []mcpTool{{Name: "echo", InputSchema: json.RawMessage(`{"type":"object"}`)}}}
+		case "tools/call":
+			var call struct {
+				Arguments struct {
+					Text string `json:"text"`
+				} `json:"arguments"`
+			}
+			json.Unmarshal(req.Params, &call)
+			if call.Arguments.Text == "quit" {
+				fmt.Fprintln(os.Stderr, "helper: told to quit")
+				os.Exit(1)
+			}
+			resp.Result = map[string]interface{}{
+				"content": []textContent{{Type: "text", Text: os.Getenv("ECHO_PREFIX") + call.Arguments.Text}},
+			}
+		default:
+			resp.Error = &rpcError{Code: -32601, Message: "unsupported"}
+		}
+		data, _ := json.Marshal(resp)
+		fmt.Println(string(data))
+	}
+	os.Exit(0)
+}
+
+func TestClientOverStdio(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cfg := ServerConfig{
+		Transport: TransportStdio,
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=^TestStdioHelperServer$"},
+		Env:       map[string]string{"OPENPAW_MCP_HELPER": "1", "ECHO_PREFIX": "echo: "},
+	}
+	c, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatalf("ListTools = %+v, %v; want echo", tools, err)
+	}
+	res, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hello"}`))
+	if err != nil || res.Text != "echo: hello" {
+		t.Fatalf("CallTool = %+v, %v; want the prefix from the injected env", res, err)
+	}
+
+	// A server that dies reports it, with its last stderr output.
+	_, err = c.CallTool(ctx, "echo", json.RawMessage(`{"text":"quit"}`))
+	if err == nil || !strings.Contains(err.Error(), "told to quit") {
+		t.Errorf("call to a dead server = %v, want ErrClosed with its stderr", err)
+	}
+}
+
+func TestExposedToolName(t *testing.T) {
+	if got := ExposedToolName("github", "create.issue"); got != "mcp_github__create_issue" {
+		t.Errorf("ExposedToolName = %q", got)
+	}
+	a := ExposedToolName("a-long-server-name-here", "list_pull_request_review_comments_for_repo")
+	b := ExposedToolName("a-long-server-name-here", "list_pull_request_review_comments_for_org")
+	if len(a) > maxToolName || len(b) > maxToolName || a == b {
+		t.Errorf("long names %q and %q must be distinct and at most %d characters", a, b, maxToolName)
+	}
+}
@@ -0,0 +1,230 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	llm "github.com/openpaw/openpaw/internal/llm"
+)
+
+// sseKeepalive is how often a streamed response sends a comment while a slow
+// tool runs, so proxies and clients don't give up on a quiet connection.
+const sseKeepalive = 15 * time.Second
+
+// Resource is a readable document listed by resources/list.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is a resource's body as returned by resources/read.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// Prompt is a reusable prompt listed by prompts/list.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is one message of a rendered prompt.
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content textContent `json:"content"`
+}
+
+// NewPromptMessage builds a text prompt message.
+func NewPromptMessage(role, text string) PromptMessage {
+	return PromptMessage{Role: role, Content: textContent{Type: "text", Text: text}}
+}
+
+// PromptResult is a rendered prompt as returned by prompts/get.
+type PromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// ErrNotFound is returned by a Catalog for an unknown resource or prompt.
+var ErrNotFound = fmt.Errorf("not found")
+
+// Catalog is what the persistent endpoint serves. It is asked afresh on every
+// request, so each call sees the caller's own role, workspace and the
+// currently enabled capabilities.
+type Catalog interface {
+	Tools(r *http.Request) ([]llm.ToolDef, map[string]llm.ToolHandler)
+	Resources(r *http.Request) ([]Resource, error)
+	ReadResource(r *http.Request, uri string) (*ResourceContents, error)
+	Prompts(r *http.Request) ([]Prompt, error)
+	GetPrompt(r *http.Request, name string, args map[string]string) (*PromptResult, error)
+}
+
+// Endpoint serves OpenPaw as a long-lived MCP server for outside clients —
+// Claude Desktop, Cursor, another OpenPaw. Unlike the per-run bridge it has
+// no token of its own: it sits behind the normal API auth, so a personal
+// access token is the credential and revoking it cuts the client off.
+//
+// Responses are streamed as SSE when the client accepts it, which is what
+// lets a long tools/call survive without the connection looking dead.
+func Endpoint(catalog Catalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodPost:
+			servePost(w, req, catalog)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		default:
+			// No server-initiated messages, so no standalone GET stream; the
+			// spec allows 405 here.
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func servePost(w http.ResponseWriter, req *http.Request, catalog Catalog) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "read error", http.StatusBadRequest)
+		return
+	}
+	var rpc rpcRequest
+	if err := json.Unmarshal(body, &rpc); err != nil {
+		writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: -32700, Message: "parse error"}})
+		return
+	}
+	if len(rpc.ID) == 0 || string(rpc.ID) == "null" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if !strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		writeRPC(w, dispatch(req, catalog, rpc))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeRPC(w, dispatch(req, catalog, rpc))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	done := make(chan rpcResponse, 1)
+	go func() { done <- dispatch(req, catalog, rpc) }()
+	ticker := time.NewTicker(sseKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case resp := <-done:
+			data, _ := json.Marshal(resp)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func dispatch(req *http.Request, catalog Catalog, rpc rpcRequest) rpcResponse {
+	resp := rpcResponse{JSONRPC: "2.0", ID: rpc.ID}
+	notFound := func(err error, what string) *rpcError {
+		if err == ErrNotFound {
+			return &rpcError{Code: -32602, Message: what + " not found"}
+		}
+		return &rpcError{Code: -32603, Message: err.Error()}
+	}
+
+	switch rpc.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(rpc.Params, &params)
+		version := params.ProtocolVersion
+		if version == "" {
+			version = protocolVersion
+		}
+		resp.Result = map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities": map[string]interface{}{
+				"tools":     map[string]interface{}{},
+				"resources": map[string]interface{}{},
+				"prompts":   map[string]interface{}{},
+			},
+			"serverInfo": map[string]interface{}{"name": "openpaw", "version": "1.0"},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		defs, _ := catalog.Tools(req)
+		resp.Result = map[string]interface{}{"tools": publishTools(defs)}
+	case "tools/call":
+		_, handlers := catalog.Tools(req)
+		resp.Result, resp.Error = callTool(req.Context(), handlers, "", "mcp-client", rpc.Params, nil)
+	case "resources/list":
+		list, err := catalog.Resources(req)
+		if err != nil {
+			resp.Error = notFound(err, "resources")
+			break
+		}
+		resp.Result = map[string]interface{}{"resources": list}
+	case "resources/templates/list":
+		resp.Result = map[string]interface{}{"resourceTemplates": []interface{}{}}
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(rpc.Params, &params)
+		contents, err := catalog.ReadResource(req, params.URI)
+		if err != nil {
+			resp.Error = notFound(err, "resource "+params.URI)
+			break
+		}
+		resp.Result = map[string]interface{}{"contents": []*ResourceContents{contents}}
+	case "prompts/list":
+		list, err := catalog.Prompts(req)
+		if err != nil {
+			resp.Error = notFound(err, "prompts")
+			break
+		}
+		resp.Result = map[string]interface{}{"prompts": list}
+	case "prompts/get":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		json.Unmarshal(rpc.Params, &params)
+		result, err := catalog.GetPrompt(req, params.Name, params.Arguments)
+		if err != nil {
+			resp.Error = notFound(err, "prompt "+params.Name)
+			break
+		}
+		resp.Result = result
+	default:
+		resp.Error = &rpcError{Code: -32601, Message: fmt.Sprintf("method %q not supported", rpc.Method)}
+	}
+	return resp
+}
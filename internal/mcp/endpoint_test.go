@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	llm "github.com/openpaw/openpaw/internal/llm"
+)
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) Tools(r *http.Request) ([]llm.ToolDef, map[string]llm.ToolHandler) {
+	defs := []llm.ToolDef{{Type: "function", Function: llm.FunctionDef{Name: "whoami"}}}
+	handlers := map[string]llm.ToolHandler{
+		"whoami": func(context.Context, string, json.RawMessage) llm.ToolResult {
+			return llm.ToolResult{Output: "workspace " + r.URL.Query().Get("workspace")}
+		},
+	}
+	return defs, handlers
+}
+
+func (fakeCatalog) Resources(*http.Request) ([]Resource, error) {
+	return []Resource{{URI: "openpaw://context/a", Name: "A"}}, nil
+}
+
+func (fakeCatalog) ReadResource(_ *http.Request, uri string) (*ResourceContents, error) {
+	if uri != "openpaw://context/a" {
+		return nil, ErrNotFound
+	}
+	return &ResourceContents{URI: uri, Text: "alpha"}, nil
+}
+
+func (fakeCatalog) Prompts(*http.Request) ([]Prompt, error) { return nil, nil }
+
+func (fakeCatalog) GetPrompt(_ *http.Request, name string, args map[string]string) (*PromptResult, error) {
+	return &PromptResult{Messages: []PromptMessage{NewPromptMessage("user", name+": "+args["task"])}}, nil
+}
+
+// The client asks for SSE, so this covers the streamed response path.
+func TestEndpointServesClient(t *testing.T) {
+	srv := httptest.NewServer(Endpoint(fakeCatalog{}))
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := Connect(ctx, ServerConfig{Transport: TransportHTTP, URL: srv.URL + "?workspace=ws1"})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close()
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "whoami" {
+		t.Fatalf("ListTools = %+v, %v; want whoami", tools, err)
+	}
+	res, err := c.CallTool(ctx, "whoami", nil)
+	if err != nil || res.Text != "workspace ws1" {
+		t.Fatalf("CallTool = %+v, %v; want the tools resolved against the request", res, err)
+	}
+}
+
+func TestEndpointResourcesAndPrompts(t *testing.T) {
+	srv := httptest.NewServer(Endpoint(fakeCatalog{}))
+	t.Cleanup(srv.Close)
+
+	read := rpcCall(t, srv.URL, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"openpaw://context/a"}}`)
+	contents := read["result"].(map[string]interface{})["contents"].([]interface{})
+	if text := contents[0].(map[string]interface{})["text"]; text != "alpha" {
+		t.Errorf("resources/read text = %v, want alpha", text)
+	}
+
+	missing := rpcCall(t, srv.URL, `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"openpaw://context/b"}}`)
+	if missing["error"] == nil {
+		t.Error("reading an unknown resource should be an error")
+	}
+
+	prompt := rpcCall(t, srv.URL, `{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"scout","arguments":{"task":"find flights"}}}`)
+	msgs := prompt["result"].(map[string]interface{})["messages"].([]interface{})
+	content := msgs[0].(map[string]interface{})["content"].(map[string]interface{})
+	if content["text"] != "scout: find flights" {
+		t.Errorf("prompts/get text = %v", content["text"])
+	}
+}
@@ -0,0 +1,425 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+	llm "github.com/openpaw/openpaw/internal/llm"
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+const (
+	// connectTimeout bounds starting a server and the initialize handshake.
+	// Generous because the usual stdio server is an npx or uvx command that
+	// may download its package on first start.
+	connectTimeout = 60 * time.Second
+	// callTimeout bounds a single tool call.
+	callTimeout = 2 * time.Minute
+	// maxToolName keeps exposed names inside provider limits (64 characters)
+	// even after a CLI engine prefixes them with its own bridge name.
+	maxToolName = 48
+)
+
+// Grant scopes for mcp_server_grants.
+const (
+	GrantAgent     = "agent"
+	GrantWorkspace = "workspace"
+)
+
+var serverNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,23}$`)
+
+// ValidServerName reports whether name can prefix tool names: lowercase
+// letters, digits, - and _, at most 24 characters.
+func ValidServerName(name string) bool {
+	return serverNameRe.MatchString(name)
+}
+
+// Server is an external MCP server as stored. Env and Headers map a variable
+// or header name to the name of the secret holding its value.
+type Server struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Transport       string            `json:"transport"`
+	Command         string            `json:"command"`
+	Args            []string          `json:"args"`
+	URL             string            `json:"url"`
+	Env             map[string]string `json:"env"`
+	Headers         map[string]string `json:"headers"`
+	Enabled         bool              `json:"enabled"`
+	Tools           []Tool            `json:"tools"`
+	LastError       string            `json:"last_error"`
+	LastConnectedAt *time.Time        `json:"last_connected_at"`
+	Connected       bool              `json:"connected"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// ServerConfig is what Connect needs: a server with its secrets resolved.
+type ServerConfig struct {
+	Transport string
+	Command   string
+	Args      []string
+	URL       string
+	Env       map[string]string
+	Headers   map[string]string
+}
+
+// SecretDecryptor decrypts stored secret values. Satisfied by secrets.Manager.
+type SecretDecryptor interface {
+	Decrypt(encrypted string) (string, error)
+}
+
+// Clients owns the connections to external MCP servers and turns their tools
+// into agent tools. Connections are opened on first use and kept; a server
+// that dies is reconnected on the next call.
+type Clients struct {
+	db      *database.DB
+	secrets SecretDecryptor
+
+	mu    sync.Mutex
+	conns map[string]*Client
+}
+
+func NewClients(db *database.DB, secrets SecretDecryptor) *Clients {
+	return &Clients{db: db, secrets: secrets, conns: map[string]*Client{}}
+}
+
+const serverColumns = `id, name, description, transport, command, args, url, env, headers, enabled,
+	tools_json, last_error, last_connected_at, created_at, updated_at`
+
+func scanServer(row interface{ Scan(...interface{}) error }) (*Server, error) {
+	var s Server
+	var args, env, headers, tools string
+	var connected sql.NullTime
+	if err := row.Scan(&s.ID, &s.Name, &s.Description, &s.Transport, &s.Command, &args, &s.URL, &env, &headers,
+		&s.Enabled, &tools, &s.LastError, &connected, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(args), &s.Args)
+	json.Unmarshal([]byte(env), &s.Env)
+	json.Unmarshal([]byte(headers), &s.Headers)
+	json.Unmarshal([]byte(tools), &s.Tools)
+	if s.Args == nil {
+		s.Args = []string{}
+	}
+	if s.Env == nil {
+		s.Env = map[string]string{}
+	}
+	if s.Headers == nil {
+		s.Headers = map[string]string{}
+	}
+	if s.Tools == nil {
+		s.Tools = []Tool{}
+	}
+	if connected.Valid {
+		s.LastConnectedAt = &connected.Time
+	}
+	return &s, nil
+}
+
+// Get loads one server.
+func (m *Clients) Get(id string) (*Server, error) {
+	s, err := scanServer(m.db.QueryRow("SELECT "+serverColumns+" FROM mcp_servers WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	s.Connected = m.isConnected(id)
+	return s, nil
+}
+
+// List loads every server.
+func (m *Clients) List() ([]*Server, error) {
+	rows, err := m.db.Query("SELECT " + serverColumns + " FROM mcp_servers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	servers := []*Server{}
+	for rows.Next() {
+		s, err := scanServer(rows)
+		if err != nil {
+			logger.Warn("scan MCP server: %v", err)
+			continue
+		}
+		s.Connected = m.isConnected(s.ID)
+		servers = append(servers, s)
+	}
+	return servers, rows.Err()
+}
+
+func (m *Clients) isConnected(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conns[id] != nil
+}
+
+// resolve turns a stored server into a connectable config by decrypting the
+// secrets its env and headers name.
+func (m *Clients) resolve(s *Server) (ServerConfig, error) {
+	cfg := ServerConfig{
+		Transport: s.Transport,
+		Command:   s.Command,
+		Args:      s.Args,
+		URL:       s.URL,
+		Env:       map[string]string{},
+		Headers:   map[string]string{},
+	}
+	for dst, src := range map[*map[string]string]map[string]string{&cfg.Env: s.Env, &cfg.Headers: s.Headers} {
+		for key, secretName := range src {
+			value, err := m.secretValue(secretName)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", key, err)
+			}
+			(*dst)[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+func (m *Clients) secretValue(name string) (string, error) {
+	var encrypted string
+	if err := m.db.QueryRow("SELECT encrypted_value FROM secrets WHERE name = ?", name).Scan(&encrypted); err != nil {
+		return "", fmt.Errorf("secret %q not found — add it in Settings → Secrets", name)
+	}
+	if m.secrets == nil {
+		return "", fmt.Errorf("secret %q cannot be decrypted: no secrets manager", name)
+	}
+	value, err := m.secrets.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("secret %q could not be decrypted", name)
+	}
+	if value == "REPLACE_ME" {
+		return "", fmt.Errorf("secret %q is still a REPLACE_ME placeholder", name)
+	}
+	return value, nil
+}
+
+// client returns the live connection to a server, connecting if needed.
+func (m *Clients) client(ctx context.Context, id string) (*Client, error) {
+	m.mu.Lock()
+	c := m.conns[id]
+	m.mu.Unlock()
+	if c != nil {
+		return c, nil
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("MCP server not found")
+	}
+	if !s.Enabled {
+		return nil, fmt.Errorf("MCP server %s is disabled", s.Name)
+	}
+	cfg, err := m.resolve(s)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	c, err = Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Another caller may have connected meanwhile; keep theirs.
+	if existing := m.conns[id]; existing != nil {
+		c.Close()
+		return existing, nil
+	}
+	m.conns[id] = c
+	return c, nil
+}
+
+// Disconnect closes a server's connection, if open. Called when a server is
+// edited or deleted, so the next use picks up the new settings.
+func (m *Clients) Disconnect(id string) {
+	m.mu.Lock()
+	c := m.conns[id]
+	delete(m.conns, id)
+	m.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// Close disconnects every server.
+func (m *Clients) Close() {
+	m.mu.Lock()
+	conns := m.conns
+	m.conns = map[string]*Client{}
+	m.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// Refresh reconnects a server and re-reads its tools, recording the result
+// (or the failure) on the server row.
+func (m *Clients) Refresh(ctx context.Context, id string) ([]Tool, error) {
+	m.Disconnect(id)
+	tools, err := m.listTools(ctx, id)
+	now := time.Now().UTC()
+	if err != nil {
+		m.db.Exec("UPDATE mcp_servers SET last_error = ? WHERE id = ?", err.Error(), id)
+		return nil, err
+	}
+	data, _ := json.Marshal(tools)
+	m.db.Exec("UPDATE mcp_servers SET tools_json = ?, last_error = '', last_connected_at = ? WHERE id = ?", string(data), now, id)
+	return tools, nil
+}
+
+func (m *Clients) listTools(ctx context.Context, id string) ([]Tool, error) {
+	c, err := m.client(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		m.Disconnect(id)
+		return nil, err
+	}
+	if tools == nil {
+		tools = []Tool{}
+	}
+	return tools, nil
+}
+
+// RefreshAll refreshes every enabled server, so tool lists are current after
+// a restart. Failures are recorded on the servers and logged.
+func (m *Clients) RefreshAll(ctx context.Context) {
+	rows, err := m.db.Query("SELECT id, name FROM mcp_servers WHERE enabled = 1")
+	if err != nil {
+		return
+	}
+	type idName struct{ id, name string }
+	var servers []idName
+	for rows.Next() {
+		var s idName
+		if rows.Scan(&s.id, &s.name) == nil {
+			servers = append(servers, s)
+		}
+	}
+	rows.Close()
+	for _, s := range servers {
+		if tools, err := m.Refresh(ctx, s.id); err != nil {
+			logger.Warn("MCP server %s: %v", s.name, err)
+		} else {
+			logger.Info("MCP server %s connected: %d tools", s.name, len(tools))
+		}
+	}
+}
+
+// Call runs a tool on a server. A connection that has died is reopened once.
+func (m *Clients) Call(ctx context.Context, id, tool string, args json.RawMessage) (CallResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		c, err := m.client(ctx, id)
+		if err != nil {
+			return CallResult{}, err
+		}
+		res, err := c.CallTool(ctx, tool, args)
+		if errors.Is(err, ErrClosed) && attempt == 0 {
+			m.Disconnect(id)
+			continue
+		}
+		return res, err
+	}
+}
+
+// ExposedToolName is the name an external tool is offered to agents under:
+// prefixed with its server so two servers' "search" tools don't collide, and
+// cut to a length every provider accepts.
+func ExposedToolName(server, tool string) string {
+	var b strings.Builder
+	for _, r := range tool {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	name := "mcp_" + server + "__" + b.String()
+	if len(name) > maxToolName {
+		sum := sha1.Sum([]byte(server + "/" + tool))
+		name = name[:maxToolName-8] + "_" + hex.EncodeToString(sum[:])[:7]
+	}
+	return name
+}
+
+// ToolsFor returns the external tools granted to an agent — directly, or
+// through the workspace it is running in — as tool definitions and handlers
+// for the agent loop. Tool lists come from the last refresh, so this never
+// waits on a server; the connection opens on the first call.
+func (m *Clients) ToolsFor(agentSlug, workspaceID string) ([]llm.ToolDef, map[string]llm.ToolHandler) {
+	rows, err := m.db.Query(
+		`SELECT `+serverColumns+` FROM mcp_servers s
+		 WHERE s.enabled = 1 AND EXISTS (
+		     SELECT 1 FROM mcp_server_grants g WHERE g.server_id = s.id
+		       AND ((g.scope_type = 'agent' AND g.scope_id = ?) OR (g.scope_type = 'workspace' AND g.scope_id = ?)))
+		 ORDER BY s.name`,
+		agentSlug, workspaceID,
+	)
+	if err != nil {
+		logger.Error("Failed to load MCP servers for %s: %v", agentSlug, err)
+		return nil, nil
+	}
+	var servers []*Server
+	for rows.Next() {
+		if s, err := scanServer(rows); err == nil {
+			servers = append(servers, s)
+		}
+	}
+	rows.Close()
+
+	var defs []llm.ToolDef
+	handlers := map[string]llm.ToolHandler{}
+	for _, s := range servers {
+		for _, t := range s.Tools {
+			name := ExposedToolName(s.Name, t.Name)
+			if _, dup := handlers[name]; dup {
+				continue
+			}
+			desc := t.Description
+			if desc == "" {
+				desc = t.Name
+			}
+			defs = append(defs, llm.ToolDef{
+				Type: "function",
+				Function: llm.FunctionDef{
+					Name:        name,
+					Description: "[" + s.Name + " MCP] " + desc,
+					Parameters:  normalizeSchema(t.InputSchema),
+				},
+			})
+			handlers[name] = m.toolHandler(s.ID, s.Name, t.Name, agentSlug)
+		}
+	}
+	return defs, handlers
+}
+
+func (m *Clients) toolHandler(serverID, serverName, tool, agentSlug string) llm.ToolHandler {
+	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
+		logger.Info("MCP client call: %s/%s (agent=%s)", serverName, tool, agentSlug)
+		res, err := m.Call(ctx, serverID, tool, input)
+		if err != nil {
+			return llm.ToolResult{Output: fmt.Sprintf("MCP server %s failed: %v", serverName, err), IsError: true}
+		}
+		return llm.ToolResult{Output: res.Text, IsError: res.IsError}
+	}
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+func TestToolsForFollowsGrants(t *testing.T) {
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`INSERT INTO mcp_servers (id, name, command, tools_json) VALUES ('s1', 'github', 'gh-mcp', '[{"name":"create_issue","description":"Open an issue"}]')`,
+		`INSERT INTO mcp_servers (id, name, command, tools_json) VALUES ('s2', 'notion', 'notion-mcp', '[{"name":"search"}]')`,
+		`INSERT INTO mcp_servers (id, name, command, tools_json, enabled) VALUES ('s3', 'off', 'x', '[{"name":"t"}]', 0)`,
+		`INSERT INTO mcp_server_grants (id, server_id, scope_type, scope_id) VALUES ('g1', 's1', 'agent', 'scout')`,
+		`INSERT INTO mcp_server_grants (id, server_id, scope_type, scope_id) VALUES ('g2', 's2', 'workspace', 'ws1')`,
+		`INSERT INTO mcp_server_grants (id, server_id, scope_type, scope_id) VALUES ('g3', 's3', 'agent', 'scout')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	m := NewClients(db, nil)
+
+	names := func(agent, ws string) map[string]bool {
+		defs, handlers := m.ToolsFor(agent, ws)
+		out := map[string]bool{}
+		for _, d := range defs {
+			if handlers[d.Function.Name] == nil {
+				t.Errorf("%s has no handler", d.Function.Name)
+			}
+			out[d.Function.Name] = true
+		}
+		return out
+	}
+
+	if got := names("scout", "ws2"); len(got) != 1 || !got["mcp_github__create_issue"] {
+		t.Errorf("scout outside ws1 = %v, want only its own github grant", got)
+	}
+	if got := names("scout", "ws1"); len(got) != 2 || !got["mcp_notion__search"] {
+		t.Errorf("scout in ws1 = %v, want github and the workspace's notion", got)
+	}
+	if got := names("other", "ws2"); len(got) != 0 {
+		t.Errorf("ungranted agent = %v, want nothing (disabled servers never count)", got)
+	}
+}
@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedding backends. OpenRouter proxies the hosted embedding models; the
+// OpenAI-compatible backend covers Ollama, LM Studio, vLLM and llama.cpp, which
+// all serve POST /v1/embeddings.
+const (
+	EmbeddingBackendNone       = ""
+	EmbeddingBackendOpenRouter = "openrouter"
+	EmbeddingBackendCompat     = "openai_compatible"
+)
+
+// Default models per backend, used when none is configured.
+const (
+	DefaultOpenRouterEmbeddingModel = "openai/text-embedding-3-small"
+	DefaultCompatEmbeddingModel     = "nomic-embed-text"
+)
+
+const openRouterBaseURL = "https://openrouter.ai/api/v1"
+
+// Embedder turns text into vectors for semantic memory search. Model names the
+// vector space: embeddings from different models are not comparable, so every
+// stored vector records the model that produced it.
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingConfig is the saved embedding backend configuration.
+type EmbeddingConfig struct {
+	Backend string `json:"backend"`
+	// BaseURL is only used by the OpenAI-compatible backend, e.g.
+	// http://localhost:11434/v1 for Ollama.
+	BaseURL string `json:"base_url"`
+	Model   string `json:"model"`
+	// APIKey is the OpenRouter key for that backend, or the optional key for a
+	// self-hosted server.
+	APIKey string `json:"-"`
+}
+
+// Embedder returns the configured backend, or nil when semantic search is off
+// or not configured enough to run.
+func (c EmbeddingConfig) Embedder() Embedder {
+	switch c.Backend {
+	case EmbeddingBackendOpenRouter:
+		if c.APIKey == "" {
+			return nil
+		}
+		model := c.Model
+		if model == "" {
+			model = DefaultOpenRouterEmbeddingModel
+		}
+		return &HTTPEmbedder{BaseURL: openRouterBaseURL, APIKey: c.APIKey, ModelName: model, openRouter: true}
+	case EmbeddingBackendCompat:
+		base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+		if base == "" {
+			return nil
+		}
+		model := c.Model
+		if model == "" {
+			model = DefaultCompatEmbeddingModel
+		}
+		return &HTTPEmbedder{BaseURL: base, APIKey: c.APIKey, ModelName: model}
+	}
+	return nil
+}
+
+// HTTPEmbedder calls an OpenAI-style /embeddings endpoint.
+type HTTPEmbedder struct {
+	BaseURL    string
+	APIKey     string
+	ModelName  string
+	HTTPClient *http.Client
+
+	openRouter bool
+}
+
+func (e *HTTPEmbedder) Model() string { return e.ModelName }
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"model": e.ModelName,
+		"input": texts,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	if e.openRouter {
+		req.Header.Set("HTTP-Referer", "https://openpaw.dev")
+		req.Header.Set("X-Title", "OpenPaw")
+	}
+
+	client := e.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed (status %d): %s", resp.StatusCode, truncateText(string(data), 300))
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("parse embedding response: %w", err)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+	// Vectors are matched to inputs by index, not position, so each index
+	// must name a distinct input and every input must get a vector.
+	out := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		switch {
+		case d.Index < 0 || d.Index >= len(out):
+			return nil, fmt.Errorf("embedding response has index %d for %d inputs", d.Index, len(texts))
+		case out[d.Index] != nil:
+			return nil, fmt.Errorf("embedding response has index %d twice", d.Index)
+		case len(d.Embedding) == 0:
+			return nil, fmt.Errorf("embedding response has an empty vector at index %d", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if v == nil {
+			return nil, fmt.Errorf("embedding response has no vector for input %d", i)
+		}
+	}
+	return out, nil
+}
+
+// embeddingText is what gets embedded for a memory. Tags go in because they
+// are often the only place a topic is named outright.
+func embeddingText(content, summary, tags string) string {
+	text := strings.TrimSpace(content)
+	if s := strings.TrimSpace(summary); s != "" && s != text {
+		text = s + "\n" + text
+	}
+	if t := strings.TrimSpace(tags); t != "" {
+		text += "\nTags: " + t
+	}
+	return text
+}
+
+// contentHash fingerprints the embedded text, so an edited memory is noticed
+// and re-embedded.
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:16])
+}
+
+// EncodeVector packs a vector as little-endian float32s for a BLOB column.
+func EncodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func DecodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when they differ
+// in length or either is zero.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
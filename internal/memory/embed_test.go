@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Vectors come back keyed by index; a response that skips, repeats or
+// invents an index must fail rather than leave an input without a vector.
+func TestHTTPEmbedderChecksIndexes(t *testing.T) {
+	var reply string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, reply)
+	}))
+	defer srv.Close()
+	e := &HTTPEmbedder{BaseURL: srv.URL, ModelName: "test"}
+
+	reply = `{"data":[{"index":1,"embedding":[0.2]},{"index":0,"embedding":[0.1]}]}`
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vecs) != 2 || vecs[0][0] != 0.1 || vecs[1][0] != 0.2 {
+		t.Fatalf("Embed = %v, %v; want vectors in input order", vecs, err)
+	}
+
+	for name, bad := range map[string]string{
+		"duplicate index": `{"data":[{"index":0,"embedding":[0.1]},{"index":0,"embedding":[0.2]}]}`,
+		"out of range":    `{"data":[{"index":0,"embedding":[0.1]},{"index":2,"embedding":[0.2]}]}`,
+		"empty vector":    `{"data":[{"index":0,"embedding":[0.1]},{"index":1,"embedding":[]}]}`,
+		"too few":         `{"data":[{"index":0,"embedding":[0.1]}]}`,
+	} {
+		reply = bad
+		if _, err := e.Embed(context.Background(), []string{"a", "b"}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+const (
+	// embedBatch is how many memories go to the backend per request.
+	embedBatch = 32
+	// indexInterval is how often the indexer sweeps every agent even when
+	// nothing woke it — it catches memories written by paths that don't kick
+	// (imports, restores, direct SQL).
+	indexInterval = 10 * time.Minute
+)
+
+// SetEmbedder installs the embedding backend resolver. A resolver returning
+// nil turns semantic search off; keyword search is unaffected either way.
+func (m *Manager) SetEmbedder(fn func() Embedder) {
+	m.embedder = fn
+}
+
+// currentEmbedder returns the configured backend, or nil.
+func (m *Manager) currentEmbedder() Embedder {
+	if m.embedder == nil {
+		return nil
+	}
+	return m.embedder()
+}
+
+// kickIndexer wakes the background indexer so a memory just written becomes
+// searchable by meaning within seconds rather than at the next sweep.
+func (m *Manager) kickIndexer() {
+	select {
+	case m.kick <- struct{}{}:
+	default:
+	}
+}
+
+// StartEmbeddingIndexer embeds memories in the background: everything stored
+// before semantic search was configured on the first sweep, then whatever is
+// added or edited. It runs until Close.
+func (m *Manager) StartEmbeddingIndexer() {
+	go func() {
+		// Let startup finish before the first sweep competes with it.
+		timer := time.NewTimer(30 * time.Second)
+		defer timer.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-timer.C:
+			case <-m.kick:
+			}
+			m.indexAll()
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(indexInterval)
+		}
+	}()
+}
+
+// indexAll brings every agent's embeddings up to date.
+func (m *Manager) indexAll() {
+	e := m.currentEmbedder()
+	if e == nil {
+		return
+	}
+	for _, slug := range m.knownSlugs() {
+		for {
+			select {
+			case <-m.stop:
+				return
+			default:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			n, err := m.IndexEmbeddings(ctx, slug, e)
+			cancel()
+			if err != nil {
+				logger.Warn("Memory embedding for %s: %v", slug, err)
+				break
+			}
+			if n < embedBatch {
+				break
+			}
+		}
+	}
+}
+
+// knownSlugs lists every agent with a memory database on disk, plus the
+// gateway and the shared scopes.
+func (m *Manager) knownSlugs() []string {
+	var slugs []string
+	if _, err := os.Stat(m.dbPath("gateway")); err == nil {
+		slugs = append(slugs, "gateway")
+	}
+	entries, _ := os.ReadDir(filepath.Join(m.dataDir, "agents"))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(m.dbPath(e.Name())); err == nil {
+			slugs = append(slugs, e.Name())
+		}
+	}
+	entries, _ = os.ReadDir(filepath.Join(m.dataDir, "shared"))
+	for _, e := range entries {
+		key := SharedKey(e.Name())
+		if _, ok := sharedScopeID(key); !ok || !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(m.dbPath(key)); err == nil {
+			slugs = append(slugs, key)
+		}
+	}
+	return slugs
+}
+
+// IndexEmbeddings embeds up to one batch of an agent's memories that have no
+// vector for the backend's model, or whose text changed since theirs was made,
+// and returns how many it embedded. Archived memories are included so
+// include_archived searches still find them by meaning.
+func (m *Manager) IndexEmbeddings(ctx context.Context, slug string, e Embedder) (int, error) {
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Query(
+		`SELECT m.id, m.content, m.summary, m.tags, COALESCE(e.model, ''), COALESCE(e.content_hash, '')
+		 FROM memories m LEFT JOIN memory_embeddings e ON e.memory_id = m.id
+		 ORDER BY m.importance DESC, m.created_at DESC`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids, texts, hashes []string
+	for rows.Next() && len(ids) < embedBatch {
+		var id, content, summary, tags, model, hash string
+		if rows.Scan(&id, &content, &summary, &tags, &model, &hash) != nil {
+			continue
+		}
+		text := embeddingText(content, summary, tags)
+		h := contentHash(text)
+		if model == e.Model() && hash == h {
+			continue
+		}
+		ids = append(ids, id)
+		texts = append(texts, text)
+		hashes = append(hashes, h)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	vectors, err := e.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != len(texts) {
+		return 0, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	now := time.Now().UTC().Format(sqlTime)
+	for i, id := range ids {
+		if _, err := db.Exec(
+			`INSERT INTO memory_embeddings (memory_id, model, dims, vector, content_hash, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(memory_id) DO UPDATE SET model = excluded.model, dims = excluded.dims,
+			     vector = excluded.vector, content_hash = excluded.content_hash, updated_at = excluded.updated_at`,
+			id, e.Model(), len(vectors[i]), EncodeVector(vectors[i]), hashes[i], now,
+		); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
@@ -16,23 +16,47 @@ import (
 type Manager struct {
 	dataDir string
 	dbs     sync.Map // map[slug]*sql.DB
+
+	// embedder resolves the embedding backend on every use, so configuring
+	// one in Settings takes effect without a restart. Nil means keyword-only.
+	embedder func() Embedder
+	// scopes resolves the shared memory scopes an agent can reach. Nil means
+	// agents only have their own memory.
+	scopes   func(agentSlug string) []Scope
+	kick     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func NewManager(dataDir string) *Manager {
-	return &Manager{dataDir: dataDir}
+	return &Manager{
+		dataDir: dataDir,
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
 }
 
 func (m *Manager) dbPath(slug string) string {
 	if slug == "gateway" {
 		return filepath.Join(m.dataDir, "gateway", "memory.db")
 	}
+	if id, ok := sharedScopeID(slug); ok {
+		return filepath.Join(m.dataDir, "shared", id, "memory.db")
+	}
 	return filepath.Join(m.dataDir, "agents", slug, "memory.db")
 }
 
+// GetDB opens a memory database: an agent's own, the gateway's, or a shared
+// scope's when slug is a SharedKey.
 func (m *Manager) GetDB(slug string) (*sql.DB, error) {
 	if v, ok := m.dbs.Load(slug); ok {
 		return v.(*sql.DB), nil
 	}
+	if strings.HasPrefix(slug, sharedPrefix) {
+		if _, ok := sharedScopeID(slug); !ok {
+			return nil, fmt.Errorf("invalid shared memory scope %q", slug)
+		}
+	}
 
 	dbPath := m.dbPath(slug)
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
@@ -59,6 +83,7 @@ func (m *Manager) GetDB(slug string) (*sql.DB, error) {
 }
 
 func (m *Manager) Close() {
+	m.stopOnce.Do(func() { close(m.stop) })
 	m.dbs.Range(func(key, value interface{}) bool {
 		if db, ok := value.(*sql.DB); ok {
 			db.Close()
@@ -113,6 +138,9 @@ func (m *Manager) SaveNote(slug, note, source string) error {
 		 VALUES (?, ?, ?, 'general', 5, ?, '', ?, ?)`,
 		id, note, summary, source, now, now,
 	)
+	if err == nil {
+		m.kickIndexer()
+	}
 	return err
 }
 
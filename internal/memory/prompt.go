@@ -17,8 +17,9 @@ func (m *Manager) BuildMemoryPromptSection(slug string) string {
 	db.QueryRow("SELECT COUNT(*) FROM memories WHERE archived = 0").Scan(&total)
 	db.QueryRow("SELECT COUNT(*) FROM memories WHERE archived = 1").Scan(&archived)
 
+	shared := m.sharedPromptSection(slug)
 	if total == 0 && archived == 0 {
-		return buildEmptyMemorySection()
+		return buildEmptyMemorySection() + shared
 	}
 
 	var sb strings.Builder
@@ -125,6 +126,7 @@ func (m *Manager) BuildMemoryPromptSection(slug string) string {
 		}
 	}
 
+	sb.WriteString(shared)
 	sb.WriteString("Use `memory_save`, `memory_search`, `memory_list`, `memory_update`, `memory_forget`, `memory_stats` to interact with your full memory database.\n")
 	sb.WriteString("**Search before assuming you don't know something.**\n")
 
@@ -135,7 +137,7 @@ func buildEmptyMemorySection() string {
 	var sb strings.Builder
 	sb.WriteString("## MEMORY SYSTEM\n\n")
 	sb.WriteString("Your memory database is empty. Save important information using `memory_save` to remember it across conversations.\n\n")
-	sb.WriteString("Available tools: `memory_save`, `memory_search`, `memory_list`, `memory_update`, `memory_forget`, `memory_stats`\n")
+	sb.WriteString("Available tools: `memory_save`, `memory_search`, `memory_list`, `memory_update`, `memory_forget`, `memory_stats`\n\n")
 	return sb.String()
 }
 
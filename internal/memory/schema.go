@@ -45,6 +45,18 @@ CREATE TABLE IF NOT EXISTS memory_collections (
     collection_id TEXT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
     PRIMARY KEY (memory_id, collection_id)
 );
+
+-- One vector per memory for semantic search. content_hash fingerprints the
+-- text that was embedded, so an edited memory (or a change of model) is picked
+-- up by the background indexer and embedded again.
+CREATE TABLE IF NOT EXISTS memory_embeddings (
+    memory_id TEXT PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
+    model TEXT NOT NULL,
+    dims INTEGER NOT NULL,
+    vector BLOB NOT NULL,
+    content_hash TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
 `
 
 const ftsSQL = `
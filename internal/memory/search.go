@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+const (
+	// minSimilarity is the cosine floor for a semantic hit. Every memory has
+	// some similarity to every query; below this it is noise, and returning it
+	// would bury real keyword hits.
+	minSimilarity = 0.3
+	// rrfK is the Reciprocal Rank Fusion constant. 60 is the usual choice: it
+	// keeps one list's top hit from swamping a memory both lists rank well.
+	rrfK = 60
+	// queryEmbedTimeout bounds the query embedding. A slow backend degrades
+	// search to keyword-only rather than stalling the agent.
+	queryEmbedTimeout = 10 * time.Second
+)
+
+// SearchOptions filters a memory search.
+type SearchOptions struct {
+	Query           string
+	Category        string
+	MinImportance   int
+	Tags            string
+	Limit           int
+	IncludeArchived bool
+}
+
+// SearchHit is one memory returned by Search. Match says which half of the
+// hybrid search found it: "keyword", "semantic" or "both".
+type SearchHit struct {
+	ID          string  `json:"id"`
+	Content     string  `json:"content"`
+	Summary     string  `json:"summary"`
+	Category    string  `json:"category"`
+	Importance  int     `json:"importance"`
+	Tags        string  `json:"tags"`
+	AccessCount int     `json:"access_count"`
+	CreatedAt   string  `json:"created_at"`
+	Archived    bool    `json:"archived"`
+	Match       string  `json:"match"`
+	Similarity  float64 `json:"similarity,omitempty"`
+	// Scope names the shared memory a hit came from; empty for the agent's own.
+	Scope string `json:"scope,omitempty"`
+
+	score float64
+}
+
+// Search runs a hybrid memory search: full-text (FTS5, or LIKE without it)
+// and, when an embedding backend is configured, cosine similarity against the
+// stored vectors. The two rankings are merged with Reciprocal Rank Fusion, so
+// "my partner's birthday" finds "Sam was born March 3" even with no word in
+// common, while an exact keyword hit still ranks first.
+func (m *Manager) Search(ctx context.Context, slug string, opts SearchOptions) ([]SearchHit, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.Limit > 100 {
+		opts.Limit = 100
+	}
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each half fetches a deeper list than asked for, so a memory ranked
+	// modestly by both can still surface after fusion.
+	depth := opts.Limit * 3
+	keyword, kwErr := keywordSearch(db, opts, depth)
+
+	var semantic []SearchHit
+	if e := m.currentEmbedder(); e != nil {
+		semantic, err = semanticSearch(ctx, db, e, opts, depth)
+		if err != nil {
+			logger.Warn("Semantic memory search for %s fell back to keywords: %v", slug, err)
+		}
+	}
+	if kwErr != nil && len(semantic) == 0 {
+		return nil, kwErr
+	}
+
+	merged := map[string]*SearchHit{}
+	var order []*SearchHit
+	for i := range keyword {
+		h := keyword[i]
+		h.Match = "keyword"
+		h.score = 1.0 / float64(rrfK+i+1)
+		merged[h.ID] = &h
+		order = append(order, &h)
+	}
+	for i, s := range semantic {
+		if h, ok := merged[s.ID]; ok {
+			h.Match = "both"
+			h.Similarity = s.Similarity
+			h.score += 1.0 / float64(rrfK+i+1)
+			continue
+		}
+		h := s
+		h.Match = "semantic"
+		h.score = 1.0 / float64(rrfK+i+1)
+		merged[h.ID] = &h
+		order = append(order, &h)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].score > order[j].score })
+
+	hits := make([]SearchHit, 0, opts.Limit)
+	for _, h := range order {
+		if len(hits) == opts.Limit {
+			break
+		}
+		hits = append(hits, *h)
+	}
+	return hits, nil
+}
+
+// filterSQL appends the option filters for a memories table aliased as alias.
+func filterSQL(alias string, opts SearchOptions) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	if !opts.IncludeArchived {
+		fmt.Fprintf(&b, " AND %sarchived = 0", alias)
+	}
+	if opts.Category != "" {
+		fmt.Fprintf(&b, " AND %scategory = ?", alias)
+		args = append(args, opts.Category)
+	}
+	if opts.MinImportance > 0 {
+		fmt.Fprintf(&b, " AND %simportance >= ?", alias)
+		args = append(args, opts.MinImportance)
+	}
+	if opts.Tags != "" {
+		for _, tag := range strings.Split(opts.Tags, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" {
+				fmt.Fprintf(&b, " AND %stags LIKE ?", alias)
+				args = append(args, "%"+tag+"%")
+			}
+		}
+	}
+	return b.String(), args
+}
+
+const hitColumns = `m.id, m.content, m.summary, m.category, m.importance, m.tags,
+	m.access_count, m.created_at, m.archived`
+
+func scanHit(rows *sql.Rows, extra ...interface{}) (SearchHit, error) {
+	var h SearchHit
+	dest := append([]interface{}{&h.ID, &h.Content, &h.Summary, &h.Category, &h.Importance, &h.Tags,
+		&h.AccessCount, &h.CreatedAt, &h.Archived}, extra...)
+	return h, rows.Scan(dest...)
+}
+
+// keywordSearch is the full-text half: FTS5 ranked by bm25, or a LIKE scan by
+// importance when FTS5 isn't compiled in.
+func keywordSearch(db *sql.DB, opts SearchOptions, limit int) ([]SearchHit, error) {
+	var query string
+	var args []interface{}
+	filters, filterArgs := filterSQL("m.", opts)
+
+	if HasFTS() {
+		query = `SELECT ` + hitColumns + `
+			FROM memories_fts fts
+			JOIN memories m ON m.rowid = fts.rowid
+			WHERE memories_fts MATCH ?` + filters + `
+			ORDER BY bm25(memories_fts) LIMIT ?`
+		args = append([]interface{}{opts.Query}, filterArgs...)
+	} else {
+		like := "%" + opts.Query + "%"
+		query = `SELECT ` + hitColumns + `
+			FROM memories m
+			WHERE (m.content LIKE ? OR m.summary LIKE ? OR m.tags LIKE ?)` + filters + `
+			ORDER BY m.importance DESC, m.created_at DESC LIMIT ?`
+		args = append([]interface{}{like, like, like}, filterArgs...)
+	}
+	args = append(args, limit)
+
+	hits, err := queryHits(db, query, args)
+	if err != nil && HasFTS() {
+		// Natural-language queries trip FTS5's syntax ("partner's", "C++").
+		// Retry with every word quoted, which keeps the implicit AND.
+		if q := quoteFTSTerms(opts.Query); q != "" {
+			args[0] = q
+			hits, err = queryHits(db, query, args)
+		}
+	}
+	return hits, err
+}
+
+// queryHits runs a keyword query. FTS5 reports a bad MATCH expression while
+// stepping rather than when preparing, so the error can come from rows.Err.
+func queryHits(db *sql.DB, query string, args []interface{}) ([]SearchHit, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		h, err := scanHit(rows)
+		if err != nil {
+			logger.Warn("scan memory search row: %v", err)
+			continue
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// quoteFTSTerms turns free text into an FTS5 query of quoted words.
+func quoteFTSTerms(q string) string {
+	var terms []string
+	for _, w := range strings.Fields(normalizeText(q)) {
+		terms = append(terms, `"`+w+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// semanticSearch is the vector half: the query is embedded once and compared
+// with every stored vector from the same model. Per-agent memory is small
+// enough — thousands of rows, not millions — that a linear scan beats keeping
+// an index in sync.
+func semanticSearch(ctx context.Context, db *sql.DB, e Embedder, opts SearchOptions, limit int) ([]SearchHit, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryEmbedTimeout)
+	defer cancel()
+	vecs, err := e.Embed(ctx, []string{opts.Query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) == 0 || len(vecs[0]) == 0 {
+		return nil, errors.New("embedder returned no vector for the query")
+	}
+	q := vecs[0]
+
+	filters, filterArgs := filterSQL("m.", opts)
+	rows, err := db.Query(
+		`SELECT `+hitColumns+`, e.vector
+		 FROM memory_embeddings e JOIN memories m ON m.id = e.memory_id
+		 WHERE e.model = ?`+filters,
+		append([]interface{}{e.Model()}, filterArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var blob []byte
+		h, err := scanHit(rows, &blob)
+		if err != nil {
+			continue
+		}
+		h.Similarity = Cosine(q, DecodeVector(blob))
+		if h.Similarity >= minSimilarity {
+			hits = append(hits, h)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Similarity > hits[j].Similarity })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
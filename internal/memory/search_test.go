@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// topicEmbedder maps text onto a few hand-picked topics, so paraphrases with
+// no words in common land on the same vector the way a real model's would.
+type topicEmbedder struct{ calls int }
+
+var topics = [][]string{
+	{"birthday", "born", "birth"},
+	{"partner", "sam", "wife", "husband"},
+	{"typescript", "go", "language"},
+}
+
+func (e *topicEmbedder) Model() string { return "topics-v1" }
+
+func (e *topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, len(topics)+1)
+		v[len(topics)] = 0.1
+		for _, w := range strings.Fields(normalizeText(t)) {
+			for ti, words := range topics {
+				for _, tw := range words {
+					if w == tw {
+						v[ti]++
+					}
+				}
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager(t.TempDir())
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestSearchFindsParaphrasesWithEmbeddings(t *testing.T) {
+	m := newTestManager(t)
+	born, _ := m.Add("scout", Record{Content: "Sam was born March 3"})
+	lang, _ := m.Add("scout", Record{Content: "Prefers Go over TypeScript"})
+
+	opts := SearchOptions{Query: "my partner's birthday"}
+	hits, err := m.Search(context.Background(), "scout", opts)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("keyword-only search found %d memories for a paraphrase, want 0", len(hits))
+	}
+
+	e := &topicEmbedder{}
+	m.SetEmbedder(func() Embedder { return e })
+	if n, err := m.IndexEmbeddings(context.Background(), "scout", e); err != nil || n != 2 {
+		t.Fatalf("IndexEmbeddings = %d, %v; want 2 embedded", n, err)
+	}
+	if n, _ := m.IndexEmbeddings(context.Background(), "scout", e); n != 0 {
+		t.Errorf("second index pass re-embedded %d unchanged memories", n)
+	}
+
+	hits, err = m.Search(context.Background(), "scout", opts)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != born || hits[0].Match != "semantic" {
+		t.Fatalf("hybrid search = %+v, want only the birthday memory as a semantic match", hits)
+	}
+
+	// A keyword hit that is also the closest by meaning is reported as both.
+	hits, _ = m.Search(context.Background(), "scout", SearchOptions{Query: "TypeScript"})
+	if len(hits) == 0 || hits[0].ID != lang || hits[0].Match != "both" {
+		t.Errorf("search for TypeScript = %+v, want the language memory matched both ways", hits)
+	}
+
+	// Editing a memory makes its vector stale.
+	if err := m.Update("scout", Record{ID: lang, Content: "Prefers Rust"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := m.IndexEmbeddings(context.Background(), "scout", e); n != 1 {
+		t.Errorf("index pass after an edit embedded %d memories, want 1", n)
+	}
+}
+
+func TestMergeCandidates(t *testing.T) {
+	m := newTestManager(t)
+	a, _ := m.Add("scout", Record{Content: "Sam was born March 3"})
+	b, _ := m.Add("scout", Record{Content: "Sam's birthday is March 3"})
+	c, _ := m.Add("scout", Record{Content: "Prefers Go"})
+
+	if got := m.MergeCandidates(context.Background(), "scout", nil, []string{a, b, c}); got != nil {
+		t.Errorf("MergeCandidates without an embedder = %v, want none", got)
+	}
+
+	e := &topicEmbedder{}
+	m.SetEmbedder(func() Embedder { return e })
+	got := m.MergeCandidates(context.Background(), "scout", []string{"Uses the Go language"}, []string{a, b, c})
+
+	var stored, fact bool
+	for _, p := range got {
+		if p.Fact < 0 && ((p.A == a && p.B == b) || (p.A == b && p.B == a)) {
+			stored = true
+		}
+		if p.Fact == 0 && p.B == c {
+			fact = true
+		}
+		if p.Fact < 0 && (p.A == c || p.B == c) {
+			t.Errorf("unrelated memories paired: %+v", p)
+		}
+	}
+	if !stored {
+		t.Errorf("the two birthday memories were not offered as a merge: %+v", got)
+	}
+	if !fact {
+		t.Errorf("the new Go fact was not matched to the stored one: %+v", got)
+	}
+}
@@ -0,0 +1,371 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Shared memory scopes are memory databases that belong to no single agent:
+// a team-wide one, or one per workspace, that several agents read and write.
+// They have the same schema as an agent's own memory and live beside it on
+// disk, under data/shared/<scope id>/memory.db, so search, embeddings and the
+// store helpers work on them unchanged — every function here that takes a
+// slug accepts a SharedKey as well.
+//
+// Which agent may reach which scope is decided outside this package (the
+// scopes and their access rules live in the main database); the Manager only
+// asks, through the resolver installed with SetScopeResolver.
+
+const sharedPrefix = "shared/"
+
+// scopeIDRegex guards the scope id, which becomes a directory name.
+var scopeIDRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]{0,63}$`)
+
+// Scope is a shared memory scope as one agent sees it.
+type Scope struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// Write is false for an agent that may only read the scope.
+	Write bool `json:"write"`
+}
+
+// SharedKey is the slug a shared scope's database is opened under.
+func SharedKey(scopeID string) string { return sharedPrefix + scopeID }
+
+func sharedScopeID(slug string) (string, bool) {
+	id, ok := strings.CutPrefix(slug, sharedPrefix)
+	return id, ok && scopeIDRegex.MatchString(id)
+}
+
+// SetScopeResolver installs the lookup of the shared scopes an agent can
+// reach. Like SetEmbedder it is consulted on every use, so access changes
+// apply to the next tool call rather than the next restart.
+func (m *Manager) SetScopeResolver(fn func(agentSlug string) []Scope) {
+	m.scopes = fn
+}
+
+// Scopes returns the shared scopes an agent can reach.
+func (m *Manager) Scopes(agentSlug string) []Scope {
+	if m.scopes == nil {
+		return nil
+	}
+	return m.scopes(agentSlug)
+}
+
+// DropShared closes and deletes a shared scope's database.
+func (m *Manager) DropShared(scopeID string) error {
+	key := SharedKey(scopeID)
+	if _, ok := sharedScopeID(key); !ok {
+		return fmt.Errorf("invalid shared memory scope %q", scopeID)
+	}
+	if v, ok := m.dbs.LoadAndDelete(key); ok {
+		v.(*sql.DB).Close()
+	}
+	return os.RemoveAll(filepath.Dir(m.dbPath(key)))
+}
+
+// scopeAll is the memory_search scope that means "mine and every shared
+// scope I can read".
+const scopeAll = "all"
+
+// resolveScope turns a tool's scope argument into the database to use. An
+// empty scope, "own" or "personal" is the agent's own memory; anything else
+// must name (or id) a shared scope the agent can reach, and one it may write
+// to when write is set. The returned label is what results are tagged with.
+func (m *Manager) resolveScope(slug, scope string, write bool) (key, label string, err error) {
+	scope = strings.TrimSpace(scope)
+	switch strings.ToLower(scope) {
+	case "", "own", "personal":
+		return slug, "", nil
+	}
+	for _, s := range m.Scopes(slug) {
+		if s.ID != scope && !strings.EqualFold(s.Name, scope) {
+			continue
+		}
+		if write && !s.Write {
+			return "", "", fmt.Errorf("shared memory %q is read-only for you", s.Name)
+		}
+		return SharedKey(s.ID), s.Name, nil
+	}
+	return "", "", fmt.Errorf("no shared memory named %q is available to you", scope)
+}
+
+// searchAll searches the agent's own memory and every shared scope it can
+// read, and interleaves the results by rank so no one database crowds out
+// the others.
+func (m *Manager) searchAll(ctx context.Context, slug string, opts SearchOptions) ([]SearchHit, map[string]string, error) {
+	type source struct{ key, label string }
+	sources := []source{{slug, ""}}
+	for _, s := range m.Scopes(slug) {
+		sources = append(sources, source{SharedKey(s.ID), s.Name})
+	}
+
+	keys := map[string]string{}
+	var lists [][]SearchHit
+	var firstErr error
+	for _, src := range sources {
+		hits, err := m.Search(ctx, src.key, opts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for i := range hits {
+			hits[i].Scope = src.label
+			keys[hits[i].ID] = src.key
+		}
+		lists = append(lists, hits)
+	}
+	if len(lists) == 0 {
+		return nil, nil, firstErr
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	var out []SearchHit
+	for rank := 0; len(out) < limit; rank++ {
+		added := false
+		for _, l := range lists {
+			if rank < len(l) && len(out) < limit {
+				out = append(out, l[rank])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return out, keys, nil
+}
+
+// sharedPromptSection lists the shared scopes an agent can reach and what is
+// most worth knowing in each: the important memories and the recent ones.
+func (m *Manager) sharedPromptSection(slug string) string {
+	scopes := m.Scopes(slug)
+	if len(scopes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("### Shared Memory\n")
+	sb.WriteString("You share memory with other agents in the spaces below. Pass `scope` with the space's name to any memory tool to use it, and `scope: \"all\"` to `memory_search` to search your own memory and every shared space at once. Save facts the whole team should know to a shared space rather than your own memory.\n\n")
+	shown := 0
+	for _, s := range scopes {
+		db, err := m.GetDB(SharedKey(s.ID))
+		if err != nil {
+			continue
+		}
+		var total int
+		db.QueryRow("SELECT COUNT(*) FROM memories WHERE archived = 0").Scan(&total)
+		access := "read & write"
+		if !s.Write {
+			access = "read-only"
+		}
+		sb.WriteString(fmt.Sprintf("- **%s** (%s, %d memories)\n", s.Name, access, total))
+		if shown >= maxSharedPromptMemories {
+			continue
+		}
+
+		rows, err := db.Query(
+			`SELECT summary, content, category FROM memories
+			 WHERE archived = 0 AND (importance >= 7 OR created_at >= datetime('now', '-3 days'))
+			 ORDER BY importance DESC, created_at DESC LIMIT ?`,
+			min(sharedPromptPerScope, maxSharedPromptMemories-shown),
+		)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var summary, content, category string
+			rows.Scan(&summary, &content, &category)
+			display := summary
+			if display == "" {
+				display = firstLine(content, 100)
+			}
+			sb.WriteString(fmt.Sprintf("  - [%s] %s\n", category, display))
+			shown++
+		}
+		rows.Close()
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// The shared section is a digest on top of the agent's own memory, so it is
+// kept short: a few memories per scope and a cap across all of them.
+const (
+	sharedPromptPerScope    = 5
+	maxSharedPromptMemories = 15
+)
+
+// CommonFact is something several agents each hold in their own memory.
+type CommonFact struct {
+	Record Record
+	// Agents are the agents holding it, in the order they were given.
+	Agents []string
+}
+
+// maxCommonFacts bounds one CommonFacts call.
+const maxCommonFacts = 20
+
+// CommonFacts finds facts that at least minAgents of the given agents hold,
+// looking at each agent's perAgent most recent memories. Two memories are the
+// same fact when their text is the same once normalised or, with an embedding
+// backend configured, when their vectors' cosine similarity is at least MergeSimilarity.
+// The record returned is the most important of the copies, with the tags of
+// all of them.
+func (m *Manager) CommonFacts(ctx context.Context, slugs []string, perAgent, minAgents int) []CommonFact {
+	if minAgents < 2 {
+		minAgents = 2
+	}
+	type held struct {
+		agent  int
+		rec    Record
+		norm   string
+		vector []float32
+	}
+	var all []held
+	e := m.currentEmbedder()
+	for ai, slug := range slugs {
+		recs, err := m.Recent(slug, perAgent)
+		if err != nil || len(recs) == 0 {
+			continue
+		}
+		var vectors map[string][]float32
+		if e != nil {
+			vectors = m.vectors(ctx, slug, e, recs)
+		}
+		for _, r := range recs {
+			all = append(all, held{agent: ai, rec: r, norm: normalizeText(r.Content), vector: vectors[r.ID]})
+		}
+	}
+
+	// Union-find over copies held by different agents.
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range all {
+		for j := i + 1; j < len(all); j++ {
+			if all[i].agent == all[j].agent {
+				continue
+			}
+			same := all[i].norm != "" && all[i].norm == all[j].norm
+			if !same && all[i].vector != nil && all[j].vector != nil {
+				same = Cosine(all[i].vector, all[j].vector) >= MergeSimilarity
+			}
+			if same {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range all {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+
+	var out []CommonFact
+	for _, root := range roots {
+		members := groups[root]
+		agentSet := map[int]bool{}
+		for _, i := range members {
+			agentSet[all[i].agent] = true
+		}
+		if len(agentSet) < minAgents {
+			continue
+		}
+		best := all[members[0]].rec
+		tags := map[string]bool{}
+		var tagOrder []string
+		for _, i := range members {
+			r := all[i].rec
+			if r.Importance > best.Importance || (r.Importance == best.Importance && r.CreatedAt > best.CreatedAt) {
+				best = r
+			}
+			for _, t := range strings.Split(r.Tags, ",") {
+				if t = strings.TrimSpace(t); t != "" && !tags[t] {
+					tags[t] = true
+					tagOrder = append(tagOrder, t)
+				}
+			}
+		}
+		best.Tags = strings.Join(tagOrder, ", ")
+		fact := CommonFact{Record: best}
+		for ai, slug := range slugs {
+			if agentSet[ai] {
+				fact.Agents = append(fact.Agents, slug)
+			}
+		}
+		out = append(out, fact)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if len(out[i].Agents) != len(out[j].Agents) {
+			return len(out[i].Agents) > len(out[j].Agents)
+		}
+		return out[i].Record.Importance > out[j].Record.Importance
+	})
+	if len(out) > maxCommonFacts {
+		out = out[:maxCommonFacts]
+	}
+	return out
+}
+
+// vectors returns the stored embeddings of recs from the backend's model,
+// catching the indexer up first like MergeCandidates does.
+func (m *Manager) vectors(ctx context.Context, slug string, e Embedder, recs []Record) map[string][]float32 {
+	for {
+		n, err := m.IndexEmbeddings(ctx, slug, e)
+		if err != nil || n < embedBatch {
+			break
+		}
+	}
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return nil
+	}
+	args := []interface{}{e.Model()}
+	for _, r := range recs {
+		args = append(args, r.ID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(recs)), ",")
+	rows, err := db.Query(
+		"SELECT memory_id, vector FROM memory_embeddings WHERE model = ? AND memory_id IN ("+placeholders+")", args...,
+	)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	out := map[string][]float32{}
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if rows.Scan(&id, &blob) == nil {
+			out[id] = DecodeVector(blob)
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func callTool(t *testing.T, m *Manager, slug, tool string, args map[string]interface{}) (map[string]interface{}, bool) {
+	t.Helper()
+	input, _ := json.Marshal(args)
+	res := m.MakeMemoryHandlers(slug)[tool](context.Background(), "", input)
+	if res.IsError {
+		return map[string]interface{}{"error": res.Output}, false
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(res.Output), &out); err != nil {
+		t.Fatalf("%s output %q: %v", tool, res.Output, err)
+	}
+	return out, true
+}
+
+func TestMemoryToolsReachSharedScopes(t *testing.T) {
+	m := newTestManager(t)
+	m.SetScopeResolver(func(agent string) []Scope {
+		switch agent {
+		case "scout":
+			return []Scope{{ID: "team", Name: "Team", Write: true}}
+		case "intern":
+			return []Scope{{ID: "team", Name: "Team"}}
+		}
+		return nil
+	})
+
+	if _, ok := callTool(t, m, "scout", "memory_save", map[string]interface{}{
+		"content": "Staging lives at staging2.example.com", "importance": 8, "scope": "team",
+	}); !ok {
+		t.Fatal("scout could not save to the team scope")
+	}
+	if rec, _ := m.Recent(SharedKey("team"), 10); len(rec) != 1 || rec[0].Source != "agent:scout" {
+		t.Fatalf("shared memories = %+v", rec)
+	}
+	if own, _ := m.Recent("scout", 10); len(own) != 0 {
+		t.Errorf("save to a scope also landed in own memory: %+v", own)
+	}
+
+	if out, ok := callTool(t, m, "intern", "memory_save", map[string]interface{}{
+		"content": "I was here", "scope": "Team",
+	}); ok || !strings.Contains(out["error"].(string), "read-only") {
+		t.Errorf("read-only save = %v", out)
+	}
+	if _, ok := callTool(t, m, "nobody", "memory_list", map[string]interface{}{"scope": "team"}); ok {
+		t.Error("an agent without access listed the scope")
+	}
+
+	m.Add("intern", Record{Content: "Staging passwords rotate monthly"})
+	out, ok := callTool(t, m, "intern", "memory_search", map[string]interface{}{"query": "staging", "scope": "all"})
+	if !ok || out["count"].(float64) != 2 {
+		t.Fatalf("search all = %v", out)
+	}
+	scopes := map[string]bool{}
+	for _, h := range out["results"].([]interface{}) {
+		hit := h.(map[string]interface{})
+		scope, _ := hit["scope"].(string)
+		scopes[scope] = true
+	}
+	if !scopes["Team"] || !scopes[""] {
+		t.Errorf("hit scopes = %v", scopes)
+	}
+
+	prompt := m.BuildMemoryPromptSection("intern")
+	if !strings.Contains(prompt, "**Team** (read-only, 1 memories)") || !strings.Contains(prompt, "staging2.example.com") {
+		t.Errorf("prompt lacks the shared section:\n%s", prompt)
+	}
+}
+
+func TestCommonFactsNeedSeveralAgents(t *testing.T) {
+	m := newTestManager(t)
+	m.Add("scout", Record{Content: "Deploys freeze on Fridays", Importance: 5, Tags: "deploy"})
+	m.Add("builder", Record{Content: "deploys freeze on fridays.", Importance: 7, Tags: "release"})
+	m.Add("builder", Record{Content: "Uses Bazel", Importance: 5})
+	m.Add("scout", Record{Content: "Prefers short answers", Importance: 5})
+
+	facts := m.CommonFacts(context.Background(), []string{"scout", "builder", "idle"}, 50, 2)
+	if len(facts) != 1 {
+		t.Fatalf("facts = %+v", facts)
+	}
+	f := facts[0]
+	if f.Record.Importance != 7 || f.Record.Tags != "deploy, release" || strings.Join(f.Agents, ",") != "scout,builder" {
+		t.Errorf("fact = %+v", f)
+	}
+}
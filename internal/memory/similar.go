@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// MergeSimilarity is the cosine at which two memories are probably saying the
+// same thing. Deliberately high: these are offered to the consolidation pass
+// as merge candidates, and a false pair costs a model's attention while a
+// missed one only leaves a duplicate for the next dream.
+const MergeSimilarity = 0.85
+
+// maxMergeCandidates bounds how many pairs are handed to a dream.
+const maxMergeCandidates = 40
+
+// MergeCandidate pairs two things that look like duplicates by embedding. A is
+// either a stored memory id or, when Fact >= 0, the new fact at that index; B
+// is always a stored memory id.
+type MergeCandidate struct {
+	Fact       int
+	A          string
+	B          string
+	Similarity float64
+}
+
+// MergeCandidates finds likely duplicates among the given stored memories, and
+// between each new fact and those memories. Stored memories are compared by
+// their indexed vectors; the facts are embedded here. It returns nothing when
+// no embedding backend is configured — the dream then reviews without hints,
+// as it always has.
+func (m *Manager) MergeCandidates(ctx context.Context, slug string, facts []string, ids []string) []MergeCandidate {
+	e := m.currentEmbedder()
+	if e == nil || len(ids) == 0 {
+		return nil
+	}
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return nil
+	}
+
+	// Catch up on anything the background indexer hasn't reached yet, so a
+	// dream right after semantic search is switched on still gets hints.
+	for {
+		n, err := m.IndexEmbeddings(ctx, slug, e)
+		if err != nil || n < embedBatch {
+			break
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := []interface{}{e.Model()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := db.Query(
+		"SELECT memory_id, vector FROM memory_embeddings WHERE model = ? AND memory_id IN ("+placeholders+")", args...,
+	)
+	if err != nil {
+		return nil
+	}
+	vectors := map[string][]float32{}
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if rows.Scan(&id, &blob) == nil {
+			vectors[id] = DecodeVector(blob)
+		}
+	}
+	rows.Close()
+
+	// Keep the caller's order so results are stable run to run.
+	var stored []string
+	for _, id := range ids {
+		if _, ok := vectors[id]; ok {
+			stored = append(stored, id)
+		}
+	}
+
+	var out []MergeCandidate
+	for i := 0; i < len(stored); i++ {
+		for j := i + 1; j < len(stored); j++ {
+			if sim := Cosine(vectors[stored[i]], vectors[stored[j]]); sim >= MergeSimilarity {
+				out = append(out, MergeCandidate{Fact: -1, A: stored[i], B: stored[j], Similarity: sim})
+			}
+		}
+	}
+
+	if len(facts) > 0 && len(stored) > 0 {
+		factVecs, err := e.Embed(ctx, facts)
+		if err == nil {
+			for fi, fv := range factVecs {
+				for _, id := range stored {
+					if sim := Cosine(fv, vectors[id]); sim >= MergeSimilarity {
+						out = append(out, MergeCandidate{Fact: fi, B: id, Similarity: sim})
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Similarity > out[j].Similarity })
+	if len(out) > maxMergeCandidates {
+		out = out[:maxMergeCandidates]
+	}
+	return out
+}
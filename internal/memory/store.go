@@ -63,17 +63,31 @@ func (m *Manager) Recent(slug string, limit int) ([]Record, error) {
 	if limit <= 0 {
 		limit = 100
 	}
-	db, err := m.GetDB(slug)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := db.Query(
+	return m.records(slug,
 		`SELECT id, content, summary, category, importance, source, tags, created_at
 		 FROM memories WHERE archived = 0
 		 ORDER BY created_at DESC LIMIT ?`,
 		limit,
 	)
+}
+
+// All returns every active memory, oldest first — the whole set, for copying
+// an agent's memory somewhere else rather than for showing it to a model.
+func (m *Manager) All(slug string) ([]Record, error) {
+	return m.records(slug,
+		`SELECT id, content, summary, category, importance, source, tags, created_at
+		 FROM memories WHERE archived = 0
+		 ORDER BY created_at, id`,
+	)
+}
+
+func (m *Manager) records(slug, query string, args ...interface{}) ([]Record, error) {
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +127,7 @@ func (m *Manager) Add(slug string, r Record) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	m.kickIndexer()
 	return id, nil
 }
 
@@ -158,8 +173,11 @@ func (m *Manager) Update(slug string, r Record) error {
 	}
 
 	args = append(args, r.ID)
-	_, err = db.Exec("UPDATE memories SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
-	return err
+	if _, err = db.Exec("UPDATE memories SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...); err != nil {
+		return err
+	}
+	m.kickIndexer()
+	return nil
 }
 
 // Forget deletes a memory outright. Reported separately from Update by callers
@@ -176,6 +194,55 @@ func (m *Manager) Forget(slug, id string) error {
 	return err
 }
 
+// Get loads one memory, archived or not; sql.ErrNoRows if there is none.
+func (m *Manager) Get(slug, id string) (Record, error) {
+	var r Record
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return r, err
+	}
+	err = db.QueryRow(
+		`SELECT id, content, summary, category, importance, source, tags, created_at
+		 FROM memories WHERE id = ?`, id,
+	).Scan(&r.ID, &r.Content, &r.Summary, &r.Category, &r.Importance, &r.Source, &r.Tags, &r.CreatedAt)
+	return r, err
+}
+
+// Restore writes a memory back exactly as recorded, recreating it under its
+// old id if it has been deleted. Unlike Update every field is applied, empty
+// or not — this is for undoing a change, not for refining one.
+func (m *Manager) Restore(slug string, r Record) error {
+	if r.ID == "" {
+		return fmt.Errorf("memory id is required")
+	}
+	if strings.TrimSpace(r.Content) == "" {
+		return fmt.Errorf("memory content is empty")
+	}
+	db, err := m.GetDB(slug)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(sqlTime)
+	created := now
+	if t, err := time.Parse(time.RFC3339Nano, r.CreatedAt); err == nil {
+		created = t.UTC().Format(sqlTime)
+	} else if _, err := time.Parse(sqlTime, r.CreatedAt); err == nil {
+		created = r.CreatedAt
+	}
+	if _, err := db.Exec(
+		`INSERT INTO memories (id, content, summary, category, importance, source, tags, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET content = excluded.content, summary = excluded.summary,
+		     category = excluded.category, importance = excluded.importance, source = excluded.source,
+		     tags = excluded.tags, archived = 0, updated_at = excluded.updated_at`,
+		r.ID, r.Content, r.Summary, r.Category, r.Importance, r.Source, r.Tags, created, now,
+	); err != nil {
+		return err
+	}
+	m.kickIndexer()
+	return nil
+}
+
 // HasSimilar reports whether an active memory already says essentially this.
 //
 // A cheap guard for the reflex writer, which runs after every single reply: the
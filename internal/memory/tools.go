@@ -36,10 +36,22 @@ func (m *Manager) MakeMemoryHandlers(slug string) map[string]llm.ToolHandler {
 
 // --- Tool Definitions ---
 
+// scopeParam is the optional argument every memory tool takes to work on a
+// shared memory space instead of the agent's own memory.
+func scopeParam(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": description,
+	}
+}
+
+const scopeParamDesc = "Shared memory space to use, by name. Omit for your own memory."
+
 func buildMemorySaveDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"scope": scopeParam("Shared memory space to save to, by name, for facts other agents should know too. Omit for your own memory."),
 			"content": map[string]interface{}{
 				"type":        "string",
 				"description": "The memory content to save",
@@ -81,9 +93,10 @@ func buildMemorySearchDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"scope": scopeParam("Shared memory space to search, by name, or \"all\" for your own memory and every shared space you can read. Omit for your own memory."),
 			"query": map[string]interface{}{
 				"type":        "string",
-				"description": "Full-text search query (supports natural language)",
+				"description": "Search query (natural language works best)",
 			},
 			"category": map[string]interface{}{
 				"type":        "string",
@@ -113,7 +126,7 @@ func buildMemorySearchDef() llm.ToolDef {
 		Type: "function",
 		Function: llm.FunctionDef{
 			Name:        "memory_search",
-			Description: "Search your memory database using full-text search. Results are ranked by relevance. Search before assuming you don't know something.",
+			Description: "Search your memory database. Matches by keyword and, when semantic search is configured, by meaning — a paraphrase finds the memory even with no words in common. Results are ranked by relevance. Search before assuming you don't know something.",
 			Parameters:  params,
 		},
 	}
@@ -123,6 +136,7 @@ func buildMemoryListDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"scope": scopeParam(scopeParamDesc),
 			"category": map[string]interface{}{
 				"type":        "string",
 				"description": "Filter by category",
@@ -167,6 +181,7 @@ func buildMemoryUpdateDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"scope": scopeParam(scopeParamDesc),
 			"id": map[string]interface{}{
 				"type":        "string",
 				"description": "The memory ID to update",
@@ -212,6 +227,7 @@ func buildMemoryForgetDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"scope": scopeParam(scopeParamDesc),
 			"id": map[string]interface{}{
 				"type":        "string",
 				"description": "The memory ID to forget",
@@ -235,8 +251,10 @@ func buildMemoryForgetDef() llm.ToolDef {
 
 func buildMemoryStatsDef() llm.ToolDef {
 	params, _ := json.Marshal(map[string]interface{}{
-		"type":       "object",
-		"properties": map[string]interface{}{},
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scope": scopeParam(scopeParamDesc),
+		},
 	})
 	return llm.ToolDef{
 		Type: "function",
@@ -258,6 +276,7 @@ func (m *Manager) handleSave(slug string) llm.ToolHandler {
 			Category   string `json:"category"`
 			Importance int    `json:"importance"`
 			Tags       string `json:"tags"`
+			Scope      string `json:"scope"`
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
@@ -275,7 +294,16 @@ func (m *Manager) handleSave(slug string) llm.ToolHandler {
 			params.Importance = 10
 		}
 
-		db, err := m.GetDB(slug)
+		key, label, err := m.resolveScope(slug, params.Scope, true)
+		if err != nil {
+			return llm.ToolResult{Output: err.Error(), IsError: true}
+		}
+		// A shared memory records who saved it, since everyone else reads it.
+		source := "agent"
+		if label != "" {
+			source = "agent:" + slug
+		}
+		db, err := m.GetDB(key)
 		if err != nil {
 			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
 		}
@@ -284,19 +312,24 @@ func (m *Manager) handleSave(slug string) llm.ToolHandler {
 		now := time.Now().UTC().Format("2006-01-02 15:04:05")
 		_, err = db.Exec(
 			`INSERT INTO memories (id, content, summary, category, importance, source, tags, created_at, updated_at)
-			 VALUES (?, ?, ?, ?, ?, 'agent', ?, ?, ?)`,
-			id, params.Content, params.Summary, params.Category, params.Importance, params.Tags, now, now,
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, params.Content, params.Summary, params.Category, params.Importance, source, params.Tags, now, now,
 		)
 		if err != nil {
 			return llm.ToolResult{Output: "Failed to save memory: " + err.Error(), IsError: true}
 		}
+		m.kickIndexer()
 
-		result, _ := json.Marshal(map[string]interface{}{
+		resp := map[string]interface{}{
 			"id":         id,
 			"saved":      true,
 			"category":   params.Category,
 			"importance": params.Importance,
-		})
+		}
+		if label != "" {
+			resp["scope"] = label
+		}
+		result, _ := json.Marshal(resp)
 		return llm.ToolResult{Output: string(result)}
 	}
 }
@@ -310,6 +343,7 @@ func (m *Manager) handleSearch(slug string) llm.ToolHandler {
 			Tags            string `json:"tags"`
 			Limit           int    `json:"limit"`
 			IncludeArchived bool   `json:"include_archived"`
+			Scope           string `json:"scope"`
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
@@ -317,130 +351,48 @@ func (m *Manager) handleSearch(slug string) llm.ToolHandler {
 		if params.Query == "" {
 			return llm.ToolResult{Output: "query is required", IsError: true}
 		}
-		if params.Limit <= 0 {
-			params.Limit = 20
-		}
-		if params.Limit > 100 {
-			params.Limit = 100
-		}
 
-		db, err := m.GetDB(slug)
-		if err != nil {
-			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
+		opts := SearchOptions{
+			Query:           params.Query,
+			Category:        params.Category,
+			MinImportance:   params.MinImportance,
+			Tags:            params.Tags,
+			Limit:           params.Limit,
+			IncludeArchived: params.IncludeArchived,
 		}
-
-		var query string
-		var args []interface{}
-
-		if HasFTS() {
-			// FTS5 full-text search
-			query = `
-				SELECT m.id, m.content, m.summary, m.category, m.importance, m.tags,
-				       m.access_count, m.created_at, m.updated_at, m.archived,
-				       bm25(memories_fts) as rank
-				FROM memories_fts fts
-				JOIN memories m ON m.rowid = fts.rowid
-				WHERE memories_fts MATCH ?`
-			args = []interface{}{params.Query}
-
-			if !params.IncludeArchived {
-				query += " AND m.archived = 0"
-			}
-			if params.Category != "" {
-				query += " AND m.category = ?"
-				args = append(args, params.Category)
-			}
-			if params.MinImportance > 0 {
-				query += " AND m.importance >= ?"
-				args = append(args, params.MinImportance)
-			}
-			if params.Tags != "" {
-				for _, tag := range strings.Split(params.Tags, ",") {
-					tag = strings.TrimSpace(tag)
-					if tag != "" {
-						query += " AND m.tags LIKE ?"
-						args = append(args, "%"+tag+"%")
-					}
-				}
-			}
-			query += " ORDER BY rank LIMIT ?"
-			args = append(args, params.Limit)
+		var hits []SearchHit
+		var keys map[string]string
+		var err error
+		if strings.EqualFold(strings.TrimSpace(params.Scope), scopeAll) {
+			hits, keys, err = m.searchAll(ctx, slug, opts)
 		} else {
-			// Fallback: LIKE-based search when FTS5 is not available
-			likePattern := "%" + params.Query + "%"
-			query = `
-				SELECT id, content, summary, category, importance, tags,
-				       access_count, created_at, updated_at, archived,
-				       0 as rank
-				FROM memories
-				WHERE (content LIKE ? OR summary LIKE ? OR tags LIKE ?)`
-			args = []interface{}{likePattern, likePattern, likePattern}
-
-			if !params.IncludeArchived {
-				query += " AND archived = 0"
-			}
-			if params.Category != "" {
-				query += " AND category = ?"
-				args = append(args, params.Category)
+			var key, label string
+			if key, label, err = m.resolveScope(slug, params.Scope, false); err != nil {
+				return llm.ToolResult{Output: err.Error(), IsError: true}
 			}
-			if params.MinImportance > 0 {
-				query += " AND importance >= ?"
-				args = append(args, params.MinImportance)
-			}
-			if params.Tags != "" {
-				for _, tag := range strings.Split(params.Tags, ",") {
-					tag = strings.TrimSpace(tag)
-					if tag != "" {
-						query += " AND tags LIKE ?"
-						args = append(args, "%"+tag+"%")
-					}
-				}
+			hits, err = m.Search(ctx, key, opts)
+			keys = map[string]string{}
+			for i := range hits {
+				hits[i].Scope = label
+				keys[hits[i].ID] = key
 			}
-			query += " ORDER BY importance DESC, created_at DESC LIMIT ?"
-			args = append(args, params.Limit)
 		}
-
-		rows, err := db.Query(query, args...)
 		if err != nil {
 			return llm.ToolResult{Output: "Search failed: " + err.Error(), IsError: true}
 		}
-		defer rows.Close()
-
-		var memories []map[string]interface{}
-		var ids []string
-		for rows.Next() {
-			var id, content, summary, category, tags, createdAt, updatedAt string
-			var importance, accessCount int
-			var archived bool
-			var rank float64
-			if err := rows.Scan(&id, &content, &summary, &category, &importance, &tags,
-				&accessCount, &createdAt, &updatedAt, &archived, &rank); err != nil {
-				log.Printf("[warn] scan memory search row: %v", err)
-				continue
-			}
-			memories = append(memories, map[string]interface{}{
-				"id":           id,
-				"content":      content,
-				"summary":      summary,
-				"category":     category,
-				"importance":   importance,
-				"tags":         tags,
-				"access_count": accessCount,
-				"created_at":   createdAt,
-				"archived":     archived,
-			})
-			ids = append(ids, id)
-		}
 
 		// Update access counts
-		for _, id := range ids {
-			db.Exec("UPDATE memories SET access_count = access_count + 1, last_accessed_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+		for _, h := range hits {
+			if db, err := m.GetDB(keys[h.ID]); err == nil {
+				db.Exec("UPDATE memories SET access_count = access_count + 1, last_accessed_at = CURRENT_TIMESTAMP WHERE id = ?", h.ID)
+			}
 		}
 
 		result, _ := json.Marshal(map[string]interface{}{
-			"query":   params.Query,
-			"count":   len(memories),
-			"results": memories,
+			"query":    params.Query,
+			"count":    len(hits),
+			"semantic": m.currentEmbedder() != nil,
+			"results":  hits,
 		})
 		return llm.ToolResult{Output: string(result)}
 	}
@@ -455,6 +407,7 @@ func (m *Manager) handleList(slug string) llm.ToolHandler {
 			Limit           int    `json:"limit"`
 			Offset          int    `json:"offset"`
 			IncludeArchived bool   `json:"include_archived"`
+			Scope           string `json:"scope"`
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
@@ -466,7 +419,11 @@ func (m *Manager) handleList(slug string) llm.ToolHandler {
 			params.Limit = 100
 		}
 
-		db, err := m.GetDB(slug)
+		key, _, err := m.resolveScope(slug, params.Scope, false)
+		if err != nil {
+			return llm.ToolResult{Output: err.Error(), IsError: true}
+		}
+		db, err := m.GetDB(key)
 		if err != nil {
 			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
 		}
@@ -568,6 +525,7 @@ func (m *Manager) handleUpdate(slug string) llm.ToolHandler {
 			Importance *int    `json:"importance"`
 			Tags       *string `json:"tags"`
 			Archived   *bool   `json:"archived"`
+			Scope      string  `json:"scope"`
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
@@ -576,7 +534,11 @@ func (m *Manager) handleUpdate(slug string) llm.ToolHandler {
 			return llm.ToolResult{Output: "id is required", IsError: true}
 		}
 
-		db, err := m.GetDB(slug)
+		key, _, err := m.resolveScope(slug, params.Scope, true)
+		if err != nil {
+			return llm.ToolResult{Output: err.Error(), IsError: true}
+		}
+		db, err := m.GetDB(key)
 		if err != nil {
 			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
 		}
@@ -637,6 +599,7 @@ func (m *Manager) handleUpdate(slug string) llm.ToolHandler {
 		if affected == 0 {
 			return llm.ToolResult{Output: "Memory not found: " + params.ID, IsError: true}
 		}
+		m.kickIndexer()
 
 		resp, _ := json.Marshal(map[string]interface{}{
 			"id":      params.ID,
@@ -651,6 +614,7 @@ func (m *Manager) handleForget(slug string) llm.ToolHandler {
 		var params struct {
 			ID          string `json:"id"`
 			ArchiveOnly bool   `json:"archive_only"`
+			Scope       string `json:"scope"`
 		}
 		if err := json.Unmarshal(input, &params); err != nil {
 			return llm.ToolResult{Output: "Invalid input: " + err.Error(), IsError: true}
@@ -659,7 +623,11 @@ func (m *Manager) handleForget(slug string) llm.ToolHandler {
 			return llm.ToolResult{Output: "id is required", IsError: true}
 		}
 
-		db, err := m.GetDB(slug)
+		key, _, err := m.resolveScope(slug, params.Scope, true)
+		if err != nil {
+			return llm.ToolResult{Output: err.Error(), IsError: true}
+		}
+		db, err := m.GetDB(key)
 		if err != nil {
 			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
 		}
@@ -694,7 +662,15 @@ func (m *Manager) handleForget(slug string) llm.ToolHandler {
 
 func (m *Manager) handleStats(slug string) llm.ToolHandler {
 	return func(ctx context.Context, workDir string, input json.RawMessage) llm.ToolResult {
-		db, err := m.GetDB(slug)
+		var params struct {
+			Scope string `json:"scope"`
+		}
+		json.Unmarshal(input, &params)
+		key, _, err := m.resolveScope(slug, params.Scope, false)
+		if err != nil {
+			return llm.ToolResult{Output: err.Error(), IsError: true}
+		}
+		db, err := m.GetDB(key)
 		if err != nil {
 			return llm.ToolResult{Output: "Memory DB error: " + err.Error(), IsError: true}
 		}
@@ -765,6 +741,12 @@ func (m *Manager) handleStats(slug string) llm.ToolHandler {
 			stats["collections"] = collections
 		}
 
+		if key == slug {
+			if scopes := m.Scopes(slug); len(scopes) > 0 {
+				stats["shared_scopes"] = scopes
+			}
+		}
+
 		result, _ := json.Marshal(stats)
 		return llm.ToolResult{Output: string(result)}
 	}
@@ -21,6 +21,9 @@ const (
 	UserIDKey    contextKey = "userID"
 	UsernameKey  contextKey = "username"
 	RequestIDKey contextKey = "requestID"
+	// APITokenIDKey is set when the request authenticated with a personal
+	// access token rather than a session.
+	APITokenIDKey contextKey = "apiTokenID"
 )
 
 func RequestID(next http.Handler) http.Handler {
@@ -78,7 +81,7 @@ func CORS(next http.Handler) http.Handler {
 			// Dev mode: Vite dev server
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Workspace-ID")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 		}
 		w.Header().Set("Access-Control-Max-Age", "86400")
@@ -95,12 +98,14 @@ func Auth(authService *auth.Service) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenStr := ""
+			fromHeader := false
 
 			// Check Authorization header first
 			if authHeader := r.Header.Get("Authorization"); authHeader != "" {
 				parts := strings.SplitN(authHeader, " ", 2)
 				if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
 					tokenStr = parts[1]
+					fromHeader = true
 				}
 			}
 
@@ -116,8 +121,34 @@ func Auth(authService *auth.Service) func(http.Handler) http.Handler {
 				return
 			}
 
+			if auth.IsAPIToken(tokenStr) {
+				// Only from the header: CSRFProtection trusts a token request
+				// because a browser never sends one on its own, which a cookie
+				// would undo.
+				if !fromHeader {
+					http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+					return
+				}
+				apiToken, err := authService.ValidateAPIToken(tokenStr)
+				if err != nil || !authService.UserActive(apiToken.UserID) {
+					http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+					return
+				}
+				if !auth.ScopesAllow(apiToken.Scopes, r.Method, r.URL.Path) {
+					http.Error(w, `{"error":"token scope does not allow this request"}`, http.StatusForbidden)
+					return
+				}
+				authService.RecordAPITokenUse(apiToken, r)
+
+				ctx := context.WithValue(r.Context(), UserIDKey, apiToken.UserID)
+				ctx = context.WithValue(ctx, UsernameKey, apiToken.Username)
+				ctx = context.WithValue(ctx, APITokenIDKey, apiToken.ID)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			claims, err := authService.ValidateToken(tokenStr)
-			if err != nil {
+			if err != nil || !authService.UserActive(claims.UserID) {
 				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 				return
 			}
@@ -136,6 +167,15 @@ func GetUserID(ctx context.Context) string {
 	return ""
 }
 
+// GetAPITokenID returns the personal access token that authenticated the
+// request, or "" for a session.
+func GetAPITokenID(ctx context.Context) string {
+	if v, ok := ctx.Value(APITokenIDKey).(string); ok {
+		return v
+	}
+	return ""
+}
+
 func SecurityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -237,6 +277,14 @@ func CSRFProtection(next http.Handler) http.Handler {
 			return
 		}
 
+		// CSRF is a cookie attack. A personal access token is sent explicitly
+		// in a header the browser never attaches on its own, so there is
+		// nothing to forge.
+		if GetAPITokenID(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		cookie, err := r.Cookie("openpaw_csrf")
 		if err != nil || cookie.Value == "" {
 			http.Error(w, `{"error":"missing CSRF token"}`, http.StatusForbidden)
@@ -267,4 +315,3 @@ func SetCSRFCookie(w http.ResponseWriter, r *http.Request) string {
 	})
 	return token
 }
-
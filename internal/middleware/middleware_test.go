@@ -63,7 +63,7 @@ func TestCORS_DevOrigin(t *testing.T) {
 	expected := map[string]string{
 		"Access-Control-Allow-Origin":      "http://localhost:5173",
 		"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type, Authorization, X-CSRF-Token",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization, X-CSRF-Token, X-Workspace-ID",
 		"Access-Control-Allow-Credentials": "true",
 		"Access-Control-Max-Age":           "86400",
 	}
@@ -261,6 +261,140 @@ func TestAuth_BearerHeaderTakesPrecedenceOverCookie(t *testing.T) {
 	}
 }
 
+// --- Personal access tokens ---
+
+type fakeTokenStore struct {
+	tokens map[string]*auth.APIToken
+	used   []string
+}
+
+func (f *fakeTokenStore) LookupAPIToken(hash string) (*auth.APIToken, error) {
+	if t, ok := f.tokens[hash]; ok {
+		return t, nil
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+func (f *fakeTokenStore) RecordAPITokenUse(t *auth.APIToken, r *http.Request) {
+	f.used = append(f.used, t.ID+" "+r.Method+" "+r.URL.Path)
+}
+
+func newTokenAuthService(t *testing.T, scopes []string, expiresAt *time.Time) (*auth.Service, *fakeTokenStore, string) {
+	t.Helper()
+	raw, err := auth.GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken: %v", err)
+	}
+	store := &fakeTokenStore{tokens: map[string]*auth.APIToken{
+		auth.HashAPIToken(raw): {ID: "tok-1", UserID: "user-789", Username: "ci", Scopes: scopes, ExpiresAt: expiresAt},
+	}}
+	svc := newTestAuthService()
+	svc.SetAPITokenStore(store)
+	return svc, store, raw
+}
+
+func TestAuth_APIToken_SkipsCSRFAndRecordsUse(t *testing.T) {
+	svc, store, raw := newTokenAuthService(t, []string{auth.ScopeChat}, nil)
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetUserID(r.Context()) != "user-789" || GetAPITokenID(r.Context()) != "tok-1" {
+			t.Errorf("context user=%q token=%q", GetUserID(r.Context()), GetAPITokenID(r.Context()))
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Auth(svc)(CSRFProtection(inner))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat/threads", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 without CSRF for a token, got %d", rr.Code)
+	}
+	if len(store.used) != 1 || store.used[0] != "tok-1 POST /api/v1/chat/threads" {
+		t.Errorf("recorded uses = %v", store.used)
+	}
+}
+
+func TestAuth_APIToken_ScopeDenied_Returns403(t *testing.T) {
+	svc, store, raw := newTokenAuthService(t, []string{auth.ScopeRead}, nil)
+	handler := Auth(svc)(okHandler)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/schedules/abc", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rr.Code)
+	}
+	if len(store.used) != 0 {
+		t.Errorf("denied request recorded as a use: %v", store.used)
+	}
+}
+
+func TestAuth_APIToken_ExpiredOrUnknown_Returns401(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	svc, _, raw := newTokenAuthService(t, []string{auth.ScopeAll}, &past)
+	handler := Auth(svc)(okHandler)
+
+	for _, token := range []string{raw, auth.APITokenPrefix + "unknown"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tools", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected status 401, got %d", token[:10], rr.Code)
+		}
+	}
+}
+
+// A token in the session cookie would ride along on a cross-site form post
+// and, being a token request, skip the CSRF check.
+func TestAuth_APIToken_InCookie_Returns401(t *testing.T) {
+	svc, store, raw := newTokenAuthService(t, []string{auth.ScopeAll}, nil)
+	handler := Auth(svc)(CSRFProtection(okHandler))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat/threads", nil)
+	req.AddCookie(&http.Cookie{Name: "openpaw_token", Value: raw})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rr.Code)
+	}
+	if len(store.used) != 0 {
+		t.Errorf("cookie request recorded as a use: %v", store.used)
+	}
+}
+
+type fakeUserStore map[string]bool
+
+func (f fakeUserStore) UserExists(id string) bool { return f[id] }
+
+func TestAuth_APIToken_DeletedUser_Returns401(t *testing.T) {
+	svc, _, raw := newTokenAuthService(t, []string{auth.ScopeAll}, nil)
+	users := fakeUserStore{"user-789": true}
+	svc.SetUserStore(users)
+	handler := Auth(svc)(okHandler)
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tools", nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("expected status 200 while the user exists, got %d", code)
+	}
+	delete(users, "user-789")
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 once the user is gone, got %d", code)
+	}
+}
+
 // --- SecurityHeaders ---
 
 func TestSecurityHeaders_SetsAllHeaders(t *testing.T) {
@@ -272,9 +406,9 @@ func TestSecurityHeaders_SetsAllHeaders(t *testing.T) {
 
 	headers := map[string]string{
 		"X-Content-Type-Options": "nosniff",
-		"X-Frame-Options":       "DENY",
-		"Referrer-Policy":       "strict-origin-when-cross-origin",
-		"Permissions-Policy":    "camera=(), microphone=(), geolocation=()",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+		"Permissions-Policy":     "camera=(), microphone=(), geolocation=()",
 	}
 
 	for header, want := range headers {
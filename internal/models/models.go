@@ -11,6 +11,7 @@ type User struct {
 	PasswordHash string    `json:"-"`
 	DisplayName  string    `json:"display_name"`
 	AvatarPath   string    `json:"avatar_path"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -73,19 +74,83 @@ type Schedule struct {
 	WidgetID      string  `json:"widget_id"`
 	WorkspaceID   *string `json:"workspace_id,omitempty"`
 	// Provider pins the engine this routine runs on ("" = whatever is active).
-	Provider  string     `json:"provider"`
-	LastRunAt *time.Time `json:"last_run_at,omitempty"`
-	NextRunAt *time.Time `json:"next_run_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	Provider string `json:"provider"`
+	// Retry, timeout and overlap policy; see scheduler.Policy.
+	RetryMaxAttempts       int      `json:"retry_max_attempts"`
+	RetryBackoffSeconds    int      `json:"retry_backoff_seconds"`
+	RetryBackoffMultiplier float64  `json:"retry_backoff_multiplier"`
+	RetryOn                []string `json:"retry_on"`
+	TimeoutSeconds         int      `json:"timeout_seconds"`
+	ConcurrencyPolicy      string   `json:"concurrency_policy"`
+	// TriggerType is "cron" or "webhook". A webhook automation runs when its
+	// WebhookURL receives a signed POST; WebhookSecret is only filled in the
+	// responses that create or rotate it.
+	TriggerType   string     `json:"trigger_type"`
+	WebhookURL    string     `json:"webhook_url,omitempty"`
+	WebhookSecret string     `json:"webhook_secret,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 type ScheduleExecution struct {
+	ID         string `json:"id"`
+	ScheduleID string `json:"schedule_id"`
+	Status     string `json:"status"`
+	Output     string `json:"output"`
+	Error      string `json:"error"`
+	Attempt    int    `json:"attempt"`
+	// TriggeredBy is what started the run: "cron", "manual" or "webhook".
+	TriggeredBy string     `json:"triggered_by"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ScheduleChain is a DAG of follow-up steps run when its root schedule
+// finishes.
+type ScheduleChain struct {
+	ID             string              `json:"id"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description"`
+	RootScheduleID string              `json:"root_schedule_id"`
+	Enabled        bool                `json:"enabled"`
+	Steps          []ScheduleChainStep `json:"steps"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+type ScheduleChainStep struct {
+	ID string `json:"id"`
+	// DependsOn lists the steps this one waits for (none = the root schedule).
+	DependsOn      []string `json:"depends_on"`
+	Name           string   `json:"name"`
+	RunOn          string   `json:"run_on"`
+	ConditionType  string   `json:"condition_type"`
+	ConditionValue string   `json:"condition_value"`
+	AgentRoleSlug  string   `json:"agent_role_slug"`
+	PromptTemplate string   `json:"prompt_template"`
+	Notify         bool     `json:"notify"`
+	SortOrder      int      `json:"sort_order"`
+}
+
+type ScheduleChainRun struct {
+	ID              string                 `json:"id"`
+	ChainID         string                 `json:"chain_id"`
+	RootExecutionID string                 `json:"root_execution_id"`
+	Status          string                 `json:"status"`
+	Steps           []ScheduleChainRunStep `json:"steps"`
+	StartedAt       time.Time              `json:"started_at"`
+	FinishedAt      *time.Time             `json:"finished_at,omitempty"`
+}
+
+type ScheduleChainRunStep struct {
 	ID         string     `json:"id"`
-	ScheduleID string     `json:"schedule_id"`
+	StepID     string     `json:"step_id"`
 	Status     string     `json:"status"`
 	Output     string     `json:"output"`
 	Error      string     `json:"error"`
+	ThreadID   string     `json:"thread_id"`
 	StartedAt  time.Time  `json:"started_at"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
@@ -167,6 +232,11 @@ type ChatMessage struct {
 	// without loading every child thread.
 	ThreadReplyCount int    `json:"thread_reply_count,omitempty"`
 	ChildThreadID    string `json:"child_thread_id,omitempty"`
+	// Editing or regenerating a message forks the conversation, so a message
+	// can have alternatives: Siblings lists them oldest first, this message
+	// included, when there is more than one. The transcript shows one branch.
+	ParentID string   `json:"parent_id,omitempty"`
+	Siblings []string `json:"siblings,omitempty"`
 	// Stopped marks a reply the user interrupted mid-stream. The content is
 	// whatever had been written by then, not a complete answer.
 	Stopped   bool      `json:"stopped,omitempty"`
@@ -273,6 +343,25 @@ type NotificationInput struct {
 	Link            string
 }
 
+// Budget caps spend for one agent, workspace or schedule over a UTC day or
+// month. A zero limit means no limit on that measure.
+type Budget struct {
+	ID          string    `json:"id"`
+	ScopeType   string    `json:"scope_type"`
+	ScopeID     string    `json:"scope_id"`
+	Period      string    `json:"period"`
+	LimitUSD    float64   `json:"limit_usd"`
+	LimitTokens int64     `json:"limit_tokens"`
+	SoftPercent int       `json:"soft_percent"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Spend so far in the current period, filled in when listing.
+	SpentUSD    float64   `json:"spent_usd"`
+	SpentTokens int64     `json:"spent_tokens"`
+	PeriodStart time.Time `json:"period_start"`
+}
+
 type HeartbeatExecution struct {
 	ID            string     `json:"id"`
 	AgentRoleSlug string     `json:"agent_role_slug"`
@@ -287,7 +376,9 @@ type HeartbeatExecution struct {
 	FinishedAt    *time.Time `json:"finished_at,omitempty"`
 }
 
-// DreamRun is one agent's pass over its chats and memories.
+// DreamRun is one agent's pass over its chats and memories. Mode is "apply"
+// for a run that changed memory itself, "propose" for one whose changes wait
+// in the review queue; Pending counts those still waiting.
 type DreamRun struct {
 	ID              string     `json:"id"`
 	AgentRoleSlug   string     `json:"agent_role_slug"`
@@ -300,8 +391,40 @@ type DreamRun struct {
 	MemoriesPruned  int        `json:"memories_pruned"`
 	Summary         string     `json:"summary"`
 	Error           string     `json:"error"`
+	Mode            string     `json:"mode"`
+	Pending         int        `json:"pending"`
 	StartedAt       time.Time  `json:"started_at"`
 	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	RevertedAt      *time.Time `json:"reverted_at,omitempty"`
+	Ops             []DreamOp  `json:"ops"`
+}
+
+// DreamOp is one change a dream made, or proposes, to an agent's memory:
+// "add", "update" or "forget", with the memory before and after and a
+// unified diff of its content.
+type DreamOp struct {
+	ID            string       `json:"id"`
+	RunID         string       `json:"run_id"`
+	AgentRoleSlug string       `json:"agent_role_slug"`
+	Op            string       `json:"op"`
+	MemoryID      string       `json:"memory_id"`
+	Before        *DreamMemory `json:"before,omitempty"`
+	After         *DreamMemory `json:"after,omitempty"`
+	Diff          string       `json:"diff"`
+	Reason        string       `json:"reason"`
+	Status        string       `json:"status"`
+	DecidedBy     string       `json:"decided_by"`
+	DecidedAt     *time.Time   `json:"decided_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// DreamMemory is a memory as a dream op saw it.
+type DreamMemory struct {
+	Content    string `json:"content"`
+	Summary    string `json:"summary"`
+	Category   string `json:"category"`
+	Importance int    `json:"importance"`
+	Tags       string `json:"tags"`
 }
 
 type ThreadMember struct {
@@ -349,6 +472,9 @@ type ContextFile struct {
 	IsAboutYou bool      `json:"is_about_you"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	// TextFilename is the Markdown extracted from a non-text upload, stored
+	// beside it. Empty when the file is read as is.
+	TextFilename string `json:"text_filename,omitempty"`
 }
 
 type Workspace struct {
@@ -0,0 +1,404 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Channel types.
+const (
+	TypeEmail    = "email"
+	TypeNtfy     = "ntfy"
+	TypeGotify   = "gotify"
+	TypeTelegram = "telegram"
+	TypeSlack    = "slack"
+	TypeDiscord  = "discord"
+	TypeWebhook  = "webhook"
+)
+
+// Message is what a channel sends: a notification's content and where it
+// came from.
+type Message struct {
+	NotificationID  string    `json:"id"`
+	Title           string    `json:"title"`
+	Body            string    `json:"body"`
+	Detail          string    `json:"detail"`
+	Priority        string    `json:"priority"`
+	SourceType      string    `json:"source_type"`
+	SourceAgentSlug string    `json:"source_agent_slug"`
+	WorkspaceID     string    `json:"workspace_id"`
+	Link            string    `json:"link"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// text is the message as one short plain-text block: the title, then the
+// preview, falling back to the full detail when there is no preview.
+func (m Message) text() string {
+	body := m.Body
+	if body == "" {
+		body = m.Detail
+	}
+	if body == "" {
+		return m.Title
+	}
+	return m.Title + "\n\n" + body
+}
+
+// channelType describes one kind of channel: the settings it needs, the
+// credentials it takes from secrets, and how it sends.
+type channelType struct {
+	required []string
+	secrets  []string
+	// requiredSecrets are the credentials it can't send without.
+	requiredSecrets []string
+	send            func(ctx context.Context, s *sender, settings map[string]string, m Message) error
+}
+
+var channelTypes = map[string]channelType{
+	TypeEmail: {
+		required: []string{"host", "from", "to"},
+		secrets:  []string{"password"},
+		send:     sendEmail,
+	},
+	TypeNtfy: {
+		required: []string{"topic"},
+		secrets:  []string{"token"},
+		send:     sendNtfy,
+	},
+	TypeGotify: {
+		required:        []string{"server"},
+		secrets:         []string{"token"},
+		requiredSecrets: []string{"token"},
+		send:            sendGotify,
+	},
+	TypeTelegram: {
+		required:        []string{"chat_id"},
+		secrets:         []string{"bot_token"},
+		requiredSecrets: []string{"bot_token"},
+		send:            sendTelegram,
+	},
+	TypeSlack: {
+		secrets:         []string{"webhook_url"},
+		requiredSecrets: []string{"webhook_url"},
+		send:            sendSlack,
+	},
+	TypeDiscord: {
+		secrets:         []string{"webhook_url"},
+		requiredSecrets: []string{"webhook_url"},
+		send:            sendDiscord,
+	},
+	TypeWebhook: {
+		required: []string{"url"},
+		secrets:  []string{"signing_secret"},
+		send:     sendWebhook,
+	},
+}
+
+// ValidateChannel checks a channel's type, settings and secret references
+// before it is saved.
+func ValidateChannel(typ string, config, secrets map[string]string) error {
+	ct, ok := channelTypes[typ]
+	if !ok {
+		return fmt.Errorf("type must be one of email, ntfy, gotify, telegram, slack, discord or webhook")
+	}
+	for _, key := range ct.required {
+		if strings.TrimSpace(config[key]) == "" {
+			return fmt.Errorf("a %s channel needs %s", typ, key)
+		}
+	}
+	for _, key := range ct.requiredSecrets {
+		if strings.TrimSpace(secrets[key]) == "" {
+			return fmt.Errorf("a %s channel needs the name of the secret holding its %s", typ, key)
+		}
+	}
+	for key := range secrets {
+		if !slices.Contains(ct.secrets, key) {
+			return fmt.Errorf("a %s channel takes no %s secret", typ, key)
+		}
+	}
+	for _, key := range []string{"url", "server"} {
+		if v := config[key]; v != "" && !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+			return fmt.Errorf("%s must be an http:// or https:// URL", key)
+		}
+	}
+	return nil
+}
+
+// permanentError is a failure retrying won't fix, such as a rejected
+// credential or a malformed request.
+type permanentError struct{ error }
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// sender carries what the senders share: the HTTP client and the Telegram API
+// base, which tests point at a local server.
+type sender struct {
+	client      *http.Client
+	telegramAPI string
+}
+
+// post sends a request body and treats any non-2xx answer as a failure,
+// permanent for client errors other than timeouts and rate limits.
+func (s *sender) post(ctx context.Context, target, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "OpenPaw")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s answered %d: %s", hostOf(target), resp.StatusCode, strings.TrimSpace(string(snippet)))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
+
+func (s *sender) postJSON(ctx context.Context, target string, payload interface{}, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return permanentError{err}
+	}
+	return s.post(ctx, target, "application/json", body, header)
+}
+
+// hostOf names the service in an error without echoing a URL that may carry
+// a token in its path.
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "the server"
+}
+
+func sendNtfy(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	server := strings.TrimRight(settings["server"], "/")
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	header := http.Header{}
+	header.Set("Title", mimeHeader(m.Title))
+	header.Set("Priority", map[string]string{"low": "2", "high": "4"}[m.Priority])
+	if header.Get("Priority") == "" {
+		header.Set("Priority", "3")
+	}
+	if m.SourceType != "" {
+		header.Set("Tags", m.SourceType)
+	}
+	if token := settings["token"]; token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	body := m.Body
+	if body == "" {
+		body = m.Title
+	}
+	return s.post(ctx, server+"/"+settings["topic"], "text/plain; charset=utf-8", []byte(truncate(body, 4000)), header)
+}
+
+func sendGotify(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	priority := map[string]int{"low": 2, "high": 8}[m.Priority]
+	if priority == 0 {
+		priority = 5
+	}
+	header := http.Header{}
+	header.Set("X-Gotify-Key", settings["token"])
+	message := m.Body
+	if message == "" {
+		message = m.Title
+	}
+	return s.postJSON(ctx, strings.TrimRight(settings["server"], "/")+"/message", map[string]interface{}{
+		"title":    m.Title,
+		"message":  message,
+		"priority": priority,
+	}, header)
+}
+
+func sendTelegram(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	return s.postJSON(ctx, s.telegramAPI+"/bot"+settings["bot_token"]+"/sendMessage", map[string]interface{}{
+		"chat_id":                  settings["chat_id"],
+		"text":                     truncate(m.text(), 4000),
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+func sendSlack(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	text := "*" + m.Title + "*"
+	if m.Body != "" {
+		text += "\n" + m.Body
+	}
+	return s.postJSON(ctx, settings["webhook_url"], map[string]string{"text": truncate(text, 3000)}, nil)
+}
+
+func sendDiscord(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	text := "**" + m.Title + "**"
+	if m.Body != "" {
+		text += "\n" + m.Body
+	}
+	return s.postJSON(ctx, settings["webhook_url"], map[string]string{"content": truncate(text, 1900)}, nil)
+}
+
+// sendWebhook posts the whole notification as JSON. With a signing secret the
+// body is signed the way inbound webhook automations verify it:
+// X-OpenPaw-Signature: sha256=<hex HMAC-SHA256 of the body>.
+func sendWebhook(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	body, err := json.Marshal(map[string]interface{}{"event": "notification", "notification": m})
+	if err != nil {
+		return permanentError{err}
+	}
+	header := http.Header{}
+	header.Set("X-OpenPaw-Event", "notification")
+	if secret := settings["signing_secret"]; secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		header.Set("X-OpenPaw-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return s.post(ctx, settings["url"], "application/json", body, header)
+}
+
+// sendEmail sends through an SMTP server. Port 465 speaks TLS from the
+// start; any other port upgrades with STARTTLS when the server offers it.
+// "to" is a comma-separated list of addresses.
+func sendEmail(ctx context.Context, s *sender, settings map[string]string, m Message) error {
+	host := settings["host"]
+	port := settings["port"]
+	if port == "" {
+		port = "587"
+	}
+	var to []string
+	for _, addr := range strings.Split(settings["to"], ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if len(to) == 0 {
+		return permanentError{errors.New("no recipients")}
+	}
+
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	var conn net.Conn
+	var err error
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if password := settings["password"]; password != "" {
+		username := settings["username"]
+		if username == "" {
+			username = settings["from"]
+		}
+		if err := c.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return permanentError{fmt.Errorf("SMTP login: %w", err)}
+		}
+	}
+	if err := c.Mail(settings["from"]); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(emailMessage(settings["from"], to, m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailMessage builds a plain-text message. The full report goes in the
+// body, since email has room for it.
+func emailMessage(from string, to []string, m Message) []byte {
+	body := m.Detail
+	if body == "" {
+		body = m.Body
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mimeHeader(m.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	if m.Priority == "high" {
+		b.WriteString("X-Priority: 1\r\n")
+	}
+	b.WriteString("\r\n")
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteString(strings.TrimRight(line, "\r") + "\r\n")
+	}
+	return []byte(b.String())
+}
+
+// mimeHeader puts a value on one header line, encoding it if it isn't plain
+// ASCII.
+func mimeHeader(s string) string {
+	return mime.BEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(s))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
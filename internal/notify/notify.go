@@ -0,0 +1,819 @@
+// Package notify sends notifications out of the app: to email, ntfy or Gotify
+// push, a Telegram chat, a Slack or Discord channel, or a signed webhook.
+// Rules pick which notifications go to which channel by priority, source,
+// workspace and agent, and can hold deliveries through quiet hours. Every
+// send is logged per channel and retried with backoff until it goes through
+// or the channel rejects it for good.
+//
+// Routing doesn't depend on how a notification was created: the dispatcher
+// picks up every notification not yet routed, so the scheduler, heartbeat,
+// dreaming, budgets, tmux alerts and agent inbox posts are all covered.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+// Delivery statuses.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+const (
+	// pollInterval is how often the dispatcher looks for new notifications
+	// and due retries when nothing kicks it.
+	pollInterval = 10 * time.Second
+	// sendTimeout bounds one attempt at one channel.
+	sendTimeout = 30 * time.Second
+	// routeBatch and sendBatch bound one pass, so a burst of notifications
+	// doesn't hold the loop for long.
+	routeBatch = 100
+	sendBatch  = 50
+	// keepDeliveries is how long finished deliveries stay in the log.
+	keepDeliveries = 30 * 24 * time.Hour
+)
+
+// retryBackoff is the wait after each failed attempt. A delivery that has
+// failed once more than there are entries here is given up on.
+var retryBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+}
+
+// SecretDecryptor decrypts stored secret values. Satisfied by secrets.Manager.
+type SecretDecryptor interface {
+	Decrypt(encrypted string) (string, error)
+}
+
+// Channel is a place notifications can be sent. Secrets maps a credential
+// setting to the name of the secret holding its value.
+type Channel struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Config    map[string]string `json:"config"`
+	Secrets   map[string]string `json:"secrets"`
+	Enabled   bool              `json:"enabled"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Rule sends the notifications it matches to one channel. Empty conditions
+// match anything. QuietStart and QuietEnd ("HH:MM", in Timezone) hold
+// matching deliveries until the window ends; high-priority ones still go
+// out when QuietAllowsHigh is set.
+type Rule struct {
+	ID              string    `json:"id"`
+	ChannelID       string    `json:"channel_id"`
+	Name            string    `json:"name"`
+	MinPriority     string    `json:"min_priority"`
+	SourceTypes     []string  `json:"source_types"`
+	WorkspaceID     string    `json:"workspace_id"`
+	AgentSlug       string    `json:"agent_slug"`
+	QuietStart      string    `json:"quiet_start"`
+	QuietEnd        string    `json:"quiet_end"`
+	Timezone        string    `json:"timezone"`
+	QuietAllowsHigh bool      `json:"quiet_allows_high"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Delivery is one notification's entry in a channel's delivery log.
+type Delivery struct {
+	ID                string     `json:"id"`
+	NotificationID    string     `json:"notification_id"`
+	NotificationTitle string     `json:"notification_title"`
+	ChannelID         string     `json:"channel_id"`
+	RuleID            string     `json:"rule_id"`
+	Status            string     `json:"status"`
+	Attempts          int        `json:"attempts"`
+	LastError         string     `json:"last_error"`
+	NextAttemptAt     time.Time  `json:"next_attempt_at"`
+	SentAt            *time.Time `json:"sent_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+var priorityRank = map[string]int{"low": 0, "normal": 1, "high": 2}
+
+func rank(priority string) int {
+	if r, ok := priorityRank[priority]; ok {
+		return r
+	}
+	return priorityRank["normal"]
+}
+
+// sourceAliases maps the names people use for a source to the source_type
+// notifications carry.
+var sourceAliases = map[string]string{"dreaming": "dream", "schedules": "schedule", "heartbeats": "heartbeat"}
+
+// Matches reports whether the rule applies to a notification. Quiet hours
+// don't affect matching; they only delay the send.
+func (r *Rule) Matches(m Message) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.MinPriority != "" && rank(m.Priority) < rank(r.MinPriority) {
+		return false
+	}
+	if len(r.SourceTypes) > 0 {
+		found := false
+		for _, s := range r.SourceTypes {
+			if s == m.SourceType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.WorkspaceID != "" && r.WorkspaceID != m.WorkspaceID {
+		return false
+	}
+	if r.AgentSlug != "" && r.AgentSlug != m.SourceAgentSlug {
+		return false
+	}
+	return true
+}
+
+var clockRe = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+func minutesOf(clock string) int {
+	var h, m int
+	fmt.Sscanf(clock, "%d:%d", &h, &m)
+	return h*60 + m
+}
+
+// holdUntil returns when a delivery the rule matched may go out, if now falls
+// inside its quiet hours. A window whose end is before its start runs past
+// midnight (22:00-07:00).
+func (r *Rule) holdUntil(now time.Time, priority string) (time.Time, bool) {
+	if r.QuietStart == "" || r.QuietEnd == "" || r.QuietStart == r.QuietEnd {
+		return time.Time{}, false
+	}
+	if r.QuietAllowsHigh && priority == "high" {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	current := local.Hour()*60 + local.Minute()
+	start, end := minutesOf(r.QuietStart), minutesOf(r.QuietEnd)
+	var quiet bool
+	if start < end {
+		quiet = current >= start && current < end
+	} else {
+		quiet = current >= start || current < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	release := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !release.After(local) {
+		release = release.AddDate(0, 0, 1)
+	}
+	return release.UTC(), true
+}
+
+// Validate checks a rule before it is saved and fills in defaults.
+func (r *Rule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.MinPriority = strings.ToLower(strings.TrimSpace(r.MinPriority))
+	if _, ok := priorityRank[r.MinPriority]; r.MinPriority != "" && !ok {
+		return fmt.Errorf("min_priority must be low, normal or high")
+	}
+	sources := []string{}
+	for _, s := range r.SourceTypes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if alias, ok := sourceAliases[s]; ok {
+			s = alias
+		}
+		if s != "" {
+			sources = append(sources, s)
+		}
+	}
+	r.SourceTypes = sources
+	r.QuietStart, r.QuietEnd = strings.TrimSpace(r.QuietStart), strings.TrimSpace(r.QuietEnd)
+	if (r.QuietStart == "") != (r.QuietEnd == "") {
+		return fmt.Errorf("quiet hours need both quiet_start and quiet_end")
+	}
+	for _, clock := range []string{r.QuietStart, r.QuietEnd} {
+		if clock != "" && !clockRe.MatchString(clock) {
+			return fmt.Errorf("quiet hours must be HH:MM (24-hour)")
+		}
+	}
+	if r.Timezone == "" {
+		r.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	return nil
+}
+
+// Dispatcher routes notifications to channels and sends them.
+type Dispatcher struct {
+	db      *database.DB
+	secrets SecretDecryptor
+	sender  *sender
+	now     func() time.Time
+
+	kick       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+	lastPruned time.Time
+}
+
+// New returns a dispatcher; Start runs it.
+func New(db *database.DB, secrets SecretDecryptor) *Dispatcher {
+	return &Dispatcher{
+		db:      db,
+		secrets: secrets,
+		sender: &sender{
+			client:      &http.Client{Timeout: sendTimeout},
+			telegramAPI: "https://api.telegram.org",
+		},
+		now:  func() time.Time { return time.Now().UTC() },
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start runs the dispatch loop until Close.
+func (d *Dispatcher) Start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-d.stop
+			cancel()
+		}()
+		for {
+			d.step(ctx)
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			case <-d.kick:
+			}
+		}
+	}()
+}
+
+// Kick asks the loop to run now, so a new notification goes out without
+// waiting for the next poll.
+func (d *Dispatcher) Kick() {
+	select {
+	case d.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the dispatch loop, abandoning any send in flight; it is retried
+// after the next start.
+func (d *Dispatcher) Close() {
+	close(d.stop)
+	<-d.done
+}
+
+// step routes new notifications, sends what is due and prunes the log.
+func (d *Dispatcher) step(ctx context.Context) {
+	if err := d.route(); err != nil {
+		logger.Warn("Notification routing: %v", err)
+	}
+	if err := d.sendDue(ctx); err != nil {
+		logger.Warn("Notification delivery: %v", err)
+	}
+	if now := d.now(); now.Sub(d.lastPruned) > time.Hour {
+		d.lastPruned = now
+		d.db.Exec(`DELETE FROM notification_deliveries WHERE status != ? AND created_at < ?`,
+			StatusPending, now.Add(-keepDeliveries))
+	}
+}
+
+const messageColumns = `n.id, n.title, n.body, n.detail, n.priority, n.source_type, n.source_agent_slug,
+	n.workspace_id, n.link, n.created_at`
+
+func (m *Message) fields() []interface{} {
+	return []interface{}{&m.NotificationID, &m.Title, &m.Body, &m.Detail, &m.Priority, &m.SourceType,
+		&m.SourceAgentSlug, &m.WorkspaceID, &m.Link, &m.CreatedAt}
+}
+
+// route creates the deliveries for notifications not yet routed: one per
+// channel with a matching rule, held to the end of quiet hours when every
+// matching rule for that channel is in them.
+func (d *Dispatcher) route() error {
+	rows, err := d.db.Query(`SELECT `+messageColumns+` FROM notifications n WHERE n.routed = 0
+		ORDER BY n.created_at LIMIT ?`, routeBatch)
+	if err != nil {
+		return err
+	}
+	var pending []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(m.fields()...); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, m)
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rules, err := d.activeRules()
+	if err != nil {
+		return err
+	}
+	now := d.now()
+	for _, m := range pending {
+		type target struct {
+			ruleID string
+			at     time.Time
+		}
+		targets := map[string]target{}
+		for _, r := range rules {
+			if !r.Matches(m) {
+				continue
+			}
+			at := now
+			if release, held := r.holdUntil(now, m.Priority); held {
+				at = release
+			}
+			if t, ok := targets[r.ChannelID]; !ok || at.Before(t.at) {
+				targets[r.ChannelID] = target{ruleID: r.ID, at: at}
+			}
+		}
+
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		for channelID, t := range targets {
+			if _, err := tx.Exec(
+				`INSERT INTO notification_deliveries (id, notification_id, channel_id, rule_id, status, next_attempt_at, created_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				uuid.New().String(), m.NotificationID, channelID, t.ruleID, StatusPending, t.at, now,
+			); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE notifications SET routed = 1 WHERE id = ?`, m.NotificationID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeRules loads the enabled rules of enabled channels.
+func (d *Dispatcher) activeRules() ([]*Rule, error) {
+	return d.queryRules(`WHERE r.enabled = 1 AND r.channel_id IN (SELECT id FROM notification_channels WHERE enabled = 1)`)
+}
+
+// sendDue makes one attempt at every pending delivery whose time has come.
+func (d *Dispatcher) sendDue(ctx context.Context) error {
+	rows, err := d.db.Query(`SELECT d.id, d.channel_id, d.attempts, `+messageColumns+`
+		FROM notification_deliveries d JOIN notifications n ON n.id = d.notification_id
+		WHERE d.status = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at LIMIT ?`, StatusPending, d.now(), sendBatch)
+	if err != nil {
+		return err
+	}
+	type due struct {
+		id, channelID string
+		attempts      int
+		msg           Message
+	}
+	var batch []due
+	for rows.Next() {
+		var x due
+		if err := rows.Scan(append([]interface{}{&x.id, &x.channelID, &x.attempts}, x.msg.fields()...)...); err != nil {
+			rows.Close()
+			return err
+		}
+		batch = append(batch, x)
+	}
+	rows.Close()
+
+	channels := map[string]*Channel{}
+	for _, x := range batch {
+		if ctx.Err() != nil {
+			return nil
+		}
+		ch, ok := channels[x.channelID]
+		if !ok {
+			ch, err = d.GetChannel(x.channelID)
+			if err != nil {
+				return err
+			}
+			channels[x.channelID] = ch
+		}
+		var sendErr error
+		if ch.Enabled {
+			sendErr = d.send(ctx, ch, x.msg)
+		} else {
+			sendErr = permanentError{errors.New("channel is disabled")}
+		}
+		if ctx.Err() != nil {
+			// Shutting down: leave the delivery as it was.
+			return nil
+		}
+		d.recordAttempt(x.id, x.attempts+1, sendErr)
+	}
+	return nil
+}
+
+// recordAttempt logs the outcome of one send and schedules the next try.
+func (d *Dispatcher) recordAttempt(id string, attempts int, sendErr error) {
+	now := d.now()
+	var err error
+	switch {
+	case sendErr == nil:
+		_, err = d.db.Exec(`UPDATE notification_deliveries SET status = ?, attempts = ?, last_error = '', sent_at = ? WHERE id = ?`,
+			StatusSent, attempts, now, id)
+	case isPermanent(sendErr) || attempts > len(retryBackoff):
+		_, err = d.db.Exec(`UPDATE notification_deliveries SET status = ?, attempts = ?, last_error = ? WHERE id = ?`,
+			StatusFailed, attempts, sendErr.Error(), id)
+	default:
+		_, err = d.db.Exec(`UPDATE notification_deliveries SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+			attempts, sendErr.Error(), now.Add(retryBackoff[attempts-1]), id)
+	}
+	if err != nil {
+		logger.Warn("Record notification delivery %s: %v", id, err)
+	}
+}
+
+// send makes one attempt at sending a message through a channel.
+func (d *Dispatcher) send(ctx context.Context, ch *Channel, m Message) error {
+	ct, ok := channelTypes[ch.Type]
+	if !ok {
+		return permanentError{fmt.Errorf("unknown channel type %q", ch.Type)}
+	}
+	settings := map[string]string{}
+	for k, v := range ch.Config {
+		settings[k] = v
+	}
+	for key, secretName := range ch.Secrets {
+		value, err := d.secretValue(secretName)
+		if err != nil {
+			return permanentError{fmt.Errorf("%s: %w", key, err)}
+		}
+		settings[key] = value
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return ct.send(ctx, d.sender, settings, m)
+}
+
+func (d *Dispatcher) secretValue(name string) (string, error) {
+	var encrypted string
+	if err := d.db.QueryRow("SELECT encrypted_value FROM secrets WHERE name = ?", name).Scan(&encrypted); err != nil {
+		return "", fmt.Errorf("secret %q not found — add it in Settings → Secrets", name)
+	}
+	if d.secrets == nil {
+		return "", fmt.Errorf("secret %q cannot be decrypted: no secrets manager", name)
+	}
+	value, err := d.secrets.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("secret %q could not be decrypted", name)
+	}
+	if value == "REPLACE_ME" {
+		return "", fmt.Errorf("secret %q is still a REPLACE_ME placeholder", name)
+	}
+	return value, nil
+}
+
+// Test sends a sample notification through a channel right away and returns
+// what the channel said. Nothing is logged.
+func (d *Dispatcher) Test(ctx context.Context, channelID string) error {
+	ch, err := d.GetChannel(channelID)
+	if err != nil {
+		return err
+	}
+	err = d.send(ctx, ch, Message{
+		Title:      "OpenPaw test notification",
+		Body:       fmt.Sprintf("If you can read this, the %q channel works.", ch.Name),
+		Priority:   "normal",
+		SourceType: "test",
+		CreatedAt:  d.now(),
+	})
+	var p permanentError
+	if errors.As(err, &p) {
+		return p.error
+	}
+	return err
+}
+
+// Retry puts a delivery back in the queue for an immediate attempt, with its
+// retries starting over.
+func (d *Dispatcher) Retry(deliveryID string) (*Delivery, error) {
+	res, err := d.db.Exec(`UPDATE notification_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND status != ?`, StatusPending, d.now(), deliveryID, StatusSent)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var status string
+		if err := d.db.QueryRow(`SELECT status FROM notification_deliveries WHERE id = ?`, deliveryID).Scan(&status); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("this notification was already delivered")
+	}
+	d.Kick()
+	return d.getDelivery(deliveryID)
+}
+
+// --- channels ---
+
+const channelColumns = `id, name, type, config, secrets, enabled, created_at, updated_at`
+
+func scanChannel(row interface{ Scan(...interface{}) error }) (*Channel, error) {
+	var c Channel
+	var config, secrets string
+	if err := row.Scan(&c.ID, &c.Name, &c.Type, &config, &secrets, &c.Enabled, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(config), &c.Config)
+	json.Unmarshal([]byte(secrets), &c.Secrets)
+	if c.Config == nil {
+		c.Config = map[string]string{}
+	}
+	if c.Secrets == nil {
+		c.Secrets = map[string]string{}
+	}
+	return &c, nil
+}
+
+// ListChannels loads every channel.
+func (d *Dispatcher) ListChannels() ([]*Channel, error) {
+	rows, err := d.db.Query("SELECT " + channelColumns + " FROM notification_channels ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	channels := []*Channel{}
+	for rows.Next() {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// GetChannel loads one channel; sql.ErrNoRows if there is none.
+func (d *Dispatcher) GetChannel(id string) (*Channel, error) {
+	return scanChannel(d.db.QueryRow("SELECT "+channelColumns+" FROM notification_channels WHERE id = ?", id))
+}
+
+// Validate checks a channel before it is saved: its name, type, required
+// settings and secret references.
+func (c *Channel) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.Config == nil {
+		c.Config = map[string]string{}
+	}
+	if c.Secrets == nil {
+		c.Secrets = map[string]string{}
+	}
+	return ValidateChannel(c.Type, c.Config, c.Secrets)
+}
+
+// CreateChannel validates and stores a new channel, filling in its ID and
+// timestamps.
+func (d *Dispatcher) CreateChannel(c *Channel) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	config, _ := json.Marshal(c.Config)
+	secrets, _ := json.Marshal(c.Secrets)
+	c.ID = uuid.New().String()
+	c.CreatedAt = d.now()
+	c.UpdatedAt = c.CreatedAt
+	_, err := d.db.Exec(`INSERT INTO notification_channels (`+channelColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Name, c.Type, string(config), string(secrets), c.Enabled, c.CreatedAt, c.UpdatedAt)
+	return err
+}
+
+// UpdateChannel validates and saves an edited channel.
+func (d *Dispatcher) UpdateChannel(c *Channel) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	config, _ := json.Marshal(c.Config)
+	secrets, _ := json.Marshal(c.Secrets)
+	c.UpdatedAt = d.now()
+	res, err := d.db.Exec(`UPDATE notification_channels SET name = ?, type = ?, config = ?, secrets = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`, c.Name, c.Type, string(config), string(secrets), c.Enabled, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteChannel removes a channel with its rules and delivery log.
+func (d *Dispatcher) DeleteChannel(id string) error {
+	res, err := d.db.Exec(`DELETE FROM notification_channels WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- rules ---
+
+const ruleColumns = `r.id, r.channel_id, r.name, r.min_priority, r.source_types, r.workspace_id, r.agent_slug,
+	r.quiet_start, r.quiet_end, r.timezone, r.quiet_allows_high, r.enabled, r.created_at, r.updated_at`
+
+func (d *Dispatcher) queryRules(where string, args ...interface{}) ([]*Rule, error) {
+	rows, err := d.db.Query("SELECT "+ruleColumns+" FROM notification_rules r "+where+" ORDER BY r.created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []*Rule{}
+	for rows.Next() {
+		var r Rule
+		var sources string
+		if err := rows.Scan(&r.ID, &r.ChannelID, &r.Name, &r.MinPriority, &sources, &r.WorkspaceID, &r.AgentSlug,
+			&r.QuietStart, &r.QuietEnd, &r.Timezone, &r.QuietAllowsHigh, &r.Enabled, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(sources), &r.SourceTypes)
+		if r.SourceTypes == nil {
+			r.SourceTypes = []string{}
+		}
+		rules = append(rules, &r)
+	}
+	return rules, rows.Err()
+}
+
+// ListRules loads the rules of one channel, or every rule when channelID is
+// empty.
+func (d *Dispatcher) ListRules(channelID string) ([]*Rule, error) {
+	if channelID == "" {
+		return d.queryRules("")
+	}
+	return d.queryRules("WHERE r.channel_id = ?", channelID)
+}
+
+// GetRule loads one rule; sql.ErrNoRows if there is none.
+func (d *Dispatcher) GetRule(id string) (*Rule, error) {
+	rules, err := d.queryRules("WHERE r.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return rules[0], nil
+}
+
+// CreateRule validates and stores a new rule for an existing channel.
+func (d *Dispatcher) CreateRule(r *Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if _, err := d.GetChannel(r.ChannelID); err != nil {
+		return fmt.Errorf("channel_id must name an existing channel")
+	}
+	sources, _ := json.Marshal(r.SourceTypes)
+	r.ID = uuid.New().String()
+	r.CreatedAt = d.now()
+	r.UpdatedAt = r.CreatedAt
+	_, err := d.db.Exec(`INSERT INTO notification_rules (id, channel_id, name, min_priority, source_types, workspace_id,
+		agent_slug, quiet_start, quiet_end, timezone, quiet_allows_high, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.ChannelID, r.Name, r.MinPriority, string(sources), r.WorkspaceID, r.AgentSlug,
+		r.QuietStart, r.QuietEnd, r.Timezone, r.QuietAllowsHigh, r.Enabled, r.CreatedAt, r.UpdatedAt)
+	return err
+}
+
+// UpdateRule validates and saves an edited rule. A rule stays with the
+// channel it was created for.
+func (d *Dispatcher) UpdateRule(r *Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	sources, _ := json.Marshal(r.SourceTypes)
+	r.UpdatedAt = d.now()
+	res, err := d.db.Exec(`UPDATE notification_rules SET name = ?, min_priority = ?, source_types = ?, workspace_id = ?,
+		agent_slug = ?, quiet_start = ?, quiet_end = ?, timezone = ?, quiet_allows_high = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		r.Name, r.MinPriority, string(sources), r.WorkspaceID, r.AgentSlug, r.QuietStart, r.QuietEnd, r.Timezone,
+		r.QuietAllowsHigh, r.Enabled, r.UpdatedAt, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRule removes a rule. Deliveries it already queued still go out.
+func (d *Dispatcher) DeleteRule(id string) error {
+	res, err := d.db.Exec(`DELETE FROM notification_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- delivery log ---
+
+const deliveryColumns = `d.id, d.notification_id, n.title, d.channel_id, d.rule_id, d.status, d.attempts,
+	d.last_error, d.next_attempt_at, d.sent_at, d.created_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*Delivery, error) {
+	var x Delivery
+	var sent sql.NullTime
+	if err := row.Scan(&x.ID, &x.NotificationID, &x.NotificationTitle, &x.ChannelID, &x.RuleID, &x.Status,
+		&x.Attempts, &x.LastError, &x.NextAttemptAt, &sent, &x.CreatedAt); err != nil {
+		return nil, err
+	}
+	if sent.Valid {
+		x.SentAt = &sent.Time
+	}
+	return &x, nil
+}
+
+func (d *Dispatcher) getDelivery(id string) (*Delivery, error) {
+	return scanDelivery(d.db.QueryRow(`SELECT `+deliveryColumns+`
+		FROM notification_deliveries d JOIN notifications n ON n.id = d.notification_id WHERE d.id = ?`, id))
+}
+
+// Deliveries returns a channel's delivery log, newest first, optionally only
+// those with one status.
+func (d *Dispatcher) Deliveries(channelID, status string, limit int) ([]*Delivery, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT ` + deliveryColumns + `
+		FROM notification_deliveries d JOIN notifications n ON n.id = d.notification_id
+		WHERE d.channel_id = ?`
+	args := []interface{}{channelID}
+	if status != "" {
+		query += ` AND d.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY d.created_at DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		x, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, x)
+	}
+	return deliveries, rows.Err()
+}
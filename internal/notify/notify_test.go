@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+// plainSecrets stores secrets unencrypted, standing in for secrets.Manager.
+type plainSecrets struct{}
+
+func (plainSecrets) Decrypt(s string) (string, error) { return s, nil }
+
+func newDispatcher(t *testing.T) (*Dispatcher, *time.Time) {
+	t.Helper()
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	d := New(db, plainSecrets{})
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+func notification(t *testing.T, d *Dispatcher, id, priority, source string) {
+	t.Helper()
+	if _, err := d.db.Exec(
+		`INSERT INTO notifications (id, title, body, priority, source_type, source_agent_slug, created_at)
+		 VALUES (?, ?, 'All checks passed.', ?, ?, 'ops', ?)`, id, "Report "+id, priority, source, d.now()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deliveryStatus(t *testing.T, d *Dispatcher, notificationID string) (status string, attempts int) {
+	t.Helper()
+	err := d.db.QueryRow(`SELECT status, attempts FROM notification_deliveries WHERE notification_id = ?`,
+		notificationID).Scan(&status, &attempts)
+	if err != nil {
+		return "", 0
+	}
+	return status, attempts
+}
+
+func TestWebhookRoutingAndSignature(t *testing.T) {
+	d, _ := newDispatcher(t)
+	var mu sync.Mutex
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("shh"))
+		mac.Write(body)
+		if r.Header.Get("X-OpenPaw-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var payload map[string]interface{}
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		got = append(got, payload["notification"].(map[string]interface{}))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	d.db.Exec(`INSERT INTO secrets (id, name, encrypted_value) VALUES ('s1', 'HOOK_SECRET', 'shh')`)
+
+	ch := &Channel{Name: "Ops hook", Type: TypeWebhook, Enabled: true,
+		Config: map[string]string{"url": srv.URL}, Secrets: map[string]string{"signing_secret": "HOOK_SECRET"}}
+	if err := d.CreateChannel(ch); err != nil {
+		t.Fatal(err)
+	}
+	rule := &Rule{ChannelID: ch.ID, MinPriority: "normal", SourceTypes: []string{"Schedule", "dreaming"}, Enabled: true}
+	if err := d.CreateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rule.SourceTypes, ",") != "schedule,dream" {
+		t.Errorf("source types = %v", rule.SourceTypes)
+	}
+
+	notification(t, d, "n1", "normal", "schedule")
+	notification(t, d, "n2", "low", "schedule")
+	notification(t, d, "n3", "high", "heartbeat")
+	d.step(context.Background())
+
+	if status, _ := deliveryStatus(t, d, "n1"); status != StatusSent {
+		t.Errorf("n1 status = %q", status)
+	}
+	for _, id := range []string{"n2", "n3"} {
+		if status, _ := deliveryStatus(t, d, id); status != "" {
+			t.Errorf("%s was routed (%s)", id, status)
+		}
+	}
+	if len(got) != 1 || got[0]["id"] != "n1" || got[0]["title"] != "Report n1" {
+		t.Errorf("webhook received %v", got)
+	}
+	var unrouted int
+	d.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE routed = 0`).Scan(&unrouted)
+	if unrouted != 0 {
+		t.Errorf("%d notifications left unrouted", unrouted)
+	}
+}
+
+func TestRetryWithBackoffAndPermanentFailure(t *testing.T) {
+	d, now := newDispatcher(t)
+	var calls int
+	status := http.StatusBadGateway
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	ch := &Channel{Name: "Push", Type: TypeNtfy, Enabled: true,
+		Config: map[string]string{"server": srv.URL, "topic": "alerts"}}
+	if err := d.CreateChannel(ch); err != nil {
+		t.Fatal(err)
+	}
+	d.CreateRule(&Rule{ChannelID: ch.ID, Enabled: true})
+
+	notification(t, d, "n1", "normal", "heartbeat")
+	d.step(context.Background())
+	if s, attempts := deliveryStatus(t, d, "n1"); s != StatusPending || attempts != 1 {
+		t.Fatalf("after a 502: %s, %d attempts", s, attempts)
+	}
+	// Not due yet: the first retry waits 30 seconds.
+	d.step(context.Background())
+	if calls != 1 {
+		t.Fatalf("retried early: %d calls", calls)
+	}
+	*now = now.Add(time.Minute)
+	status = http.StatusOK
+	d.step(context.Background())
+	if s, attempts := deliveryStatus(t, d, "n1"); s != StatusSent || attempts != 2 {
+		t.Errorf("after the retry: %s, %d attempts", s, attempts)
+	}
+
+	status = http.StatusForbidden
+	notification(t, d, "n2", "normal", "heartbeat")
+	d.step(context.Background())
+	s, _ := deliveryStatus(t, d, "n2")
+	if s != StatusFailed {
+		t.Fatalf("a 403 left the delivery %s", s)
+	}
+	log, err := d.Deliveries(ch.ID, StatusFailed, 0)
+	if err != nil || len(log) != 1 || !strings.Contains(log[0].LastError, "403") {
+		t.Fatalf("failed log = %+v %v", log, err)
+	}
+
+	status = http.StatusOK
+	if _, err := d.Retry(log[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	d.step(context.Background())
+	if s, _ := deliveryStatus(t, d, "n2"); s != StatusSent {
+		t.Errorf("manual retry left it %s", s)
+	}
+	if _, err := d.Retry(log[0].ID); err == nil {
+		t.Error("retried a delivered notification")
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	r := &Rule{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Europe/Berlin", QuietAllowsHigh: true}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		local    string
+		priority string
+		release  string
+	}{
+		{"2026-03-02 21:59", "normal", ""},
+		{"2026-03-02 23:30", "normal", "2026-03-03 07:00"},
+		{"2026-03-03 06:15", "low", "2026-03-03 07:00"},
+		{"2026-03-03 07:00", "normal", ""},
+		{"2026-03-02 23:30", "high", ""},
+	}
+	for _, c := range cases {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", c.local, berlin)
+		release, held := r.holdUntil(now, c.priority)
+		want := c.release != ""
+		if held != want {
+			t.Errorf("%s %s: held = %v", c.local, c.priority, held)
+			continue
+		}
+		if held {
+			if got := release.In(berlin).Format("2006-01-02 15:04"); got != c.release {
+				t.Errorf("%s: released at %s, want %s", c.local, got, c.release)
+			}
+		}
+	}
+
+	day := &Rule{QuietStart: "12:00", QuietEnd: "13:00", Timezone: "UTC"}
+	if _, held := day.holdUntil(time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC), "high"); !held {
+		t.Error("high priority bypassed quiet hours without quiet_allows_high")
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	for _, c := range []struct {
+		typ     string
+		config  map[string]string
+		secrets map[string]string
+		ok      bool
+	}{
+		{TypeTelegram, map[string]string{"chat_id": "42"}, map[string]string{"bot_token": "TG"}, true},
+		{TypeTelegram, map[string]string{"chat_id": "42"}, nil, false},
+		{TypeSlack, nil, map[string]string{"webhook_url": "SLACK"}, true},
+		{TypeEmail, map[string]string{"host": "smtp.example.com", "from": "a@example.com"}, nil, false},
+		{TypeWebhook, map[string]string{"url": "ftp://example.com"}, nil, false},
+		{TypeNtfy, map[string]string{"topic": "t"}, map[string]string{"password": "X"}, false},
+		{"pager", nil, nil, false},
+	} {
+		if err := ValidateChannel(c.typ, c.config, c.secrets); (err == nil) != c.ok {
+			t.Errorf("%s %v %v: %v", c.typ, c.config, c.secrets, err)
+		}
+	}
+}
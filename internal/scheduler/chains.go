@@ -0,0 +1,325 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/logger"
+	"github.com/openpaw/openpaw/internal/models"
+)
+
+// When a chain step runs, relative to its upstream's outcome.
+const (
+	RunOnSuccess = "success"
+	RunOnFailure = "failure"
+	RunOnAlways  = "always"
+)
+
+// Conditions a step can test against its upstream's output.
+const (
+	ConditionNone        = ""
+	ConditionContains    = "contains"
+	ConditionNotContains = "not_contains"
+	ConditionRegex       = "regex"
+)
+
+// MaxChainSteps bounds a chain: each step is a full agent run.
+const MaxChainSteps = 20
+
+// ValidateChainStep reports the first problem with a step definition.
+func ValidateChainStep(step models.ScheduleChainStep) error {
+	switch step.RunOn {
+	case RunOnSuccess, RunOnFailure, RunOnAlways:
+	default:
+		return errors.New("run_on must be success, failure or always")
+	}
+	if step.AgentRoleSlug == "" {
+		return errors.New("every step needs an agent_role_slug")
+	}
+	if _, err := ConditionMatches(step.ConditionType, step.ConditionValue, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConditionMatches tests a step's condition against the upstream output.
+// Matching is case-insensitive for the substring conditions; a regex can opt
+// in or out itself with (?i).
+func ConditionMatches(condType, value, output string) (bool, error) {
+	switch condType {
+	case ConditionNone:
+		return true, nil
+	case ConditionContains:
+		return strings.Contains(strings.ToLower(output), strings.ToLower(value)), nil
+	case ConditionNotContains:
+		return !strings.Contains(strings.ToLower(output), strings.ToLower(value)), nil
+	case ConditionRegex:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid condition regex: %w", err)
+		}
+		return re.MatchString(output), nil
+	}
+	return false, errors.New("condition_type must be contains, not_contains or regex")
+}
+
+// renderStepPrompt builds a step's prompt from its template and the upstream
+// outcome. A template that never mentions {{output}} still gets the upstream
+// output appended — handing it on is the point of chaining.
+func renderStepPrompt(tmpl, status, output, errStr string) string {
+	prompt := strings.NewReplacer(
+		"{{output}}", output,
+		"{{error}}", errStr,
+		"{{status}}", status,
+	).Replace(tmpl)
+	if !strings.Contains(tmpl, "{{output}}") && output != "" {
+		prompt = strings.TrimSpace(prompt) + "\n\n---\nOutput of the previous step:\n\n" + output
+	}
+	return prompt
+}
+
+// outcome is what a finished run or step hands to the steps under it.
+type outcome struct {
+	status string // "success", "error" or "skipped"
+	output string
+	errStr string
+	step   string // the step's name, to label its output where steps join
+}
+
+// runChains starts every enabled chain rooted at a schedule once its run has
+// reached a final outcome. It runs in the schedule's own goroutine, so the
+// concurrency policy treats the root run and its chain as one unit.
+func (s *Scheduler) runChains(cfg ScheduleConfig, execID string, root outcome) {
+	rows, err := s.db.Query("SELECT id FROM schedule_chains WHERE root_schedule_id = ? AND enabled = 1", cfg.ID)
+	if err != nil {
+		return
+	}
+	var chainIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			chainIDs = append(chainIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range chainIDs {
+		s.runChain(id, cfg, execID, root)
+	}
+}
+
+// LoadChainSteps returns a chain's steps in their saved order, each with the
+// steps it depends on.
+func LoadChainSteps(db *database.DB, chainID string) ([]models.ScheduleChainStep, error) {
+	rows, err := db.Query(
+		`SELECT id, name, run_on, condition_type, condition_value, agent_role_slug, prompt_template, notify, sort_order
+		 FROM schedule_chain_steps WHERE chain_id = ? ORDER BY sort_order, id`, chainID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	steps := []models.ScheduleChainStep{}
+	index := map[string]int{}
+	for rows.Next() {
+		st := models.ScheduleChainStep{DependsOn: []string{}}
+		if err := rows.Scan(&st.ID, &st.Name, &st.RunOn, &st.ConditionType, &st.ConditionValue,
+			&st.AgentRoleSlug, &st.PromptTemplate, &st.Notify, &st.SortOrder); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[st.ID] = len(steps)
+		steps = append(steps, st)
+	}
+	rows.Close()
+
+	edges, err := db.Query(
+		`SELECT e.step_id, e.depends_on_step_id FROM schedule_chain_edges e
+		 JOIN schedule_chain_steps s ON s.id = e.step_id
+		 JOIN schedule_chain_steps d ON d.id = e.depends_on_step_id
+		 WHERE s.chain_id = ? ORDER BY d.sort_order, d.id`, chainID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer edges.Close()
+	for edges.Next() {
+		var stepID, dependsOn string
+		if err := edges.Scan(&stepID, &dependsOn); err != nil {
+			return nil, err
+		}
+		if i, ok := index[stepID]; ok {
+			steps[i].DependsOn = append(steps[i].DependsOn, dependsOn)
+		}
+	}
+	return steps, edges.Err()
+}
+
+func (s *Scheduler) runChain(chainID string, cfg ScheduleConfig, execID string, root outcome) {
+	steps, err := LoadChainSteps(s.db, chainID)
+	if err != nil {
+		logger.Error("Chain %s: failed to load steps: %v", chainID, err)
+		return
+	}
+	if len(steps) == 0 {
+		return
+	}
+
+	runID := uuid.New().String()
+	s.db.Exec(
+		"INSERT INTO schedule_chain_runs (id, chain_id, root_execution_id, status, started_at) VALUES (?, ?, ?, 'running', ?)",
+		runID, chainID, execID, time.Now().UTC(),
+	)
+	logger.Info("Chain %s started after schedule %s (%s)", chainID, cfg.ID, root.status)
+
+	// A step runs once everything it depends on has an outcome. Passes go in
+	// saved order, so steps that become ready together run as listed.
+	done := map[string]outcome{}
+	failed := false
+	for progress := true; progress; {
+		progress = false
+		for _, st := range steps {
+			if _, ran := done[st.ID]; ran {
+				continue
+			}
+			ups, ready := upstreamOutcomes(st, root, done)
+			if !ready {
+				continue
+			}
+			res := s.runChainStep(runID, st, cfg, ups)
+			if res.status == "error" {
+				failed = true
+			}
+			done[st.ID] = res
+			progress = true
+		}
+	}
+
+	status := "success"
+	if failed {
+		status = "error"
+	}
+	s.db.Exec("UPDATE schedule_chain_runs SET status = ?, finished_at = ? WHERE id = ?", status, time.Now().UTC(), runID)
+}
+
+// upstreamOutcomes returns the outcomes a step waits on — the root run's for a
+// step that depends on nothing — and whether they are all in.
+func upstreamOutcomes(st models.ScheduleChainStep, root outcome, done map[string]outcome) ([]outcome, bool) {
+	if len(st.DependsOn) == 0 {
+		return []outcome{root}, true
+	}
+	ups := make([]outcome, 0, len(st.DependsOn))
+	for _, id := range st.DependsOn {
+		up, ok := done[id]
+		if !ok {
+			return nil, false
+		}
+		ups = append(ups, up)
+	}
+	return ups, true
+}
+
+// allStatus reports whether every upstream finished with status.
+func allStatus(ups []outcome, status string) bool {
+	for _, up := range ups {
+		if up.status != status {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeOutcomes folds the outcomes a step joins into the one its condition
+// and prompt see: a success only if every upstream succeeded, with each
+// upstream's output under its step's name.
+func mergeOutcomes(ups []outcome) outcome {
+	if len(ups) == 1 {
+		return ups[0]
+	}
+	merged := outcome{status: "success"}
+	var outputs, errs []string
+	for _, up := range ups {
+		if up.status != "success" {
+			merged.status = "error"
+		}
+		if up.output != "" {
+			outputs = append(outputs, "## "+up.step+"\n\n"+up.output)
+		}
+		if up.errStr != "" {
+			errs = append(errs, up.step+": "+up.errStr)
+		}
+	}
+	merged.output = strings.Join(outputs, "\n\n")
+	merged.errStr = strings.Join(errs, "; ")
+	return merged
+}
+
+// runChainStep runs (or skips) one step and records it. A skipped step passes
+// "skipped" down, so everything under it is skipped too.
+func (s *Scheduler) runChainStep(runID string, st models.ScheduleChainStep, cfg ScheduleConfig, ups []outcome) outcome {
+	stepRunID := uuid.New().String()
+	now := time.Now().UTC()
+	name := st.Name
+	if name == "" {
+		name = st.ID
+	}
+
+	skip := func(reason string) outcome {
+		s.db.Exec(
+			`INSERT INTO schedule_chain_run_steps (id, run_id, step_id, status, error, started_at, finished_at)
+			 VALUES (?, ?, ?, 'skipped', ?, ?, ?)`,
+			stepRunID, runID, st.ID, reason, now, now,
+		)
+		return outcome{status: "skipped", step: name}
+	}
+
+	for _, up := range ups {
+		if up.status == "skipped" {
+			return skip("Upstream step did not run.")
+		}
+	}
+	switch {
+	case st.RunOn == RunOnSuccess && !allStatus(ups, "success"):
+		return skip("Runs on success; upstream failed.")
+	case st.RunOn == RunOnFailure && !allStatus(ups, "error"):
+		return skip("Runs on failure; upstream succeeded.")
+	}
+	up := mergeOutcomes(ups)
+	if ok, err := ConditionMatches(st.ConditionType, st.ConditionValue, up.output); err != nil {
+		return skip(err.Error())
+	} else if !ok {
+		return skip(fmt.Sprintf("Condition not met (%s %q).", st.ConditionType, st.ConditionValue))
+	}
+
+	s.db.Exec(
+		"INSERT INTO schedule_chain_run_steps (id, run_id, step_id, status, started_at) VALUES (?, ?, ?, 'running', ?)",
+		stepRunID, runID, st.ID, now,
+	)
+
+	stepCfg := ScheduleConfig{
+		ID:            cfg.ID,
+		AgentRoleSlug: st.AgentRoleSlug,
+		PromptContent: renderStepPrompt(st.PromptTemplate, up.status, up.output, up.errStr),
+		WorkspaceID:   cfg.WorkspaceID,
+		Provider:      cfg.Provider,
+	}
+	output, threadID, err := s.executePrompt(stepCfg, DefaultPolicy(), "")
+	res := outcome{status: "success", output: output, step: name}
+	if err != nil {
+		res = outcome{status: "error", output: output, errStr: err.Error(), step: name}
+		logger.Error("Chain step %s failed: %v", st.ID, err)
+	}
+	s.db.Exec(
+		"UPDATE schedule_chain_run_steps SET status = ?, output = ?, error = ?, thread_id = ?, finished_at = ? WHERE id = ?",
+		res.status, output, res.errStr, threadID, time.Now().UTC(), stepRunID,
+	)
+
+	if st.Notify {
+		s.fileReport(stepCfg, stepRunID, res.status, output, res.errStr, threadID)
+	}
+	return res
+}
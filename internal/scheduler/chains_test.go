@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+func TestConditionMatches(t *testing.T) {
+	cases := []struct {
+		condType, value, output string
+		want                    bool
+	}{
+		{ConditionNone, "", "anything", true},
+		{ConditionContains, "ALERT", "status: alert raised", true},
+		{ConditionContains, "alert", "all quiet", false},
+		{ConditionNotContains, "nothing new", "Nothing new today", false},
+		{ConditionNotContains, "nothing new", "3 new papers", true},
+		{ConditionRegex, `\d+ new papers`, "found 3 new papers", true},
+		{ConditionRegex, `^OK$`, "ok", false},
+	}
+	for _, tc := range cases {
+		got, err := ConditionMatches(tc.condType, tc.value, tc.output)
+		if err != nil {
+			t.Errorf("ConditionMatches(%q, %q): %v", tc.condType, tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ConditionMatches(%q, %q, %q) = %v, want %v", tc.condType, tc.value, tc.output, got, tc.want)
+		}
+	}
+
+	if _, err := ConditionMatches(ConditionRegex, "(", ""); err == nil {
+		t.Error("invalid regex accepted")
+	}
+	if _, err := ConditionMatches("equals", "x", ""); err == nil {
+		t.Error("unknown condition type accepted")
+	}
+}
+
+func TestRenderStepPrompt(t *testing.T) {
+	got := renderStepPrompt("Summarize ({{status}}): {{output}}", "success", "three findings", "")
+	if got != "Summarize (success): three findings" {
+		t.Errorf("templated prompt = %q", got)
+	}
+
+	got = renderStepPrompt("Post this to the team.", "success", "three findings", "")
+	if !strings.HasPrefix(got, "Post this to the team.") || !strings.HasSuffix(got, "three findings") {
+		t.Errorf("output was not appended to a template without {{output}}: %q", got)
+	}
+
+	got = renderStepPrompt("Investigate: {{error}}", "error", "", "provider timeout")
+	if got != "Investigate: provider timeout" {
+		t.Errorf("failure prompt = %q", got)
+	}
+}
+
+// recordingSender answers every prompt with a canned reply per agent and
+// remembers what each agent was asked.
+type recordingSender struct {
+	mu      sync.Mutex
+	replies map[string]string
+	prompts map[string]string
+}
+
+func (r *recordingSender) SendScheduledPrompt(ctx context.Context, slug, prompt, threadID, workspaceID, provider string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prompts[slug] = prompt
+	return r.replies[slug], "", nil
+}
+
+func (r *recordingSender) AgentTimeout() time.Duration { return time.Minute }
+
+// A research → summarize flow with an ops step on failure and a conditional
+// post step: one chain run, every step recorded, only the matching path runs.
+func TestExecuteSchedule_RunsChain(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	s := New(db)
+	sender := &recordingSender{
+		replies: map[string]string{"researcher": "3 new papers", "writer": "all quiet today"},
+		prompts: map[string]string{},
+	}
+	s.SetPromptSender(sender)
+	insertSchedule(t, db, "s1", nil)
+
+	db.Exec("INSERT INTO schedule_chains (id, name, root_schedule_id) VALUES ('c1', 'Morning', 's1')")
+	for _, st := range [][]string{
+		{"summarize", "", "success", "", "", "writer", "Summarize: {{output}}"},
+		{"ops", "", "failure", "", "", "ops", "Investigate"},
+		{"post", "summarize", "success", ConditionContains, "urgent", "poster", "Post it"},
+	} {
+		insertChainStep(t, db, st[0], st[1], st[2], st[3], st[4], st[5], st[6])
+	}
+
+	s.executeSchedule(ScheduleConfig{ID: "s1", CronExpr: hourly, AgentRoleSlug: "researcher", PromptContent: "go"})
+
+	if got := sender.prompts["writer"]; got != "Summarize: 3 new papers" {
+		t.Errorf("summarize step prompt = %q", got)
+	}
+	if _, ran := sender.prompts["ops"]; ran {
+		t.Error("failure step ran after a successful root run")
+	}
+	if _, ran := sender.prompts["poster"]; ran {
+		t.Error("post step ran although its condition was not met")
+	}
+
+	var runID, status string
+	if err := db.QueryRow("SELECT id, status FROM schedule_chain_runs WHERE chain_id = 'c1'").Scan(&runID, &status); err != nil {
+		t.Fatalf("chain run not recorded: %v", err)
+	}
+	if status != "success" {
+		t.Errorf("chain run status = %q, want success", status)
+	}
+	want := map[string]string{"summarize": "success", "ops": "skipped", "post": "skipped"}
+	rows, err := db.Query("SELECT step_id, status FROM schedule_chain_run_steps WHERE run_id = ?", runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[string]string{}
+	for rows.Next() {
+		var stepID, stepStatus string
+		rows.Scan(&stepID, &stepStatus)
+		got[stepID] = stepStatus
+	}
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("step %s status = %q, want %q", id, got[id], w)
+		}
+	}
+}
+
+// insertChainStep adds a step to chain c1 that waits on the comma-separated
+// steps in dependsOn.
+func insertChainStep(t *testing.T, db *database.DB, id, dependsOn, runOn, condType, condValue, agent, prompt string) {
+	t.Helper()
+	if _, err := db.Exec(
+		`INSERT INTO schedule_chain_steps (id, chain_id, run_on, condition_type, condition_value, agent_role_slug, prompt_template)
+		 VALUES (?, 'c1', ?, ?, ?, ?, ?)`,
+		id, runOn, condType, condValue, agent, prompt,
+	); err != nil {
+		t.Fatalf("insert step: %v", err)
+	}
+	for _, dep := range strings.Split(dependsOn, ",") {
+		if dep == "" {
+			continue
+		}
+		if _, err := db.Exec("INSERT INTO schedule_chain_edges (step_id, depends_on_step_id) VALUES (?, ?)", id, dep); err != nil {
+			t.Fatalf("insert edge: %v", err)
+		}
+	}
+}
+
+// A step that depends on two others runs once, after both, with both outputs.
+func TestExecuteSchedule_JoinsChainSteps(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	s := New(db)
+	sender := &recordingSender{
+		replies: map[string]string{"researcher": "papers", "metrics": "traffic up", "news": "launch day", "ops": "paged"},
+		prompts: map[string]string{},
+	}
+	s.SetPromptSender(sender)
+	insertSchedule(t, db, "s1", nil)
+
+	db.Exec("INSERT INTO schedule_chains (id, name, root_schedule_id) VALUES ('c1', 'Morning', 's1')")
+	insertChainStep(t, db, "stats", "", "success", "", "", "metrics", "Stats")
+	insertChainStep(t, db, "headlines", "", "success", "", "", "news", "News")
+	// Sorts before the steps it waits on, so the edges have to set the order.
+	insertChainStep(t, db, "brief", "stats,headlines", "success", "", "", "writer", "Brief: {{output}}")
+	insertChainStep(t, db, "escalate", "stats,headlines", "failure", "", "", "ops", "Escalate")
+	db.Exec("UPDATE schedule_chain_steps SET name = 'Stats' WHERE id = 'stats'")
+
+	s.executeSchedule(ScheduleConfig{ID: "s1", CronExpr: hourly, AgentRoleSlug: "researcher", PromptContent: "go"})
+
+	got := sender.prompts["writer"]
+	if !strings.Contains(got, "## Stats\n\ntraffic up") || !strings.Contains(got, "## headlines\n\nlaunch day") {
+		t.Errorf("joined step prompt = %q, want both upstream outputs", got)
+	}
+	if _, ran := sender.prompts["ops"]; ran {
+		t.Error("failure step ran although every upstream succeeded")
+	}
+	var briefRuns int
+	db.QueryRow("SELECT COUNT(*) FROM schedule_chain_run_steps WHERE step_id = 'brief' AND status = 'success'").Scan(&briefRuns)
+	if briefRuns != 1 {
+		t.Errorf("joined step ran %d times, want once", briefRuns)
+	}
+}
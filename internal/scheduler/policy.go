@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/budget"
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+// Concurrency policies: what a fire does while the previous run is going.
+const (
+	ConcurrencySkip  = "skip"
+	ConcurrencyQueue = "queue"
+	ConcurrencyAllow = "allow"
+)
+
+// maxQueuedRuns bounds the fires a queue policy holds behind a running one;
+// past it a burst of webhook deliveries is recorded as skipped rather than
+// piling up without limit.
+const maxQueuedRuns = 20
+
+// Error classes a retry policy can match.
+const (
+	ErrorTimeout   = "timeout"
+	ErrorRateLimit = "rate_limit"
+	ErrorServer    = "server"
+	ErrorNetwork   = "network"
+	ErrorOther     = "other"
+	// ErrorAny matches every class, including ones that won't fix themselves.
+	ErrorAny = "any"
+)
+
+// DefaultRetryOn is used when a policy names no classes: the failures a retry
+// can plausibly fix.
+var DefaultRetryOn = []string{ErrorTimeout, ErrorRateLimit, ErrorServer, ErrorNetwork}
+
+const (
+	MaxAttempts = 10
+	maxBackoff  = time.Hour
+)
+
+// Policy controls retries, timeout and overlap for one schedule.
+type Policy struct {
+	MaxAttempts       int      `json:"retry_max_attempts"`
+	BackoffSeconds    int      `json:"retry_backoff_seconds"`
+	BackoffMultiplier float64  `json:"retry_backoff_multiplier"`
+	RetryOn           []string `json:"retry_on"`
+	// TimeoutSeconds overrides the agent timeout per attempt (0 = default).
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	Concurrency    string `json:"concurrency_policy"`
+}
+
+// DefaultPolicy is a single attempt that skips overlapping fires.
+func DefaultPolicy() Policy {
+	return Policy{MaxAttempts: 1, BackoffSeconds: 30, BackoffMultiplier: 2, RetryOn: []string{}, Concurrency: ConcurrencySkip}
+}
+
+// Validate reports the first problem with a policy, for the API to return.
+func (p Policy) Validate() error {
+	if p.MaxAttempts < 1 || p.MaxAttempts > MaxAttempts {
+		return fmt.Errorf("retry_max_attempts must be between 1 and %d", MaxAttempts)
+	}
+	if p.BackoffSeconds < 0 || p.BackoffSeconds > int(maxBackoff/time.Second) {
+		return errors.New("retry_backoff_seconds must be between 0 and 3600")
+	}
+	if p.BackoffMultiplier < 1 || p.BackoffMultiplier > 10 {
+		return errors.New("retry_backoff_multiplier must be between 1 and 10")
+	}
+	for _, class := range p.RetryOn {
+		switch class {
+		case ErrorTimeout, ErrorRateLimit, ErrorServer, ErrorNetwork, ErrorOther, ErrorAny:
+		default:
+			return fmt.Errorf("unknown retry_on class %q", class)
+		}
+	}
+	if p.TimeoutSeconds < 0 || p.TimeoutSeconds > 24*3600 {
+		return errors.New("timeout_seconds must be between 0 and 86400")
+	}
+	switch p.Concurrency {
+	case ConcurrencySkip, ConcurrencyQueue, ConcurrencyAllow:
+	default:
+		return errors.New("concurrency_policy must be skip, queue or allow")
+	}
+	return nil
+}
+
+// Backoff is the wait after a failed attempt (1-based) before the next one.
+func (p Policy) Backoff(attempt int) time.Duration {
+	mult := p.BackoffMultiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := time.Duration(float64(p.BackoffSeconds) * math.Pow(mult, float64(attempt-1)) * float64(time.Second))
+	if d > maxBackoff || d < 0 {
+		return maxBackoff
+	}
+	return d
+}
+
+// Retryable reports whether a failed attempt should be tried again under this
+// policy (ignoring the attempt count).
+func (p Policy) Retryable(err error) bool {
+	// A refusal over budget stays refused until the period rolls over, so
+	// even "any" must not spend the retries on it.
+	if err == nil || budget.IsExceeded(err) {
+		return false
+	}
+	classes := p.RetryOn
+	if len(classes) == 0 {
+		classes = DefaultRetryOn
+	}
+	class := ClassifyError(err)
+	for _, c := range classes {
+		if c == ErrorAny || c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyError sorts a run failure into a retry class. Provider errors reach
+// the scheduler as wrapped strings from several engines, so this goes by the
+// message rather than by type.
+func ClassifyError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "deadline exceeded"), strings.Contains(msg, "timed out"), strings.Contains(msg, "timeout"):
+		return ErrorTimeout
+	case strings.Contains(msg, "status 429"), strings.Contains(msg, "rate limit"), strings.Contains(msg, "too many requests"):
+		return ErrorRateLimit
+	case strings.Contains(msg, "status 500"), strings.Contains(msg, "status 502"), strings.Contains(msg, "status 503"),
+		strings.Contains(msg, "status 504"), strings.Contains(msg, "status 529"), strings.Contains(msg, "overloaded"):
+		return ErrorServer
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "connection reset"),
+		strings.Contains(msg, "no such host"), strings.Contains(msg, "unexpected eof"), strings.Contains(msg, "broken pipe"):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// LoadPolicy reads a schedule's policy at fire time, so edits apply to the
+// next run without re-registering the cron entry. A missing row gets the
+// defaults.
+func LoadPolicy(db *database.DB, id string) Policy {
+	p := DefaultPolicy()
+	var retryOn string
+	if err := db.QueryRow(
+		`SELECT retry_max_attempts, retry_backoff_seconds, retry_backoff_multiplier, retry_on, timeout_seconds, concurrency_policy
+		 FROM schedules WHERE id = ?`, id,
+	).Scan(&p.MaxAttempts, &p.BackoffSeconds, &p.BackoffMultiplier, &retryOn, &p.TimeoutSeconds, &p.Concurrency); err != nil {
+		return DefaultPolicy()
+	}
+	json.Unmarshal([]byte(retryOn), &p.RetryOn)
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	return p
+}
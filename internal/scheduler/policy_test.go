@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/budget"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, ErrorTimeout},
+		{fmt.Errorf("run: %w", context.DeadlineExceeded), ErrorTimeout},
+		{errors.New("API error (status 429): slow down"), ErrorRateLimit},
+		{errors.New("API error (status 503): overloaded"), ErrorServer},
+		{errors.New("dial tcp: connection refused"), ErrorNetwork},
+		{errors.New("agent not found"), ErrorOther},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("ClassifyError(%q) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestPolicyRetryableAndBackoff(t *testing.T) {
+	p := DefaultPolicy()
+	if !p.Retryable(errors.New("status 502")) {
+		t.Error("server errors should be retryable by default")
+	}
+	if p.Retryable(errors.New("agent not found")) {
+		t.Error("other errors should not be retryable by default")
+	}
+	p.RetryOn = []string{ErrorAny}
+	if !p.Retryable(errors.New("agent not found")) {
+		t.Error("retry_on any should match every error")
+	}
+	if p.Retryable(fmt.Errorf("scheduled prompt failed: %w", &budget.ExceededError{})) {
+		t.Error("a budget refusal should never be retried")
+	}
+
+	p.BackoffSeconds, p.BackoffMultiplier = 10, 3
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 30 * time.Second, 3: 90 * time.Second} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := p.Backoff(50); got != time.Hour {
+		t.Errorf("Backoff should cap at an hour, got %v", got)
+	}
+
+	p.Concurrency = "sometimes"
+	if p.Validate() == nil {
+		t.Error("Validate accepted an unknown concurrency policy")
+	}
+}
+
+// flakySender fails with a provider error a set number of times, then succeeds.
+type flakySender struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (f *flakySender) SendScheduledPrompt(ctx context.Context, slug, prompt, threadID, workspaceID, provider string) (string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return "", "", errors.New("API error (status 503): overloaded")
+	}
+	return "done", "", nil
+}
+
+func (f *flakySender) AgentTimeout() time.Duration { return time.Minute }
+
+// A transient 503 used to end the run as an error; with retries configured
+// each attempt is its own execution row and the second one succeeds.
+func TestExecuteSchedule_RetriesTransientErrors(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	s := New(db)
+	s.SetPromptSender(&flakySender{failures: 1})
+	insertSchedule(t, db, "s1", nil)
+	db.Exec("UPDATE schedules SET retry_max_attempts = 3, retry_backoff_seconds = 0 WHERE id = 's1'")
+
+	s.executeSchedule(ScheduleConfig{ID: "s1", CronExpr: hourly, AgentRoleSlug: "researcher", PromptContent: "go"})
+
+	rows, err := db.Query("SELECT attempt, status FROM schedule_executions WHERE schedule_id = 's1' ORDER BY attempt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var attempt int
+		var status string
+		rows.Scan(&attempt, &status)
+		got = append(got, fmt.Sprintf("%d:%s", attempt, status))
+	}
+	if len(got) != 2 || got[0] != "1:error" || got[1] != "2:success" {
+		t.Errorf("executions = %v, want [1:error 2:success]", got)
+	}
+}
+
+func TestExecuteSchedule_SkipsOverlappingRun(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	s := New(db)
+	sender := &flakySender{}
+	s.SetPromptSender(sender)
+	insertSchedule(t, db, "s1", nil)
+
+	// Pretend the previous fire is still going.
+	s.running["s1"] = 1
+	s.executeSchedule(ScheduleConfig{ID: "s1", CronExpr: hourly, AgentRoleSlug: "researcher", PromptContent: "go"})
+
+	if sender.calls != 0 {
+		t.Errorf("overlapping fire sent the prompt %d times under the skip policy", sender.calls)
+	}
+	var skipped int
+	db.QueryRow("SELECT COUNT(*) FROM schedule_executions WHERE schedule_id = 's1' AND status = 'skipped'").Scan(&skipped)
+	if skipped != 1 {
+		t.Errorf("recorded %d skipped runs, want 1", skipped)
+	}
+}
+
+func TestBeginRun_QueuesInOrder(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	s := New(db)
+	insertSchedule(t, db, "s1", nil)
+	queue := Policy{Concurrency: ConcurrencyQueue}
+
+	if !s.beginRun(ScheduleConfig{ID: "s1", PromptContent: "first"}, queue) {
+		t.Fatal("the first fire did not start")
+	}
+	for i := 0; i < maxQueuedRuns+1; i++ {
+		if s.beginRun(ScheduleConfig{ID: "s1", PromptContent: fmt.Sprint(i)}, queue) {
+			t.Fatalf("fire %d started while the first was running", i)
+		}
+	}
+
+	// Every delivery is kept, oldest first, not just the latest.
+	q := s.queued["s1"]
+	if len(q) != maxQueuedRuns || q[0].PromptContent != "0" || q[len(q)-1].PromptContent != fmt.Sprint(maxQueuedRuns-1) {
+		t.Errorf("queue holds %d runs, %q first", len(q), q[0].PromptContent)
+	}
+	var skipped int
+	db.QueryRow("SELECT COUNT(*) FROM schedule_executions WHERE schedule_id = 's1' AND status = 'skipped'").Scan(&skipped)
+	if skipped != 1 {
+		t.Errorf("recorded %d skipped runs past the queue limit, want 1", skipped)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/budget"
 	"github.com/openpaw/openpaw/internal/database"
 	"github.com/openpaw/openpaw/internal/logger"
 	"github.com/openpaw/openpaw/internal/models"
@@ -37,6 +38,8 @@ type ScheduleConfig struct {
 	WorkspaceID string
 	// Provider pins the engine this routine runs on ("" = whatever is active).
 	Provider string
+	// Trigger records what started the run in schedule_executions ("" = cron).
+	Trigger string
 }
 
 type Scheduler struct {
@@ -47,6 +50,16 @@ type Scheduler struct {
 	promptSender  PromptSender
 	notifyFn      NotifyFunc
 	retentionStop chan struct{}
+
+	// runMu guards the per-schedule run bookkeeping the concurrency policy
+	// needs: how many runs are in flight, and those queued behind them, oldest
+	// first.
+	runMu   sync.Mutex
+	running map[string]int
+	queued  map[string][]ScheduleConfig
+	// stop ends retry backoff waits when the scheduler shuts down.
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func New(db *database.DB) *Scheduler {
@@ -54,6 +67,9 @@ func New(db *database.DB) *Scheduler {
 		cron:    cron.New(cron.WithSeconds()),
 		entries: make(map[string]cron.EntryID),
 		db:      db,
+		running: make(map[string]int),
+		queued:  make(map[string][]ScheduleConfig),
+		stop:    make(chan struct{}),
 	}
 }
 
@@ -187,6 +203,7 @@ func (s *Scheduler) Stop() {
 	if s.retentionStop != nil {
 		close(s.retentionStop)
 	}
+	s.stopOnce.Do(func() { close(s.stop) })
 	s.cron.Stop()
 	logger.Success("Scheduler stopped")
 }
@@ -226,40 +243,145 @@ func (s *Scheduler) RemoveSchedule(id string) {
 }
 
 func (s *Scheduler) executeSchedule(cfg ScheduleConfig) {
-	execID := uuid.New().String()
+	policy := LoadPolicy(s.db, cfg.ID)
 	now := time.Now().UTC()
 
-	s.db.Exec(
-		"INSERT INTO schedule_executions (id, schedule_id, status, started_at) VALUES (?, ?, 'running', ?)",
-		execID, cfg.ID, now,
-	)
-
-	s.db.Exec("UPDATE schedules SET last_run_at = ?, updated_at = ? WHERE id = ?", now, now, cfg.ID)
-
 	// Advance the next-run time up front rather than after the run: a long run
 	// would otherwise leave "Next run" showing a time in the past for its whole
 	// duration, and a crash mid-run would look like a missed fire at next boot.
-	s.setNextRun(cfg.ID, cfg.CronExpr)
+	// A skipped or queued fire still moves it on. Manual and webhook runs
+	// aren't cron fires and leave it alone.
+	if cfg.Trigger == "" {
+		cfg.Trigger = TriggerCron
+	}
+	if cfg.Trigger == TriggerCron {
+		s.setNextRun(cfg.ID, cfg.CronExpr)
+	}
+	if !s.beginRun(cfg, policy) {
+		return
+	}
+	defer s.endRun(cfg.ID)
 
-	output, threadID, execErr := s.executePrompt(cfg)
+	s.db.Exec("UPDATE schedules SET last_run_at = ?, updated_at = ? WHERE id = ?", now, now, cfg.ID)
 
-	finishedAt := time.Now().UTC()
-	status := "success"
-	errStr := ""
-	if execErr != nil {
-		status = "error"
-		errStr = execErr.Error()
-		logger.Error("Schedule %s execution failed: %v", cfg.ID, execErr)
-	} else {
-		logger.Info("Schedule %s executed successfully", cfg.ID)
+	for attempt := 1; ; attempt++ {
+		execID := uuid.New().String()
+		s.db.Exec(
+			"INSERT INTO schedule_executions (id, schedule_id, status, attempt, triggered_by, started_at) VALUES (?, ?, 'running', ?, ?, ?)",
+			execID, cfg.ID, attempt, cfg.Trigger, time.Now().UTC(),
+		)
+
+		output, threadID, execErr := s.executePrompt(cfg, policy, execID)
+		finishedAt := time.Now().UTC()
+
+		if execErr == nil {
+			logger.Info("Schedule %s executed successfully (attempt %d)", cfg.ID, attempt)
+			s.db.Exec(
+				"UPDATE schedule_executions SET status = 'success', output = ?, error = '', finished_at = ? WHERE id = ?",
+				output, finishedAt, execID,
+			)
+			s.fileReport(cfg, execID, "success", output, "", threadID)
+			s.runChains(cfg, execID, outcome{status: "success", output: output})
+			return
+		}
+
+		retry := attempt < policy.MaxAttempts && policy.Retryable(execErr)
+		wait := policy.Backoff(attempt)
+		errStr := execErr.Error()
+		switch {
+		case retry:
+			errStr += fmt.Sprintf(" (attempt %d of %d, retrying in %s)", attempt, policy.MaxAttempts, wait)
+		case attempt > 1:
+			errStr += fmt.Sprintf(" (gave up after %d attempts)", attempt)
+		}
+		logger.Error("Schedule %s attempt %d failed: %v", cfg.ID, attempt, execErr)
+		s.db.Exec(
+			"UPDATE schedule_executions SET status = 'error', output = ?, error = ?, finished_at = ? WHERE id = ?",
+			output, errStr, finishedAt, execID,
+		)
+
+		if !retry {
+			s.fileReport(cfg, execID, "error", output, errStr, threadID)
+			s.runChains(cfg, execID, outcome{status: "error", output: output, errStr: execErr.Error()})
+			return
+		}
+		// Keep the conversation in one place: a retry continues in the thread
+		// the failed attempt opened rather than starting another.
+		if cfg.ThreadID == "" && threadID != "" {
+			cfg.ThreadID = threadID
+		}
+		select {
+		case <-time.After(wait):
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// beginRun applies the schedule's concurrency policy and reports whether this
+// fire may start now. Under skip an overlapping fire is recorded and dropped;
+// under queue it waits its turn behind the run in progress and any fires
+// queued before it, up to maxQueuedRuns; past that it is recorded and dropped.
+func (s *Scheduler) beginRun(cfg ScheduleConfig, policy Policy) bool {
+	s.runMu.Lock()
+	if s.running[cfg.ID] > 0 && policy.Concurrency != ConcurrencyAllow {
+		if policy.Concurrency == ConcurrencyQueue {
+			if len(s.queued[cfg.ID]) < maxQueuedRuns {
+				s.queued[cfg.ID] = append(s.queued[cfg.ID], cfg)
+				s.runMu.Unlock()
+				logger.Info("Schedule %s is still running; queued the next run", cfg.ID)
+				return false
+			}
+			s.runMu.Unlock()
+			s.recordSkipped(cfg, "Skipped — too many runs were already queued.")
+			return false
+		}
+		s.runMu.Unlock()
+		s.recordSkipped(cfg, "Skipped — the previous run was still in progress.")
+		return false
+	}
+	s.running[cfg.ID]++
+	s.runMu.Unlock()
+	return true
+}
+
+// endRun releases a run and starts the oldest queued one, if any.
+func (s *Scheduler) endRun(id string) {
+	s.runMu.Lock()
+	s.running[id]--
+	if s.running[id] > 0 {
+		s.runMu.Unlock()
+		return
+	}
+	delete(s.running, id)
+	var next ScheduleConfig
+	q := s.queued[id]
+	ok := len(q) > 0
+	if ok {
+		next = q[0]
+		if len(q) == 1 {
+			delete(s.queued, id)
+		} else {
+			s.queued[id] = q[1:]
+		}
+	}
+	s.runMu.Unlock()
+
+	if ok {
+		go s.executeSchedule(next)
 	}
+}
 
+// recordSkipped files an execution row for a fire dropped by the concurrency
+// policy, so the gap in the history has an explanation.
+func (s *Scheduler) recordSkipped(cfg ScheduleConfig, reason string) {
+	now := time.Now().UTC()
 	s.db.Exec(
-		"UPDATE schedule_executions SET status = ?, output = ?, error = ?, finished_at = ? WHERE id = ?",
-		status, output, errStr, finishedAt, execID,
+		`INSERT INTO schedule_executions (id, schedule_id, status, error, triggered_by, started_at, finished_at)
+		 VALUES (?, ?, 'skipped', ?, ?, ?, ?)`,
+		uuid.New().String(), cfg.ID, reason, cfg.Trigger, now, now,
 	)
-
-	s.fileReport(cfg, execID, status, output, errStr, threadID)
+	logger.Warn("Schedule %s fired while its previous run was still in progress; skipped", cfg.ID)
 }
 
 // fileReport files the run's outcome into the Inbox.
@@ -343,7 +465,7 @@ func truncate(s string, n int) string {
 	return strings.TrimSpace(s[:n]) + "…"
 }
 
-func (s *Scheduler) executePrompt(cfg ScheduleConfig) (output, threadID string, err error) {
+func (s *Scheduler) executePrompt(cfg ScheduleConfig, policy Policy, execID string) (output, threadID string, err error) {
 	if s.promptSender == nil {
 		logger.Warn("Schedule %s: prompt sender not configured, skipping", cfg.ID)
 		return "", "", nil
@@ -353,14 +475,25 @@ func (s *Scheduler) executePrompt(cfg ScheduleConfig) (output, threadID string,
 	// scheduled prompt asks an agent to actually do work — with a CLI engine
 	// like Claude Code that routinely runs longer, and the old cap killed the
 	// subprocess mid-task, surfacing as "signal: killed" with nothing done.
-	ctx, cancel := context.WithTimeout(context.Background(), s.promptSender.AgentTimeout())
+	// A schedule can override it per attempt.
+	timeout := s.promptSender.AgentTimeout()
+	if policy.TimeoutSeconds > 0 {
+		timeout = time.Duration(policy.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	// Charge the run — and anything it delegates — to this schedule's budget.
+	ctx = budget.WithScope(ctx, budget.Scope{ScheduleID: cfg.ID, Source: budget.SourceSchedule, ExecutionID: execID})
 
 	return s.promptSender.SendScheduledPrompt(ctx, cfg.AgentRoleSlug, cfg.PromptContent, cfg.ThreadID, cfg.WorkspaceID, cfg.Provider)
 }
 
-// RunNow executes a schedule immediately (called from API).
+// RunNow executes a schedule immediately (called from API). A config with no
+// Trigger set is recorded as a manual run.
 func (s *Scheduler) RunNow(cfg ScheduleConfig) {
+	if cfg.Trigger == "" {
+		cfg.Trigger = TriggerManual
+	}
 	go s.executeSchedule(cfg)
 }
 
@@ -399,7 +532,7 @@ func (s *Scheduler) cleanupOldDataPoints() {
 func (s *Scheduler) LoadSchedules() {
 	rows, err := s.db.Query(
 		`SELECT id, cron_expr, agent_role_slug, prompt_content, thread_id, workspace_id, provider
-		 FROM schedules WHERE enabled = 1 AND type = 'prompt'`,
+		 FROM schedules WHERE enabled = 1 AND type = 'prompt' AND trigger_type = 'cron'`,
 	)
 	if err != nil {
 		logger.Error("Failed to load schedules: %v", err)
@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+// Trigger types and what started an execution.
+const (
+	TriggerCron    = "cron"
+	TriggerWebhook = "webhook"
+	TriggerManual  = "manual"
+)
+
+// stripeTolerance is how old a Stripe-style signed timestamp may be before the
+// delivery is treated as a replay.
+const stripeTolerance = 5 * time.Minute
+
+// deliveryRetention is how long a delivery's replay key is remembered. Body-
+// only signatures carry no time, so this is how long a captured GitHub-style
+// delivery stays useless to whoever captured it.
+const deliveryRetention = 30 * 24 * time.Hour
+
+// GenerateWebhookToken returns a random URL token and signing secret for a new
+// webhook automation.
+func GenerateWebhookToken() (token, secret string, err error) {
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(b[:16]), "whsec_" + hex.EncodeToString(b[16:]), nil
+}
+
+// VerifySignature checks an inbound delivery's HMAC-SHA256 signature against
+// the automation's secret. It accepts the common sender formats:
+//
+//   - X-Hub-Signature-256: sha256=<hex>           (GitHub and most others)
+//   - X-OpenPaw-Signature: sha256=<hex> or <hex>  (curl, scripts, sensors;
+//     signs "<X-OpenPaw-Delivery>.<body>")
+//   - Stripe-Signature: t=<unix>,v1=<hex>          (signs "<t>.<body>")
+//
+// It returns the delivery's replay key for ClaimDelivery, or "" when the
+// signature doesn't verify. GitHub's X-GitHub-Delivery id isn't signed, so a
+// body-only signature is its own key.
+func VerifySignature(secret string, header http.Header, body []byte, now time.Time) string {
+	if secret == "" {
+		return ""
+	}
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		if sig = strings.TrimPrefix(sig, "sha256="); hmacEqual(secret, body, sig) {
+			return "sig:" + strings.ToLower(strings.TrimSpace(sig))
+		}
+		return ""
+	}
+	if sig := header.Get("X-OpenPaw-Signature"); sig != "" {
+		delivery := strings.TrimSpace(header.Get("X-OpenPaw-Delivery"))
+		if delivery == "" {
+			return ""
+		}
+		if hmacEqual(secret, append([]byte(delivery+"."), body...), strings.TrimPrefix(sig, "sha256=")) {
+			return "delivery:" + delivery
+		}
+		return ""
+	}
+	if sig := header.Get("Stripe-Signature"); sig != "" {
+		var ts string
+		var candidates []string
+		for _, part := range strings.Split(sig, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				ts = v
+			case "v1":
+				candidates = append(candidates, v)
+			}
+		}
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil || now.Sub(time.Unix(unix, 0)).Abs() > stripeTolerance {
+			return ""
+		}
+		signed := append([]byte(ts+"."), body...)
+		for _, c := range candidates {
+			if hmacEqual(secret, signed, c) {
+				return "sig:" + strings.ToLower(strings.TrimSpace(c))
+			}
+		}
+	}
+	return ""
+}
+
+// ClaimDelivery records a verified delivery and reports whether it is new. A
+// false means the same delivery was already accepted: a replay, or a sender
+// retrying one that did arrive.
+func ClaimDelivery(db *database.DB, scheduleID, replayKey string, now time.Time) (bool, error) {
+	if _, err := db.Exec("DELETE FROM webhook_deliveries WHERE received_at < ?", now.Add(-deliveryRetention)); err != nil {
+		return false, err
+	}
+	res, err := db.Exec(
+		"INSERT OR IGNORE INTO webhook_deliveries (schedule_id, replay_key, received_at) VALUES (?, ?, ?)",
+		scheduleID, replayKey, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func hmacEqual(secret string, msg []byte, sigHex string) bool {
+	got, err := hex.DecodeString(strings.TrimSpace(sigHex))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(msg)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// RenderPrompt fills a webhook automation's prompt template from the delivery.
+// {{payload}} is the whole JSON body, {{payload.a.b}} a field inside it (with
+// numeric segments indexing arrays) and {{headers.Name}} a request header.
+// Placeholders that don't resolve render empty rather than failing the run —
+// senders routinely omit optional fields. Other {{...}} text is left as is.
+func RenderPrompt(tmpl string, body []byte, header http.Header) string {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		// Not JSON (form posts, plain text): the raw body is still usable.
+		payload = string(body)
+	}
+	return placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		path := strings.Split(placeholderRe.FindStringSubmatch(m)[1], ".")
+		switch path[0] {
+		case "payload":
+			return formatValue(lookup(payload, path[1:]))
+		case "headers":
+			if len(path) < 2 {
+				return ""
+			}
+			return header.Get(strings.Join(path[1:], "."))
+		}
+		return m
+	})
+}
+
+func lookup(v interface{}, path []string) interface{} {
+	for _, seg := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		b, _ := json.MarshalIndent(val, "", "  ")
+		return string(b)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
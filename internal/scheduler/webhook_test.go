@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func sign(secret, msg string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"action":"opened"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	github := http.Header{}
+	bodySig := sign(secret, string(body))
+	github.Set("X-Hub-Signature-256", "sha256="+bodySig)
+	if key := VerifySignature(secret, github, body, now); key != "sig:"+bodySig {
+		t.Errorf("GitHub-style signature: key %q", key)
+	}
+	if VerifySignature("other", github, body, now) != "" {
+		t.Error("signature accepted with the wrong secret")
+	}
+	if VerifySignature(secret, github, []byte(`{"action":"closed"}`), now) != "" {
+		t.Error("signature accepted for a tampered body")
+	}
+	if VerifySignature(secret, http.Header{}, body, now) != "" {
+		t.Error("unsigned delivery accepted")
+	}
+
+	// Our own format signs the delivery id, so it can't be swapped for a
+	// fresh one to get past the replay check.
+	openpaw := http.Header{}
+	openpaw.Set("X-OpenPaw-Delivery", "d-1")
+	openpaw.Set("X-OpenPaw-Signature", "sha256="+sign(secret, "d-1."+string(body)))
+	if key := VerifySignature(secret, openpaw, body, now); key != "delivery:d-1" {
+		t.Errorf("OpenPaw signature: key %q", key)
+	}
+	openpaw.Set("X-OpenPaw-Delivery", "d-2")
+	if VerifySignature(secret, openpaw, body, now) != "" {
+		t.Error("signature accepted for another delivery id")
+	}
+	openpaw.Del("X-OpenPaw-Delivery")
+	openpaw.Set("X-OpenPaw-Signature", bodySig)
+	if VerifySignature(secret, openpaw, body, now) != "" {
+		t.Error("OpenPaw signature accepted without a delivery id")
+	}
+
+	ts := fmt.Sprint(now.Unix())
+	stripe := http.Header{}
+	stripe.Set("Stripe-Signature", "t="+ts+",v1="+sign(secret, ts+"."+string(body)))
+	if VerifySignature(secret, stripe, body, now) == "" {
+		t.Error("Stripe-style signature rejected")
+	}
+	if VerifySignature(secret, stripe, body, now.Add(time.Hour)) != "" {
+		t.Error("stale Stripe timestamp accepted")
+	}
+}
+
+func TestClaimDelivery(t *testing.T) {
+	db := newSchedulerTestDB(t)
+	db.Exec("INSERT INTO schedules (id, name, cron_expr) VALUES ('s1', 'Hook', '')")
+
+	now := time.Now().UTC()
+	for i, want := range []bool{true, false} {
+		if fresh, err := ClaimDelivery(db, "s1", "delivery:d-1", now); err != nil || fresh != want {
+			t.Errorf("claim %d = %v, %v; want %v", i+1, fresh, err, want)
+		}
+	}
+	// Once the key has aged out it is forgotten.
+	if fresh, _ := ClaimDelivery(db, "s1", "delivery:d-1", now.Add(deliveryRetention+time.Hour)); !fresh {
+		t.Error("an expired key still blocked the delivery")
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	body := []byte(`{"repository":{"full_name":"acme/api"},"commits":[{"message":"fix login"}],"count":3}`)
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+
+	got := RenderPrompt("{{headers.X-GitHub-Event}} to {{ payload.repository.full_name }}: {{payload.commits.0.message}} ({{payload.count}}){{payload.missing}}", body, header)
+	want := "push to acme/api: fix login (3)"
+	if got != want {
+		t.Errorf("RenderPrompt = %q, want %q", got, want)
+	}
+
+	if got := RenderPrompt("Reading: {{payload}}", []byte("21.5C"), header); got != "Reading: 21.5C" {
+		t.Errorf("non-JSON body = %q", got)
+	}
+}
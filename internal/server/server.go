@@ -23,8 +23,10 @@ import (
 	"github.com/openpaw/openpaw/internal/media"
 	"github.com/openpaw/openpaw/internal/memory"
 	mw "github.com/openpaw/openpaw/internal/middleware"
+	"github.com/openpaw/openpaw/internal/notify"
 	"github.com/openpaw/openpaw/internal/scheduler"
 	"github.com/openpaw/openpaw/internal/secrets"
+	"github.com/openpaw/openpaw/internal/sharedmemory"
 	"github.com/openpaw/openpaw/internal/terminal"
 	"github.com/openpaw/openpaw/internal/toolmgr"
 	ws "github.com/openpaw/openpaw/internal/websocket"
@@ -43,7 +45,10 @@ type Server struct {
 	BackupMgr    *backup.Manager
 	MemoryMgr    *memory.Manager
 	DreamingMgr  *dreaming.Manager
+	SharedMemory *sharedmemory.Store
 	TerminalMgr  *terminal.Manager
+	MCPClients   *mcp.Clients
+	Notifier     *notify.Dispatcher
 	FrontendFS   fs.FS
 }
 
@@ -58,10 +63,13 @@ type Config struct {
 	BackupMgr     *backup.Manager
 	MemoryMgr     *memory.Manager
 	DreamingMgr   *dreaming.Manager
+	SharedMemory  *sharedmemory.Store
 	TerminalMgr   *terminal.Manager
 	LLMClient     *llm.Client
 	Providers     *llm.ProviderRouter
 	MCPRegistry   *mcp.Registry
+	MCPClients    *mcp.Clients
+	Notifier      *notify.Dispatcher
 	MediaRegistry *media.Registry
 	FrontendFS    fs.FS
 	ToolsDir      string
@@ -83,7 +91,10 @@ func New(cfg Config) *Server {
 		BackupMgr:    cfg.BackupMgr,
 		MemoryMgr:    cfg.MemoryMgr,
 		DreamingMgr:  cfg.DreamingMgr,
+		SharedMemory: cfg.SharedMemory,
 		TerminalMgr:  cfg.TerminalMgr,
+		MCPClients:   cfg.MCPClients,
+		Notifier:     cfg.Notifier,
 		FrontendFS:   cfg.FrontendFS,
 	}
 
@@ -105,11 +116,17 @@ func (s *Server) setupMiddleware() {
 
 func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir string, secretsMgr *secrets.Manager, llmClient *llm.Client, providers *llm.ProviderRouter, mcpRegistry *mcp.Registry, mediaRegistry *media.Registry, port int) {
 	authHandler := handlers.NewAuthHandler(s.DB, s.Auth, dataDir)
+	apiTokensHandler := handlers.NewAPITokensHandler(s.DB)
+	usersHandler := handlers.NewUsersHandler(s.DB, s.Auth)
 	setupHandler := handlers.NewSetupHandler(s.DB, s.Auth, secretsMgr, llmClient, providers, dataDir)
 	toolsHandler := handlers.NewToolsHandler(s.DB, s.AgentManager, toolMgr, toolsDir)
 	secretsHandler := handlers.NewSecretsHandler(s.DB, s.Secrets, toolMgr)
-	schedulesHandler := handlers.NewSchedulesHandler(s.DB, s.Scheduler)
+	schedulesHandler := handlers.NewSchedulesHandler(s.DB, s.Scheduler, secretsMgr)
 	automationHandler := handlers.NewAutomationHandler(s.DB)
+	budgetsHandler := handlers.NewBudgetsHandler(s.DB, s.AgentManager.Budget)
+	mcpServersHandler := handlers.NewMCPServersHandler(s.DB, s.MCPClients)
+	notificationChannelsHandler := handlers.NewNotificationChannelsHandler(s.DB, s.Notifier)
+	mcpEndpointHandler := handlers.NewMCPEndpointHandler(s.DB, s.AgentManager, dataDir)
 	dashboardsDir := filepath.Join(dataDir, "..", "dashboards")
 	dashboardsHandler := handlers.NewDashboardsHandler(s.DB, toolMgr, dashboardsDir)
 	agentRolesHandler := handlers.NewAgentRolesHandler(s.DB, dataDir, llmClient, s.FrontendFS, s.AgentManager)
@@ -123,6 +140,8 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 		chatHandler.Reflector = s.DreamingMgr
 	}
 	contextHandler := handlers.NewContextHandler(s.DB, dataDir)
+	contextHandler.Index = s.AgentManager.ContextIndex
+	contextHandler.Describe = handlers.VisionDescriber(llmClient)
 	canvasHandler := handlers.NewCanvasHandler()
 	skillsHandler := handlers.NewSkillsHandler(dataDir, s.DB)
 	logsHandler := handlers.NewLogsHandler(s.DB)
@@ -133,8 +152,9 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 	backupHandler := handlers.NewBackupHandler(s.DB, s.BackupMgr)
 	memoryHandler := handlers.NewMemoryHandler(s.MemoryMgr)
 	dreamingHandler := handlers.NewDreamingHandler(s.DB, s.DreamingMgr)
+	sharedMemoryHandler := handlers.NewSharedMemoryHandler(s.DB, s.SharedMemory, s.MemoryMgr)
 	toolLibraryHandler := handlers.NewToolLibraryHandler(s.DB, toolMgr, toolsDir, secretsMgr)
-	agentLibraryHandler := handlers.NewAgentLibraryHandler(s.DB, dataDir)
+	agentLibraryHandler := handlers.NewAgentLibraryHandler(s.DB, dataDir, s.MemoryMgr, s.AgentManager)
 	skillLibraryHandler := handlers.NewSkillLibraryHandler(s.DB, dataDir)
 	skillsShHandler := handlers.NewSkillsShHandler(s.DB, dataDir)
 	projectsHandler := handlers.NewProjectsHandler(s.DB)
@@ -178,6 +198,18 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 			r.With(mw.RateLimit(10, time.Minute)).Post("/login", authHandler.Login)
 		})
 
+		// Public invitation accept (the invitee has no account yet)
+		r.Route("/invitations", func(r chi.Router) {
+			r.With(mw.RateLimit(10, time.Minute)).Get("/{token}", usersHandler.GetInvitation)
+			r.With(mw.RateLimit(5, time.Minute)).Post("/accept", usersHandler.AcceptInvitation)
+		})
+
+		// Public webhook triggers (auth = unguessable token + HMAC signature)
+		r.With(mw.RateLimit(60, time.Minute)).Post("/hooks/{token}", schedulesHandler.ReceiveWebhook)
+
+		// Public read-only chat share links (auth = unguessable, expiring token)
+		r.With(mw.RateLimit(30, time.Minute)).Get("/share/{token}", chatHandler.ViewShare)
+
 		r.Route("/setup", func(r chi.Router) {
 			r.With(mw.RateLimit(5, time.Minute)).Get("/status", setupHandler.Status)
 			r.With(mw.RateLimit(5, time.Minute)).Post("/init", setupHandler.Init)
@@ -239,6 +271,7 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 		r.Group(func(r chi.Router) {
 			r.Use(mw.Auth(s.Auth))
 			r.Use(mw.CSRFProtection)
+			r.Use(handlers.WorkspaceScope(s.DB))
 
 			// Auth
 			r.Post("/auth/logout", authHandler.Logout)
@@ -247,6 +280,20 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 			r.Get("/auth/me", authHandler.Me)
 			r.Put("/auth/profile", authHandler.UpdateProfile)
 			r.Post("/auth/avatar", authHandler.UploadAvatar)
+			r.Get("/auth/tokens", apiTokensHandler.List)
+			r.Post("/auth/tokens", apiTokensHandler.Create)
+			r.Get("/auth/tokens/scopes", apiTokensHandler.Scopes)
+			r.Delete("/auth/tokens/{id}", apiTokensHandler.Revoke)
+
+			// Users, invitations and workspace membership (admin only)
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", usersHandler.List)
+				r.Put("/{id}/role", usersHandler.UpdateRole)
+				r.Delete("/{id}", usersHandler.Delete)
+				r.Get("/invitations", usersHandler.ListInvitations)
+				r.Post("/invitations", usersHandler.CreateInvitation)
+				r.Delete("/invitations/{id}", usersHandler.RevokeInvitation)
+			})
 
 			// Tools
 			r.Route("/tools", func(r chi.Router) {
@@ -321,7 +368,40 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Post("/{id}/run-now", schedulesHandler.RunNow)
 				r.Post("/{id}/toggle", schedulesHandler.Toggle)
 				r.Get("/{id}/executions", schedulesHandler.Executions)
+				r.Post("/{id}/webhook/rotate", schedulesHandler.RotateWebhookSecret)
+			})
+
+			// Schedule chains (follow-up steps after a schedule finishes)
+			r.Route("/schedule-chains", func(r chi.Router) {
+				r.Get("/", schedulesHandler.ListChains)
+				r.Post("/", schedulesHandler.CreateChain)
+				r.Put("/{id}", schedulesHandler.UpdateChain)
+				r.Delete("/{id}", schedulesHandler.DeleteChain)
+				r.Get("/{id}/runs", schedulesHandler.ChainRuns)
+			})
+
+			// OpenPaw as an MCP server for outside clients (token scope "mcp")
+			r.HandleFunc("/mcp", mcpEndpointHandler.Serve)
+
+			// External MCP servers whose tools agents can use
+			r.Route("/mcp-servers", func(r chi.Router) {
+				r.Get("/", mcpServersHandler.List)
+				r.Post("/", mcpServersHandler.Create)
+				r.Put("/{id}", mcpServersHandler.Update)
+				r.Delete("/{id}", mcpServersHandler.Delete)
+				r.Post("/{id}/refresh", mcpServersHandler.Refresh)
+				r.Get("/{id}/grants", mcpServersHandler.GetGrants)
+				r.Put("/{id}/grants", mcpServersHandler.SetGrants)
+			})
+
+			// Spend budgets and the usage report
+			r.Route("/budgets", func(r chi.Router) {
+				r.Get("/", budgetsHandler.List)
+				r.Post("/", budgetsHandler.Create)
+				r.Put("/{id}", budgetsHandler.Update)
+				r.Delete("/{id}", budgetsHandler.Delete)
 			})
+			r.Get("/usage/report", budgetsHandler.Report)
 
 			// Background automation status (schedules + heartbeats in flight)
 			r.Get("/automation/active", automationHandler.Active)
@@ -350,6 +430,7 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Post("/", agentRolesHandler.Create)
 				r.Post("/upload-avatar", agentRolesHandler.UploadAvatar)
 				r.Post("/describe-avatar", agentRolesHandler.DescribeAvatar)
+				r.Post("/import", agentLibraryHandler.ImportAgent)
 				// Task counts across all agents (before {slug} to avoid conflict)
 				r.Get("/task-counts", agentTasksHandler.AllCounts)
 				// Gateway file endpoints (before {slug} to avoid conflict)
@@ -361,6 +442,7 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Put("/{slug}", agentRolesHandler.Update)
 				r.Put("/{slug}/toggle", agentRolesHandler.Toggle)
 				r.Delete("/{slug}", agentRolesHandler.Delete)
+				r.Get("/{slug}/export", agentLibraryHandler.ExportAgent)
 				// Identity files
 				r.Get("/{slug}/files", agentRolesHandler.GetFiles)
 				r.Get("/{slug}/files/*", agentRolesHandler.GetFile)
@@ -400,6 +482,19 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 			r.Get("/gateway/memories", memoryHandler.ListGatewayMemories)
 			r.Get("/gateway/memories/stats", memoryHandler.GetGatewayStats)
 
+			// Shared memory scopes across agents
+			r.Route("/shared-memory", func(r chi.Router) {
+				r.Get("/", sharedMemoryHandler.List)
+				r.Post("/", sharedMemoryHandler.Create)
+				r.Get("/{id}", sharedMemoryHandler.Get)
+				r.Put("/{id}", sharedMemoryHandler.Update)
+				r.Delete("/{id}", sharedMemoryHandler.Delete)
+				r.Put("/{id}/access", sharedMemoryHandler.SetAccess)
+				r.Get("/{id}/memories", sharedMemoryHandler.ListMemories)
+				r.Get("/{id}/memories/stats", sharedMemoryHandler.GetStats)
+				r.Delete("/{id}/memories/{memoryId}", sharedMemoryHandler.DeleteMemory)
+			})
+
 			// Tool ownership
 			r.Put("/tools/{id}/owner", agentRolesHandler.UpdateToolOwner)
 
@@ -422,15 +517,24 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 			r.Route("/chat", func(r chi.Router) {
 				r.Get("/active", chatHandler.ActiveThreads)
 				r.Get("/threads", chatHandler.ListThreads)
+				r.Get("/search", chatHandler.SearchHistory)
 				r.Get("/threads/active", chatHandler.ActiveThreadIds)
 				r.Post("/threads", chatHandler.CreateThread)
+				r.Post("/threads/import", chatHandler.ImportThread)
 				r.Put("/threads/{id}", chatHandler.UpdateThread)
 				r.Delete("/threads/{id}", chatHandler.DeleteThread)
 				r.Get("/threads/{id}/status", chatHandler.ThreadStatus)
 				r.Get("/threads/{id}/stats", chatHandler.ThreadStats)
 				r.Get("/threads/{id}/messages", chatHandler.GetMessages)
 				r.Post("/threads/{id}/messages", chatHandler.SendMessage)
+				r.Post("/threads/{id}/messages/{messageId}/edit", chatHandler.EditMessage)
+				r.Post("/threads/{id}/messages/{messageId}/regenerate", chatHandler.RegenerateMessage)
+				r.Post("/threads/{id}/branch", chatHandler.SwitchBranch)
 				r.Post("/threads/{id}/compact", chatHandler.CompactThread)
+				r.Get("/threads/{id}/export", chatHandler.ExportThread)
+				r.Get("/threads/{id}/shares", chatHandler.ListShares)
+				r.Post("/threads/{id}/shares", chatHandler.CreateShare)
+				r.Delete("/threads/{id}/shares/{shareId}", chatHandler.RevokeShare)
 				r.Get("/threads/{id}/pin", chatHandler.GetPin)
 				r.Post("/threads/{id}/pin", chatHandler.PinThread)
 				r.Post("/threads/{id}/unpin", chatHandler.UnpinThread)
@@ -466,6 +570,10 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Put("/files/{id}", contextHandler.UpdateFile)
 				r.Delete("/files/{id}", contextHandler.DeleteFile)
 				r.Put("/files/{id}/move", contextHandler.MoveFile)
+				r.Get("/files/{id}/revisions", contextHandler.ListRevisions)
+				r.Get("/files/{id}/revisions/{revisionId}", contextHandler.GetRevision)
+				r.Get("/files/{id}/revisions/{revisionId}/diff", contextHandler.RevisionDiff)
+				r.Post("/files/{id}/revisions/{revisionId}/restore", contextHandler.RestoreRevision)
 				r.Get("/about-you", contextHandler.GetAboutYou)
 				r.Put("/about-you", contextHandler.UpdateAboutYou)
 			})
@@ -487,9 +595,15 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Delete("/columns/{columnId}", databasesHandler.DeleteColumn)
 				r.Get("/tables/{tableId}/rows", databasesHandler.ListRows)
 				r.Get("/tables/{tableId}/query", databasesHandler.QueryRows)
+				r.Post("/tables/{tableId}/query", databasesHandler.QueryRows)
 				r.Post("/tables/{tableId}/rows", databasesHandler.CreateRow)
 				r.Put("/rows/{rowId}", databasesHandler.UpdateRow)
 				r.Delete("/rows/{rowId}", databasesHandler.DeleteRow)
+				r.Get("/rows/{rowId}/history", databasesHandler.RowHistory)
+				r.Post("/rows/{rowId}/restore", databasesHandler.RestoreRow)
+				r.Get("/tables/{tableId}/history", databasesHandler.TableHistory)
+				r.Post("/tables/{tableId}/restore", databasesHandler.RestoreTable)
+				r.Get("/revisions/{revisionId}/diff", databasesHandler.RevisionDiff)
 			})
 
 			// Chat attachments
@@ -517,11 +631,34 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Delete("/", notificationsHandler.DismissAll)
 			})
 
+			// Notification delivery: channels, routing rules and delivery logs
+			r.Route("/notification-channels", func(r chi.Router) {
+				r.Get("/", notificationChannelsHandler.ListChannels)
+				r.Post("/", notificationChannelsHandler.CreateChannel)
+				r.Put("/{id}", notificationChannelsHandler.UpdateChannel)
+				r.Delete("/{id}", notificationChannelsHandler.DeleteChannel)
+				r.Post("/{id}/test", notificationChannelsHandler.TestChannel)
+				r.Get("/{id}/deliveries", notificationChannelsHandler.Deliveries)
+			})
+			r.Route("/notification-rules", func(r chi.Router) {
+				r.Get("/", notificationChannelsHandler.ListRules)
+				r.Post("/", notificationChannelsHandler.CreateRule)
+				r.Put("/{id}", notificationChannelsHandler.UpdateRule)
+				r.Delete("/{id}", notificationChannelsHandler.DeleteRule)
+			})
+			r.Post("/notification-deliveries/{id}/retry", notificationChannelsHandler.RetryDelivery)
+
 			// Heartbeat
 			r.Route("/dreaming", func(r chi.Router) {
 				r.Get("/config", dreamingHandler.GetConfig)
 				r.Put("/config", dreamingHandler.UpdateConfig)
 				r.Get("/runs", dreamingHandler.ListRuns)
+				r.Post("/runs/{id}/approve", dreamingHandler.ApproveRun)
+				r.Post("/runs/{id}/reject", dreamingHandler.RejectRun)
+				r.Post("/runs/{id}/revert", dreamingHandler.RevertRun)
+				r.Get("/review", dreamingHandler.ReviewQueue)
+				r.Post("/ops/{id}/approve", dreamingHandler.ApproveOp)
+				r.Post("/ops/{id}/reject", dreamingHandler.RejectOp)
 				r.Post("/run-now", dreamingHandler.RunNow)
 			})
 
@@ -537,6 +674,7 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Get("/", backupHandler.GetConfig)
 				r.Put("/", backupHandler.UpdateConfig)
 				r.Post("/run", backupHandler.RunNow)
+				r.Post("/restore", backupHandler.Restore)
 				r.Post("/test", backupHandler.TestConnection)
 				r.Get("/history", backupHandler.ListHistory)
 				r.Get("/detect-git", backupHandler.DetectGit)
@@ -589,6 +727,9 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 				r.Get("/{id}/directories", workspacesHandler.ListDirectories)
 				r.Post("/{id}/directories", workspacesHandler.AddDirectory)
 				r.Delete("/{id}/directories/{dirId}", workspacesHandler.RemoveDirectory)
+				r.Get("/{id}/members", usersHandler.ListMembers)
+				r.Put("/{id}/members/{userId}", usersHandler.SetMember)
+				r.Delete("/{id}/members/{userId}", usersHandler.RemoveMember)
 			})
 
 			// Terminal / Workbench
@@ -682,6 +823,12 @@ func (s *Server) setupRoutes(toolMgr *toolmgr.Manager, toolsDir string, dataDir
 			r.Get("/settings/available-models", settingsHandler.AvailableModels)
 			r.Get("/settings/llm-provider", settingsHandler.GetLLMProvider)
 			r.Put("/settings/llm-provider", settingsHandler.UpdateLLMProvider)
+			r.Get("/settings/openai-compatible", settingsHandler.GetOpenAICompat)
+			r.Put("/settings/openai-compatible", settingsHandler.UpdateOpenAICompat)
+			r.Get("/settings/memory-embeddings", settingsHandler.GetMemoryEmbeddings)
+			r.Put("/settings/memory-embeddings", settingsHandler.UpdateMemoryEmbeddings)
+			r.Get("/settings/mcp-endpoint", mcpEndpointHandler.GetSettings)
+			r.Put("/settings/mcp-endpoint", mcpEndpointHandler.UpdateSettings)
 			r.Get("/settings/openclaw", settingsHandler.GetOpenClaw)
 			r.Post("/settings/openclaw/sync", settingsHandler.SyncOpenClaw)
 			r.Delete("/settings/openclaw", settingsHandler.RemoveOpenClaw)
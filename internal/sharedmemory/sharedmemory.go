@@ -0,0 +1,360 @@
+// Package sharedmemory manages the shared memory scopes agents have in common
+// and who may reach them. The memories themselves are ordinary memory
+// databases owned by the memory package; this package keeps the scope list and
+// its access rules, and answers the memory manager's question of which scopes
+// a given agent can read or write.
+package sharedmemory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/memory"
+)
+
+// Access levels, for a scope's default and for per-agent grants.
+const (
+	AccessNone  = "none"
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// ErrInvalid is returned for a scope or grant that fails validation.
+var ErrInvalid = errors.New("invalid shared memory scope")
+
+// Grant overrides a scope's default access for one agent.
+type Grant struct {
+	AgentSlug string `json:"agent_slug"`
+	Access    string `json:"access"`
+}
+
+// Scope is a shared memory scope with its access rules. WorkspaceID nil makes
+// it global.
+type Scope struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	WorkspaceID   *string   `json:"workspace_id"`
+	DefaultAccess string    `json:"default_access"`
+	Access        []Grant   `json:"access"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Input creates or updates a scope. Update leaves nil fields alone.
+type Input struct {
+	Name          *string `json:"name"`
+	Description   *string `json:"description"`
+	WorkspaceID   *string `json:"workspace_id"`
+	DefaultAccess *string `json:"default_access"`
+}
+
+type Store struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+func validAccess(a string) bool {
+	return a == AccessNone || a == AccessRead || a == AccessWrite
+}
+
+const scopeColumns = `id, name, description, workspace_id, default_access, created_at, updated_at`
+
+func scanScope(row interface{ Scan(...interface{}) error }) (Scope, error) {
+	var s Scope
+	var ws sql.NullString
+	err := row.Scan(&s.ID, &s.Name, &s.Description, &ws, &s.DefaultAccess, &s.CreatedAt, &s.UpdatedAt)
+	if ws.Valid {
+		s.WorkspaceID = &ws.String
+	}
+	s.Access = []Grant{}
+	return s, err
+}
+
+// List returns every scope with its grants, by name.
+func (s *Store) List() ([]Scope, error) {
+	rows, err := s.db.Query(`SELECT ` + scopeColumns + ` FROM shared_memory_scopes ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scopes := []Scope{}
+	index := map[string]int{}
+	for rows.Next() {
+		sc, err := scanScope(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[sc.ID] = len(scopes)
+		scopes = append(scopes, sc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	grants, err := s.db.Query(`SELECT scope_id, agent_slug, access FROM shared_memory_access ORDER BY agent_slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer grants.Close()
+	for grants.Next() {
+		var scopeID string
+		var g Grant
+		if err := grants.Scan(&scopeID, &g.AgentSlug, &g.Access); err != nil {
+			return nil, err
+		}
+		if i, ok := index[scopeID]; ok {
+			scopes[i].Access = append(scopes[i].Access, g)
+		}
+	}
+	return scopes, grants.Err()
+}
+
+// Get returns one scope with its grants, or sql.ErrNoRows.
+func (s *Store) Get(id string) (*Scope, error) {
+	sc, err := scanScope(s.db.QueryRow(`SELECT `+scopeColumns+` FROM shared_memory_scopes WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT agent_slug, access FROM shared_memory_access WHERE scope_id = ? ORDER BY agent_slug`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g Grant
+		if err := rows.Scan(&g.AgentSlug, &g.Access); err != nil {
+			return nil, err
+		}
+		sc.Access = append(sc.Access, g)
+	}
+	return &sc, rows.Err()
+}
+
+// Create adds a scope. Name is required; access defaults to write for every
+// agent the scope reaches.
+func (s *Store) Create(in Input) (*Scope, error) {
+	sc := Scope{ID: uuid.New().String(), DefaultAccess: AccessWrite}
+	if err := s.apply(&sc, in); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	_, err := s.db.Exec(
+		`INSERT INTO shared_memory_scopes (id, name, description, workspace_id, default_access, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sc.ID, sc.Name, sc.Description, sc.WorkspaceID, sc.DefaultAccess, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(sc.ID)
+}
+
+// Update changes a scope's name, description, workspace or default access.
+func (s *Store) Update(id string, in Input) (*Scope, error) {
+	sc, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(sc, in); err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(
+		`UPDATE shared_memory_scopes SET name = ?, description = ?, workspace_id = ?, default_access = ?, updated_at = ?
+		 WHERE id = ?`,
+		sc.Name, sc.Description, sc.WorkspaceID, sc.DefaultAccess, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+func (s *Store) apply(sc *Scope, in Input) error {
+	if in.Name != nil {
+		sc.Name = strings.TrimSpace(*in.Name)
+	}
+	if sc.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if strings.EqualFold(sc.Name, "all") || strings.EqualFold(sc.Name, "own") || strings.EqualFold(sc.Name, "personal") {
+		// These are what the memory tools' scope argument already means.
+		return fmt.Errorf("%w: %q is reserved", ErrInvalid, sc.Name)
+	}
+	var taken bool
+	s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM shared_memory_scopes WHERE name = ? AND id != ?)", sc.Name, sc.ID).Scan(&taken)
+	if taken {
+		return fmt.Errorf("%w: a scope named %q already exists", ErrInvalid, sc.Name)
+	}
+	if in.Description != nil {
+		sc.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.WorkspaceID != nil {
+		if ws := strings.TrimSpace(*in.WorkspaceID); ws == "" {
+			sc.WorkspaceID = nil
+		} else {
+			var exists bool
+			s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM workspaces WHERE id = ?)", ws).Scan(&exists)
+			if !exists {
+				return fmt.Errorf("%w: workspace %q not found", ErrInvalid, ws)
+			}
+			sc.WorkspaceID = &ws
+		}
+	}
+	if in.DefaultAccess != nil {
+		sc.DefaultAccess = *in.DefaultAccess
+	}
+	if !validAccess(sc.DefaultAccess) {
+		return fmt.Errorf("%w: default_access must be none, read or write", ErrInvalid)
+	}
+	return nil
+}
+
+// Delete removes a scope and its grants. The caller drops the memory
+// database.
+func (s *Store) Delete(id string) error {
+	res, err := s.db.Exec("DELETE FROM shared_memory_scopes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetAccess replaces a scope's per-agent grants.
+func (s *Store) SetAccess(id string, grants []Grant) (*Scope, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i, g := range grants {
+		g.AgentSlug = strings.TrimSpace(g.AgentSlug)
+		if g.AgentSlug == "" || !validAccess(g.Access) {
+			return nil, fmt.Errorf("%w: each grant needs an agent_slug and an access of none, read or write", ErrInvalid)
+		}
+		if seen[g.AgentSlug] {
+			return nil, fmt.Errorf("%w: %s is listed twice", ErrInvalid, g.AgentSlug)
+		}
+		seen[g.AgentSlug] = true
+		grants[i] = g
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM shared_memory_access WHERE scope_id = ?", id); err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		if _, err := tx.Exec(
+			"INSERT INTO shared_memory_access (scope_id, agent_slug, access) VALUES (?, ?, ?)",
+			id, g.AgentSlug, g.Access,
+		); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec("UPDATE shared_memory_scopes SET updated_at = ? WHERE id = ?", time.Now().UTC(), id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// effectiveAccess is what an agent gets in a scope: its own grant if it has
+// one, otherwise the scope's default — but a workspace scope's default only
+// reaches the agents pinned to that workspace.
+func effectiveAccess(sc Scope, agentSlug, agentWorkspace string) string {
+	for _, g := range sc.Access {
+		if g.AgentSlug == agentSlug {
+			return g.Access
+		}
+	}
+	if sc.WorkspaceID != nil && *sc.WorkspaceID != agentWorkspace {
+		return AccessNone
+	}
+	return sc.DefaultAccess
+}
+
+func (s *Store) agentWorkspace(slug string) string {
+	var ws sql.NullString
+	s.db.QueryRow("SELECT workspace_id FROM agent_roles WHERE slug = ?", slug).Scan(&ws)
+	return ws.String
+}
+
+// ScopesFor lists the scopes an agent can reach, as the memory manager's
+// scope resolver. Errors leave the agent with only its own memory.
+func (s *Store) ScopesFor(agentSlug string) []memory.Scope {
+	scopes, err := s.List()
+	if err != nil || len(scopes) == 0 {
+		return nil
+	}
+	ws := s.agentWorkspace(agentSlug)
+	var out []memory.Scope
+	for _, sc := range scopes {
+		access := effectiveAccess(sc, agentSlug, ws)
+		if access == AccessNone {
+			continue
+		}
+		out = append(out, toMemoryScope(sc, access == AccessWrite))
+	}
+	return out
+}
+
+func toMemoryScope(sc Scope, write bool) memory.Scope {
+	ms := memory.Scope{ID: sc.ID, Name: sc.Name, Write: write}
+	if sc.WorkspaceID != nil {
+		ms.WorkspaceID = *sc.WorkspaceID
+	}
+	return ms
+}
+
+// All lists every scope, for the dream's promotion pass.
+func (s *Store) All() ([]memory.Scope, error) {
+	scopes, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]memory.Scope, 0, len(scopes))
+	for _, sc := range scopes {
+		out = append(out, toMemoryScope(sc, true))
+	}
+	return out, nil
+}
+
+// Writers lists the enabled agents that may write to a scope.
+func (s *Store) Writers(scopeID string) ([]string, error) {
+	sc, err := s.Get(scopeID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query("SELECT slug, COALESCE(workspace_id, '') FROM agent_roles WHERE enabled = 1 ORDER BY sort_order, slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var slug, ws string
+		if err := rows.Scan(&slug, &ws); err != nil {
+			return nil, err
+		}
+		if effectiveAccess(*sc, slug, ws) == AccessWrite {
+			out = append(out, slug)
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,100 @@
+package sharedmemory
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openpaw/openpaw/internal/database"
+)
+
+const defaultWorkspace = "00000000-0000-0000-0000-000000000001"
+
+func newTestStore(t *testing.T) (*Store, *database.DB) {
+	t.Helper()
+	db, err := database.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, a := range []struct{ slug, ws string }{{"scout", defaultWorkspace}, {"builder", ""}, {"intern", ""}} {
+		var ws interface{}
+		if a.ws != "" {
+			ws = a.ws
+		}
+		if _, err := db.Exec("INSERT INTO agent_roles (id, slug, name, workspace_id) VALUES (?, ?, ?, ?)", a.slug, a.slug, a.slug, ws); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(db), db
+}
+
+func str(s string) *string { return &s }
+
+func names(t *testing.T, s *Store, agent string) string {
+	t.Helper()
+	var out []string
+	for _, sc := range s.ScopesFor(agent) {
+		access := "r"
+		if sc.Write {
+			access = "w"
+		}
+		out = append(out, sc.Name+":"+access)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestScopesForAppliesDefaultsWorkspacesAndGrants(t *testing.T) {
+	s, _ := newTestStore(t)
+	team, err := s.Create(Input{Name: str("Team")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := s.Create(Input{Name: str("Project"), WorkspaceID: str(defaultWorkspace), DefaultAccess: str(AccessRead)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(t, s, "scout"); got != "Project:r,Team:w" {
+		t.Errorf("scout = %q", got)
+	}
+	if got := names(t, s, "builder"); got != "Team:w" {
+		t.Errorf("builder = %q", got)
+	}
+
+	// Grants override the default, and reach agents outside the workspace.
+	if _, err := s.SetAccess(team.ID, []Grant{{AgentSlug: "intern", Access: AccessNone}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetAccess(project.ID, []Grant{{AgentSlug: "builder", Access: AccessWrite}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(t, s, "intern"); got != "" {
+		t.Errorf("intern = %q", got)
+	}
+	if got := names(t, s, "builder"); got != "Project:w,Team:w" {
+		t.Errorf("builder = %q", got)
+	}
+
+	writers, err := s.Writers(team.ID)
+	if err != nil || strings.Join(writers, ",") != "builder,scout" {
+		t.Errorf("team writers = %v %v", writers, err)
+	}
+}
+
+func TestCreateValidates(t *testing.T) {
+	s, _ := newTestStore(t)
+	if _, err := s.Create(Input{Name: str("Team")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []Input{
+		{Name: str("team")},
+		{Name: str("all")},
+		{Name: str("Ops"), DefaultAccess: str("admin")},
+		{Name: str("Ops"), WorkspaceID: str("nope")},
+	} {
+		if _, err := s.Create(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%s) = %v", *in.Name, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package textdiff compares texts line by line and renders the result as a
+// unified diff, the format git and patch read.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of a diff: kept, added to the new text, or removed from
+// the old one.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// maxEditDistance bounds the search for a minimal diff. Texts further apart
+// than this are shown as one replacement: the diff is still correct, just not
+// minimal, and the search's memory stays bounded.
+const maxEditDistance = 2000
+
+// Lines diffs a and b by line. A trailing newline doesn't count as a line of
+// its own, so "a\n" and "a" compare equal.
+func Lines(a, b string) []Edit {
+	return diff(splitLines(a), splitLines(b))
+}
+
+// Count returns how many lines the edits add and remove.
+func Count(edits []Edit) (added, removed int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified renders edits as a unified diff with context lines of surrounding
+// text around each change. Identical texts render as "".
+func Unified(edits []Edit, fromName, toName string, context int) string {
+	if context < 0 {
+		context = 0
+	}
+	var changes []int
+	for i, e := range edits {
+		if e.Op != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	// aAt[i] and bAt[i] are how many lines of each text precede edits[i].
+	aAt := make([]int, len(edits)+1)
+	bAt := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aAt[i+1], bAt[i+1] = aAt[i], bAt[i]
+		if e.Op != Insert {
+			aAt[i+1]++
+		}
+		if e.Op != Delete {
+			bAt[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changes); {
+		start := max(changes[i]-context, 0)
+		end := min(changes[i]+context+1, len(edits))
+		// Changes close enough to share context lines share a hunk.
+		for i++; i < len(changes) && changes[i]-context <= end; i++ {
+			end = min(changes[i]+context+1, len(edits))
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(aAt[start], aAt[end]-aAt[start]), hunkRange(bAt[start], bAt[end]-bAt[start]))
+		for _, e := range edits[start:end] {
+			b.WriteString([]string{" ", "+", "-"}[e.Op] + e.Line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// hunkRange formats a hunk's line range: 1-based, or for an empty range the
+// line it follows.
+func hunkRange(before, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func diff(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script with Myers' O(ND) algorithm, keeping
+// the frontier of each round so the path can be walked back.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	// trace[d] holds v[-d-1 .. d+1] as it stood before round d.
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEditDistance {
+			return replace(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return replace(a, b)
+}
+
+func backtrack(trace [][]int, a, b []string) []Edit {
+	var rev []Edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, Edit{Equal, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, Edit{Insert, b[y-1]})
+			} else {
+				rev = append(rev, Edit{Delete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+		rev[i], rev[j] = rev[j], rev[i]
+	}
+	return rev
+}
+
+func replace(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Delete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Insert, line})
+	}
+	return edits
+}
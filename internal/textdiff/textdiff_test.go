@@ -0,0 +1,72 @@
+package textdiff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "# Plan\nIntro\nOne\nTwo\nThree\nFour\nFive\nSix\nSeven\nEight\nNine\n"
+	b := "# Plan\nIntro\nOne\nTwo (revised)\nThree\nFour\nFive\nSix\nSeven\nEight\nNine\nTen\n"
+	edits := Lines(a, b)
+	if added, removed := Count(edits); added != 2 || removed != 1 {
+		t.Fatalf("count = +%d -%d", added, removed)
+	}
+	got := Unified(edits, "a/plan.md", "b/plan.md", 2)
+	want := `--- a/plan.md
++++ b/plan.md
+@@ -2,5 +2,5 @@
+ Intro
+ One
+-Two
++Two (revised)
+ Three
+ Four
+@@ -10,2 +10,3 @@
+ Eight
+ Nine
++Ten
+`
+	if got != want {
+		t.Errorf("unified diff:\n%s\nwant:\n%s", got, want)
+	}
+	if Unified(Lines(a, a), "a", "b", 3) != "" {
+		t.Error("identical texts should have an empty diff")
+	}
+	if got := Unified(Lines("", "new\n"), "a", "b", 3); !strings.Contains(got, "@@ -0,0 +1,1 @@\n+new\n") {
+		t.Errorf("diff from empty:\n%s", got)
+	}
+}
+
+// Applying the edits to the old text must give the new one, and the edit
+// script must be no longer than the naive one.
+func TestLinesReconstructs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	for i := 0; i < 200; i++ {
+		var a, b []string
+		for j := r.Intn(30); j > 0; j-- {
+			a = append(a, words[r.Intn(len(words))])
+		}
+		for j := r.Intn(30); j > 0; j-- {
+			b = append(b, words[r.Intn(len(words))])
+		}
+		edits := diff(a, b)
+		var old, neu []string
+		for _, e := range edits {
+			if e.Op != Insert {
+				old = append(old, e.Line)
+			}
+			if e.Op != Delete {
+				neu = append(neu, e.Line)
+			}
+		}
+		if strings.Join(old, ",") != strings.Join(a, ",") || strings.Join(neu, ",") != strings.Join(b, ",") {
+			t.Fatalf("edits of %v -> %v don't reconstruct them: %v", a, b, edits)
+		}
+		if added, removed := Count(edits); added+removed > len(a)+len(b) {
+			t.Fatalf("edit script longer than a replacement: %v", edits)
+		}
+	}
+}
@@ -108,9 +108,13 @@ func (s *Store) ImportCSV(workspaceID, filename string, source io.Reader) (resul
 		if marshalErr != nil {
 			return result, marshalErr
 		}
-		if _, insertErr := statement.Exec(uuid.New().String(), table.ID, string(raw), rowIndex, now, now); insertErr != nil {
+		id := uuid.New().String()
+		if _, insertErr := statement.Exec(id, table.ID, string(raw), rowIndex, now, now); insertErr != nil {
 			return result, insertErr
 		}
+		if recordErr := s.record(tx, table.ID, id, "", RevisionRowCreated, nil, values); recordErr != nil {
+			return result, recordErr
+		}
 	}
 	if err := tx.Commit(); err != nil {
 		return result, err
@@ -0,0 +1,668 @@
+package userdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Formula columns use a small expression language, parsed once and evaluated
+// per row. It has no loops, no variables and no access to anything but the
+// row's own columns, so a formula can't do more than compute a value.
+//
+//	{Amount} * 1.2
+//	IF({Status} = "Done", "✓", "")
+//	ROUND({Price} * {Qty}, 2)
+//	{First} & " " & {Last}
+//	DAYS(TODAY(), {Due})
+//
+// Operators: + - * / & = != < <= > >=, parentheses and unary minus.
+// Functions: IF, AND, OR, NOT, BLANK, ROUND, ABS, MIN, MAX, SUM, AVG, COUNT,
+// LEN, UPPER, LOWER, TRIM, CONCAT, TODAY, DAYS.
+
+const maxFormulaLength = 2000
+
+// formulaEnv resolves a {Column Name} reference for one row.
+type formulaEnv func(name string) (interface{}, error)
+
+type formulaNode interface {
+	eval(env formulaEnv) (interface{}, error)
+}
+
+// Formula is a parsed formula expression.
+type Formula struct {
+	root formulaNode
+	refs []string
+}
+
+// References lists the column names the formula reads, in order of first use.
+func (f *Formula) References() []string {
+	return f.refs
+}
+
+// Eval computes the formula against one row.
+func (f *Formula) Eval(env formulaEnv) (interface{}, error) {
+	return f.root.eval(env)
+}
+
+// ParseFormula parses an expression, reporting the first syntax error.
+func ParseFormula(expression string) (*Formula, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, errors.New("formula expression is required")
+	}
+	if len(expression) > maxFormulaLength {
+		return nil, fmt.Errorf("formula is longer than %d characters", maxFormulaLength)
+	}
+	tokens, err := tokenizeFormula(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &formulaParser{tokens: tokens, seen: map[string]bool{}}
+	root, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("formula: unexpected %q", p.peek().text)
+	}
+	return &Formula{root: root, refs: p.refs}, nil
+}
+
+// --- Tokenizer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokRef
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type formulaToken struct {
+	kind tokenKind
+	text string
+}
+
+func tokenizeFormula(src string) ([]formulaToken, error) {
+	var tokens []formulaToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '{':
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("formula: unclosed { column reference")
+			}
+			name := strings.TrimSpace(string(runes[i+1 : end]))
+			if name == "" {
+				return nil, errors.New("formula: empty {} column reference")
+			}
+			tokens = append(tokens, formulaToken{tokRef, name})
+			i = end + 1
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				sb.WriteRune(runes[end])
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("formula: unclosed string")
+			}
+			tokens = append(tokens, formulaToken{tokString, sb.String()})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, formulaToken{tokNumber, string(runes[i:end])})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, formulaToken{tokIdent, strings.ToUpper(string(runes[i:end]))})
+			i = end
+		case r == '(':
+			tokens = append(tokens, formulaToken{tokLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, formulaToken{tokRParen, ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, formulaToken{tokComma, ","})
+			i++
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<=" || two == ">=" || two == "!=" || two == "<>" {
+					tokens = append(tokens, formulaToken{tokOp, two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("+-*/&=<>", r) {
+				tokens = append(tokens, formulaToken{tokOp, string(r)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("formula: unexpected character %q", r)
+		}
+	}
+	return append(tokens, formulaToken{kind: tokEOF}), nil
+}
+
+// --- Parser ---
+
+type formulaParser struct {
+	tokens []formulaToken
+	pos    int
+	refs   []string
+	seen   map[string]bool
+}
+
+func (p *formulaParser) peek() formulaToken { return p.tokens[p.pos] }
+
+func (p *formulaParser) next() formulaToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *formulaParser) binary(next func() (formulaNode, error), ops ...string) (formulaNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range ops {
+			if t.kind == tokOp && t.text == op {
+				matched = true
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.next()
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *formulaParser) comparison() (formulaNode, error) {
+	return p.binary(p.concat, "=", "!=", "<>", "<", "<=", ">", ">=")
+}
+
+func (p *formulaParser) concat() (formulaNode, error) { return p.binary(p.additive, "&") }
+
+func (p *formulaParser) additive() (formulaNode, error) { return p.binary(p.term, "+", "-") }
+
+func (p *formulaParser) term() (formulaNode, error) { return p.binary(p.unary, "*", "/") }
+
+func (p *formulaParser) unary() (formulaNode, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "-" {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: "-", left: literalNode{float64(0)}, right: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *formulaParser) primary() (formulaNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("formula: bad number %q", t.text)
+		}
+		return literalNode{n}, nil
+	case tokString:
+		return literalNode{t.text}, nil
+	case tokRef:
+		if key := strings.ToLower(t.text); !p.seen[key] {
+			p.seen[key] = true
+			p.refs = append(p.refs, t.text)
+		}
+		return refNode{name: t.text}, nil
+	case tokLParen:
+		inner, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, errors.New("formula: missing )")
+		}
+		return inner, nil
+	case tokIdent:
+		switch t.text {
+		case "TRUE":
+			return literalNode{true}, nil
+		case "FALSE":
+			return literalNode{false}, nil
+		}
+		fn, ok := formulaFunctions[t.text]
+		if !ok {
+			return nil, fmt.Errorf("formula: unknown function %s", t.text)
+		}
+		if p.next().kind != tokLParen {
+			return nil, fmt.Errorf("formula: %s needs ( after it", t.text)
+		}
+		var args []formulaNode
+		if p.peek().kind != tokRParen {
+			for {
+				arg, err := p.comparison()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("formula: missing ) after %s arguments", t.text)
+		}
+		if len(args) < fn.min || (fn.max >= 0 && len(args) > fn.max) {
+			return nil, fmt.Errorf("formula: wrong number of arguments to %s", t.text)
+		}
+		return callNode{name: t.text, fn: fn, args: args}, nil
+	case tokEOF:
+		return nil, errors.New("formula: unexpected end of expression")
+	}
+	return nil, fmt.Errorf("formula: unexpected %q", t.text)
+}
+
+// --- Evaluation ---
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(formulaEnv) (interface{}, error) { return n.value, nil }
+
+type refNode struct{ name string }
+
+func (n refNode) eval(env formulaEnv) (interface{}, error) { return env(n.name) }
+
+type binaryNode struct {
+	op          string
+	left, right formulaNode
+}
+
+func (n binaryNode) eval(env formulaEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&":
+		return formulaText(left) + formulaText(right), nil
+	case "=", "!=", "<>":
+		equal := formulaCompare(left, right) == 0
+		if n.op == "=" {
+			return equal, nil
+		}
+		return !equal, nil
+	case "<":
+		return formulaCompare(left, right) < 0, nil
+	case "<=":
+		return formulaCompare(left, right) <= 0, nil
+	case ">":
+		return formulaCompare(left, right) > 0, nil
+	case ">=":
+		return formulaCompare(left, right) >= 0, nil
+	}
+	a, err := formulaNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	b, err := formulaNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+type formulaFunction struct {
+	min, max int // max < 0 means variadic
+	// lazy functions get unevaluated arguments (IF must not evaluate the
+	// branch it doesn't take).
+	lazy func(env formulaEnv, args []formulaNode) (interface{}, error)
+	call func(args []interface{}) (interface{}, error)
+}
+
+type callNode struct {
+	name string
+	fn   formulaFunction
+	args []formulaNode
+}
+
+func (n callNode) eval(env formulaEnv) (interface{}, error) {
+	if n.fn.lazy != nil {
+		return n.fn.lazy(env, n.args)
+	}
+	values := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return n.fn.call(values)
+}
+
+// formulaNow is the clock TODAY and DAYS read; tests pin it.
+var formulaNow = time.Now
+
+var formulaFunctions = map[string]formulaFunction{
+	"IF": {min: 2, max: 3, lazy: func(env formulaEnv, args []formulaNode) (interface{}, error) {
+		cond, err := args[0].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if formulaTruthy(cond) {
+			return args[1].eval(env)
+		}
+		if len(args) == 3 {
+			return args[2].eval(env)
+		}
+		return nil, nil
+	}},
+	"AND": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		for _, a := range args {
+			if !formulaTruthy(a) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}},
+	"OR": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		for _, a := range args {
+			if formulaTruthy(a) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}},
+	"NOT": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return !formulaTruthy(args[0]), nil
+	}},
+	"BLANK": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return formulaBlank(args[0]), nil
+	}},
+	"ROUND": {min: 1, max: 2, call: func(args []interface{}) (interface{}, error) {
+		n, err := formulaNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		places := 0.0
+		if len(args) == 2 {
+			if places, err = formulaNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, math.Trunc(places))
+		return math.Round(n*scale) / scale, nil
+	}},
+	"ABS": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		n, err := formulaNumber(args[0])
+		return math.Abs(n), err
+	}},
+	"MIN": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		return formulaReduce(args, func(acc, n float64) float64 { return math.Min(acc, n) })
+	}},
+	"MAX": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		return formulaReduce(args, func(acc, n float64) float64 { return math.Max(acc, n) })
+	}},
+	"SUM": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		total := 0.0
+		for _, v := range formulaFlatten(args) {
+			n, err := formulaNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return total, nil
+	}},
+	"AVG": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		values := formulaFlatten(args)
+		if len(values) == 0 {
+			return nil, nil
+		}
+		total := 0.0
+		for _, v := range values {
+			n, err := formulaNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return total / float64(len(values)), nil
+	}},
+	"COUNT": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		return float64(len(formulaFlatten(args))), nil
+	}},
+	"LEN": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return float64(len([]rune(formulaText(args[0])))), nil
+	}},
+	"UPPER": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(formulaText(args[0])), nil
+	}},
+	"LOWER": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(formulaText(args[0])), nil
+	}},
+	"TRIM": {min: 1, max: 1, call: func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(formulaText(args[0])), nil
+	}},
+	"CONCAT": {min: 1, max: -1, call: func(args []interface{}) (interface{}, error) {
+		var sb strings.Builder
+		for _, a := range args {
+			sb.WriteString(formulaText(a))
+		}
+		return sb.String(), nil
+	}},
+	"TODAY": {min: 0, max: 0, call: func([]interface{}) (interface{}, error) {
+		return formulaNow().Format("2006-01-02"), nil
+	}},
+	"DAYS": {min: 2, max: 2, call: func(args []interface{}) (interface{}, error) {
+		end, err := formulaDate(args[0])
+		if err != nil {
+			return nil, err
+		}
+		start, err := formulaDate(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return math.Round(end.Sub(start).Hours() / 24), nil
+	}},
+}
+
+// formulaFlatten spreads lookup lists into their values and drops blanks.
+func formulaFlatten(args []interface{}) []interface{} {
+	var out []interface{}
+	for _, a := range args {
+		if list, ok := a.([]interface{}); ok {
+			out = append(out, formulaFlatten(list)...)
+		} else if !formulaBlank(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func formulaReduce(args []interface{}, combine func(acc, n float64) float64) (interface{}, error) {
+	values := formulaFlatten(args)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	acc, err := formulaNumber(values[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values[1:] {
+		n, err := formulaNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		acc = combine(acc, n)
+	}
+	return acc, nil
+}
+
+func formulaBlank(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(t) == ""
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+func formulaTruthy(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case []interface{}:
+		return len(t) > 0
+	}
+	return !formulaBlank(v)
+}
+
+// formulaNumber reads a value as a number. Blank counts as zero, so a sum
+// over a row with an empty cell still works.
+func formulaNumber(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return t, nil
+	case int:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if strings.TrimSpace(t) == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", t)
+		}
+		return n, nil
+	case []interface{}:
+		if len(t) == 1 {
+			return formulaNumber(t[0])
+		}
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func formulaText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, item := range t {
+			parts = append(parts, formulaText(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func formulaDate(v interface{}) (time.Time, error) {
+	s := formulaText(v)
+	if len(s) >= 10 {
+		if t, err := time.Parse("2006-01-02", s[:10]); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", s)
+}
+
+// formulaCompare orders two values: numerically when both read as numbers,
+// otherwise as case-insensitive text.
+func formulaCompare(a, b interface{}) int {
+	_, aText := a.(string)
+	_, bText := b.(string)
+	if !(aText && bText) {
+		x, errA := formulaNumber(a)
+		y, errB := formulaNumber(b)
+		if errA == nil && errB == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(formulaText(a)), strings.ToLower(formulaText(b)))
+}
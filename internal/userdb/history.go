@@ -0,0 +1,406 @@
+package userdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Revision kinds. Row revisions snapshot the row's values; column revisions
+// snapshot the column definition; a table rename snapshots {"name": ...}.
+const (
+	RevisionRowCreated    = "row_created"
+	RevisionRowUpdated    = "row_updated"
+	RevisionRowDeleted    = "row_deleted"
+	RevisionRowRestored   = "row_restored"
+	RevisionColumnCreated = "column_created"
+	RevisionColumnUpdated = "column_updated"
+	RevisionColumnDeleted = "column_deleted"
+	RevisionTableRenamed  = "table_renamed"
+)
+
+const maxRevisionsPerPage = 200
+
+// Actor is who made a change, recorded with every revision: a user ID or
+// "agent:<slug>", and for agents the thread and schedule run it happened in.
+type Actor struct {
+	Actor       string `json:"actor"`
+	ThreadID    string `json:"thread_id,omitempty"`
+	ScheduleID  string `json:"schedule_id,omitempty"`
+	ExecutionID string `json:"execution_id,omitempty"`
+}
+
+// As returns a copy of the store that records a as the author of the changes
+// made through it. A store nobody called As on records "system".
+func (s *Store) As(a Actor) *Store {
+	c := *s
+	c.actor = a
+	return &c
+}
+
+// Revision is one entry in a table's append-only history. Before is nil for a
+// row or column that didn't exist yet, After is nil once it's gone.
+type Revision struct {
+	ID       string                 `json:"id"`
+	TableID  string                 `json:"table_id"`
+	RowID    string                 `json:"row_id,omitempty"`
+	ColumnID string                 `json:"column_id,omitempty"`
+	Kind     string                 `json:"kind"`
+	Before   map[string]interface{} `json:"before"`
+	After    map[string]interface{} `json:"after"`
+	Actor
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldChange is one value that differs between two snapshots. Key is a column
+// ID for row revisions and a field name for schema revisions.
+type FieldChange struct {
+	Key    string      `json:"key"`
+	Column string      `json:"column,omitempty"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type RevisionDiff struct {
+	From    *Revision     `json:"from,omitempty"`
+	To      Revision      `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// TableRestore counts what RestoreTable changed.
+type TableRestore struct {
+	At        time.Time `json:"at"`
+	Updated   int       `json:"updated"`
+	Recreated int       `json:"recreated"`
+	Deleted   int       `json:"deleted"`
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// record appends a revision. Callers pass the transaction that made the change
+// so the two land together.
+func (s *Store) record(x execer, tableID, rowID, columnID, kind string, before, after interface{}) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	actor := s.actor.Actor
+	if actor == "" {
+		actor = "system"
+	}
+	_, err = x.Exec(`
+		INSERT INTO user_database_revisions
+		    (id, table_id, row_id, column_id, kind, before_data, after_data, actor, thread_id, schedule_id, execution_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), tableID, rowID, columnID, kind, string(beforeJSON), string(afterJSON),
+		actor, s.actor.ThreadID, s.actor.ScheduleID, s.actor.ExecutionID, time.Now().UTC())
+	return err
+}
+
+// RowHistory lists a row's revisions, newest first. It works for deleted rows
+// too, as long as their table still exists.
+func (s *Store) RowHistory(workspaceID, rowID string, limit, offset int) ([]Revision, error) {
+	return s.listRevisions(workspaceID, "v.row_id = ?", []interface{}{rowID}, limit, offset)
+}
+
+// TableHistory lists every row and schema revision of a table, newest first.
+func (s *Store) TableHistory(workspaceID, tableID string, limit, offset int) ([]Revision, error) {
+	if err := s.assertTable(workspaceID, tableID); err != nil {
+		return nil, err
+	}
+	return s.listRevisions(workspaceID, "v.table_id = ?", []interface{}{tableID}, limit, offset)
+}
+
+func (s *Store) GetRevision(workspaceID, id string) (Revision, error) {
+	revisions, err := s.listRevisions(workspaceID, "v.id = ?", []interface{}{id}, 1, 0)
+	if err != nil {
+		return Revision{}, err
+	}
+	if len(revisions) == 0 {
+		return Revision{}, sql.ErrNoRows
+	}
+	return revisions[0], nil
+}
+
+func (s *Store) listRevisions(workspaceID, where string, args []interface{}, limit, offset int) ([]Revision, error) {
+	if limit <= 0 || limit > maxRevisionsPerPage {
+		limit = maxRevisionsPerPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	args = append([]interface{}{workspaceID}, args...)
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(`
+		SELECT v.id, v.table_id, v.row_id, v.column_id, v.kind, v.before_data, v.after_data,
+		       v.actor, v.thread_id, v.schedule_id, v.execution_id, v.created_at
+		  FROM user_database_revisions v
+		  JOIN user_database_tables t ON t.id = v.table_id
+		  JOIN user_databases d ON d.id = t.database_id
+		 WHERE d.workspace_id = ? AND `+where+`
+		 ORDER BY v.created_at DESC, v.rowid DESC
+		 LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []Revision{}
+	for rows.Next() {
+		var v Revision
+		var before, after string
+		if err := rows.Scan(&v.ID, &v.TableID, &v.RowID, &v.ColumnID, &v.Kind, &before, &after,
+			&v.Actor.Actor, &v.ThreadID, &v.ScheduleID, &v.ExecutionID, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(before), &v.Before)
+		_ = json.Unmarshal([]byte(after), &v.After)
+		revisions = append(revisions, v)
+	}
+	return revisions, rows.Err()
+}
+
+// DiffRevisions lists what changed between two revisions of the same row or
+// column, or — with fromID empty — what the revision toID itself changed.
+func (s *Store) DiffRevisions(workspaceID, toID, fromID string) (RevisionDiff, error) {
+	to, err := s.GetRevision(workspaceID, toID)
+	if err != nil {
+		return RevisionDiff{}, err
+	}
+	diff := RevisionDiff{To: to}
+	before := to.Before
+	if fromID != "" {
+		from, err := s.GetRevision(workspaceID, fromID)
+		if err != nil {
+			return RevisionDiff{}, err
+		}
+		if from.TableID != to.TableID || from.RowID != to.RowID || from.ColumnID != to.ColumnID {
+			return RevisionDiff{}, errors.New("invalid revision: both revisions must belong to the same row or column")
+		}
+		diff.From = &from
+		before = from.After
+	}
+
+	names := map[string]string{}
+	if to.RowID != "" {
+		columns, _ := s.ListColumns(workspaceID, to.TableID)
+		for _, c := range columns {
+			names[c.ID] = c.Name
+		}
+	}
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range to.After {
+		keys[key] = true
+	}
+	diff.Changes = []FieldChange{}
+	for key := range keys {
+		if to.RowID == "" && (key == "created_at" || key == "updated_at") {
+			continue
+		}
+		if !reflect.DeepEqual(before[key], to.After[key]) {
+			diff.Changes = append(diff.Changes, FieldChange{Key: key, Column: names[key], Before: before[key], After: to.After[key]})
+		}
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool { return diff.Changes[i].Key < diff.Changes[j].Key })
+	return diff, nil
+}
+
+// RestoreRow puts a row back the way a revision left it: re-creating it if it
+// has since been deleted, or deleting it if the revision did. Values for
+// columns that no longer exist, and links to rows that no longer exist, are
+// dropped. The restore is itself a revision. It returns the row as restored,
+// or nil when the restored state is "deleted".
+func (s *Store) RestoreRow(workspaceID, revisionID string) (*Row, error) {
+	rev, err := s.GetRevision(workspaceID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.RowID == "" {
+		return nil, errors.New("invalid revision: only row revisions can be restored")
+	}
+	columns, err := s.ListColumns(workspaceID, rev.TableID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.restoreRow(workspaceID, rev.TableID, rev.RowID, columns, rev.After); err != nil {
+		return nil, err
+	}
+	t, _ := s.GetTable(workspaceID, rev.TableID)
+	s.touchTable(t)
+	row, err := s.getRow(workspaceID, rev.RowID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &row, err
+}
+
+// RestoreTable puts every row with recorded history back the way it was at a
+// point in time: rows created since are deleted, rows deleted since come
+// back, and edited rows get their old values. Rows with no revisions — those
+// older than history itself and untouched since — are left alone, as is the
+// schema.
+func (s *Store) RestoreTable(workspaceID, tableID string, at time.Time) (TableRestore, error) {
+	result := TableRestore{At: at}
+	if err := s.assertTable(workspaceID, tableID); err != nil {
+		return result, err
+	}
+	rows, err := s.db.Query(`
+		SELECT row_id, before_data, after_data, created_at FROM user_database_revisions
+		 WHERE table_id = ? AND row_id != ''
+		 ORDER BY created_at, rowid`, tableID)
+	if err != nil {
+		return result, err
+	}
+	// A row's state at `at` is what its last revision up to then left, or —
+	// if every revision came later — what the first one found.
+	states := map[string]map[string]interface{}{}
+	final := map[string]bool{}
+	for rows.Next() {
+		var rowID, before, after string
+		var createdAt time.Time
+		if err := rows.Scan(&rowID, &before, &after, &createdAt); err != nil {
+			rows.Close()
+			return result, err
+		}
+		if final[rowID] {
+			continue
+		}
+		var state map[string]interface{}
+		if createdAt.After(at) {
+			_ = json.Unmarshal([]byte(before), &state)
+			final[rowID] = true
+		} else {
+			_ = json.Unmarshal([]byte(after), &state)
+		}
+		states[rowID] = state
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	columns, err := s.ListColumns(workspaceID, tableID)
+	if err != nil {
+		return result, err
+	}
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		outcome, err := s.restoreRow(workspaceID, tableID, id, columns, states[id])
+		if err != nil {
+			return result, fmt.Errorf("restore row %s: %w", id, err)
+		}
+		switch outcome {
+		case "updated":
+			result.Updated++
+		case "recreated":
+			result.Recreated++
+		case "deleted":
+			result.Deleted++
+		}
+	}
+	t, _ := s.GetTable(workspaceID, tableID)
+	s.touchTable(t)
+	return result, nil
+}
+
+// restoreRow makes a row match state (nil meaning absent), recording a
+// row_restored revision if anything changed. It reports "updated",
+// "recreated", "deleted" or "" for no change.
+func (s *Store) restoreRow(workspaceID, tableID, rowID string, columns []Column, state map[string]interface{}) (string, error) {
+	current, err := s.getRow(workspaceID, rowID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if exists && current.TableID != tableID {
+		return "", sql.ErrNoRows
+	}
+
+	if state == nil {
+		if !exists {
+			return "", nil
+		}
+		if err := s.deleteRow(current, RevisionRowRestored); err != nil {
+			return "", err
+		}
+		return "deleted", nil
+	}
+
+	values := s.restorableValues(columns, state)
+	if exists && reflect.DeepEqual(values, current.Values) {
+		return "", nil
+	}
+	raw, _ := json.Marshal(values)
+	now := time.Now().UTC()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	outcome := "updated"
+	var before map[string]interface{}
+	if exists {
+		before = current.Values
+		_, err = tx.Exec("UPDATE user_database_rows SET data = ?, updated_at = ? WHERE id = ?", string(raw), now, rowID)
+	} else {
+		outcome = "recreated"
+		var sortOrder int
+		_ = tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) + 1 FROM user_database_rows WHERE table_id = ?", tableID).Scan(&sortOrder)
+		_, err = tx.Exec(
+			`INSERT INTO user_database_rows (id, table_id, data, sort_order, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			rowID, tableID, string(raw), sortOrder, now, now)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := s.record(tx, tableID, rowID, "", RevisionRowRestored, before, values); err != nil {
+		return "", err
+	}
+	return outcome, tx.Commit()
+}
+
+// restorableValues keeps the parts of an old snapshot that can still be
+// written: stored columns that exist, and links to rows that exist.
+func (s *Store) restorableValues(columns []Column, state map[string]interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, column := range columns {
+		value, ok := state[column.ID]
+		if !ok || value == nil || isComputed(column.Type) {
+			continue
+		}
+		if column.Type == "link" {
+			kept := []interface{}{}
+			for _, id := range linkIDs(value) {
+				var found string
+				if s.db.QueryRow("SELECT id FROM user_database_rows WHERE id = ? AND table_id = ?", id, optionString(column.Options, "table_id")).Scan(&found) == nil {
+					kept = append(kept, id)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			value = kept
+		}
+		values[column.ID] = value
+	}
+	return values
+}
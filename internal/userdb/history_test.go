@@ -0,0 +1,97 @@
+package userdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRowHistoryDiffAndRestore(t *testing.T) {
+	store, db := newTestStore(t)
+	workspaceID := createWorkspace(t, db, "History")
+	databaseItem, err := store.CreateDatabase(workspaceID, "Leads", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := databaseItem.Tables[0]
+	name := table.Columns[0].ID
+	status, err := store.CreateColumn(workspaceID, table.ID, "Status", "text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := store.As(Actor{Actor: "user-1"})
+	agent := store.As(Actor{Actor: "agent:scout", ScheduleID: "nightly", ExecutionID: "exec-1"})
+	acme, err := user.CreateRow(workspaceID, table.ID, map[string]interface{}{name: "Acme", status.ID: "Open"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	globex, err := user.CreateRow(workspaceID, table.ID, map[string]interface{}{name: "Globex", status.ID: "Open"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	beforeRun := time.Now().UTC()
+	time.Sleep(5 * time.Millisecond)
+
+	// A scheduled run goes wrong: closes one lead, deletes another, adds junk.
+	if _, err := agent.UpdateRow(workspaceID, acme.ID, map[string]interface{}{status.ID: "Closed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.DeleteRow(workspaceID, globex.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.CreateRow(workspaceID, table.ID, map[string]interface{}{name: "Junk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := store.RowHistory(workspaceID, acme.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Kind != RevisionRowUpdated || history[1].Kind != RevisionRowCreated {
+		t.Fatalf("acme history = %+v", history)
+	}
+	if history[0].Actor.Actor != "agent:scout" || history[0].ExecutionID != "exec-1" || history[1].Actor.Actor != "user-1" {
+		t.Errorf("attribution = %+v / %+v", history[0].Actor, history[1].Actor)
+	}
+
+	diff, err := store.DiffRevisions(workspaceID, history[0].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].Column != "Status" || diff.Changes[0].Before != "Open" || diff.Changes[0].After != "Closed" {
+		t.Errorf("diff = %+v", diff.Changes)
+	}
+	schema, _ := store.TableHistory(workspaceID, table.ID, 0, 0)
+	if _, err := store.DiffRevisions(workspaceID, history[0].ID, schema[len(schema)-1].ID); err == nil {
+		t.Error("diffing a row revision against a column revision was accepted")
+	}
+
+	// Restoring the deleted row's last revision brings it back under its ID.
+	globexHistory, _ := store.RowHistory(workspaceID, globex.ID, 0, 0)
+	restored, err := user.RestoreRow(workspaceID, globexHistory[1].ID)
+	if err != nil || restored == nil || restored.ID != globex.ID || restored.Values[name] != "Globex" {
+		t.Fatalf("restore deleted row = %+v, %v", restored, err)
+	}
+
+	result, err := user.RestoreTable(workspaceID, table.ID, beforeRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 || result.Deleted != 1 || result.Recreated != 0 {
+		t.Errorf("table restore = %+v", result)
+	}
+	named, _ := store.NamedRows(workspaceID, table.ID, "", 10, 0)
+	if len(named.Records) != 2 {
+		t.Fatalf("rows after restore = %v", named.Records)
+	}
+	for _, record := range named.Records {
+		if record["Status"] != "Open" {
+			t.Errorf("%v still %v after restore", record["Name"], record["Status"])
+		}
+	}
+	latest, _ := store.RowHistory(workspaceID, acme.ID, 1, 0)
+	if latest[0].Kind != RevisionRowRestored || latest[0].Actor.Actor != "user-1" {
+		t.Errorf("restore revision = %+v", latest[0])
+	}
+}
@@ -0,0 +1,601 @@
+package userdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Linked records and computed columns.
+//
+// A link column stores a list of row IDs from another table in the same
+// database. Lookup and rollup columns read through a link column: a lookup
+// lists one column of the linked rows, a rollup aggregates it. Formula
+// columns compute a value from the row's other columns. Lookup, rollup and
+// formula values are never stored; they are computed whenever rows are read.
+//
+// Options by type:
+//
+//	link:    {"table_id": "<table in the same database>"}
+//	lookup:  {"link_column_id": "...", "target_column_id": "..."}
+//	rollup:  {"link_column_id": "...", "target_column_id": "...", "function": "sum"}
+//	formula: {"expression": "{Price} * {Qty}"}
+
+var rollupFunctions = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true, "concat": true,
+}
+
+// isComputed reports whether a column type's values are derived rather than
+// stored.
+func isComputed(columnType string) bool {
+	return columnType == "lookup" || columnType == "rollup" || columnType == "formula"
+}
+
+func optionString(options map[string]interface{}, key string) string {
+	s, _ := options[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// normalizeColumnOptions checks a column's options against its type and
+// returns them cleaned. Plain types keep their options as given.
+func (s *Store) normalizeColumnOptions(workspaceID, tableID, columnID, columnType string, options map[string]interface{}) (map[string]interface{}, error) {
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	switch columnType {
+	case "link":
+		targetID := optionString(options, "table_id")
+		if targetID == "" {
+			return nil, errors.New("link columns require options.table_id")
+		}
+		var sameDatabase int
+		err := s.db.QueryRow(`
+			SELECT COUNT(*) FROM user_database_tables a
+			  JOIN user_database_tables b ON b.database_id = a.database_id
+			 WHERE a.id = ? AND b.id = ?`, tableID, targetID).Scan(&sameDatabase)
+		if err != nil || sameDatabase == 0 {
+			return nil, fmt.Errorf("link table %q must be a table in the same database", targetID)
+		}
+		return map[string]interface{}{"table_id": targetID}, nil
+
+	case "lookup", "rollup":
+		link, err := s.getColumn(workspaceID, optionString(options, "link_column_id"))
+		if err != nil || link.TableID != tableID || link.Type != "link" {
+			return nil, errors.New(columnType + " columns require options.link_column_id naming a link column in this table")
+		}
+		out := map[string]interface{}{"link_column_id": link.ID}
+		function := strings.ToLower(optionString(options, "function"))
+		targetRef := optionString(options, "target_column_id")
+		if columnType == "rollup" {
+			if !rollupFunctions[function] {
+				return nil, errors.New("rollup columns require options.function: count, sum, avg, min, max or concat")
+			}
+			out["function"] = function
+			if function == "count" && targetRef == "" {
+				return out, nil
+			}
+		}
+		target, err := s.getColumn(workspaceID, targetRef)
+		if err != nil || target.TableID != optionString(link.Options, "table_id") {
+			return nil, errors.New(columnType + " columns require options.target_column_id naming a column in the linked table")
+		}
+		if target.Type == "lookup" || target.Type == "rollup" {
+			return nil, errors.New("a " + columnType + " can't read another lookup or rollup")
+		}
+		if (function == "sum" || function == "avg") && target.Type != "number" && target.Type != "checkbox" && target.Type != "formula" {
+			return nil, fmt.Errorf("a %s rollup needs a number column, %q is %s", function, target.Name, target.Type)
+		}
+		out["target_column_id"] = target.ID
+		return out, nil
+
+	case "formula":
+		expression := optionString(options, "expression")
+		formula, err := ParseFormula(expression)
+		if err != nil {
+			return nil, err
+		}
+		columns, err := s.ListColumns(workspaceID, tableID)
+		if err != nil {
+			return nil, err
+		}
+		byName := map[string]Column{}
+		for _, c := range columns {
+			byName[strings.ToLower(c.Name)] = c
+		}
+		for _, ref := range formula.References() {
+			c, ok := byName[strings.ToLower(ref)]
+			if !ok {
+				return nil, fmt.Errorf("unknown column {%s} in formula", ref)
+			}
+			if c.ID == columnID {
+				return nil, errors.New("a formula can't refer to itself")
+			}
+		}
+		return map[string]interface{}{"expression": expression}, nil
+	}
+	return options, nil
+}
+
+// linkValue validates a value written to a link column: a row ID or a list
+// of them, all from the linked table. It returns the stored form.
+func (s *Store) linkValue(column Column, value interface{}) (interface{}, error) {
+	var ids []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) != "" {
+			ids = append(ids, strings.TrimSpace(v))
+		}
+	case []interface{}:
+		for _, item := range v {
+			id, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value: column %q links rows by ID; %v is not a row ID", column.Name, item)
+			}
+			ids = append(ids, strings.TrimSpace(id))
+		}
+	case []string:
+		ids = append(ids, v...)
+	default:
+		return nil, fmt.Errorf("invalid value: column %q links rows by ID; use a row ID or a list of them", column.Name)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	targetTable := optionString(column.Options, "table_id")
+	out := []interface{}{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var found string
+		if err := s.db.QueryRow("SELECT id FROM user_database_rows WHERE id = ? AND table_id = ?", id, targetTable).Scan(&found); err != nil {
+			return nil, fmt.Errorf("invalid value: column %q: row %q is not in the linked table", column.Name, id)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+func linkIDs(value interface{}) []string {
+	list, _ := value.([]interface{})
+	ids := make([]string, 0, len(list))
+	for _, item := range list {
+		if id, ok := item.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// computeValues fills in lookup, rollup and formula values on rows that were
+// just read. Errors in one formula become that cell's value rather than
+// failing the whole read.
+func (s *Store) computeValues(workspaceID string, columns []Column, rows []Row) error {
+	var computed bool
+	for _, c := range columns {
+		computed = computed || isComputed(c.Type)
+	}
+	if !computed || len(rows) == 0 {
+		return nil
+	}
+
+	// Load every row any lookup or rollup reaches, once per linked table.
+	wanted := map[string][]string{} // table -> row IDs
+	for _, c := range columns {
+		if c.Type != "lookup" && c.Type != "rollup" {
+			continue
+		}
+		link := findColumn(columns, optionString(c.Options, "link_column_id"))
+		if link == nil {
+			continue
+		}
+		target := optionString(link.Options, "table_id")
+		for _, row := range rows {
+			wanted[target] = append(wanted[target], linkIDs(row.Values[link.ID])...)
+		}
+	}
+	linked := map[string]map[string]map[string]interface{}{} // table -> row -> values
+	for target, ids := range wanted {
+		values, err := s.loadRowValues(workspaceID, target, ids)
+		if err != nil {
+			return err
+		}
+		cols, err := s.ListColumns(workspaceID, target)
+		if err != nil {
+			return err
+		}
+		// Linked rows' own formulas, so a lookup can show them. Their lookups
+		// and rollups are not followed further.
+		for _, v := range values {
+			applyFormulas(cols, v)
+		}
+		linked[target] = values
+	}
+
+	for i := range rows {
+		values := rows[i].Values
+		for _, c := range columns {
+			if c.Type != "lookup" && c.Type != "rollup" {
+				continue
+			}
+			link := findColumn(columns, optionString(c.Options, "link_column_id"))
+			if link == nil {
+				values[c.ID] = nil
+				continue
+			}
+			source := linked[optionString(link.Options, "table_id")]
+			targetID := optionString(c.Options, "target_column_id")
+			var items []interface{}
+			count := 0
+			for _, id := range linkIDs(values[link.ID]) {
+				linkedRow, ok := source[id]
+				if !ok {
+					continue
+				}
+				count++
+				if v, ok := linkedRow[targetID]; ok && !formulaBlank(v) {
+					items = append(items, v)
+				}
+			}
+			if c.Type == "lookup" {
+				if items == nil {
+					items = []interface{}{}
+				}
+				values[c.ID] = items
+				continue
+			}
+			values[c.ID] = rollup(optionString(c.Options, "function"), items, count, targetID == "")
+		}
+		applyFormulas(columns, values)
+	}
+	return nil
+}
+
+// loadRowValues reads the stored values of the given rows of one table.
+func (s *Store) loadRowValues(workspaceID, tableID string, ids []string) (map[string]map[string]interface{}, error) {
+	out := map[string]map[string]interface{}{}
+	if err := s.assertTable(workspaceID, tableID); err != nil {
+		return out, nil
+	}
+	unique := map[string]bool{}
+	var list []interface{}
+	for _, id := range ids {
+		if !unique[id] {
+			unique[id] = true
+			list = append(list, id)
+		}
+	}
+	for start := 0; start < len(list); start += maxRowsPerQuery {
+		end := start + maxRowsPerQuery
+		if end > len(list) {
+			end = len(list)
+		}
+		chunk := list[start:end]
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+		rows, err := s.db.Query(
+			"SELECT id, data FROM user_database_rows WHERE table_id = ? AND id IN ("+marks+")",
+			append([]interface{}{tableID}, chunk...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, raw string
+			if err := rows.Scan(&id, &raw); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			values := map[string]interface{}{}
+			_ = json.Unmarshal([]byte(raw), &values)
+			out[id] = values
+		}
+		rows.Close()
+	}
+	return out, nil
+}
+
+func rollup(function string, items []interface{}, linkedRows int, countRows bool) interface{} {
+	switch function {
+	case "count":
+		if countRows {
+			return float64(linkedRows)
+		}
+		return float64(len(formulaFlatten(items)))
+	case "concat":
+		return formulaText(formulaFlatten(items))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	fn := map[string]string{"sum": "SUM", "avg": "AVG", "min": "MIN", "max": "MAX"}[function]
+	if fn == "" {
+		return nil
+	}
+	value, err := formulaFunctions[fn].call(items)
+	if err != nil {
+		return "#ERROR: " + err.Error()
+	}
+	return value
+}
+
+// applyFormulas evaluates a row's formula columns in place. A formula can read
+// another formula; a cycle is reported in the cell instead of recursing.
+func applyFormulas(columns []Column, values map[string]interface{}) {
+	byName := map[string]Column{}
+	var formulas []Column
+	for _, c := range columns {
+		byName[strings.ToLower(c.Name)] = c
+		if c.Type == "formula" {
+			formulas = append(formulas, c)
+		}
+	}
+	if len(formulas) == 0 {
+		return
+	}
+
+	done := map[string]bool{}
+	active := map[string]bool{}
+	var evaluate func(c Column) (interface{}, error)
+	evaluate = func(c Column) (interface{}, error) {
+		if done[c.ID] {
+			return values[c.ID], nil
+		}
+		if active[c.ID] {
+			return nil, errors.New("formula refers to itself")
+		}
+		active[c.ID] = true
+		defer delete(active, c.ID)
+
+		formula, err := parseFormulaCached(optionString(c.Options, "expression"))
+		if err != nil {
+			return nil, err
+		}
+		value, err := formula.Eval(func(name string) (interface{}, error) {
+			ref, ok := byName[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown column {%s}", name)
+			}
+			if ref.Type == "formula" {
+				return evaluate(ref)
+			}
+			return values[ref.ID], nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		done[c.ID] = true
+		values[c.ID] = value
+		return value, nil
+	}
+	for _, c := range formulas {
+		if _, err := evaluate(c); err != nil {
+			values[c.ID] = "#ERROR: " + err.Error()
+			done[c.ID] = true
+		}
+	}
+}
+
+// formulaCache holds parsed formulas by expression, since the same few are
+// evaluated for every row read.
+var formulaCache sync.Map
+
+func parseFormulaCached(expression string) (*Formula, error) {
+	if cached, ok := formulaCache.Load(expression); ok {
+		return cached.(*Formula), nil
+	}
+	formula, err := ParseFormula(expression)
+	if err != nil {
+		return nil, err
+	}
+	formulaCache.Store(expression, formula)
+	return formula, nil
+}
+
+func findColumn(columns []Column, id string) *Column {
+	for i := range columns {
+		if columns[i].ID == id {
+			return &columns[i]
+		}
+	}
+	return nil
+}
+
+// dependentColumns lists the columns that stop making sense without the
+// given one: lookups and rollups reading through or from it, and, for a
+// table, link columns pointing at it.
+func (s *Store) dependentColumns(columnID, tableID string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM user_database_columns
+		 WHERE (type IN ('lookup', 'rollup')
+		        AND ? <> '' AND (json_extract(options, '$.link_column_id') = ? OR json_extract(options, '$.target_column_id') = ?))
+		    OR (type = 'link' AND ? <> '' AND json_extract(options, '$.table_id') = ? AND table_id <> ?)`,
+		columnID, columnID, columnID, tableID, tableID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// unlinkRow removes a deleted row's ID from every link column that pointed
+// at its table.
+func (s *Store) unlinkRow(tableID, rowID string) error {
+	rows, err := s.db.Query(
+		"SELECT id, table_id FROM user_database_columns WHERE type = 'link' AND json_extract(options, '$.table_id') = ?", tableID)
+	if err != nil {
+		return err
+	}
+	type linkColumn struct{ id, tableID string }
+	var links []linkColumn
+	for rows.Next() {
+		var l linkColumn
+		if err := rows.Scan(&l.id, &l.tableID); err != nil {
+			rows.Close()
+			return err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+
+	for _, l := range links {
+		if err := s.rewriteRows(l.tableID, "%"+escapeLike(rowID)+"%", RevisionRowUpdated, func(values map[string]interface{}) bool {
+			ids := linkIDs(values[l.id])
+			kept := []interface{}{}
+			for _, id := range ids {
+				if id != rowID {
+					kept = append(kept, id)
+				}
+			}
+			if len(kept) == len(ids) {
+				return false
+			}
+			if len(kept) == 0 {
+				delete(values, l.id)
+			} else {
+				values[l.id] = kept
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteRows applies change to each row of a table whose data matches the
+// LIKE pattern, saving the rows it reports as changed. Each saved row gets a
+// revision of the given kind; "" records none, for changes a schema revision
+// already explains.
+func (s *Store) rewriteRows(tableID, pattern, kind string, change func(values map[string]interface{}) bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query("SELECT id, data FROM user_database_rows WHERE table_id = ? AND data LIKE ? ESCAPE '\\'", tableID, pattern)
+	if err != nil {
+		return err
+	}
+	updates := map[string]string{}
+	befores := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		values := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(raw), &values)
+		if change(values) {
+			encoded, _ := json.Marshal(values)
+			updates[id] = string(encoded)
+			before := map[string]interface{}{}
+			_ = json.Unmarshal([]byte(raw), &before)
+			befores[id] = before
+		}
+	}
+	rows.Close()
+	ids := make([]string, 0, len(updates))
+	for id := range updates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE user_database_rows SET data = ?, updated_at = ? WHERE id = ?", updates[id], now, id); err != nil {
+			return err
+		}
+		if kind != "" {
+			var after map[string]interface{}
+			_ = json.Unmarshal([]byte(updates[id]), &after)
+			if err := s.record(tx, tableID, id, "", kind, befores[id], after); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// renameFormulaReferences keeps formulas pointing at a renamed column.
+func (s *Store) renameFormulaReferences(tableID, oldName, newName string) error {
+	if strings.EqualFold(oldName, newName) {
+		return nil
+	}
+	rows, err := s.db.Query("SELECT id, options FROM user_database_columns WHERE table_id = ? AND type = 'formula'", tableID)
+	if err != nil {
+		return err
+	}
+	updates := map[string]string{}
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		options := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(raw), &options)
+		expression := optionString(options, "expression")
+		rewritten := replaceFormulaRef(expression, oldName, newName)
+		if rewritten != expression {
+			options["expression"] = rewritten
+			encoded, _ := json.Marshal(options)
+			updates[id] = string(encoded)
+		}
+	}
+	rows.Close()
+	for id, options := range updates {
+		if _, err := s.db.Exec("UPDATE user_database_columns SET options = ? WHERE id = ?", options, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceFormulaRef rewrites {old} references (case-insensitive, ignoring
+// padding inside the braces) to {new}.
+func replaceFormulaRef(expression, oldName, newName string) string {
+	var sb strings.Builder
+	rest := expression
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			break
+		}
+		closing := strings.IndexByte(rest[open:], '}')
+		if closing < 0 {
+			break
+		}
+		ref := rest[open+1 : open+closing]
+		sb.WriteString(rest[:open])
+		if strings.EqualFold(strings.TrimSpace(ref), oldName) {
+			sb.WriteString("{" + newName + "}")
+		} else {
+			sb.WriteString(rest[open : open+closing+1])
+		}
+		rest = rest[open+closing+1:]
+	}
+	sb.WriteString(rest)
+	return sb.String()
+}
+
+// errComputedColumn is returned when a write or query targets a computed
+// column.
+func errComputedColumn(c Column) error {
+	return fmt.Errorf("column %q is computed (%s); it can't be set, filtered, sorted or grouped", c.Name, c.Type)
+}
@@ -0,0 +1,144 @@
+package userdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinksLookupsRollupsAndFormulas(t *testing.T) {
+	store, db := newTestStore(t)
+	workspaceID := createWorkspace(t, db, "CRM")
+	databaseItem, err := store.CreateDatabase(workspaceID, "CRM", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	companies := databaseItem.Tables[0]
+	deals, err := store.CreateTable(workspaceID, databaseItem.ID, "Deals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := func(tableID, name, columnType string, options map[string]interface{}) Column {
+		t.Helper()
+		c, err := store.CreateColumn(workspaceID, tableID, name, columnType, options)
+		if err != nil {
+			t.Fatalf("create %s column %s: %v", columnType, name, err)
+		}
+		return c
+	}
+	companyName := companies.Columns[0]
+	dealName := deals.Columns[0]
+	value := column(deals.ID, "Value", "number", nil)
+	dealsLink := column(companies.ID, "Deals", "link", map[string]interface{}{"table_id": deals.ID})
+	dealNames := column(companies.ID, "Deal names", "lookup", map[string]interface{}{"link_column_id": dealsLink.ID, "target_column_id": dealName.ID})
+	pipeline := column(companies.ID, "Pipeline", "rollup", map[string]interface{}{"link_column_id": dealsLink.ID, "target_column_id": value.ID, "function": "sum"})
+	dealCount := column(companies.ID, "Deal count", "rollup", map[string]interface{}{"link_column_id": dealsLink.ID, "function": "count"})
+	column(companies.ID, "Summary", "formula", map[string]interface{}{"expression": `UPPER({Name}) & ": " & {Deal count} & IF({Pipeline} > 1000, " big", " small")`})
+
+	var dealIDs []interface{}
+	for _, d := range []struct {
+		name  string
+		value float64
+	}{{"Renewal", 900}, {"Expansion", 400}} {
+		row, err := store.CreateRow(workspaceID, deals.ID, map[string]interface{}{dealName.ID: d.name, value.ID: d.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dealIDs = append(dealIDs, row.ID)
+	}
+	if _, err := store.CreateRow(workspaceID, companies.ID, map[string]interface{}{
+		companyName.ID: "Acme",
+		dealsLink.ID:   dealIDs,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	named, err := store.NamedRows(workspaceID, companies.ID, "", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme := named.Records[0]
+	if got := acme["Deal names"].([]interface{}); len(got) != 2 || got[0] != "Renewal" {
+		t.Errorf("lookup = %v", acme["Deal names"])
+	}
+	if acme["Pipeline"] != float64(1300) || acme["Deal count"] != float64(2) {
+		t.Errorf("rollups = %v / %v, want 1300 and 2", acme["Pipeline"], acme["Deal count"])
+	}
+	if acme["Summary"] != "ACME: 2 big" {
+		t.Errorf("formula = %v", acme["Summary"])
+	}
+
+	// Computed columns can't be written, and links only take rows of the
+	// linked table.
+	if _, err := store.CreateRow(workspaceID, companies.ID, map[string]interface{}{pipeline.ID: 5}); err == nil {
+		t.Error("writing a rollup was accepted")
+	}
+	if _, err := store.CreateRow(workspaceID, companies.ID, map[string]interface{}{dealsLink.ID: []interface{}{"not-a-row"}}); err == nil {
+		t.Error("linking an unknown row was accepted")
+	}
+
+	// Deleting a linked row takes it out of the link.
+	if err := store.DeleteRow(workspaceID, dealIDs[0].(string)); err != nil {
+		t.Fatal(err)
+	}
+	named, _ = store.NamedRows(workspaceID, companies.ID, "", 10, 0)
+	if links := named.Records[0]["Deals"].([]interface{}); len(links) != 1 || named.Records[0]["Pipeline"] != float64(400) {
+		t.Errorf("after delete: links %v, pipeline %v", links, named.Records[0]["Pipeline"])
+	}
+
+	// Renaming a column keeps formulas pointing at it; deleting the link
+	// removes the lookups and rollups that read through it.
+	newName := "Company"
+	if _, err := store.UpdateColumn(workspaceID, companyName.ID, &newName, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteColumn(workspaceID, dealsLink.ID); err != nil {
+		t.Fatal(err)
+	}
+	columns, _ := store.ListColumns(workspaceID, companies.ID)
+	for _, c := range columns {
+		if c.ID == dealNames.ID || c.ID == pipeline.ID || c.ID == dealCount.ID {
+			t.Errorf("%s survived deleting its link column", c.Name)
+		}
+		if c.Type == "formula" && !strings.Contains(c.Options["expression"].(string), "{Company}") {
+			t.Errorf("formula not renamed: %v", c.Options["expression"])
+		}
+	}
+}
+
+func TestFormulaParseAndEval(t *testing.T) {
+	row := map[string]interface{}{"price": 2.5, "qty": float64(4), "due": "2026-01-10", "note": ""}
+	env := func(name string) (interface{}, error) { return row[strings.ToLower(name)], nil }
+
+	cases := map[string]interface{}{
+		`{Price} * {Qty} + 1`:                   float64(11),
+		`-{Qty} / 2`:                            float64(-2),
+		`ROUND(10 / 3, 2)`:                      3.33,
+		`IF(BLANK({Note}), "empty", "set")`:     "empty",
+		`AND({Qty} >= 4, NOT({Price} = 3))`:     true,
+		`DAYS({Due}, "2026-01-01")`:             float64(9),
+		`CONCAT("a", 1, TRUE) & LEN("héllo")`:   "a1true5",
+		`MAX(1, {Qty}, 3) - MIN(7, {Price})`:    1.5,
+		`IF({Qty} > 10, "never evaluated" / 0)`: nil,
+	}
+	for expression, want := range cases {
+		f, err := ParseFormula(expression)
+		if err != nil {
+			t.Errorf("parse %s: %v", expression, err)
+			continue
+		}
+		got, err := f.Eval(env)
+		if err != nil || got != want {
+			t.Errorf("%s = %#v, %v; want %#v", expression, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{``, `1 +`, `{Price`, `EXEC("rm")`, `(1`, `ROUND()`} {
+		if _, err := ParseFormula(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+	f, _ := ParseFormula(`{Qty} / 0`)
+	if _, err := f.Eval(env); err == nil {
+		t.Error("division by zero was not an error")
+	}
+}
@@ -0,0 +1,646 @@
+package userdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on a structured query, so one request can't build an unbounded SQL
+// statement.
+const (
+	maxFilterDepth      = 4
+	maxFilterConditions = 50
+	maxSortColumns      = 5
+	maxGroupColumns     = 3
+	maxAggregates       = 10
+)
+
+// Query is a structured read of one table. Filtering, sorting and grouping
+// all run in SQLite over the row's JSON data, never in Go after loading.
+// Columns are referenced by ID or (case-insensitive) name.
+type Query struct {
+	Search     string      `json:"search,omitempty"`
+	Filter     *Filter     `json:"filter,omitempty"`
+	Sort       []Sort      `json:"sort,omitempty"`
+	GroupBy    []string    `json:"group_by,omitempty"`
+	Aggregates []Aggregate `json:"aggregates,omitempty"`
+	Limit      int         `json:"limit,omitempty"`
+	Offset     int         `json:"offset,omitempty"`
+}
+
+// Filter is either one condition (Column, Op, Value) or a group of filters
+// joined by And or Or.
+//
+// Ops: eq, neq, gt, gte, lt, lte, contains, not_contains, starts_with,
+// is_empty, not_empty, in, not_in, between, within_last, within_next.
+// Date values may be relative: "today", "yesterday", "tomorrow", "-7d",
+// "+30d". within_last / within_next take a number of days.
+type Filter struct {
+	Column string      `json:"column,omitempty"`
+	Op     string      `json:"op,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	And    []Filter    `json:"and,omitempty"`
+	Or     []Filter    `json:"or,omitempty"`
+}
+
+// Sort orders by one column. In a grouped query Column may also name a group
+// column or an aggregate's alias.
+type Sort struct {
+	Column    string `json:"column"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// Aggregate is count, sum, avg, min or max. Column is optional for count.
+type Aggregate struct {
+	Op     string `json:"op"`
+	Column string `json:"column,omitempty"`
+	As     string `json:"as,omitempty"`
+}
+
+// IsGrouped reports whether the query returns groups rather than rows.
+func (q Query) IsGrouped() bool {
+	return len(q.GroupBy) > 0 || len(q.Aggregates) > 0
+}
+
+func queryError(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid query: "+format, args...)
+}
+
+// columnIndex resolves column references for one table.
+type columnIndex map[string]Column
+
+func indexColumns(columns []Column) columnIndex {
+	index := columnIndex{}
+	for _, column := range columns {
+		index[column.ID] = column
+		index["name:"+strings.ToLower(column.Name)] = column
+	}
+	return index
+}
+
+// findStored resolves a column that a query filters, sorts or groups on.
+// Computed columns only exist after rows are read, so SQL can't see them.
+func (c columnIndex) findStored(ref string) (Column, error) {
+	column, ok := c.find(ref)
+	if !ok {
+		return Column{}, fmt.Errorf("unknown column %q", ref)
+	}
+	if isComputed(column.Type) {
+		return Column{}, queryError("%v", errComputedColumn(column))
+	}
+	return column, nil
+}
+
+func (c columnIndex) find(ref string) (Column, bool) {
+	ref = strings.TrimSpace(ref)
+	if column, ok := c[ref]; ok {
+		return column, true
+	}
+	column, ok := c["name:"+strings.ToLower(ref)]
+	return column, ok
+}
+
+func jsonPath(columnID string) string {
+	return `$."` + strings.ReplaceAll(columnID, `"`, `\"`) + `"`
+}
+
+// cellExpression is the typed SQL expression for a column's value. It holds
+// one placeholder, for the JSON path.
+func cellExpression(column Column) string {
+	switch column.Type {
+	case "number", "checkbox":
+		return "CAST(json_extract(data, ?) AS REAL)"
+	case "date":
+		return "substr(CAST(json_extract(data, ?) AS TEXT), 1, 10)"
+	default:
+		return "CAST(json_extract(data, ?) AS TEXT)"
+	}
+}
+
+// whereBuilder accumulates a WHERE clause and its arguments in order.
+type whereBuilder struct {
+	columns    columnIndex
+	now        time.Time
+	conditions int
+}
+
+func (b *whereBuilder) build(f Filter, depth int) (string, []interface{}, error) {
+	if depth > maxFilterDepth {
+		return "", nil, queryError("filters nest deeper than %d levels", maxFilterDepth)
+	}
+	if len(f.And) > 0 || len(f.Or) > 0 {
+		if f.Column != "" || (len(f.And) > 0 && len(f.Or) > 0) {
+			return "", nil, queryError("a filter is either a condition or one and/or group")
+		}
+		group, joiner := f.And, " AND "
+		if len(f.Or) > 0 {
+			group, joiner = f.Or, " OR "
+		}
+		parts := make([]string, 0, len(group))
+		var args []interface{}
+		for _, child := range group {
+			clause, childArgs, err := b.build(child, depth+1)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, clause)
+			args = append(args, childArgs...)
+		}
+		return "(" + strings.Join(parts, joiner) + ")", args, nil
+	}
+
+	b.conditions++
+	if b.conditions > maxFilterConditions {
+		return "", nil, queryError("more than %d filter conditions", maxFilterConditions)
+	}
+	column, err := b.columns.findStored(f.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	return b.condition(column, strings.ToLower(strings.TrimSpace(f.Op)), f.Value)
+}
+
+func (b *whereBuilder) condition(column Column, op string, value interface{}) (string, []interface{}, error) {
+	path := jsonPath(column.ID)
+	raw := "json_extract(data, ?)"
+	cell := cellExpression(column)
+	empty := "(" + raw + " IS NULL OR CAST(" + raw + " AS TEXT) IN ('', '[]'))"
+	text := column.Type != "number" && column.Type != "checkbox" && column.Type != "date"
+
+	switch op {
+	case "is_empty":
+		return empty, []interface{}{path, path}, nil
+	case "not_empty":
+		return "NOT " + empty, []interface{}{path, path}, nil
+	case "within_last", "within_next":
+		if column.Type != "date" {
+			return "", nil, queryError("%s only applies to date columns", op)
+		}
+		days, err := numberValue(value)
+		if err != nil || days < 0 {
+			return "", nil, queryError("%s needs a number of days", op)
+		}
+		today := b.now.Format("2006-01-02")
+		other := b.now.AddDate(0, 0, int(days)).Format("2006-01-02")
+		if op == "within_last" {
+			today, other = b.now.AddDate(0, 0, -int(days)).Format("2006-01-02"), today
+		}
+		return cell + " BETWEEN ? AND ?", []interface{}{path, today, other}, nil
+	case "contains", "not_contains", "starts_with":
+		needle := strings.ToLower(fmt.Sprint(value))
+		pattern := "%" + escapeLike(needle) + "%"
+		if op == "starts_with" {
+			pattern = escapeLike(needle) + "%"
+		}
+		like := "LOWER(CAST(" + raw + " AS TEXT)) LIKE ? ESCAPE '\\'"
+		if op == "not_contains" {
+			return "(" + raw + " IS NULL OR NOT " + like + ")", []interface{}{path, path, pattern}, nil
+		}
+		return like, []interface{}{path, pattern}, nil
+	case "in", "not_in":
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			return "", nil, queryError("%s needs a non-empty list", op)
+		}
+		args := []interface{}{path}
+		marks := make([]string, 0, len(list))
+		for _, item := range list {
+			v, err := b.operand(column, item)
+			if err != nil {
+				return "", nil, err
+			}
+			args = append(args, v)
+			marks = append(marks, "?")
+		}
+		expression := cell
+		if text {
+			expression = "LOWER(" + cell + ")"
+		}
+		clause := expression + " IN (" + strings.Join(marks, ", ") + ")"
+		if op == "not_in" {
+			return "(" + raw + " IS NULL OR NOT " + clause + ")", append([]interface{}{path}, args...), nil
+		}
+		return clause, args, nil
+	case "between":
+		list, ok := value.([]interface{})
+		if !ok || len(list) != 2 {
+			return "", nil, queryError("between needs a [low, high] pair")
+		}
+		low, err := b.operand(column, list[0])
+		if err != nil {
+			return "", nil, err
+		}
+		high, err := b.operand(column, list[1])
+		if err != nil {
+			return "", nil, err
+		}
+		if text {
+			return cell + " COLLATE NOCASE BETWEEN ? AND ?", []interface{}{path, low, high}, nil
+		}
+		return cell + " BETWEEN ? AND ?", []interface{}{path, low, high}, nil
+	}
+
+	operators := map[string]string{"eq": "=", "neq": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+	operator, ok := operators[op]
+	if !ok {
+		return "", nil, queryError("unknown filter op %q", op)
+	}
+	if column.Type == "checkbox" && op != "eq" && op != "neq" {
+		return "", nil, queryError("checkbox columns only support eq and neq")
+	}
+	operand, err := b.operand(column, value)
+	if err != nil {
+		return "", nil, err
+	}
+	expression := cell
+	if text && (op == "eq" || op == "neq") {
+		expression = "LOWER(" + cell + ")"
+	} else if text {
+		expression = cell + " COLLATE NOCASE"
+	}
+	if column.Type == "checkbox" {
+		// An unticked checkbox is usually just absent from the row.
+		expression = "COALESCE(" + cell + ", 0)"
+	}
+	clause := expression + " " + operator + " ?"
+	if op == "neq" && column.Type != "checkbox" {
+		return "(" + raw + " IS NULL OR " + clause + ")", []interface{}{path, path, operand}, nil
+	}
+	return clause, []interface{}{path, operand}, nil
+}
+
+// operand converts a filter value to what the column's cell expression
+// compares against.
+func (b *whereBuilder) operand(column Column, value interface{}) (interface{}, error) {
+	switch column.Type {
+	case "number":
+		n, err := numberValue(value)
+		if err != nil {
+			return nil, queryError("%q is a number column; %v is not a number", column.Name, value)
+		}
+		return n, nil
+	case "checkbox":
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			if parsed, err := strconv.ParseBool(v); err == nil {
+				return b.operand(column, parsed)
+			}
+		}
+		return nil, queryError("%q is a checkbox column; use true or false", column.Name)
+	case "date":
+		s, ok := value.(string)
+		if !ok {
+			return nil, queryError("%q is a date column; use YYYY-MM-DD or a relative date", column.Name)
+		}
+		return resolveDate(s, b.now)
+	default:
+		if value == nil {
+			return nil, queryError("a value is required for %q", column.Name)
+		}
+		return strings.ToLower(fmt.Sprint(value)), nil
+	}
+}
+
+func numberValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("not a number")
+}
+
+// resolveDate turns an absolute or relative date into YYYY-MM-DD.
+func resolveDate(value string, now time.Time) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "today":
+		return now.Format("2006-01-02"), nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1).Format("2006-01-02"), nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1).Format("2006-01-02"), nil
+	}
+	if strings.HasSuffix(value, "d") && (strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+")) {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil {
+			return now.AddDate(0, 0, days).Format("2006-01-02"), nil
+		}
+	}
+	if len(value) >= 10 {
+		if _, err := time.Parse("2006-01-02", value[:10]); err == nil {
+			return value[:10], nil
+		}
+	}
+	return "", queryError("%q is not a date (use YYYY-MM-DD, today, -7d or +30d)", value)
+}
+
+func sortDirection(direction string) (string, error) {
+	direction = strings.ToUpper(strings.TrimSpace(direction))
+	if direction == "" {
+		direction = "ASC"
+	}
+	if direction != "ASC" && direction != "DESC" {
+		return "", fmt.Errorf("invalid sort direction %q", direction)
+	}
+	return direction, nil
+}
+
+// whereClause builds the shared WHERE for a query: the table, the free-text
+// search and the structured filter.
+func (s *Store) whereClause(tableID string, q Query, columns columnIndex) (string, []interface{}, error) {
+	where := "table_id = ?"
+	args := []interface{}{tableID}
+	if search := strings.TrimSpace(q.Search); search != "" {
+		where += " AND LOWER(data) LIKE ? ESCAPE '\\'"
+		args = append(args, "%"+escapeLike(strings.ToLower(search))+"%")
+	}
+	if q.Filter != nil {
+		b := &whereBuilder{columns: columns, now: time.Now()}
+		clause, filterArgs, err := b.build(*q.Filter, 1)
+		if err != nil {
+			return "", nil, err
+		}
+		where += " AND " + clause
+		args = append(args, filterArgs...)
+	}
+	return where, args, nil
+}
+
+func pageBounds(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > maxRowsPerQuery {
+		limit = maxRowsPerQuery
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// QueryRows returns one page of rows matching q's search and filter, in q's
+// sort order. Grouping fields are ignored; use Query for those.
+func (s *Store) QueryRows(workspaceID, tableID string, q Query) (RowPage, error) {
+	if err := s.assertTable(workspaceID, tableID); err != nil {
+		return RowPage{}, err
+	}
+	columnList, err := s.ListColumns(workspaceID, tableID)
+	if err != nil {
+		return RowPage{}, err
+	}
+	columns := indexColumns(columnList)
+	limit, offset := pageBounds(q.Limit, q.Offset)
+	where, args, err := s.whereClause(tableID, q, columns)
+	if err != nil {
+		return RowPage{}, err
+	}
+
+	if len(q.Sort) > maxSortColumns {
+		return RowPage{}, queryError("at most %d sort columns", maxSortColumns)
+	}
+	var orderParts []string
+	var orderArgs []interface{}
+	for _, sort := range q.Sort {
+		column, ok := columns.find(sort.Column)
+		if !ok {
+			return RowPage{}, queryError("unknown sort column %q", sort.Column)
+		}
+		if isComputed(column.Type) {
+			return RowPage{}, queryError("%v", errComputedColumn(column))
+		}
+		direction, err := sortDirection(sort.Direction)
+		if err != nil {
+			return RowPage{}, err
+		}
+		valueExpression := "CAST(json_extract(data, ?) AS TEXT) COLLATE NOCASE"
+		if column.Type == "number" || column.Type == "checkbox" {
+			valueExpression = "CAST(json_extract(data, ?) AS REAL)"
+		}
+		// Keep empty cells at the bottom in both directions.
+		orderParts = append(orderParts, "json_extract(data, ?) IS NULL ASC", valueExpression+" "+direction)
+		path := jsonPath(column.ID)
+		orderArgs = append(orderArgs, path, path)
+	}
+	// The original row order is the stable tie-breaker.
+	orderParts = append(orderParts, "sort_order", "created_at")
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM user_database_rows WHERE "+where, args...).Scan(&total); err != nil {
+		return RowPage{}, err
+	}
+	queryArgs := append(append(append([]interface{}{}, args...), orderArgs...), limit, offset)
+	rows, err := s.db.Query(
+		`SELECT id, table_id, data, sort_order, created_at, updated_at
+		   FROM user_database_rows WHERE `+where+`
+		  ORDER BY `+strings.Join(orderParts, ", ")+` LIMIT ? OFFSET ?`, queryArgs...)
+	if err != nil {
+		return RowPage{}, err
+	}
+	defer rows.Close()
+
+	out := []Row{}
+	for rows.Next() {
+		var row Row
+		var raw string
+		if err := rows.Scan(&row.ID, &row.TableID, &raw, &row.SortOrder, &row.CreatedAt, &row.UpdatedAt); err != nil {
+			return RowPage{}, err
+		}
+		row.Values = map[string]interface{}{}
+		_ = json.Unmarshal([]byte(raw), &row.Values)
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		return RowPage{}, err
+	}
+	rows.Close()
+	if err := s.computeValues(workspaceID, columnList, out); err != nil {
+		return RowPage{}, err
+	}
+	return RowPage{Rows: out, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// Query runs a structured query and returns it keyed by column name. Without
+// grouping it is NamedRows with filters and multi-column sort; with GroupBy or
+// Aggregates each result row is one group, its columns the group columns
+// followed by the aggregates, and Total is the number of groups.
+func (s *Store) Query(workspaceID, tableID string, q Query) (NamedRows, error) {
+	if !q.IsGrouped() {
+		return s.namedQuery(workspaceID, tableID, q)
+	}
+	if err := s.assertTable(workspaceID, tableID); err != nil {
+		return NamedRows{}, err
+	}
+	columnList, err := s.ListColumns(workspaceID, tableID)
+	if err != nil {
+		return NamedRows{}, err
+	}
+	columns := indexColumns(columnList)
+	if len(q.GroupBy) > maxGroupColumns {
+		return NamedRows{}, queryError("at most %d group_by columns", maxGroupColumns)
+	}
+	if len(q.Aggregates) > maxAggregates {
+		return NamedRows{}, queryError("at most %d aggregates", maxAggregates)
+	}
+	limit, offset := pageBounds(q.Limit, q.Offset)
+	where, whereArgs, err := s.whereClause(tableID, q, columns)
+	if err != nil {
+		return NamedRows{}, err
+	}
+
+	var names, selects, groupAliases []string
+	var selectArgs []interface{}
+	aliases := map[string]string{}
+	for i, ref := range q.GroupBy {
+		column, err := columns.findStored(ref)
+		if err != nil {
+			return NamedRows{}, err
+		}
+		alias := fmt.Sprintf("g%d", i)
+		selects = append(selects, cellExpression(column)+" AS "+alias)
+		selectArgs = append(selectArgs, jsonPath(column.ID))
+		groupAliases = append(groupAliases, alias)
+		names = append(names, column.Name)
+		aliases[strings.ToLower(column.Name)] = alias
+		aliases[column.ID] = alias
+	}
+	for i, agg := range q.Aggregates {
+		op := strings.ToLower(strings.TrimSpace(agg.Op))
+		alias := fmt.Sprintf("a%d", i)
+		name := strings.TrimSpace(agg.As)
+		var expression string
+		if op == "count" && strings.TrimSpace(agg.Column) == "" {
+			expression = "COUNT(*)"
+			if name == "" {
+				name = "count"
+			}
+		} else {
+			column, err := columns.findStored(agg.Column)
+			if err != nil {
+				return NamedRows{}, err
+			}
+			switch op {
+			case "count":
+				// Counts the rows where the column is filled in.
+				expression = "COUNT(NULLIF(CAST(json_extract(data, ?) AS TEXT), ''))"
+			case "sum", "avg":
+				if column.Type != "number" && column.Type != "checkbox" {
+					return NamedRows{}, queryError("%s needs a number column, %q is %s", op, column.Name, column.Type)
+				}
+				expression = strings.ToUpper(op) + "(" + cellExpression(column) + ")"
+			case "min", "max":
+				expression = strings.ToUpper(op) + "(" + cellExpression(column) + ")"
+			default:
+				return NamedRows{}, queryError("unknown aggregate %q (use count, sum, avg, min or max)", agg.Op)
+			}
+			selectArgs = append(selectArgs, jsonPath(column.ID))
+			if name == "" {
+				name = op + "_" + column.Name
+			}
+		}
+		selects = append(selects, expression+" AS "+alias)
+		names = append(names, name)
+		aliases[strings.ToLower(name)] = alias
+	}
+
+	var orderParts []string
+	for _, sort := range q.Sort {
+		alias, ok := aliases[strings.ToLower(strings.TrimSpace(sort.Column))]
+		if !ok {
+			alias, ok = aliases[strings.TrimSpace(sort.Column)]
+		}
+		if !ok {
+			return NamedRows{}, queryError("unknown sort column %q", sort.Column)
+		}
+		direction, err := sortDirection(sort.Direction)
+		if err != nil {
+			return NamedRows{}, err
+		}
+		orderParts = append(orderParts, alias+" IS NULL ASC", alias+" "+direction)
+	}
+	orderParts = append(orderParts, groupAliases...)
+
+	grouped := "SELECT " + strings.Join(selects, ", ") + " FROM user_database_rows WHERE " + where
+	if len(groupAliases) > 0 {
+		grouped += " GROUP BY " + strings.Join(groupAliases, ", ")
+	}
+	baseArgs := append(append([]interface{}{}, selectArgs...), whereArgs...)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM ("+grouped+")", baseArgs...).Scan(&total); err != nil {
+		return NamedRows{}, err
+	}
+	if len(orderParts) > 0 {
+		grouped += " ORDER BY " + strings.Join(orderParts, ", ")
+	}
+	rows, err := s.db.Query(grouped+" LIMIT ? OFFSET ?", append(baseArgs, limit, offset)...)
+	if err != nil {
+		return NamedRows{}, err
+	}
+	defer rows.Close()
+
+	out := NamedRows{Columns: names, Rows: [][]interface{}{}, Records: []map[string]interface{}{}, Total: total}
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		pointers := make([]interface{}, len(names))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return NamedRows{}, err
+		}
+		record := map[string]interface{}{}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+				values[i] = value
+			}
+			record[names[i]] = value
+		}
+		out.Rows = append(out.Rows, values)
+		out.Records = append(out.Records, record)
+	}
+	return out, rows.Err()
+}
+
+// namedQuery projects QueryRows' page onto column names.
+func (s *Store) namedQuery(workspaceID, tableID string, q Query) (NamedRows, error) {
+	columns, err := s.ListColumns(workspaceID, tableID)
+	if err != nil {
+		return NamedRows{}, err
+	}
+	page, err := s.QueryRows(workspaceID, tableID, q)
+	if err != nil {
+		return NamedRows{}, err
+	}
+	out := NamedRows{Columns: []string{}, Rows: [][]interface{}{}, Records: []map[string]interface{}{}, Total: page.Total}
+	for _, column := range columns {
+		out.Columns = append(out.Columns, column.Name)
+	}
+	for _, row := range page.Rows {
+		values := make([]interface{}, 0, len(columns))
+		record := map[string]interface{}{"_row_id": row.ID}
+		for _, column := range columns {
+			value := row.Values[column.ID]
+			values = append(values, value)
+			record[column.Name] = value
+		}
+		out.Rows = append(out.Rows, values)
+		out.Records = append(out.Records, record)
+	}
+	return out, nil
+}
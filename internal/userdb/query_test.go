@@ -0,0 +1,153 @@
+package userdb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExpensesTable(t *testing.T) (*Store, string, string) {
+	t.Helper()
+	store, db := newTestStore(t)
+	workspaceID := createWorkspace(t, db, "Finance")
+	databaseItem, err := store.CreateDatabase(workspaceID, "Expenses", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := databaseItem.Tables[0]
+	name := table.Columns[0].ID
+	column := func(columnName, columnType string) string {
+		c, err := store.CreateColumn(workspaceID, table.ID, columnName, columnType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+	category, amount, spent, paid := column("Category", "text"), column("Amount", "number"), column("Spent", "date"), column("Paid", "checkbox")
+
+	today := time.Now().Format("2006-01-02")
+	lastMonth := time.Now().AddDate(0, 0, -40).Format("2006-01-02")
+	for _, values := range []map[string]interface{}{
+		{name: "Coffee", category: "Food", amount: 4.5, spent: today, paid: true},
+		{name: "Groceries", category: "Food", amount: 82, spent: today},
+		{name: "Train", category: "Travel", amount: 30, spent: lastMonth, paid: true},
+		{name: "Hotel", category: "Travel", amount: 210, spent: lastMonth, paid: true},
+		{name: "Mystery"},
+	} {
+		if _, err := store.CreateRow(workspaceID, table.ID, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, workspaceID, table.ID
+}
+
+func mustQuery(t *testing.T, store *Store, workspaceID, tableID, query string) NamedRows {
+	t.Helper()
+	var q Query
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		t.Fatalf("bad test query %s: %v", query, err)
+	}
+	result, err := store.Query(workspaceID, tableID, q)
+	if err != nil {
+		t.Fatalf("Query(%s): %v", query, err)
+	}
+	return result
+}
+
+func names(result NamedRows) []interface{} {
+	out := []interface{}{}
+	for _, record := range result.Records {
+		out = append(out, record["Name"])
+	}
+	return out
+}
+
+func TestQueryFiltersAndSort(t *testing.T) {
+	store, workspaceID, tableID := newExpensesTable(t)
+
+	cases := []struct {
+		query string
+		want  []interface{}
+	}{
+		{`{"filter":{"column":"Amount","op":"gt","value":20},"sort":[{"column":"Amount","direction":"desc"}]}`,
+			[]interface{}{"Hotel", "Groceries", "Train"}},
+		{`{"filter":{"and":[{"column":"category","op":"eq","value":"food"},{"column":"Paid","op":"eq","value":false}]}}`,
+			[]interface{}{"Groceries"}},
+		{`{"filter":{"or":[{"column":"Name","op":"starts_with","value":"ho"},{"column":"Amount","op":"between","value":[1,5]}]}}`,
+			[]interface{}{"Coffee", "Hotel"}},
+		{`{"filter":{"column":"Category","op":"is_empty"}}`, []interface{}{"Mystery"}},
+		{`{"filter":{"column":"Category","op":"neq","value":"Travel"}}`, []interface{}{"Coffee", "Groceries", "Mystery"}},
+		{`{"filter":{"column":"Name","op":"in","value":["train","COFFEE"]}}`, []interface{}{"Coffee", "Train"}},
+		{`{"filter":{"column":"Spent","op":"within_last","value":7}}`, []interface{}{"Coffee", "Groceries"}},
+		{`{"filter":{"column":"Spent","op":"lt","value":"-30d"}}`, []interface{}{"Train", "Hotel"}},
+		{`{"sort":[{"column":"Category","direction":"desc"},{"column":"Amount"}]}`,
+			[]interface{}{"Train", "Hotel", "Coffee", "Groceries", "Mystery"}},
+	}
+	for _, c := range cases {
+		result := mustQuery(t, store, workspaceID, tableID, c.query)
+		got := names(result)
+		if len(got) != len(c.want) || result.Total != len(c.want) {
+			t.Errorf("%s = %v (total %d), want %v", c.query, got, result.Total, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s = %v, want %v", c.query, got, c.want)
+				break
+			}
+		}
+	}
+
+	for _, bad := range []string{
+		`{"filter":{"column":"Nope","op":"eq","value":1}}`,
+		`{"filter":{"column":"Amount","op":"gt","value":"lots"}}`,
+		`{"filter":{"column":"Amount","op":"like","value":1}}`,
+		`{"filter":{"column":"Name","op":"within_last","value":3}}`,
+	} {
+		var q Query
+		json.Unmarshal([]byte(bad), &q)
+		if _, err := store.Query(workspaceID, tableID, q); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
+
+func TestQueryGroupByAndAggregates(t *testing.T) {
+	store, workspaceID, tableID := newExpensesTable(t)
+
+	result := mustQuery(t, store, workspaceID, tableID,
+		`{"filter":{"column":"Category","op":"not_empty"},"group_by":["Category"],
+		  "aggregates":[{"op":"count"},{"op":"sum","column":"Amount","as":"total"},{"op":"max","column":"Amount"}],
+		  "sort":[{"column":"total","direction":"desc"}]}`)
+	if result.Total != 2 || len(result.Records) != 2 {
+		t.Fatalf("groups = %+v, want Food and Travel", result)
+	}
+	travel := result.Records[0]
+	if travel["Category"] != "Travel" || travel["count"] != int64(2) || travel["total"] != float64(240) || travel["max_Amount"] != float64(210) {
+		t.Errorf("travel group = %v", travel)
+	}
+	if got := result.Columns; len(got) != 4 || got[0] != "Category" || got[2] != "total" {
+		t.Errorf("columns = %v", got)
+	}
+
+	overall := mustQuery(t, store, workspaceID, tableID, `{"aggregates":[{"op":"avg","column":"Amount"},{"op":"count","column":"Paid"}]}`)
+	if overall.Total != 1 || overall.Records[0]["avg_Amount"] != 81.625 || overall.Records[0]["count_Paid"] != int64(3) {
+		t.Errorf("whole-table aggregates = %+v", overall.Records)
+	}
+
+	if _, err := store.Query(workspaceID, tableID, Query{Aggregates: []Aggregate{{Op: "sum", Column: "Name"}}}); err == nil {
+		t.Error("sum over a text column was accepted")
+	}
+
+	// A sort on a column that isn't there is the caller's mistake, reported
+	// as an invalid query whether or not the rows are grouped.
+	for _, q := range []Query{
+		{Sort: []Sort{{Column: "Nope"}}},
+		{GroupBy: []string{"Category"}, Sort: []Sort{{Column: "Nope"}}},
+	} {
+		if _, err := store.Query(workspaceID, tableID, q); err == nil || !strings.HasPrefix(err.Error(), "invalid query") {
+			t.Errorf("sort on a missing column: err = %v, want an invalid query", err)
+		}
+	}
+}
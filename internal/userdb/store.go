@@ -27,10 +27,15 @@ var validColumnTypes = map[string]bool{
 	"url":       true,
 	"email":     true,
 	"select":    true,
+	"link":      true,
+	"lookup":    true,
+	"rollup":    true,
+	"formula":   true,
 }
 
 type Store struct {
-	db *database.DB
+	db    *database.DB
+	actor Actor
 }
 
 func NewStore(db *database.DB) *Store {
@@ -349,6 +354,11 @@ func (s *Store) UpdateTable(workspaceID, tableID string, name *string) (Table, e
 			return Table{}, friendlyConstraint(err, "a table with that name already exists")
 		}
 		_, _ = s.db.Exec("UPDATE user_databases SET updated_at = ? WHERE id = ?", now, t.DatabaseID)
+		if value != t.Name {
+			if err := s.record(s.db, tableID, "", "", RevisionTableRenamed, map[string]string{"name": t.Name}, map[string]string{"name": value}); err != nil {
+				return Table{}, err
+			}
+		}
 	}
 	return s.GetTable(workspaceID, tableID)
 }
@@ -358,6 +368,9 @@ func (s *Store) DeleteTable(workspaceID, tableID string) error {
 	if err != nil {
 		return err
 	}
+	if err := s.deleteDependents(workspaceID, "", tableID); err != nil {
+		return err
+	}
 	result, err := s.db.Exec("DELETE FROM user_database_tables WHERE id = ?", tableID)
 	if err != nil {
 		return err
@@ -409,8 +422,9 @@ func (s *Store) CreateColumn(workspaceID, tableID, name, columnType string, opti
 	if err != nil {
 		return Column{}, err
 	}
-	if options == nil {
-		options = map[string]interface{}{}
+	options, err = s.normalizeColumnOptions(workspaceID, tableID, "", columnType, options)
+	if err != nil {
+		return Column{}, fmt.Errorf("invalid column options: %w", err)
 	}
 	optionsJSON, err := json.Marshal(options)
 	if err != nil {
@@ -428,7 +442,11 @@ func (s *Store) CreateColumn(workspaceID, tableID, name, columnType string, opti
 		return Column{}, friendlyConstraint(err, "a column with that name already exists")
 	}
 	s.touchTable(t)
-	return s.getColumn(workspaceID, id)
+	column, err := s.getColumn(workspaceID, id)
+	if err != nil {
+		return Column{}, err
+	}
+	return column, s.record(s.db, tableID, "", id, RevisionColumnCreated, nil, column)
 }
 
 func (s *Store) UpdateColumn(workspaceID, columnID string, name, columnType *string, options map[string]interface{}) (Column, error) {
@@ -438,22 +456,32 @@ func (s *Store) UpdateColumn(workspaceID, columnID string, name, columnType *str
 	}
 	set := []string{"updated_at = ?"}
 	args := []interface{}{time.Now().UTC()}
+	var newName string
 	if name != nil {
-		value := cleanName(*name)
-		if value == "" {
+		newName = cleanName(*name)
+		if newName == "" {
 			return Column{}, errors.New("name cannot be empty")
 		}
 		set = append(set, "name = ?")
-		args = append(args, value)
+		args = append(args, newName)
 	}
+	newType := current.Type
 	if columnType != nil {
 		if !validColumnTypes[*columnType] {
 			return Column{}, fmt.Errorf("unsupported column type %q", *columnType)
 		}
+		newType = *columnType
 		set = append(set, "type = ?")
-		args = append(args, *columnType)
+		args = append(args, newType)
 	}
-	if options != nil {
+	if options != nil || newType != current.Type {
+		if options == nil {
+			options = current.Options
+		}
+		options, err = s.normalizeColumnOptions(workspaceID, current.TableID, columnID, newType, options)
+		if err != nil {
+			return Column{}, fmt.Errorf("invalid column options: %w", err)
+		}
 		value, err := json.Marshal(options)
 		if err != nil {
 			return Column{}, errors.New("invalid column options")
@@ -461,13 +489,36 @@ func (s *Store) UpdateColumn(workspaceID, columnID string, name, columnType *str
 		set = append(set, "options = ?")
 		args = append(args, string(value))
 	}
+	// Row IDs mean nothing once a link points elsewhere or stops being a
+	// link, and neither do the lookups and rollups reading through it.
+	relinked := current.Type == "link" &&
+		(newType != "link" || optionString(options, "table_id") != optionString(current.Options, "table_id"))
+	if relinked {
+		if err := s.deleteDependents(workspaceID, columnID, ""); err != nil {
+			return Column{}, err
+		}
+	}
 	args = append(args, columnID)
 	if _, err := s.db.Exec("UPDATE user_database_columns SET "+strings.Join(set, ", ")+" WHERE id = ?", args...); err != nil {
 		return Column{}, friendlyConstraint(err, "a column with that name already exists")
 	}
+	if relinked || (newType == "link" && current.Type != "link") {
+		if err := s.clearColumnValues(current.TableID, columnID); err != nil {
+			return Column{}, err
+		}
+	}
+	if name != nil {
+		if err := s.renameFormulaReferences(current.TableID, current.Name, newName); err != nil {
+			return Column{}, err
+		}
+	}
 	t, _ := s.GetTable(workspaceID, current.TableID)
 	s.touchTable(t)
-	return s.getColumn(workspaceID, columnID)
+	updated, err := s.getColumn(workspaceID, columnID)
+	if err != nil {
+		return Column{}, err
+	}
+	return updated, s.record(s.db, current.TableID, "", columnID, RevisionColumnUpdated, current, updated)
 }
 
 func (s *Store) DeleteColumn(workspaceID, columnID string) error {
@@ -475,47 +526,45 @@ func (s *Store) DeleteColumn(workspaceID, columnID string) error {
 	if err != nil {
 		return err
 	}
-	tx, err := s.db.Begin()
-	if err != nil {
+	if err := s.deleteDependents(workspaceID, columnID, ""); err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	rows, err := tx.Query("SELECT id, data FROM user_database_rows WHERE table_id = ?", column.TableID)
-	if err != nil {
+	if err := s.clearColumnValues(column.TableID, columnID); err != nil {
 		return err
 	}
-	type rowData struct {
-		id   string
-		data map[string]interface{}
+	if _, err := s.db.Exec("DELETE FROM user_database_columns WHERE id = ?", columnID); err != nil {
+		return err
 	}
-	var updates []rowData
-	for rows.Next() {
-		var id, raw string
-		if err := rows.Scan(&id, &raw); err != nil {
-			rows.Close()
-			return err
+	t, _ := s.GetTable(workspaceID, column.TableID)
+	s.touchTable(t)
+	return s.record(s.db, column.TableID, "", columnID, RevisionColumnDeleted, column, nil)
+}
+
+// clearColumnValues removes a column's stored value from every row.
+func (s *Store) clearColumnValues(tableID, columnID string) error {
+	// The column's own revision covers this; a row revision per row would
+	// only bury the history that matters.
+	return s.rewriteRows(tableID, "%", "", func(values map[string]interface{}) bool {
+		if _, ok := values[columnID]; !ok {
+			return false
 		}
-		values := map[string]interface{}{}
-		_ = json.Unmarshal([]byte(raw), &values)
 		delete(values, columnID)
-		updates = append(updates, rowData{id: id, data: values})
+		return true
+	})
+}
+
+// deleteDependents deletes the columns that depend on a column or table; see
+// dependentColumns.
+func (s *Store) deleteDependents(workspaceID, columnID, tableID string) error {
+	ids, err := s.dependentColumns(columnID, tableID)
+	if err != nil {
+		return err
 	}
-	rows.Close()
-	for _, update := range updates {
-		raw, _ := json.Marshal(update.data)
-		if _, err := tx.Exec("UPDATE user_database_rows SET data = ?, updated_at = ? WHERE id = ?", string(raw), time.Now().UTC(), update.id); err != nil {
+	for _, id := range ids {
+		if err := s.DeleteColumn(workspaceID, id); err != nil && !errors.Is(err, sql.ErrNoRows) {
 			return err
 		}
 	}
-	if _, err := tx.Exec("DELETE FROM user_database_columns WHERE id = ?", columnID); err != nil {
-		return err
-	}
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-	t, _ := s.GetTable(workspaceID, column.TableID)
-	s.touchTable(t)
 	return nil
 }
 
@@ -524,119 +573,16 @@ func (s *Store) ListRows(workspaceID, tableID, search string, limit, offset int)
 }
 
 // ListRowsSorted returns one page of rows, optionally ordered by a column.
-// Column IDs and directions are validated before the direction is inserted
-// into SQL; the JSON path itself remains a bound parameter.
 func (s *Store) ListRowsSorted(workspaceID, tableID, search string, limit, offset int, sortColumnID, sortDirection string) (RowPage, error) {
-	if err := s.assertTable(workspaceID, tableID); err != nil {
-		return RowPage{}, err
-	}
-	if limit <= 0 {
-		limit = 100
-	}
-	if limit > maxRowsPerQuery {
-		limit = maxRowsPerQuery
-	}
-	if offset < 0 {
-		offset = 0
-	}
-	where := "table_id = ?"
-	args := []interface{}{tableID}
-	if search = strings.TrimSpace(search); search != "" {
-		where += " AND LOWER(data) LIKE ? ESCAPE '\\'"
-		args = append(args, "%"+escapeLike(strings.ToLower(search))+"%")
-	}
-
-	orderBy := "sort_order, created_at"
-	orderArgs := []interface{}{}
+	q := Query{Search: search, Limit: limit, Offset: offset}
 	if sortColumnID = strings.TrimSpace(sortColumnID); sortColumnID != "" {
-		var columnType string
-		if err := s.db.QueryRow(
-			"SELECT type FROM user_database_columns WHERE id = ? AND table_id = ?",
-			sortColumnID,
-			tableID,
-		).Scan(&columnType); err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return RowPage{}, fmt.Errorf("unknown sort column %q", sortColumnID)
-			}
-			return RowPage{}, err
-		}
-
-		direction := strings.ToUpper(strings.TrimSpace(sortDirection))
-		if direction == "" {
-			direction = "ASC"
-		}
-		if direction != "ASC" && direction != "DESC" {
-			return RowPage{}, fmt.Errorf("invalid sort direction %q", sortDirection)
-		}
-
-		jsonPath := `$."` + strings.ReplaceAll(sortColumnID, `"`, `\"`) + `"`
-		valueExpression := "CAST(json_extract(data, ?) AS TEXT) COLLATE NOCASE"
-		if columnType == "number" || columnType == "checkbox" {
-			valueExpression = "CAST(json_extract(data, ?) AS REAL)"
-		}
-		// Keep empty cells at the bottom in both directions, then use the
-		// original row order as a stable tie-breaker.
-		orderBy = fmt.Sprintf(
-			"json_extract(data, ?) IS NULL ASC, %s %s, sort_order, created_at",
-			valueExpression,
-			direction,
-		)
-		orderArgs = append(orderArgs, jsonPath, jsonPath)
-	}
-
-	var total int
-	if err := s.db.QueryRow("SELECT COUNT(*) FROM user_database_rows WHERE "+where, args...).Scan(&total); err != nil {
-		return RowPage{}, err
-	}
-	queryArgs := append(append(append([]interface{}{}, args...), orderArgs...), limit, offset)
-	rows, err := s.db.Query(
-		`SELECT id, table_id, data, sort_order, created_at, updated_at
-		   FROM user_database_rows WHERE `+where+`
-		  ORDER BY `+orderBy+` LIMIT ? OFFSET ?`, queryArgs...)
-	if err != nil {
-		return RowPage{}, err
-	}
-	defer rows.Close()
-
-	out := []Row{}
-	for rows.Next() {
-		var row Row
-		var raw string
-		if err := rows.Scan(&row.ID, &row.TableID, &raw, &row.SortOrder, &row.CreatedAt, &row.UpdatedAt); err != nil {
-			return RowPage{}, err
-		}
-		row.Values = map[string]interface{}{}
-		_ = json.Unmarshal([]byte(raw), &row.Values)
-		out = append(out, row)
+		q.Sort = []Sort{{Column: sortColumnID, Direction: sortDirection}}
 	}
-	return RowPage{Rows: out, Total: total, Limit: limit, Offset: offset}, rows.Err()
+	return s.QueryRows(workspaceID, tableID, q)
 }
 
 func (s *Store) NamedRows(workspaceID, tableID, search string, limit, offset int) (NamedRows, error) {
-	columns, err := s.ListColumns(workspaceID, tableID)
-	if err != nil {
-		return NamedRows{}, err
-	}
-	page, err := s.ListRows(workspaceID, tableID, search, limit, offset)
-	if err != nil {
-		return NamedRows{}, err
-	}
-	out := NamedRows{Columns: []string{}, Rows: [][]interface{}{}, Records: []map[string]interface{}{}, Total: page.Total}
-	for _, column := range columns {
-		out.Columns = append(out.Columns, column.Name)
-	}
-	for _, row := range page.Rows {
-		values := make([]interface{}, 0, len(columns))
-		record := map[string]interface{}{"_row_id": row.ID}
-		for _, column := range columns {
-			value := row.Values[column.ID]
-			values = append(values, value)
-			record[column.Name] = value
-		}
-		out.Rows = append(out.Rows, values)
-		out.Records = append(out.Records, record)
-	}
-	return out, nil
+	return s.Query(workspaceID, tableID, Query{Search: search, Limit: limit, Offset: offset})
 }
 
 func (s *Store) CreateRow(workspaceID, tableID string, values map[string]interface{}) (Row, error) {
@@ -652,13 +598,24 @@ func (s *Store) CreateRow(workspaceID, tableID string, values map[string]interfa
 	_ = s.db.QueryRow("SELECT COALESCE(MAX(sort_order), -1) + 1 FROM user_database_rows WHERE table_id = ?", tableID).Scan(&sortOrder)
 	id := uuid.New().String()
 	now := time.Now().UTC()
-	if _, err := s.db.Exec(
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Row{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
 		`INSERT INTO user_database_rows (id, table_id, data, sort_order, created_at, updated_at)
 		 VALUES (?, ?, ?, ?, ?, ?)`,
 		id, tableID, string(raw), sortOrder, now, now,
 	); err != nil {
 		return Row{}, err
 	}
+	if err := s.record(tx, tableID, id, "", RevisionRowCreated, nil, clean); err != nil {
+		return Row{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Row{}, err
+	}
 	t, _ := s.GetTable(workspaceID, tableID)
 	s.touchTable(t)
 	return Row{ID: id, TableID: tableID, Values: clean, SortOrder: sortOrder, CreatedAt: now, UpdatedAt: now}, nil
@@ -673,6 +630,10 @@ func (s *Store) UpdateRow(workspaceID, rowID string, values map[string]interface
 	if err != nil {
 		return Row{}, err
 	}
+	before := make(map[string]interface{}, len(row.Values))
+	for key, value := range row.Values {
+		before[key] = value
+	}
 	for key, value := range clean {
 		if value == nil {
 			delete(row.Values, key)
@@ -682,7 +643,18 @@ func (s *Store) UpdateRow(workspaceID, rowID string, values map[string]interface
 	}
 	raw, _ := json.Marshal(row.Values)
 	row.UpdatedAt = time.Now().UTC()
-	if _, err := s.db.Exec("UPDATE user_database_rows SET data = ?, updated_at = ? WHERE id = ?", string(raw), row.UpdatedAt, rowID); err != nil {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Row{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE user_database_rows SET data = ?, updated_at = ? WHERE id = ?", string(raw), row.UpdatedAt, rowID); err != nil {
+		return Row{}, err
+	}
+	if err := s.record(tx, row.TableID, rowID, "", RevisionRowUpdated, before, row.Values); err != nil {
+		return Row{}, err
+	}
+	if err := tx.Commit(); err != nil {
 		return Row{}, err
 	}
 	t, _ := s.GetTable(workspaceID, row.TableID)
@@ -695,16 +667,36 @@ func (s *Store) DeleteRow(workspaceID, rowID string) error {
 	if err != nil {
 		return err
 	}
-	result, err := s.db.Exec("DELETE FROM user_database_rows WHERE id = ?", rowID)
+	if err := s.deleteRow(row, RevisionRowDeleted); err != nil {
+		return err
+	}
+	t, _ := s.GetTable(workspaceID, row.TableID)
+	s.touchTable(t)
+	return nil
+}
+
+// deleteRow deletes a row, recording it as a revision of the given kind, and
+// takes it out of the links that point at it.
+func (s *Store) deleteRow(row Row, kind string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("DELETE FROM user_database_rows WHERE id = ?", row.ID)
 	if err != nil {
 		return err
 	}
 	if n, _ := result.RowsAffected(); n == 0 {
 		return sql.ErrNoRows
 	}
-	t, _ := s.GetTable(workspaceID, row.TableID)
-	s.touchTable(t)
-	return nil
+	if err := s.record(tx, row.TableID, row.ID, "", kind, row.Values, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.unlinkRow(row.TableID, row.ID)
 }
 
 // ColumnIDsForNames converts an agent-friendly {column name: value} object to
@@ -770,15 +762,24 @@ func (s *Store) validateValues(workspaceID, tableID string, values map[string]in
 	if err != nil {
 		return nil, err
 	}
-	known := map[string]bool{}
+	known := map[string]Column{}
 	for _, column := range columns {
-		known[column.ID] = true
+		known[column.ID] = column
 	}
 	clean := map[string]interface{}{}
 	for id, value := range values {
-		if !known[id] {
+		column, ok := known[id]
+		if !ok {
 			return nil, fmt.Errorf("unknown column id %q", id)
 		}
+		if isComputed(column.Type) {
+			return nil, errComputedColumn(column)
+		}
+		if column.Type == "link" {
+			if value, err = s.linkValue(column, value); err != nil {
+				return nil, err
+			}
+		}
 		clean[id] = value
 	}
 	return clean, nil
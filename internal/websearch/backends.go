@@ -0,0 +1,175 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultBraveURL  = "https://api.search.brave.com/res/v1/web/search"
+	DefaultGoogleURL = "https://www.googleapis.com/customsearch/v1"
+)
+
+// SearXNG queries a self-hosted SearXNG instance through its JSON API. The
+// instance must have "json" enabled under search.formats in settings.yml.
+type SearXNG struct {
+	BaseURL string
+}
+
+func (s *SearXNG) Name() string { return "searxng" }
+
+func (s *SearXNG) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := strings.TrimRight(s.BaseURL, "/")
+	if !strings.HasSuffix(endpoint, "/search") {
+		endpoint += "/search"
+	}
+	params := url.Values{"q": {query}, "format": {"json"}}
+
+	var body struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, endpoint+"?"+params.Encode(), nil, &body); err != nil {
+		return nil, err
+	}
+
+	var out []Result
+	for _, r := range body.Results {
+		out = append(out, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Brave queries the Brave Search API.
+type Brave struct {
+	APIKey  string
+	BaseURL string // defaults to DefaultBraveURL
+}
+
+func (b *Brave) Name() string { return "brave" }
+
+func (b *Brave) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := b.BaseURL
+	if endpoint == "" {
+		endpoint = DefaultBraveURL
+	}
+	count := limit
+	if count > 20 {
+		count = 20 // Brave's per-request maximum
+	}
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(count)}}
+
+	var body struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	headers := map[string]string{"X-Subscription-Token": b.APIKey}
+	if err := getJSON(ctx, endpoint+"?"+params.Encode(), headers, &body); err != nil {
+		return nil, err
+	}
+
+	var out []Result
+	for _, r := range body.Web.Results {
+		out = append(out, Result{Title: r.Title, URL: r.URL, Snippet: stripTags(r.Description)})
+	}
+	return out, nil
+}
+
+// Google queries a Google Programmable Search engine.
+type Google struct {
+	APIKey   string
+	EngineID string
+	BaseURL  string // defaults to DefaultGoogleURL
+}
+
+func (g *Google) Name() string { return "google" }
+
+func (g *Google) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := g.BaseURL
+	if endpoint == "" {
+		endpoint = DefaultGoogleURL
+	}
+	num := limit
+	if num > 10 {
+		num = 10 // the Custom Search JSON API returns at most 10 per page
+	}
+	params := url.Values{"key": {g.APIKey}, "cx": {g.EngineID}, "q": {query}, "num": {strconv.Itoa(num)}}
+
+	var body struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := getJSON(ctx, endpoint+"?"+params.Encode(), nil, &body); err != nil {
+		return nil, err
+	}
+
+	var out []Result
+	for _, r := range body.Items {
+		out = append(out, Result{Title: r.Title, URL: r.Link, Snippet: r.Snippet})
+	}
+	return out, nil
+}
+
+func getJSON(ctx context.Context, endpoint string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "OpenPaw/1.0")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// stripTags removes the <strong> highlighting Brave puts in descriptions.
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package websearch
+
+import (
+	"os"
+	"strings"
+
+	"github.com/openpaw/openpaw/internal/database"
+	"github.com/openpaw/openpaw/internal/secrets"
+)
+
+// Where each backend's configuration lives. The SearXNG URL is an ordinary
+// setting; API keys are read from the secrets store under the same names the
+// Brave and Google tool-library tools use, so one secret serves both.
+const (
+	SearXNGURLSetting    = "web_search_searxng_url"
+	BraveKeySecret       = "BRAVE_SEARCH_API_KEY"
+	GoogleKeySecret      = "GOOGLE_SEARCH_API_KEY"
+	GoogleEngineIDSecret = "GOOGLE_SEARCH_ENGINE_ID"
+)
+
+// Config is the resolved backend configuration. Base URLs are only set to
+// point a backend somewhere other than its public API (tests, proxies).
+type Config struct {
+	SearXNGURL     string
+	BraveAPIKey    string
+	BraveURL       string
+	GoogleAPIKey   string
+	GoogleEngineID string
+	GoogleURL      string
+}
+
+// LoadConfig reads backend configuration, with environment variables taking
+// precedence over stored values the same way API keys do elsewhere.
+func LoadConfig(db *database.DB, secretsMgr *secrets.Manager) Config {
+	cfg := Config{
+		SearXNGURL:     os.Getenv("SEARXNG_URL"),
+		BraveAPIKey:    os.Getenv(BraveKeySecret),
+		GoogleAPIKey:   os.Getenv(GoogleKeySecret),
+		GoogleEngineID: os.Getenv(GoogleEngineIDSecret),
+	}
+	if cfg.SearXNGURL == "" {
+		db.QueryRow("SELECT value FROM settings WHERE key = ?", SearXNGURLSetting).Scan(&cfg.SearXNGURL)
+	}
+	if cfg.BraveAPIKey == "" {
+		cfg.BraveAPIKey = secretValue(db, secretsMgr, BraveKeySecret)
+	}
+	if cfg.GoogleAPIKey == "" {
+		cfg.GoogleAPIKey = secretValue(db, secretsMgr, GoogleKeySecret)
+	}
+	if cfg.GoogleEngineID == "" {
+		cfg.GoogleEngineID = secretValue(db, secretsMgr, GoogleEngineIDSecret)
+	}
+	return cfg
+}
+
+// secretValue decrypts a named secret, treating a missing secret and the
+// REPLACE_ME placeholder alike as unset.
+func secretValue(db *database.DB, secretsMgr *secrets.Manager, name string) string {
+	if secretsMgr == nil {
+		return ""
+	}
+	var encrypted string
+	if err := db.QueryRow("SELECT encrypted_value FROM secrets WHERE name = ?", name).Scan(&encrypted); err != nil {
+		return ""
+	}
+	plaintext, err := secretsMgr.Decrypt(encrypted)
+	if err != nil {
+		return ""
+	}
+	plaintext = strings.TrimSpace(plaintext)
+	if plaintext == "REPLACE_ME" {
+		return ""
+	}
+	return plaintext
+}
+
+// Backends returns a backend for every engine that has enough configuration
+// to run. SearXNG comes first: it is self-hosted, so it is the one most
+// deliberately set up.
+func (c Config) Backends() []Backend {
+	var out []Backend
+	if u := strings.TrimSpace(c.SearXNGURL); u != "" {
+		out = append(out, &SearXNG{BaseURL: u})
+	}
+	if c.BraveAPIKey != "" {
+		out = append(out, &Brave{APIKey: c.BraveAPIKey, BaseURL: c.BraveURL})
+	}
+	if c.GoogleAPIKey != "" && c.GoogleEngineID != "" {
+		out = append(out, &Google{APIKey: c.GoogleAPIKey, EngineID: c.GoogleEngineID, BaseURL: c.GoogleURL})
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+// Package websearch backs the agents' WebSearch tool. Each search engine is a
+// Backend; a Searcher fans a query out to every configured backend, then
+// merges, ranks and deduplicates what comes back.
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openpaw/openpaw/internal/logger"
+)
+
+const (
+	DefaultLimit = 8
+	MaxLimit     = 20
+)
+
+// Result is a single search hit as returned to the agent.
+type Result struct {
+	Title   string   `json:"title"`
+	URL     string   `json:"url"`
+	Snippet string   `json:"snippet"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Backend is one search engine. Results come back in the engine's own order,
+// best first.
+type Backend interface {
+	Name() string
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// ErrNotConfigured is returned when no backend has been set up.
+var ErrNotConfigured = fmt.Errorf("no web search backend is configured — add a SearXNG URL in Settings, or a BRAVE_SEARCH_API_KEY or GOOGLE_SEARCH_API_KEY + GOOGLE_SEARCH_ENGINE_ID secret")
+
+// Searcher queries the configured backends. Backends are resolved on every
+// search rather than captured once, so a key saved in Secrets takes effect
+// without a restart.
+type Searcher struct {
+	backends func() []Backend
+}
+
+func NewSearcher(backends func() []Backend) *Searcher {
+	return &Searcher{backends: backends}
+}
+
+// Search runs query against every backend in parallel. A backend that fails is
+// logged and skipped; the search only fails when all of them do.
+func (s *Searcher) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	backends := s.backends()
+	if len(backends) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	lists := make([][]Result, len(backends))
+	errs := make([]error, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+			lists[i], errs[i] = b.Search(ctx, query, limit)
+			if errs[i] != nil {
+				logger.Warn("web search: %s backend failed: %v", b.Name(), errs[i])
+			}
+			for j := range lists[i] {
+				lists[i][j].Sources = []string{b.Name()}
+			}
+		}(i, b)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", backends[i].Name(), err))
+		}
+	}
+	if len(failed) == len(backends) {
+		return nil, fmt.Errorf("all search backends failed (%s)", strings.Join(failed, "; "))
+	}
+
+	return Merge(lists, limit), nil
+}
+
+// rrfK damps reciprocal rank fusion so a top hit from one engine doesn't
+// drown out a result that several engines agree on. 60 is the customary value.
+const rrfK = 60
+
+// Merge combines per-backend result lists with reciprocal rank fusion. Hits for
+// the same page (after URL normalization) are folded into one, keeping the
+// longest snippet and the union of sources.
+func Merge(lists [][]Result, limit int) []Result {
+	type scored struct {
+		Result
+		score float64
+		first int
+	}
+	byKey := make(map[string]*scored)
+	var order []*scored
+
+	for _, list := range lists {
+		for rank, r := range list {
+			if r.URL == "" {
+				continue
+			}
+			key := normalizeURL(r.URL)
+			s, ok := byKey[key]
+			if !ok {
+				s = &scored{Result: r, first: len(order)}
+				s.Sources = nil
+				byKey[key] = s
+				order = append(order, s)
+			}
+			s.score += 1.0 / float64(rrfK+rank+1)
+			if len(r.Snippet) > len(s.Snippet) {
+				s.Snippet = r.Snippet
+			}
+			if s.Title == "" {
+				s.Title = r.Title
+			}
+			for _, src := range r.Sources {
+				if !contains(s.Sources, src) {
+					s.Sources = append(s.Sources, src)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].score != order[j].score {
+			return order[i].score > order[j].score
+		}
+		return order[i].first < order[j].first
+	})
+
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	out := make([]Result, len(order))
+	for i, s := range order {
+		out[i] = s.Result
+		out[i].Title = strings.TrimSpace(out[i].Title)
+		out[i].Snippet = strings.TrimSpace(out[i].Snippet)
+	}
+	return out
+}
+
+// normalizeURL reduces a URL to the form used for deduplication: no scheme,
+// no "www.", no fragment, no tracking parameters and no trailing slash.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimSpace(raw))
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	q := u.Query()
+	for key := range q {
+		if strings.HasPrefix(key, "utm_") || key == "ref" || key == "fbclid" || key == "gclid" {
+			q.Del(key)
+		}
+	}
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	key := host + path
+	if enc := q.Encode(); enc != "" {
+		key += "?" + enc
+	}
+	return key
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// httpClient is shared by the backends; the Searcher's context carries the
+// overall deadline.
+var httpClient = &http.Client{Timeout: 20 * time.Second}
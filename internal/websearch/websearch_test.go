@@ -0,0 +1,121 @@
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearchMergesBackends(t *testing.T) {
+	searx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"results":[
+			{"title":"Go","url":"https://go.dev/","content":"The Go language"},
+			{"title":"Tour","url":"https://go.dev/tour","content":"A tour"}
+		]}`)
+	}))
+	defer searx.Close()
+
+	var braveKey string
+	brave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		braveKey = r.Header.Get("X-Subscription-Token")
+		fmt.Fprint(w, `{"web":{"results":[
+			{"title":"Blog","url":"https://blog.example.com/go","description":"A <strong>blog</strong>"},
+			{"title":"Go","url":"https://www.go.dev?utm_source=brave","description":"The Go programming language, longer"}
+		]}}`)
+	}))
+	defer brave.Close()
+
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cx") != "engine" {
+			http.Error(w, "bad cx", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"items":[{"title":"Go","link":"https://go.dev","snippet":"Go"}]}`)
+	}))
+	defer google.Close()
+
+	cfg := Config{
+		SearXNGURL:     searx.URL,
+		BraveAPIKey:    "brave-key",
+		BraveURL:       brave.URL,
+		GoogleAPIKey:   "google-key",
+		GoogleEngineID: "engine",
+		GoogleURL:      google.URL,
+	}
+	s := NewSearcher(cfg.Backends)
+
+	results, err := s.Search(context.Background(), "golang", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if braveKey != "brave-key" {
+		t.Errorf("brave token = %q", braveKey)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3 after dedup: %+v", len(results), results)
+	}
+	top := results[0]
+	if top.URL != "https://go.dev/" || len(top.Sources) != 3 {
+		t.Errorf("top result = %+v, want go.dev from all three backends", top)
+	}
+	if top.Snippet != "The Go programming language, longer" {
+		t.Errorf("top snippet = %q, want the longest one", top.Snippet)
+	}
+	for _, r := range results {
+		if strings.Contains(r.Snippet, "<strong>") {
+			t.Errorf("snippet still has markup: %q", r.Snippet)
+		}
+	}
+}
+
+func TestSearchPartialFailure(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[{"title":"A","url":"https://a.example","content":"a"}]}`)
+	}))
+	defer up.Close()
+
+	s := NewSearcher(func() []Backend {
+		return []Backend{&Brave{APIKey: "k", BaseURL: down.URL}, &SearXNG{BaseURL: up.URL}}
+	})
+	results, err := s.Search(context.Background(), "a", 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("results = %+v, err = %v; want the working backend's result", results, err)
+	}
+
+	s = NewSearcher(func() []Backend { return []Backend{&Brave{APIKey: "k", BaseURL: down.URL}} })
+	if _, err := s.Search(context.Background(), "a", 0); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("err = %v, want the backend failure", err)
+	}
+
+	s = NewSearcher(Config{}.Backends)
+	if _, err := s.Search(context.Background(), "a", 0); err != ErrNotConfigured {
+		t.Fatalf("err = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	same := []string{
+		"https://www.example.com/page/",
+		"http://example.com/page",
+		"https://example.com/page?utm_source=x#section",
+	}
+	for _, u := range same[1:] {
+		if normalizeURL(u) != normalizeURL(same[0]) {
+			t.Errorf("normalizeURL(%q) = %q, want %q", u, normalizeURL(u), normalizeURL(same[0]))
+		}
+	}
+	if normalizeURL("https://example.com/page?id=1") == normalizeURL("https://example.com/page?id=2") {
+		t.Error("distinct query strings collapsed")
+	}
+}